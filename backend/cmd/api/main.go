@@ -1,18 +1,30 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // Fusos dos calendários externos, mesmo sem a base do sistema
 
 	"github.com/gin-gonic/gin"
 	"github.com/xclean/backend/internal/config"
 	"github.com/xclean/backend/internal/handlers"
+	"github.com/xclean/backend/internal/jobs"
+	"github.com/xclean/backend/internal/middleware"
+	"github.com/xclean/backend/internal/models"
 	"github.com/xclean/backend/internal/repositories"
 	"github.com/xclean/backend/internal/routes"
 	"github.com/xclean/backend/internal/services"
 )
 
 func main() {
+	// Fuso em que a data e o horário dos agendamentos são expressos
+	models.ScheduleLocation = scheduleLocation()
+	appEnv := config.AppEnvironment()
+
 	// Inicializa a configuração do banco de dados
 	dbConfig := config.NewDatabaseConfig()
 	db, err := dbConfig.Connect()
@@ -22,12 +34,16 @@ func main() {
 
 	// Inicializa o router Gin
 	r := gin.Default()
+	// Só os proxies de TRUSTED_PROXIES podem informar o IP do cliente, usado nos limites de requisições
+	if err := r.SetTrustedProxies(envList("TRUSTED_PROXIES")); err != nil {
+		log.Fatal("TRUSTED_PROXIES inválido:", err)
+	}
 
 	// Configuração de CORS
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -35,18 +51,97 @@ func main() {
 		c.Next()
 	})
 
+	// Estimativa de deslocamento entre atendimentos; troque por um serviço de rotas se houver
+	travelEstimator := services.NewHaversineTravelEstimator(envFloat("TRAVEL_AVERAGE_SPEED_KMH", services.DefaultAverageSpeedKmh))
+
 	// Inicializa repositórios
 	userRepo := repositories.NewUserRepository(db)
-	appointmentRepo := repositories.NewAppointmentRepository(db)
+	appointmentRepo := repositories.NewAppointmentRepository(db, travelEstimator)
+	rescheduleRepo := repositories.NewRescheduleRepository(db, travelEstimator)
+	seriesRepo := repositories.NewSeriesRepository(db, travelEstimator)
+	noShowRepo := repositories.NewNoShowRepository(db)
+	idempotencyRepo := repositories.NewIdempotencyRepository(db)
+	eventRepo := repositories.NewAppointmentEventRepository(db)
+	waitlistRepo := repositories.NewWaitlistRepository(db, travelEstimator)
+	calendarFeedRepo := repositories.NewCalendarFeedRepository(db)
+	externalCalendarRepo := repositories.NewExternalCalendarRepository(db)
+	termsRepo := repositories.NewTermsRepository(db)
+	paymentRepo := repositories.NewPaymentRepository(db)
+	ledgerRepo := repositories.NewLedgerRepository(db)
+	payoutRepo := repositories.NewPayoutRepository(db)
+	commissionRepo := repositories.NewCommissionRepository(db)
+	tipRepo := repositories.NewTipRepository(db)
+	promotionRepo := repositories.NewPromotionRepository(db)
+	webhookRepo := repositories.NewWebhookRepository(db)
 
 	// Inicializa serviços
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "your-secret-key" // Em produção, sempre use uma chave segura via variável de ambiente
+	authService := services.NewAuthService(secretEnv(appEnv, "JWT_SECRET", "your-secret-key"))
+	cancellationService := services.NewCancellationService()
+	pricingService := services.NewPricingService()
+	auditService := services.NewAuditService(eventRepo)
+	visitService := services.NewVisitService(pricingService)
+	seriesService := services.NewSeriesService(seriesRepo, userRepo, pricingService, auditService)
+	pixConfig := services.PixConfig{
+		MerchantName: envString("PIX_MERCHANT_NAME", "XCLEAN"),
+		MerchantCity: envString("PIX_MERCHANT_CITY", "SAO PAULO"),
+		ChargeExpiry: time.Duration(envFloat("PIX_CHARGE_EXPIRY_MINUTES", services.DefaultPixChargeExpiry.Minutes())) * time.Minute,
 	}
-	authService := services.NewAuthService(jwtSecret)
+	pixWebhookSecret := secretEnv(appEnv, "PIX_WEBHOOK_SECRET", "pix-webhook-secret") // Segredo combinado com o PSP
+	paymentGateway := paymentGateway(appEnv)
+	ledgerService := services.NewLedgerService(ledgerRepo, paymentRepo, tipRepo)
+	commissionService := services.NewCommissionService(commissionRepo, userRepo, tipRepo)
+	promotionService := services.NewPromotionService(promotionRepo, ledgerRepo, userRepo, appointmentRepo, ledgerService, pricingService)
+	paymentService := services.NewPaymentService(paymentRepo, appointmentRepo, termsRepo, paymentGateway, pixProvider(appEnv), pixConfig, auditService, ledgerService, commissionService, promotionService)
+	// Webhooks dos provedores de pagamento, gravados e processados com novas tentativas
+	webhookService := services.NewWebhookService(webhookRepo)
+	webhookService.Register(services.GatewayWebhookProvider, services.NewGatewayWebhookSource(secretEnv(appEnv, "GATEWAY_WEBHOOK_SECRET", "gateway-webhook-secret"), paymentService))
+	webhookService.Register(services.PixWebhookProvider, services.NewPixWebhookSource(pixWebhookSecret, paymentService))
+	documentSigner := services.NewDocumentSigner(secretEnv(appEnv, "DOCUMENT_SIGNING_SECRET", "document-signing-secret"))
+	documentService := services.NewDocumentService(paymentRepo, appointmentRepo, ledgerRepo, userRepo, commissionRepo, documentSigner, models.ScheduleLocation)
+	tipService := services.NewTipService(tipRepo, paymentRepo, paymentGateway, ledgerService, auditService)
+	// Provedor de repasses em memória até a integração com o banco
+	payoutService := services.NewPayoutService(payoutRepo, ledgerRepo, userRepo, ledgerService, payoutProvider(appEnv))
+	noShowService := services.NewNoShowService(noShowRepo, appointmentRepo, auditService, paymentService)
+	bookingRulesService := services.NewBookingRulesService()
+	availabilityService := services.NewAvailabilityService(appointmentRepo, userRepo, waitlistRepo, externalCalendarRepo, bookingRulesService)
+	waitlistService := services.NewWaitlistService(waitlistRepo, userRepo, availabilityService, pricingService, bookingRulesService, auditService)
+	calendarService := services.NewCalendarService(calendarFeedRepo)
+	instantBookingService := services.NewInstantBookingService(appointmentRepo)
+	externalCalendarService := services.NewExternalCalendarService(externalCalendarRepo, nil, models.ScheduleLocation)
+
+	// Inicializa handlers
 	authHandler := handlers.NewAuthHandler(authService, userRepo)
-	appointmentHandler := handlers.NewAppointmentHandler(appointmentRepo, userRepo, authService)
+	appointmentHandler := handlers.NewAppointmentHandler(appointmentRepo, userRepo, authService, cancellationService, pricingService, auditService, waitlistService, instantBookingService, bookingRulesService, termsRepo, paymentService, paymentService, promotionService)
+	rescheduleHandler := handlers.NewRescheduleHandler(appointmentRepo, rescheduleRepo, userRepo, authService, bookingRulesService, auditService)
+	seriesHandler := handlers.NewSeriesHandler(seriesRepo, appointmentRepo, userRepo, authService, seriesService, cancellationService, pricingService, auditService, waitlistService, paymentService)
+	visitHandler := handlers.NewVisitHandler(appointmentRepo, userRepo, authService, visitService, auditService, termsRepo, paymentService)
+	noShowHandler := handlers.NewNoShowHandler(appointmentRepo, noShowRepo, userRepo, authService, noShowService)
+	providerHandler := handlers.NewProviderHandler(userRepo, authService, cancellationService, availabilityService)
+	waitlistHandler := handlers.NewWaitlistHandler(waitlistRepo, userRepo, authService, waitlistService)
+	calendarHandler := handlers.NewCalendarHandler(appointmentRepo, authService, calendarService)
+	externalCalendarHandler := handlers.NewExternalCalendarHandler(externalCalendarRepo, userRepo, authService, externalCalendarService)
+	adminHandler := handlers.NewAdminHandler(userRepo)
+	termsHandler := handlers.NewTermsHandler(appointmentRepo, termsRepo, userRepo, authService, auditService)
+	// Simulador de pagamentos Pix, apenas para desenvolvimento
+	var pixSimulator *services.PixWebhookSimulator
+	if os.Getenv("PIX_SIMULATOR") == "true" {
+		webhookURL := envString("PIX_SIMULATOR_WEBHOOK_URL", "http://localhost:"+envString("PORT", "8080")+"/api/payments/pix/webhook")
+		pixSimulator = services.NewPixWebhookSimulator(webhookURL, pixWebhookSecret, nil)
+	}
+	paymentHandler := handlers.NewPaymentHandler(paymentRepo, appointmentRepo, userRepo, authService, paymentService, documentService, tipService, webhookService, pixSimulator)
+	disputeHandler := handlers.NewDisputeHandler(appointmentRepo, paymentRepo, userRepo, authService, paymentService)
+	ledgerHandler := handlers.NewLedgerHandler(ledgerRepo, userRepo, authService, ledgerService)
+	tipHandler := handlers.NewTipHandler(appointmentRepo, tipRepo, authService, tipService)
+	commissionHandler := handlers.NewCommissionHandler(commissionRepo, commissionService)
+	payoutHandler := handlers.NewPayoutHandler(payoutRepo, userRepo, authService, payoutService, commissionService, documentService)
+	documentHandler := handlers.NewDocumentHandler(documentService)
+	promotionHandler := handlers.NewPromotionHandler(promotionRepo, authService, promotionService)
+	webhookHandler := handlers.NewWebhookHandler(webhookRepo, webhookService)
+
+	// Reenvio seguro de requisições POST e PATCH com Idempotency-Key
+	r.Use(middleware.Idempotency(idempotencyRepo, authService))
+	// Rotas exclusivas de admins
+	requireAdmin := middleware.RequireAdmin(authService, userRepo)
 
 	// Rotas de autenticação
 	auth := r.Group("/auth")
@@ -58,6 +153,31 @@ func main() {
 
 	// Rotas de agendamento
 	routes.SetupAppointmentRoutes(r, appointmentHandler)
+	routes.SetupRescheduleRoutes(r, rescheduleHandler)
+	routes.SetupSeriesRoutes(r, seriesHandler)
+	routes.SetupVisitRoutes(r, visitHandler)
+	routes.SetupNoShowRoutes(r, noShowHandler, requireAdmin)
+	routes.SetupWaitlistRoutes(r, waitlistHandler)
+	routes.SetupCalendarRoutes(r, calendarHandler)
+	routes.SetupTermsRoutes(r, termsHandler)
+
+	// Rotas de pagamentos
+	routes.SetupPaymentRoutes(r, paymentHandler)
+	routes.SetupDisputeRoutes(r, disputeHandler, requireAdmin)
+	routes.SetupLedgerRoutes(r, ledgerHandler, requireAdmin)
+	routes.SetupTipRoutes(r, tipHandler)
+	routes.SetupPromotionRoutes(r, promotionHandler, requireAdmin)
+	routes.SetupDocumentRoutes(r, documentHandler)
+	routes.SetupWebhookRoutes(r, webhookHandler, requireAdmin)
+
+	// Rotas de prestadoras
+	routes.SetupProviderRoutes(r, providerHandler)
+	routes.SetupExternalCalendarRoutes(r, externalCalendarHandler)
+	routes.SetupPayoutRoutes(r, payoutHandler)
+
+	// Rotas de administração
+	routes.SetupAdminRoutes(r, adminHandler, requireAdmin)
+	routes.SetupCommissionRoutes(r, commissionHandler, requireAdmin)
 
 	// Rota de healthcheck
 	r.GET("/health", func(c *gin.Context) {
@@ -66,6 +186,25 @@ func main() {
 		})
 	})
 
+	// Tarefas periódicas
+	scheduler := jobs.NewScheduler()
+	scheduler.Add("materializar séries", time.Hour, seriesService.MaterializeDue)
+	scheduler.Add("resolver ausências", 15*time.Minute, noShowService.ProcessExpired)
+	scheduler.Add("lista de espera", time.Minute, waitlistService.ProcessExpired)
+	scheduler.Add("sincronizar calendários externos", 30*time.Minute, externalCalendarService.SyncAll)
+	scheduler.Add("expirar cobranças Pix", time.Minute, paymentService.ExpirePixCharges)
+	scheduler.Add("repassar pagamentos em custódia", 15*time.Minute, paymentService.ReleaseDueEscrows)
+	scheduler.Add("refazer reembolsos de cancelamento", 15*time.Minute, paymentService.RetryCancellationRefunds)
+	scheduler.Add("processar saques", 15*time.Minute, payoutService.ProcessPayouts)
+	scheduler.Add("repasses automáticos", 24*time.Hour, payoutService.RunAutoPayouts)
+	scheduler.Add("conferir gorjetas pendentes", 10*time.Minute, tipService.ReconcilePending)
+	scheduler.Add("recompensar indicações", 15*time.Minute, promotionService.RewardReferrals)
+	scheduler.Add("processar webhooks", time.Minute, webhookService.ProcessDue)
+	scheduler.Add("limpar chaves de idempotência", time.Hour, func() error {
+		return idempotencyRepo.DeleteExpired(time.Now())
+	})
+	scheduler.Start(context.Background())
+
 	// Inicia o servidor
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -76,3 +215,107 @@ func main() {
 		log.Fatal("Erro ao iniciar o servidor: ", err)
 	}
 }
+
+// scheduleLocation retorna o fuso em que os horários dos agendamentos são expressos,
+// configurável por APP_TIMEZONE
+func scheduleLocation() *time.Location {
+	name := os.Getenv("APP_TIMEZONE")
+	if name == "" {
+		name = "America/Sao_Paulo"
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("Fuso %q inválido, usando UTC: %v", name, err)
+		return time.UTC
+	}
+	return location
+}
+
+// fakeProvider é o nome dos provedores em memória, os únicos integrados por enquanto
+const fakeProvider = "fake"
+
+// providerName lê o provedor configurado em key, que por padrão é o provedor em memória.
+// Enquanto não houver integração com um provedor real, ele é aceito em qualquer ambiente,
+// com um aviso fora de desenvolvimento e testes: nenhum valor é cobrado ou transferido.
+func providerName(env config.Environment, key string) string {
+	name := envString(key, fakeProvider)
+	if name == fakeProvider && !env.IsDevelopment() {
+		log.Printf("Aviso: %s=%s no ambiente %s; nenhum valor é cobrado ou transferido de verdade", key, fakeProvider, env)
+	}
+	return name
+}
+
+// paymentGateway escolhe o gateway de cartão por PAYMENT_GATEWAY
+func paymentGateway(env config.Environment) services.PaymentGateway {
+	switch name := providerName(env, "PAYMENT_GATEWAY"); name {
+	case fakeProvider:
+		return services.NewFakePaymentGateway()
+	default:
+		log.Fatalf("Gateway de pagamento %q não suportado", name)
+		return nil
+	}
+}
+
+// pixProvider escolhe o PSP das cobranças Pix por PIX_PROVIDER
+func pixProvider(env config.Environment) services.PixProvider {
+	switch name := providerName(env, "PIX_PROVIDER"); name {
+	case fakeProvider:
+		return services.NewFakePixProvider()
+	default:
+		log.Fatalf("PSP Pix %q não suportado", name)
+		return nil
+	}
+}
+
+// payoutProvider escolhe o provedor dos saques das prestadoras por PAYOUT_PROVIDER
+func payoutProvider(env config.Environment) services.PayoutProvider {
+	switch name := providerName(env, "PAYOUT_PROVIDER"); name {
+	case fakeProvider:
+		return services.NewFakePayoutProvider()
+	default:
+		log.Fatalf("Provedor de repasses %q não suportado", name)
+		return nil
+	}
+}
+
+// secretEnv lê um segredo de key. O valor de desenvolvimento só é usado com APP_ENV=development
+// ou test; nos demais ambientes, o segredo precisa ser informado.
+func secretEnv(env config.Environment, key, devFallback string) string {
+	secret := os.Getenv(key)
+	if secret == "" {
+		if !env.IsDevelopment() {
+			log.Fatalf("%s não configurado para o ambiente %s", key, env)
+		}
+		secret = devFallback
+	}
+	return secret
+}
+
+// envString lê uma variável de ambiente, usando fallback se ausente
+func envString(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// envList lê uma lista separada por vírgulas de uma variável de ambiente
+func envList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// envFloat lê um número de uma variável de ambiente, usando fallback se ausente ou inválido
+func envFloat(key string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
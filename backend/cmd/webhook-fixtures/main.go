@@ -0,0 +1,111 @@
+// webhook-fixtures envia ao backend local as notificações de exemplo do gateway de cartão,
+// assinadas como as reais, para testar o recebimento e o processamento dos webhooks.
+//
+//	go run ./cmd/webhook-fixtures -intent pi_123 -amount 15000 testdata/webhooks/gateway/payment_succeeded.json
+//
+// Sem arquivos, envia todos os exemplos de -dir. Cada arquivo pode usar {{intent_id}},
+// {{amount}} e {{created}}, substituídos antes da assinatura. Enviar o mesmo arquivo duas
+// vezes deve resultar em um evento duplicado.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xclean/backend/internal/services"
+)
+
+func main() {
+	url := flag.String("url", "http://localhost:8080/api/webhooks/gateway", "endereço do webhook")
+	secret := flag.String("secret", envString("GATEWAY_WEBHOOK_SECRET", "gateway-webhook-secret"), "segredo da assinatura")
+	dir := flag.String("dir", "testdata/webhooks/gateway", "pasta com os exemplos")
+	intent := flag.String("intent", "", "ID da intenção de pagamento no gateway")
+	amount := flag.Int64("amount", 0, "valor do evento em centavos")
+	flag.Parse()
+
+	if *intent == "" {
+		log.Fatal("Informe a intenção de pagamento com -intent")
+	}
+
+	files := flag.Args()
+	if len(files) == 0 {
+		matches, err := filepath.Glob(filepath.Join(*dir, "*.json"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		files = matches
+	}
+	if len(files) == 0 {
+		log.Fatalf("Nenhum exemplo encontrado em %s", *dir)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	failed := false
+	for _, file := range files {
+		status, response, err := post(client, *url, *secret, file, *intent, *amount)
+		if err != nil {
+			log.Printf("%s: %v", file, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("%s: %d %s\n", filepath.Base(file), status, strings.TrimSpace(response))
+		if status != http.StatusOK {
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// post preenche o exemplo, assina o corpo e envia ao webhook
+func post(client *http.Client, url, secret, file, intent string, amount int64) (int, string, error) {
+	template, err := os.ReadFile(file)
+	if err != nil {
+		return 0, "", err
+	}
+
+	now := time.Now()
+	body := []byte(strings.NewReplacer(
+		"{{intent_id}}", intent,
+		"{{amount}}", strconv.FormatInt(amount, 10),
+		"{{created}}", strconv.FormatInt(now.Unix(), 10),
+	).Replace(string(template)))
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(services.GatewayTimestampHeader, timestamp)
+	req.Header.Set(services.GatewaySignatureHeader, services.SignWebhook(secret, timestamp, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	response, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, "", err
+	}
+	return resp.StatusCode, string(response), nil
+}
+
+func envString(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
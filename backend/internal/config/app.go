@@ -0,0 +1,32 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// Environment é o ambiente em que a API roda, definido por APP_ENV
+type Environment string
+
+const (
+	EnvironmentProduction  Environment = "production"
+	EnvironmentDevelopment Environment = "development"
+	EnvironmentTest        Environment = "test"
+)
+
+// AppEnvironment retorna o ambiente configurado em APP_ENV. Sem a variável, assume produção,
+// para que provedores em memória e segredos de exemplo nunca sejam usados por engano.
+func AppEnvironment() Environment {
+	switch env := strings.ToLower(strings.TrimSpace(os.Getenv("APP_ENV"))); env {
+	case "dev", string(EnvironmentDevelopment):
+		return EnvironmentDevelopment
+	case string(EnvironmentTest):
+		return EnvironmentTest
+	}
+	return EnvironmentProduction
+}
+
+// IsDevelopment indica se o ambiente aceita provedores em memória e segredos de exemplo
+func (e Environment) IsDevelopment() bool {
+	return e == EnvironmentDevelopment || e == EnvironmentTest
+}
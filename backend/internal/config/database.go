@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/xclean/backend/internal/models"
 	"gorm.io/driver/postgres"
@@ -43,16 +44,95 @@ func (c *DatabaseConfig) Connect() (*gorm.DB, error) {
 	}
 
 	// Auto-migra os modelos
-	err = db.AutoMigrate(
+	if err := Migrate(db); err != nil {
+		return nil, fmt.Errorf("erro ao migrar o banco de dados: %v", err)
+	}
+
+	log.Println("Banco de dados conectado com sucesso")
+	return db, nil
+}
+
+// Migrate cria ou atualiza as tabelas de todos os modelos
+func Migrate(db *gorm.DB) error {
+	err := db.AutoMigrate(
 		&models.User{},
 		&models.ProviderProfile{},
+		&models.Appointment{},
+		&models.RescheduleRequest{},
+		&models.AppointmentSeries{},
+		&models.SeriesConflict{},
+		&models.NoShowClaim{},
+		&models.IdempotencyKey{},
+		&models.AppointmentEvent{},
+		&models.WaitlistEntry{},
+		&models.WaitlistOffer{},
+		&models.CalendarFeed{},
+		&models.ExternalCalendar{},
+		&models.ExternalBusyBlock{},
+		&models.AppointmentTerms{},
+		&models.TermsAcknowledgement{},
+		&models.Payment{},
+		&models.Refund{},
+		&models.RefundRequest{},
+		&models.PaymentMethod{},
+		&models.PaymentDispute{},
+		&models.LedgerAccount{},
+		&models.LedgerTransaction{},
+		&models.LedgerPosting{},
+		&models.PayoutDestination{},
+		&models.Payout{},
+		&models.CommissionRule{},
+		&models.PaymentSettlement{},
+		&models.Tip{},
+		&models.PromoCode{},
+		&models.PromoRedemption{},
+		&models.ReferralCode{},
+		&models.Referral{},
+		&models.WebhookEvent{},
+		&models.WebhookDelivery{},
 	)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao migrar o banco de dados: %v", err)
+		return err
 	}
+	return protectLedger(db)
+}
 
-	log.Println("Banco de dados conectado com sucesso")
-	return db, nil
+// ledgerTables são as tabelas do razão, que só aceitam inclusões
+var ledgerTables = []string{"ledger_transactions", "ledger_postings"}
+
+// protectLedger cria gatilhos que recusam alterar ou apagar lançamentos e partidas do razão.
+// Correções são feitas com novos lançamentos de estorno.
+func protectLedger(db *gorm.DB) error {
+	var statements []string
+	switch db.Dialector.Name() {
+	case "postgres":
+		statements = append(statements, `CREATE OR REPLACE FUNCTION ledger_reject_change() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'o razão não aceita alterar ou apagar lançamentos';
+END;
+$$ LANGUAGE plpgsql`)
+		for _, table := range ledgerTables {
+			statements = append(statements,
+				fmt.Sprintf("DROP TRIGGER IF EXISTS %s_immutable ON %s", table, table),
+				fmt.Sprintf("CREATE TRIGGER %s_immutable BEFORE UPDATE OR DELETE ON %s FOR EACH ROW EXECUTE FUNCTION ledger_reject_change()", table, table),
+			)
+		}
+	case "sqlite":
+		for _, table := range ledgerTables {
+			for _, operation := range []string{"UPDATE", "DELETE"} {
+				statements = append(statements, fmt.Sprintf(
+					"CREATE TRIGGER IF NOT EXISTS %s_no_%s BEFORE %s ON %s BEGIN SELECT RAISE(ABORT, 'o razão não aceita alterar ou apagar lançamentos'); END",
+					table, strings.ToLower(operation), operation, table))
+			}
+		}
+	}
+
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // getEnv retorna o valor de uma variável de ambiente ou um valor padrão
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+)
+
+type AdminHandler struct {
+	userRepo *repositories.UserRepository
+}
+
+func NewAdminHandler(userRepo *repositories.UserRepository) *AdminHandler {
+	return &AdminHandler{
+		userRepo: userRepo,
+	}
+}
+
+// SetUserVerification marca ou desmarca a identidade de um usuário como verificada
+func (h *AdminHandler) SetUserVerification(c *gin.Context) {
+	targetID, err := parseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req struct {
+		IsVerified *bool `json:"is_verified" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userRepo.FindByID(targetID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Usuário não encontrado"})
+		return
+	}
+
+	user.IsVerified = *req.IsVerified
+	if err := h.userRepo.Update(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar usuário"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// SetProviderTier altera a categoria comercial de uma prestadora, usada nas regras de comissão
+func (h *AdminHandler) SetProviderTier(c *gin.Context) {
+	targetID, err := parseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req struct {
+		Tier models.ProviderTier `json:"tier" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.Tier.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Categoria inválida"})
+		return
+	}
+
+	user, err := h.userRepo.FindByID(targetID)
+	if err != nil || user.UserType != models.UserTypeProvider {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prestadora não encontrada"})
+		return
+	}
+
+	profile, err := h.userRepo.GetProviderProfile(targetID)
+	if errors.Is(err, repositories.ErrUserNotFound) {
+		profile = &models.ProviderProfile{
+			UserID:             targetID,
+			CancellationPolicy: models.CancellationPolicyFlexible,
+			Tier:               req.Tier,
+		}
+		err = h.userRepo.CreateProviderProfile(profile)
+	} else if err == nil {
+		profile.Tier = req.Tier
+		err = h.userRepo.UpdateProviderProfile(profile)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar prestadora"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"slices"
 	"strconv"
 	"time"
 
@@ -12,20 +16,50 @@ import (
 )
 
 type AppointmentHandler struct {
-	appointmentRepo *repositories.AppointmentRepository
-	userRepo        *repositories.UserRepository
-	authService     *services.AuthService
+	appointmentRepo     *repositories.AppointmentRepository
+	userRepo            *repositories.UserRepository
+	authService         *services.AuthService
+	cancellationService *services.CancellationService
+	pricingService      *services.PricingService
+	auditService        *services.AuditService
+	waitlistService     *services.WaitlistService
+	instantBooking      *services.InstantBookingService
+	bookingRules        *services.BookingRulesService
+	termsRepo           *repositories.TermsRepository
+	refundIssuer        services.RefundIssuer
+	escrow              services.EscrowScheduler
+	promotions          *services.PromotionService
 }
 
 func NewAppointmentHandler(
 	appointmentRepo *repositories.AppointmentRepository,
 	userRepo *repositories.UserRepository,
 	authService *services.AuthService,
+	cancellationService *services.CancellationService,
+	pricingService *services.PricingService,
+	auditService *services.AuditService,
+	waitlistService *services.WaitlistService,
+	instantBooking *services.InstantBookingService,
+	bookingRules *services.BookingRulesService,
+	termsRepo *repositories.TermsRepository,
+	refundIssuer services.RefundIssuer,
+	escrow services.EscrowScheduler,
+	promotions *services.PromotionService,
 ) *AppointmentHandler {
 	return &AppointmentHandler{
-		appointmentRepo: appointmentRepo,
-		userRepo:        userRepo,
-		authService:     authService,
+		appointmentRepo:     appointmentRepo,
+		userRepo:            userRepo,
+		authService:         authService,
+		cancellationService: cancellationService,
+		pricingService:      pricingService,
+		auditService:        auditService,
+		waitlistService:     waitlistService,
+		instantBooking:      instantBooking,
+		bookingRules:        bookingRules,
+		termsRepo:           termsRepo,
+		refundIssuer:        refundIssuer,
+		escrow:              escrow,
+		promotions:          promotions,
 	}
 }
 
@@ -34,10 +68,13 @@ type CreateAppointmentRequest struct {
 	Service    string    `json:"service" binding:"required"`
 	Date       time.Time `json:"date" binding:"required"`
 	Time       string    `json:"time" binding:"required"`
+	Duration   int       `json:"duration"` // Duração em minutos; usa o padrão se omitida
 	Notes      string    `json:"notes"`
 	Location   string    `json:"location"`
 	Latitude   float64   `json:"latitude"`
 	Longitude  float64   `json:"longitude"`
+	PromoCode  string    `json:"promo_code"`
+	UseCredit  *bool     `json:"use_credit"` // Usa os créditos da carteira; padrão true
 }
 
 // CreateAppointment cria um novo agendamento
@@ -67,6 +104,10 @@ func (h *AppointmentHandler) CreateAppointment(c *gin.Context) {
 		return
 	}
 
+	if req.Duration <= 0 {
+		req.Duration = models.DefaultAppointmentDuration
+	}
+
 	// Criar agendamento
 	appointment := &models.Appointment{
 		UserID:     userID,
@@ -76,12 +117,87 @@ func (h *AppointmentHandler) CreateAppointment(c *gin.Context) {
 		Time:       req.Time,
 		Status:     models.AppointmentStatusPending,
 		Notes:      req.Notes,
+		Duration:   req.Duration,
 		Location:   req.Location,
 		Latitude:   req.Latitude,
 		Longitude:  req.Longitude,
 	}
 
-	if err := h.appointmentRepo.Create(appointment); err != nil {
+	profile, err := h.userRepo.GetProviderProfile(req.ProviderID)
+	if err != nil {
+		profile = nil
+	}
+
+	// Respeitar a antecedência mínima e o horizonte de agendamento da prestadora
+	if err := h.bookingRules.CheckWindow(profile, appointment.StartsAt()); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Calcular preço pelo valor por hora da prestadora
+	if profile != nil {
+		appointment.Price = h.pricingService.Quote(profile.HourlyRate, appointment.Duration)
+
+		// Clientes que atendem aos critérios da prestadora têm o agendamento confirmado na hora
+		client, err := h.userRepo.FindByID(userID)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+			return
+		}
+		instant, err := h.instantBooking.Qualifies(profile, client)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar agendamento"})
+			return
+		}
+		if instant {
+			appointment.Status = models.AppointmentStatusConfirmed
+			appointment.InstantBooked = true
+		}
+	}
+
+	// Aplicar o cupom e os créditos da carteira sobre o preço
+	var incentives *repositories.BookingIncentives
+	if appointment.Price > 0 {
+		useCredit := req.UseCredit == nil || *req.UseCredit
+		incentives, _, err = h.promotions.PrepareBooking(userID, services.ToMinorUnits(appointment.Price), req.PromoCode, useCredit)
+		if err != nil {
+			respondPromotionError(c, err)
+			return
+		}
+	} else if req.PromoCode != "" {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": services.ErrPromoCodeMinSpend.Error()})
+		return
+	}
+
+	if err := h.appointmentRepo.CreateWithoutConflict(appointment, incentives, func(created *models.Appointment) []*models.AppointmentEvent {
+		details := map[string]interface{}{
+			"status":         created.Status,
+			"date":           created.Date,
+			"time":           created.Time,
+			"price":          created.Price,
+			"instant_booked": created.InstantBooked,
+		}
+		if incentives != nil {
+			if incentives.PromoCode != nil {
+				details["promo_code"] = incentives.PromoCode.Code
+				details["promo_discount"] = created.PromoDiscount
+			}
+			details["credit_applied"] = created.CreditApplied
+		}
+		return []*models.AppointmentEvent{h.auditService.Event(created.ID, &userID, models.AppointmentEventCreated, nil, details)}
+	}); err != nil {
+		if errors.Is(err, repositories.ErrSlotUnavailable) || errors.Is(err, repositories.ErrCreditChanged) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if repositories.IsBookingCapError(err) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		if incentives != nil {
+			respondPromotionError(c, err)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar agendamento"})
 		return
 	}
@@ -98,17 +214,20 @@ func (h *AppointmentHandler) GetUserAppointments(c *gin.Context) {
 		return
 	}
 
-	// Obter status do query param (opcional)
-	status := c.Query("status")
+	filter, err := parseAppointmentFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Buscar agendamentos
-	appointments, err := h.appointmentRepo.GetUserAppointments(userID, status)
+	page, err := h.appointmentRepo.GetUserAppointments(userID, filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar agendamentos"})
 		return
 	}
 
-	c.JSON(http.StatusOK, appointments)
+	c.JSON(http.StatusOK, newAppointmentPageResponse(page))
 }
 
 // GetProviderAppointments retorna os agendamentos da prestadora autenticada
@@ -127,17 +246,51 @@ func (h *AppointmentHandler) GetProviderAppointments(c *gin.Context) {
 		return
 	}
 
-	// Obter status do query param (opcional)
-	status := c.Query("status")
+	filter, err := parseAppointmentFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Buscar agendamentos
-	appointments, err := h.appointmentRepo.GetProviderAppointments(userID, status)
+	page, err := h.appointmentRepo.GetProviderAppointments(userID, filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar agendamentos"})
 		return
 	}
 
-	c.JSON(http.StatusOK, appointments)
+	c.JSON(http.StatusOK, newAppointmentPageResponse(page))
+}
+
+// GetAppointment retorna os detalhes de um agendamento para seus participantes ou admins
+func (h *AppointmentHandler) GetAppointment(c *gin.Context) {
+	// Obter usuário autenticado
+	userID, err := h.getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	appointmentID, err := parseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	appointment, err := h.appointmentRepo.FindDetailByID(appointmentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agendamento não encontrado"})
+		return
+	}
+
+	if userID != appointment.UserID && userID != appointment.ProviderID {
+		if !isAdmin(h.userRepo, userID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Acesso negado"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, newAppointmentDetailResponse(appointment))
 }
 
 // UpdateAppointmentStatus atualiza o status de um agendamento
@@ -159,6 +312,7 @@ func (h *AppointmentHandler) UpdateAppointmentStatus(c *gin.Context) {
 	// Obter status do body
 	var req struct {
 		Status string `json:"status" binding:"required"`
+		Reason string `json:"reason"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -179,7 +333,7 @@ func (h *AppointmentHandler) UpdateAppointmentStatus(c *gin.Context) {
 		return
 	}
 
-	// Apenas o cliente ou a prestadora podem atualizar o status
+	// Apenas o cliente, a prestadora ou um admin podem atualizar o status
 	if user.UserType != models.UserTypeAdmin &&
 		userID != appointment.UserID &&
 		userID != appointment.ProviderID {
@@ -187,15 +341,274 @@ func (h *AppointmentHandler) UpdateAppointmentStatus(c *gin.Context) {
 		return
 	}
 
+	status := models.AppointmentStatus(req.Status)
+	if !authorizeStatusChange(c, user, appointment, status) {
+		return
+	}
+
+	// Cancelamentos aplicam a política da prestadora
+	if status == models.AppointmentStatusCancelled {
+		h.cancelAppointment(c, appointment, userID, req.Reason)
+		return
+	}
+
+	// A prestadora precisa dar ciência das condições antes de confirmar
+	if status == models.AppointmentStatusConfirmed {
+		if err := h.termsRepo.EnsureAcknowledged(appointment.ID); err != nil {
+			if errors.Is(err, repositories.ErrTermsNotAcknowledged) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar condições"})
+			return
+		}
+	}
+
 	// Atualizar status
-	if err := h.appointmentRepo.UpdateStatus(uint(appointmentID), models.AppointmentStatus(req.Status)); err != nil {
+	changed := h.auditService.Event(appointment.ID, &userID, models.AppointmentEventStatusChanged, appointment.Status, status)
+	if err := h.appointmentRepo.TransitionStatus(appointment.ID, appointment.Status, status, changed); err != nil {
+		if errors.Is(err, repositories.ErrAppointmentStatusChanged) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar status"})
 		return
 	}
 
+	// Conclusão pelo admin, sem check-out: o repasse conta a partir de agora
+	if status == models.AppointmentStatusCompleted && h.escrow != nil {
+		if err := h.escrow.ScheduleEscrowRelease(appointment); err != nil {
+			log.Printf("Erro ao agendar repasse do agendamento %d: %v", appointment.ID, err)
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Status atualizado com sucesso"})
 }
 
+// appointmentRole é o papel de quem altera o status de um agendamento
+type appointmentRole string
+
+const (
+	roleClient   appointmentRole = "client"
+	roleProvider appointmentRole = "provider"
+	roleAdmin    appointmentRole = "admin"
+)
+
+// statusTransition lista, por papel, de quais status o agendamento pode ir para um destino
+type statusTransition struct {
+	from      map[appointmentRole][]models.AppointmentStatus
+	forbidden string // Resposta a quem não tem nenhuma transição para o destino
+}
+
+// statusTransitions são as mudanças de status permitidas pela atualização direta.
+// Check-in, check-out e ausência têm fluxos próprios; a conclusão direta é reservada ao
+// admin, e um agendamento concluído ou cancelado não volta a nenhum outro status.
+var statusTransitions = map[models.AppointmentStatus]statusTransition{
+	models.AppointmentStatusConfirmed: {
+		from:      map[appointmentRole][]models.AppointmentStatus{roleProvider: {models.AppointmentStatusPending}},
+		forbidden: "Apenas a prestadora pode confirmar o agendamento",
+	},
+	models.AppointmentStatusCancelled: {
+		from: map[appointmentRole][]models.AppointmentStatus{
+			roleClient:   {models.AppointmentStatusPending, models.AppointmentStatusConfirmed},
+			roleProvider: {models.AppointmentStatusPending, models.AppointmentStatusConfirmed},
+			roleAdmin:    {models.AppointmentStatusPending, models.AppointmentStatusConfirmed},
+		},
+		forbidden: "Acesso negado",
+	},
+	models.AppointmentStatusCompleted: {
+		from: map[appointmentRole][]models.AppointmentStatus{
+			roleAdmin: {models.AppointmentStatusConfirmed, models.AppointmentStatusInProgress},
+		},
+		forbidden: "O atendimento é concluído pelo check-out",
+	},
+}
+
+// authorizeStatusChange confere se o usuário pode levar o agendamento ao status informado.
+// Em caso de erro, a resposta já é enviada e o retorno é falso.
+func authorizeStatusChange(c *gin.Context, user *models.User, appointment *models.Appointment, status models.AppointmentStatus) bool {
+	switch status {
+	case models.AppointmentStatusPending, models.AppointmentStatusConfirmed, models.AppointmentStatusInProgress,
+		models.AppointmentStatusCompleted, models.AppointmentStatusCancelled, models.AppointmentStatusNoShow:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status inválido"})
+		return false
+	}
+
+	transition, ok := statusTransitions[status]
+	if !ok {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Este status não pode ser definido diretamente"})
+		return false
+	}
+
+	var roles []appointmentRole
+	if user.ID == appointment.UserID {
+		roles = append(roles, roleClient)
+	}
+	if user.ID == appointment.ProviderID {
+		roles = append(roles, roleProvider)
+	}
+	if user.UserType == models.UserTypeAdmin {
+		roles = append(roles, roleAdmin)
+	}
+
+	allowed := false
+	for _, role := range roles {
+		from, ok := transition.from[role]
+		if !ok {
+			continue
+		}
+		allowed = true
+		if slices.Contains(from, appointment.Status) {
+			return true
+		}
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": transition.forbidden})
+		return false
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("O agendamento não pode passar de %s para %s", appointment.Status, status)})
+	return false
+}
+
+// cancelAppointment cancela o agendamento calculando a taxa conforme a política da prestadora
+func (h *AppointmentHandler) cancelAppointment(c *gin.Context, appointment *models.Appointment, userID uint, reason string) {
+	previousStatus := appointment.Status
+	policy := models.CancellationPolicyFlexible
+	if profile, err := h.userRepo.GetProviderProfile(appointment.ProviderID); err == nil {
+		policy = profile.CancellationPolicy
+	}
+
+	if err := h.cancellationService.Cancel(appointment, policy, userID, reason); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	cancelled := h.auditService.Event(appointment.ID, &userID, models.AppointmentEventCancelled, previousStatus, map[string]interface{}{
+		"status":           appointment.Status,
+		"reason":           appointment.CancellationReason,
+		"cancellation_fee": appointment.CancellationFee,
+	})
+	if err := h.appointmentRepo.Cancel(appointment, cancelled); err != nil {
+		if errors.Is(err, repositories.ErrAppointmentStatusChanged) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao cancelar agendamento"})
+		return
+	}
+
+	// Oferece o horário liberado à lista de espera da prestadora
+	if err := h.waitlistService.SlotFreed(appointment); err != nil {
+		log.Printf("Erro ao oferecer vaga do agendamento %d à lista de espera: %v", appointment.ID, err)
+	}
+
+	// Repassa o reembolso parcial para a camada de pagamentos. Se falhar, ele continua
+	// pendente e é refeito pela tarefa periódica.
+	refundPending := false
+	if h.refundIssuer != nil {
+		if err := h.refundIssuer.RefundCancellation(appointment); err != nil {
+			log.Printf("Erro ao reembolsar agendamento %d cancelado: %v", appointment.ID, err)
+			refundPending = true
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Agendamento cancelado com sucesso",
+		"cancellation_fee": appointment.CancellationFee,
+		"refund_pending":   refundPending,
+	})
+}
+
+// RateClient permite que a prestadora avalie o cliente após um atendimento concluído
+func (h *AppointmentHandler) RateClient(c *gin.Context) {
+	// Obter usuário autenticado
+	userID, err := h.getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	appointmentID, err := parseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req struct {
+		Rating int `json:"rating" binding:"required,min=1,max=5"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	appointment, err := h.appointmentRepo.FindByID(appointmentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agendamento não encontrado"})
+		return
+	}
+
+	if appointment.ProviderID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Acesso negado"})
+		return
+	}
+
+	if appointment.Status != models.AppointmentStatusCompleted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Apenas atendimentos concluídos podem ser avaliados"})
+		return
+	}
+
+	if err := h.appointmentRepo.RateClient(appointment, req.Rating); err != nil {
+		if errors.Is(err, repositories.ErrClientAlreadyRated) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao avaliar cliente"})
+		return
+	}
+
+	c.JSON(http.StatusOK, appointment)
+}
+
+// GetAppointmentHistory retorna o histórico de alterações de um agendamento
+func (h *AppointmentHandler) GetAppointmentHistory(c *gin.Context) {
+	// Obter usuário autenticado
+	userID, err := h.getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	appointmentID, err := parseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	appointment, err := h.appointmentRepo.FindByID(appointmentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agendamento não encontrado"})
+		return
+	}
+
+	// Apenas participantes e admins podem ver o histórico
+	if userID != appointment.UserID && userID != appointment.ProviderID {
+		if !isAdmin(h.userRepo, userID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Acesso negado"})
+			return
+		}
+	}
+
+	events, err := h.auditService.History(appointment.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar histórico"})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
 // GetAvailableProviders retorna as prestadoras disponíveis para um determinado horário
 func (h *AppointmentHandler) GetAvailableProviders(c *gin.Context) {
 	// Obter data do query param
@@ -230,30 +643,5 @@ func (h *AppointmentHandler) GetAvailableProviders(c *gin.Context) {
 
 // getUserIDFromToken extrai o ID do usuário do token JWT
 func (h *AppointmentHandler) getUserIDFromToken(c *gin.Context) (uint, error) {
-	// Extrair token do header
-	authHeader := c.GetHeader("Authorization")
-	if authHeader == "" {
-		return 0, nil
-	}
-
-	// Validar formato do token
-	if len(authHeader) < 7 || authHeader[:7] != "Bearer " {
-		return 0, nil
-	}
-
-	tokenString := authHeader[7:]
-
-	// Validar token
-	claims, err := h.authService.ValidateToken(tokenString)
-	if err != nil {
-		return 0, err
-	}
-
-	// Extrair ID do usuário
-	userID, ok := claims["user_id"].(float64)
-	if !ok {
-		return 0, nil
-	}
-
-	return uint(userID), nil
+	return userIDFromToken(c, h.authService)
 }
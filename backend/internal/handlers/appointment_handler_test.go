@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/testutil"
+	"github.com/xclean/backend/internal/testutil/apptest"
+)
+
+func newAppointmentHandler(app *apptest.App) *AppointmentHandler {
+	return NewAppointmentHandler(app.AppointmentRepo, app.UserRepo, app.AuthService, app.CancellationService, app.PricingService,
+		app.AuditService, app.WaitlistService, app.InstantBookingService, app.BookingRulesService, app.TermsRepo, nil, nil, nil)
+}
+
+// updateStatus envia a alteração de status do agendamento em nome do usuário
+func updateStatus(t *testing.T, router *gin.Engine, app *apptest.App, user *models.User, appointmentID uint, status models.AppointmentStatus) *httptest.ResponseRecorder {
+	t.Helper()
+	body := fmt.Sprintf(`{"status": %q}`, status)
+	req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/appointments/%d/status", appointmentID), strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", app.Bearer(t, user))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestOnlyProviderConfirmsAppointment(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name     string
+		actor    string
+		wantCode int
+	}{
+		{"prestadora", "provider", http.StatusOK},
+		{"cliente", "client", http.StatusForbidden},
+		{"administrador", "admin", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := apptest.New(t)
+			db := app.DB
+			actors := map[string]*models.User{
+				"client":   testutil.CreateUser(t, db, models.UserTypeClient),
+				"provider": testutil.CreateProvider(t, db, models.ProviderProfile{}),
+				"admin":    testutil.CreateUser(t, db, models.UserTypeAdmin),
+			}
+			appointment := testutil.CreateAppointment(t, db, models.Appointment{
+				UserID: actors["client"].ID, ProviderID: actors["provider"].ID, Status: models.AppointmentStatusPending,
+				Date: time.Now().UTC().AddDate(0, 0, 5).Truncate(24 * time.Hour),
+			})
+
+			router := gin.New()
+			router.PUT("/api/appointments/:id/status", newAppointmentHandler(app).UpdateAppointmentStatus)
+
+			w := updateStatus(t, router, app, actors[tt.actor], appointment.ID, models.AppointmentStatusConfirmed)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status %d: %s; want %d", w.Code, w.Body.String(), tt.wantCode)
+			}
+
+			var stored models.Appointment
+			db.First(&stored, appointment.ID)
+			want := models.AppointmentStatusPending
+			if tt.wantCode == http.StatusOK {
+				want = models.AppointmentStatusConfirmed
+			}
+			if stored.Status != want {
+				t.Errorf("status %s, want %s", stored.Status, want)
+			}
+		})
+	}
+}
+
+func TestUpdateAppointmentStatusTransitions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name     string
+		actor    string
+		from     models.AppointmentStatus
+		to       models.AppointmentStatus
+		wantCode int
+	}{
+		{"cliente cancela pendente", "client", models.AppointmentStatusPending, models.AppointmentStatusCancelled, http.StatusOK},
+		{"admin cancela confirmado", "admin", models.AppointmentStatusConfirmed, models.AppointmentStatusCancelled, http.StatusOK},
+		{"cliente cancela concluído", "client", models.AppointmentStatusCompleted, models.AppointmentStatusCancelled, http.StatusConflict},
+		{"prestadora reabre concluído", "provider", models.AppointmentStatusCompleted, models.AppointmentStatusPending, http.StatusUnprocessableEntity},
+		{"prestadora conclui sem check-out", "provider", models.AppointmentStatusInProgress, models.AppointmentStatusCompleted, http.StatusForbidden},
+		{"admin conclui em andamento", "admin", models.AppointmentStatusInProgress, models.AppointmentStatusCompleted, http.StatusOK},
+		{"prestadora confirma já confirmado", "provider", models.AppointmentStatusConfirmed, models.AppointmentStatusConfirmed, http.StatusConflict},
+		{"status desconhecido", "provider", models.AppointmentStatusPending, "finished", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := apptest.New(t)
+			db := app.DB
+			actors := map[string]*models.User{
+				"client":   testutil.CreateUser(t, db, models.UserTypeClient),
+				"provider": testutil.CreateProvider(t, db, models.ProviderProfile{}),
+				"admin":    testutil.CreateUser(t, db, models.UserTypeAdmin),
+			}
+			appointment := testutil.CreateAppointment(t, db, models.Appointment{
+				UserID: actors["client"].ID, ProviderID: actors["provider"].ID, Status: tt.from,
+				Date: time.Now().UTC().AddDate(0, 0, 5).Truncate(24 * time.Hour),
+			})
+
+			router := gin.New()
+			router.PUT("/api/appointments/:id/status", newAppointmentHandler(app).UpdateAppointmentStatus)
+
+			w := updateStatus(t, router, app, actors[tt.actor], appointment.ID, tt.to)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status %d: %s; want %d", w.Code, w.Body.String(), tt.wantCode)
+			}
+
+			want := tt.from
+			if tt.wantCode == http.StatusOK {
+				want = tt.to
+			}
+			var stored models.Appointment
+			db.First(&stored, appointment.ID)
+			if stored.Status != want {
+				t.Errorf("agendamento %s; want %s", stored.Status, want)
+			}
+		})
+	}
+}
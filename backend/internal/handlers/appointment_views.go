@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+)
+
+var errInvalidCursor = errors.New("cursor inválido")
+
+// participantView é a projeção pública de um cliente, sem dados de contato
+type participantView struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// providerView é a projeção pública de uma prestadora
+type providerView struct {
+	ID          uint    `json:"id"`
+	Name        string  `json:"name"`
+	IsVerified  bool    `json:"is_verified"`
+	Description string  `json:"description,omitempty"`
+	HourlyRate  float64 `json:"hourly_rate"`
+}
+
+// serviceView descreve o serviço contratado no agendamento
+type serviceView struct {
+	Name     string  `json:"name"`
+	Duration int     `json:"duration"`
+	Price    float64 `json:"price"`
+}
+
+// appointmentDetailResponse substitui os usuários completos do agendamento por projeções
+type appointmentDetailResponse struct {
+	models.Appointment
+	User     participantView `json:"user"`
+	Provider providerView    `json:"provider"`
+	Service  serviceView     `json:"service"`
+}
+
+func newAppointmentDetailResponse(appointment *models.Appointment) appointmentDetailResponse {
+	provider := providerView{
+		ID:   appointment.Provider.ID,
+		Name: appointment.Provider.Name,
+	}
+	if profile := appointment.Provider.ProviderProfile; profile != nil {
+		provider.IsVerified = profile.IsVerified
+		provider.Description = profile.Description
+		provider.HourlyRate = profile.HourlyRate
+	}
+
+	return appointmentDetailResponse{
+		Appointment: *appointment,
+		User: participantView{
+			ID:   appointment.User.ID,
+			Name: appointment.User.Name,
+		},
+		Provider: provider,
+		Service: serviceView{
+			Name:     appointment.Service,
+			Duration: appointment.Duration,
+			Price:    appointment.Price,
+		},
+	}
+}
+
+// appointmentPageResponse é a resposta paginada das listagens de agendamentos
+type appointmentPageResponse struct {
+	Data       []models.Appointment `json:"data"`
+	Total      int64                `json:"total"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+func newAppointmentPageResponse(page *repositories.AppointmentPage) appointmentPageResponse {
+	response := appointmentPageResponse{
+		Data:  page.Appointments,
+		Total: page.Total,
+	}
+	if response.Data == nil {
+		response.Data = []models.Appointment{}
+	}
+	if page.NextCursor != nil {
+		response.NextCursor = encodeAppointmentCursor(page.NextCursor)
+	}
+	return response
+}
+
+// parseAppointmentFilter lê os filtros das listagens a partir da query string:
+// status (lista separada por vírgula), from e to (AAAA-MM-DD), when (upcoming ou past),
+// limit e cursor
+func parseAppointmentFilter(c *gin.Context) (repositories.AppointmentFilter, error) {
+	var filter repositories.AppointmentFilter
+
+	if status := c.Query("status"); status != "" {
+		for _, s := range strings.Split(status, ",") {
+			filter.Statuses = append(filter.Statuses, models.AppointmentStatus(strings.TrimSpace(s)))
+		}
+	}
+
+	for param, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return filter, fmt.Errorf("formato de data inválido em %s, use AAAA-MM-DD", param)
+		}
+		*target = &date
+	}
+
+	switch c.Query("when") {
+	case "":
+	case "upcoming":
+		filter.Upcoming = true
+	case "past":
+		filter.Past = true
+	default:
+		return filter, errors.New("when deve ser upcoming ou past")
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return filter, errors.New("limit deve ser um número positivo")
+		}
+		filter.Limit = n
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		decoded, err := decodeAppointmentCursor(cursor)
+		if err != nil {
+			return filter, err
+		}
+		filter.Cursor = decoded
+	}
+
+	return filter, nil
+}
+
+// encodeAppointmentCursor gera um cursor opaco a partir da data e do ID
+func encodeAppointmentCursor(cursor *repositories.AppointmentCursor) string {
+	raw := fmt.Sprintf("%s|%d", cursor.Date.Format(time.RFC3339Nano), cursor.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeAppointmentCursor interpreta um cursor gerado por encodeAppointmentCursor
+func decodeAppointmentCursor(value string) (*repositories.AppointmentCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	dateStr, idStr, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, errInvalidCursor
+	}
+
+	date, err := time.Parse(time.RFC3339Nano, dateStr)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	return &repositories.AppointmentCursor{Date: date, ID: uint(id)}, nil
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/services"
+)
+
+const icalContentType = "text/calendar; charset=utf-8"
+
+type CalendarHandler struct {
+	appointmentRepo *repositories.AppointmentRepository
+	authService     *services.AuthService
+	calendarService *services.CalendarService
+}
+
+func NewCalendarHandler(
+	appointmentRepo *repositories.AppointmentRepository,
+	authService *services.AuthService,
+	calendarService *services.CalendarService,
+) *CalendarHandler {
+	return &CalendarHandler{
+		appointmentRepo: appointmentRepo,
+		authService:     authService,
+		calendarService: calendarService,
+	}
+}
+
+// GetFeed retorna a URL de assinatura do calendário do usuário autenticado
+func (h *CalendarHandler) GetFeed(c *gin.Context) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	feed, err := h.calendarService.Feed(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar feed de calendário"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.feedResponse(c, feed))
+}
+
+// UpdateFeed altera as opções do feed do usuário autenticado
+func (h *CalendarHandler) UpdateFeed(c *gin.Context) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	var req struct {
+		IncludeDetails *bool `json:"include_details" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	feed, err := h.calendarService.SetIncludeDetails(userID, *req.IncludeDetails)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar feed de calendário"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.feedResponse(c, feed))
+}
+
+// RegenerateFeed troca o token do feed, invalidando a URL de assinatura anterior
+func (h *CalendarHandler) RegenerateFeed(c *gin.Context) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	feed, err := h.calendarService.Regenerate(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao regenerar feed de calendário"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.feedResponse(c, feed))
+}
+
+// ServeFeed entrega o calendário iCalendar do dono do token. A rota é pública,
+// pois aplicativos de calendário não enviam o cabeçalho de autorização.
+func (h *CalendarHandler) ServeFeed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+
+	body, err := h.calendarService.RenderFeed(token)
+	if err != nil {
+		if errors.Is(err, repositories.ErrCalendarFeedNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Feed de calendário não encontrado"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar calendário"})
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Data(http.StatusOK, icalContentType, body)
+}
+
+// DownloadAppointment entrega o arquivo .ics de um agendamento para um de seus participantes
+func (h *CalendarHandler) DownloadAppointment(c *gin.Context) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	appointmentID, err := parseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	appointment, err := h.appointmentRepo.FindDetailByID(appointmentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agendamento não encontrado"})
+		return
+	}
+
+	if userID != appointment.UserID && userID != appointment.ProviderID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Acesso negado"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=agendamento-%d.ics", appointment.ID))
+	c.Data(http.StatusOK, icalContentType, h.calendarService.RenderAppointment(appointment, userID))
+}
+
+// feedResponse monta a resposta com a URL de assinatura do feed
+func (h *CalendarHandler) feedResponse(c *gin.Context, feed *models.CalendarFeed) gin.H {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	return gin.H{
+		"url":             fmt.Sprintf("%s://%s/api/calendar/feeds/%s.ics", scheme, c.Request.Host, feed.Token),
+		"include_details": feed.IncludeDetails,
+		"updated_at":      feed.UpdatedAt,
+	}
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/services"
+)
+
+type CommissionHandler struct {
+	commissionRepo    *repositories.CommissionRepository
+	commissionService *services.CommissionService
+}
+
+func NewCommissionHandler(
+	commissionRepo *repositories.CommissionRepository,
+	commissionService *services.CommissionService,
+) *CommissionHandler {
+	return &CommissionHandler{
+		commissionRepo:    commissionRepo,
+		commissionService: commissionService,
+	}
+}
+
+// CommissionRuleRequest contém os campos de uma regra de comissão. Cidade, categoria e
+// período vazios fazem a regra valer para todos.
+type CommissionRuleRequest struct {
+	Name         string              `json:"name" binding:"required"`
+	PercentBps   int                 `json:"percent_bps"`  // 1500 = 15%
+	FixedAmount  int64               `json:"fixed_amount"` // Em centavos
+	City         string              `json:"city"`
+	ProviderTier models.ProviderTier `json:"provider_tier"`
+	StartsAt     *time.Time          `json:"starts_at"`
+	EndsAt       *time.Time          `json:"ends_at"`
+	Active       *bool               `json:"active"`
+}
+
+func (req *CommissionRuleRequest) apply(rule *models.CommissionRule) {
+	rule.Name = req.Name
+	rule.PercentBps = req.PercentBps
+	rule.FixedAmount = req.FixedAmount
+	rule.City = req.City
+	rule.ProviderTier = req.ProviderTier
+	rule.StartsAt = req.StartsAt
+	rule.EndsAt = req.EndsAt
+	if req.Active != nil {
+		rule.Active = *req.Active
+	}
+}
+
+// GetRules lista as regras de comissão
+func (h *CommissionHandler) GetRules(c *gin.Context) {
+	rules, err := h.commissionRepo.GetRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar regras de comissão"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// CreateRule cadastra uma regra de comissão
+func (h *CommissionHandler) CreateRule(c *gin.Context) {
+	var req CommissionRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := &models.CommissionRule{Active: true}
+	req.apply(rule)
+	if err := h.commissionService.ValidateRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.commissionRepo.CreateRule(rule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao cadastrar regra de comissão"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateRule substitui os campos de uma regra, criando uma nova versão. Repasses já feitos
+// mantêm a versão usada.
+func (h *CommissionHandler) UpdateRule(c *gin.Context) {
+	rule, ok := h.loadRule(c)
+	if !ok {
+		return
+	}
+
+	var req CommissionRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.apply(rule)
+	if err := h.commissionService.ValidateRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.commissionRepo.UpdateRule(rule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar regra de comissão"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeactivateRule desativa uma regra. Ela é mantida para consulta dos repasses que a usaram.
+func (h *CommissionHandler) DeactivateRule(c *gin.Context) {
+	rule, ok := h.loadRule(c)
+	if !ok {
+		return
+	}
+
+	rule.Active = false
+	if err := h.commissionRepo.UpdateRule(rule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao desativar regra de comissão"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// loadRule carrega a regra da rota. Em caso de erro, a resposta já é enviada e ok é falso.
+func (h *CommissionHandler) loadRule(c *gin.Context) (*models.CommissionRule, bool) {
+	ruleID, err := parseIDParam(c, "ruleId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return nil, false
+	}
+
+	rule, err := h.commissionRepo.FindRuleByID(ruleID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrCommissionRuleNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar regra de comissão"})
+		return nil, false
+	}
+	return rule, true
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/services"
+)
+
+type DisputeHandler struct {
+	appointmentRepo *repositories.AppointmentRepository
+	paymentRepo     *repositories.PaymentRepository
+	userRepo        *repositories.UserRepository
+	authService     *services.AuthService
+	paymentService  *services.PaymentService
+}
+
+func NewDisputeHandler(
+	appointmentRepo *repositories.AppointmentRepository,
+	paymentRepo *repositories.PaymentRepository,
+	userRepo *repositories.UserRepository,
+	authService *services.AuthService,
+	paymentService *services.PaymentService,
+) *DisputeHandler {
+	return &DisputeHandler{
+		appointmentRepo: appointmentRepo,
+		paymentRepo:     paymentRepo,
+		userRepo:        userRepo,
+		authService:     authService,
+		paymentService:  paymentService,
+	}
+}
+
+// OpenDispute permite que o cliente ou a prestadora contestem o atendimento concluído,
+// congelando o valor em custódia até a decisão do suporte
+func (h *DisputeHandler) OpenDispute(c *gin.Context) {
+	userID, appointment, _, ok := h.loadAppointment(c)
+	if !ok {
+		return
+	}
+
+	if userID != appointment.UserID && userID != appointment.ProviderID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Acesso negado"})
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dispute, err := h.paymentService.OpenDispute(appointment, userID, req.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDisputeNotAllowed),
+			errors.Is(err, services.ErrDisputeWindowClosed),
+			errors.Is(err, repositories.ErrPaymentDisputeExists):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao abrir disputa"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, dispute)
+}
+
+// GetDispute retorna a disputa de um agendamento
+func (h *DisputeHandler) GetDispute(c *gin.Context) {
+	userID, appointment, user, ok := h.loadAppointment(c)
+	if !ok {
+		return
+	}
+
+	if user.UserType != models.UserTypeAdmin && userID != appointment.UserID && userID != appointment.ProviderID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Acesso negado"})
+		return
+	}
+
+	dispute, err := h.paymentRepo.FindDisputeByAppointment(appointment.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Disputa não encontrada"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dispute)
+}
+
+// ResolveDispute permite que um admin decida quanto do valor volta ao cliente;
+// o restante é repassado à prestadora
+func (h *DisputeHandler) ResolveDispute(c *gin.Context) {
+	userID, appointment, _, ok := h.loadAppointment(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		RefundAmount int64  `json:"refund_amount" binding:"min=0"` // Em centavos
+		Note         string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dispute, err := h.paymentRepo.FindDisputeByAppointment(appointment.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Disputa não encontrada"})
+		return
+	}
+
+	if err := h.paymentService.ResolveDispute(dispute, req.RefundAmount, userID, req.Note); err != nil {
+		switch {
+		case errors.Is(err, services.ErrDisputeNotOpen):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, repositories.ErrRefundExceedsAvailable):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrRefundFailed):
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao resolver disputa"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, dispute)
+}
+
+// loadAppointment autentica o usuário e carrega o agendamento da rota.
+// Em caso de erro, a resposta já é enviada e ok é falso.
+func (h *DisputeHandler) loadAppointment(c *gin.Context) (uint, *models.Appointment, *models.User, bool) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return 0, nil, nil, false
+	}
+
+	user, err := h.userRepo.FindByID(userID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return 0, nil, nil, false
+	}
+
+	appointmentID, err := parseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return 0, nil, nil, false
+	}
+
+	appointment, err := h.appointmentRepo.FindByID(appointmentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agendamento não encontrado"})
+		return 0, nil, nil, false
+	}
+
+	return userID, appointment, user, true
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/services"
+)
+
+const (
+	pdfContentType = "application/pdf"
+	csvContentType = "text/csv; charset=utf-8"
+	// signatureHeader leva a assinatura dos documentos baixados como arquivo
+	signatureHeader = "X-Document-Signature"
+)
+
+type DocumentHandler struct {
+	documents *services.DocumentService
+}
+
+func NewDocumentHandler(documents *services.DocumentService) *DocumentHandler {
+	return &DocumentHandler{
+		documents: documents,
+	}
+}
+
+// VerifyDocument confere a assinatura de um recibo (type=receipt, payment_id) ou de um
+// extrato mensal (type=statement, provider_id, month). Público, para que terceiros possam
+// conferir documentos apresentados; só retorna detalhes quando a assinatura confere.
+func (h *DocumentHandler) VerifyDocument(c *gin.Context) {
+	signature := c.Query("signature")
+	if signature == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Informe a assinatura"})
+		return
+	}
+
+	switch c.Query("type") {
+	case "receipt":
+		paymentID, err := strconv.ParseUint(c.Query("payment_id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Pagamento inválido"})
+			return
+		}
+
+		receipt, valid, err := h.documents.VerifyReceipt(uint(paymentID), signature)
+		if err != nil || !valid {
+			c.JSON(http.StatusOK, gin.H{"valid": false})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"valid":         true,
+			"type":          "receipt",
+			"payment_id":    receipt.PaymentID,
+			"paid_at":       receipt.PaidAt,
+			"currency":      receipt.Currency,
+			"amount":        receipt.Amount,
+			"net_amount":    receipt.NetAmount,
+			"provider_name": receipt.ProviderName,
+		})
+
+	case "statement":
+		providerID, err := strconv.ParseUint(c.Query("provider_id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Prestadora inválida"})
+			return
+		}
+		month, err := h.documents.ParseStatementMonth(c.Query("month"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		statement, valid, err := h.documents.VerifyStatement(uint(providerID), month, signature)
+		if err != nil || !valid {
+			c.JSON(http.StatusOK, gin.H{"valid": false})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"valid":         true,
+			"type":          "statement",
+			"provider_id":   statement.ProviderID,
+			"provider_name": statement.ProviderName,
+			"month":         statement.Month,
+			"currency":      statement.Currency,
+			"gross":         statement.Gross,
+			"net":           statement.Net,
+		})
+
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tipo de documento inválido, use receipt ou statement"})
+	}
+}
+
+// documentFormat lê o formato pedido em format: json (padrão), pdf ou csv.
+// Em caso de erro, a resposta já é enviada e ok é falso.
+func documentFormat(c *gin.Context) (string, bool) {
+	switch format := c.DefaultQuery("format", services.DocumentFormatJSON); format {
+	case services.DocumentFormatJSON, services.DocumentFormatPDF, services.DocumentFormatCSV:
+		return format, true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Formato inválido, use json, pdf ou csv"})
+	return "", false
+}
+
+// sendDocument envia um documento para download, com a assinatura no cabeçalho
+func sendDocument(c *gin.Context, filename, contentType, signature string, body []byte) {
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	c.Header(signatureHeader, signature)
+	c.Data(http.StatusOK, contentType, body)
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/services"
+)
+
+type ExternalCalendarHandler struct {
+	calendarRepo            *repositories.ExternalCalendarRepository
+	userRepo                *repositories.UserRepository
+	authService             *services.AuthService
+	externalCalendarService *services.ExternalCalendarService
+}
+
+func NewExternalCalendarHandler(
+	calendarRepo *repositories.ExternalCalendarRepository,
+	userRepo *repositories.UserRepository,
+	authService *services.AuthService,
+	externalCalendarService *services.ExternalCalendarService,
+) *ExternalCalendarHandler {
+	return &ExternalCalendarHandler{
+		calendarRepo:            calendarRepo,
+		userRepo:                userRepo,
+		authService:             authService,
+		externalCalendarService: externalCalendarService,
+	}
+}
+
+// GetCalendars lista os calendários externos da prestadora autenticada
+func (h *ExternalCalendarHandler) GetCalendars(c *gin.Context) {
+	providerID, ok := h.authenticatedProvider(c)
+	if !ok {
+		return
+	}
+
+	calendars, err := h.calendarRepo.GetByProvider(providerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar calendários externos"})
+		return
+	}
+
+	c.JSON(http.StatusOK, calendars)
+}
+
+// SubscribeCalendar cadastra um calendário externo por URL e faz a primeira sincronização
+func (h *ExternalCalendarHandler) SubscribeCalendar(c *gin.Context) {
+	providerID, ok := h.authenticatedProvider(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name string `json:"name" binding:"required"`
+		URL  string `json:"url" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	calendarURL, err := services.NormalizeCalendarURL(req.URL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	calendar := &models.ExternalCalendar{
+		ProviderID: providerID,
+		Name:       req.Name,
+		URL:        calendarURL,
+	}
+	if err := h.calendarRepo.Create(calendar); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao cadastrar calendário externo"})
+		return
+	}
+
+	// Calendários que não podem ser lidos não são mantidos
+	if err := h.externalCalendarService.Sync(calendar); err != nil {
+		if delErr := h.calendarRepo.Delete(calendar); delErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao cadastrar calendário externo"})
+			return
+		}
+		respondCalendarImportError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, calendar)
+}
+
+// UploadCalendar importa um arquivo .ics enviado pela prestadora no campo "file"
+func (h *ExternalCalendarHandler) UploadCalendar(c *gin.Context) {
+	providerID, ok := h.authenticatedProvider(c)
+	if !ok {
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Arquivo .ics não enviado"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Não foi possível ler o arquivo"})
+		return
+	}
+	defer file.Close()
+
+	name := c.PostForm("name")
+	if name == "" {
+		name = fileHeader.Filename
+	}
+
+	calendar := &models.ExternalCalendar{
+		ProviderID: providerID,
+		Name:       name,
+	}
+	if err := h.calendarRepo.Create(calendar); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao cadastrar calendário externo"})
+		return
+	}
+
+	if err := h.externalCalendarService.Import(calendar, file); err != nil {
+		if delErr := h.calendarRepo.Delete(calendar); delErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao cadastrar calendário externo"})
+			return
+		}
+		respondCalendarImportError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, calendar)
+}
+
+// SyncCalendar sincroniza imediatamente um calendário externo por URL
+func (h *ExternalCalendarHandler) SyncCalendar(c *gin.Context) {
+	calendar, ok := h.ownCalendar(c)
+	if !ok {
+		return
+	}
+
+	if err := h.externalCalendarService.Sync(calendar); err != nil {
+		respondCalendarImportError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, calendar)
+}
+
+// DeleteCalendar remove um calendário externo e libera os horários que ele bloqueava
+func (h *ExternalCalendarHandler) DeleteCalendar(c *gin.Context) {
+	calendar, ok := h.ownCalendar(c)
+	if !ok {
+		return
+	}
+
+	if err := h.calendarRepo.Delete(calendar); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao remover calendário externo"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Calendário externo removido com sucesso"})
+}
+
+// authenticatedProvider retorna o ID da prestadora autenticada.
+// Em caso de erro, a resposta já é enviada e ok é falso.
+func (h *ExternalCalendarHandler) authenticatedProvider(c *gin.Context) (uint, bool) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return 0, false
+	}
+
+	user, err := h.userRepo.FindByID(userID)
+	if err != nil || user.UserType != models.UserTypeProvider {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Acesso negado"})
+		return 0, false
+	}
+
+	return userID, true
+}
+
+// ownCalendar carrega o calendário da rota, garantindo que pertence à prestadora autenticada.
+// Em caso de erro, a resposta já é enviada e ok é falso.
+func (h *ExternalCalendarHandler) ownCalendar(c *gin.Context) (*models.ExternalCalendar, bool) {
+	providerID, ok := h.authenticatedProvider(c)
+	if !ok {
+		return nil, false
+	}
+
+	calendarID, err := parseIDParam(c, "calendarId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return nil, false
+	}
+
+	calendar, err := h.calendarRepo.FindByID(calendarID)
+	if err != nil || calendar.ProviderID != providerID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendário externo não encontrado"})
+		return nil, false
+	}
+
+	return calendar, true
+}
+
+// respondCalendarImportError traduz as falhas de leitura de calendário em respostas HTTP
+func respondCalendarImportError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidICalendar),
+		errors.Is(err, services.ErrInvalidCalendarURL),
+		errors.Is(err, services.ErrCalendarNotSubscribed):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCalendarFetchFailed):
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao importar calendário externo"})
+	}
+}
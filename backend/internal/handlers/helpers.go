@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/services"
+)
+
+// userIDFromToken extrai o ID do usuário do token JWT do header Authorization
+func userIDFromToken(c *gin.Context, authService *services.AuthService) (uint, error) {
+	return authService.UserIDFromHeader(c.GetHeader("Authorization"))
+}
+
+// isAdmin informa se o usuário é admin, para as rotas abertas também aos participantes
+// do agendamento. As rotas exclusivas de admins usam middleware.RequireAdmin.
+func isAdmin(userRepo *repositories.UserRepository, userID uint) bool {
+	user, err := userRepo.FindByID(userID)
+	return err == nil && user.UserType == models.UserTypeAdmin
+}
+
+// parseIDParam converte um parâmetro de rota em ID
+func parseIDParam(c *gin.Context, name string) (uint, error) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint(id), nil
+}
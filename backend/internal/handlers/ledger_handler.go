@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/services"
+)
+
+// defaultLedgerPeriod é o período consultado quando from e to não são informados
+const defaultLedgerPeriod = 30 * 24 * time.Hour
+
+type LedgerHandler struct {
+	ledgerRepo    *repositories.LedgerRepository
+	userRepo      *repositories.UserRepository
+	authService   *services.AuthService
+	ledgerService *services.LedgerService
+}
+
+func NewLedgerHandler(
+	ledgerRepo *repositories.LedgerRepository,
+	userRepo *repositories.UserRepository,
+	authService *services.AuthService,
+	ledgerService *services.LedgerService,
+) *LedgerHandler {
+	return &LedgerHandler{
+		ledgerRepo:    ledgerRepo,
+		userRepo:      userRepo,
+		authService:   authService,
+		ledgerService: ledgerService,
+	}
+}
+
+// GetMyBalance retorna o saldo da conta do usuário autenticado: o valor a receber,
+// para prestadoras, ou o crédito, para clientes
+func (h *LedgerHandler) GetMyBalance(c *gin.Context) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	user, err := h.userRepo.FindByID(userID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	accountType := models.LedgerAccountClient
+	if user.UserType == models.UserTypeProvider {
+		accountType = models.LedgerAccountProvider
+	}
+
+	balance, err := h.ledgerService.UserBalance(accountType, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao consultar saldo"})
+		return
+	}
+
+	// Créditos aparecem negativos no razão; para o usuário, o saldo é o valor a seu favor
+	c.JSON(http.StatusOK, gin.H{
+		"account":  balance.Code,
+		"currency": balance.Currency,
+		"balance":  -balance.Balance,
+	})
+}
+
+// GetAccounts retorna todas as contas do razão com seus saldos
+func (h *LedgerHandler) GetAccounts(c *gin.Context) {
+	balances, err := h.ledgerRepo.Balances()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar contas"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"accounts": balances})
+}
+
+// GetAccountPostings retorna as partidas de uma conta no período informado
+func (h *LedgerHandler) GetAccountPostings(c *gin.Context) {
+	accountID, err := parseIDParam(c, "accountId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	from, to, ok := ledgerPeriod(c)
+	if !ok {
+		return
+	}
+
+	account, err := h.ledgerRepo.FindAccountByID(accountID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conta não encontrada"})
+		return
+	}
+
+	balance, err := h.ledgerRepo.Balance(account.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao consultar saldo"})
+		return
+	}
+
+	postings, err := h.ledgerRepo.GetPostings(account.ID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar partidas"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"account":  account,
+		"balance":  balance,
+		"postings": postings,
+	})
+}
+
+// GetReconciliation concilia o razão com os registros do gateway no período informado
+func (h *LedgerHandler) GetReconciliation(c *gin.Context) {
+	from, to, ok := ledgerPeriod(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.ledgerService.Reconcile(from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao conciliar razão"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// ledgerPeriod lê o período das consultas (from e to no formato AAAA-MM-DD, com to
+// incluído). Sem parâmetros, usa os últimos 30 dias. Em caso de erro, a resposta já é enviada.
+func ledgerPeriod(c *gin.Context) (time.Time, time.Time, bool) {
+	to := time.Now()
+	from := to.Add(-defaultLedgerPeriod)
+
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Data inicial inválida, use AAAA-MM-DD"})
+			return time.Time{}, time.Time{}, false
+		}
+		from = parsed
+	}
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Data final inválida, use AAAA-MM-DD"})
+			return time.Time{}, time.Time{}, false
+		}
+		to = parsed.AddDate(0, 0, 1)
+	}
+
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Período inválido"})
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/services"
+)
+
+type NoShowHandler struct {
+	appointmentRepo *repositories.AppointmentRepository
+	noShowRepo      *repositories.NoShowRepository
+	userRepo        *repositories.UserRepository
+	authService     *services.AuthService
+	noShowService   *services.NoShowService
+}
+
+func NewNoShowHandler(
+	appointmentRepo *repositories.AppointmentRepository,
+	noShowRepo *repositories.NoShowRepository,
+	userRepo *repositories.UserRepository,
+	authService *services.AuthService,
+	noShowService *services.NoShowService,
+) *NoShowHandler {
+	return &NoShowHandler{
+		appointmentRepo: appointmentRepo,
+		noShowRepo:      noShowRepo,
+		userRepo:        userRepo,
+		authService:     authService,
+		noShowService:   noShowService,
+	}
+}
+
+// ClaimNoShow registra que a outra parte não compareceu ao agendamento
+func (h *NoShowHandler) ClaimNoShow(c *gin.Context) {
+	userID, appointment, _, ok := h.loadAppointment(c)
+	if !ok {
+		return
+	}
+
+	if userID != appointment.UserID && userID != appointment.ProviderID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Acesso negado"})
+		return
+	}
+
+	var req struct {
+		Evidence string `json:"evidence" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claim, err := h.noShowService.Claim(appointment, userID, req.Evidence)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNoShowNotAllowed),
+			errors.Is(err, services.ErrNoShowTooEarly),
+			errors.Is(err, repositories.ErrNoShowClaimExists):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao registrar ausência"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, claim)
+}
+
+// GetNoShowClaim retorna a alegação de ausência de um agendamento
+func (h *NoShowHandler) GetNoShowClaim(c *gin.Context) {
+	userID, appointment, user, ok := h.loadAppointment(c)
+	if !ok {
+		return
+	}
+
+	if user.UserType != models.UserTypeAdmin && userID != appointment.UserID && userID != appointment.ProviderID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Acesso negado"})
+		return
+	}
+
+	claim, err := h.noShowRepo.FindByAppointment(appointment.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alegação de ausência não encontrada"})
+		return
+	}
+
+	c.JSON(http.StatusOK, claim)
+}
+
+// DisputeNoShow permite que a parte acusada conteste a alegação de ausência
+func (h *NoShowHandler) DisputeNoShow(c *gin.Context) {
+	userID, appointment, _, ok := h.loadAppointment(c)
+	if !ok {
+		return
+	}
+
+	claim, err := h.noShowRepo.FindByAppointment(appointment.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alegação de ausência não encontrada"})
+		return
+	}
+
+	// Apenas a parte acusada pode contestar
+	accused := appointment.ProviderID
+	if claim.AbsentParty == models.NoShowPartyClient {
+		accused = appointment.UserID
+	}
+	if userID != accused {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Acesso negado"})
+		return
+	}
+
+	var req struct {
+		Response string `json:"response" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.noShowService.Dispute(claim, userID, req.Response); err != nil {
+		switch {
+		case errors.Is(err, services.ErrNoShowNotOpen), errors.Is(err, services.ErrNoShowWindowClosed):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao contestar ausência"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, claim)
+}
+
+// ResolveNoShow permite que um admin decida uma alegação contestada
+func (h *NoShowHandler) ResolveNoShow(c *gin.Context) {
+	userID, appointment, _, ok := h.loadAppointment(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Upheld *bool  `json:"upheld" binding:"required"`
+		Note   string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claim, err := h.noShowRepo.FindByAppointment(appointment.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alegação de ausência não encontrada"})
+		return
+	}
+
+	if err := h.noShowService.Resolve(claim, appointment, *req.Upheld, &userID, req.Note); err != nil {
+		if errors.Is(err, services.ErrNoShowNotDisputed) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao resolver ausência"})
+		return
+	}
+
+	c.JSON(http.StatusOK, claim)
+}
+
+// loadAppointment autentica o usuário e carrega o agendamento da rota.
+// Em caso de erro, a resposta já é enviada e ok é falso.
+func (h *NoShowHandler) loadAppointment(c *gin.Context) (uint, *models.Appointment, *models.User, bool) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return 0, nil, nil, false
+	}
+
+	user, err := h.userRepo.FindByID(userID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return 0, nil, nil, false
+	}
+
+	appointmentID, err := parseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return 0, nil, nil, false
+	}
+
+	appointment, err := h.appointmentRepo.FindByID(appointmentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agendamento não encontrado"})
+		return 0, nil, nil, false
+	}
+
+	return userID, appointment, user, true
+}
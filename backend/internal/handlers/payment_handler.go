@@ -0,0 +1,564 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/services"
+)
+
+type PaymentHandler struct {
+	paymentRepo     *repositories.PaymentRepository
+	appointmentRepo *repositories.AppointmentRepository
+	userRepo        *repositories.UserRepository
+	authService     *services.AuthService
+	paymentService  *services.PaymentService
+	documents       *services.DocumentService
+	tipService      *services.TipService
+	webhooks        *services.WebhookService
+	pixSimulator    *services.PixWebhookSimulator // nil fora de desenvolvimento
+}
+
+func NewPaymentHandler(
+	paymentRepo *repositories.PaymentRepository,
+	appointmentRepo *repositories.AppointmentRepository,
+	userRepo *repositories.UserRepository,
+	authService *services.AuthService,
+	paymentService *services.PaymentService,
+	documents *services.DocumentService,
+	tipService *services.TipService,
+	webhooks *services.WebhookService,
+	pixSimulator *services.PixWebhookSimulator,
+) *PaymentHandler {
+	return &PaymentHandler{
+		paymentRepo:     paymentRepo,
+		appointmentRepo: appointmentRepo,
+		userRepo:        userRepo,
+		authService:     authService,
+		paymentService:  paymentService,
+		documents:       documents,
+		tipService:      tipService,
+		webhooks:        webhooks,
+		pixSimulator:    pixSimulator,
+	}
+}
+
+// flexibleID aceita IDs enviados como número ou como string, como faz o aplicativo
+type flexibleID uint
+
+func (id *flexibleID) UnmarshalJSON(data []byte) error {
+	value, err := strconv.ParseUint(strings.Trim(string(data), `"`), 10, 32)
+	if err != nil {
+		return fmt.Errorf("ID inválido: %s", data)
+	}
+	*id = flexibleID(value)
+	return nil
+}
+
+// CreateIntentRequest segue o contrato do aplicativo. O valor enviado é ignorado:
+// a cobrança é sempre calculada a partir do agendamento.
+type CreateIntentRequest struct {
+	AppointmentID flexibleID `json:"appointmentId" binding:"required"`
+	Amount        int64      `json:"amount"`
+	Currency      string     `json:"currency"`
+}
+
+// CreateIntent cria a intenção de pagamento de um agendamento do cliente autenticado
+func (h *PaymentHandler) CreateIntent(c *gin.Context) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	var req CreateIntentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Currency != "" && !strings.EqualFold(req.Currency, models.PaymentCurrency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Moeda não suportada"})
+		return
+	}
+
+	appointment, err := h.appointmentRepo.FindByID(uint(req.AppointmentID))
+	if err != nil || appointment.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agendamento não encontrado"})
+		return
+	}
+
+	h.openPayment(c, appointment, h.paymentService.CreateIntent)
+}
+
+// CreatePixCharge cria a cobrança Pix de um agendamento do cliente autenticado.
+// A resposta traz o código "copia e cola"; o QR Code fica em GetPixQRCode.
+func (h *PaymentHandler) CreatePixCharge(c *gin.Context) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	var req struct {
+		AppointmentID flexibleID `json:"appointmentId" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	appointment, err := h.appointmentRepo.FindByID(uint(req.AppointmentID))
+	if err != nil || appointment.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agendamento não encontrado"})
+		return
+	}
+
+	h.openPayment(c, appointment, h.paymentService.CreatePixCharge)
+}
+
+// openPayment abre o pagamento do agendamento e responde com ele
+func (h *PaymentHandler) openPayment(c *gin.Context, appointment *models.Appointment, open func(*models.Appointment) (*models.Payment, error)) {
+	payment, err := open(appointment)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrAppointmentNotPayable), errors.Is(err, services.ErrAppointmentAlreadyPaid):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar intenção de pagamento"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
+
+// ConfirmPayment confirma a intenção de pagamento com o método escolhido pelo cliente
+func (h *PaymentHandler) ConfirmPayment(c *gin.Context) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	var req struct {
+		PaymentIntentID string `json:"paymentIntentId" binding:"required"`
+		PaymentMethodID string `json:"paymentMethodId" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payment, err := h.paymentRepo.FindByIntentID(req.PaymentIntentID)
+	if err != nil || payment.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pagamento não encontrado"})
+		return
+	}
+
+	payment, err = h.paymentService.Confirm(payment, req.PaymentMethodID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrPaymentDeclined):
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error(), "payment": payment})
+		case errors.Is(err, services.ErrPaymentNotConfirmable):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrPaymentMethodNotOwned):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrGatewayMethodNotFound):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Erro ao confirmar pagamento"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
+
+// RefundPayment reembolsa total ou parcialmente um pagamento. Apenas a prestadora do
+// agendamento ou um admin podem reembolsar; os reembolsos ao cliente por cancelamento
+// são feitos automaticamente.
+func (h *PaymentHandler) RefundPayment(c *gin.Context) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	var req struct {
+		PaymentIntentID string `json:"paymentIntentId" binding:"required"`
+		Amount          int64  `json:"amount" binding:"min=0"` // Em centavos; zero reembolsa todo o saldo
+		Reason          string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payment, err := h.paymentRepo.FindByIntentID(req.PaymentIntentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pagamento não encontrado"})
+		return
+	}
+
+	// O cliente pede o reembolso, que fica aguardando a prestadora ou o suporte
+	if userID == payment.UserID {
+		h.requestRefund(c, payment, req.Amount, req.Reason)
+		return
+	}
+	if userID != payment.ProviderID && !isAdmin(h.userRepo, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Acesso negado"})
+		return
+	}
+
+	refund, err := h.paymentService.Refund(payment, req.Amount, req.Reason, &userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrRefundExceedsAvailable):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		case errors.Is(err, repositories.ErrEscrowReleased):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrRefundFailed):
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao processar reembolso"})
+		}
+		return
+	}
+
+	payment, err = h.paymentRepo.FindByID(payment.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar pagamento"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"refund":  refund,
+		"payment": payment,
+	})
+}
+
+// requestRefund registra o pedido de reembolso do cliente
+func (h *PaymentHandler) requestRefund(c *gin.Context, payment *models.Payment, amount int64, reason string) {
+	request, err := h.paymentService.RequestRefund(payment, amount, reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrRefundExceedsAvailable):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		case errors.Is(err, repositories.ErrEscrowReleased), errors.Is(err, repositories.ErrRefundRequestExists):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao pedir reembolso"})
+		}
+		return
+	}
+
+	// O aplicativo trata qualquer resposta diferente de 200 como falha
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Pedido de reembolso enviado para análise",
+		"refund_request": request,
+	})
+}
+
+// GetRefundRequests lista os pedidos de reembolso feitos pelo cliente ou dirigidos à prestadora
+func (h *PaymentHandler) GetRefundRequests(c *gin.Context) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	requests, err := h.paymentRepo.GetRefundRequests(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar pedidos de reembolso"})
+		return
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+// ApproveRefundRequest aprova o pedido de reembolso, devolvendo o valor ao cliente
+func (h *PaymentHandler) ApproveRefundRequest(c *gin.Context) {
+	h.reviewRefundRequest(c, true)
+}
+
+// RejectRefundRequest recusa o pedido de reembolso
+func (h *PaymentHandler) RejectRefundRequest(c *gin.Context) {
+	h.reviewRefundRequest(c, false)
+}
+
+// reviewRefundRequest registra a análise da prestadora do pagamento ou do suporte
+func (h *PaymentHandler) reviewRefundRequest(c *gin.Context, approve bool) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	requestID, err := parseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req struct {
+		Note string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	request, err := h.paymentRepo.FindRefundRequest(requestID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pedido de reembolso não encontrado"})
+		return
+	}
+	if userID != request.ProviderID && !isAdmin(h.userRepo, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Pedidos de reembolso são analisados pela prestadora ou pelo suporte"})
+		return
+	}
+
+	if err := h.paymentService.ReviewRefundRequest(request, approve, userID, req.Note); err != nil {
+		switch {
+		case errors.Is(err, services.ErrRefundRequestNotPending), errors.Is(err, repositories.ErrEscrowReleased):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, repositories.ErrRefundExceedsAvailable):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrRefundFailed):
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao analisar pedido de reembolso"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// GetPaymentHistory lista os pagamentos e as gorjetas do cliente autenticado
+func (h *PaymentHandler) GetPaymentHistory(c *gin.Context) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	payments, err := h.paymentService.History(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar histórico de pagamentos"})
+		return
+	}
+	tips, err := h.tipService.History(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar histórico de pagamentos"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"payments": payments, "tips": tips})
+}
+
+// GetReceipt retorna o recibo assinado de um pagamento, com a taxa da plataforma incluída
+// no valor. Disponível para o cliente, a prestadora do agendamento e admins, em JSON ou,
+// com format=pdf ou format=csv, como arquivo.
+func (h *PaymentHandler) GetReceipt(c *gin.Context) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	paymentID, err := parseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	payment, err := h.paymentRepo.FindByID(paymentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pagamento não encontrado"})
+		return
+	}
+
+	if userID != payment.UserID && userID != payment.ProviderID {
+		if !isAdmin(h.userRepo, userID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pagamento não encontrado"})
+			return
+		}
+	}
+
+	format, ok := documentFormat(c)
+	if !ok {
+		return
+	}
+
+	receipt, err := h.documents.Receipt(payment)
+	if err != nil {
+		if errors.Is(err, services.ErrReceiptNotAvailable) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar recibo"})
+		return
+	}
+
+	filename := fmt.Sprintf("recibo-%d", payment.ID)
+	switch format {
+	case services.DocumentFormatPDF:
+		sendDocument(c, filename+".pdf", pdfContentType, receipt.Signature, h.documents.ReceiptPDF(receipt))
+	case services.DocumentFormatCSV:
+		body, err := h.documents.ReceiptCSV(receipt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar recibo"})
+			return
+		}
+		sendDocument(c, filename+".csv", csvContentType, receipt.Signature, body)
+	default:
+		c.JSON(http.StatusOK, receipt)
+	}
+}
+
+// GetPaymentMethods lista os métodos de pagamento salvos pelo cliente autenticado
+func (h *PaymentHandler) GetPaymentMethods(c *gin.Context) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	methods, err := h.paymentRepo.GetMethods(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar métodos de pagamento"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"methods": methods})
+}
+
+// SavePaymentMethod salva um método de pagamento criado pelo aplicativo no gateway
+func (h *PaymentHandler) SavePaymentMethod(c *gin.Context) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	var req struct {
+		PaymentMethodID string `json:"paymentMethodId" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	method, err := h.paymentService.SaveMethod(userID, req.PaymentMethodID)
+	if err != nil {
+		if errors.Is(err, services.ErrGatewayMethodInUse) {
+			c.JSON(http.StatusForbidden, gin.H{"error": services.ErrPaymentMethodNotOwned.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrGatewayMethodNotFound) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar método de pagamento"})
+		return
+	}
+
+	c.JSON(http.StatusOK, method)
+}
+
+// DeletePaymentMethod remove um método de pagamento do cliente autenticado
+func (h *PaymentHandler) DeletePaymentMethod(c *gin.Context) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	if err := h.paymentService.DeleteMethod(userID, c.Param("methodId")); err != nil {
+		if errors.Is(err, repositories.ErrPaymentMethodNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao excluir método de pagamento"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Método de pagamento excluído com sucesso"})
+}
+
+// GetPixQRCode retorna o QR Code PNG de uma cobrança Pix do cliente autenticado
+func (h *PaymentHandler) GetPixQRCode(c *gin.Context) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	payment, err := h.paymentService.FindPixCharge(c.Param("txid"))
+	if err != nil || payment.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cobrança Pix não encontrada"})
+		return
+	}
+
+	png, err := h.paymentService.PixQRCode(payment)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar QR Code"})
+		return
+	}
+
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+// PixWebhook recebe as notificações de liquidação do PSP. Mantido no endereço antigo
+// combinado com o PSP; equivale a /api/webhooks/pix.
+func (h *PaymentHandler) PixWebhook(c *gin.Context) {
+	receipt, ok := receiveWebhook(c, h.webhooks, services.PixWebhookProvider)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"received": receipt.Received + receipt.Duplicates})
+}
+
+// SimulatePixPayment paga uma cobrança Pix do cliente autenticado pelo simulador de webhook.
+// Disponível apenas com PIX_SIMULATOR habilitado. A liquidação é processada em segundo
+// plano, então o pagamento retornado pode ainda estar pendente.
+func (h *PaymentHandler) SimulatePixPayment(c *gin.Context) {
+	if h.pixSimulator == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Simulador Pix desabilitado"})
+		return
+	}
+
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	payment, err := h.paymentService.FindPixCharge(c.Param("txid"))
+	if err != nil || payment.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cobrança Pix não encontrada"})
+		return
+	}
+
+	if err := h.pixSimulator.Pay(payment); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	payment, err = h.paymentRepo.FindByID(payment.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar pagamento"})
+		return
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xclean/backend/internal/models"
+)
+
+func TestClientRefundRequestAwaitsApproval(t *testing.T) {
+	env := newWebhookTestEnv(t)
+	payment := env.createPayment(t)
+	if _, err := env.PaymentService.Confirm(payment, "pm_card_visa"); err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+
+	handler := NewPaymentHandler(env.PaymentRepo, env.AppointmentRepo, env.UserRepo, env.AuthService, env.PaymentService,
+		nil, nil, env.WebhookService, nil)
+	env.router.POST("/api/payments/refund", handler.RefundPayment)
+	env.router.POST("/api/payments/refund-requests/:id/approve", handler.ApproveRefundRequest)
+
+	post := func(path, body string, actor *models.User, wantCode int) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", env.Bearer(t, actor))
+		w := httptest.NewRecorder()
+		env.router.ServeHTTP(w, req)
+		if w.Code != wantCode {
+			t.Fatalf("%s: status %d: %s; want %d", path, w.Code, w.Body.String(), wantCode)
+		}
+	}
+	check := func(wantRequest models.RefundRequestStatus, wantRefunded int64) *models.RefundRequest {
+		t.Helper()
+		var request models.RefundRequest
+		env.DB.Where("payment_id = ?", payment.ID).First(&request)
+		var got models.Payment
+		env.DB.First(&got, payment.ID)
+		if request.Status != wantRequest || got.RefundedAmount != wantRefunded {
+			t.Fatalf("pedido %s, reembolsado %d; want %s, %d", request.Status, got.RefundedAmount, wantRequest, wantRefunded)
+		}
+		return &request
+	}
+
+	// O pedido do cliente não devolve nada até ser aprovado
+	body := fmt.Sprintf(`{"paymentIntentId": %q, "amount": 4000, "reason": "serviço incompleto"}`, payment.GatewayIntentID)
+	post("/api/payments/refund", body, env.client, http.StatusOK)
+	request := check(models.RefundRequestStatusPending, 0)
+	post("/api/payments/refund", body, env.client, http.StatusConflict)
+
+	approve := fmt.Sprintf("/api/payments/refund-requests/%d/approve", request.ID)
+	post(approve, "{}", env.client, http.StatusForbidden)
+	post(approve, `{"note": "combinado com o cliente"}`, env.provider, http.StatusOK)
+	request = check(models.RefundRequestStatusApproved, 4000)
+	if request.RefundID == nil || request.ReviewedBy == nil || *request.ReviewedBy != env.provider.ID {
+		t.Errorf("pedido aprovado %+v; want reembolso e análise da prestadora", request)
+	}
+	post(approve, "{}", env.provider, http.StatusConflict)
+}
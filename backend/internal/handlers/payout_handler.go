@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/services"
+)
+
+type PayoutHandler struct {
+	payoutRepo    *repositories.PayoutRepository
+	userRepo      *repositories.UserRepository
+	authService   *services.AuthService
+	payoutService *services.PayoutService
+	commission    *services.CommissionService
+	documents     *services.DocumentService
+}
+
+func NewPayoutHandler(
+	payoutRepo *repositories.PayoutRepository,
+	userRepo *repositories.UserRepository,
+	authService *services.AuthService,
+	payoutService *services.PayoutService,
+	commission *services.CommissionService,
+	documents *services.DocumentService,
+) *PayoutHandler {
+	return &PayoutHandler{
+		payoutRepo:    payoutRepo,
+		userRepo:      userRepo,
+		authService:   authService,
+		payoutService: payoutService,
+		commission:    commission,
+		documents:     documents,
+	}
+}
+
+// PayoutDestinationRequest contém a conta bancária ou a chave Pix para receber os saques
+type PayoutDestinationRequest struct {
+	Type models.PayoutDestinationType `json:"type" binding:"required"`
+
+	PixKeyType models.PixKeyType `json:"pix_key_type"`
+	PixKey     string            `json:"pix_key"`
+
+	BankCode      string                 `json:"bank_code"`
+	Branch        string                 `json:"branch"`
+	AccountNumber string                 `json:"account_number"`
+	AccountType   models.BankAccountType `json:"account_type"`
+
+	HolderName     string `json:"holder_name" binding:"required"`
+	HolderDocument string `json:"holder_document" binding:"required"`
+}
+
+// GetWallet retorna os saldos em custódia, disponível, em trânsito e já sacado da prestadora
+func (h *PayoutHandler) GetWallet(c *gin.Context) {
+	providerID, ok := h.authenticatedProvider(c)
+	if !ok {
+		return
+	}
+
+	wallet, err := h.payoutService.Wallet(providerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao consultar saldo"})
+		return
+	}
+
+	c.JSON(http.StatusOK, wallet)
+}
+
+// GetEarnings retorna o extrato de ganhos da prestadora no período, com a comissão retida
+// em cada repasse. Aceita from e to no formato AAAA-MM-DD; o padrão são os últimos 30 dias.
+func (h *PayoutHandler) GetEarnings(c *gin.Context) {
+	providerID, ok := h.authenticatedProvider(c)
+	if !ok {
+		return
+	}
+
+	from, to, ok := ledgerPeriod(c)
+	if !ok {
+		return
+	}
+
+	statement, err := h.commission.EarningsStatement(providerID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar extrato"})
+		return
+	}
+
+	c.JSON(http.StatusOK, statement)
+}
+
+// GetStatement retorna o extrato mensal de ganhos da prestadora (month no formato AAAA-MM),
+// com bruto, reembolsos, taxas, gorjetas e líquido, em JSON ou, com format=pdf ou
+// format=csv, como arquivo assinado
+func (h *PayoutHandler) GetStatement(c *gin.Context) {
+	providerID, ok := h.authenticatedProvider(c)
+	if !ok {
+		return
+	}
+
+	month, err := h.documents.ParseStatementMonth(c.Param("month"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	format, ok := documentFormat(c)
+	if !ok {
+		return
+	}
+
+	statement, err := h.documents.MonthlyStatement(providerID, month)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar extrato"})
+		return
+	}
+
+	filename := "extrato-" + statement.Month
+	switch format {
+	case services.DocumentFormatPDF:
+		sendDocument(c, filename+".pdf", pdfContentType, statement.Signature, h.documents.StatementPDF(statement))
+	case services.DocumentFormatCSV:
+		body, err := h.documents.StatementCSV(statement)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar extrato"})
+			return
+		}
+		sendDocument(c, filename+".csv", csvContentType, statement.Signature, body)
+	default:
+		c.JSON(http.StatusOK, statement)
+	}
+}
+
+// GetDestination retorna a conta ou chave Pix cadastrada para saques
+func (h *PayoutHandler) GetDestination(c *gin.Context) {
+	providerID, ok := h.authenticatedProvider(c)
+	if !ok {
+		return
+	}
+
+	destination, err := h.payoutRepo.FindDestination(providerID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrPayoutDestinationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar dados de recebimento"})
+		return
+	}
+
+	c.JSON(http.StatusOK, destination)
+}
+
+// SaveDestination cadastra ou substitui a conta ou chave Pix para saques
+func (h *PayoutHandler) SaveDestination(c *gin.Context) {
+	providerID, ok := h.authenticatedProvider(c)
+	if !ok {
+		return
+	}
+
+	var req PayoutDestinationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	destination := &models.PayoutDestination{
+		ProviderID:     providerID,
+		Type:           req.Type,
+		PixKeyType:     req.PixKeyType,
+		PixKey:         req.PixKey,
+		BankCode:       req.BankCode,
+		Branch:         req.Branch,
+		AccountNumber:  req.AccountNumber,
+		AccountType:    req.AccountType,
+		HolderName:     req.HolderName,
+		HolderDocument: req.HolderDocument,
+	}
+	if err := h.payoutService.SaveDestination(destination); err != nil {
+		if errors.Is(err, services.ErrInvalidPayoutDestination) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar dados de recebimento"})
+		return
+	}
+
+	c.JSON(http.StatusOK, destination)
+}
+
+// GetPayouts lista os saques da prestadora autenticada
+func (h *PayoutHandler) GetPayouts(c *gin.Context) {
+	providerID, ok := h.authenticatedProvider(c)
+	if !ok {
+		return
+	}
+
+	payouts, err := h.payoutService.History(providerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar saques"})
+		return
+	}
+
+	c.JSON(http.StatusOK, payouts)
+}
+
+// RequestPayout solicita o saque de um valor em centavos, ou de todo o saldo disponível
+// quando o valor é omitido
+func (h *PayoutHandler) RequestPayout(c *gin.Context) {
+	providerID, ok := h.authenticatedProvider(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Amount int64 `json:"amount"` // Em centavos; zero saca todo o saldo disponível
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Amount < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Valor inválido"})
+		return
+	}
+
+	payout, err := h.payoutService.RequestPayout(providerID, req.Amount, false)
+	if err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrPayoutDestinationNotFound):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, repositories.ErrInsufficientBalance),
+			errors.Is(err, repositories.ErrPayoutBelowMinimum):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao solicitar saque"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, payout)
+}
+
+// authenticatedProvider retorna o ID da prestadora autenticada.
+// Em caso de erro, a resposta já é enviada e ok é falso.
+func (h *PayoutHandler) authenticatedProvider(c *gin.Context) (uint, bool) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return 0, false
+	}
+
+	user, err := h.userRepo.FindByID(userID)
+	if err != nil || user.UserType != models.UserTypeProvider {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Acesso negado"})
+		return 0, false
+	}
+
+	return userID, true
+}
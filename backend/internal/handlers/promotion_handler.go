@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/services"
+)
+
+type PromotionHandler struct {
+	promotionRepo    *repositories.PromotionRepository
+	authService      *services.AuthService
+	promotionService *services.PromotionService
+}
+
+func NewPromotionHandler(
+	promotionRepo *repositories.PromotionRepository,
+	authService *services.AuthService,
+	promotionService *services.PromotionService,
+) *PromotionHandler {
+	return &PromotionHandler{
+		promotionRepo:    promotionRepo,
+		authService:      authService,
+		promotionService: promotionService,
+	}
+}
+
+// PromoCodeRequest contém os campos de um cupom. Valores em centavos; limites zerados
+// ficam desativados.
+type PromoCodeRequest struct {
+	Code             string     `json:"code" binding:"required"`
+	Description      string     `json:"description"`
+	PercentOff       int        `json:"percent_off"`
+	AmountOff        int64      `json:"amount_off"`
+	MaxDiscount      int64      `json:"max_discount"`
+	MinSpend         int64      `json:"min_spend"`
+	MaxRedemptions   int        `json:"max_redemptions"`
+	PerUserLimit     *int       `json:"per_user_limit"` // Padrão: um uso por cliente
+	FirstBookingOnly bool       `json:"first_booking_only"`
+	StartsAt         *time.Time `json:"starts_at"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+	Active           *bool      `json:"active"`
+}
+
+func (req *PromoCodeRequest) apply(promo *models.PromoCode) {
+	promo.Description = req.Description
+	promo.PercentOff = req.PercentOff
+	promo.AmountOff = req.AmountOff
+	promo.MaxDiscount = req.MaxDiscount
+	promo.MinSpend = req.MinSpend
+	promo.MaxRedemptions = req.MaxRedemptions
+	if req.PerUserLimit != nil {
+		promo.PerUserLimit = *req.PerUserLimit
+	}
+	promo.FirstBookingOnly = req.FirstBookingOnly
+	promo.StartsAt = req.StartsAt
+	promo.ExpiresAt = req.ExpiresAt
+	if req.Active != nil {
+		promo.Active = *req.Active
+	}
+}
+
+// GetPromoCodes lista os cupons com o número de usos ativos
+func (h *PromotionHandler) GetPromoCodes(c *gin.Context) {
+	promos, err := h.promotionRepo.GetPromoCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar cupons"})
+		return
+	}
+
+	c.JSON(http.StatusOK, promos)
+}
+
+// CreatePromoCode cadastra um cupom
+func (h *PromotionHandler) CreatePromoCode(c *gin.Context) {
+	var req PromoCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	promo := &models.PromoCode{Code: req.Code, Active: true, PerUserLimit: 1}
+	req.apply(promo)
+	if err := h.promotionService.ValidatePromoCode(promo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.promotionRepo.CreatePromoCode(promo); err != nil {
+		if errors.Is(err, repositories.ErrPromoCodeExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao cadastrar cupom"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, promo)
+}
+
+// UpdatePromoCode substitui as regras de um cupom. O código não muda; reservas já feitas
+// mantêm o desconto aplicado.
+func (h *PromotionHandler) UpdatePromoCode(c *gin.Context) {
+	promo, ok := h.loadPromoCode(c)
+	if !ok {
+		return
+	}
+
+	var req PromoCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.apply(promo)
+	if err := h.promotionService.ValidatePromoCode(promo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.promotionRepo.UpdatePromoCode(promo); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar cupom"})
+		return
+	}
+
+	c.JSON(http.StatusOK, promo)
+}
+
+// DeactivatePromoCode desativa um cupom. Ele é mantido para consulta das reservas que o usaram.
+func (h *PromotionHandler) DeactivatePromoCode(c *gin.Context) {
+	promo, ok := h.loadPromoCode(c)
+	if !ok {
+		return
+	}
+
+	promo.Active = false
+	if err := h.promotionRepo.UpdatePromoCode(promo); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao desativar cupom"})
+		return
+	}
+
+	c.JSON(http.StatusOK, promo)
+}
+
+// GetWallet retorna o saldo de créditos do cliente autenticado e os movimentos do período
+func (h *PromotionHandler) GetWallet(c *gin.Context) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	from, to, ok := ledgerPeriod(c)
+	if !ok {
+		return
+	}
+
+	wallet, err := h.promotionService.Wallet(userID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao consultar carteira"})
+		return
+	}
+
+	c.JSON(http.StatusOK, wallet)
+}
+
+// GetMyReferrals retorna o código de indicação do cliente autenticado e suas indicações
+func (h *PromotionHandler) GetMyReferrals(c *gin.Context) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	summary, err := h.promotionService.Referrals(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar indicações"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+type RedeemReferralRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// RedeemReferral vincula o cliente autenticado a quem o indicou. Os créditos dos dois são
+// concedidos após o primeiro atendimento concluído do cliente.
+func (h *PromotionHandler) RedeemReferral(c *gin.Context) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	var req RedeemReferralRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	referral, err := h.promotionService.RedeemReferral(userID, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrReferralCodeNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, repositories.ErrAlreadyReferred):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrSelfReferral), errors.Is(err, services.ErrReferralNotAllowed):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao registrar indicação"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, referral)
+}
+
+// respondPromotionError responde aos erros do cupom e dos créditos aplicados numa reserva
+func respondPromotionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repositories.ErrPromoCodeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, repositories.ErrCreditChanged):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, repositories.ErrPromoCodeUnavailable),
+		errors.Is(err, repositories.ErrPromoCodeUserLimit),
+		errors.Is(err, repositories.ErrPromoCodeFirstBooking),
+		errors.Is(err, services.ErrPromoCodeNotStarted),
+		errors.Is(err, services.ErrPromoCodeExpired),
+		errors.Is(err, services.ErrPromoCodeMinSpend):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar agendamento"})
+	}
+}
+
+// loadPromoCode carrega o cupom da rota. Em caso de erro, a resposta já é enviada e ok é falso.
+func (h *PromotionHandler) loadPromoCode(c *gin.Context) (*models.PromoCode, bool) {
+	promoID, err := parseIDParam(c, "promoId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return nil, false
+	}
+
+	promo, err := h.promotionRepo.FindPromoCodeByID(promoID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrPromoCodeNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar cupom"})
+		return nil, false
+	}
+	return promo, true
+}
@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/services"
+)
+
+type ProviderHandler struct {
+	userRepo            *repositories.UserRepository
+	authService         *services.AuthService
+	cancellationService *services.CancellationService
+	availabilityService *services.AvailabilityService
+}
+
+func NewProviderHandler(
+	userRepo *repositories.UserRepository,
+	authService *services.AuthService,
+	cancellationService *services.CancellationService,
+	availabilityService *services.AvailabilityService,
+) *ProviderHandler {
+	return &ProviderHandler{
+		userRepo:            userRepo,
+		authService:         authService,
+		cancellationService: cancellationService,
+		availabilityService: availabilityService,
+	}
+}
+
+// UpdateProviderSettingsRequest contém as configurações que a prestadora pode alterar.
+// Campos ausentes mantêm o valor atual.
+type UpdateProviderSettingsRequest struct {
+	CancellationPolicy *models.CancellationPolicy `json:"cancellation_policy"`
+	BufferMinutes      *int                       `json:"buffer_minutes"`
+	TravelTimeEnabled  *bool                      `json:"travel_time_enabled"`
+
+	InstantBookingEnabled         *bool    `json:"instant_booking_enabled"`
+	InstantBookingVerifiedOnly    *bool    `json:"instant_booking_verified_only"`
+	InstantBookingMinRating       *float64 `json:"instant_booking_min_rating"`
+	InstantBookingPastClientsOnly *bool    `json:"instant_booking_past_clients_only"`
+	InstantBookingDailyCap        *int     `json:"instant_booking_daily_cap"`
+
+	MinNoticeMinutes *int `json:"min_notice_minutes"`
+	MaxAdvanceDays   *int `json:"max_advance_days"`
+	DailyBookingCap  *int `json:"daily_booking_cap"`
+	WeeklyBookingCap *int `json:"weekly_booking_cap"`
+
+	City *string `json:"city"` // Cidade de atuação
+
+	AutoPayoutEnabled *bool  `json:"auto_payout_enabled"`
+	AutoPayoutMinimum *int64 `json:"auto_payout_minimum"` // Em centavos
+}
+
+const (
+	// MaxBufferMinutes limita o intervalo fixo entre atendimentos
+	MaxBufferMinutes = 240
+	// MaxMinNoticeMinutes limita a antecedência mínima exigida (30 dias)
+	MaxMinNoticeMinutes = 30 * 24 * 60
+	// MaxAdvanceDaysLimit limita o horizonte de agendamento (2 anos)
+	MaxAdvanceDaysLimit = 730
+)
+
+// GetSettings retorna as configurações da prestadora autenticada
+func (h *ProviderHandler) GetSettings(c *gin.Context) {
+	profile, ok := h.authenticatedProfile(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// UpdateSettings atualiza as configurações da prestadora autenticada
+func (h *ProviderHandler) UpdateSettings(c *gin.Context) {
+	profile, ok := h.authenticatedProfile(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateProviderSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.CancellationPolicy != nil {
+		if !req.CancellationPolicy.IsValid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Política de cancelamento inválida"})
+			return
+		}
+		profile.CancellationPolicy = *req.CancellationPolicy
+	}
+
+	if req.BufferMinutes != nil {
+		if *req.BufferMinutes < 0 || *req.BufferMinutes > MaxBufferMinutes {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Intervalo entre atendimentos deve estar entre 0 e %d minutos", MaxBufferMinutes)})
+			return
+		}
+		profile.BufferMinutes = *req.BufferMinutes
+	}
+
+	if req.TravelTimeEnabled != nil {
+		profile.TravelTimeEnabled = *req.TravelTimeEnabled
+	}
+
+	if req.InstantBookingEnabled != nil {
+		profile.InstantBookingEnabled = *req.InstantBookingEnabled
+	}
+	if req.InstantBookingVerifiedOnly != nil {
+		profile.InstantBookingVerifiedOnly = *req.InstantBookingVerifiedOnly
+	}
+	if req.InstantBookingMinRating != nil {
+		if *req.InstantBookingMinRating < 0 || *req.InstantBookingMinRating > 5 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Avaliação mínima deve estar entre 0 e 5"})
+			return
+		}
+		profile.InstantBookingMinRating = *req.InstantBookingMinRating
+	}
+	if req.InstantBookingPastClientsOnly != nil {
+		profile.InstantBookingPastClientsOnly = *req.InstantBookingPastClientsOnly
+	}
+	if req.InstantBookingDailyCap != nil {
+		if *req.InstantBookingDailyCap < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Limite diário de reservas instantâneas inválido"})
+			return
+		}
+		profile.InstantBookingDailyCap = *req.InstantBookingDailyCap
+	}
+
+	if req.MinNoticeMinutes != nil {
+		if *req.MinNoticeMinutes < 0 || *req.MinNoticeMinutes > MaxMinNoticeMinutes {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Antecedência mínima deve estar entre 0 e %d minutos", MaxMinNoticeMinutes)})
+			return
+		}
+		profile.MinNoticeMinutes = *req.MinNoticeMinutes
+	}
+	if req.MaxAdvanceDays != nil {
+		if *req.MaxAdvanceDays < 0 || *req.MaxAdvanceDays > MaxAdvanceDaysLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Horizonte de agendamento deve estar entre 0 e %d dias", MaxAdvanceDaysLimit)})
+			return
+		}
+		profile.MaxAdvanceDays = *req.MaxAdvanceDays
+	}
+	if req.DailyBookingCap != nil {
+		if *req.DailyBookingCap < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Limite diário de agendamentos inválido"})
+			return
+		}
+		profile.DailyBookingCap = *req.DailyBookingCap
+	}
+	if req.WeeklyBookingCap != nil {
+		if *req.WeeklyBookingCap < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Limite semanal de agendamentos inválido"})
+			return
+		}
+		profile.WeeklyBookingCap = *req.WeeklyBookingCap
+	}
+
+	if req.City != nil {
+		profile.City = strings.TrimSpace(*req.City)
+	}
+
+	if req.AutoPayoutEnabled != nil {
+		profile.AutoPayoutEnabled = *req.AutoPayoutEnabled
+	}
+	if req.AutoPayoutMinimum != nil {
+		if *req.AutoPayoutMinimum < services.MinPayoutAmount {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Valor mínimo do repasse automático deve ser de pelo menos %d centavos", services.MinPayoutAmount)})
+			return
+		}
+		profile.AutoPayoutMinimum = *req.AutoPayoutMinimum
+	}
+
+	if err := h.userRepo.UpdateProviderProfile(profile); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar configurações"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// GetCancellationPolicy retorna a política de cancelamento de uma prestadora e suas faixas de taxa
+func (h *ProviderHandler) GetCancellationPolicy(c *gin.Context) {
+	providerID, err := parseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	policy := models.CancellationPolicyFlexible
+	profile, err := h.userRepo.GetProviderProfile(providerID)
+	if err != nil && !errors.Is(err, repositories.ErrUserNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar prestadora"})
+		return
+	}
+	if profile != nil {
+		policy = profile.CancellationPolicy
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"policy":                 policy,
+		"tiers":                  h.cancellationService.Tiers(policy),
+		"provider_cancellations": profileCancellations(profile),
+	})
+}
+
+// GetSlots retorna os horários livres de uma prestadora em um dia.
+// Parâmetros: date (YYYY-MM-DD, obrigatório), duration (minutos, opcional) e
+// latitude/longitude do atendimento (opcionais, para considerar o deslocamento).
+func (h *ProviderHandler) GetSlots(c *gin.Context) {
+	providerID, err := parseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", c.Query("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data inválida, use o formato YYYY-MM-DD"})
+		return
+	}
+
+	duration := models.DefaultAppointmentDuration
+	if value := c.Query("duration"); value != "" {
+		duration, err = strconv.Atoi(value)
+		if err != nil || duration <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Duração inválida"})
+			return
+		}
+	}
+
+	query := services.SlotQuery{
+		ProviderID: providerID,
+		Date:       date,
+		Duration:   duration,
+	}
+
+	if c.Query("latitude") != "" || c.Query("longitude") != "" {
+		query.Latitude, err = strconv.ParseFloat(c.Query("latitude"), 64)
+		if err == nil {
+			query.Longitude, err = strconv.ParseFloat(c.Query("longitude"), 64)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Localização inválida"})
+			return
+		}
+	}
+
+	// Reservas da lista de espera feitas para o próprio cliente não bloqueiam seus horários
+	if id, err := userIDFromToken(c, h.authService); err == nil {
+		query.UserID = id
+	}
+
+	slots, err := h.availabilityService.FreeSlots(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar horários"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"slots": slots})
+}
+
+// authenticatedProfile retorna o perfil da prestadora autenticada, criando-o se ainda não existir.
+// Em caso de erro, a resposta já é enviada e ok é falso.
+func (h *ProviderHandler) authenticatedProfile(c *gin.Context) (*models.ProviderProfile, bool) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return nil, false
+	}
+
+	user, err := h.userRepo.FindByID(userID)
+	if err != nil || user.UserType != models.UserTypeProvider {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Acesso negado"})
+		return nil, false
+	}
+
+	profile, err := h.userRepo.GetProviderProfile(userID)
+	if errors.Is(err, repositories.ErrUserNotFound) {
+		profile = &models.ProviderProfile{
+			UserID:             userID,
+			CancellationPolicy: models.CancellationPolicyFlexible,
+		}
+		err = h.userRepo.CreateProviderProfile(profile)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar perfil"})
+		return nil, false
+	}
+
+	return profile, true
+}
+
+// profileCancellations retorna o número de cancelamentos feitos pela prestadora
+func profileCancellations(profile *models.ProviderProfile) int {
+	if profile == nil {
+		return 0
+	}
+	return profile.ProviderCancellations
+}
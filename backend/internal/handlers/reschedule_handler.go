@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/services"
+)
+
+type RescheduleHandler struct {
+	appointmentRepo *repositories.AppointmentRepository
+	rescheduleRepo  *repositories.RescheduleRepository
+	userRepo        *repositories.UserRepository
+	authService     *services.AuthService
+	bookingRules    *services.BookingRulesService
+	auditService    *services.AuditService
+}
+
+func NewRescheduleHandler(
+	appointmentRepo *repositories.AppointmentRepository,
+	rescheduleRepo *repositories.RescheduleRepository,
+	userRepo *repositories.UserRepository,
+	authService *services.AuthService,
+	bookingRules *services.BookingRulesService,
+	auditService *services.AuditService,
+) *RescheduleHandler {
+	return &RescheduleHandler{
+		appointmentRepo: appointmentRepo,
+		rescheduleRepo:  rescheduleRepo,
+		userRepo:        userRepo,
+		authService:     authService,
+		bookingRules:    bookingRules,
+		auditService:    auditService,
+	}
+}
+
+type RescheduleProposalRequest struct {
+	Date   time.Time `json:"date" binding:"required"`
+	Time   string    `json:"time" binding:"required"`
+	Reason string    `json:"reason"`
+}
+
+// ProposeReschedule cria um pedido de remarcação para um agendamento
+func (h *RescheduleHandler) ProposeReschedule(c *gin.Context) {
+	userID, appointment, ok := h.participantAppointment(c)
+	if !ok {
+		return
+	}
+
+	if !reschedulable(appointment) {
+		c.JSON(http.StatusConflict, gin.H{"error": repositories.ErrNotReschedulable.Error()})
+		return
+	}
+
+	var req RescheduleProposalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	request, err := newRescheduleRequest(appointment, userID, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.rescheduleRepo.Create(request); err != nil {
+		if errors.Is(err, repositories.ErrReschedulePendingExist) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar pedido de remarcação"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, request)
+}
+
+// GetRescheduleHistory retorna todos os pedidos de remarcação de um agendamento
+func (h *RescheduleHandler) GetRescheduleHistory(c *gin.Context) {
+	_, appointment, ok := h.participantAppointment(c)
+	if !ok {
+		return
+	}
+
+	requests, err := h.rescheduleRepo.GetByAppointment(appointment.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar remarcações"})
+		return
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+// AcceptReschedule aceita um pedido de remarcação e aplica o novo horário
+func (h *RescheduleHandler) AcceptReschedule(c *gin.Context) {
+	userID, appointment, request, ok := h.pendingRequest(c)
+	if !ok {
+		return
+	}
+
+	if !reschedulable(appointment) {
+		c.JSON(http.StatusConflict, gin.H{"error": repositories.ErrNotReschedulable.Error()})
+		return
+	}
+
+	previous := *appointment
+	appointment.Date = request.Date
+	appointment.Time = request.Time
+
+	// O novo horário respeita a antecedência mínima e o horizonte da prestadora, como na
+	// criação. O preço combinado não muda: só a data e o horário são remarcados.
+	profile, err := h.userRepo.GetProviderProfile(appointment.ProviderID)
+	if err != nil {
+		profile = nil
+	}
+	if err := h.bookingRules.CheckWindow(profile, appointment.StartsAt()); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.rescheduleRepo.Accept(request, appointment, userID, rescheduleEvents(h.auditService, &previous, appointment, &userID)...); err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrSlotUnavailable), errors.Is(err, repositories.ErrRescheduleNotPending),
+			errors.Is(err, repositories.ErrNotReschedulable):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case repositories.IsBookingCapError(err):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao remarcar agendamento"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, appointment)
+}
+
+// RejectReschedule recusa um pedido de remarcação
+func (h *RescheduleHandler) RejectReschedule(c *gin.Context) {
+	userID, _, request, ok := h.pendingRequest(c)
+	if !ok {
+		return
+	}
+
+	if err := h.rescheduleRepo.Reject(request, userID); err != nil {
+		if errors.Is(err, repositories.ErrRescheduleNotPending) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao recusar remarcação"})
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// CounterReschedule responde a um pedido de remarcação com outro horário
+func (h *RescheduleHandler) CounterReschedule(c *gin.Context) {
+	userID, appointment, request, ok := h.pendingRequest(c)
+	if !ok {
+		return
+	}
+
+	var req RescheduleProposalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	counter, err := newRescheduleRequest(appointment, userID, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.rescheduleRepo.Counter(request, counter); err != nil {
+		if errors.Is(err, repositories.ErrRescheduleNotPending) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar contraproposta"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, counter)
+}
+
+// participantAppointment carrega o agendamento da rota e verifica se o usuário participa dele.
+// Em caso de erro, a resposta já é enviada e ok é falso.
+func (h *RescheduleHandler) participantAppointment(c *gin.Context) (uint, *models.Appointment, bool) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return 0, nil, false
+	}
+
+	appointmentID, err := parseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return 0, nil, false
+	}
+
+	appointment, err := h.appointmentRepo.FindByID(appointmentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agendamento não encontrado"})
+		return 0, nil, false
+	}
+
+	if userID != appointment.UserID && userID != appointment.ProviderID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Acesso negado"})
+		return 0, nil, false
+	}
+
+	return userID, appointment, true
+}
+
+// pendingRequest carrega o pedido de remarcação da rota e verifica se o usuário pode respondê-lo
+func (h *RescheduleHandler) pendingRequest(c *gin.Context) (uint, *models.Appointment, *models.RescheduleRequest, bool) {
+	userID, appointment, ok := h.participantAppointment(c)
+	if !ok {
+		return 0, nil, nil, false
+	}
+
+	requestID, err := parseIDParam(c, "requestId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return 0, nil, nil, false
+	}
+
+	request, err := h.rescheduleRepo.FindByID(requestID)
+	if err != nil || request.AppointmentID != appointment.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pedido de remarcação não encontrado"})
+		return 0, nil, nil, false
+	}
+
+	if request.Status != models.RescheduleStatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Pedido de remarcação já foi respondido"})
+		return 0, nil, nil, false
+	}
+
+	// Apenas a outra parte pode responder ao pedido
+	if request.ProposedBy == userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Aguardando resposta da outra parte"})
+		return 0, nil, nil, false
+	}
+
+	return userID, appointment, request, true
+}
+
+// reschedulable indica se o agendamento ainda pode mudar de horário
+func reschedulable(appointment *models.Appointment) bool {
+	return appointment.Status == models.AppointmentStatusPending || appointment.Status == models.AppointmentStatusConfirmed
+}
+
+// newRescheduleRequest monta um pedido de remarcação validando o horário proposto
+func newRescheduleRequest(appointment *models.Appointment, userID uint, req RescheduleProposalRequest) (*models.RescheduleRequest, error) {
+	if _, err := time.Parse("15:04", req.Time); err != nil {
+		return nil, errors.New("horário inválido, use o formato HH:MM")
+	}
+
+	proposed := models.Appointment{Date: req.Date, Time: req.Time}
+	if !proposed.StartsAt().After(time.Now()) {
+		return nil, errors.New("o novo horário deve estar no futuro")
+	}
+
+	return &models.RescheduleRequest{
+		AppointmentID: appointment.ID,
+		ProposedBy:    userID,
+		Date:          req.Date,
+		Time:          req.Time,
+		PreviousDate:  appointment.Date,
+		PreviousTime:  appointment.Time,
+		Reason:        req.Reason,
+		Status:        models.RescheduleStatusPending,
+	}, nil
+}
+
+// rescheduleEvents monta os eventos do histórico da mudança de horário e, se houver, da de preço
+func rescheduleEvents(auditService *services.AuditService, previous, appointment *models.Appointment, actorID *uint) []*models.AppointmentEvent {
+	events := []*models.AppointmentEvent{
+		auditService.Event(appointment.ID, actorID, models.AppointmentEventRescheduled,
+			map[string]interface{}{"date": previous.Date, "time": previous.Time},
+			map[string]interface{}{"date": appointment.Date, "time": appointment.Time}),
+	}
+	if previous.Price != appointment.Price {
+		events = append(events, auditService.Event(appointment.ID, actorID, models.AppointmentEventPriceChanged, previous.Price, appointment.Price))
+	}
+	return events
+}
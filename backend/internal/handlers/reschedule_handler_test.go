@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/testutil"
+	"github.com/xclean/backend/internal/testutil/apptest"
+)
+
+func TestAcceptRescheduleKeepsPriceAndChecksWindow(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name       string
+		notice     time.Duration // Antecedência do horário proposto
+		wantStatus int
+	}{
+		{"fora da antecedência mínima", 2 * time.Hour, http.StatusUnprocessableEntity},
+		{"dentro da janela", 5 * 24 * time.Hour, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := apptest.New(t)
+			db := app.DB
+			client := testutil.CreateUser(t, db, models.UserTypeClient)
+			// A prestadora aumentou o valor por hora depois do agendamento
+			provider := testutil.CreateProvider(t, db, models.ProviderProfile{HourlyRate: 90, MinNoticeMinutes: 24 * 60})
+			day := time.Now().In(models.ScheduleLocation).AddDate(0, 0, 3)
+			appointment := testutil.CreateAppointment(t, db, models.Appointment{
+				UserID: client.ID, ProviderID: provider.ID, Price: 120, Duration: 120,
+				Status: models.AppointmentStatusConfirmed,
+				Date:   time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC), Time: "10:00",
+			})
+
+			proposed := time.Now().Add(tt.notice).In(models.ScheduleLocation)
+			request := &models.RescheduleRequest{
+				AppointmentID: appointment.ID, ProposedBy: client.ID, Status: models.RescheduleStatusPending,
+				Date: time.Date(proposed.Year(), proposed.Month(), proposed.Day(), 0, 0, 0, 0, time.UTC), Time: proposed.Format("15:04"),
+				PreviousDate: appointment.Date, PreviousTime: appointment.Time,
+			}
+			if err := db.Create(request).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			handler := NewRescheduleHandler(app.AppointmentRepo, app.RescheduleRepo, app.UserRepo, app.AuthService,
+				app.BookingRulesService, app.AuditService)
+			router := gin.New()
+			router.POST("/api/appointments/:id/reschedule/:requestId/accept", handler.AcceptReschedule)
+
+			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/appointments/%d/reschedule/%d/accept", appointment.ID, request.ID), nil)
+			req.Header.Set("Authorization", app.Bearer(t, provider))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d: %s; want %d", w.Code, w.Body.String(), tt.wantStatus)
+			}
+
+			var stored models.Appointment
+			db.First(&stored, appointment.ID)
+			if stored.Price != 120 {
+				t.Errorf("preço %v; want o combinado, 120", stored.Price)
+			}
+			var response models.Appointment
+			if tt.wantStatus == http.StatusOK {
+				if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Price != 120 || stored.Time != request.Time {
+					t.Errorf("resposta %+v, horário gravado %s; want preço 120 no horário %s", response, stored.Time, request.Time)
+				}
+			} else if stored.Time != appointment.Time {
+				t.Errorf("horário gravado %s; want o anterior, %s", stored.Time, appointment.Time)
+			}
+		})
+	}
+}
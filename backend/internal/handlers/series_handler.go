@@ -0,0 +1,381 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/services"
+)
+
+// Escopos de cancelamento de ocorrências
+const (
+	CancelScopeThis      = "this"
+	CancelScopeFollowing = "following"
+)
+
+type SeriesHandler struct {
+	seriesRepo          *repositories.SeriesRepository
+	appointmentRepo     *repositories.AppointmentRepository
+	userRepo            *repositories.UserRepository
+	authService         *services.AuthService
+	seriesService       *services.SeriesService
+	cancellationService *services.CancellationService
+	pricingService      *services.PricingService
+	auditService        *services.AuditService
+	waitlistService     *services.WaitlistService
+	refundIssuer        services.RefundIssuer
+}
+
+func NewSeriesHandler(
+	seriesRepo *repositories.SeriesRepository,
+	appointmentRepo *repositories.AppointmentRepository,
+	userRepo *repositories.UserRepository,
+	authService *services.AuthService,
+	seriesService *services.SeriesService,
+	cancellationService *services.CancellationService,
+	pricingService *services.PricingService,
+	auditService *services.AuditService,
+	waitlistService *services.WaitlistService,
+	refundIssuer services.RefundIssuer,
+) *SeriesHandler {
+	return &SeriesHandler{
+		seriesRepo:          seriesRepo,
+		appointmentRepo:     appointmentRepo,
+		userRepo:            userRepo,
+		authService:         authService,
+		seriesService:       seriesService,
+		cancellationService: cancellationService,
+		pricingService:      pricingService,
+		auditService:        auditService,
+		waitlistService:     waitlistService,
+		refundIssuer:        refundIssuer,
+	}
+}
+
+type CreateSeriesRequest struct {
+	ProviderID uint      `json:"provider_id" binding:"required"`
+	Service    string    `json:"service" binding:"required"`
+	StartDate  time.Time `json:"start_date" binding:"required"`
+	Time       string    `json:"time" binding:"required"`
+	RRule      string    `json:"rrule" binding:"required"` // Ex.: FREQ=WEEKLY;INTERVAL=2;COUNT=10
+	Duration   int       `json:"duration"`
+	Notes      string    `json:"notes"`
+	Location   string    `json:"location"`
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+}
+
+// CreateSeries cria uma série recorrente e materializa as primeiras ocorrências
+func (h *SeriesHandler) CreateSeries(c *gin.Context) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	var req CreateSeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := services.ParseRRule(req.RRule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := time.Parse("15:04", req.Time); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Horário inválido, use o formato HH:MM"})
+		return
+	}
+
+	provider, err := h.userRepo.FindByID(req.ProviderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prestadora não encontrada"})
+		return
+	}
+	if provider.UserType != models.UserTypeProvider {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Usuário não é uma prestadora"})
+		return
+	}
+
+	if req.Duration <= 0 {
+		req.Duration = models.DefaultAppointmentDuration
+	}
+
+	series := &models.AppointmentSeries{
+		UserID:     userID,
+		ProviderID: req.ProviderID,
+		RRule:      req.RRule,
+		StartDate:  req.StartDate,
+		Time:       req.Time,
+		Service:    req.Service,
+		Duration:   req.Duration,
+		Notes:      req.Notes,
+		Location:   req.Location,
+		Latitude:   req.Latitude,
+		Longitude:  req.Longitude,
+		Status:     models.SeriesStatusActive,
+	}
+
+	if err := h.seriesRepo.Create(series); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar série"})
+		return
+	}
+
+	occurrences, err := h.seriesService.Materialize(series)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar ocorrências da série"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"series":      series,
+		"occurrences": occurrences,
+	})
+}
+
+// GetSeriesList retorna as séries do usuário autenticado
+func (h *SeriesHandler) GetSeriesList(c *gin.Context) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	series, err := h.seriesRepo.GetByParticipant(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar séries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
+
+// GetSeries retorna uma série com suas ocorrências e conflitos
+func (h *SeriesHandler) GetSeries(c *gin.Context) {
+	_, series, ok := h.participantSeries(c)
+	if !ok {
+		return
+	}
+
+	occurrences, err := h.seriesRepo.GetOccurrences(series.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar ocorrências"})
+		return
+	}
+
+	conflicts, err := h.seriesRepo.GetConflicts(series.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar conflitos"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"series":      series,
+		"occurrences": occurrences,
+		"conflicts":   conflicts,
+	})
+}
+
+// SkipOccurrence pula uma única ocorrência da série
+func (h *SeriesHandler) SkipOccurrence(c *gin.Context) {
+	userID, series, occurrence, ok := h.seriesOccurrence(c)
+	if !ok {
+		return
+	}
+
+	// Pular uma ocorrência é planejado e não gera taxa de cancelamento
+	h.cancelOccurrences(c, series, []models.Appointment{*occurrence}, userID, "Ocorrência pulada", true)
+}
+
+// MoveOccurrence altera o horário de uma única ocorrência da série
+func (h *SeriesHandler) MoveOccurrence(c *gin.Context) {
+	userID, _, occurrence, ok := h.seriesOccurrence(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Date time.Time `json:"date" binding:"required"`
+		Time string    `json:"time" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := time.Parse("15:04", req.Time); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Horário inválido, use o formato HH:MM"})
+		return
+	}
+
+	previous := *occurrence
+	occurrence.Date = req.Date
+	occurrence.Time = req.Time
+	if profile, err := h.userRepo.GetProviderProfile(occurrence.ProviderID); err == nil {
+		occurrence.Price = h.pricingService.Quote(profile.HourlyRate, occurrence.Duration)
+	}
+
+	if err := h.seriesRepo.MoveOccurrence(occurrence, rescheduleEvents(h.auditService, &previous, occurrence, &userID)...); err != nil {
+		if errors.Is(err, repositories.ErrSlotUnavailable) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if repositories.IsBookingCapError(err) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao mover ocorrência"})
+		return
+	}
+
+	c.JSON(http.StatusOK, occurrence)
+}
+
+// CancelOccurrence cancela uma ocorrência ou esta e as seguintes (scope=following)
+func (h *SeriesHandler) CancelOccurrence(c *gin.Context) {
+	userID, series, occurrence, ok := h.seriesOccurrence(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Scope  string `json:"scope"`
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch req.Scope {
+	case "", CancelScopeThis:
+		h.cancelOccurrences(c, series, []models.Appointment{*occurrence}, userID, req.Reason, false)
+	case CancelScopeFollowing:
+		following, err := h.seriesRepo.GetFollowingOccurrences(series.ID, *occurrence.OccurrenceDate)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar ocorrências"})
+			return
+		}
+		if err := h.seriesRepo.End(series.ID, *occurrence.OccurrenceDate); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao encerrar série"})
+			return
+		}
+		h.cancelOccurrences(c, series, following, userID, req.Reason, false)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Escopo inválido, use this ou following"})
+	}
+}
+
+// cancelOccurrences cancela as ocorrências aplicando a política de cancelamento da prestadora.
+// Ocorrências puladas (skip) são canceladas sem taxa.
+func (h *SeriesHandler) cancelOccurrences(c *gin.Context, series *models.AppointmentSeries, occurrences []models.Appointment, userID uint, reason string, skip bool) {
+	policy := models.CancellationPolicyFlexible
+	if profile, err := h.userRepo.GetProviderProfile(series.ProviderID); err == nil {
+		policy = profile.CancellationPolicy
+	}
+
+	cancelled := make([]models.Appointment, 0, len(occurrences))
+	for i := range occurrences {
+		occurrence := &occurrences[i]
+		previousStatus := occurrence.Status
+		var err error
+		if skip {
+			err = h.cancellationService.Skip(occurrence, userID, reason)
+		} else {
+			err = h.cancellationService.Cancel(occurrence, policy, userID, reason)
+		}
+		if err != nil {
+			continue
+		}
+		event := h.auditService.Event(occurrence.ID, &userID, models.AppointmentEventCancelled, previousStatus, map[string]interface{}{
+			"status":           occurrence.Status,
+			"reason":           occurrence.CancellationReason,
+			"cancellation_fee": occurrence.CancellationFee,
+			"skipped":          skip,
+		})
+		if err := h.appointmentRepo.Cancel(occurrence, event); err != nil {
+			// A ocorrência começou ou foi cancelada enquanto a série era cancelada
+			if errors.Is(err, repositories.ErrAppointmentStatusChanged) {
+				continue
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao cancelar ocorrência"})
+			return
+		}
+		// Oferece o horário liberado à lista de espera da prestadora
+		if err := h.waitlistService.SlotFreed(occurrence); err != nil {
+			log.Printf("Erro ao oferecer vaga da ocorrência %d à lista de espera: %v", occurrence.ID, err)
+		}
+		if h.refundIssuer != nil {
+			if err := h.refundIssuer.RefundCancellation(occurrence); err != nil {
+				log.Printf("Erro ao reembolsar ocorrência %d cancelada: %v", occurrence.ID, err)
+			}
+		}
+		cancelled = append(cancelled, *occurrence)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Ocorrências canceladas com sucesso",
+		"cancelled": cancelled,
+	})
+}
+
+// participantSeries carrega a série da rota e verifica se o usuário participa dela.
+// Em caso de erro, a resposta já é enviada e ok é falso.
+func (h *SeriesHandler) participantSeries(c *gin.Context) (uint, *models.AppointmentSeries, bool) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return 0, nil, false
+	}
+
+	seriesID, err := parseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return 0, nil, false
+	}
+
+	series, err := h.seriesRepo.FindByID(seriesID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Série não encontrada"})
+		return 0, nil, false
+	}
+
+	if userID != series.UserID && userID != series.ProviderID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Acesso negado"})
+		return 0, nil, false
+	}
+
+	return userID, series, true
+}
+
+// seriesOccurrence carrega a série e a ocorrência indicadas na rota
+func (h *SeriesHandler) seriesOccurrence(c *gin.Context) (uint, *models.AppointmentSeries, *models.Appointment, bool) {
+	userID, series, ok := h.participantSeries(c)
+	if !ok {
+		return 0, nil, nil, false
+	}
+
+	appointmentID, err := parseIDParam(c, "appointmentId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return 0, nil, nil, false
+	}
+
+	occurrence, err := h.appointmentRepo.FindByID(appointmentID)
+	if err != nil || occurrence.SeriesID == nil || *occurrence.SeriesID != series.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ocorrência não encontrada"})
+		return 0, nil, nil, false
+	}
+
+	if occurrence.Status != models.AppointmentStatusPending && occurrence.Status != models.AppointmentStatusConfirmed {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ocorrência não pode mais ser alterada"})
+		return 0, nil, nil, false
+	}
+
+	return userID, series, occurrence, true
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/testutil"
+	"github.com/xclean/backend/internal/testutil/apptest"
+)
+
+func newSeriesHandler(app *apptest.App) *SeriesHandler {
+	return NewSeriesHandler(app.SeriesRepo, app.AppointmentRepo, app.UserRepo, app.AuthService, app.SeriesService,
+		app.CancellationService, app.PricingService, app.AuditService, app.WaitlistService, nil)
+}
+
+func TestCancelledOccurrencesAreOfferedToTheWaitlist(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name string
+		path string
+		body string
+	}{
+		{"pular", "skip", ""},
+		{"cancelar", "cancel", `{"reason": "viagem"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := apptest.New(t)
+			db := app.DB
+			client := testutil.CreateUser(t, db, models.UserTypeClient)
+			waiting := testutil.CreateUser(t, db, models.UserTypeClient)
+			provider := testutil.CreateProvider(t, db, models.ProviderProfile{HourlyRate: 60})
+
+			day := time.Now().UTC().AddDate(0, 0, 10).Truncate(24 * time.Hour)
+			series := &models.AppointmentSeries{
+				UserID: client.ID, ProviderID: provider.ID, RRule: "FREQ=WEEKLY", StartDate: day,
+				Time: "10:00", Service: "Limpeza", Duration: 120,
+			}
+			if err := db.Create(series).Error; err != nil {
+				t.Fatal(err)
+			}
+			occurrence := testutil.CreateAppointment(t, db, models.Appointment{
+				UserID: client.ID, ProviderID: provider.ID, Date: day, Time: "10:00", Duration: 120,
+				SeriesID: &series.ID, OccurrenceDate: &day,
+			})
+			entry := testutil.CreateWaitlistEntry(t, db, models.WaitlistEntry{
+				UserID: waiting.ID, ProviderID: provider.ID, WindowStart: day, WindowEnd: day, Duration: 120,
+			})
+
+			router := gin.New()
+			handler := newSeriesHandler(app)
+			router.POST("/api/series/:id/occurrences/:appointmentId/skip", handler.SkipOccurrence)
+			router.POST("/api/series/:id/occurrences/:appointmentId/cancel", handler.CancelOccurrence)
+
+			body := tt.body
+			if body == "" {
+				body = "{}"
+			}
+			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/series/%d/occurrences/%d/%s", series.ID, occurrence.ID, tt.path), strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", app.Bearer(t, client))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body.String())
+			}
+
+			var offers []models.WaitlistOffer
+			db.Where("entry_id = ?", entry.ID).Find(&offers)
+			if len(offers) != 1 || !offers[0].SlotStart.Equal(occurrence.StartsAt()) {
+				t.Fatalf("ofertas = %+v; want uma oferta do horário %s", offers, occurrence.StartsAt())
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/services"
+)
+
+// Limites do conteúdo das condições de um agendamento
+const (
+	maxTermsItems      = 50
+	maxTermsItemLength = 500
+	maxTermsTextLength = 2000
+)
+
+type TermsHandler struct {
+	appointmentRepo *repositories.AppointmentRepository
+	termsRepo       *repositories.TermsRepository
+	userRepo        *repositories.UserRepository
+	authService     *services.AuthService
+	auditService    *services.AuditService
+}
+
+func NewTermsHandler(
+	appointmentRepo *repositories.AppointmentRepository,
+	termsRepo *repositories.TermsRepository,
+	userRepo *repositories.UserRepository,
+	authService *services.AuthService,
+	auditService *services.AuditService,
+) *TermsHandler {
+	return &TermsHandler{
+		appointmentRepo: appointmentRepo,
+		termsRepo:       termsRepo,
+		userRepo:        userRepo,
+		authService:     authService,
+		auditService:    auditService,
+	}
+}
+
+// GetTerms retorna todas as versões das condições do agendamento e suas ciências
+func (h *TermsHandler) GetTerms(c *gin.Context) {
+	userID, appointment, ok := h.loadAppointment(c)
+	if !ok {
+		return
+	}
+
+	if userID != appointment.UserID && userID != appointment.ProviderID {
+		if !isAdmin(h.userRepo, userID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Acesso negado"})
+			return
+		}
+	}
+
+	versions, err := h.termsRepo.GetVersions(appointment.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar condições"})
+		return
+	}
+
+	acknowledged := len(versions) == 0 || versions[len(versions)-1].Acknowledgement != nil
+	c.JSON(http.StatusOK, gin.H{
+		"versions":     versions,
+		"acknowledged": acknowledged,
+	})
+}
+
+// SetTerms grava uma nova versão das condições definidas pelo cliente.
+// Depois de qualquer alteração, a prestadora precisa dar ciência novamente, e uma reserva
+// instantânea já confirmada volta a aguardar a confirmação dela.
+func (h *TermsHandler) SetTerms(c *gin.Context) {
+	userID, appointment, ok := h.loadAppointment(c)
+	if !ok {
+		return
+	}
+
+	if userID != appointment.UserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Apenas o cliente pode definir as condições"})
+		return
+	}
+
+	if appointment.Status != models.AppointmentStatusPending && appointment.Status != models.AppointmentStatusConfirmed {
+		c.JSON(http.StatusConflict, gin.H{"error": "As condições não podem mais ser alteradas"})
+		return
+	}
+
+	var content models.TermsContent
+	if err := c.ShouldBindJSON(&content); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := normalizeTermsContent(&content); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	terms := &models.AppointmentTerms{
+		AppointmentID: appointment.ID,
+		AuthorID:      userID,
+		Content:       content,
+	}
+	// A reserva instantânea foi confirmada sem ciência das condições e volta para a prestadora
+	reverted := h.auditService.Event(appointment.ID, &userID, models.AppointmentEventStatusChanged, models.AppointmentStatusConfirmed, models.AppointmentStatusPending)
+	if _, err := h.termsRepo.CreateVersion(terms, h.termsEvent(models.AppointmentEventTermsUpdated, userID), reverted); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar condições"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, terms)
+}
+
+// AcknowledgeTerms registra a ciência da prestadora sobre a versão mais recente das condições
+func (h *TermsHandler) AcknowledgeTerms(c *gin.Context) {
+	userID, appointment, ok := h.loadAppointment(c)
+	if !ok {
+		return
+	}
+
+	if userID != appointment.ProviderID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Acesso negado"})
+		return
+	}
+
+	var req struct {
+		Version int `json:"version" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ack, err := h.termsRepo.Acknowledge(appointment.ID, req.Version, userID, h.termsEvent(models.AppointmentEventTermsAcked, userID))
+	if err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrTermsNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, repositories.ErrTermsOutdated), errors.Is(err, repositories.ErrTermsAcknowledged):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao registrar ciência"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, ack)
+}
+
+// termsEvent registra no histórico a versão das condições gravada ou reconhecida
+func (h *TermsHandler) termsEvent(eventType models.AppointmentEventType, userID uint) repositories.TermsRecorder {
+	return func(terms *models.AppointmentTerms) []*models.AppointmentEvent {
+		return []*models.AppointmentEvent{h.auditService.Event(terms.AppointmentID, &userID, eventType, nil, map[string]interface{}{
+			"version":      terms.Version,
+			"content_hash": terms.ContentHash,
+		})}
+	}
+}
+
+// loadAppointment autentica o usuário e carrega o agendamento da rota.
+// Em caso de erro, a resposta já é enviada e ok é falso.
+func (h *TermsHandler) loadAppointment(c *gin.Context) (uint, *models.Appointment, bool) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return 0, nil, false
+	}
+
+	appointmentID, err := parseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return 0, nil, false
+	}
+
+	appointment, err := h.appointmentRepo.FindByID(appointmentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agendamento não encontrado"})
+		return 0, nil, false
+	}
+
+	return userID, appointment, true
+}
+
+// normalizeTermsContent remove itens vazios e valida os limites de tamanho
+func normalizeTermsContent(content *models.TermsContent) error {
+	lists := map[string]*[]string{
+		"included_services": &content.IncludedServices,
+		"accepted_options":  &content.AcceptedOptions,
+		"hard_limits":       &content.HardLimits,
+	}
+	for name, list := range lists {
+		items := make([]string, 0, len(*list))
+		for _, item := range *list {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			if len(item) > maxTermsItemLength {
+				return fmt.Errorf("itens de %s devem ter no máximo %d caracteres", name, maxTermsItemLength)
+			}
+			items = append(items, item)
+		}
+		if len(items) > maxTermsItems {
+			return fmt.Errorf("%s aceita no máximo %d itens", name, maxTermsItems)
+		}
+		*list = items
+	}
+
+	if len(content.IncludedServices) == 0 {
+		return errors.New("informe ao menos um serviço incluído")
+	}
+	house := &content.HouseDetails
+	if house.Bedrooms < 0 || house.Bathrooms < 0 {
+		return errors.New("quantidade de cômodos inválida")
+	}
+	for _, text := range []string{house.PetDetails, house.AccessInstructions, house.Notes} {
+		if len(text) > maxTermsTextLength {
+			return fmt.Errorf("os detalhes do local devem ter no máximo %d caracteres por campo", maxTermsTextLength)
+		}
+	}
+	return nil
+}
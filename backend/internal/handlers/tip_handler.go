@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/services"
+)
+
+type TipHandler struct {
+	appointmentRepo *repositories.AppointmentRepository
+	tipRepo         *repositories.TipRepository
+	authService     *services.AuthService
+	tipService      *services.TipService
+}
+
+func NewTipHandler(
+	appointmentRepo *repositories.AppointmentRepository,
+	tipRepo *repositories.TipRepository,
+	authService *services.AuthService,
+	tipService *services.TipService,
+) *TipHandler {
+	return &TipHandler{
+		appointmentRepo: appointmentRepo,
+		tipRepo:         tipRepo,
+		authService:     authService,
+		tipService:      tipService,
+	}
+}
+
+// SendTip cobra uma gorjeta do cliente no cartão salvo após o atendimento concluído
+func (h *TipHandler) SendTip(c *gin.Context) {
+	userID, appointment, ok := h.loadAppointment(c)
+	if !ok {
+		return
+	}
+
+	if userID != appointment.UserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Apenas o cliente do agendamento pode enviar gorjeta"})
+		return
+	}
+
+	var req struct {
+		Amount          int64  `json:"amount" binding:"required"` // Em centavos
+		PaymentMethodID string `json:"payment_method_id"`         // Cartão salvo; o mais recente se vazio
+		Message         string `json:"message"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tip, err := h.tipService.Send(appointment, req.Amount, req.PaymentMethodID, req.Message)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidTipAmount),
+			errors.Is(err, services.ErrTipMessageTooLong):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrTipNotAllowed),
+			errors.Is(err, services.ErrTipWindowClosed),
+			errors.Is(err, repositories.ErrTipAlreadySent):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrNoSavedPaymentMethod),
+			errors.Is(err, repositories.ErrPaymentMethodNotFound):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrPaymentDeclined):
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error(), "tip": tip})
+		case errors.Is(err, services.ErrTipPending):
+			c.JSON(http.StatusAccepted, gin.H{"message": err.Error(), "tip": tip})
+		default:
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Erro ao cobrar gorjeta"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, tip)
+}
+
+// GetTip retorna a gorjeta do agendamento ao cliente ou à prestadora
+func (h *TipHandler) GetTip(c *gin.Context) {
+	userID, appointment, ok := h.loadAppointment(c)
+	if !ok {
+		return
+	}
+
+	if userID != appointment.UserID && userID != appointment.ProviderID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Acesso negado"})
+		return
+	}
+
+	tip, err := h.tipRepo.FindByAppointment(appointment.ID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrTipNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar gorjeta"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tip)
+}
+
+// loadAppointment autentica o usuário e carrega o agendamento da rota.
+// Em caso de erro, a resposta já é enviada e ok é falso.
+func (h *TipHandler) loadAppointment(c *gin.Context) (uint, *models.Appointment, bool) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return 0, nil, false
+	}
+
+	appointmentID, err := parseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return 0, nil, false
+	}
+
+	appointment, err := h.appointmentRepo.FindByID(appointmentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agendamento não encontrado"})
+		return 0, nil, false
+	}
+
+	return userID, appointment, true
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/services"
+)
+
+type VisitHandler struct {
+	appointmentRepo *repositories.AppointmentRepository
+	userRepo        *repositories.UserRepository
+	authService     *services.AuthService
+	visitService    *services.VisitService
+	auditService    *services.AuditService
+	termsRepo       *repositories.TermsRepository
+	escrow          services.EscrowScheduler
+}
+
+func NewVisitHandler(
+	appointmentRepo *repositories.AppointmentRepository,
+	userRepo *repositories.UserRepository,
+	authService *services.AuthService,
+	visitService *services.VisitService,
+	auditService *services.AuditService,
+	termsRepo *repositories.TermsRepository,
+	escrow services.EscrowScheduler,
+) *VisitHandler {
+	return &VisitHandler{
+		appointmentRepo: appointmentRepo,
+		userRepo:        userRepo,
+		authService:     authService,
+		visitService:    visitService,
+		auditService:    auditService,
+		termsRepo:       termsRepo,
+		escrow:          escrow,
+	}
+}
+
+// VisitLocationRequest traz as coordenadas do dispositivo; ponteiros para aceitar 0 como valor válido
+type VisitLocationRequest struct {
+	Latitude  *float64 `json:"latitude" binding:"required,min=-90,max=90"`
+	Longitude *float64 `json:"longitude" binding:"required,min=-180,max=180"`
+}
+
+// CheckIn registra a chegada da prestadora ao local do agendamento
+func (h *VisitHandler) CheckIn(c *gin.Context) {
+	appointment, req, ok := h.providerVisit(c)
+	if !ok {
+		return
+	}
+
+	// Condições alteradas depois da confirmação precisam de nova ciência antes do atendimento
+	if err := h.termsRepo.EnsureAcknowledged(appointment.ID); err != nil {
+		if errors.Is(err, repositories.ErrTermsNotAcknowledged) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar condições"})
+		return
+	}
+
+	if err := h.visitService.CheckIn(appointment, *req.Latitude, *req.Longitude); err != nil {
+		h.respondVisitError(c, err)
+		return
+	}
+
+	checkedIn := h.auditService.Event(appointment.ID, &appointment.ProviderID, models.AppointmentEventCheckedIn,
+		models.AppointmentStatusConfirmed, map[string]interface{}{
+			"status":        appointment.Status,
+			"checked_in_at": appointment.CheckedInAt,
+			"latitude":      appointment.CheckInLatitude,
+			"longitude":     appointment.CheckInLongitude,
+		})
+	if err := h.appointmentRepo.UpdateVisit(appointment, checkedIn); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao registrar check-in"})
+		return
+	}
+
+	c.JSON(http.StatusOK, appointment)
+}
+
+// CheckOut registra a saída da prestadora e conclui o agendamento
+func (h *VisitHandler) CheckOut(c *gin.Context) {
+	appointment, req, ok := h.providerVisit(c)
+	if !ok {
+		return
+	}
+
+	var hourlyRate float64
+	if profile, err := h.userRepo.GetProviderProfile(appointment.ProviderID); err == nil {
+		hourlyRate = profile.HourlyRate
+	}
+
+	if err := h.visitService.CheckOut(appointment, *req.Latitude, *req.Longitude, hourlyRate); err != nil {
+		h.respondVisitError(c, err)
+		return
+	}
+
+	checkedOut := h.auditService.Event(appointment.ID, &appointment.ProviderID, models.AppointmentEventCheckedOut,
+		models.AppointmentStatusInProgress, map[string]interface{}{
+			"status":           appointment.Status,
+			"checked_out_at":   appointment.CheckedOutAt,
+			"actual_duration":  appointment.ActualDuration,
+			"overtime_minutes": appointment.OvertimeMinutes,
+			"overtime_charge":  appointment.OvertimeCharge,
+		})
+	if err := h.appointmentRepo.UpdateVisit(appointment, checkedOut); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao registrar check-out"})
+		return
+	}
+
+	// O valor retido é repassado à prestadora ao fim da janela de contestação
+	if h.escrow != nil {
+		if err := h.escrow.ScheduleEscrowRelease(appointment); err != nil {
+			log.Printf("Erro ao agendar repasse do agendamento %d: %v", appointment.ID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, appointment)
+}
+
+// providerVisit carrega o agendamento da rota, garante que a prestadora autenticada
+// é a responsável e lê as coordenadas enviadas pelo dispositivo
+func (h *VisitHandler) providerVisit(c *gin.Context) (*models.Appointment, *VisitLocationRequest, bool) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return nil, nil, false
+	}
+
+	appointmentID, err := parseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return nil, nil, false
+	}
+
+	var req VisitLocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, nil, false
+	}
+
+	appointment, err := h.appointmentRepo.FindByID(appointmentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agendamento não encontrado"})
+		return nil, nil, false
+	}
+
+	if appointment.ProviderID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Acesso negado"})
+		return nil, nil, false
+	}
+
+	return appointment, &req, true
+}
+
+// respondVisitError converte os erros de validação do check-in/check-out em respostas HTTP
+func (h *VisitHandler) respondVisitError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrOutsideGeofence), errors.Is(err, services.ErrVisitLocationUnset):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestVisitLocationRequestBinding(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name  string
+		body  string
+		valid bool
+	}{
+		{"coordenadas", `{"latitude": -23.55, "longitude": -46.63}`, true},
+		{"zero é válido", `{"latitude": 0, "longitude": 0}`, true},
+		{"sem latitude", `{"longitude": -46.63}`, false},
+		{"fora da faixa", `{"latitude": 91, "longitude": 0}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			var req VisitLocationRequest
+			err := c.ShouldBindJSON(&req)
+			if (err == nil) != tt.valid {
+				t.Errorf("ShouldBindJSON(%s): err = %v, want valid = %v", tt.body, err, tt.valid)
+			}
+		})
+	}
+}
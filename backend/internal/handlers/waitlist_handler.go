@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/services"
+)
+
+type WaitlistHandler struct {
+	waitlistRepo    *repositories.WaitlistRepository
+	userRepo        *repositories.UserRepository
+	authService     *services.AuthService
+	waitlistService *services.WaitlistService
+}
+
+func NewWaitlistHandler(
+	waitlistRepo *repositories.WaitlistRepository,
+	userRepo *repositories.UserRepository,
+	authService *services.AuthService,
+	waitlistService *services.WaitlistService,
+) *WaitlistHandler {
+	return &WaitlistHandler{
+		waitlistRepo:    waitlistRepo,
+		userRepo:        userRepo,
+		authService:     authService,
+		waitlistService: waitlistService,
+	}
+}
+
+type JoinWaitlistRequest struct {
+	ProviderID  uint      `json:"provider_id" binding:"required"`
+	Service     string    `json:"service" binding:"required"`
+	WindowStart time.Time `json:"window_start" binding:"required"`
+	WindowEnd   time.Time `json:"window_end" binding:"required"`
+	Duration    int       `json:"duration"` // Duração em minutos; usa o padrão se omitida
+	Notes       string    `json:"notes"`
+	Location    string    `json:"location"`
+	Latitude    float64   `json:"latitude"`
+	Longitude   float64   `json:"longitude"`
+}
+
+// JoinWaitlist inscreve o cliente na lista de espera de uma prestadora para um período
+func (h *WaitlistHandler) JoinWaitlist(c *gin.Context) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	var req JoinWaitlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	provider, err := h.userRepo.FindByID(req.ProviderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prestadora não encontrada"})
+		return
+	}
+	if provider.UserType != models.UserTypeProvider {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Usuário não é uma prestadora"})
+		return
+	}
+
+	windowStart := truncateDay(req.WindowStart)
+	windowEnd := truncateDay(req.WindowEnd)
+	if windowEnd.Before(windowStart) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Período inválido"})
+		return
+	}
+	if windowEnd.Before(truncateDay(time.Now())) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "O período já terminou"})
+		return
+	}
+
+	if req.Duration <= 0 {
+		req.Duration = models.DefaultAppointmentDuration
+	}
+
+	entry := &models.WaitlistEntry{
+		UserID:      userID,
+		ProviderID:  req.ProviderID,
+		WindowStart: windowStart,
+		WindowEnd:   windowEnd,
+		Service:     req.Service,
+		Duration:    req.Duration,
+		Notes:       req.Notes,
+		Location:    req.Location,
+		Latitude:    req.Latitude,
+		Longitude:   req.Longitude,
+		Status:      models.WaitlistEntryStatusWaiting,
+	}
+	if err := h.waitlistRepo.CreateEntry(entry); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao entrar na lista de espera"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+// GetWaitlist retorna as inscrições do cliente e as vagas ofertadas a ele
+func (h *WaitlistHandler) GetWaitlist(c *gin.Context) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	entries, err := h.waitlistRepo.GetEntriesByUser(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar lista de espera"})
+		return
+	}
+
+	offers, err := h.waitlistRepo.GetPendingOffersByUser(userID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar ofertas de vaga"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"offers":  offers,
+	})
+}
+
+// LeaveWaitlist retira o cliente da lista de espera
+func (h *WaitlistHandler) LeaveWaitlist(c *gin.Context) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return
+	}
+
+	entryID, err := parseIDParam(c, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	entry, err := h.waitlistRepo.FindEntry(entryID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inscrição não encontrada"})
+		return
+	}
+	if entry.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Acesso negado"})
+		return
+	}
+	if entry.Status != models.WaitlistEntryStatusWaiting && entry.Status != models.WaitlistEntryStatusOffered {
+		c.JSON(http.StatusConflict, gin.H{"error": "Inscrição não está mais ativa"})
+		return
+	}
+
+	if err := h.waitlistRepo.CancelEntry(entry); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao sair da lista de espera"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// AcceptOffer aceita a vaga ofertada, criando o agendamento
+func (h *WaitlistHandler) AcceptOffer(c *gin.Context) {
+	offer, ok := h.pendingOffer(c)
+	if !ok {
+		return
+	}
+
+	appointment, err := h.waitlistService.Accept(offer)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrWaitlistOfferExpired),
+			errors.Is(err, repositories.ErrWaitlistOfferClosed),
+			errors.Is(err, repositories.ErrSlotUnavailable):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case repositories.IsBookingCapError(err), services.IsBookingWindowError(err):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao aceitar vaga"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, appointment)
+}
+
+// DeclineOffer recusa a vaga ofertada, que passa ao próximo cliente da lista
+func (h *WaitlistHandler) DeclineOffer(c *gin.Context) {
+	offer, ok := h.pendingOffer(c)
+	if !ok {
+		return
+	}
+
+	if err := h.waitlistService.Decline(offer); err != nil {
+		if errors.Is(err, repositories.ErrWaitlistOfferClosed) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao recusar vaga"})
+		return
+	}
+
+	c.JSON(http.StatusOK, offer)
+}
+
+// pendingOffer carrega a oferta da rota, garantindo que pertence ao cliente autenticado
+// e ainda está pendente. Em caso de erro, a resposta já é enviada e ok é falso.
+func (h *WaitlistHandler) pendingOffer(c *gin.Context) (*models.WaitlistOffer, bool) {
+	userID, err := userIDFromToken(c, h.authService)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+		return nil, false
+	}
+
+	offerID, err := parseIDParam(c, "offerId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return nil, false
+	}
+
+	offer, err := h.waitlistRepo.FindOffer(offerID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Oferta de vaga não encontrada"})
+		return nil, false
+	}
+	if offer.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Acesso negado"})
+		return nil, false
+	}
+	if offer.Status != models.WaitlistOfferStatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": repositories.ErrWaitlistOfferClosed.Error()})
+		return nil, false
+	}
+
+	return offer, true
+}
+
+// truncateDay retorna o início do dia de t
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
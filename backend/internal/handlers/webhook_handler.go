@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/services"
+)
+
+// maxWebhookSize limita o corpo das notificações dos provedores
+const maxWebhookSize = 1 << 20
+
+type WebhookHandler struct {
+	webhookRepo    *repositories.WebhookRepository
+	webhookService *services.WebhookService
+}
+
+func NewWebhookHandler(
+	webhookRepo *repositories.WebhookRepository,
+	webhookService *services.WebhookService,
+) *WebhookHandler {
+	return &WebhookHandler{
+		webhookRepo:    webhookRepo,
+		webhookService: webhookService,
+	}
+}
+
+// Receive recebe a notificação de um provedor registrado. Os eventos são gravados e
+// processados em segundo plano; reenvios do mesmo evento são aceitos sem efeito.
+func (h *WebhookHandler) Receive(c *gin.Context) {
+	receipt, ok := receiveWebhook(c, h.webhookService, c.Param("provider"))
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, receipt)
+}
+
+// GetEvents lista os eventos recebidos, com filtros por provider, status e type
+func (h *WebhookHandler) GetEvents(c *gin.Context) {
+	filter := repositories.WebhookEventFilter{
+		Provider: c.Query("provider"),
+		Status:   models.WebhookEventStatus(c.Query("status")),
+		Type:     c.Query("type"),
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Limite inválido"})
+			return
+		}
+		filter.Limit = n
+	}
+
+	events, err := h.webhookRepo.GetEvents(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar eventos"})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// RetryEvent devolve à fila um evento com falha ou ignorado, para ser processado de novo
+func (h *WebhookHandler) RetryEvent(c *gin.Context) {
+	eventID, err := parseIDParam(c, "eventId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	event, err := h.webhookRepo.FindByID(eventID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrWebhookEventNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar evento"})
+		return
+	}
+
+	if err := h.webhookService.Retry(event); err != nil {
+		if errors.Is(err, repositories.ErrWebhookEventNotRetry) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao reprocessar evento"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, event)
+}
+
+// receiveWebhook lê o corpo da notificação e a entrega ao provedor.
+// Em caso de erro, a resposta já é enviada e ok é falso.
+func receiveWebhook(c *gin.Context, webhooks *services.WebhookService, provider string) (*services.WebhookReceipt, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookSize)
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Corpo da notificação inválido"})
+		return nil, false
+	}
+
+	receipt, err := webhooks.Ingest(provider, c.Request.Header, body)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownWebhookProvider):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidWebhookSignature):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidWebhookPayload):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			// Falha temporária: o provedor reenvia a notificação
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao registrar notificação"})
+		}
+		return nil, false
+	}
+	return receipt, true
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/middleware"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/services"
+	"github.com/xclean/backend/internal/testutil"
+	"github.com/xclean/backend/internal/testutil/apptest"
+)
+
+// gatewayFixtures são os exemplos de notificação do gateway, os mesmos de cmd/webhook-fixtures
+var gatewayFixtures = filepath.Join("..", "..", "testdata", "webhooks", "gateway")
+
+type webhookTestEnv struct {
+	*apptest.App
+	router   *gin.Engine
+	client   *models.User
+	provider *models.User
+	admin    *models.User
+}
+
+func newWebhookTestEnv(t *testing.T) *webhookTestEnv {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	app := apptest.New(t)
+
+	handler := NewWebhookHandler(app.WebhookRepo, app.WebhookService)
+	router := gin.New()
+	router.POST("/api/webhooks/:provider", handler.Receive)
+	router.POST("/api/admin/webhooks/events/:eventId/retry", middleware.RequireAdmin(app.AuthService, app.UserRepo), handler.RetryEvent)
+
+	return &webhookTestEnv{
+		App:      app,
+		router:   router,
+		client:   testutil.CreateUser(t, app.DB, models.UserTypeClient),
+		provider: testutil.CreateProvider(t, app.DB, models.ProviderProfile{}),
+		admin:    testutil.CreateUser(t, app.DB, models.UserTypeAdmin),
+	}
+}
+
+// createPayment grava um agendamento de R$ 100 e a intenção de pagamento por cartão
+func (e *webhookTestEnv) createPayment(t *testing.T) *models.Payment {
+	t.Helper()
+	appointment := testutil.CreateAppointment(t, e.DB, models.Appointment{
+		UserID: e.client.ID, ProviderID: e.provider.ID, Price: 100,
+		Date: time.Now().UTC().AddDate(0, 0, 3).Truncate(24 * time.Hour),
+	})
+	payment, err := e.PaymentService.CreateIntent(appointment)
+	if err != nil {
+		t.Fatalf("CreateIntent: %v", err)
+	}
+	return payment
+}
+
+// postFixture assina com secret e envia o exemplo do gateway, com os marcadores substituídos
+func (e *webhookTestEnv) postFixture(t *testing.T, file, secret, intent string, amount int64) *httptest.ResponseRecorder {
+	t.Helper()
+	template, err := os.ReadFile(filepath.Join(gatewayFixtures, file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	body := []byte(strings.NewReplacer(
+		"{{intent_id}}", intent,
+		"{{amount}}", strconv.FormatInt(amount, 10),
+		"{{created}}", strconv.FormatInt(now.Unix(), 10),
+	).Replace(string(template)))
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req := httptest.NewRequest(http.MethodPost, "/api/webhooks/"+services.GatewayWebhookProvider, strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(services.GatewayTimestampHeader, timestamp)
+	req.Header.Set(services.GatewaySignatureHeader, services.SignWebhook(secret, timestamp, body))
+	w := httptest.NewRecorder()
+	e.router.ServeHTTP(w, req)
+	return w
+}
+
+// receive envia o exemplo com o segredo correto e confere a contagem da resposta
+func (e *webhookTestEnv) receive(t *testing.T, file, intent string, amount int64, wantReceived, wantDuplicates int) {
+	t.Helper()
+	w := e.postFixture(t, file, apptest.WebhookSecret, intent, amount)
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s: status %d: %s", file, w.Code, w.Body.String())
+	}
+	var receipt services.WebhookReceipt
+	if err := json.Unmarshal(w.Body.Bytes(), &receipt); err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Received != wantReceived || receipt.Duplicates != wantDuplicates {
+		t.Fatalf("%s: %d recebidos e %d duplicados, want %d e %d", file, receipt.Received, receipt.Duplicates, wantReceived, wantDuplicates)
+	}
+}
+
+// waitEvent aguarda o processamento em segundo plano do evento até done ser verdadeiro
+func (e *webhookTestEnv) waitEvent(t *testing.T, eventID string, done func(*models.WebhookEvent) bool) *models.WebhookEvent {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var event models.WebhookEvent
+		if err := e.DB.Where("event_id = ?", eventID).First(&event).Error; err != nil {
+			t.Fatalf("evento %s: %v", eventID, err)
+		}
+		if done(&event) {
+			return &event
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("evento %s não processado: %s, %q", eventID, event.Status, event.LastError)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func finished(event *models.WebhookEvent) bool {
+	return event.Status != models.WebhookEventPending
+}
+
+func TestGatewayWebhookFixtures(t *testing.T) {
+	env := newWebhookTestEnv(t)
+	payment := env.createPayment(t)
+	intent := payment.GatewayIntentID
+
+	// Assinatura com outro segredo é recusada sem gravar o evento
+	if w := env.postFixture(t, "payment_succeeded.json", "outro-segredo", intent, payment.Amount); w.Code != http.StatusUnauthorized {
+		t.Fatalf("assinatura inválida: status %d, want 401", w.Code)
+	}
+	var stored int64
+	env.DB.Model(&models.WebhookEvent{}).Count(&stored)
+	if stored != 0 {
+		t.Fatalf("%d eventos gravados com assinatura inválida", stored)
+	}
+
+	env.receive(t, "payment_succeeded.json", intent, payment.Amount, 1, 0)
+	if event := env.waitEvent(t, "evt_succeeded_"+intent, finished); event.Status != models.WebhookEventProcessed {
+		t.Fatalf("pagamento: evento %s, %q", event.Status, event.LastError)
+	}
+	env.receive(t, "payment_succeeded.json", intent, payment.Amount, 0, 1)
+
+	env.receive(t, "charge_refunded.json", intent, 3000, 1, 0)
+	if event := env.waitEvent(t, "evt_refunded_"+intent, finished); event.Status != models.WebhookEventProcessed {
+		t.Fatalf("reembolso: evento %s, %q", event.Status, event.LastError)
+	}
+
+	env.receive(t, "chargeback.json", intent, 7000, 1, 0)
+	if event := env.waitEvent(t, "evt_dispute_"+intent, finished); event.Status != models.WebhookEventProcessed {
+		t.Fatalf("contestação: evento %s, %q", event.Status, event.LastError)
+	}
+
+	var got models.Payment
+	env.DB.First(&got, payment.ID)
+	if got.Status != models.PaymentStatusPartiallyRefunded || got.RefundedAmount != 3000 ||
+		got.ChargebackAmount != 7000 || got.EscrowStatus != models.EscrowStatusFrozen {
+		t.Errorf("pagamento %s, %d reembolsado, %d contestado, custódia %s; want partially_refunded, 3000, 7000, frozen",
+			got.Status, got.RefundedAmount, got.ChargebackAmount, got.EscrowStatus)
+	}
+
+	// A contestação sai da compensação, e o gateway e o razão fecham em zero
+	report, err := env.LedgerService.Reconcile(time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Payments != 1 || len(report.Discrepancies) != 0 || report.GatewayTotal != 0 || report.LedgerTotal != 0 {
+		t.Errorf("conciliação: %d pagamentos, gateway %d, razão %d, divergências %+v; want 1, 0, 0, nenhuma",
+			report.Payments, report.GatewayTotal, report.LedgerTotal, report.Discrepancies)
+	}
+}
+
+func TestGatewayWebhookAdminRetry(t *testing.T) {
+	env := newWebhookTestEnv(t)
+	const intent = "pi_atrasado"
+
+	// A notificação chega antes de a intenção ser gravada e o evento é ignorado
+	env.receive(t, "payment_succeeded.json", intent, 10000, 1, 0)
+	event := env.waitEvent(t, "evt_succeeded_"+intent, finished)
+	if event.Status != models.WebhookEventIgnored {
+		t.Fatalf("evento %s, want ignored", event.Status)
+	}
+
+	payment := env.createPayment(t)
+	env.DB.Model(payment).Update("gateway_intent_id", intent)
+
+	tests := []struct {
+		name     string
+		actor    *models.User
+		wantCode int
+	}{
+		{"cliente", env.client, http.StatusForbidden},
+		{"administrador", env.admin, http.StatusAccepted},
+		{"evento já processado", env.admin, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/admin/webhooks/events/%d/retry", event.ID), nil)
+			req.Header.Set("Authorization", env.Bearer(t, tt.actor))
+			w := httptest.NewRecorder()
+			env.router.ServeHTTP(w, req)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status %d: %s; want %d", w.Code, w.Body.String(), tt.wantCode)
+			}
+			if tt.wantCode != http.StatusAccepted {
+				return
+			}
+
+			retried := env.waitEvent(t, event.EventID, finished)
+			var got models.Payment
+			env.DB.First(&got, payment.ID)
+			if retried.Status != models.WebhookEventProcessed || got.Status != models.PaymentStatusSucceeded {
+				t.Errorf("evento %s, pagamento %s; want processed, succeeded", retried.Status, got.Status)
+			}
+		})
+	}
+}
+
+func TestWebhookPanicIsRetried(t *testing.T) {
+	env := newWebhookTestEnv(t)
+	env.WebhookService.Register("instavel", &apptest.WebhookSource{
+		Handle: func(*models.WebhookEvent) error { panic("falha inesperada") },
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/webhooks/instavel", strings.NewReader("{}"))
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+
+	// O pânico vira uma falha temporária e o evento volta à fila
+	event := env.waitEvent(t, "evt_1", func(event *models.WebhookEvent) bool { return event.LastError != "" })
+	if event.Status != models.WebhookEventPending || event.Attempts != 1 || !strings.Contains(event.LastError, "falha inesperada") {
+		t.Errorf("evento %s, %d tentativas, %q; want pending, 1, pânico registrado", event.Status, event.Attempts, event.LastError)
+	}
+}
@@ -0,0 +1,53 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Job é uma tarefa executada periodicamente em segundo plano
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func() error
+}
+
+// Scheduler executa tarefas periódicas até o contexto ser cancelado
+type Scheduler struct {
+	jobs []Job
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{}
+}
+
+// Add registra uma tarefa no agendador
+func (s *Scheduler) Add(name string, interval time.Duration, run func() error) {
+	s.jobs = append(s.jobs, Job{Name: name, Interval: interval, Run: run})
+}
+
+// Start inicia cada tarefa em sua própria goroutine
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, job := range s.jobs {
+		go s.loop(ctx, job)
+	}
+}
+
+// loop executa a tarefa imediatamente e depois a cada intervalo
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job.Run(); err != nil {
+			log.Printf("Erro ao executar tarefa %s: %v", job.Name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
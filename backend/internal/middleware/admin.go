@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/services"
+)
+
+// RequireAdmin libera a rota apenas para admins. Sem token válido a resposta é 401; outros
+// usuários recebem 403.
+func RequireAdmin(authService *services.AuthService, userRepo *repositories.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := authService.UserIDFromHeader(c.GetHeader("Authorization"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Não autorizado"})
+			return
+		}
+
+		user, err := userRepo.FindByID(userID)
+		if err != nil || user.UserType != models.UserTypeAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Acesso negado"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/testutil"
+	"github.com/xclean/backend/internal/testutil/apptest"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	app := apptest.New(t)
+	db := app.DB
+	users := map[string]*models.User{
+		"client":   testutil.CreateUser(t, db, models.UserTypeClient),
+		"provider": testutil.CreateProvider(t, db, models.ProviderProfile{}),
+		"admin":    testutil.CreateUser(t, db, models.UserTypeAdmin),
+	}
+
+	calls := 0
+	router := gin.New()
+	router.GET("/admin", RequireAdmin(app.AuthService, app.UserRepo), func(c *gin.Context) {
+		calls++
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name     string
+		actor    string
+		token    string
+		wantCode int
+	}{
+		{"sem token", "", "", http.StatusUnauthorized},
+		{"token inválido", "", "invalido", http.StatusUnauthorized},
+		{"cliente", "client", "", http.StatusForbidden},
+		{"prestadora", "provider", "", http.StatusForbidden},
+		{"administrador", "admin", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			token := tt.token
+			if tt.actor != "" {
+				var err error
+				if token, err = app.AuthService.GenerateToken(users[tt.actor]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			wantCalls := 0
+			if tt.wantCode == http.StatusOK {
+				wantCalls = 1
+			}
+			if w.Code != tt.wantCode || calls != wantCalls {
+				t.Errorf("status %d com %d chamadas ao handler, want %d e %d", w.Code, calls, tt.wantCode, wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/services"
+)
+
+const (
+	// IdempotencyHeader é o header enviado pelo app para identificar tentativas da mesma operação
+	IdempotencyHeader = "Idempotency-Key"
+	// IdempotencyKeyTTL é por quanto tempo a resposta fica disponível para reenvio
+	IdempotencyKeyTTL = 24 * time.Hour
+	// maxIdempotencyKeyLength limita o tamanho da chave aceita
+	maxIdempotencyKeyLength = 255
+)
+
+// responseRecorder copia o corpo da resposta enquanto ele é escrito para o cliente
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency torna idempotentes as requisições POST e PATCH autenticadas que
+// enviam o header Idempotency-Key. A primeira resposta é armazenada por usuário e
+// chave e reenviada nas tentativas idênticas; reutilizar a chave com outro corpo
+// retorna 422. Respostas de erro do servidor não são armazenadas.
+func Idempotency(repo *repositories.IdempotencyRepository, authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyHeader)
+		method := c.Request.Method
+		if key == "" || (method != http.MethodPost && method != http.MethodPatch) {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key muito longa"})
+			return
+		}
+
+		// Sem usuário autenticado não há escopo para a chave; o handler responde 401
+		userID, err := authService.UserIDFromHeader(c.GetHeader("Authorization"))
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Erro ao ler requisição"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		record := &models.IdempotencyKey{
+			UserID:      userID,
+			Key:         key,
+			Method:      method,
+			Path:        c.Request.URL.Path,
+			RequestHash: hashRequest(method, c.Request.URL.Path, body),
+			ExpiresAt:   time.Now().Add(IdempotencyKeyTTL),
+		}
+
+		reserved, existing, err := repo.Reserve(record)
+		if err == nil && !reserved && existing.ExpiresAt.Before(time.Now()) {
+			// Chave expirada ainda não removida pela limpeza: libera e reserva de novo
+			if err = repo.Release(existing.ID); err == nil {
+				reserved, existing, err = repo.Reserve(record)
+			}
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar Idempotency-Key"})
+			return
+		}
+
+		if !reserved {
+			switch {
+			case existing.RequestHash != record.RequestHash:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key já utilizada com outra requisição"})
+			case existing.StatusCode == 0:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "Requisição com esta Idempotency-Key ainda em processamento"})
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(existing.StatusCode, existing.ContentType, existing.ResponseBody)
+				c.Abort()
+			}
+			return
+		}
+
+		// Um panic no handler não pode deixar a chave presa como em processamento; ela é
+		// liberada e o panic segue para o middleware de recuperação do gin
+		defer func() {
+			if p := recover(); p != nil {
+				if err := repo.Release(record.ID); err != nil {
+					log.Printf("Erro ao liberar Idempotency-Key %d: %v", record.ID, err)
+				}
+				panic(p)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			if err := repo.Release(record.ID); err != nil {
+				log.Printf("Erro ao liberar Idempotency-Key %d: %v", record.ID, err)
+			}
+			return
+		}
+
+		record.StatusCode = status
+		record.ContentType = recorder.Header().Get("Content-Type")
+		record.ResponseBody = recorder.body.Bytes()
+		if err := repo.Complete(record); err != nil {
+			log.Printf("Erro ao armazenar resposta da Idempotency-Key %d: %v", record.ID, err)
+		}
+	}
+}
+
+// hashRequest identifica a requisição pelo método, caminho e corpo
+func hashRequest(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
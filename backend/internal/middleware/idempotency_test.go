@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/testutil"
+	"github.com/xclean/backend/internal/testutil/apptest"
+)
+
+func TestIdempotency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	app := apptest.New(t)
+	db := app.DB
+	user := testutil.CreateUser(t, db, models.UserTypeClient)
+	token, err := app.AuthService.GenerateToken(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	panics := true
+	router := gin.New()
+	router.Use(gin.Recovery(), Idempotency(app.IdempotencyRepo, app.AuthService))
+	router.POST("/orders", func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusCreated, gin.H{"order": calls})
+	})
+	router.POST("/flaky", func(c *gin.Context) {
+		calls++
+		if panics {
+			panic("falha inesperada")
+		}
+		c.JSON(http.StatusCreated, gin.H{"order": calls})
+	})
+
+	send := func(path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set(IdempotencyHeader, key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := send("/orders", "k1", `{"a":1}`)
+	replay := send("/orders", "k1", `{"a":1}`)
+	if first.Code != http.StatusCreated || replay.Code != http.StatusCreated || replay.Body.String() != first.Body.String() {
+		t.Fatalf("reenvio: %d %s, want %d %s", replay.Code, replay.Body, first.Code, first.Body)
+	}
+	if replay.Header().Get("Idempotent-Replayed") != "true" || calls != 1 {
+		t.Errorf("reenvio executou o handler de novo: %d chamadas", calls)
+	}
+
+	if w := send("/orders", "k1", `{"a":2}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("chave com outro corpo: status %d, want 422", w.Code)
+	}
+	if w := send("/orders", "k2", `{"a":1}`); w.Code != http.StatusCreated || calls != 2 {
+		t.Errorf("outra chave: status %d, %d chamadas; want 201 e uma nova execução", w.Code, calls)
+	}
+
+	// Depois de um panic a chave é liberada e a nova tentativa é processada
+	if w := send("/flaky", "k3", `{}`); w.Code != http.StatusInternalServerError {
+		t.Fatalf("panic: status %d, want 500", w.Code)
+	}
+	panics = false
+	if w := send("/flaky", "k3", `{}`); w.Code != http.StatusCreated {
+		t.Errorf("nova tentativa após panic: status %d %s, want 201", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateWindow conta as requisições de um IP na janela atual
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// rateLimiter conta as requisições por IP em janelas fixas, em memória
+type rateLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	windows   map[string]*rateWindow
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// RateLimit limita cada IP a limit requisições por janela. Os contadores ficam em memória,
+// em cada instância da API; acima do limite, responde 429 com o header Retry-After.
+func RateLimit(limit int, window time.Duration) gin.HandlerFunc {
+	return newRateLimiter(limit, window, time.Now).handle
+}
+
+func newRateLimiter(limit int, window time.Duration, now func() time.Time) *rateLimiter {
+	return &rateLimiter{
+		limit:   limit,
+		window:  window,
+		windows: map[string]*rateWindow{},
+		now:     now,
+	}
+}
+
+func (l *rateLimiter) handle(c *gin.Context) {
+	retryAfter, allowed := l.allow(c.ClientIP())
+	if !allowed {
+		c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds()+0.999)))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Muitas requisições, tente novamente em instantes"})
+		return
+	}
+	c.Next()
+}
+
+// allow registra uma requisição do IP e informa se ela cabe no limite ou, se não couber,
+// quanto falta para a próxima janela
+func (l *rateLimiter) allow(ip string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	// Descarta as janelas encerradas, para que IPs inativos não fiquem em memória
+	if now.Sub(l.lastSweep) >= l.window {
+		for key, w := range l.windows {
+			if now.Sub(w.start) >= l.window {
+				delete(l.windows, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	w, ok := l.windows[ip]
+	if !ok || now.Sub(w.start) >= l.window {
+		w = &rateWindow{start: now}
+		l.windows[ip] = w
+	}
+	if w.count >= l.limit {
+		return w.start.Add(l.window).Sub(now), false
+	}
+	w.count++
+	return 0, true
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	clock := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	limiter := newRateLimiter(2, time.Minute, func() time.Time { return clock })
+
+	router := gin.New()
+	router.GET("/verify", limiter.handle, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	request := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/verify", nil)
+		req.RemoteAddr = ip + ":40000"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name       string
+		advance    time.Duration
+		ip         string
+		wantStatus int
+		wantRetry  string
+	}{
+		{"primeira requisição", 0, "203.0.113.1", http.StatusOK, ""},
+		{"segunda requisição", 10 * time.Second, "203.0.113.1", http.StatusOK, ""},
+		{"acima do limite", 10 * time.Second, "203.0.113.1", http.StatusTooManyRequests, "40"},
+		{"outro IP tem seu próprio limite", 0, "203.0.113.2", http.StatusOK, ""},
+		{"nova janela", 40 * time.Second, "203.0.113.1", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		clock = clock.Add(tt.advance)
+		w := request(tt.ip)
+		if w.Code != tt.wantStatus || w.Header().Get("Retry-After") != tt.wantRetry {
+			t.Errorf("%s: status %d, Retry-After %q; want %d, %q", tt.name, w.Code, w.Header().Get("Retry-After"), tt.wantStatus, tt.wantRetry)
+		}
+	}
+}
@@ -13,8 +13,12 @@ const (
 	AppointmentStatusInProgress AppointmentStatus = "in_progress"
 	AppointmentStatusCompleted  AppointmentStatus = "completed"
 	AppointmentStatusCancelled  AppointmentStatus = "cancelled"
+	AppointmentStatusNoShow     AppointmentStatus = "no_show"
 )
 
+// DefaultAppointmentDuration é a duração em minutos usada quando o agendamento não informa uma
+const DefaultAppointmentDuration = 120
+
 // Appointment representa um agendamento no sistema
 type Appointment struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
@@ -38,4 +42,59 @@ type Appointment struct {
 	Location  string            `json:"location"`
 	Latitude  float64           `json:"latitude"`
 	Longitude float64           `json:"longitude"`
+
+	// Descontos aplicados na reserva, em centavos, custeados pela plataforma (cupom) e pela
+	// carteira de créditos do cliente; são abatidos do valor cobrado
+	PromoDiscount int64 `json:"promo_discount" gorm:"not null;default:0"`
+	CreditApplied int64 `json:"credit_applied" gorm:"not null;default:0"`
+
+	// InstantBooked indica que o agendamento foi confirmado automaticamente pela reserva instantânea
+	InstantBooked bool `json:"instant_booked" gorm:"not null;default:false"`
+
+	// Avaliação do cliente feita pela prestadora após o atendimento (1 a 5)
+	ClientRating *int `json:"client_rating,omitempty"`
+
+	// Recorrência: ocorrência de uma série e a data original prevista pela regra
+	SeriesID       *uint      `json:"series_id,omitempty" gorm:"uniqueIndex:idx_series_occurrence"`
+	OccurrenceDate *time.Time `json:"occurrence_date,omitempty" gorm:"uniqueIndex:idx_series_occurrence"`
+
+	// Check-in e check-out da prestadora no local do atendimento
+	CheckedInAt       *time.Time `json:"checked_in_at,omitempty"`
+	CheckInLatitude   float64    `json:"check_in_latitude,omitempty"`
+	CheckInLongitude  float64    `json:"check_in_longitude,omitempty"`
+	CheckedOutAt      *time.Time `json:"checked_out_at,omitempty"`
+	CheckOutLatitude  float64    `json:"check_out_latitude,omitempty"`
+	CheckOutLongitude float64    `json:"check_out_longitude,omitempty"`
+	ActualDuration    int        `json:"actual_duration"` // Duração real em minutos
+	OvertimeMinutes   int        `json:"overtime_minutes"`
+	OvertimeCharge    float64    `json:"overtime_charge"`
+
+	// Conclusão pelo check-out ou por um admin; não muda em alterações posteriores
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+
+	// Cancelamento
+	CancelledAt        *time.Time `json:"cancelled_at,omitempty"`
+	CancelledBy        *uint      `json:"cancelled_by,omitempty"`
+	CancellationReason string     `json:"cancellation_reason,omitempty"`
+	CancellationFee    float64    `json:"cancellation_fee"`
+	// Reembolso do cancelamento ainda não processado, refeito pela tarefa periódica
+	CancellationRefundPending bool `json:"cancellation_refund_pending" gorm:"not null;default:false;index"`
+}
+
+// StartsAt retorna o horário de início do agendamento combinando Date e Time no fuso da agenda
+func (a *Appointment) StartsAt() time.Time {
+	t, err := time.Parse("15:04", a.Time)
+	if err != nil {
+		return a.Date
+	}
+	return ScheduleTime(a.Date, t.Hour(), t.Minute())
+}
+
+// EndsAt retorna o horário previsto de término do agendamento
+func (a *Appointment) EndsAt() time.Time {
+	duration := a.Duration
+	if duration <= 0 {
+		duration = DefaultAppointmentDuration
+	}
+	return a.StartsAt().Add(time.Duration(duration) * time.Minute)
 }
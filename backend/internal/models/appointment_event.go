@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+)
+
+// AppointmentEventType define os tipos de evento do histórico de um agendamento
+type AppointmentEventType string
+
+const (
+	AppointmentEventCreated        AppointmentEventType = "created"
+	AppointmentEventStatusChanged  AppointmentEventType = "status_changed"
+	AppointmentEventCancelled      AppointmentEventType = "cancelled"
+	AppointmentEventRescheduled    AppointmentEventType = "rescheduled"
+	AppointmentEventPriceChanged   AppointmentEventType = "price_changed"
+	AppointmentEventCheckedIn      AppointmentEventType = "checked_in"
+	AppointmentEventCheckedOut     AppointmentEventType = "checked_out"
+	AppointmentEventNoShowClaimed  AppointmentEventType = "no_show_claimed"
+	AppointmentEventNoShowDisputed AppointmentEventType = "no_show_disputed"
+	AppointmentEventNoShowResolved AppointmentEventType = "no_show_resolved"
+	AppointmentEventTermsUpdated   AppointmentEventType = "terms_updated"
+	AppointmentEventTermsAcked     AppointmentEventType = "terms_acknowledged"
+	AppointmentEventPaymentPaid    AppointmentEventType = "payment_succeeded"
+	AppointmentEventRefunded       AppointmentEventType = "payment_refunded"
+	AppointmentEventRefundAsked    AppointmentEventType = "refund_requested"
+	AppointmentEventRefundReviewed AppointmentEventType = "refund_request_reviewed"
+	AppointmentEventEscrowFrozen   AppointmentEventType = "escrow_frozen"
+	AppointmentEventEscrowUnfrozen AppointmentEventType = "escrow_unfrozen"
+	AppointmentEventEscrowReleased AppointmentEventType = "escrow_released"
+	AppointmentEventDisputeOpened  AppointmentEventType = "dispute_opened"
+	AppointmentEventDisputeClosed  AppointmentEventType = "dispute_resolved"
+	AppointmentEventTipPaid        AppointmentEventType = "tip_paid"
+	AppointmentEventChargeback     AppointmentEventType = "chargeback_opened"
+)
+
+// AppointmentEvent é uma entrada imutável do histórico de alterações de um agendamento
+type AppointmentEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+
+	AppointmentID uint                 `json:"appointment_id" gorm:"not null;index"`
+	ActorID       *uint                `json:"actor_id,omitempty"` // Nulo quando a alteração é feita pelo sistema
+	Type          AppointmentEventType `json:"type" gorm:"not null"`
+
+	// Valores anteriores e novos, em JSON
+	OldValue string `json:"old_value,omitempty" gorm:"type:text"`
+	NewValue string `json:"new_value,omitempty" gorm:"type:text"`
+}
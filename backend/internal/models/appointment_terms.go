@@ -0,0 +1,61 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// HouseDetails descreve o local do atendimento
+type HouseDetails struct {
+	HasPets            bool   `json:"has_pets"`
+	PetDetails         string `json:"pet_details,omitempty"`
+	Bedrooms           int    `json:"bedrooms,omitempty"`
+	Bathrooms          int    `json:"bathrooms,omitempty"`
+	AccessInstructions string `json:"access_instructions,omitempty"`
+	Notes              string `json:"notes,omitempty"`
+}
+
+// TermsContent é o conteúdo estruturado das condições combinadas para o atendimento
+type TermsContent struct {
+	IncludedServices []string     `json:"included_services"` // Serviços incluídos no atendimento
+	AcceptedOptions  []string     `json:"accepted_options"`  // Opções que a prestadora aceita explicitamente ao dar ciência
+	HardLimits       []string     `json:"hard_limits"`       // Limites que não podem ser ultrapassados
+	HouseDetails     HouseDetails `json:"house_details"`
+}
+
+// Hash retorna o SHA-256 do conteúdo serializado, usado para vincular a ciência ao texto exato
+func (c TermsContent) Hash() string {
+	data, _ := json.Marshal(c)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// AppointmentTerms é uma versão das condições de um agendamento, definida pelo cliente.
+// Versões nunca são alteradas: cada mudança cria uma nova versão, preservando o histórico
+// para disputas.
+type AppointmentTerms struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	AppointmentID uint `json:"appointment_id" gorm:"not null;uniqueIndex:idx_terms_version"`
+	Version       int  `json:"version" gorm:"not null;uniqueIndex:idx_terms_version"`
+	AuthorID      uint `json:"author_id" gorm:"not null"`
+
+	Content     TermsContent `json:"content" gorm:"type:text;not null;serializer:json"`
+	ContentHash string       `json:"content_hash" gorm:"not null"`
+
+	Acknowledgement *TermsAcknowledgement `json:"acknowledgement,omitempty" gorm:"foreignKey:TermsID"`
+}
+
+// TermsAcknowledgement registra a ciência da prestadora sobre uma versão das condições.
+// Também é imutável e guarda o hash do conteúdo reconhecido.
+type TermsAcknowledgement struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	TermsID     uint   `json:"terms_id" gorm:"not null;unique"`
+	ProviderID  uint   `json:"provider_id" gorm:"not null"`
+	ContentHash string `json:"content_hash" gorm:"not null"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// CalendarFeed guarda o token secreto da assinatura iCalendar de um usuário.
+// Quem conhece o token consegue ler a agenda, por isso ele pode ser regenerado.
+type CalendarFeed struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID uint   `json:"user_id" gorm:"unique;not null"`
+	Token  string `json:"-" gorm:"uniqueIndex;not null"`
+
+	// IncludeDetails inclui nomes, endereço e observações nos eventos do feed
+	IncludeDetails bool `json:"include_details" gorm:"default:false"`
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// ProviderTier define a categoria comercial da prestadora, usada nas regras de comissão
+type ProviderTier string
+
+const (
+	ProviderTierStandard ProviderTier = "standard"
+	ProviderTierPro      ProviderTier = "pro"
+	ProviderTierElite    ProviderTier = "elite"
+)
+
+// IsValid informa se a categoria é conhecida
+func (t ProviderTier) IsValid() bool {
+	switch t {
+	case ProviderTierStandard, ProviderTierPro, ProviderTierElite:
+		return true
+	}
+	return false
+}
+
+// CommissionRule define quanto a plataforma retém dos atendimentos: um percentual, em
+// pontos-base (1/100 de 1%), mais um valor fixo. Regras com cidade, categoria ou período
+// restringem a quem e quando se aplicam; entre as que se aplicam, vence a mais específica.
+type CommissionRule struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name    string `json:"name" gorm:"not null"`
+	Version int    `json:"version" gorm:"not null;default:1"` // Incrementada a cada alteração
+	Active  bool   `json:"active" gorm:"not null;index"`
+
+	PercentBps  int   `json:"percent_bps" gorm:"not null;default:0"`  // 1500 = 15%
+	FixedAmount int64 `json:"fixed_amount" gorm:"not null;default:0"` // Em centavos, por atendimento
+
+	// Restrições; vazias valem para todos
+	City         string       `json:"city,omitempty" gorm:"index"`
+	ProviderTier ProviderTier `json:"provider_tier,omitempty"`
+	StartsAt     *time.Time   `json:"starts_at,omitempty"` // Período promocional
+	EndsAt       *time.Time   `json:"ends_at,omitempty"`
+}
+
+// Applies informa se a regra vale para a cidade e a categoria da prestadora no instante at
+func (r *CommissionRule) Applies(city string, tier ProviderTier, at time.Time) bool {
+	if !r.Active {
+		return false
+	}
+	if r.City != "" && !strings.EqualFold(r.City, strings.TrimSpace(city)) {
+		return false
+	}
+	if r.ProviderTier != "" && r.ProviderTier != tier {
+		return false
+	}
+	if r.StartsAt != nil && at.Before(*r.StartsAt) {
+		return false
+	}
+	if r.EndsAt != nil && !at.Before(*r.EndsAt) {
+		return false
+	}
+	return true
+}
+
+// Specificity ordena as regras aplicáveis: períodos promocionais vencem cidade, que vence
+// categoria, que vence a regra geral
+func (r *CommissionRule) Specificity() int {
+	specificity := 0
+	if r.StartsAt != nil || r.EndsAt != nil {
+		specificity += 4
+	}
+	if r.City != "" {
+		specificity += 2
+	}
+	if r.ProviderTier != "" {
+		specificity++
+	}
+	return specificity
+}
+
+// Fee calcula a comissão sobre amount centavos, arredondada para baixo e limitada ao valor
+func (r *CommissionRule) Fee(amount int64) int64 {
+	if amount <= 0 {
+		return 0
+	}
+	fee := amount*int64(r.PercentBps)/10000 + r.FixedAmount
+	return min(max(fee, 0), amount)
+}
+
+// PaymentSettlement registra como o valor repassado de um pagamento foi dividido entre a
+// prestadora e a plataforma, com uma cópia da regra de comissão usada
+type PaymentSettlement struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	PaymentID     uint `json:"payment_id" gorm:"not null;uniqueIndex"`
+	AppointmentID uint `json:"appointment_id" gorm:"not null;index"`
+	ProviderID    uint `json:"provider_id" gorm:"not null;index"`
+
+	// Valores em centavos: o bruto é o pago menos os reembolsos
+	GrossAmount    int64 `json:"gross_amount" gorm:"not null"`
+	PlatformFee    int64 `json:"platform_fee" gorm:"not null"`
+	ProviderAmount int64 `json:"provider_amount" gorm:"not null"`
+
+	// Regra vigente no repasse; nula quando nenhuma regra se aplicava
+	CommissionRuleID      *uint        `json:"commission_rule_id,omitempty"`
+	CommissionRuleVersion int          `json:"commission_rule_version"`
+	CommissionRuleName    string       `json:"commission_rule_name,omitempty"`
+	CommissionPercentBps  int          `json:"commission_percent_bps"`
+	CommissionFixedAmount int64        `json:"commission_fixed_amount"`
+	City                  string       `json:"city,omitempty"`
+	ProviderTier          ProviderTier `json:"provider_tier,omitempty"`
+}
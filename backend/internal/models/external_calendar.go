@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+// ExternalCalendar é um calendário externo da prestadora cujos compromissos bloqueiam a agenda.
+// Calendários com URL são sincronizados periodicamente; os enviados como arquivo não têm URL.
+type ExternalCalendar struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ProviderID uint   `json:"provider_id" gorm:"not null;index"`
+	Name       string `json:"name" gorm:"not null"`
+	URL        string `json:"url,omitempty"`
+
+	// Resultado da última sincronização
+	LastSyncedAt *time.Time `json:"last_synced_at,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+}
+
+// ExternalBusyBlock é um intervalo ocupado importado de um calendário externo.
+// Apenas o horário é guardado, sem título ou descrição do compromisso.
+type ExternalBusyBlock struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	CalendarID uint      `json:"calendar_id" gorm:"not null;index"`
+	ProviderID uint      `json:"provider_id" gorm:"not null;index:idx_external_busy_provider_start"`
+	StartAt    time.Time `json:"start_at" gorm:"not null;index:idx_external_busy_provider_start"`
+	EndAt      time.Time `json:"end_at" gorm:"not null"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// IdempotencyKey guarda a primeira resposta de uma requisição enviada com o header
+// Idempotency-Key, para que novas tentativas recebam a mesma resposta
+type IdempotencyKey struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID      uint   `json:"user_id" gorm:"not null;uniqueIndex:idx_idempotency_user_key"`
+	Key         string `json:"key" gorm:"not null;uniqueIndex:idx_idempotency_user_key"`
+	Method      string `json:"method" gorm:"not null"`
+	Path        string `json:"path" gorm:"not null"`
+	RequestHash string `json:"request_hash" gorm:"not null"` // SHA-256 do método, caminho e corpo
+
+	// Resposta armazenada; StatusCode zero indica requisição ainda em processamento
+	StatusCode   int    `json:"status_code"`
+	ContentType  string `json:"content_type"`
+	ResponseBody []byte `json:"-"`
+
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null;index"`
+}
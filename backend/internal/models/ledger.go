@@ -0,0 +1,95 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// LedgerAccountType define os tipos de conta do razão
+type LedgerAccountType string
+
+const (
+	LedgerAccountClient          LedgerAccountType = "client"           // Valores pagos e devolvidos a um cliente
+	LedgerAccountProvider        LedgerAccountType = "provider"         // Valores devidos a uma prestadora
+	LedgerAccountPlatformRevenue LedgerAccountType = "platform_revenue" // Receita da plataforma
+	LedgerAccountEscrow          LedgerAccountType = "escrow"           // Valores retidos até o fim dos atendimentos
+	LedgerAccountGatewayClearing LedgerAccountType = "gateway_clearing" // Valores em trânsito no gateway ou no PSP
+	LedgerAccountPromotions      LedgerAccountType = "promotions"       // Cupons e créditos de indicação custeados pela plataforma
+	LedgerAccountChargebacks     LedgerAccountType = "chargebacks"      // Valores contestados no emissor, retirados pelo gateway até a decisão
+)
+
+// LedgerAccount é uma conta do razão. Clientes e prestadoras têm uma conta cada; as contas
+// da plataforma são únicas, exceto a de compensação, que existe por método de pagamento.
+type LedgerAccount struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Code     string            `json:"code" gorm:"not null;uniqueIndex"` // Ex.: "client:12", "gateway_clearing:pix"
+	Type     LedgerAccountType `json:"type" gorm:"not null;index"`
+	OwnerID  *uint             `json:"owner_id,omitempty" gorm:"index"` // Cliente ou prestadora dona da conta
+	Currency string            `json:"currency" gorm:"not null"`
+}
+
+// LedgerAccountCode monta o código único de uma conta. ownerID é nulo nas contas da
+// plataforma; qualifier distingue as contas de compensação por método de pagamento.
+func LedgerAccountCode(accountType LedgerAccountType, ownerID *uint, qualifier string) string {
+	code := string(accountType)
+	if ownerID != nil {
+		code += fmt.Sprintf(":%d", *ownerID)
+	}
+	if qualifier != "" {
+		code += ":" + qualifier
+	}
+	return code
+}
+
+// LedgerTransactionKind define a origem de uma transação do razão
+type LedgerTransactionKind string
+
+const (
+	LedgerTransactionPayment       LedgerTransactionKind = "payment"         // Valor recebido do cliente pelo gateway
+	LedgerTransactionEscrowHold    LedgerTransactionKind = "escrow_hold"     // Valor do cliente colocado em custódia
+	LedgerTransactionEscrowReturn  LedgerTransactionKind = "escrow_return"   // Valor em custódia devolvido ao cliente
+	LedgerTransactionRefund        LedgerTransactionKind = "refund"          // Valor do cliente estornado pelo gateway
+	LedgerTransactionEscrowRelease LedgerTransactionKind = "escrow_release"  // Repasse da custódia à prestadora
+	LedgerTransactionPayout        LedgerTransactionKind = "payout"          // Saque da prestadora enviado ao provedor de repasses
+	LedgerTransactionPayoutReturn  LedgerTransactionKind = "payout_reversal" // Saque que falhou, devolvido ao saldo da prestadora
+	LedgerTransactionTip           LedgerTransactionKind = "tip"             // Gorjeta recebida do cliente pelo gateway
+	LedgerTransactionTipTransfer   LedgerTransactionKind = "tip_transfer"    // Gorjeta repassada integralmente à prestadora
+	LedgerTransactionReferral      LedgerTransactionKind = "referral_credit" // Crédito de indicação concedido ao cliente
+	LedgerTransactionCreditHold    LedgerTransactionKind = "credit_hold"     // Crédito da carteira usado numa reserva, levado à custódia
+	LedgerTransactionCreditReturn  LedgerTransactionKind = "credit_return"   // Crédito devolvido à carteira no cancelamento
+	LedgerTransactionPromoSubsidy  LedgerTransactionKind = "promo_subsidy"   // Desconto de cupom custeado pela plataforma, levado à custódia
+	LedgerTransactionPromoReversal LedgerTransactionKind = "promo_reversal"  // Desconto de cupom estornado no cancelamento
+	LedgerTransactionChargeback    LedgerTransactionKind = "chargeback"      // Valor contestado no emissor, debitado pelo gateway
+)
+
+// LedgerTransaction é um lançamento imutável do razão. A soma dos valores das partidas
+// é sempre zero; correções são feitas com novos lançamentos.
+type LedgerTransaction struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+
+	Kind          LedgerTransactionKind `json:"kind" gorm:"not null;index"`
+	Reference     string                `json:"reference" gorm:"not null;uniqueIndex"` // Evita lançar o mesmo movimento duas vezes
+	PaymentID     *uint                 `json:"payment_id,omitempty" gorm:"index"`
+	PayoutID      *uint                 `json:"payout_id,omitempty" gorm:"index"`
+	TipID         *uint                 `json:"tip_id,omitempty" gorm:"index"`
+	AppointmentID *uint                 `json:"appointment_id,omitempty" gorm:"index"`
+	Description   string                `json:"description"`
+
+	Postings []LedgerPosting `json:"postings" gorm:"foreignKey:TransactionID"`
+}
+
+// LedgerPosting é uma partida de um lançamento, em centavos. Valores positivos são
+// débitos e negativos são créditos.
+type LedgerPosting struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	TransactionID uint               `json:"transaction_id" gorm:"not null;index"`
+	Transaction   *LedgerTransaction `json:"transaction,omitempty" gorm:"foreignKey:TransactionID"`
+	AccountID     uint               `json:"account_id" gorm:"not null;index"`
+	Account       *LedgerAccount     `json:"account,omitempty" gorm:"foreignKey:AccountID"`
+	Amount        int64              `json:"amount" gorm:"not null"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+)
+
+// NoShowClaimStatus define os possíveis status de uma alegação de ausência
+type NoShowClaimStatus string
+
+const (
+	NoShowClaimStatusOpen     NoShowClaimStatus = "open"     // Dentro da janela de contestação
+	NoShowClaimStatusDisputed NoShowClaimStatus = "disputed" // Contestada, aguardando análise do admin
+	NoShowClaimStatusUpheld   NoShowClaimStatus = "upheld"   // Ausência confirmada
+	NoShowClaimStatusRejected NoShowClaimStatus = "rejected" // Ausência não confirmada
+)
+
+// NoShowParty indica quem não compareceu ao agendamento
+type NoShowParty string
+
+const (
+	NoShowPartyClient   NoShowParty = "client"
+	NoShowPartyProvider NoShowParty = "provider"
+	NoShowPartyUnknown  NoShowParty = "unknown" // Agendamento abandonado sem alegação de nenhuma das partes
+)
+
+// NoShowClaim representa a alegação de que uma das partes não compareceu
+type NoShowClaim struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	AppointmentID uint        `json:"appointment_id" gorm:"not null;uniqueIndex"`
+	ClaimedBy     *uint       `json:"claimed_by,omitempty"` // Nulo quando gerada automaticamente
+	AbsentParty   NoShowParty `json:"absent_party" gorm:"not null"`
+	Evidence      string      `json:"evidence"`
+
+	// Contestação pela outra parte
+	DisputeDeadline time.Time  `json:"dispute_deadline" gorm:"not null"`
+	Response        string     `json:"response,omitempty"`
+	RespondedAt     *time.Time `json:"responded_at,omitempty"`
+
+	// Resolução
+	Status         NoShowClaimStatus `json:"status" gorm:"not null;default:'open'"`
+	ResolvedAt     *time.Time        `json:"resolved_at,omitempty"`
+	ResolvedBy     *uint             `json:"resolved_by,omitempty"` // Nulo quando resolvida automaticamente
+	ResolutionNote string            `json:"resolution_note,omitempty"`
+	Fee            float64           `json:"fee"` // Valor retido do cliente
+}
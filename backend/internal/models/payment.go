@@ -0,0 +1,125 @@
+package models
+
+import (
+	"time"
+)
+
+// PaymentCurrency é a moeda dos pagamentos; os valores são guardados em centavos
+const PaymentCurrency = "brl"
+
+// PaymentMethodType define como o cliente paga
+type PaymentMethodType string
+
+const (
+	PaymentMethodCard PaymentMethodType = "card"
+	PaymentMethodPix  PaymentMethodType = "pix"
+)
+
+// PaymentStatus define os possíveis status de um pagamento
+type PaymentStatus string
+
+const (
+	PaymentStatusPending           PaymentStatus = "pending"   // Aguardando confirmação do cliente
+	PaymentStatusSucceeded         PaymentStatus = "succeeded" // Valor capturado
+	PaymentStatusFailed            PaymentStatus = "failed"    // Recusado; pode ser confirmado com outro método
+	PaymentStatusCanceled          PaymentStatus = "canceled"  // Intenção cancelada antes do pagamento
+	PaymentStatusPartiallyRefunded PaymentStatus = "partially_refunded"
+	PaymentStatusRefunded          PaymentStatus = "refunded"
+)
+
+// EscrowStatus define a situação do valor pago mantido em custódia pela plataforma
+type EscrowStatus string
+
+const (
+	EscrowStatusNone     EscrowStatus = "none"     // Ainda não pago
+	EscrowStatusHeld     EscrowStatus = "held"     // Retido até a conclusão do atendimento e o fim da janela de contestação
+	EscrowStatusFrozen   EscrowStatus = "frozen"   // Congelado enquanto há disputa aberta
+	EscrowStatusReleased EscrowStatus = "released" // Repassado à prestadora
+	EscrowStatusRefunded EscrowStatus = "refunded" // Devolvido integralmente ao cliente
+)
+
+// Payment é o pagamento de um agendamento pelo cliente, espelhando a intenção de
+// pagamento criada no gateway
+type Payment struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	AppointmentID uint        `json:"appointment_id" gorm:"not null;index"`
+	Appointment   Appointment `json:"appointment,omitempty" gorm:"foreignKey:AppointmentID"`
+	UserID        uint        `json:"user_id" gorm:"not null;index"`
+	ProviderID    uint        `json:"provider_id" gorm:"not null;index"`
+
+	// Valores em centavos, calculados pelo servidor a partir do agendamento
+	Amount         int64  `json:"amount" gorm:"not null"`
+	RefundedAmount int64  `json:"refunded_amount" gorm:"not null;default:0"`
+	Currency       string `json:"currency" gorm:"not null"`
+
+	Method          PaymentMethodType `json:"method" gorm:"not null;default:'card'"`
+	Status          PaymentStatus     `json:"status" gorm:"not null;default:'pending'"`
+	GatewayIntentID string            `json:"payment_intent_id" gorm:"not null;uniqueIndex"` // No Pix, o txid da cobrança
+	ClientSecret    string            `json:"client_secret,omitempty"`
+	PaymentMethodID string            `json:"payment_method_id,omitempty"`
+	FailureReason   string            `json:"failure_reason,omitempty"`
+	PaidAt          *time.Time        `json:"paid_at,omitempty"`
+
+	// Pix: código "copia e cola" da cobrança, validade e identificador da transação liquidada
+	PixCode    string     `json:"pix_code,omitempty" gorm:"type:text"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" gorm:"index"`
+	EndToEndID string     `json:"end_to_end_id,omitempty"`
+
+	// Custódia: o valor fica retido até ser liberado à prestadora ou devolvido ao cliente
+	EscrowStatus    EscrowStatus `json:"escrow_status" gorm:"not null;default:'none';index"`
+	EscrowReleaseAt *time.Time   `json:"escrow_release_at,omitempty" gorm:"index"`
+	ReleasedAmount  int64        `json:"released_amount" gorm:"not null;default:0"` // Em centavos
+	ReleasedAt      *time.Time   `json:"released_at,omitempty"`
+
+	// Valor contestado pelo cliente no emissor do cartão e debitado pelo gateway, em centavos
+	ChargebackAmount int64 `json:"chargeback_amount" gorm:"not null;default:0"`
+
+	Refunds []Refund `json:"refunds,omitempty" gorm:"foreignKey:PaymentID"`
+}
+
+// Refundable retorna quanto do pagamento ainda pode ser reembolsado
+func (p *Payment) Refundable() int64 {
+	if p.Status != PaymentStatusSucceeded && p.Status != PaymentStatusPartiallyRefunded {
+		return 0
+	}
+	return p.Amount - p.RefundedAmount
+}
+
+// RefundStatus define os possíveis status de um reembolso
+type RefundStatus string
+
+const (
+	RefundStatusPending   RefundStatus = "pending" // Valor reservado, aguardando o gateway
+	RefundStatusSucceeded RefundStatus = "succeeded"
+	RefundStatusFailed    RefundStatus = "failed"
+)
+
+// Refund é a devolução total ou parcial de um pagamento
+type Refund struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	PaymentID       uint         `json:"payment_id" gorm:"not null;index"`
+	Amount          int64        `json:"amount" gorm:"not null"` // Em centavos
+	Reason          string       `json:"reason"`
+	RequestedBy     *uint        `json:"requested_by,omitempty"` // Nulo quando gerado pelo sistema
+	Status          RefundStatus `json:"status" gorm:"not null;default:'pending'"`
+	GatewayRefundID string       `json:"gateway_refund_id,omitempty"`
+}
+
+// PaymentMethod é um cartão salvo pelo cliente no gateway
+type PaymentMethod struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	UserID          uint   `json:"user_id" gorm:"not null;index"`
+	GatewayMethodID string `json:"payment_method_id" gorm:"not null;uniqueIndex"`
+	Brand           string `json:"brand"`
+	Last4           string `json:"last4"`
+	ExpMonth        int    `json:"exp_month"`
+	ExpYear         int    `json:"exp_year"`
+}
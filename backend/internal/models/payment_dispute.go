@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+// PaymentDisputeStatus define os possíveis status de uma disputa sobre o atendimento
+type PaymentDisputeStatus string
+
+const (
+	PaymentDisputeStatusOpen     PaymentDisputeStatus = "open"     // Valor congelado, aguardando análise do admin
+	PaymentDisputeStatusResolved PaymentDisputeStatus = "resolved" // Valor dividido conforme a decisão
+)
+
+// PaymentDispute é a contestação de um atendimento concluído, aberta por uma das partes
+// dentro da janela de contestação. Enquanto aberta, o valor em custódia fica congelado.
+type PaymentDispute struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	AppointmentID uint   `json:"appointment_id" gorm:"not null;uniqueIndex"`
+	PaymentID     uint   `json:"payment_id" gorm:"not null;index"`
+	OpenedBy      uint   `json:"opened_by" gorm:"not null"`
+	Reason        string `json:"reason" gorm:"not null"`
+
+	// Resolução
+	Status         PaymentDisputeStatus `json:"status" gorm:"not null;default:'open'"`
+	RefundAmount   int64                `json:"refund_amount"` // Em centavos, devolvido ao cliente; o restante vai à prestadora
+	ResolvedAt     *time.Time           `json:"resolved_at,omitempty"`
+	ResolvedBy     *uint                `json:"resolved_by,omitempty"`
+	ResolutionNote string               `json:"resolution_note,omitempty"`
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PayoutDestinationType define como a prestadora recebe os saques
+type PayoutDestinationType string
+
+const (
+	PayoutDestinationBankAccount PayoutDestinationType = "bank_account"
+	PayoutDestinationPixKey      PayoutDestinationType = "pix_key"
+)
+
+// PixKeyType define o tipo de chave Pix
+type PixKeyType string
+
+const (
+	PixKeyCPF    PixKeyType = "cpf"
+	PixKeyCNPJ   PixKeyType = "cnpj"
+	PixKeyEmail  PixKeyType = "email"
+	PixKeyPhone  PixKeyType = "phone"
+	PixKeyRandom PixKeyType = "random"
+)
+
+// BankAccountType define o tipo de conta bancária
+type BankAccountType string
+
+const (
+	BankAccountChecking BankAccountType = "checking"
+	BankAccountSavings  BankAccountType = "savings"
+)
+
+// PayoutDestination é a conta bancária ou chave Pix cadastrada pela prestadora para receber
+type PayoutDestination struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ProviderID uint                  `json:"provider_id" gorm:"not null;uniqueIndex"`
+	Type       PayoutDestinationType `json:"type" gorm:"not null"`
+
+	// Chave Pix
+	PixKeyType PixKeyType `json:"pix_key_type,omitempty"`
+	PixKey     string     `json:"pix_key,omitempty"`
+
+	// Conta bancária
+	BankCode      string          `json:"bank_code,omitempty"` // Código COMPE de 3 dígitos
+	Branch        string          `json:"branch,omitempty"`
+	AccountNumber string          `json:"account_number,omitempty"`
+	AccountType   BankAccountType `json:"account_type,omitempty"`
+
+	HolderName     string `json:"holder_name" gorm:"not null"`
+	HolderDocument string `json:"holder_document" gorm:"not null"` // CPF ou CNPJ, apenas dígitos
+}
+
+// Label descreve o destino com os dados sensíveis mascarados, para exibição e histórico
+func (d *PayoutDestination) Label() string {
+	if d.Type == PayoutDestinationPixKey {
+		return fmt.Sprintf("Pix %s %s", d.PixKeyType, maskTail(d.PixKey, 4))
+	}
+	return fmt.Sprintf("Banco %s ag. %s conta %s", d.BankCode, d.Branch, maskTail(d.AccountNumber, 4))
+}
+
+// maskTail mantém visíveis apenas os últimos visible caracteres
+func maskTail(value string, visible int) string {
+	if len(value) <= visible {
+		return value
+	}
+	return strings.Repeat("*", len(value)-visible) + value[len(value)-visible:]
+}
+
+// PayoutStatus define os possíveis status de um saque
+type PayoutStatus string
+
+const (
+	PayoutStatusPending    PayoutStatus = "pending"    // Aguardando envio ou nova tentativa
+	PayoutStatusProcessing PayoutStatus = "processing" // Aceito pelo provedor de repasses
+	PayoutStatusPaid       PayoutStatus = "paid"
+	PayoutStatusFailed     PayoutStatus = "failed" // Valor devolvido ao saldo disponível
+)
+
+// Payout é um saque do saldo disponível da prestadora
+type Payout struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ProviderID uint         `json:"provider_id" gorm:"not null;index"`
+	Amount     int64        `json:"amount" gorm:"not null"` // Em centavos
+	Currency   string       `json:"currency" gorm:"not null"`
+	Status     PayoutStatus `json:"status" gorm:"not null;default:'pending';index"`
+	Automatic  bool         `json:"automatic" gorm:"not null;default:false"` // Gerado pelos repasses automáticos
+
+	// Cópia do destino no momento do saque
+	DestinationID    uint                  `json:"destination_id" gorm:"not null"`
+	DestinationType  PayoutDestinationType `json:"destination_type" gorm:"not null"`
+	DestinationLabel string                `json:"destination_label"`
+
+	// Acompanhamento no provedor de repasses
+	TransferID    string     `json:"transfer_id,omitempty"`
+	Attempts      int        `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty" gorm:"index"`
+	FailureReason string     `json:"failure_reason,omitempty"`
+	PaidAt        *time.Time `json:"paid_at,omitempty"`
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"time"
+)
+
+// PromoCode é um cupom de desconto aplicado na reserva. O desconto é percentual ou de
+// valor fixo e é custeado pela plataforma; a prestadora recebe pelo preço cheio.
+type PromoCode struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Code        string `json:"code" gorm:"not null;uniqueIndex"` // Sempre em maiúsculas
+	Description string `json:"description"`
+	Active      bool   `json:"active" gorm:"not null;index"`
+
+	// Desconto: percentual (1 a 100) ou valor fixo em centavos, nunca os dois
+	PercentOff  int   `json:"percent_off" gorm:"not null;default:0"`
+	AmountOff   int64 `json:"amount_off" gorm:"not null;default:0"`
+	MaxDiscount int64 `json:"max_discount" gorm:"not null;default:0"` // Teto do desconto percentual; 0 sem teto
+
+	// Regras de uso (0 desativa cada limite)
+	MinSpend         int64      `json:"min_spend" gorm:"not null;default:0"`       // Valor mínimo do atendimento, em centavos
+	MaxRedemptions   int        `json:"max_redemptions" gorm:"not null;default:0"` // Usos no total
+	PerUserLimit     int        `json:"per_user_limit" gorm:"not null;default:0"`  // Usos por cliente
+	FirstBookingOnly bool       `json:"first_booking_only" gorm:"not null;default:false"`
+	StartsAt         *time.Time `json:"starts_at,omitempty"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+
+	Redemptions int `json:"redemptions" gorm:"not null;default:0"` // Usos ativos
+}
+
+// Discount calcula o desconto do cupom sobre subtotal centavos, sem ultrapassá-lo
+func (p *PromoCode) Discount(subtotal int64) int64 {
+	discount := p.AmountOff
+	if p.PercentOff > 0 {
+		discount = subtotal * int64(p.PercentOff) / 100
+		if p.MaxDiscount > 0 {
+			discount = min(discount, p.MaxDiscount)
+		}
+	}
+	return min(max(discount, 0), subtotal)
+}
+
+// PromoRedemptionStatus define se o uso do cupom ainda conta nos limites
+type PromoRedemptionStatus string
+
+const (
+	PromoRedemptionActive   PromoRedemptionStatus = "active"
+	PromoRedemptionReleased PromoRedemptionStatus = "released" // Agendamento cancelado; o uso foi devolvido
+)
+
+// PromoRedemption é o uso de um cupom em um agendamento
+type PromoRedemption struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	PromoCodeID   uint                  `json:"promo_code_id" gorm:"not null;index"`
+	UserID        uint                  `json:"user_id" gorm:"not null;index"`
+	AppointmentID uint                  `json:"appointment_id" gorm:"not null;uniqueIndex"`
+	Discount      int64                 `json:"discount" gorm:"not null"` // Em centavos
+	Status        PromoRedemptionStatus `json:"status" gorm:"not null;default:'active'"`
+}
+
+// ReferralCode é o código que um cliente compartilha para indicar outros clientes
+type ReferralCode struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	UserID uint   `json:"user_id" gorm:"not null;uniqueIndex"`
+	Code   string `json:"code" gorm:"not null;uniqueIndex"`
+}
+
+// ReferralStatus define os possíveis status de uma indicação
+type ReferralStatus string
+
+const (
+	ReferralStatusPending  ReferralStatus = "pending"  // Aguardando o primeiro atendimento concluído do indicado
+	ReferralStatusRewarded ReferralStatus = "rewarded" // Créditos concedidos aos dois clientes
+)
+
+// Referral liga o cliente indicado a quem o indicou. Cada cliente pode ser indicado uma vez.
+type Referral struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ReferrerID uint           `json:"referrer_id" gorm:"not null;index"`
+	RefereeID  uint           `json:"referee_id" gorm:"not null;uniqueIndex"`
+	Status     ReferralStatus `json:"status" gorm:"not null;default:'pending';index"`
+
+	// Recompensa, em centavos, e o atendimento que a liberou
+	ReferrerReward int64      `json:"referrer_reward" gorm:"not null;default:0"`
+	RefereeReward  int64      `json:"referee_reward" gorm:"not null;default:0"`
+	AppointmentID  *uint      `json:"appointment_id,omitempty"`
+	RewardedAt     *time.Time `json:"rewarded_at,omitempty"`
+}
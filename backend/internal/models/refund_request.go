@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+)
+
+// RefundRequestStatus define os possíveis status de um pedido de reembolso do cliente
+type RefundRequestStatus string
+
+const (
+	RefundRequestStatusPending  RefundRequestStatus = "pending"  // Aguardando a prestadora ou o suporte
+	RefundRequestStatusApproved RefundRequestStatus = "approved" // Reembolso feito
+	RefundRequestStatusRejected RefundRequestStatus = "rejected"
+)
+
+// RefundRequest é o pedido de reembolso feito pelo cliente. O valor só é devolvido
+// quando a prestadora ou o suporte aprovam o pedido.
+type RefundRequest struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	PaymentID     uint   `json:"payment_id" gorm:"not null;index"`
+	AppointmentID uint   `json:"appointment_id" gorm:"not null;index"`
+	UserID        uint   `json:"user_id" gorm:"not null;index"`
+	ProviderID    uint   `json:"provider_id" gorm:"not null;index"`
+	Amount        int64  `json:"amount" gorm:"not null"` // Em centavos
+	Reason        string `json:"reason"`
+
+	// Análise
+	Status     RefundRequestStatus `json:"status" gorm:"not null;default:'pending';index"`
+	ReviewedAt *time.Time          `json:"reviewed_at,omitempty"`
+	ReviewedBy *uint               `json:"reviewed_by,omitempty"`
+	ReviewNote string              `json:"review_note,omitempty"`
+	RefundID   *uint               `json:"refund_id,omitempty"` // Reembolso feito na aprovação
+}
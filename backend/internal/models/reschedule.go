@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// RescheduleStatus define os possíveis status de um pedido de remarcação
+type RescheduleStatus string
+
+const (
+	RescheduleStatusPending   RescheduleStatus = "pending"
+	RescheduleStatusAccepted  RescheduleStatus = "accepted"
+	RescheduleStatusRejected  RescheduleStatus = "rejected"
+	RescheduleStatusCountered RescheduleStatus = "countered"
+)
+
+// RescheduleRequest representa uma proposta de novo horário para um agendamento.
+// Os pedidos nunca são apagados, formando o histórico de negociação.
+type RescheduleRequest struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	AppointmentID uint  `json:"appointment_id" gorm:"not null;index"`
+	ProposedBy    uint  `json:"proposed_by" gorm:"not null"`
+	ParentID      *uint `json:"parent_id,omitempty"` // Pedido ao qual esta contraproposta responde
+
+	// Horário proposto
+	Date time.Time `json:"date" gorm:"not null"`
+	Time string    `json:"time" gorm:"not null"`
+
+	// Horário anterior, preservado para o histórico
+	PreviousDate time.Time `json:"previous_date" gorm:"not null"`
+	PreviousTime string    `json:"previous_time" gorm:"not null"`
+
+	Reason      string           `json:"reason"`
+	Status      RescheduleStatus `json:"status" gorm:"not null;default:'pending'"`
+	RespondedBy *uint            `json:"responded_by,omitempty"`
+	RespondedAt *time.Time       `json:"responded_at,omitempty"`
+}
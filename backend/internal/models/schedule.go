@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// ScheduleLocation é o fuso em que a data e o horário dos agendamentos são expressos.
+// É definido na inicialização a partir de APP_TIMEZONE.
+var ScheduleLocation = time.UTC
+
+// ScheduleTime combina o dia de date com o horário informado no fuso da agenda
+func ScheduleTime(date time.Time, hour, minute int) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, ScheduleLocation)
+}
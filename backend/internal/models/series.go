@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+)
+
+// SeriesStatus define os possíveis status de uma série de agendamentos
+type SeriesStatus string
+
+const (
+	SeriesStatusActive SeriesStatus = "active"
+	SeriesStatusEnded  SeriesStatus = "ended"
+)
+
+// AppointmentSeries representa uma série de agendamentos recorrentes com a mesma prestadora.
+// As ocorrências são materializadas como Appointment com antecedência.
+type AppointmentSeries struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Relacionamentos
+	UserID     uint `json:"user_id" gorm:"not null;index"`
+	ProviderID uint `json:"provider_id" gorm:"not null;index"`
+
+	// Regra de recorrência (subconjunto de RRULE) a partir de StartDate
+	RRule     string    `json:"rrule" gorm:"not null"`
+	StartDate time.Time `json:"start_date" gorm:"not null"`
+	Time      string    `json:"time" gorm:"not null"`
+
+	// Dados copiados para cada ocorrência
+	Service   string  `json:"service" gorm:"not null"`
+	Duration  int     `json:"duration"` // Duração em minutos
+	Notes     string  `json:"notes"`
+	Location  string  `json:"location"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+
+	Status SeriesStatus `json:"status" gorm:"not null;default:'active'"`
+	// EndsBefore encerra a série a partir desta data ("esta e as seguintes")
+	EndsBefore *time.Time `json:"ends_before,omitempty"`
+	// MaterializedUntil indica até onde as ocorrências já foram geradas
+	MaterializedUntil time.Time `json:"materialized_until"`
+}
+
+// SeriesConflict registra uma ocorrência que não pôde ser criada por conflito de horário
+// ou pelo limite de agendamentos da prestadora, descrito em Reason
+type SeriesConflict struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	SeriesID                 uint      `json:"series_id" gorm:"not null;index"`
+	OccurrenceDate           time.Time `json:"occurrence_date" gorm:"not null"`
+	ConflictingAppointmentID uint      `json:"conflicting_appointment_id"`
+	Reason                   string    `json:"reason,omitempty"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+// TipStatus define os possíveis status de uma gorjeta
+type TipStatus string
+
+const (
+	TipStatusPending   TipStatus = "pending"   // Cobrança em andamento no gateway
+	TipStatusSucceeded TipStatus = "succeeded" // Valor capturado e repassado à prestadora
+	TipStatusFailed    TipStatus = "failed"    // Recusada; pode ser enviada novamente
+)
+
+// Tip é a gorjeta do cliente para a prestadora após um atendimento concluído, cobrada no
+// cartão salvo e repassada sem comissão. Cada agendamento aceita uma gorjeta.
+type Tip struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	AppointmentID uint `json:"appointment_id" gorm:"not null;uniqueIndex"`
+	UserID        uint `json:"user_id" gorm:"not null;index"`
+	ProviderID    uint `json:"provider_id" gorm:"not null;index"`
+
+	Amount          int64      `json:"amount" gorm:"not null"` // Em centavos
+	Currency        string     `json:"currency" gorm:"not null"`
+	Message         string     `json:"message,omitempty"`
+	Status          TipStatus  `json:"status" gorm:"not null;default:'pending'"`
+	PaymentMethodID string     `json:"payment_method_id"`
+	GatewayIntentID string     `json:"payment_intent_id,omitempty"`
+	FailureReason   string     `json:"failure_reason,omitempty"`
+	PaidAt          *time.Time `json:"paid_at,omitempty"`
+}
@@ -27,10 +27,33 @@ type User struct {
 	UserType UserType `json:"user_type" gorm:"not null"`
 	IsActive bool     `json:"is_active" gorm:"default:true"`
 
+	// Reputação do cliente
+	IsVerified  bool    `json:"is_verified" gorm:"not null;default:false"` // Identidade verificada por um admin
+	Rating      float64 `json:"rating" gorm:"not null;default:0"`          // Média das avaliações das prestadoras
+	RatingCount int     `json:"rating_count" gorm:"not null;default:0"`
+
 	// Campos específicos para prestadoras
 	ProviderProfile *ProviderProfile `json:"provider_profile,omitempty" gorm:"foreignKey:UserID"`
 }
 
+// CancellationPolicy define a política de cancelamento de uma prestadora
+type CancellationPolicy string
+
+const (
+	CancellationPolicyFlexible CancellationPolicy = "flexible"
+	CancellationPolicyModerate CancellationPolicy = "moderate"
+	CancellationPolicyStrict   CancellationPolicy = "strict"
+)
+
+// IsValid indica se a política de cancelamento é conhecida
+func (p CancellationPolicy) IsValid() bool {
+	switch p {
+	case CancellationPolicyFlexible, CancellationPolicyModerate, CancellationPolicyStrict:
+		return true
+	}
+	return false
+}
+
 // ProviderProfile representa o perfil de uma prestadora
 type ProviderProfile struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
@@ -52,4 +75,35 @@ type ProviderProfile struct {
 	// Configurações
 	AvailableClothes []string `json:"available_clothes" gorm:"type:text[]"` // Lista de roupas permitidas
 	WorkingHours     string   `json:"working_hours"`                        // Horário de trabalho em formato JSON
+
+	// Intervalo entre atendimentos
+	BufferMinutes     int  `json:"buffer_minutes" gorm:"not null;default:0"`          // Intervalo fixo entre atendimentos
+	TravelTimeEnabled bool `json:"travel_time_enabled" gorm:"not null;default:false"` // Soma o deslocamento estimado entre endereços
+
+	// Janela e limites de agendamento (0 desativa cada regra)
+	MinNoticeMinutes int `json:"min_notice_minutes" gorm:"not null;default:0"` // Antecedência mínima
+	MaxAdvanceDays   int `json:"max_advance_days" gorm:"not null;default:0"`   // Horizonte máximo em dias
+	DailyBookingCap  int `json:"daily_booking_cap" gorm:"not null;default:0"`  // Agendamentos por dia
+	WeeklyBookingCap int `json:"weekly_booking_cap" gorm:"not null;default:0"` // Agendamentos por semana (segunda a domingo)
+
+	// Cancelamento
+	CancellationPolicy    CancellationPolicy `json:"cancellation_policy" gorm:"not null;default:'flexible'"`
+	ProviderCancellations int                `json:"provider_cancellations" gorm:"not null;default:0"` // Cancelamentos feitos pela prestadora
+	ProviderNoShows       int                `json:"provider_no_shows" gorm:"not null;default:0"`      // Ausências confirmadas da prestadora
+
+	// Reserva instantânea: agendamentos de clientes que atendem aos critérios já nascem confirmados.
+	// Todos os critérios habilitados precisam ser atendidos.
+	InstantBookingEnabled         bool    `json:"instant_booking_enabled" gorm:"not null;default:false"`
+	InstantBookingVerifiedOnly    bool    `json:"instant_booking_verified_only" gorm:"not null;default:false"`
+	InstantBookingMinRating       float64 `json:"instant_booking_min_rating" gorm:"not null;default:0"` // 0 dispensa avaliação mínima
+	InstantBookingPastClientsOnly bool    `json:"instant_booking_past_clients_only" gorm:"not null;default:false"`
+	InstantBookingDailyCap        int     `json:"instant_booking_daily_cap" gorm:"not null;default:0"` // Por dia de atendimento; 0 é ilimitado
+
+	// Repasses automáticos: o saldo disponível é sacado quando atinge o mínimo, em centavos
+	AutoPayoutEnabled bool  `json:"auto_payout_enabled" gorm:"not null;default:false"`
+	AutoPayoutMinimum int64 `json:"auto_payout_minimum" gorm:"not null;default:5000"`
+
+	// Cidade de atuação e categoria comercial, usadas nas regras de comissão
+	City string       `json:"city"`
+	Tier ProviderTier `json:"tier" gorm:"not null;default:'standard'"`
 }
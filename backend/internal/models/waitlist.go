@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+)
+
+// WaitlistEntryStatus define os possíveis status de uma inscrição na lista de espera
+type WaitlistEntryStatus string
+
+const (
+	WaitlistEntryStatusWaiting   WaitlistEntryStatus = "waiting"
+	WaitlistEntryStatusOffered   WaitlistEntryStatus = "offered"
+	WaitlistEntryStatusBooked    WaitlistEntryStatus = "booked"
+	WaitlistEntryStatusCancelled WaitlistEntryStatus = "cancelled"
+	WaitlistEntryStatusExpired   WaitlistEntryStatus = "expired"
+)
+
+// WaitlistEntry representa um cliente aguardando vaga com uma prestadora em um período
+type WaitlistEntry struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID     uint `json:"user_id" gorm:"not null;index"`
+	ProviderID uint `json:"provider_id" gorm:"not null;index"`
+
+	// Período aceito pelo cliente (datas inclusivas)
+	WindowStart time.Time `json:"window_start" gorm:"not null"`
+	WindowEnd   time.Time `json:"window_end" gorm:"not null"`
+
+	// Dados usados para criar o agendamento quando a vaga for aceita
+	Service   string  `json:"service" gorm:"not null"`
+	Duration  int     `json:"duration"` // Duração em minutos
+	Notes     string  `json:"notes"`
+	Location  string  `json:"location"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+
+	Status        WaitlistEntryStatus `json:"status" gorm:"not null;default:'waiting'"`
+	AppointmentID *uint               `json:"appointment_id,omitempty"`
+}
+
+// WaitlistOfferStatus define os possíveis status de uma oferta de vaga
+type WaitlistOfferStatus string
+
+const (
+	WaitlistOfferStatusPending  WaitlistOfferStatus = "pending"
+	WaitlistOfferStatusAccepted WaitlistOfferStatus = "accepted"
+	WaitlistOfferStatusDeclined WaitlistOfferStatus = "declined"
+	WaitlistOfferStatusExpired  WaitlistOfferStatus = "expired"
+)
+
+// WaitlistOffer é uma vaga liberada oferecida a um cliente da lista de espera.
+// Enquanto pendente, o horário fica reservado para o cliente até ExpiresAt.
+type WaitlistOffer struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	EntryID    uint `json:"entry_id" gorm:"not null;index"`
+	UserID     uint `json:"user_id" gorm:"not null;index"`
+	ProviderID uint `json:"provider_id" gorm:"not null;index"`
+
+	// Vaga liberada e duração reservada para o cliente a partir de SlotStart
+	SlotStart time.Time `json:"slot_start" gorm:"not null"`
+	SlotEnd   time.Time `json:"slot_end" gorm:"not null"`
+	Duration  int       `json:"duration" gorm:"not null"`
+
+	ExpiresAt     time.Time           `json:"expires_at" gorm:"not null;index"`
+	Status        WaitlistOfferStatus `json:"status" gorm:"not null;default:'pending'"`
+	AppointmentID *uint               `json:"appointment_id,omitempty"`
+}
+
+// StartsAt retorna o início do horário reservado
+func (o *WaitlistOffer) StartsAt() time.Time {
+	return o.SlotStart
+}
+
+// EndsAt retorna o fim do horário reservado
+func (o *WaitlistOffer) EndsAt() time.Time {
+	return o.SlotStart.Add(time.Duration(o.Duration) * time.Minute)
+}
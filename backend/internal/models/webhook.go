@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+)
+
+// WebhookEventStatus define os possíveis status de um evento recebido por webhook
+type WebhookEventStatus string
+
+const (
+	WebhookEventPending   WebhookEventStatus = "pending"   // Aguardando processamento ou nova tentativa
+	WebhookEventProcessed WebhookEventStatus = "processed" // Aplicado com sucesso
+	WebhookEventIgnored   WebhookEventStatus = "ignored"   // Sem efeito, como pagamento desconhecido; não é repetido
+	WebhookEventFailed    WebhookEventStatus = "failed"    // Tentativas esgotadas; pode ser reprocessado por um admin
+)
+
+// WebhookEvent é um evento recebido de um provedor externo, gravado como chegou antes de
+// ser processado. O ID do evento no provedor é único, o que descarta reenvios.
+type WebhookEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Provider string             `json:"provider" gorm:"not null;uniqueIndex:idx_webhook_events_provider_event"`
+	EventID  string             `json:"event_id" gorm:"not null;uniqueIndex:idx_webhook_events_provider_event"`
+	Type     string             `json:"type" gorm:"not null;index"`
+	Payload  string             `json:"payload" gorm:"type:text;not null"` // Corpo original do evento
+	Status   WebhookEventStatus `json:"status" gorm:"not null;default:'pending';index"`
+
+	Attempts      int        `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty" gorm:"index"`
+	LastError     string     `json:"last_error,omitempty"`
+	ProcessedAt   *time.Time `json:"processed_at,omitempty"`
+}
+
+// WebhookDelivery é uma notificação assinada já recebida. A assinatura é única por provedor,
+// o que descarta a repetição da mesma notificação enquanto o horário assinado ainda é aceito;
+// depois disso a assinatura já é recusada e o registro pode ser apagado.
+type WebhookDelivery struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Provider  string    `json:"provider" gorm:"not null;uniqueIndex:idx_webhook_deliveries_provider_signature"`
+	Signature string    `json:"signature" gorm:"not null;uniqueIndex:idx_webhook_deliveries_provider_signature"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null;index"`
+}
@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"github.com/xclean/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// AppointmentEventRepository grava o histórico de alterações dos agendamentos.
+// O histórico é somente de inclusão: não há métodos de alteração ou remoção.
+type AppointmentEventRepository struct {
+	db *gorm.DB
+}
+
+func NewAppointmentEventRepository(db *gorm.DB) *AppointmentEventRepository {
+	return &AppointmentEventRepository{
+		db: db,
+	}
+}
+
+// Append inclui eventos no histórico
+func (r *AppointmentEventRepository) Append(events ...*models.AppointmentEvent) error {
+	return appendEvents(r.db, events...)
+}
+
+// appendEvents inclui eventos no histórico dentro da transação da alteração que os originou,
+// para que a alteração e o seu registro sejam gravados juntos. Eventos nulos são ignorados.
+func appendEvents(tx *gorm.DB, events ...*models.AppointmentEvent) error {
+	pending := make([]*models.AppointmentEvent, 0, len(events))
+	for _, event := range events {
+		if event != nil {
+			pending = append(pending, event)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	return tx.Create(pending).Error
+}
+
+// eventsFor associa ao agendamento recém-criado os eventos que ainda não têm agendamento
+func eventsFor(appointmentID uint, events []*models.AppointmentEvent) []*models.AppointmentEvent {
+	for _, event := range events {
+		if event != nil && event.AppointmentID == 0 {
+			event.AppointmentID = appointmentID
+		}
+	}
+	return events
+}
+
+// GetByAppointment retorna o histórico de um agendamento em ordem cronológica
+func (r *AppointmentEventRepository) GetByAppointment(appointmentID uint) ([]models.AppointmentEvent, error) {
+	var events []models.AppointmentEvent
+	if err := r.db.Where("appointment_id = ?", appointmentID).Order("created_at ASC, id ASC").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -0,0 +1,125 @@
+package repositories
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/testutil"
+	"gorm.io/gorm"
+)
+
+var errEventRejected = errors.New("histórico indisponível")
+
+// failEventInserts faz toda inclusão no histórico falhar, como uma queda do banco no meio
+// da transação
+func failEventInserts(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	err := db.Callback().Create().Before("gorm:create").Register("test:fail_events", func(tx *gorm.DB) {
+		if tx.Statement.Table == "appointment_events" {
+			tx.AddError(errEventRejected)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStateChangesWriteEventsInTheSameTransaction(t *testing.T) {
+	tests := []struct {
+		name       string
+		failEvents bool
+		wantErr    error
+		wantStatus models.AppointmentStatus
+		wantEvents int
+	}{
+		{"alteração e evento gravados juntos", false, nil, models.AppointmentStatusInProgress, 1},
+		{"falha no histórico desfaz a alteração", true, errEventRejected, models.AppointmentStatusConfirmed, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			client := testutil.CreateUser(t, db, models.UserTypeClient)
+			provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+			appointment := testutil.CreateAppointment(t, db, models.Appointment{
+				UserID: client.ID, ProviderID: provider.ID, Status: models.AppointmentStatusConfirmed,
+				Date: time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC),
+			})
+			if tt.failEvents {
+				failEventInserts(t, db)
+			}
+
+			now := time.Now()
+			appointment.Status = models.AppointmentStatusInProgress
+			appointment.CheckedInAt = &now
+			event := &models.AppointmentEvent{AppointmentID: appointment.ID, ActorID: &provider.ID, Type: models.AppointmentEventCheckedIn}
+			err := NewAppointmentRepository(db, nil).UpdateVisit(appointment, event)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateVisit: %v, want %v", err, tt.wantErr)
+			}
+
+			var stored models.Appointment
+			db.First(&stored, appointment.ID)
+			var events int64
+			db.Model(&models.AppointmentEvent{}).Where("appointment_id = ?", appointment.ID).Count(&events)
+			if stored.Status != tt.wantStatus || events != int64(tt.wantEvents) {
+				t.Errorf("status %s com %d eventos; want %s com %d", stored.Status, events, tt.wantStatus, tt.wantEvents)
+			}
+		})
+	}
+}
+
+func TestCreatedAppointmentEventsGetTheNewID(t *testing.T) {
+	db := testutil.NewDB(t)
+	client := testutil.CreateUser(t, db, models.UserTypeClient)
+	provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+	appointment := &models.Appointment{
+		UserID: client.ID, ProviderID: provider.ID, Service: "Limpeza", Status: models.AppointmentStatusPending,
+		Date: time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC), Time: "09:00", Duration: 120,
+	}
+
+	err := NewAppointmentRepository(db, nil).CreateWithoutConflict(appointment, nil, func(created *models.Appointment) []*models.AppointmentEvent {
+		return []*models.AppointmentEvent{{AppointmentID: created.ID, ActorID: &client.ID, Type: models.AppointmentEventCreated}}
+	})
+	if err != nil {
+		t.Fatalf("CreateWithoutConflict: %v", err)
+	}
+
+	events, err := NewAppointmentEventRepository(db).GetByAppointment(appointment.ID)
+	if err != nil || len(events) != 1 || events[0].Type != models.AppointmentEventCreated {
+		t.Fatalf("histórico = %+v, %v; want um evento de criação", events, err)
+	}
+}
+
+func TestReleaseEscrowRecordsReleasedAmount(t *testing.T) {
+	db := testutil.NewDB(t)
+	client := testutil.CreateUser(t, db, models.UserTypeClient)
+	provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+	appointment := testutil.CreateAppointment(t, db, models.Appointment{
+		UserID: client.ID, ProviderID: provider.ID, Status: models.AppointmentStatusCompleted,
+		Date: time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC),
+	})
+	payment := testutil.CreatePayment(t, db, models.Payment{
+		AppointmentID: appointment.ID, UserID: client.ID, ProviderID: provider.ID,
+		Amount: 15000, RefundedAmount: 5000, Status: models.PaymentStatusSucceeded, EscrowStatus: models.EscrowStatusHeld,
+	})
+
+	var recorded int64
+	_, released, err := NewPaymentRepository(db).ReleaseEscrowNow(payment.ID, time.Now(), func(p *models.Payment, _ ReturnedIncentives) *EscrowReleaseRecord {
+		recorded = p.ReleasedAmount
+		return &EscrowReleaseRecord{Events: []*models.AppointmentEvent{{AppointmentID: p.AppointmentID, Type: models.AppointmentEventEscrowReleased}}}
+	})
+	if err != nil || !released {
+		t.Fatalf("ReleaseEscrowNow = %v, %v", released, err)
+	}
+	if recorded != 10000 {
+		t.Errorf("valor no histórico = %d, want 10000", recorded)
+	}
+
+	var events int64
+	db.Model(&models.AppointmentEvent{}).Where("appointment_id = ? AND type = ?", appointment.ID, models.AppointmentEventEscrowReleased).Count(&events)
+	if events != 1 {
+		t.Errorf("%d eventos de repasse, want 1", events)
+	}
+}
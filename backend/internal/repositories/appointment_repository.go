@@ -6,19 +6,28 @@ import (
 
 	"github.com/xclean/backend/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var (
 	ErrAppointmentNotFound = errors.New("agendamento não encontrado")
+	ErrSlotUnavailable     = errors.New("horário indisponível para a prestadora")
+	ErrClientAlreadyRated  = errors.New("o cliente já foi avaliado neste agendamento")
+	// ErrAppointmentStatusChanged indica que o status mudou desde que o agendamento foi lido
+	ErrAppointmentStatusChanged = errors.New("o status do agendamento foi alterado por outra operação")
 )
 
 type AppointmentRepository struct {
-	db *gorm.DB
+	db     *gorm.DB
+	travel TravelTimeEstimator
+	now    func() time.Time
 }
 
-func NewAppointmentRepository(db *gorm.DB) *AppointmentRepository {
+func NewAppointmentRepository(db *gorm.DB, travel TravelTimeEstimator) *AppointmentRepository {
 	return &AppointmentRepository{
-		db: db,
+		db:     db,
+		travel: travel,
+		now:    time.Now,
 	}
 }
 
@@ -27,6 +36,61 @@ func (r *AppointmentRepository) Create(appointment *models.Appointment) error {
 	return r.db.Create(appointment).Error
 }
 
+// CreateWithoutConflict cria o agendamento somente se a prestadora estiver livre no horário.
+// A agenda da prestadora fica bloqueada durante a verificação para evitar reservas duplicadas.
+// Os limites diário e semanal de agendamentos da prestadora também são verificados aqui.
+// Reservas instantâneas acima do limite diário da prestadora são criadas como pendentes.
+// O cupom e o crédito em incentives, se houver, são confirmados e gravados na mesma transação,
+// assim como os eventos do histórico montados por record.
+func (r *AppointmentRepository) CreateWithoutConflict(appointment *models.Appointment, incentives *BookingIncentives, record AppointmentRecorder) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockProvider(tx, appointment.ProviderID); err != nil {
+			return err
+		}
+		if err := ensureProviderAvailable(tx, appointment, 0, r.travel); err != nil {
+			return err
+		}
+		if err := enforceBookingCaps(tx, appointment, 0); err != nil {
+			return err
+		}
+		if appointment.InstantBooked {
+			if err := applyInstantBookingCap(tx, appointment); err != nil {
+				return err
+			}
+		}
+		if incentives != nil {
+			if err := reserveIncentives(tx, appointment, incentives); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(appointment).Error; err != nil {
+			return err
+		}
+		if incentives != nil {
+			if err := recordIncentives(tx, appointment, incentives); err != nil {
+				return err
+			}
+		}
+		if record == nil {
+			return nil
+		}
+		return appendEvents(tx, record(appointment)...)
+	})
+}
+
+// AppointmentRecorder monta os eventos do histórico a partir do agendamento já gravado.
+// É chamado dentro da transação e não deve acessar o banco.
+type AppointmentRecorder func(appointment *models.Appointment) []*models.AppointmentEvent
+
+// HasCompletedAppointment indica se o cliente já teve um atendimento concluído com a prestadora
+func (r *AppointmentRepository) HasCompletedAppointment(userID, providerID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Appointment{}).
+		Where("user_id = ? AND provider_id = ? AND status = ?", userID, providerID, models.AppointmentStatusCompleted).
+		Count(&count).Error
+	return count > 0, err
+}
+
 // FindByID busca um agendamento pelo ID
 func (r *AppointmentRepository) FindByID(id uint) (*models.Appointment, error) {
 	var appointment models.Appointment
@@ -39,41 +103,252 @@ func (r *AppointmentRepository) FindByID(id uint) (*models.Appointment, error) {
 	return &appointment, nil
 }
 
+// Limites de paginação das listagens de agendamentos
+const (
+	DefaultAppointmentPageSize = 20
+	MaxAppointmentPageSize     = 100
+)
+
+// AppointmentCursor identifica o último item de uma página na ordenação (date, id)
+type AppointmentCursor struct {
+	Date time.Time
+	ID   uint
+}
+
+// AppointmentFilter contém os filtros das listagens de agendamentos
+type AppointmentFilter struct {
+	Statuses []models.AppointmentStatus
+	From     *time.Time // Data inicial (inclusive)
+	To       *time.Time // Data final (inclusive)
+	Upcoming bool       // Apenas agendamentos de hoje em diante, em ordem crescente
+	Past     bool       // Apenas agendamentos anteriores a hoje
+	Limit    int
+	Cursor   *AppointmentCursor
+}
+
+// AppointmentPage é uma página de agendamentos com o total de resultados do filtro
+type AppointmentPage struct {
+	Appointments []models.Appointment
+	Total        int64
+	NextCursor   *AppointmentCursor
+}
+
 // GetUserAppointments retorna os agendamentos de um usuário
-func (r *AppointmentRepository) GetUserAppointments(userID uint, status string) ([]models.Appointment, error) {
-	var appointments []models.Appointment
-	query := r.db.Where("user_id = ?", userID)
+func (r *AppointmentRepository) GetUserAppointments(userID uint, filter AppointmentFilter) (*AppointmentPage, error) {
+	return r.list(r.db.Where("user_id = ?", userID), filter)
+}
+
+// GetProviderAppointments retorna os agendamentos de uma prestadora
+func (r *AppointmentRepository) GetProviderAppointments(providerID uint, filter AppointmentFilter) (*AppointmentPage, error) {
+	return r.list(r.db.Where("provider_id = ?", providerID), filter)
+}
+
+// list aplica os filtros, conta o total e busca uma página a partir do cursor
+func (r *AppointmentRepository) list(query *gorm.DB, filter AppointmentFilter) (*AppointmentPage, error) {
+	query = query.Model(&models.Appointment{})
+
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
+	}
+	if filter.From != nil {
+		query = query.Where("date >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("date <= ?", *filter.To)
+	}
 
-	if status != "" {
-		query = query.Where("status = ?", status)
+	// O dia de hoje é o do fuso da agenda, o mesmo das datas dos agendamentos
+	today := models.ScheduleTime(r.now().In(models.ScheduleLocation), 0, 0)
+	switch {
+	case filter.Upcoming:
+		query = query.Where("date >= ?", today)
+	case filter.Past:
+		query = query.Where("date < ?", today)
 	}
 
-	if err := query.Order("date DESC").Find(&appointments).Error; err != nil {
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
 		return nil, err
 	}
 
-	return appointments, nil
-}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultAppointmentPageSize
+	}
+	if limit > MaxAppointmentPageSize {
+		limit = MaxAppointmentPageSize
+	}
 
-// GetProviderAppointments retorna os agendamentos de uma prestadora
-func (r *AppointmentRepository) GetProviderAppointments(providerID uint, status string) ([]models.Appointment, error) {
+	order, comparison := "date DESC, id DESC", "<"
+	if filter.Upcoming {
+		order, comparison = "date ASC, id ASC", ">"
+	}
+	if filter.Cursor != nil {
+		query = query.Where("(date "+comparison+" ? OR (date = ? AND id "+comparison+" ?))",
+			filter.Cursor.Date, filter.Cursor.Date, filter.Cursor.ID)
+	}
+
+	// Busca um item a mais para saber se existe próxima página
 	var appointments []models.Appointment
-	query := r.db.Where("provider_id = ?", providerID)
+	if err := query.Order(order).Limit(limit + 1).Find(&appointments).Error; err != nil {
+		return nil, err
+	}
 
-	if status != "" {
-		query = query.Where("status = ?", status)
+	page := &AppointmentPage{Total: total}
+	if len(appointments) > limit {
+		appointments = appointments[:limit]
+		last := appointments[limit-1]
+		page.NextCursor = &AppointmentCursor{Date: last.Date, ID: last.ID}
 	}
+	page.Appointments = appointments
+
+	return page, nil
+}
 
-	if err := query.Order("date DESC").Find(&appointments).Error; err != nil {
+// FindDetailByID busca um agendamento com cliente e prestadora carregados
+func (r *AppointmentRepository) FindDetailByID(id uint) (*models.Appointment, error) {
+	var appointment models.Appointment
+	err := r.db.Preload("User").Preload("Provider").Preload("Provider.ProviderProfile").First(&appointment, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAppointmentNotFound
+		}
 		return nil, err
 	}
+	return &appointment, nil
+}
+
+// UpdateStatus atualiza o status de um agendamento e grava os eventos do histórico
+func (r *AppointmentRepository) UpdateStatus(id uint, status models.AppointmentStatus, events ...*models.AppointmentEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Appointment{}).Where("id = ?", id).Update("status", status).Error; err != nil {
+			return err
+		}
+		return appendEvents(tx, events...)
+	})
+}
 
+// TransitionStatus muda o status do agendamento de from para to e grava os eventos do
+// histórico. Retorna ErrAppointmentStatusChanged se o agendamento já não estiver em from.
+func (r *AppointmentRepository) TransitionStatus(id uint, from, to models.AppointmentStatus, events ...*models.AppointmentEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{"status": to}
+		if to == models.AppointmentStatusCompleted {
+			updates["completed_at"] = time.Now()
+		}
+		result := tx.Model(&models.Appointment{}).Where("id = ? AND status = ?", id, from).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrAppointmentStatusChanged
+		}
+		return appendEvents(tx, events...)
+	})
+}
+
+// GetProviderSchedule retorna os agendamentos ativos da prestadora que se sobrepõem ao intervalo [from, to)
+func (r *AppointmentRepository) GetProviderSchedule(providerID uint, from, to time.Time) ([]models.Appointment, error) {
+	return findProviderConflicts(r.db, providerID, from, to, 0)
+}
+
+// UpdateVisit persiste o status e os dados de check-in e check-out de um agendamento
+// junto com os eventos do histórico
+func (r *AppointmentRepository) UpdateVisit(appointment *models.Appointment, events ...*models.AppointmentEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Appointment{}).Where("id = ?", appointment.ID).Updates(map[string]interface{}{
+			"status":              appointment.Status,
+			"checked_in_at":       appointment.CheckedInAt,
+			"check_in_latitude":   appointment.CheckInLatitude,
+			"check_in_longitude":  appointment.CheckInLongitude,
+			"checked_out_at":      appointment.CheckedOutAt,
+			"check_out_latitude":  appointment.CheckOutLatitude,
+			"check_out_longitude": appointment.CheckOutLongitude,
+			"actual_duration":     appointment.ActualDuration,
+			"overtime_minutes":    appointment.OvertimeMinutes,
+			"overtime_charge":     appointment.OvertimeCharge,
+			"completed_at":        appointment.CompletedAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return appendEvents(tx, events...)
+	})
+}
+
+// Cancel persiste o cancelamento de um agendamento com os eventos do histórico e, quando
+// feito pela prestadora, incrementa seu contador de cancelamentos. O reembolso fica
+// pendente até ser processado. Retorna ErrAppointmentStatusChanged se o agendamento já
+// não estiver pendente ou confirmado.
+func (r *AppointmentRepository) Cancel(appointment *models.Appointment, events ...*models.AppointmentEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Appointment{}).
+			Where("id = ? AND status IN ?", appointment.ID, []models.AppointmentStatus{models.AppointmentStatusPending, models.AppointmentStatusConfirmed}).
+			Updates(map[string]interface{}{
+				"status":              appointment.Status,
+				"cancelled_at":        appointment.CancelledAt,
+				"cancelled_by":        appointment.CancelledBy,
+				"cancellation_reason": appointment.CancellationReason,
+				"cancellation_fee":    appointment.CancellationFee,
+				// Gravado junto com o cancelamento para que uma falha no reembolso seja refeita
+				"cancellation_refund_pending": true,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrAppointmentStatusChanged
+		}
+		if err := appendEvents(tx, events...); err != nil {
+			return err
+		}
+
+		if appointment.CancelledBy == nil || *appointment.CancelledBy != appointment.ProviderID {
+			return nil
+		}
+
+		return tx.Model(&models.ProviderProfile{}).
+			Where("user_id = ?", appointment.ProviderID).
+			UpdateColumn("provider_cancellations", gorm.Expr("provider_cancellations + 1")).Error
+	})
+}
+
+// GetPendingCancellationRefunds retorna os agendamentos cancelados até before cujo
+// reembolso ainda está pendente
+func (r *AppointmentRepository) GetPendingCancellationRefunds(before time.Time) ([]models.Appointment, error) {
+	var appointments []models.Appointment
+	err := r.db.Where("status = ? AND cancellation_refund_pending = ? AND cancelled_at <= ?", models.AppointmentStatusCancelled, true, before).
+		Find(&appointments).Error
+	if err != nil {
+		return nil, err
+	}
 	return appointments, nil
 }
 
-// UpdateStatus atualiza o status de um agendamento
-func (r *AppointmentRepository) UpdateStatus(id uint, status models.AppointmentStatus) error {
-	return r.db.Model(&models.Appointment{}).Where("id = ?", id).Update("status", status).Error
+// ClearCancellationRefund marca o reembolso do cancelamento como processado
+func (r *AppointmentRepository) ClearCancellationRefund(id uint) error {
+	return r.db.Model(&models.Appointment{}).Where("id = ?", id).Update("cancellation_refund_pending", false).Error
+}
+
+// RateClient registra a avaliação do cliente feita pela prestadora e atualiza a média do cliente
+func (r *AppointmentRepository) RateClient(appointment *models.Appointment, rating int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Appointment{}).
+			Where("id = ? AND client_rating IS NULL", appointment.ID).
+			Update("client_rating", rating)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrClientAlreadyRated
+		}
+		appointment.ClientRating = &rating
+
+		return tx.Model(&models.User{}).Where("id = ?", appointment.UserID).Updates(map[string]interface{}{
+			"rating":       gorm.Expr("(rating * rating_count + ?) / (rating_count + 1)", rating),
+			"rating_count": gorm.Expr("rating_count + 1"),
+		}).Error
+	})
 }
 
 // GetAvailableProviders retorna as prestadoras disponíveis para um determinado horário
@@ -102,3 +377,131 @@ func (r *AppointmentRepository) GetAvailableProviders(date time.Time, service st
 
 	return providers, nil
 }
+
+// lockProvider bloqueia a linha da prestadora até o fim da transação,
+// serializando as alterações de agenda de uma mesma prestadora
+func lockProvider(tx *gorm.DB, providerID uint) error {
+	var provider models.User
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&provider, providerID).Error
+}
+
+// findProviderConflicts retorna os agendamentos ativos da prestadora que se sobrepõem
+// ao intervalo [start, end), ignorando o agendamento excludeID
+func findProviderConflicts(tx *gorm.DB, providerID uint, start, end time.Time, excludeID uint) ([]models.Appointment, error) {
+	candidates, err := findProviderCandidates(tx, providerID, start, end, excludeID)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []models.Appointment
+	for _, a := range candidates {
+		if a.StartsAt().Before(end) && start.Before(a.EndsAt()) {
+			conflicts = append(conflicts, a)
+		}
+	}
+	return conflicts, nil
+}
+
+// findScheduleConflicts retorna os agendamentos ativos da prestadora que impedem o
+// agendamento informado, respeitando o intervalo mínimo entre atendimentos
+func findScheduleConflicts(tx *gorm.DB, appointment *models.Appointment, excludeID uint, gap ScheduleGap) ([]models.Appointment, error) {
+	candidate := AppointmentScheduleItem(appointment)
+	others, err := findProviderCandidates(tx, appointment.ProviderID, candidate.Start, candidate.End, excludeID)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []models.Appointment
+	for i := range others {
+		blocked, err := gap.Blocks(AppointmentScheduleItem(&others[i]), candidate)
+		if err != nil {
+			return nil, err
+		}
+		if blocked {
+			conflicts = append(conflicts, others[i])
+		}
+	}
+	return conflicts, nil
+}
+
+// findProviderCandidates retorna os agendamentos ativos da prestadora próximos de [start, end).
+// Date guarda apenas o dia; a busca usa folga de um dia e os horários são comparados em Go.
+func findProviderCandidates(tx *gorm.DB, providerID uint, start, end time.Time, excludeID uint) ([]models.Appointment, error) {
+	var candidates []models.Appointment
+	err := tx.Where("provider_id = ? AND id <> ? AND status <> ?", providerID, excludeID, models.AppointmentStatusCancelled).
+		Where("date BETWEEN ? AND ?", start.AddDate(0, 0, -1), end.AddDate(0, 0, 1)).
+		Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+	return candidates, nil
+}
+
+// ensureProviderAvailable retorna ErrSlotUnavailable se o horário do agendamento conflitar,
+// já contado o intervalo mínimo entre atendimentos, com outro agendamento da prestadora,
+// com uma vaga reservada a outro cliente ou com um compromisso de calendário externo
+func ensureProviderAvailable(tx *gorm.DB, appointment *models.Appointment, excludeID uint, travel TravelTimeEstimator) error {
+	gap, err := loadScheduleGap(tx, appointment.ProviderID, travel)
+	if err != nil {
+		return err
+	}
+
+	conflicts, err := findScheduleConflicts(tx, appointment, excludeID, gap)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return ErrSlotUnavailable
+	}
+
+	// Reservas e compromissos externos não têm endereço: vale apenas o intervalo fixo
+	start, end := appointment.StartsAt().Add(-gap.Buffer), appointment.EndsAt().Add(gap.Buffer)
+
+	held, err := hasActiveHold(tx, appointment.ProviderID, start, end, appointment.UserID, time.Now())
+	if err != nil {
+		return err
+	}
+	if held {
+		return ErrSlotUnavailable
+	}
+
+	external, err := findExternalBusy(tx, appointment.ProviderID, start, end)
+	if err != nil {
+		return err
+	}
+	if len(external) > 0 {
+		return ErrSlotUnavailable
+	}
+
+	return nil
+}
+
+// applyInstantBookingCap devolve o agendamento à confirmação manual quando a prestadora já
+// atingiu o limite de reservas instantâneas no dia do atendimento. Deve rodar com a
+// prestadora bloqueada por lockProvider.
+func applyInstantBookingCap(tx *gorm.DB, appointment *models.Appointment) error {
+	var profile models.ProviderProfile
+	err := tx.Select("instant_booking_daily_cap").Where("user_id = ?", appointment.ProviderID).First(&profile).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil || profile.InstantBookingDailyCap <= 0 {
+		return err
+	}
+
+	day := time.Date(appointment.Date.Year(), appointment.Date.Month(), appointment.Date.Day(), 0, 0, 0, 0, appointment.Date.Location())
+	var count int64
+	err = tx.Model(&models.Appointment{}).
+		Where("provider_id = ? AND instant_booked = ? AND status <> ?", appointment.ProviderID, true, models.AppointmentStatusCancelled).
+		Where("date >= ? AND date < ?", day, day.AddDate(0, 0, 1)).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+
+	if int(count) >= profile.InstantBookingDailyCap {
+		appointment.InstantBooked = false
+		appointment.Status = models.AppointmentStatusPending
+	}
+	return nil
+}
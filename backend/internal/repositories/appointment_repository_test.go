@@ -0,0 +1,239 @@
+package repositories
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/testutil"
+)
+
+func TestCancelCountsProviderCancellations(t *testing.T) {
+	tests := []struct {
+		name      string
+		byClient  bool
+		wantCount int
+	}{
+		{"pelo cliente", true, 0},
+		{"pela prestadora", false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			client := testutil.CreateUser(t, db, models.UserTypeClient)
+			provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+			appointment := testutil.CreateAppointment(t, db, models.Appointment{
+				UserID: client.ID, ProviderID: provider.ID, Status: models.AppointmentStatusConfirmed,
+				Date: time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC), Price: 200,
+			})
+
+			cancelledBy := provider.ID
+			if tt.byClient {
+				cancelledBy = client.ID
+			}
+			now := time.Now()
+			appointment.Status = models.AppointmentStatusCancelled
+			appointment.CancelledAt = &now
+			appointment.CancelledBy = &cancelledBy
+			appointment.CancellationReason = "imprevisto"
+			appointment.CancellationFee = 50
+			if err := NewAppointmentRepository(db, nil).Cancel(appointment); err != nil {
+				t.Fatalf("Cancel: %v", err)
+			}
+
+			var stored models.Appointment
+			db.First(&stored, appointment.ID)
+			if stored.Status != models.AppointmentStatusCancelled || stored.CancellationFee != 50 ||
+				stored.CancelledBy == nil || *stored.CancelledBy != cancelledBy || stored.CancellationReason != "imprevisto" {
+				t.Errorf("agendamento gravado %+v; want cancelado por %d com taxa 50", stored, cancelledBy)
+			}
+			var profile models.ProviderProfile
+			db.Where("user_id = ?", provider.ID).First(&profile)
+			if profile.ProviderCancellations != tt.wantCount {
+				t.Errorf("cancelamentos da prestadora = %d, want %d", profile.ProviderCancellations, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestCancelOnlyFromPendingOrConfirmed(t *testing.T) {
+	tests := []struct {
+		name       string
+		stored     models.AppointmentStatus // Status gravado quando o cancelamento chega
+		wantErr    error
+		wantStatus models.AppointmentStatus
+		wantEvents int64
+	}{
+		{"pendente", models.AppointmentStatusPending, nil, models.AppointmentStatusCancelled, 1},
+		{"confirmado", models.AppointmentStatusConfirmed, nil, models.AppointmentStatusCancelled, 1},
+		{"check-in concorrente", models.AppointmentStatusInProgress, ErrAppointmentStatusChanged, models.AppointmentStatusInProgress, 0},
+		{"concluído", models.AppointmentStatusCompleted, ErrAppointmentStatusChanged, models.AppointmentStatusCompleted, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			client := testutil.CreateUser(t, db, models.UserTypeClient)
+			provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+			appointment := testutil.CreateAppointment(t, db, models.Appointment{
+				UserID: client.ID, ProviderID: provider.ID, Status: tt.stored,
+				Date: time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC),
+			})
+
+			// O cancelamento foi decidido sobre uma leitura anterior do agendamento
+			now := time.Now()
+			appointment.Status = models.AppointmentStatusCancelled
+			appointment.CancelledAt = &now
+			appointment.CancelledBy = &client.ID
+			appointment.CancellationFee = 50
+			event := &models.AppointmentEvent{AppointmentID: appointment.ID, ActorID: &client.ID, Type: models.AppointmentEventCancelled}
+			err := NewAppointmentRepository(db, nil).Cancel(appointment, event)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Cancel: %v, want %v", err, tt.wantErr)
+			}
+
+			var stored models.Appointment
+			db.First(&stored, appointment.ID)
+			var events int64
+			db.Model(&models.AppointmentEvent{}).Where("appointment_id = ?", appointment.ID).Count(&events)
+			if stored.Status != tt.wantStatus || events != tt.wantEvents {
+				t.Errorf("status %s com %d eventos; want %s com %d", stored.Status, events, tt.wantStatus, tt.wantEvents)
+			}
+		})
+	}
+}
+
+func TestListPaginatesWithCursor(t *testing.T) {
+	db := testutil.NewDB(t)
+	repo := NewAppointmentRepository(db, nil)
+	client := testutil.CreateUser(t, db, models.UserTypeClient)
+	provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+	other := testutil.CreateUser(t, db, models.UserTypeClient)
+
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC) }
+	create := func(userID uint, date time.Time, status models.AppointmentStatus) uint {
+		return testutil.CreateAppointment(t, db, models.Appointment{
+			UserID: userID, ProviderID: provider.ID, Date: date, Time: "09:00", Status: status,
+		}).ID
+	}
+	first := create(client.ID, day(2), models.AppointmentStatusConfirmed)
+	second := create(client.ID, day(5), models.AppointmentStatusConfirmed)
+	third := create(client.ID, day(5), models.AppointmentStatusConfirmed)
+	create(client.ID, day(8), models.AppointmentStatusCancelled)
+	fourth := create(client.ID, day(9), models.AppointmentStatusConfirmed)
+	create(other.ID, day(6), models.AppointmentStatusConfirmed)
+
+	// Do mais recente para o mais antigo, com o ID desempatando o mesmo dia
+	filter := AppointmentFilter{Statuses: []models.AppointmentStatus{models.AppointmentStatusConfirmed}, Limit: 2}
+	var got []uint
+	for pages := 0; ; pages++ {
+		page, err := repo.GetUserAppointments(client.ID, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 4 {
+			t.Fatalf("total %d, want 4", page.Total)
+		}
+		for _, appointment := range page.Appointments {
+			got = append(got, appointment.ID)
+		}
+		if page.NextCursor == nil || pages > 3 {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	want := []uint{fourth, third, second, first}
+	if len(got) != len(want) {
+		t.Fatalf("agendamentos %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("agendamentos %v, want %v", got, want)
+		}
+	}
+
+	from, to := day(3), day(8)
+	page, err := repo.GetProviderAppointments(provider.ID, AppointmentFilter{From: &from, To: &to})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 4 || len(page.Appointments) != 4 || page.NextCursor != nil {
+		t.Errorf("intervalo de %s a %s: %d de %d agendamentos; want 4 sem próxima página",
+			from.Format("02/01"), to.Format("02/01"), len(page.Appointments), page.Total)
+	}
+}
+
+func TestCreateWithoutConflictAppliesInstantBookingDailyCap(t *testing.T) {
+	db := testutil.NewDB(t)
+	repo := NewAppointmentRepository(db, nil)
+	client := testutil.CreateUser(t, db, models.UserTypeClient)
+	provider := testutil.CreateProvider(t, db, models.ProviderProfile{InstantBookingEnabled: true, InstantBookingDailyCap: 1})
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	book := func(date time.Time, at string) *models.Appointment {
+		appointment := &models.Appointment{
+			UserID: client.ID, ProviderID: provider.ID, Service: "Limpeza", Date: date, Time: at, Duration: 60,
+			Status: models.AppointmentStatusConfirmed, InstantBooked: true,
+		}
+		if err := repo.CreateWithoutConflict(appointment, nil, nil); err != nil {
+			t.Fatalf("CreateWithoutConflict %s: %v", at, err)
+		}
+		return appointment
+	}
+
+	first := book(day, "09:00")
+	if !first.InstantBooked || first.Status != models.AppointmentStatusConfirmed {
+		t.Errorf("primeira reserva %s instant_booked %v; want confirmada", first.Status, first.InstantBooked)
+	}
+	// O limite é por dia de atendimento: a segunda reserva do dia volta para a prestadora
+	second := book(day, "14:00")
+	if second.InstantBooked || second.Status != models.AppointmentStatusPending {
+		t.Errorf("segunda reserva %s instant_booked %v; want pendente", second.Status, second.InstantBooked)
+	}
+	nextDay := book(day.AddDate(0, 0, 1), "09:00")
+	if !nextDay.InstantBooked || nextDay.Status != models.AppointmentStatusConfirmed {
+		t.Errorf("reserva do dia seguinte %s instant_booked %v; want confirmada", nextDay.Status, nextDay.InstantBooked)
+	}
+}
+
+func TestListUpcomingAndPastUseScheduleDay(t *testing.T) {
+	previous := models.ScheduleLocation
+	models.ScheduleLocation = time.FixedZone("BRT", -3*60*60)
+	t.Cleanup(func() { models.ScheduleLocation = previous })
+
+	db := testutil.NewDB(t)
+	client := testutil.CreateUser(t, db, models.UserTypeClient)
+	provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+	yesterday := testutil.CreateAppointment(t, db, models.Appointment{
+		UserID: client.ID, ProviderID: provider.ID, Time: "09:00",
+		Date: models.ScheduleTime(time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC), 0, 0),
+	})
+	today := testutil.CreateAppointment(t, db, models.Appointment{
+		UserID: client.ID, ProviderID: provider.ID, Time: "09:00",
+		Date: models.ScheduleTime(time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC), 0, 0),
+	})
+
+	// 01:00 em UTC já é dia 10, mas ainda são 22:00 do dia 9 no fuso da agenda
+	repo := NewAppointmentRepository(db, nil)
+	repo.now = func() time.Time { return time.Date(2026, 3, 10, 1, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name   string
+		filter AppointmentFilter
+		want   uint
+	}{
+		{"próximos", AppointmentFilter{Upcoming: true}, today.ID},
+		{"anteriores", AppointmentFilter{Past: true}, yesterday.ID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := repo.GetUserAppointments(client.ID, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Appointments) != 1 || page.Appointments[0].ID != tt.want {
+				t.Errorf("agendamentos %+v; want apenas %d", page.Appointments, tt.want)
+			}
+		})
+	}
+}
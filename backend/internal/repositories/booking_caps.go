@@ -0,0 +1,95 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDailyBookingCapReached  = errors.New("a prestadora atingiu o limite de agendamentos do dia")
+	ErrWeeklyBookingCapReached = errors.New("a prestadora atingiu o limite de agendamentos da semana")
+)
+
+// BookingCounts é o número de agendamentos ativos da prestadora no dia e na semana de uma data
+type BookingCounts struct {
+	Day  int
+	Week int
+}
+
+// dayStart retorna o início do dia de t
+func dayStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// weekStart retorna a segunda-feira da semana de t
+func weekStart(t time.Time) time.Time {
+	day := dayStart(t)
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// CountBookings conta os agendamentos não cancelados da prestadora no dia e na semana de date
+func (r *AppointmentRepository) CountBookings(providerID uint, date time.Time) (BookingCounts, error) {
+	return countBookings(r.db, providerID, date, 0)
+}
+
+// countBookings conta os agendamentos ativos da prestadora, ignorando excludeID (o próprio
+// agendamento, quando ele muda de data)
+func countBookings(tx *gorm.DB, providerID uint, date time.Time, excludeID uint) (BookingCounts, error) {
+	day, week := dayStart(date), weekStart(date)
+
+	var dates []time.Time
+	err := tx.Model(&models.Appointment{}).
+		Where("provider_id = ? AND id <> ? AND status <> ?", providerID, excludeID, models.AppointmentStatusCancelled).
+		Where("date >= ? AND date < ?", week, week.AddDate(0, 0, 7)).
+		Pluck("date", &dates).Error
+	if err != nil {
+		return BookingCounts{}, err
+	}
+
+	counts := BookingCounts{Week: len(dates)}
+	for _, d := range dates {
+		if !d.Before(day) && d.Before(day.AddDate(0, 0, 1)) {
+			counts.Day++
+		}
+	}
+	return counts, nil
+}
+
+// IsBookingCapError indica se err é o limite diário ou semanal de agendamentos da prestadora
+func IsBookingCapError(err error) bool {
+	return errors.Is(err, ErrDailyBookingCapReached) || errors.Is(err, ErrWeeklyBookingCapReached)
+}
+
+// enforceBookingCaps retorna erro quando a prestadora já atingiu o limite diário ou semanal
+// de agendamentos na data do atendimento, sem contar excludeID. Deve rodar com a prestadora
+// bloqueada por lockProvider.
+func enforceBookingCaps(tx *gorm.DB, appointment *models.Appointment, excludeID uint) error {
+	var profile models.ProviderProfile
+	err := tx.Select("daily_booking_cap", "weekly_booking_cap").Where("user_id = ?", appointment.ProviderID).First(&profile).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if profile.DailyBookingCap <= 0 && profile.WeeklyBookingCap <= 0 {
+		return nil
+	}
+
+	counts, err := countBookings(tx, appointment.ProviderID, appointment.Date, excludeID)
+	if err != nil {
+		return err
+	}
+	if profile.DailyBookingCap > 0 && counts.Day >= profile.DailyBookingCap {
+		return fmt.Errorf("%w (%d por dia)", ErrDailyBookingCapReached, profile.DailyBookingCap)
+	}
+	if profile.WeeklyBookingCap > 0 && counts.Week >= profile.WeeklyBookingCap {
+		return fmt.Errorf("%w (%d por semana)", ErrWeeklyBookingCapReached, profile.WeeklyBookingCap)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package repositories
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/testutil"
+	"gorm.io/gorm"
+)
+
+func TestCreateWithoutConflictEnforcesBookingCaps(t *testing.T) {
+	// Terça-feira; a semana vai de segunda, 9, a domingo, 15
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		profile models.ProviderProfile
+		date    time.Time
+		wantErr error
+	}{
+		{"sem limites", models.ProviderProfile{}, day, nil},
+		{"limite diário no mesmo dia", models.ProviderProfile{DailyBookingCap: 2}, day, ErrDailyBookingCapReached},
+		{"limite diário em outro dia", models.ProviderProfile{DailyBookingCap: 2}, day.AddDate(0, 0, 1), nil},
+		{"limite semanal na mesma semana", models.ProviderProfile{WeeklyBookingCap: 3}, day.AddDate(0, 0, 5), ErrWeeklyBookingCapReached},
+		{"limite semanal na semana seguinte", models.ProviderProfile{WeeklyBookingCap: 3}, day.AddDate(0, 0, 6), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			client := testutil.CreateUser(t, db, models.UserTypeClient)
+			provider := testutil.CreateProvider(t, db, tt.profile)
+			// Dois agendamentos no dia e um na segunda-feira; o cancelado não conta
+			for _, booked := range []struct {
+				date   time.Time
+				at     string
+				status models.AppointmentStatus
+			}{
+				{day, "08:00", models.AppointmentStatusConfirmed},
+				{day, "10:00", models.AppointmentStatusPending},
+				{day, "16:00", models.AppointmentStatusCancelled},
+				{day.AddDate(0, 0, -1), "08:00", models.AppointmentStatusConfirmed},
+			} {
+				testutil.CreateAppointment(t, db, models.Appointment{
+					UserID: client.ID, ProviderID: provider.ID, Date: booked.date, Time: booked.at, Duration: 60, Status: booked.status,
+				})
+			}
+
+			appointment := &models.Appointment{
+				UserID: client.ID, ProviderID: provider.ID, Service: "Limpeza", Date: tt.date, Time: "14:00", Duration: 60,
+				Status: models.AppointmentStatusPending,
+			}
+			err := NewAppointmentRepository(db, nil).CreateWithoutConflict(appointment, nil, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CreateWithoutConflict: %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBookingCapsApplyToEveryBookingPath(t *testing.T) {
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		book   func(t *testing.T, db *gorm.DB, booked, other *models.Appointment) error
+		capped bool
+	}{
+		{"remarcação para o dia lotado", func(t *testing.T, db *gorm.DB, booked, other *models.Appointment) error {
+			return acceptReschedule(t, db, other, day)
+		}, true},
+		{"remarcação dentro do próprio dia", func(t *testing.T, db *gorm.DB, booked, other *models.Appointment) error {
+			return acceptReschedule(t, db, booked, day)
+		}, false},
+		{"ocorrência de série", func(t *testing.T, db *gorm.DB, booked, other *models.Appointment) error {
+			series := &models.AppointmentSeries{
+				UserID: booked.UserID, ProviderID: booked.ProviderID, RRule: "FREQ=WEEKLY", StartDate: day,
+				Time: "14:00", Service: "Limpeza", Duration: 60,
+			}
+			if err := db.Create(series).Error; err != nil {
+				t.Fatal(err)
+			}
+			occurrence := &models.Appointment{
+				UserID: booked.UserID, ProviderID: booked.ProviderID, Service: "Limpeza", Status: models.AppointmentStatusPending,
+				Date: day, Time: "14:00", Duration: 60, SeriesID: &series.ID, OccurrenceDate: &day,
+			}
+			created, _, err := NewSeriesRepository(db, nil).MaterializeOccurrence(occurrence)
+			if created {
+				t.Error("ocorrência criada acima do limite")
+			}
+			var conflicts []models.SeriesConflict
+			db.Where("series_id = ?", series.ID).Find(&conflicts)
+			if len(conflicts) != 1 || conflicts[0].Reason == "" {
+				t.Errorf("conflitos = %+v; want um conflito com o motivo", conflicts)
+			}
+			return err
+		}, true},
+		{"vaga da lista de espera", func(t *testing.T, db *gorm.DB, booked, other *models.Appointment) error {
+			slot := time.Date(2026, 3, 10, 14, 0, 0, 0, time.UTC)
+			offer := &models.WaitlistOffer{
+				EntryID: 1, UserID: booked.UserID, ProviderID: booked.ProviderID, SlotStart: slot, SlotEnd: slot.Add(time.Hour),
+				Duration: 60, ExpiresAt: time.Now().Add(time.Hour), Status: models.WaitlistOfferStatusPending,
+			}
+			if err := db.Create(offer).Error; err != nil {
+				t.Fatal(err)
+			}
+			appointment := &models.Appointment{
+				UserID: booked.UserID, ProviderID: booked.ProviderID, Service: "Limpeza", Status: models.AppointmentStatusPending,
+				Date: day, Time: "14:00", Duration: 60,
+			}
+			return NewWaitlistRepository(db, nil).AcceptOffer(offer, appointment, time.Now())
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			client := testutil.CreateUser(t, db, models.UserTypeClient)
+			provider := testutil.CreateProvider(t, db, models.ProviderProfile{DailyBookingCap: 1})
+			booked := testutil.CreateAppointment(t, db, models.Appointment{
+				UserID: client.ID, ProviderID: provider.ID, Status: models.AppointmentStatusConfirmed,
+				Date: day, Time: "08:00", Duration: 60,
+			})
+			other := testutil.CreateAppointment(t, db, models.Appointment{
+				UserID: client.ID, ProviderID: provider.ID, Status: models.AppointmentStatusConfirmed,
+				Date: day.AddDate(0, 0, 1), Time: "08:00", Duration: 60,
+			})
+
+			err := tt.book(t, db, booked, other)
+			if IsBookingCapError(err) != tt.capped {
+				t.Fatalf("erro %v; want limite atingido = %v", err, tt.capped)
+			}
+			if !tt.capped && err != nil {
+				t.Fatalf("erro inesperado: %v", err)
+			}
+		})
+	}
+}
+
+// acceptReschedule aceita a remarcação do agendamento para as 14h do dia informado
+func acceptReschedule(t *testing.T, db *gorm.DB, appointment *models.Appointment, day time.Time) error {
+	t.Helper()
+	request := &models.RescheduleRequest{
+		AppointmentID: appointment.ID, ProposedBy: appointment.UserID, Date: day, Time: "14:00",
+		PreviousDate: appointment.Date, PreviousTime: appointment.Time, Status: models.RescheduleStatusPending,
+	}
+	if err := db.Create(request).Error; err != nil {
+		t.Fatal(err)
+	}
+	moved := *appointment
+	moved.Date, moved.Time = day, "14:00"
+	return NewRescheduleRepository(db, nil).Accept(request, &moved, appointment.ProviderID)
+}
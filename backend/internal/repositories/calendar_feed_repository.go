@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCalendarFeedNotFound = errors.New("feed de calendário não encontrado")
+)
+
+// CalendarFeedMaxEvents limita a quantidade de agendamentos exportados em um feed
+const CalendarFeedMaxEvents = 500
+
+type CalendarFeedRepository struct {
+	db *gorm.DB
+}
+
+func NewCalendarFeedRepository(db *gorm.DB) *CalendarFeedRepository {
+	return &CalendarFeedRepository{
+		db: db,
+	}
+}
+
+// Create cria o feed de um usuário
+func (r *CalendarFeedRepository) Create(feed *models.CalendarFeed) error {
+	return r.db.Create(feed).Error
+}
+
+// Update atualiza o token e as opções do feed
+func (r *CalendarFeedRepository) Update(feed *models.CalendarFeed) error {
+	return r.db.Save(feed).Error
+}
+
+// FindByUser busca o feed de um usuário
+func (r *CalendarFeedRepository) FindByUser(userID uint) (*models.CalendarFeed, error) {
+	return r.findBy("user_id = ?", userID)
+}
+
+// FindByToken busca o feed pelo token secreto
+func (r *CalendarFeedRepository) FindByToken(token string) (*models.CalendarFeed, error) {
+	return r.findBy("token = ?", token)
+}
+
+// GetAppointments retorna os agendamentos do usuário, como cliente ou prestadora,
+// a partir de from. Os cancelados também são retornados para aparecerem como eventos cancelados.
+func (r *CalendarFeedRepository) GetAppointments(userID uint, from time.Time) ([]models.Appointment, error) {
+	var appointments []models.Appointment
+	err := r.db.Preload("User").Preload("Provider").
+		Where("(user_id = ? OR provider_id = ?) AND date >= ?", userID, userID, from).
+		Order("date ASC, time ASC").
+		Limit(CalendarFeedMaxEvents).
+		Find(&appointments).Error
+	if err != nil {
+		return nil, err
+	}
+	return appointments, nil
+}
+
+func (r *CalendarFeedRepository) findBy(query string, arg interface{}) (*models.CalendarFeed, error) {
+	var feed models.CalendarFeed
+	if err := r.db.Where(query, arg).First(&feed).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCalendarFeedNotFound
+		}
+		return nil, err
+	}
+	return &feed, nil
+}
@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCommissionRuleNotFound = errors.New("regra de comissão não encontrada")
+	ErrSettlementNotFound     = errors.New("repasse não encontrado")
+)
+
+type CommissionRepository struct {
+	db *gorm.DB
+}
+
+func NewCommissionRepository(db *gorm.DB) *CommissionRepository {
+	return &CommissionRepository{
+		db: db,
+	}
+}
+
+// CreateRule cadastra uma regra de comissão
+func (r *CommissionRepository) CreateRule(rule *models.CommissionRule) error {
+	rule.Version = 1
+	return r.db.Create(rule).Error
+}
+
+// FindRuleByID busca uma regra de comissão pelo ID
+func (r *CommissionRepository) FindRuleByID(id uint) (*models.CommissionRule, error) {
+	var rule models.CommissionRule
+	if err := r.db.First(&rule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCommissionRuleNotFound
+		}
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// UpdateRule salva a regra e incrementa sua versão. Repasses já feitos mantêm a cópia
+// da versão anterior.
+func (r *CommissionRepository) UpdateRule(rule *models.CommissionRule) error {
+	result := r.db.Model(&models.CommissionRule{}).Where("id = ?", rule.ID).Updates(map[string]interface{}{
+		"name":          rule.Name,
+		"active":        rule.Active,
+		"percent_bps":   rule.PercentBps,
+		"fixed_amount":  rule.FixedAmount,
+		"city":          rule.City,
+		"provider_tier": rule.ProviderTier,
+		"starts_at":     rule.StartsAt,
+		"ends_at":       rule.EndsAt,
+		"version":       gorm.Expr("version + 1"),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCommissionRuleNotFound
+	}
+	return r.db.First(rule, rule.ID).Error
+}
+
+// GetRules lista todas as regras de comissão, incluindo as desativadas
+func (r *CommissionRepository) GetRules() ([]models.CommissionRule, error) {
+	var rules []models.CommissionRule
+	err := r.db.Order("active DESC, id").Find(&rules).Error
+	return rules, err
+}
+
+// GetActiveRules retorna as regras ativas cujo período inclui at
+func (r *CommissionRepository) GetActiveRules(at time.Time) ([]models.CommissionRule, error) {
+	var rules []models.CommissionRule
+	err := r.db.Where("active = ?", true).
+		Where("starts_at IS NULL OR starts_at <= ?", at).
+		Where("ends_at IS NULL OR ends_at > ?", at).
+		Order("id").
+		Find(&rules).Error
+	return rules, err
+}
+
+// FindSettlementByPayment busca a divisão do repasse de um pagamento
+func (r *CommissionRepository) FindSettlementByPayment(paymentID uint) (*models.PaymentSettlement, error) {
+	var settlement models.PaymentSettlement
+	if err := r.db.Where("payment_id = ?", paymentID).First(&settlement).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSettlementNotFound
+		}
+		return nil, err
+	}
+	return &settlement, nil
+}
+
+// GetSettlementsByProvider retorna os repasses da prestadora feitos no período [from, to)
+func (r *CommissionRepository) GetSettlementsByProvider(providerID uint, from, to time.Time) ([]models.PaymentSettlement, error) {
+	var settlements []models.PaymentSettlement
+	err := r.db.Where("provider_id = ? AND created_at >= ? AND created_at < ?", providerID, from, to).
+		Order("created_at").
+		Find(&settlements).Error
+	return settlements, err
+}
@@ -0,0 +1,361 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrEscrowNotHeld          = errors.New("o valor deste pagamento não está em custódia")
+	ErrEscrowReleased         = errors.New("o valor deste pagamento já foi repassado à prestadora")
+	ErrPaymentDisputeExists   = errors.New("já existe uma disputa para este agendamento")
+	ErrPaymentDisputeNotFound = errors.New("disputa não encontrada")
+)
+
+// ScheduleEscrowRelease define quando o valor retido será repassado à prestadora.
+// Pagamentos congelados por disputa não são alterados.
+func (r *PaymentRepository) ScheduleEscrowRelease(paymentID uint, releaseAt time.Time) error {
+	return r.db.Model(&models.Payment{}).
+		Where("id = ? AND escrow_status = ?", paymentID, models.EscrowStatusHeld).
+		Update("escrow_release_at", releaseAt).Error
+}
+
+// FreezeEscrow congela o valor retido e grava os eventos do histórico. Retorna falso, sem
+// gravar os eventos, se o pagamento não estava em custódia.
+func (r *PaymentRepository) FreezeEscrow(paymentID uint, events ...*models.AppointmentEvent) (bool, error) {
+	frozen := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		frozen, err = freezeEscrow(tx, paymentID)
+		if err != nil || !frozen {
+			return err
+		}
+		return appendEvents(tx, events...)
+	})
+	return frozen, err
+}
+
+// Chargeback registra a contestação da compra no emissor do cartão, com o valor debitado
+// pelo gateway e seus lançamentos, e congela o valor retido, se ainda estiver em custódia.
+// Só a primeira contestação do pagamento é lançada; o evento frozen só é gravado quando o
+// valor é congelado.
+func (r *PaymentRepository) Chargeback(paymentID uint, amount int64, ledger []*models.LedgerTransaction, chargeback, frozen *models.AppointmentEvent) (bool, error) {
+	if err := checkAllBalanced(ledger); err != nil {
+		return false, err
+	}
+
+	wasFrozen := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Payment{}).
+			Where("id = ? AND chargeback_amount = 0", paymentID).
+			Update("chargeback_amount", amount)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			if err := postLedger(tx, ledger...); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		wasFrozen, err = freezeEscrow(tx, paymentID)
+		if err != nil {
+			return err
+		}
+		if !wasFrozen {
+			frozen = nil
+		}
+		return appendEvents(tx, chargeback, frozen)
+	})
+	return wasFrozen, err
+}
+
+func freezeEscrow(tx *gorm.DB, paymentID uint) (bool, error) {
+	result := tx.Model(&models.Payment{}).
+		Where("id = ? AND escrow_status = ?", paymentID, models.EscrowStatusHeld).
+		Update("escrow_status", models.EscrowStatusFrozen)
+	return result.RowsAffected > 0, result.Error
+}
+
+// UnfreezeEscrow devolve à custódia um valor congelado, desde que não haja disputa aberta
+// sobre ele, e grava os eventos do histórico. Retorna falso, sem gravar os eventos, se o
+// pagamento não estava congelado ou está em disputa.
+func (r *PaymentRepository) UnfreezeEscrow(paymentID uint, events ...*models.AppointmentEvent) (bool, error) {
+	unfrozen := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Payment{}).
+			Where("id = ? AND escrow_status = ?", paymentID, models.EscrowStatusFrozen).
+			Where("NOT EXISTS (?)", r.db.Model(&models.PaymentDispute{}).Select("1").
+				Where("payment_disputes.payment_id = payments.id AND payment_disputes.status = ?", models.PaymentDisputeStatusOpen)).
+			Update("escrow_status", models.EscrowStatusHeld)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		unfrozen = true
+		return appendEvents(tx, events...)
+	})
+	return unfrozen, err
+}
+
+// GetDueEscrowReleases retorna os pagamentos retidos cujo repasse já venceu
+func (r *PaymentRepository) GetDueEscrowReleases(now time.Time) ([]models.Payment, error) {
+	var payments []models.Payment
+	err := r.db.Where("escrow_status = ? AND escrow_release_at <= ?", models.EscrowStatusHeld, now).
+		Find(&payments).Error
+	if err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+// EscrowReleaseRecord é gravado na mesma transação do repasse: a divisão do valor pela
+// comissão, os lançamentos no razão e os eventos do histórico
+type EscrowReleaseRecord struct {
+	Settlement *models.PaymentSettlement
+	Ledger     []*models.LedgerTransaction
+	Events     []*models.AppointmentEvent
+}
+
+// ReturnedIncentives é a parte do cupom e do crédito da reserva que volta à plataforma e à
+// carteira do cliente no encerramento da custódia, na proporção do valor reembolsado
+type ReturnedIncentives struct {
+	PromoDiscount int64
+	Credit        int64
+}
+
+// EscrowReleaseRecorder monta o registro do repasse a partir do pagamento já repassado e
+// dos incentivos devolvidos. É chamado dentro da transação do repasse e não deve acessar o banco.
+type EscrowReleaseRecorder func(payment *models.Payment, returned ReturnedIncentives) *EscrowReleaseRecord
+
+// ReleaseDueEscrow repassa à prestadora o saldo não reembolsado de um pagamento retido
+// cujo repasse venceu. Retorna falso se o pagamento foi congelado ou já repassado.
+func (r *PaymentRepository) ReleaseDueEscrow(paymentID uint, now time.Time, record EscrowReleaseRecorder) (*models.Payment, bool, error) {
+	return r.releaseEscrow(paymentID, now, true, record)
+}
+
+// ReleaseEscrowNow encerra a custódia imediatamente, retida ou congelada, repassando à
+// prestadora o saldo não reembolsado. Usado quando o desfecho do atendimento já está decidido.
+func (r *PaymentRepository) ReleaseEscrowNow(paymentID uint, now time.Time, record EscrowReleaseRecorder) (*models.Payment, bool, error) {
+	return r.releaseEscrow(paymentID, now, false, record)
+}
+
+func (r *PaymentRepository) releaseEscrow(paymentID uint, now time.Time, onlyDue bool, record EscrowReleaseRecorder) (*models.Payment, bool, error) {
+	var payment models.Payment
+	released := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Bloqueia o pagamento para não concorrer com reembolsos e disputas
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, paymentID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrPaymentNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if onlyDue {
+			if payment.EscrowStatus != models.EscrowStatusHeld || payment.EscrowReleaseAt == nil || payment.EscrowReleaseAt.After(now) {
+				return nil
+			}
+		} else if payment.EscrowStatus != models.EscrowStatusHeld && payment.EscrowStatus != models.EscrowStatusFrozen {
+			return nil
+		}
+
+		// O cupom e os créditos da reserva estão na custódia junto com o valor pago. A prestadora
+		// recebe deles a mesma fração do pagamento que não foi reembolsada; o restante volta à
+		// plataforma e à carteira do cliente.
+		var incentives struct {
+			PromoDiscount int64
+			CreditApplied int64
+		}
+		err = tx.Model(&models.Appointment{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("promo_discount", "credit_applied").
+			Where("id = ?", payment.AppointmentID).
+			Scan(&incentives).Error
+		if err != nil {
+			return err
+		}
+
+		// Reembolsos pendentes já foram descontados por ReserveRefund
+		kept := min(max(payment.Amount-payment.RefundedAmount, 0), payment.Amount)
+		keptPromo := keptShare(incentives.PromoDiscount, kept, payment.Amount)
+		keptCredit := keptShare(incentives.CreditApplied, kept, payment.Amount)
+		returned := ReturnedIncentives{
+			PromoDiscount: incentives.PromoDiscount - keptPromo,
+			Credit:        incentives.CreditApplied - keptCredit,
+		}
+		payment.ReleasedAmount = kept + keptPromo + keptCredit
+		payment.EscrowStatus = models.EscrowStatusReleased
+		if payment.ReleasedAmount <= 0 {
+			payment.ReleasedAmount = 0
+			payment.EscrowStatus = models.EscrowStatusRefunded
+		}
+		payment.ReleasedAt = &now
+		released = true
+		if err := tx.Model(&payment).Select("escrow_status", "released_amount", "released_at").Updates(&payment).Error; err != nil {
+			return err
+		}
+		if record == nil {
+			return nil
+		}
+		return saveEscrowRelease(tx, record(&payment, returned))
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return &payment, released, nil
+}
+
+// keptShare retorna a parte de amount proporcional a kept de total, arredondada para baixo
+func keptShare(amount, kept, total int64) int64 {
+	if total <= 0 {
+		return 0
+	}
+	return amount * kept / total
+}
+
+// saveEscrowRelease grava a divisão, os lançamentos e os eventos de um repasse
+func saveEscrowRelease(tx *gorm.DB, release *EscrowReleaseRecord) error {
+	if release.Settlement != nil {
+		if err := tx.Create(release.Settlement).Error; err != nil {
+			return err
+		}
+	}
+	if err := checkAllBalanced(release.Ledger); err != nil {
+		return err
+	}
+	if err := postLedger(tx, release.Ledger...); err != nil {
+		return err
+	}
+	return appendEvents(tx, release.Events...)
+}
+
+// ReleaseUnpaidIncentives repassa à prestadora o cupom e o crédito de um agendamento sem
+// pagamento, que ficaram na custódia desde a reserva. Retorna falso se o repasse já foi
+// lançado e ErrIncentivesChanged se os descontos mudaram depois de montado o lançamento.
+func (r *PaymentRepository) ReleaseUnpaidIncentives(appointmentID uint, gross int64, transaction *models.LedgerTransaction, events ...*models.AppointmentEvent) (bool, error) {
+	if err := checkBalanced(transaction); err != nil {
+		return false, err
+	}
+
+	released := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var appointment models.Appointment
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "promo_discount", "credit_applied").
+			First(&appointment, appointmentID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAppointmentNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if appointment.PromoDiscount+appointment.CreditApplied != gross {
+			return ErrIncentivesChanged
+		}
+
+		var existing int64
+		if err := tx.Model(&models.LedgerTransaction{}).Where("reference = ?", transaction.Reference).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return nil
+		}
+
+		released = true
+		if err := postLedger(tx, transaction); err != nil {
+			return err
+		}
+		return appendEvents(tx, events...)
+	})
+	if err != nil {
+		return false, err
+	}
+	return released, nil
+}
+
+// OpenDispute registra a disputa, congela o valor retido e grava os eventos do histórico
+// na mesma transação
+func (r *PaymentRepository) OpenDispute(dispute *models.PaymentDispute, events ...*models.AppointmentEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var payment models.Payment
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, dispute.PaymentID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrPaymentNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if payment.EscrowStatus != models.EscrowStatusHeld {
+			return ErrEscrowNotHeld
+		}
+
+		var count int64
+		if err := tx.Model(&models.PaymentDispute{}).Where("appointment_id = ?", dispute.AppointmentID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrPaymentDisputeExists
+		}
+
+		dispute.Status = models.PaymentDisputeStatusOpen
+		if err := tx.Create(dispute).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&payment).Update("escrow_status", models.EscrowStatusFrozen).Error; err != nil {
+			return err
+		}
+		return appendEvents(tx, events...)
+	})
+}
+
+// FindDisputeByAppointment busca a disputa de um agendamento
+func (r *PaymentRepository) FindDisputeByAppointment(appointmentID uint) (*models.PaymentDispute, error) {
+	var dispute models.PaymentDispute
+	if err := r.db.Where("appointment_id = ?", appointmentID).First(&dispute).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPaymentDisputeNotFound
+		}
+		return nil, err
+	}
+	return &dispute, nil
+}
+
+// CloseDispute grava a resolução de uma disputa aberta. Retorna falso se ela já tiver
+// sido resolvida, para que duas decisões simultâneas não sejam aplicadas.
+func (r *PaymentRepository) CloseDispute(dispute *models.PaymentDispute) (bool, error) {
+	result := r.db.Model(&models.PaymentDispute{}).
+		Where("id = ? AND status = ?", dispute.ID, models.PaymentDisputeStatusOpen).
+		Updates(map[string]interface{}{
+			"status":          models.PaymentDisputeStatusResolved,
+			"refund_amount":   dispute.RefundAmount,
+			"resolved_at":     dispute.ResolvedAt,
+			"resolved_by":     dispute.ResolvedBy,
+			"resolution_note": dispute.ResolutionNote,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	dispute.Status = models.PaymentDisputeStatusResolved
+	return true, nil
+}
+
+// ReopenDispute desfaz uma resolução que não pôde ser aplicada
+func (r *PaymentRepository) ReopenDispute(dispute *models.PaymentDispute) error {
+	dispute.Status = models.PaymentDisputeStatusOpen
+	dispute.RefundAmount = 0
+	dispute.ResolvedAt = nil
+	dispute.ResolvedBy = nil
+	dispute.ResolutionNote = ""
+	return r.db.Model(dispute).
+		Select("status", "refund_amount", "resolved_at", "resolved_by", "resolution_note").
+		Updates(dispute).Error
+}
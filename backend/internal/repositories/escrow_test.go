@@ -0,0 +1,46 @@
+package repositories
+
+import (
+	"testing"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/testutil"
+)
+
+func TestUnfreezeEscrow(t *testing.T) {
+	tests := []struct {
+		name         string
+		escrow       models.EscrowStatus
+		openDispute  bool
+		wantUnfrozen bool
+		wantStatus   models.EscrowStatus
+	}{
+		{"congelado volta à custódia", models.EscrowStatusFrozen, false, true, models.EscrowStatusHeld},
+		{"disputa aberta mantém congelado", models.EscrowStatusFrozen, true, false, models.EscrowStatusFrozen},
+		{"já repassado não muda", models.EscrowStatusReleased, false, false, models.EscrowStatusReleased},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			payment := testutil.CreatePayment(t, db, models.Payment{
+				Amount: 15000, Status: models.PaymentStatusSucceeded, EscrowStatus: tt.escrow,
+			})
+			if tt.openDispute {
+				db.Create(&models.PaymentDispute{
+					AppointmentID: payment.AppointmentID, PaymentID: payment.ID, OpenedBy: payment.UserID,
+					Reason: "serviço incompleto", Status: models.PaymentDisputeStatusOpen,
+				})
+			}
+
+			repo := NewPaymentRepository(db)
+			unfrozen, err := repo.UnfreezeEscrow(payment.ID)
+			if err != nil {
+				t.Fatalf("UnfreezeEscrow: %v", err)
+			}
+			stored, _ := repo.FindByID(payment.ID)
+			if unfrozen != tt.wantUnfrozen || stored.EscrowStatus != tt.wantStatus {
+				t.Errorf("UnfreezeEscrow = %v, status %s; want %v, %s", unfrozen, stored.EscrowStatus, tt.wantUnfrozen, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrExternalCalendarNotFound = errors.New("calendário externo não encontrado")
+)
+
+type ExternalCalendarRepository struct {
+	db *gorm.DB
+}
+
+func NewExternalCalendarRepository(db *gorm.DB) *ExternalCalendarRepository {
+	return &ExternalCalendarRepository{
+		db: db,
+	}
+}
+
+// Create cadastra um calendário externo
+func (r *ExternalCalendarRepository) Create(calendar *models.ExternalCalendar) error {
+	return r.db.Create(calendar).Error
+}
+
+// FindByID busca um calendário externo pelo ID
+func (r *ExternalCalendarRepository) FindByID(id uint) (*models.ExternalCalendar, error) {
+	var calendar models.ExternalCalendar
+	if err := r.db.First(&calendar, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrExternalCalendarNotFound
+		}
+		return nil, err
+	}
+	return &calendar, nil
+}
+
+// GetByProvider retorna os calendários externos de uma prestadora
+func (r *ExternalCalendarRepository) GetByProvider(providerID uint) ([]models.ExternalCalendar, error) {
+	var calendars []models.ExternalCalendar
+	if err := r.db.Where("provider_id = ?", providerID).Order("created_at ASC").Find(&calendars).Error; err != nil {
+		return nil, err
+	}
+	return calendars, nil
+}
+
+// GetSubscribed retorna os calendários externos com URL, que devem ser sincronizados
+func (r *ExternalCalendarRepository) GetSubscribed() ([]models.ExternalCalendar, error) {
+	var calendars []models.ExternalCalendar
+	if err := r.db.Where("url <> ''").Find(&calendars).Error; err != nil {
+		return nil, err
+	}
+	return calendars, nil
+}
+
+// ReplaceBlocks substitui os intervalos ocupados do calendário pelos da última leitura
+func (r *ExternalCalendarRepository) ReplaceBlocks(calendar *models.ExternalCalendar, blocks []models.ExternalBusyBlock, syncedAt time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("calendar_id = ?", calendar.ID).Delete(&models.ExternalBusyBlock{}).Error; err != nil {
+			return err
+		}
+		if len(blocks) > 0 {
+			if err := tx.CreateInBatches(blocks, 200).Error; err != nil {
+				return err
+			}
+		}
+
+		calendar.LastSyncedAt = &syncedAt
+		calendar.LastError = ""
+		return tx.Model(&models.ExternalCalendar{}).Where("id = ?", calendar.ID).Updates(map[string]interface{}{
+			"last_synced_at": syncedAt,
+			"last_error":     "",
+		}).Error
+	})
+}
+
+// SetError registra a falha da última sincronização, mantendo os intervalos já importados
+func (r *ExternalCalendarRepository) SetError(calendar *models.ExternalCalendar, message string) error {
+	calendar.LastError = message
+	return r.db.Model(&models.ExternalCalendar{}).Where("id = ?", calendar.ID).Update("last_error", message).Error
+}
+
+// Delete remove o calendário externo e seus intervalos ocupados
+func (r *ExternalCalendarRepository) Delete(calendar *models.ExternalCalendar) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("calendar_id = ?", calendar.ID).Delete(&models.ExternalBusyBlock{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.ExternalCalendar{}, calendar.ID).Error
+	})
+}
+
+// GetBusyBlocks retorna os intervalos ocupados externos da prestadora que se sobrepõem a [from, to)
+func (r *ExternalCalendarRepository) GetBusyBlocks(providerID uint, from, to time.Time) ([]models.ExternalBusyBlock, error) {
+	return findExternalBusy(r.db, providerID, from, to)
+}
+
+// findExternalBusy retorna os intervalos ocupados externos da prestadora que se sobrepõem a [from, to)
+func findExternalBusy(tx *gorm.DB, providerID uint, from, to time.Time) ([]models.ExternalBusyBlock, error) {
+	var blocks []models.ExternalBusyBlock
+	err := tx.Where("provider_id = ? AND start_at < ? AND end_at > ?", providerID, to, from).
+		Order("start_at ASC").
+		Find(&blocks).Error
+	if err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
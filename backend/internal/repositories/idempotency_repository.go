@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{
+		db: db,
+	}
+}
+
+// Reserve tenta registrar a chave. Se ela já existir para o usuário, retorna o
+// registro existente e reserved falso.
+func (r *IdempotencyRepository) Reserve(record *models.IdempotencyKey) (reserved bool, existing *models.IdempotencyKey, err error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return false, nil, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, nil, nil
+	}
+
+	var found models.IdempotencyKey
+	if err := r.db.Where("user_id = ? AND key = ?", record.UserID, record.Key).First(&found).Error; err != nil {
+		return false, nil, err
+	}
+	return false, &found, nil
+}
+
+// Complete armazena a resposta da requisição
+func (r *IdempotencyRepository) Complete(record *models.IdempotencyKey) error {
+	return r.db.Model(&models.IdempotencyKey{}).Where("id = ?", record.ID).Updates(map[string]interface{}{
+		"status_code":   record.StatusCode,
+		"content_type":  record.ContentType,
+		"response_body": record.ResponseBody,
+	}).Error
+}
+
+// Release remove uma chave, permitindo que a requisição seja refeita
+func (r *IdempotencyRepository) Release(id uint) error {
+	return r.db.Delete(&models.IdempotencyKey{}, id).Error
+}
+
+// DeleteExpired remove as chaves expiradas
+func (r *IdempotencyRepository) DeleteExpired(now time.Time) error {
+	return r.db.Where("expires_at < ?", now).Delete(&models.IdempotencyKey{}).Error
+}
@@ -0,0 +1,301 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrLedgerUnbalanced      = errors.New("lançamento do razão não está balanceado")
+	ErrLedgerAccountNotFound = errors.New("conta do razão não encontrada")
+)
+
+// LedgerRepository grava o razão de partidas dobradas. Os lançamentos são somente de
+// inclusão: não há métodos de alteração ou remoção.
+type LedgerRepository struct {
+	db *gorm.DB
+}
+
+func NewLedgerRepository(db *gorm.DB) *LedgerRepository {
+	return &LedgerRepository{
+		db: db,
+	}
+}
+
+// AccountBalance é o saldo de uma conta do razão, em centavos
+type AccountBalance struct {
+	models.LedgerAccount
+	Balance int64 `json:"balance"`
+}
+
+// EnsureAccount retorna a conta com o código informado, criando-a se ainda não existir
+func (r *LedgerRepository) EnsureAccount(account *models.LedgerAccount) (*models.LedgerAccount, error) {
+	if err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(account).Error; err != nil {
+		return nil, err
+	}
+	return r.FindAccountByCode(account.Code)
+}
+
+// FindAccountByID busca uma conta pelo ID
+func (r *LedgerRepository) FindAccountByID(id uint) (*models.LedgerAccount, error) {
+	return r.findAccount(r.db.Where("id = ?", id))
+}
+
+// FindAccountByCode busca uma conta pelo código
+func (r *LedgerRepository) FindAccountByCode(code string) (*models.LedgerAccount, error) {
+	return r.findAccount(r.db.Where("code = ?", code))
+}
+
+func (r *LedgerRepository) findAccount(query *gorm.DB) (*models.LedgerAccount, error) {
+	var account models.LedgerAccount
+	if err := query.First(&account).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLedgerAccountNotFound
+		}
+		return nil, err
+	}
+	return &account, nil
+}
+
+// checkAllBalanced valida os lançamentos antes de abrir a transação que os grava
+func checkAllBalanced(transactions []*models.LedgerTransaction) error {
+	for _, transaction := range transactions {
+		if err := checkBalanced(transaction); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postLedger grava os lançamentos balanceados com suas partidas dentro de uma transação já
+// aberta. Lançamentos com uma referência já registrada são ignorados, para que o mesmo
+// movimento possa ser informado mais de uma vez sem duplicar valores. Os lançamentos devem
+// ter sido validados por checkBalanced.
+func postLedger(tx *gorm.DB, transactions ...*models.LedgerTransaction) error {
+	for _, transaction := range transactions {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Omit("Postings").Create(transaction)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		for i := range transaction.Postings {
+			transaction.Postings[i].TransactionID = transaction.ID
+		}
+		if err := tx.Create(&transaction.Postings).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkBalanced garante que o lançamento tem ao menos duas partidas e soma zero
+func checkBalanced(transaction *models.LedgerTransaction) error {
+	if len(transaction.Postings) < 2 {
+		return ErrLedgerUnbalanced
+	}
+	var sum int64
+	for _, posting := range transaction.Postings {
+		if posting.Amount == 0 || posting.AccountID == 0 {
+			return ErrLedgerUnbalanced
+		}
+		sum += posting.Amount
+	}
+	if sum != 0 {
+		return ErrLedgerUnbalanced
+	}
+	return nil
+}
+
+// Balance retorna o saldo de uma conta: débitos menos créditos, em centavos
+func (r *LedgerRepository) Balance(accountID uint) (int64, error) {
+	return accountBalance(r.db, accountID)
+}
+
+func accountBalance(db *gorm.DB, accountID uint) (int64, error) {
+	var balance int64
+	err := db.Model(&models.LedgerPosting{}).
+		Where("account_id = ?", accountID).
+		Select("COALESCE(SUM(amount), 0)").Scan(&balance).Error
+	return balance, err
+}
+
+// ProviderEscrowBalance retorna o saldo da custódia referente aos agendamentos de uma
+// prestadora, ainda não repassado nem devolvido, incluindo cupons e créditos aplicados
+func (r *LedgerRepository) ProviderEscrowBalance(providerID uint) (int64, error) {
+	var balance int64
+	err := r.db.Model(&models.LedgerPosting{}).
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_postings.account_id").
+		Joins("JOIN ledger_transactions ON ledger_transactions.id = ledger_postings.transaction_id").
+		Joins("JOIN appointments ON appointments.id = ledger_transactions.appointment_id").
+		Where("ledger_accounts.type = ? AND appointments.provider_id = ?", models.LedgerAccountEscrow, providerID).
+		Select("COALESCE(SUM(ledger_postings.amount), 0)").Scan(&balance).Error
+	return balance, err
+}
+
+// Balances retorna todas as contas com seus saldos
+func (r *LedgerRepository) Balances() ([]AccountBalance, error) {
+	var balances []AccountBalance
+	err := r.db.Model(&models.LedgerAccount{}).
+		Select("ledger_accounts.*, COALESCE(SUM(ledger_postings.amount), 0) AS balance").
+		Joins("LEFT JOIN ledger_postings ON ledger_postings.account_id = ledger_accounts.id").
+		Group("ledger_accounts.id").
+		Order("ledger_accounts.code").
+		Scan(&balances).Error
+	if err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+// GetPostings retorna as partidas de uma conta no período, da mais recente à mais antiga,
+// com o lançamento de origem
+func (r *LedgerRepository) GetPostings(accountID uint, from, to time.Time) ([]models.LedgerPosting, error) {
+	var postings []models.LedgerPosting
+	err := r.db.Preload("Transaction").
+		Where("account_id = ? AND created_at >= ? AND created_at < ?", accountID, from, to).
+		Order("created_at DESC, id DESC").
+		Find(&postings).Error
+	if err != nil {
+		return nil, err
+	}
+	return postings, nil
+}
+
+// ClearingTotals retorna, por pagamento, o saldo lançado nas contas de compensação do
+// gateway para os pagamentos informados
+func (r *LedgerRepository) ClearingTotals(paymentIDs []uint) (map[uint]int64, error) {
+	return r.clearingTotals("payment_id", paymentIDs)
+}
+
+// TipClearingTotals retorna, por gorjeta, o saldo lançado nas contas de compensação do
+// gateway para as gorjetas informadas
+func (r *LedgerRepository) TipClearingTotals(tipIDs []uint) (map[uint]int64, error) {
+	return r.clearingTotals("tip_id", tipIDs)
+}
+
+// clearingTotals agrupa o saldo das contas de compensação pela coluna do lançamento
+func (r *LedgerRepository) clearingTotals(column string, ids []uint) (map[uint]int64, error) {
+	var rows []struct {
+		ID    uint
+		Total int64
+	}
+	err := r.db.Model(&models.LedgerPosting{}).
+		Select("ledger_transactions."+column+" AS id, SUM(ledger_postings.amount) AS total").
+		Joins("JOIN ledger_transactions ON ledger_transactions.id = ledger_postings.transaction_id").
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_postings.account_id").
+		Where("ledger_accounts.type = ? AND ledger_transactions."+column+" IN ?", models.LedgerAccountGatewayClearing, ids).
+		Group("ledger_transactions." + column).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		totals[row.ID] = row.Total
+	}
+	return totals, nil
+}
+
+// PaymentIDsPostedBetween retorna os pagamentos com lançamentos no período
+func (r *LedgerRepository) PaymentIDsPostedBetween(from, to time.Time) ([]uint, error) {
+	return r.postedBetween("payment_id", from, to)
+}
+
+// TipIDsPostedBetween retorna as gorjetas com lançamentos no período
+func (r *LedgerRepository) TipIDsPostedBetween(from, to time.Time) ([]uint, error) {
+	return r.postedBetween("tip_id", from, to)
+}
+
+func (r *LedgerRepository) postedBetween(column string, from, to time.Time) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&models.LedgerTransaction{}).
+		Where(column+" IS NOT NULL AND created_at >= ? AND created_at < ?", from, to).
+		Distinct().Pluck(column, &ids).Error
+	return ids, err
+}
+
+// StatementPosting é uma partida de um lançamento ligado a um agendamento, com o tipo da conta
+type StatementPosting struct {
+	TransactionID uint
+	Kind          models.LedgerTransactionKind
+	AppointmentID uint
+	PaymentID     *uint
+	CreatedAt     time.Time
+	AccountType   models.LedgerAccountType
+	Amount        int64
+}
+
+// ProviderStatementPostings retorna as partidas dos lançamentos dos tipos informados feitos
+// no período [from, to) para os agendamentos de uma prestadora, em ordem cronológica
+func (r *LedgerRepository) ProviderStatementPostings(providerID uint, kinds []models.LedgerTransactionKind, from, to time.Time) ([]StatementPosting, error) {
+	var postings []StatementPosting
+	err := r.db.Model(&models.LedgerPosting{}).
+		Select("ledger_transactions.id AS transaction_id, ledger_transactions.kind, ledger_transactions.appointment_id, "+
+			"ledger_transactions.payment_id, ledger_transactions.created_at, ledger_accounts.type AS account_type, ledger_postings.amount").
+		Joins("JOIN ledger_transactions ON ledger_transactions.id = ledger_postings.transaction_id").
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_postings.account_id").
+		Joins("JOIN appointments ON appointments.id = ledger_transactions.appointment_id").
+		Where("appointments.provider_id = ? AND ledger_transactions.kind IN ?", providerID, kinds).
+		Where("ledger_transactions.created_at >= ? AND ledger_transactions.created_at < ?", from, to).
+		Order("ledger_transactions.created_at, ledger_transactions.id, ledger_postings.id").
+		Scan(&postings).Error
+	return postings, err
+}
+
+// ReceiptPostings retorna as partidas dos lançamentos de um pagamento e dos cupons e
+// créditos usados no agendamento, em ordem cronológica
+func (r *LedgerRepository) ReceiptPostings(paymentID, appointmentID uint) ([]StatementPosting, error) {
+	incentives := []models.LedgerTransactionKind{
+		models.LedgerTransactionPromoSubsidy, models.LedgerTransactionPromoReversal,
+		models.LedgerTransactionCreditHold, models.LedgerTransactionCreditReturn,
+	}
+
+	var postings []StatementPosting
+	err := r.db.Model(&models.LedgerPosting{}).
+		Select("ledger_transactions.id AS transaction_id, ledger_transactions.kind, ledger_transactions.appointment_id, "+
+			"ledger_transactions.payment_id, ledger_transactions.created_at, ledger_accounts.type AS account_type, ledger_postings.amount").
+		Joins("JOIN ledger_transactions ON ledger_transactions.id = ledger_postings.transaction_id").
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_postings.account_id").
+		Where("ledger_transactions.payment_id = ? OR (ledger_transactions.appointment_id = ? AND ledger_transactions.kind IN ?)",
+			paymentID, appointmentID, incentives).
+		Order("ledger_transactions.created_at, ledger_transactions.id, ledger_postings.id").
+		Scan(&postings).Error
+	return postings, err
+}
+
+// EscrowReturns retorna, por agendamento, o total devolvido da custódia aos clientes
+func (r *LedgerRepository) EscrowReturns(appointmentIDs []uint) (map[uint]int64, error) {
+	totals := make(map[uint]int64, len(appointmentIDs))
+	if len(appointmentIDs) == 0 {
+		return totals, nil
+	}
+
+	var rows []struct {
+		AppointmentID uint
+		Total         int64
+	}
+	err := r.db.Model(&models.LedgerPosting{}).
+		Select("ledger_transactions.appointment_id AS appointment_id, SUM(ledger_postings.amount) AS total").
+		Joins("JOIN ledger_transactions ON ledger_transactions.id = ledger_postings.transaction_id").
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_postings.account_id").
+		Where("ledger_accounts.type = ? AND ledger_transactions.kind = ? AND ledger_transactions.appointment_id IN ?",
+			models.LedgerAccountEscrow, models.LedgerTransactionEscrowReturn, appointmentIDs).
+		Group("ledger_transactions.appointment_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		totals[row.AppointmentID] = row.Total
+	}
+	return totals, nil
+}
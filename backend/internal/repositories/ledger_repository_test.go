@@ -0,0 +1,166 @@
+package repositories
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/testutil"
+	"gorm.io/gorm"
+)
+
+func TestLedgerPost(t *testing.T) {
+	db := testutil.NewDB(t)
+	repo := NewLedgerRepository(db)
+	clearing := testutil.LedgerAccount(t, db, models.LedgerAccountGatewayClearing, nil, "card")
+	escrow := testutil.LedgerAccount(t, db, models.LedgerAccountEscrow, nil, "")
+
+	transaction := func(reference string, amounts ...int64) *models.LedgerTransaction {
+		transaction := &models.LedgerTransaction{Kind: models.LedgerTransactionPayment, Reference: reference}
+		for i, amount := range amounts {
+			account := clearing.ID
+			if i%2 == 1 {
+				account = escrow.ID
+			}
+			transaction.Postings = append(transaction.Postings, models.LedgerPosting{AccountID: account, Amount: amount})
+		}
+		return transaction
+	}
+	// post grava os lançamentos como os repositórios que movimentam valores
+	post := func(transactions ...*models.LedgerTransaction) error {
+		if err := checkAllBalanced(transactions); err != nil {
+			return err
+		}
+		return db.Transaction(func(tx *gorm.DB) error {
+			return postLedger(tx, transactions...)
+		})
+	}
+
+	for name, unbalanced := range map[string]*models.LedgerTransaction{
+		"partida única":       transaction("payment:1", 15000),
+		"soma diferente de 0": transaction("payment:1", 15000, -14000),
+		"partida zerada":      transaction("payment:1", 0, 0),
+	} {
+		if err := post(unbalanced); !errors.Is(err, ErrLedgerUnbalanced) {
+			t.Errorf("%s: post = %v, want %v", name, err, ErrLedgerUnbalanced)
+		}
+	}
+
+	// Um lançamento desbalanceado impede a gravação dos demais
+	if err := post(transaction("payment:2", 5000, -5000), transaction("payment:3", 5000)); !errors.Is(err, ErrLedgerUnbalanced) {
+		t.Fatalf("post em lote: %v, want %v", err, ErrLedgerUnbalanced)
+	}
+	var count int64
+	db.Model(&models.LedgerTransaction{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("%d lançamentos gravados, want 0", count)
+	}
+
+	// A mesma referência informada de novo não duplica o movimento
+	for i := 0; i < 2; i++ {
+		if err := post(transaction("payment:1", 15000, -15000)); err != nil {
+			t.Fatalf("post: %v", err)
+		}
+	}
+	clearingBalance, err := repo.Balance(clearing.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	escrowBalance, err := repo.Balance(escrow.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clearingBalance != 15000 || escrowBalance != -15000 {
+		t.Errorf("saldos %d e %d, want 15000 e -15000", clearingBalance, escrowBalance)
+	}
+}
+
+// ledgerAccounts grava as duas contas do razão movimentadas na liquidação de um pagamento
+func ledgerAccounts(t *testing.T, db *gorm.DB) [2]uint {
+	t.Helper()
+	clearing := testutil.LedgerAccount(t, db, models.LedgerAccountGatewayClearing, nil, "")
+	escrow := testutil.LedgerAccount(t, db, models.LedgerAccountEscrow, nil, "")
+	return [2]uint{clearing.ID, escrow.ID}
+}
+
+// ledgerTransaction monta um lançamento entre as duas contas; unbalanced deixa de fora a contrapartida
+func ledgerTransaction(payment *models.Payment, accounts [2]uint, unbalanced bool) *models.LedgerTransaction {
+	transaction := &models.LedgerTransaction{
+		Kind: models.LedgerTransactionPayment, Reference: "payment:test", PaymentID: &payment.ID,
+		Postings: []models.LedgerPosting{{AccountID: accounts[0], Amount: payment.Amount}},
+	}
+	if !unbalanced {
+		transaction.Postings = append(transaction.Postings, models.LedgerPosting{AccountID: accounts[1], Amount: -payment.Amount})
+	}
+	return transaction
+}
+
+func TestLedgerPostsWithStateChange(t *testing.T) {
+	tests := []struct {
+		name       string
+		unbalanced bool
+		wantErr    error
+		wantStatus models.PaymentStatus
+		wantPosted int64
+	}{
+		{"lançamento balanceado é gravado com a liquidação", false, nil, models.PaymentStatusSucceeded, 1},
+		{"lançamento desbalanceado desfaz a liquidação", true, ErrLedgerUnbalanced, models.PaymentStatusPending, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			payment := testutil.CreatePayment(t, db, models.Payment{Amount: 15000, Status: models.PaymentStatusPending})
+			accounts := ledgerAccounts(t, db)
+
+			ledger := []*models.LedgerTransaction{ledgerTransaction(payment, accounts, tt.unbalanced)}
+			_, err := NewPaymentRepository(db).MarkPaid(payment, "", time.Now(), ledger)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("MarkPaid: %v; want %v", err, tt.wantErr)
+			}
+
+			var stored models.Payment
+			db.First(&stored, payment.ID)
+			var posted int64
+			db.Model(&models.LedgerTransaction{}).Where("payment_id = ?", payment.ID).Count(&posted)
+			if stored.Status != tt.wantStatus || posted != tt.wantPosted {
+				t.Errorf("pagamento %s com %d lançamentos; want %s com %d", stored.Status, posted, tt.wantStatus, tt.wantPosted)
+			}
+		})
+	}
+}
+
+func TestLedgerIsAppendOnly(t *testing.T) {
+	db := testutil.NewDB(t)
+	payment := testutil.CreatePayment(t, db, models.Payment{Amount: 15000, Status: models.PaymentStatusPending})
+	accounts := ledgerAccounts(t, db)
+	transaction := ledgerTransaction(payment, accounts, false)
+	if _, err := NewPaymentRepository(db).MarkPaid(payment, "", time.Now(), []*models.LedgerTransaction{transaction}); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := map[string]func() error{
+		"alterar lançamento": func() error {
+			return db.Model(&models.LedgerTransaction{}).Where("id = ?", transaction.ID).Update("description", "alterado").Error
+		},
+		"apagar lançamento": func() error {
+			return db.Delete(&models.LedgerTransaction{}, transaction.ID).Error
+		},
+		"alterar partida": func() error {
+			return db.Model(&models.LedgerPosting{}).Where("transaction_id = ?", transaction.ID).Update("amount", 1).Error
+		},
+		"apagar partida": func() error {
+			return db.Where("transaction_id = ?", transaction.ID).Delete(&models.LedgerPosting{}).Error
+		},
+	}
+	for name, change := range changes {
+		if err := change(); err == nil {
+			t.Errorf("%s: deveria ser recusado", name)
+		}
+	}
+
+	balance, err := NewLedgerRepository(db).Balance(accounts[0])
+	if err != nil || balance != payment.Amount {
+		t.Errorf("saldo = %d, %v; want %d", balance, err, payment.Amount)
+	}
+}
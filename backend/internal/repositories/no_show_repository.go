@@ -0,0 +1,122 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNoShowClaimNotFound = errors.New("alegação de ausência não encontrada")
+	ErrNoShowClaimExists   = errors.New("já existe uma alegação de ausência para este agendamento")
+)
+
+type NoShowRepository struct {
+	db *gorm.DB
+}
+
+func NewNoShowRepository(db *gorm.DB) *NoShowRepository {
+	return &NoShowRepository{
+		db: db,
+	}
+}
+
+// Create registra a alegação e marca o agendamento como ausência, desde que ele ainda esteja confirmado
+func (r *NoShowRepository) Create(claim *models.NoShowClaim, events ...*models.AppointmentEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Appointment{}).
+			Where("id = ? AND status = ?", claim.AppointmentID, models.AppointmentStatusConfirmed).
+			Update("status", models.AppointmentStatusNoShow)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNoShowClaimExists
+		}
+		if err := tx.Create(claim).Error; err != nil {
+			return err
+		}
+		return appendEvents(tx, events...)
+	})
+}
+
+// FindByAppointment busca a alegação de ausência de um agendamento
+func (r *NoShowRepository) FindByAppointment(appointmentID uint) (*models.NoShowClaim, error) {
+	var claim models.NoShowClaim
+	if err := r.db.Where("appointment_id = ?", appointmentID).First(&claim).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNoShowClaimNotFound
+		}
+		return nil, err
+	}
+	return &claim, nil
+}
+
+// Update salva as alterações de uma alegação
+func (r *NoShowRepository) Update(claim *models.NoShowClaim, events ...*models.AppointmentEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(claim).Error; err != nil {
+			return err
+		}
+		return appendEvents(tx, events...)
+	})
+}
+
+// Resolve salva a resolução da alegação, o novo status do agendamento e,
+// quando a prestadora faltou, incrementa seu contador de ausências
+func (r *NoShowRepository) Resolve(claim *models.NoShowClaim, appointment *models.Appointment, events ...*models.AppointmentEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(claim).Error; err != nil {
+			return err
+		}
+		if err := appendEvents(tx, events...); err != nil {
+			return err
+		}
+
+		err := tx.Model(&models.Appointment{}).Where("id = ?", appointment.ID).Update("status", appointment.Status).Error
+		if err != nil {
+			return err
+		}
+
+		if claim.Status != models.NoShowClaimStatusUpheld || claim.AbsentParty != models.NoShowPartyProvider {
+			return nil
+		}
+
+		return tx.Model(&models.ProviderProfile{}).
+			Where("user_id = ?", appointment.ProviderID).
+			UpdateColumn("provider_no_shows", gorm.Expr("provider_no_shows + 1")).Error
+	})
+}
+
+// GetExpiredOpen retorna as alegações não contestadas cuja janela de contestação terminou
+func (r *NoShowRepository) GetExpiredOpen(now time.Time) ([]models.NoShowClaim, error) {
+	var claims []models.NoShowClaim
+	err := r.db.Where("status = ? AND dispute_deadline < ?", models.NoShowClaimStatusOpen, now).Find(&claims).Error
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// GetStaleConfirmed retorna agendamentos confirmados, sem check-in, que deveriam ter começado
+// antes de before. Agendamentos que voltaram a confirmados após uma alegação rejeitada
+// ficam de fora e aguardam o check-out ou a decisão de um admin.
+func (r *NoShowRepository) GetStaleConfirmed(before time.Time) ([]models.Appointment, error) {
+	var candidates []models.Appointment
+	err := r.db.Where("status = ? AND checked_in_at IS NULL AND date <= ?", models.AppointmentStatusConfirmed, before).
+		Where("NOT EXISTS (?)", r.db.Model(&models.NoShowClaim{}).Select("1").Where("no_show_claims.appointment_id = appointments.id")).
+		Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []models.Appointment
+	for _, a := range candidates {
+		if a.StartsAt().Before(before) {
+			stale = append(stale, a)
+		}
+	}
+	return stale, nil
+}
@@ -0,0 +1,349 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrPaymentNotFound        = errors.New("pagamento não encontrado")
+	ErrPaymentMethodNotFound  = errors.New("método de pagamento não encontrado")
+	ErrRefundExceedsAvailable = errors.New("valor do reembolso maior que o disponível")
+	ErrRefundNotFound         = errors.New("reembolso não encontrado")
+)
+
+type PaymentRepository struct {
+	db *gorm.DB
+}
+
+func NewPaymentRepository(db *gorm.DB) *PaymentRepository {
+	return &PaymentRepository{
+		db: db,
+	}
+}
+
+// CreateIfNone grava o pagamento se o agendamento ainda não tiver um pagamento em aberto
+// ou pago. Se houver, o pagamento existente é retornado e created é falso.
+func (r *PaymentRepository) CreateIfNone(payment *models.Payment) (existing *models.Payment, created bool, err error) {
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		// Serializa os pagamentos do mesmo agendamento
+		var appointment models.Appointment
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&appointment, payment.AppointmentID).Error
+		if err != nil {
+			return err
+		}
+
+		var current models.Payment
+		err = tx.Where("appointment_id = ? AND status <> ?", payment.AppointmentID, models.PaymentStatusCanceled).Order("id DESC").First(&current).Error
+		if err == nil {
+			existing = &current
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		created = true
+		return tx.Create(payment).Error
+	})
+	return existing, created, err
+}
+
+// FindByID busca um pagamento pelo ID
+func (r *PaymentRepository) FindByID(id uint) (*models.Payment, error) {
+	return r.findOne(r.db.Where("id = ?", id))
+}
+
+// FindByIntentID busca um pagamento pelo ID da intenção no gateway
+func (r *PaymentRepository) FindByIntentID(intentID string) (*models.Payment, error) {
+	return r.findOne(r.db.Where("gateway_intent_id = ?", intentID))
+}
+
+// FindActiveByAppointment retorna o pagamento em aberto ou pago do agendamento
+func (r *PaymentRepository) FindActiveByAppointment(appointmentID uint) (*models.Payment, error) {
+	return r.findOne(r.db.Where("appointment_id = ? AND status <> ?", appointmentID, models.PaymentStatusCanceled).Order("id DESC"))
+}
+
+func (r *PaymentRepository) findOne(query *gorm.DB) (*models.Payment, error) {
+	var payment models.Payment
+	if err := query.Preload("Refunds").First(&payment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPaymentNotFound
+		}
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// UpdateStatus salva o status do pagamento, a entrada em custódia e os dados retornados pelo gateway.
+// O valor reembolsado só é alterado por ReserveRefund e FailRefund. Os eventos do histórico
+// são gravados na mesma transação.
+func (r *PaymentRepository) UpdateStatus(payment *models.Payment, events ...*models.AppointmentEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(payment).Select("status", "escrow_status", "payment_method_id", "failure_reason", "paid_at").Updates(payment).Error
+		if err != nil {
+			return err
+		}
+		return appendEvents(tx, events...)
+	})
+}
+
+// MarkPaid registra a liquidação de um pagamento ainda não pago e coloca o valor em custódia.
+// Retorna falso quando o pagamento já estava pago, como em notificações repetidas do PSP,
+// ou cancelado; nesse caso os lançamentos e os eventos do histórico não são gravados.
+func (r *PaymentRepository) MarkPaid(payment *models.Payment, endToEndID string, paidAt time.Time, ledger []*models.LedgerTransaction, events ...*models.AppointmentEvent) (bool, error) {
+	return r.markPaid(payment, []models.PaymentStatus{models.PaymentStatusPending, models.PaymentStatusFailed},
+		models.EscrowStatusHeld, endToEndID, paidAt, "", ledger, events)
+}
+
+// MarkPaidAfterCancel registra a liquidação de um pagamento já cancelado, como um Pix pago
+// depois de a cobrança expirar. O valor fica congelado, sem repasse à prestadora, até ser
+// devolvido ao cliente, e reason fica no pagamento para a conciliação.
+// Retorna falso quando o pagamento não estava cancelado.
+func (r *PaymentRepository) MarkPaidAfterCancel(payment *models.Payment, endToEndID string, paidAt time.Time, reason string, ledger []*models.LedgerTransaction, events ...*models.AppointmentEvent) (bool, error) {
+	return r.markPaid(payment, []models.PaymentStatus{models.PaymentStatusCanceled},
+		models.EscrowStatusFrozen, endToEndID, paidAt, reason, ledger, events)
+}
+
+// markPaid grava a liquidação, os lançamentos no razão e os eventos na mesma transação.
+// O método de pagamento informado na confirmação com cartão é salvo junto.
+func (r *PaymentRepository) markPaid(payment *models.Payment, from []models.PaymentStatus, escrow models.EscrowStatus, endToEndID string, paidAt time.Time, reason string, ledger []*models.LedgerTransaction, events []*models.AppointmentEvent) (bool, error) {
+	if err := checkAllBalanced(ledger); err != nil {
+		return false, err
+	}
+
+	settled := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Payment{}).
+			Where("id = ? AND status IN ?", payment.ID, from).
+			Updates(map[string]interface{}{
+				"status":            models.PaymentStatusSucceeded,
+				"escrow_status":     escrow,
+				"payment_method_id": payment.PaymentMethodID,
+				"end_to_end_id":     endToEndID,
+				"paid_at":           paidAt,
+				"failure_reason":    reason,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		settled = true
+		if err := postLedger(tx, ledger...); err != nil {
+			return err
+		}
+		return appendEvents(tx, events...)
+	})
+	if err != nil || !settled {
+		return false, err
+	}
+
+	payment.Status = models.PaymentStatusSucceeded
+	payment.EscrowStatus = escrow
+	payment.EndToEndID = endToEndID
+	payment.PaidAt = &paidAt
+	payment.FailureReason = reason
+	return true, nil
+}
+
+// CloseRefundedEscrow encerra a custódia congelada de um pagamento devolvido integralmente,
+// sem repasse à prestadora. Retorna falso se ainda houver saldo ou a custódia não estiver congelada.
+func (r *PaymentRepository) CloseRefundedEscrow(paymentID uint) (bool, error) {
+	result := r.db.Model(&models.Payment{}).
+		Where("id = ? AND escrow_status = ? AND refunded_amount = amount", paymentID, models.EscrowStatusFrozen).
+		Update("escrow_status", models.EscrowStatusRefunded)
+	return result.RowsAffected > 0, result.Error
+}
+
+// MarkFailed registra a recusa de um pagamento ainda pendente informada pelo gateway.
+// Retorna falso quando o pagamento já tinha outro desfecho.
+func (r *PaymentRepository) MarkFailed(payment *models.Payment, reason string) (bool, error) {
+	result := r.db.Model(&models.Payment{}).
+		Where("id = ? AND status = ?", payment.ID, models.PaymentStatusPending).
+		Updates(map[string]interface{}{
+			"status":         models.PaymentStatusFailed,
+			"failure_reason": reason,
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+
+	payment.Status = models.PaymentStatusFailed
+	payment.FailureReason = reason
+	return true, nil
+}
+
+// GetExpiredPixCharges retorna as cobranças Pix pendentes cuja validade terminou
+func (r *PaymentRepository) GetExpiredPixCharges(now time.Time) ([]models.Payment, error) {
+	var payments []models.Payment
+	err := r.db.Where("method = ? AND status = ? AND expires_at < ?", models.PaymentMethodPix, models.PaymentStatusPending, now).
+		Find(&payments).Error
+	if err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+// GetForReconciliation retorna os pagamentos liquidados no período e os pagamentos
+// informados, com os reembolsos
+func (r *PaymentRepository) GetForReconciliation(from, to time.Time, ids []uint) ([]models.Payment, error) {
+	query := r.db.Preload("Refunds").Where("paid_at >= ? AND paid_at < ?", from, to)
+	if len(ids) > 0 {
+		query = query.Or("id IN ?", ids)
+	}
+
+	var payments []models.Payment
+	if err := query.Order("id").Find(&payments).Error; err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+// GetByUser retorna os pagamentos feitos pelo cliente, do mais recente ao mais antigo
+func (r *PaymentRepository) GetByUser(userID uint) ([]models.Payment, error) {
+	var payments []models.Payment
+	err := r.db.Preload("Appointment").Preload("Refunds").
+		Where("user_id = ?", userID).Order("created_at DESC").Find(&payments).Error
+	if err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+// ReserveRefund registra um reembolso pendente e já o desconta do valor disponível,
+// para que reembolsos simultâneos não ultrapassem o valor pago
+func (r *PaymentRepository) ReserveRefund(paymentID uint, refund *models.Refund) (*models.Payment, error) {
+	var payment models.Payment
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, paymentID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrPaymentNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if payment.EscrowStatus == models.EscrowStatusReleased {
+			return ErrEscrowReleased
+		}
+		if refund.Amount <= 0 || refund.Amount > payment.Refundable() {
+			return ErrRefundExceedsAvailable
+		}
+
+		refund.PaymentID = payment.ID
+		refund.Status = models.RefundStatusPending
+		if err := tx.Create(refund).Error; err != nil {
+			return err
+		}
+
+		payment.RefundedAmount += refund.Amount
+		payment.Status = models.PaymentStatusPartiallyRefunded
+		if payment.RefundedAmount == payment.Amount {
+			payment.Status = models.PaymentStatusRefunded
+		}
+		return tx.Model(&payment).Select("refunded_amount", "status").Updates(&payment).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// CompleteRefund marca o reembolso como processado pelo gateway e grava os lançamentos no
+// razão e os eventos do histórico na mesma transação
+func (r *PaymentRepository) CompleteRefund(refund *models.Refund, gatewayRefundID string, ledger []*models.LedgerTransaction, events ...*models.AppointmentEvent) error {
+	if err := checkAllBalanced(ledger); err != nil {
+		return err
+	}
+
+	refund.Status = models.RefundStatusSucceeded
+	refund.GatewayRefundID = gatewayRefundID
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(refund).Select("status", "gateway_refund_id").Updates(refund).Error; err != nil {
+			return err
+		}
+		if err := postLedger(tx, ledger...); err != nil {
+			return err
+		}
+		return appendEvents(tx, events...)
+	})
+}
+
+// FindRefundByGatewayID busca um reembolso pelo ID no gateway
+func (r *PaymentRepository) FindRefundByGatewayID(gatewayRefundID string) (*models.Refund, error) {
+	var refund models.Refund
+	if err := r.db.Where("gateway_refund_id = ?", gatewayRefundID).First(&refund).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRefundNotFound
+		}
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// HasPendingRefund indica se o pagamento tem reembolso aguardando resposta do gateway
+func (r *PaymentRepository) HasPendingRefund(paymentID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Refund{}).
+		Where("payment_id = ? AND status = ?", paymentID, models.RefundStatusPending).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// FailRefund marca o reembolso como recusado e devolve o valor reservado ao pagamento
+func (r *PaymentRepository) FailRefund(refund *models.Refund) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var payment models.Payment
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, refund.PaymentID).Error
+		if err != nil {
+			return err
+		}
+
+		payment.RefundedAmount -= refund.Amount
+		payment.Status = models.PaymentStatusPartiallyRefunded
+		if payment.RefundedAmount == 0 {
+			payment.Status = models.PaymentStatusSucceeded
+		}
+		if err := tx.Model(&payment).Select("refunded_amount", "status").Updates(&payment).Error; err != nil {
+			return err
+		}
+
+		refund.Status = models.RefundStatusFailed
+		return tx.Model(refund).Select("status").Updates(refund).Error
+	})
+}
+
+// SaveMethod grava um método de pagamento do cliente
+func (r *PaymentRepository) SaveMethod(method *models.PaymentMethod) error {
+	return r.db.Create(method).Error
+}
+
+// GetMethods retorna os métodos de pagamento salvos pelo cliente
+func (r *PaymentRepository) GetMethods(userID uint) ([]models.PaymentMethod, error) {
+	var methods []models.PaymentMethod
+	if err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&methods).Error; err != nil {
+		return nil, err
+	}
+	return methods, nil
+}
+
+// FindMethod busca um método de pagamento do cliente pelo ID no gateway
+func (r *PaymentRepository) FindMethod(userID uint, gatewayMethodID string) (*models.PaymentMethod, error) {
+	var method models.PaymentMethod
+	err := r.db.Where("user_id = ? AND gateway_method_id = ?", userID, gatewayMethodID).First(&method).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPaymentMethodNotFound
+		}
+		return nil, err
+	}
+	return &method, nil
+}
+
+// DeleteMethod remove um método de pagamento salvo
+func (r *PaymentRepository) DeleteMethod(method *models.PaymentMethod) error {
+	return r.db.Delete(method).Error
+}
@@ -0,0 +1,236 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrPayoutNotFound            = errors.New("saque não encontrado")
+	ErrPayoutDestinationNotFound = errors.New("nenhuma conta ou chave Pix cadastrada para saques")
+	ErrInsufficientBalance       = errors.New("saldo disponível insuficiente para o saque")
+	ErrPayoutBelowMinimum        = errors.New("valor abaixo do mínimo para saque")
+)
+
+type PayoutRepository struct {
+	db *gorm.DB
+}
+
+func NewPayoutRepository(db *gorm.DB) *PayoutRepository {
+	return &PayoutRepository{
+		db: db,
+	}
+}
+
+// SaveDestination cadastra ou substitui a conta de recebimento da prestadora
+func (r *PayoutRepository) SaveDestination(destination *models.PayoutDestination) error {
+	err := r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "provider_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"updated_at", "type", "pix_key_type", "pix_key", "bank_code", "branch",
+			"account_number", "account_type", "holder_name", "holder_document",
+		}),
+	}).Create(destination).Error
+	if err != nil {
+		return err
+	}
+
+	saved, err := r.FindDestination(destination.ProviderID)
+	if err != nil {
+		return err
+	}
+	*destination = *saved
+	return nil
+}
+
+// FindDestination busca a conta de recebimento da prestadora
+func (r *PayoutRepository) FindDestination(providerID uint) (*models.PayoutDestination, error) {
+	var destination models.PayoutDestination
+	if err := r.db.Where("provider_id = ?", providerID).First(&destination).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPayoutDestinationNotFound
+		}
+		return nil, err
+	}
+	return &destination, nil
+}
+
+// CreateWithDebit grava o saque e o lançamento que o desconta da conta da prestadora no
+// razão. A conta é bloqueada durante a verificação do saldo, para que saques simultâneos
+// não ultrapassem o disponível. O lançamento recebe a referência do saque criado.
+func (r *PayoutRepository) CreateWithDebit(payout *models.Payout, accountID uint, minimum int64, debit *models.LedgerTransaction) error {
+	if payout.Amount < minimum {
+		return ErrPayoutBelowMinimum
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var account models.LedgerAccount
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&account, accountID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrLedgerAccountNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		// O saldo devido à prestadora é credor, ou seja, negativo no razão
+		balance, err := accountBalance(tx, account.ID)
+		if err != nil {
+			return err
+		}
+		if payout.Amount > -balance {
+			return ErrInsufficientBalance
+		}
+
+		if err := tx.Create(payout).Error; err != nil {
+			return err
+		}
+
+		debit.PayoutID = &payout.ID
+		debit.Reference = fmt.Sprintf("payout:%d", payout.ID)
+		if err := checkBalanced(debit); err != nil {
+			return err
+		}
+		return postLedger(tx, debit)
+	})
+}
+
+// FindByID busca um saque pelo ID
+func (r *PayoutRepository) FindByID(id uint) (*models.Payout, error) {
+	var payout models.Payout
+	if err := r.db.First(&payout, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPayoutNotFound
+		}
+		return nil, err
+	}
+	return &payout, nil
+}
+
+// ClaimAttempt reserva uma tentativa de envio do saque pendente, já agendando a próxima
+// para retryAt. Retorna falso se outra execução tiver reservado a tentativa antes.
+func (r *PayoutRepository) ClaimAttempt(payout *models.Payout, retryAt time.Time) (bool, error) {
+	result := r.db.Model(&models.Payout{}).
+		Where("id = ? AND status = ? AND attempts = ?", payout.ID, models.PayoutStatusPending, payout.Attempts).
+		Updates(map[string]interface{}{
+			"attempts":        payout.Attempts + 1,
+			"next_attempt_at": retryAt,
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+
+	payout.Attempts++
+	payout.NextAttemptAt = &retryAt
+	return true, nil
+}
+
+// UpdateStatus salva o andamento do saque no provedor de repasses
+func (r *PayoutRepository) UpdateStatus(payout *models.Payout) error {
+	return r.db.Model(payout).
+		Select("status", "transfer_id", "next_attempt_at", "failure_reason", "paid_at").
+		Updates(payout).Error
+}
+
+// Fail marca o saque como falho e grava o lançamento que devolve o valor ao saldo da
+// prestadora. Retorna falso se o saque já estava pago ou falho.
+func (r *PayoutRepository) Fail(payout *models.Payout, reversal *models.LedgerTransaction) (bool, error) {
+	if err := checkBalanced(reversal); err != nil {
+		return false, err
+	}
+
+	failed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Payout{}).
+			Where("id = ? AND status IN ?", payout.ID, []models.PayoutStatus{models.PayoutStatusPending, models.PayoutStatusProcessing}).
+			Updates(map[string]interface{}{
+				"status":          models.PayoutStatusFailed,
+				"failure_reason":  payout.FailureReason,
+				"next_attempt_at": nil,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		failed = true
+		return postLedger(tx, reversal)
+	})
+	if err != nil || !failed {
+		return false, err
+	}
+
+	payout.Status = models.PayoutStatusFailed
+	payout.NextAttemptAt = nil
+	return true, nil
+}
+
+// GetDueRetries retorna os saques pendentes cuja próxima tentativa já venceu
+func (r *PayoutRepository) GetDueRetries(now time.Time) ([]models.Payout, error) {
+	var payouts []models.Payout
+	err := r.db.Where("status = ? AND next_attempt_at <= ?", models.PayoutStatusPending, now).Find(&payouts).Error
+	if err != nil {
+		return nil, err
+	}
+	return payouts, nil
+}
+
+// GetProcessing retorna os saques aceitos pelo provedor, aguardando confirmação
+func (r *PayoutRepository) GetProcessing() ([]models.Payout, error) {
+	var payouts []models.Payout
+	if err := r.db.Where("status = ?", models.PayoutStatusProcessing).Find(&payouts).Error; err != nil {
+		return nil, err
+	}
+	return payouts, nil
+}
+
+// GetByProvider retorna os saques da prestadora, do mais recente ao mais antigo
+func (r *PayoutRepository) GetByProvider(providerID uint) ([]models.Payout, error) {
+	var payouts []models.Payout
+	if err := r.db.Where("provider_id = ?", providerID).Order("created_at DESC").Find(&payouts).Error; err != nil {
+		return nil, err
+	}
+	return payouts, nil
+}
+
+// Totals retorna os valores em trânsito (pendentes ou em processamento) e já pagos da prestadora
+func (r *PayoutRepository) Totals(providerID uint) (inTransit, paidOut int64, err error) {
+	var rows []struct {
+		Status models.PayoutStatus
+		Total  int64
+	}
+	err = r.db.Model(&models.Payout{}).
+		Select("status, SUM(amount) AS total").
+		Where("provider_id = ?", providerID).
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, row := range rows {
+		switch row.Status {
+		case models.PayoutStatusPending, models.PayoutStatusProcessing:
+			inTransit += row.Total
+		case models.PayoutStatusPaid:
+			paidOut += row.Total
+		}
+	}
+	return inTransit, paidOut, nil
+}
+
+// GetAutoPayoutProfiles retorna as prestadoras com repasse automático e conta de recebimento cadastrada
+func (r *PayoutRepository) GetAutoPayoutProfiles() ([]models.ProviderProfile, error) {
+	var profiles []models.ProviderProfile
+	err := r.db.Where("auto_payout_enabled = ?", true).
+		Where("EXISTS (SELECT 1 FROM payout_destinations WHERE payout_destinations.provider_id = provider_profiles.user_id)").
+		Find(&profiles).Error
+	if err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
@@ -0,0 +1,398 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrPromoCodeNotFound      = errors.New("cupom não encontrado")
+	ErrPromoCodeExists        = errors.New("já existe um cupom com este código")
+	ErrPromoCodeUnavailable   = errors.New("cupom esgotado ou desativado")
+	ErrPromoCodeUserLimit     = errors.New("você já atingiu o limite de usos deste cupom")
+	ErrPromoCodeFirstBooking  = errors.New("cupom válido apenas na primeira reserva")
+	ErrCreditChanged          = errors.New("o saldo de créditos mudou durante a reserva; tente novamente")
+	ErrIncentivesChanged      = errors.New("os descontos do agendamento mudaram durante a operação")
+	ErrReferralCodeNotFound   = errors.New("código de indicação não encontrado")
+	ErrReferralCodeExists     = errors.New("código de indicação já utilizado")
+	ErrAlreadyReferred        = errors.New("este cliente já foi indicado")
+	ErrReferralNotFound       = errors.New("indicação não encontrada")
+	ErrNoCompletedAppointment = errors.New("nenhum atendimento concluído")
+)
+
+// BookingIncentives são o cupom e o crédito da carteira a aplicar numa reserva. São
+// confirmados e gravados na mesma transação que cria o agendamento.
+type BookingIncentives struct {
+	PromoCode     *models.PromoCode
+	PromoDiscount int64 // Em centavos
+	Credit        int64 // Em centavos
+	// Conta do cliente no razão, bloqueada durante a verificação do saldo de créditos
+	CreditAccountID uint
+	// Lançamentos que levam o desconto e o crédito à custódia. A referência e o
+	// agendamento são definidos quando o agendamento é criado.
+	Ledger []*models.LedgerTransaction
+}
+
+type PromotionRepository struct {
+	db *gorm.DB
+}
+
+func NewPromotionRepository(db *gorm.DB) *PromotionRepository {
+	return &PromotionRepository{
+		db: db,
+	}
+}
+
+// reserveIncentives confirma, com o cliente bloqueado, que o cupom e o crédito ainda
+// podem ser usados, reservando um uso do cupom. Deve ser chamada antes de criar o agendamento.
+func reserveIncentives(tx *gorm.DB, appointment *models.Appointment, incentives *BookingIncentives) error {
+	// Reservas simultâneas do mesmo cliente esperam aqui
+	var client models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&client, appointment.UserID).Error; err != nil {
+		return err
+	}
+
+	if promo := incentives.PromoCode; promo != nil {
+		if promo.FirstBookingOnly {
+			var count int64
+			err := tx.Model(&models.Appointment{}).
+				Where("user_id = ? AND status <> ?", appointment.UserID, models.AppointmentStatusCancelled).
+				Count(&count).Error
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrPromoCodeFirstBooking
+			}
+		}
+
+		if promo.PerUserLimit > 0 {
+			var count int64
+			err := tx.Model(&models.PromoRedemption{}).
+				Where("promo_code_id = ? AND user_id = ? AND status = ?", promo.ID, appointment.UserID, models.PromoRedemptionActive).
+				Count(&count).Error
+			if err != nil {
+				return err
+			}
+			if count >= int64(promo.PerUserLimit) {
+				return ErrPromoCodeUserLimit
+			}
+		}
+
+		result := tx.Model(&models.PromoCode{}).
+			Where("id = ? AND active = ? AND (max_redemptions = 0 OR redemptions < max_redemptions)", promo.ID, true).
+			UpdateColumn("redemptions", gorm.Expr("redemptions + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrPromoCodeUnavailable
+		}
+		appointment.PromoDiscount = incentives.PromoDiscount
+	}
+
+	if incentives.Credit > 0 {
+		var account models.LedgerAccount
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&account, incentives.CreditAccountID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrLedgerAccountNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		// O crédito do cliente é o saldo credor da sua conta
+		balance, err := accountBalance(tx, account.ID)
+		if err != nil {
+			return err
+		}
+		if incentives.Credit > -balance {
+			return ErrCreditChanged
+		}
+		appointment.CreditApplied = incentives.Credit
+	}
+	return nil
+}
+
+// recordIncentives grava o uso do cupom e os lançamentos do agendamento recém-criado
+func recordIncentives(tx *gorm.DB, appointment *models.Appointment, incentives *BookingIncentives) error {
+	if incentives.PromoCode != nil {
+		redemption := &models.PromoRedemption{
+			PromoCodeID:   incentives.PromoCode.ID,
+			UserID:        appointment.UserID,
+			AppointmentID: appointment.ID,
+			Discount:      incentives.PromoDiscount,
+			Status:        models.PromoRedemptionActive,
+		}
+		if err := tx.Create(redemption).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, transaction := range incentives.Ledger {
+		transaction.AppointmentID = &appointment.ID
+		transaction.Reference = fmt.Sprintf("%s:%d", transaction.Kind, appointment.ID)
+		if err := checkBalanced(transaction); err != nil {
+			return err
+		}
+	}
+	return postLedger(tx, incentives.Ledger...)
+}
+
+// ReleaseIncentives devolve o crédito à carteira e o uso do cupom de um agendamento
+// cancelado, gravando os estornos. promoDiscount e credit são os valores lidos ao montar
+// os estornos. Retorna falso se não havia descontos a devolver.
+func (r *PromotionRepository) ReleaseIncentives(appointmentID uint, promoDiscount, credit int64, reversals ...*models.LedgerTransaction) (bool, error) {
+	for _, transaction := range reversals {
+		if err := checkBalanced(transaction); err != nil {
+			return false, err
+		}
+	}
+
+	released := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var appointment models.Appointment
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "promo_discount", "credit_applied").
+			First(&appointment, appointmentID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAppointmentNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if appointment.PromoDiscount == 0 && appointment.CreditApplied == 0 {
+			return nil
+		}
+		if appointment.PromoDiscount != promoDiscount || appointment.CreditApplied != credit {
+			return ErrIncentivesChanged
+		}
+
+		err = tx.Model(&models.Appointment{}).Where("id = ?", appointmentID).Updates(map[string]interface{}{
+			"promo_discount": 0,
+			"credit_applied": 0,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		var redemption models.PromoRedemption
+		err = tx.Where("appointment_id = ? AND status = ?", appointmentID, models.PromoRedemptionActive).First(&redemption).Error
+		if err == nil {
+			err = tx.Model(&redemption).Update("status", models.PromoRedemptionReleased).Error
+			if err == nil {
+				err = tx.Model(&models.PromoCode{}).
+					Where("id = ? AND redemptions > 0", redemption.PromoCodeID).
+					UpdateColumn("redemptions", gorm.Expr("redemptions - 1")).Error
+			}
+		} else if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+
+		released = true
+		return postLedger(tx, reversals...)
+	})
+	return released, err
+}
+
+// CreatePromoCode cadastra um cupom
+func (r *PromotionRepository) CreatePromoCode(promo *models.PromoCode) error {
+	if _, err := r.FindPromoCodeByCode(promo.Code); err == nil {
+		return ErrPromoCodeExists
+	} else if !errors.Is(err, ErrPromoCodeNotFound) {
+		return err
+	}
+	return r.db.Create(promo).Error
+}
+
+// UpdatePromoCode salva as regras do cupom. O código e o contador de usos não mudam.
+func (r *PromotionRepository) UpdatePromoCode(promo *models.PromoCode) error {
+	return r.db.Model(promo).
+		Select("description", "active", "percent_off", "amount_off", "max_discount", "min_spend",
+			"max_redemptions", "per_user_limit", "first_booking_only", "starts_at", "expires_at").
+		Updates(promo).Error
+}
+
+// FindPromoCodeByID busca um cupom pelo ID
+func (r *PromotionRepository) FindPromoCodeByID(id uint) (*models.PromoCode, error) {
+	return r.findPromoCode(r.db.Where("id = ?", id))
+}
+
+// FindPromoCodeByCode busca um cupom pelo código, já normalizado em maiúsculas
+func (r *PromotionRepository) FindPromoCodeByCode(code string) (*models.PromoCode, error) {
+	return r.findPromoCode(r.db.Where("code = ?", code))
+}
+
+func (r *PromotionRepository) findPromoCode(query *gorm.DB) (*models.PromoCode, error) {
+	var promo models.PromoCode
+	if err := query.First(&promo).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPromoCodeNotFound
+		}
+		return nil, err
+	}
+	return &promo, nil
+}
+
+// GetPromoCodes lista todos os cupons
+func (r *PromotionRepository) GetPromoCodes() ([]models.PromoCode, error) {
+	var promos []models.PromoCode
+	err := r.db.Order("created_at DESC").Find(&promos).Error
+	return promos, err
+}
+
+// FindReferralCodeByUser busca o código de indicação de um cliente
+func (r *PromotionRepository) FindReferralCodeByUser(userID uint) (*models.ReferralCode, error) {
+	return r.findReferralCode(r.db.Where("user_id = ?", userID))
+}
+
+// FindReferralCode busca um código de indicação
+func (r *PromotionRepository) FindReferralCode(code string) (*models.ReferralCode, error) {
+	return r.findReferralCode(r.db.Where("code = ?", code))
+}
+
+func (r *PromotionRepository) findReferralCode(query *gorm.DB) (*models.ReferralCode, error) {
+	var code models.ReferralCode
+	if err := query.First(&code).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReferralCodeNotFound
+		}
+		return nil, err
+	}
+	return &code, nil
+}
+
+// CreateReferralCode grava o código de indicação do cliente. Se o cliente já tiver um
+// código, ele é mantido e retornado; se o código já pertencer a outro cliente, retorna
+// ErrReferralCodeExists.
+func (r *PromotionRepository) CreateReferralCode(code *models.ReferralCode) error {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(code)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	saved, err := r.FindReferralCodeByUser(code.UserID)
+	if errors.Is(err, ErrReferralCodeNotFound) {
+		return ErrReferralCodeExists
+	}
+	if err != nil {
+		return err
+	}
+	*code = *saved
+	return nil
+}
+
+// CreateReferral registra a indicação de um cliente
+func (r *PromotionRepository) CreateReferral(referral *models.Referral) error {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(referral)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAlreadyReferred
+	}
+	return nil
+}
+
+// FindReferralByReferee busca a indicação de um cliente indicado
+func (r *PromotionRepository) FindReferralByReferee(refereeID uint) (*models.Referral, error) {
+	var referral models.Referral
+	if err := r.db.Where("referee_id = ?", refereeID).First(&referral).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReferralNotFound
+		}
+		return nil, err
+	}
+	return &referral, nil
+}
+
+// GetReferralsByReferrer lista as indicações feitas por um cliente
+func (r *PromotionRepository) GetReferralsByReferrer(referrerID uint) ([]models.Referral, error) {
+	var referrals []models.Referral
+	err := r.db.Where("referrer_id = ?", referrerID).Order("created_at DESC").Find(&referrals).Error
+	return referrals, err
+}
+
+// verifiedCompletion restringe os atendimentos concluídos aos que tiveram check-out ou
+// cujo pagamento já foi repassado à prestadora, e não apenas marcados como concluídos
+const verifiedCompletion = `appointments.status = ? AND (appointments.checked_out_at IS NOT NULL OR EXISTS (
+	SELECT 1 FROM payments WHERE payments.appointment_id = appointments.id AND payments.escrow_status = ?))`
+
+// GetRewardableReferrals retorna as indicações pendentes cujo indicado já tem um
+// atendimento concluído com check-out ou repasse
+func (r *PromotionRepository) GetRewardableReferrals() ([]models.Referral, error) {
+	var referrals []models.Referral
+	err := r.db.Where("status = ?", models.ReferralStatusPending).
+		Where("EXISTS (SELECT 1 FROM appointments WHERE appointments.user_id = referrals.referee_id AND "+verifiedCompletion+")",
+			models.AppointmentStatusCompleted, models.EscrowStatusReleased).
+		Find(&referrals).Error
+	return referrals, err
+}
+
+// FirstCompletedAppointment retorna o primeiro atendimento concluído de um cliente
+func (r *PromotionRepository) FirstCompletedAppointment(userID uint) (*models.Appointment, error) {
+	return r.firstAppointment(r.db.Where("user_id = ? AND status = ?", userID, models.AppointmentStatusCompleted))
+}
+
+// FirstVerifiedAppointment retorna o primeiro atendimento do cliente concluído com
+// check-out ou repasse
+func (r *PromotionRepository) FirstVerifiedAppointment(userID uint) (*models.Appointment, error) {
+	return r.firstAppointment(r.db.Where("user_id = ?", userID).
+		Where(verifiedCompletion, models.AppointmentStatusCompleted, models.EscrowStatusReleased))
+}
+
+func (r *PromotionRepository) firstAppointment(query *gorm.DB) (*models.Appointment, error) {
+	var appointment models.Appointment
+	err := query.Order("date, id").First(&appointment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoCompletedAppointment
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &appointment, nil
+}
+
+// RewardReferral marca a indicação como recompensada e grava os créditos dos dois
+// clientes na mesma transação. Retorna falso se ela já tiver sido recompensada.
+func (r *PromotionRepository) RewardReferral(referral *models.Referral, now time.Time, credits ...*models.LedgerTransaction) (bool, error) {
+	for _, transaction := range credits {
+		if err := checkBalanced(transaction); err != nil {
+			return false, err
+		}
+	}
+
+	rewarded := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Referral{}).
+			Where("id = ? AND status = ?", referral.ID, models.ReferralStatusPending).
+			Updates(map[string]interface{}{
+				"status":          models.ReferralStatusRewarded,
+				"referrer_reward": referral.ReferrerReward,
+				"referee_reward":  referral.RefereeReward,
+				"appointment_id":  referral.AppointmentID,
+				"rewarded_at":     now,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		rewarded = true
+		return postLedger(tx, credits...)
+	})
+	if err != nil || !rewarded {
+		return false, err
+	}
+
+	referral.Status = models.ReferralStatusRewarded
+	referral.RewardedAt = &now
+	return true, nil
+}
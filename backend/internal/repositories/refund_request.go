@@ -0,0 +1,135 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/xclean/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrRefundRequestExists   = errors.New("já existe um pedido de reembolso em análise para este pagamento")
+	ErrRefundRequestNotFound = errors.New("pedido de reembolso não encontrado")
+)
+
+// CreateRefundRequest grava o pedido de reembolso do cliente com os eventos do histórico.
+// O valor pedido precisa caber no saldo reembolsável e o pagamento não pode ter outro
+// pedido em análise.
+func (r *PaymentRepository) CreateRefundRequest(request *models.RefundRequest, events ...*models.AppointmentEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Bloqueia o pagamento para não concorrer com outro pedido ou reembolso
+		var payment models.Payment
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, request.PaymentID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrPaymentNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if payment.EscrowStatus == models.EscrowStatusReleased {
+			return ErrEscrowReleased
+		}
+		if request.Amount <= 0 || request.Amount > payment.Refundable() {
+			return ErrRefundExceedsAvailable
+		}
+
+		var pending int64
+		err = tx.Model(&models.RefundRequest{}).
+			Where("payment_id = ? AND status = ?", payment.ID, models.RefundRequestStatusPending).
+			Count(&pending).Error
+		if err != nil {
+			return err
+		}
+		if pending > 0 {
+			return ErrRefundRequestExists
+		}
+
+		request.AppointmentID = payment.AppointmentID
+		request.UserID = payment.UserID
+		request.ProviderID = payment.ProviderID
+		request.Status = models.RefundRequestStatusPending
+		if err := tx.Create(request).Error; err != nil {
+			return err
+		}
+		return appendEvents(tx, events...)
+	})
+}
+
+// FindRefundRequest busca um pedido de reembolso pelo ID
+func (r *PaymentRepository) FindRefundRequest(id uint) (*models.RefundRequest, error) {
+	var request models.RefundRequest
+	if err := r.db.First(&request, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRefundRequestNotFound
+		}
+		return nil, err
+	}
+	return &request, nil
+}
+
+// GetRefundRequests retorna os pedidos de reembolso feitos pelo usuário ou dirigidos a ele
+// como prestadora, dos mais recentes aos mais antigos
+func (r *PaymentRepository) GetRefundRequests(userID uint) ([]models.RefundRequest, error) {
+	var requests []models.RefundRequest
+	err := r.db.Where("user_id = ? OR provider_id = ?", userID, userID).
+		Order("created_at DESC").
+		Find(&requests).Error
+	if err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// CloseRefundRequest grava a análise de um pedido ainda pendente com os eventos do
+// histórico. Retorna falso se ele já tiver sido analisado, para que duas decisões
+// simultâneas não sejam aplicadas.
+func (r *PaymentRepository) CloseRefundRequest(request *models.RefundRequest, events ...*models.AppointmentEvent) (bool, error) {
+	closed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.RefundRequest{}).
+			Where("id = ? AND status = ?", request.ID, models.RefundRequestStatusPending).
+			Updates(map[string]interface{}{
+				"status":      request.Status,
+				"reviewed_at": request.ReviewedAt,
+				"reviewed_by": request.ReviewedBy,
+				"review_note": request.ReviewNote,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		closed = true
+		return appendEvents(tx, events...)
+	})
+	if err != nil {
+		return false, err
+	}
+	return closed, nil
+}
+
+// CompleteRefundRequest associa ao pedido aprovado o reembolso que o aplicou e grava os
+// eventos do histórico
+func (r *PaymentRepository) CompleteRefundRequest(request *models.RefundRequest, refundID uint, events ...*models.AppointmentEvent) error {
+	request.RefundID = &refundID
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(request).Update("refund_id", refundID).Error; err != nil {
+			return err
+		}
+		return appendEvents(tx, events...)
+	})
+}
+
+// ReopenRefundRequest desfaz uma aprovação que não pôde ser aplicada
+func (r *PaymentRepository) ReopenRefundRequest(request *models.RefundRequest) error {
+	request.Status = models.RefundRequestStatusPending
+	request.ReviewedAt = nil
+	request.ReviewedBy = nil
+	request.ReviewNote = ""
+	return r.db.Model(request).
+		Select("status", "reviewed_at", "reviewed_by", "review_note").
+		Updates(request).Error
+}
@@ -0,0 +1,141 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRescheduleNotFound     = errors.New("pedido de remarcação não encontrado")
+	ErrRescheduleNotPending   = errors.New("pedido de remarcação já foi respondido")
+	ErrReschedulePendingExist = errors.New("já existe um pedido de remarcação pendente")
+	ErrNotReschedulable       = errors.New("agendamento não pode ser remarcado")
+)
+
+type RescheduleRepository struct {
+	db     *gorm.DB
+	travel TravelTimeEstimator
+}
+
+func NewRescheduleRepository(db *gorm.DB, travel TravelTimeEstimator) *RescheduleRepository {
+	return &RescheduleRepository{
+		db:     db,
+		travel: travel,
+	}
+}
+
+// Create registra um novo pedido de remarcação, desde que não haja outro pendente
+func (r *RescheduleRepository) Create(request *models.RescheduleRequest) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		err := tx.Model(&models.RescheduleRequest{}).
+			Where("appointment_id = ? AND status = ?", request.AppointmentID, models.RescheduleStatusPending).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrReschedulePendingExist
+		}
+		return tx.Create(request).Error
+	})
+}
+
+// FindByID busca um pedido de remarcação pelo ID
+func (r *RescheduleRepository) FindByID(id uint) (*models.RescheduleRequest, error) {
+	var request models.RescheduleRequest
+	if err := r.db.First(&request, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRescheduleNotFound
+		}
+		return nil, err
+	}
+	return &request, nil
+}
+
+// GetByAppointment retorna o histórico de remarcações de um agendamento
+func (r *RescheduleRepository) GetByAppointment(appointmentID uint) ([]models.RescheduleRequest, error) {
+	var requests []models.RescheduleRequest
+	if err := r.db.Where("appointment_id = ?", appointmentID).Order("created_at ASC").Find(&requests).Error; err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// Reject marca um pedido pendente como recusado
+func (r *RescheduleRepository) Reject(request *models.RescheduleRequest, respondedBy uint) error {
+	return r.respond(r.db, request, models.RescheduleStatusRejected, respondedBy)
+}
+
+// Counter encerra um pedido pendente com uma contraproposta
+func (r *RescheduleRepository) Counter(request *models.RescheduleRequest, counter *models.RescheduleRequest) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := r.respond(tx, request, models.RescheduleStatusCountered, counter.ProposedBy); err != nil {
+			return err
+		}
+		counter.ParentID = &request.ID
+		return tx.Create(counter).Error
+	})
+}
+
+// Accept aplica o novo horário ao agendamento. A disponibilidade da prestadora é
+// verificada novamente dentro da mesma transação, com a agenda dela bloqueada.
+func (r *RescheduleRepository) Accept(request *models.RescheduleRequest, appointment *models.Appointment, respondedBy uint, events ...*models.AppointmentEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockProvider(tx, appointment.ProviderID); err != nil {
+			return err
+		}
+
+		if err := ensureProviderAvailable(tx, appointment, appointment.ID, r.travel); err != nil {
+			return err
+		}
+		if err := enforceBookingCaps(tx, appointment, appointment.ID); err != nil {
+			return err
+		}
+
+		if err := r.respond(tx, request, models.RescheduleStatusAccepted, respondedBy); err != nil {
+			return err
+		}
+
+		// O agendamento pode ter sido cancelado ou concluído depois do pedido
+		result := tx.Model(&models.Appointment{}).
+			Where("id = ? AND status IN ?", appointment.ID, []models.AppointmentStatus{models.AppointmentStatusPending, models.AppointmentStatusConfirmed}).
+			Updates(map[string]interface{}{
+				"date": appointment.Date,
+				"time": appointment.Time,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotReschedulable
+		}
+		return appendEvents(tx, events...)
+	})
+}
+
+// respond atualiza o status de um pedido que ainda esteja pendente
+func (r *RescheduleRepository) respond(tx *gorm.DB, request *models.RescheduleRequest, status models.RescheduleStatus, respondedBy uint) error {
+	now := time.Now()
+	result := tx.Model(&models.RescheduleRequest{}).
+		Where("id = ? AND status = ?", request.ID, models.RescheduleStatusPending).
+		Updates(map[string]interface{}{
+			"status":       status,
+			"responded_by": respondedBy,
+			"responded_at": now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRescheduleNotPending
+	}
+
+	request.Status = status
+	request.RespondedBy = &respondedBy
+	request.RespondedAt = &now
+	return nil
+}
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/testutil"
+)
+
+func TestRescheduleNegotiation(t *testing.T) {
+	db := testutil.NewDB(t)
+	repo := NewRescheduleRepository(db, nil)
+	client := testutil.CreateUser(t, db, models.UserTypeClient)
+	provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	appointment := testutil.CreateAppointment(t, db, models.Appointment{
+		UserID: client.ID, ProviderID: provider.ID, Status: models.AppointmentStatusConfirmed,
+		Date: day, Time: "09:00", Duration: 120, Price: 120,
+	})
+	// Outro cliente já ocupa a tarde do mesmo dia
+	testutil.CreateAppointment(t, db, models.Appointment{
+		UserID: testutil.CreateUser(t, db, models.UserTypeClient).ID, ProviderID: provider.ID,
+		Status: models.AppointmentStatusConfirmed, Date: day, Time: "14:00", Duration: 120,
+	})
+
+	request := &models.RescheduleRequest{
+		AppointmentID: appointment.ID, ProposedBy: client.ID, Date: day, Time: "14:00",
+		PreviousDate: appointment.Date, PreviousTime: appointment.Time, Status: models.RescheduleStatusPending,
+	}
+	if err := repo.Create(request); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	second := *request
+	second.ID = 0
+	if err := repo.Create(&second); !errors.Is(err, ErrReschedulePendingExist) {
+		t.Fatalf("segundo pedido pendente: err = %v, want ErrReschedulePendingExist", err)
+	}
+
+	// A prestadora responde com outro horário, e o pedido original sai de pendente
+	counter := &models.RescheduleRequest{
+		AppointmentID: appointment.ID, ProposedBy: provider.ID, Date: day, Time: "11:00",
+		PreviousDate: appointment.Date, PreviousTime: appointment.Time, Status: models.RescheduleStatusPending,
+	}
+	if err := repo.Counter(request, counter); err != nil {
+		t.Fatalf("Counter: %v", err)
+	}
+	if err := repo.Reject(request, provider.ID); !errors.Is(err, ErrRescheduleNotPending) {
+		t.Fatalf("recusar pedido já respondido: err = %v, want ErrRescheduleNotPending", err)
+	}
+	if counter.ParentID == nil || *counter.ParentID != request.ID {
+		t.Fatalf("contraproposta sem vínculo com o pedido %d: %+v", request.ID, counter)
+	}
+
+	// O horário da tarde está ocupado; o da contraproposta, não
+	busy := *appointment
+	busy.Time = "14:00"
+	if err := repo.Accept(counter, &busy, client.ID); !errors.Is(err, ErrSlotUnavailable) {
+		t.Fatalf("aceitar horário ocupado: err = %v, want ErrSlotUnavailable", err)
+	}
+	moved := *appointment
+	moved.Time = counter.Time
+	if err := repo.Accept(counter, &moved, client.ID); err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+
+	history, err := repo.GetByAppointment(appointment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Status != models.RescheduleStatusCountered || history[1].Status != models.RescheduleStatusAccepted {
+		t.Errorf("histórico %+v; want pedido com contraproposta e contraproposta aceita", history)
+	}
+	var stored models.Appointment
+	db.First(&stored, appointment.ID)
+	if stored.Time != "11:00" {
+		t.Errorf("horário gravado %s, want 11:00", stored.Time)
+	}
+}
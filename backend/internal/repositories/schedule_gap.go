@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// MaxTravelTime limita a estimativa de deslocamento entre dois atendimentos.
+// Atendimentos mais distantes que isso (somado ao intervalo) nunca consultam a estimativa.
+const MaxTravelTime = 3 * time.Hour
+
+// TravelTimeEstimator estima o tempo de deslocamento entre dois pontos.
+// A implementação padrão usa a distância em linha reta e uma velocidade média,
+// mas pode ser trocada por um serviço de rotas.
+type TravelTimeEstimator interface {
+	TravelTime(fromLat, fromLng, toLat, toLng float64) (time.Duration, error)
+}
+
+// ScheduleGap é o intervalo mínimo entre atendimentos consecutivos de uma prestadora:
+// o intervalo fixo configurado mais, se habilitado, o deslocamento entre os endereços
+type ScheduleGap struct {
+	Buffer time.Duration
+	Travel TravelTimeEstimator // nil quando a prestadora não usa tempo de deslocamento
+}
+
+// ScheduleItem é um intervalo na agenda da prestadora. Sem coordenadas (0, 0),
+// apenas o intervalo fixo é considerado.
+type ScheduleItem struct {
+	Start     time.Time
+	End       time.Time
+	Latitude  float64
+	Longitude float64
+}
+
+// AppointmentScheduleItem posiciona um agendamento na agenda
+func AppointmentScheduleItem(appointment *models.Appointment) ScheduleItem {
+	return ScheduleItem{
+		Start:     appointment.StartsAt(),
+		End:       appointment.EndsAt(),
+		Latitude:  appointment.Latitude,
+		Longitude: appointment.Longitude,
+	}
+}
+
+// Between retorna o intervalo necessário para ir de from até to
+func (g ScheduleGap) Between(from, to ScheduleItem) (time.Duration, error) {
+	gap := g.Buffer
+	if g.Travel == nil || !hasLocation(from) || !hasLocation(to) {
+		return gap, nil
+	}
+
+	travel, err := g.Travel.TravelTime(from.Latitude, from.Longitude, to.Latitude, to.Longitude)
+	if err != nil {
+		return 0, err
+	}
+	return gap + min(travel, MaxTravelTime), nil
+}
+
+// Blocks indica se o item existente busy impede o atendimento candidate
+func (g ScheduleGap) Blocks(busy, candidate ScheduleItem) (bool, error) {
+	switch {
+	case !busy.End.After(candidate.Start):
+		// busy termina antes do candidato começar
+		if !busy.End.Add(g.Buffer + MaxTravelTime).After(candidate.Start) {
+			return false, nil
+		}
+		gap, err := g.Between(busy, candidate)
+		if err != nil {
+			return false, err
+		}
+		return busy.End.Add(gap).After(candidate.Start), nil
+	case !candidate.End.After(busy.Start):
+		// o candidato termina antes de busy começar
+		if !candidate.End.Add(g.Buffer + MaxTravelTime).After(busy.Start) {
+			return false, nil
+		}
+		gap, err := g.Between(candidate, busy)
+		if err != nil {
+			return false, err
+		}
+		return candidate.End.Add(gap).After(busy.Start), nil
+	}
+	return true, nil
+}
+
+// ScheduleGap retorna o intervalo mínimo entre atendimentos configurado pela prestadora
+func (r *AppointmentRepository) ScheduleGap(providerID uint) (ScheduleGap, error) {
+	return loadScheduleGap(r.db, providerID, r.travel)
+}
+
+// loadScheduleGap lê a configuração de intervalo da prestadora. Sem perfil, não há intervalo.
+func loadScheduleGap(tx *gorm.DB, providerID uint, travel TravelTimeEstimator) (ScheduleGap, error) {
+	var profile models.ProviderProfile
+	err := tx.Select("buffer_minutes", "travel_time_enabled").Where("user_id = ?", providerID).First(&profile).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ScheduleGap{}, nil
+	}
+	if err != nil {
+		return ScheduleGap{}, err
+	}
+
+	gap := ScheduleGap{Buffer: time.Duration(profile.BufferMinutes) * time.Minute}
+	if profile.TravelTimeEnabled {
+		gap.Travel = travel
+	}
+	return gap, nil
+}
+
+func hasLocation(item ScheduleItem) bool {
+	return item.Latitude != 0 || item.Longitude != 0
+}
@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/testutil"
+)
+
+// fixedTravel estima sempre o mesmo deslocamento, independente da distância
+type fixedTravel time.Duration
+
+func (f fixedTravel) TravelTime(fromLat, fromLng, toLat, toLng float64) (time.Duration, error) {
+	return time.Duration(f), nil
+}
+
+func TestCreateWithoutConflictKeepsBufferAndTravel(t *testing.T) {
+	tests := []struct {
+		name    string
+		travel  bool   // Deslocamento habilitado no perfil da prestadora
+		time    string // Início do novo agendamento; o existente vai das 09:00 às 11:00
+		located bool   // O novo agendamento tem coordenadas
+		wantErr error
+	}{
+		{"dentro do intervalo fixo", false, "11:10", false, ErrSlotUnavailable},
+		{"depois do intervalo fixo", false, "11:15", true, nil},
+		{"sem tempo para o deslocamento", true, "11:15", true, ErrSlotUnavailable},
+		{"depois do deslocamento", true, "11:45", true, nil},
+		{"sem endereço vale só o intervalo fixo", true, "11:15", false, nil},
+		{"antes do existente, sem tempo para chegar", true, "07:00", true, ErrSlotUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			client := testutil.CreateUser(t, db, models.UserTypeClient)
+			provider := testutil.CreateProvider(t, db, models.ProviderProfile{BufferMinutes: 15, TravelTimeEnabled: tt.travel})
+			day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+			testutil.CreateAppointment(t, db, models.Appointment{
+				UserID: client.ID, ProviderID: provider.ID, Date: day, Time: "09:00", Duration: 120,
+				Latitude: -23.55, Longitude: -46.63, Status: models.AppointmentStatusConfirmed,
+			})
+
+			appointment := &models.Appointment{
+				UserID: client.ID, ProviderID: provider.ID, Service: "Limpeza", Date: day, Time: tt.time, Duration: 120,
+				Status: models.AppointmentStatusPending,
+			}
+			if tt.located {
+				appointment.Latitude, appointment.Longitude = -23.60, -46.70
+			}
+			err := NewAppointmentRepository(db, fixedTravel(30*time.Minute)).CreateWithoutConflict(appointment, nil, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CreateWithoutConflict: %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
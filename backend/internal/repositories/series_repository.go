@@ -0,0 +1,189 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSeriesNotFound = errors.New("série de agendamentos não encontrada")
+)
+
+type SeriesRepository struct {
+	db     *gorm.DB
+	travel TravelTimeEstimator
+}
+
+func NewSeriesRepository(db *gorm.DB, travel TravelTimeEstimator) *SeriesRepository {
+	return &SeriesRepository{
+		db:     db,
+		travel: travel,
+	}
+}
+
+// Create cria uma nova série de agendamentos
+func (r *SeriesRepository) Create(series *models.AppointmentSeries) error {
+	return r.db.Create(series).Error
+}
+
+// FindByID busca uma série pelo ID
+func (r *SeriesRepository) FindByID(id uint) (*models.AppointmentSeries, error) {
+	var series models.AppointmentSeries
+	if err := r.db.First(&series, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSeriesNotFound
+		}
+		return nil, err
+	}
+	return &series, nil
+}
+
+// GetByParticipant retorna as séries em que o usuário é cliente ou prestadora
+func (r *SeriesRepository) GetByParticipant(userID uint) ([]models.AppointmentSeries, error) {
+	var series []models.AppointmentSeries
+	if err := r.db.Where("user_id = ? OR provider_id = ?", userID, userID).Order("created_at DESC").Find(&series).Error; err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// GetDueForMaterialization retorna as séries ativas cujas ocorrências não chegam até horizon
+func (r *SeriesRepository) GetDueForMaterialization(horizon time.Time) ([]models.AppointmentSeries, error) {
+	var series []models.AppointmentSeries
+	err := r.db.Where("status = ? AND materialized_until < ?", models.SeriesStatusActive, horizon).Find(&series).Error
+	if err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// GetOccurrences retorna os agendamentos já materializados de uma série
+func (r *SeriesRepository) GetOccurrences(seriesID uint) ([]models.Appointment, error) {
+	var appointments []models.Appointment
+	if err := r.db.Where("series_id = ?", seriesID).Order("occurrence_date ASC").Find(&appointments).Error; err != nil {
+		return nil, err
+	}
+	return appointments, nil
+}
+
+// GetFollowingOccurrences retorna as ocorrências ativas a partir de uma data prevista
+func (r *SeriesRepository) GetFollowingOccurrences(seriesID uint, from time.Time) ([]models.Appointment, error) {
+	var appointments []models.Appointment
+	err := r.db.Where("series_id = ? AND occurrence_date >= ? AND status IN ?", seriesID, from,
+		[]models.AppointmentStatus{models.AppointmentStatusPending, models.AppointmentStatusConfirmed}).
+		Order("occurrence_date ASC").Find(&appointments).Error
+	if err != nil {
+		return nil, err
+	}
+	return appointments, nil
+}
+
+// GetConflicts retorna as ocorrências que não puderam ser criadas
+func (r *SeriesRepository) GetConflicts(seriesID uint) ([]models.SeriesConflict, error) {
+	var conflicts []models.SeriesConflict
+	if err := r.db.Where("series_id = ?", seriesID).Order("occurrence_date ASC").Find(&conflicts).Error; err != nil {
+		return nil, err
+	}
+	return conflicts, nil
+}
+
+// MaterializeOccurrence cria a ocorrência se ainda não existir e se a prestadora estiver livre.
+// Em caso de conflito, o conflito é registrado e retornado sem erro. Se a prestadora já
+// atingiu o limite de agendamentos, o conflito também é registrado e o erro do limite é retornado.
+func (r *SeriesRepository) MaterializeOccurrence(occurrence *models.Appointment, events ...*models.AppointmentEvent) (created bool, conflicts []models.Appointment, err error) {
+	var limitErr error
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockProvider(tx, occurrence.ProviderID); err != nil {
+			return err
+		}
+
+		var count int64
+		err := tx.Model(&models.Appointment{}).
+			Where("series_id = ? AND occurrence_date = ?", occurrence.SeriesID, occurrence.OccurrenceDate).
+			Count(&count).Error
+		if err != nil || count > 0 {
+			return err
+		}
+
+		gap, err := loadScheduleGap(tx, occurrence.ProviderID, r.travel)
+		if err != nil {
+			return err
+		}
+		conflicts, err = findScheduleConflicts(tx, occurrence, 0, gap)
+		if err != nil {
+			return err
+		}
+		if len(conflicts) > 0 {
+			return tx.Create(&models.SeriesConflict{
+				SeriesID:                 *occurrence.SeriesID,
+				OccurrenceDate:           *occurrence.OccurrenceDate,
+				ConflictingAppointmentID: conflicts[0].ID,
+			}).Error
+		}
+
+		// A ocorrência que ultrapassa o limite da prestadora também fica registrada, e o
+		// erro é devolvido depois da transação para não desfazer o registro
+		if capErr := enforceBookingCaps(tx, occurrence, 0); IsBookingCapError(capErr) {
+			limitErr = capErr
+			return tx.Create(&models.SeriesConflict{
+				SeriesID:       *occurrence.SeriesID,
+				OccurrenceDate: *occurrence.OccurrenceDate,
+				Reason:         capErr.Error(),
+			}).Error
+		} else if capErr != nil {
+			return capErr
+		}
+
+		if err := tx.Create(occurrence).Error; err != nil {
+			return err
+		}
+		created = true
+		return appendEvents(tx, eventsFor(occurrence.ID, events)...)
+	})
+	if err == nil {
+		err = limitErr
+	}
+	return created, conflicts, err
+}
+
+// UpdateMaterializedUntil registra até onde as ocorrências da série foram geradas
+func (r *SeriesRepository) UpdateMaterializedUntil(seriesID uint, until time.Time) error {
+	return r.db.Model(&models.AppointmentSeries{}).Where("id = ?", seriesID).Update("materialized_until", until).Error
+}
+
+// MoveOccurrence altera o horário de uma única ocorrência, verificando a disponibilidade da prestadora
+func (r *SeriesRepository) MoveOccurrence(occurrence *models.Appointment, events ...*models.AppointmentEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockProvider(tx, occurrence.ProviderID); err != nil {
+			return err
+		}
+
+		if err := ensureProviderAvailable(tx, occurrence, occurrence.ID, r.travel); err != nil {
+			return err
+		}
+		if err := enforceBookingCaps(tx, occurrence, occurrence.ID); err != nil {
+			return err
+		}
+
+		err := tx.Model(&models.Appointment{}).Where("id = ?", occurrence.ID).Updates(map[string]interface{}{
+			"date":  occurrence.Date,
+			"time":  occurrence.Time,
+			"price": occurrence.Price,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return appendEvents(tx, events...)
+	})
+}
+
+// End encerra a série para ocorrências a partir de before
+func (r *SeriesRepository) End(seriesID uint, before time.Time) error {
+	return r.db.Model(&models.AppointmentSeries{}).Where("id = ?", seriesID).Updates(map[string]interface{}{
+		"status":      models.SeriesStatusEnded,
+		"ends_before": before,
+	}).Error
+}
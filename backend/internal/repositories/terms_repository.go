@@ -0,0 +1,167 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/xclean/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrTermsNotFound        = errors.New("condições do agendamento não encontradas")
+	ErrTermsOutdated        = errors.New("as condições foram alteradas; confirme a versão mais recente")
+	ErrTermsAcknowledged    = errors.New("esta versão das condições já foi reconhecida")
+	ErrTermsNotAcknowledged = errors.New("a prestadora precisa dar ciência das condições do agendamento")
+)
+
+// TermsRepository guarda as versões das condições dos agendamentos.
+// As versões e as ciências são somente de inclusão: não há métodos de alteração ou remoção.
+type TermsRepository struct {
+	db *gorm.DB
+}
+
+func NewTermsRepository(db *gorm.DB) *TermsRepository {
+	return &TermsRepository{
+		db: db,
+	}
+}
+
+// CreateVersion grava uma nova versão das condições, numerada após a última existente.
+// Uma reserva instantânea ainda confirmada volta a aguardar a prestadora, que não deu ciência
+// das novas condições; nesse caso reverted é gravado no histórico e o retorno é verdadeiro.
+func (r *TermsRepository) CreateVersion(terms *models.AppointmentTerms, record TermsRecorder, reverted *models.AppointmentEvent) (bool, error) {
+	pending := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Serializa as novas versões do mesmo agendamento
+		var appointment models.Appointment
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "status", "instant_booked").First(&appointment, terms.AppointmentID).Error
+		if err != nil {
+			return err
+		}
+
+		var last int
+		err = tx.Model(&models.AppointmentTerms{}).Where("appointment_id = ?", terms.AppointmentID).
+			Select("COALESCE(MAX(version), 0)").Scan(&last).Error
+		if err != nil {
+			return err
+		}
+
+		terms.Version = last + 1
+		terms.ContentHash = terms.Content.Hash()
+		if err := tx.Create(terms).Error; err != nil {
+			return err
+		}
+
+		if appointment.InstantBooked && appointment.Status == models.AppointmentStatusConfirmed {
+			err := tx.Model(&models.Appointment{}).Where("id = ?", appointment.ID).Updates(map[string]interface{}{
+				"status":         models.AppointmentStatusPending,
+				"instant_booked": false,
+			}).Error
+			if err != nil {
+				return err
+			}
+			pending = true
+			if err := appendEvents(tx, reverted); err != nil {
+				return err
+			}
+		}
+		return recordTerms(tx, record, terms)
+	})
+	if err != nil {
+		return false, err
+	}
+	return pending, nil
+}
+
+// TermsRecorder monta os eventos do histórico a partir da versão das condições gravada ou
+// reconhecida. É chamado dentro da transação e não deve acessar o banco.
+type TermsRecorder func(terms *models.AppointmentTerms) []*models.AppointmentEvent
+
+func recordTerms(tx *gorm.DB, record TermsRecorder, terms *models.AppointmentTerms) error {
+	if record == nil {
+		return nil
+	}
+	return appendEvents(tx, record(terms)...)
+}
+
+// GetVersions retorna todas as versões das condições de um agendamento, com suas ciências
+func (r *TermsRepository) GetVersions(appointmentID uint) ([]models.AppointmentTerms, error) {
+	var versions []models.AppointmentTerms
+	err := r.db.Preload("Acknowledgement").Where("appointment_id = ?", appointmentID).
+		Order("version ASC").Find(&versions).Error
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// GetLatest retorna a versão mais recente das condições de um agendamento
+func (r *TermsRepository) GetLatest(appointmentID uint) (*models.AppointmentTerms, error) {
+	var terms models.AppointmentTerms
+	err := r.db.Preload("Acknowledgement").Where("appointment_id = ?", appointmentID).
+		Order("version DESC").First(&terms).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTermsNotFound
+		}
+		return nil, err
+	}
+	return &terms, nil
+}
+
+// Acknowledge registra a ciência da prestadora sobre a versão informada, desde que
+// ela ainda seja a mais recente
+func (r *TermsRepository) Acknowledge(appointmentID uint, version int, providerID uint, record TermsRecorder) (*models.TermsAcknowledgement, error) {
+	var ack *models.TermsAcknowledgement
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var appointment models.Appointment
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&appointment, appointmentID).Error
+		if err != nil {
+			return err
+		}
+
+		var latest models.AppointmentTerms
+		err = tx.Preload("Acknowledgement").Where("appointment_id = ?", appointmentID).
+			Order("version DESC").First(&latest).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTermsNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if latest.Version != version {
+			return ErrTermsOutdated
+		}
+		if latest.Acknowledgement != nil {
+			return ErrTermsAcknowledged
+		}
+
+		ack = &models.TermsAcknowledgement{
+			TermsID:     latest.ID,
+			ProviderID:  providerID,
+			ContentHash: latest.ContentHash,
+		}
+		if err := tx.Create(ack).Error; err != nil {
+			return err
+		}
+		return recordTerms(tx, record, &latest)
+	})
+	return ack, err
+}
+
+// EnsureAcknowledged retorna ErrTermsNotAcknowledged se a versão mais recente das condições
+// ainda não tiver ciência da prestadora. Agendamentos sem condições não exigem ciência.
+func (r *TermsRepository) EnsureAcknowledged(appointmentID uint) error {
+	latest, err := r.GetLatest(appointmentID)
+	if errors.Is(err, ErrTermsNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if latest.Acknowledgement == nil {
+		return ErrTermsNotAcknowledged
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/testutil"
+)
+
+func TestTermsVersionsAndAcknowledgement(t *testing.T) {
+	db := testutil.NewDB(t)
+	repo := NewTermsRepository(db)
+	client := testutil.CreateUser(t, db, models.UserTypeClient)
+	provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+	appointment := testutil.CreateAppointment(t, db, models.Appointment{
+		UserID: client.ID, ProviderID: provider.ID, Date: time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC),
+	})
+
+	// Sem condições não há o que reconhecer
+	if err := repo.EnsureAcknowledged(appointment.ID); err != nil {
+		t.Fatalf("EnsureAcknowledged sem condições: %v", err)
+	}
+
+	create := func(services ...string) *models.AppointmentTerms {
+		terms := &models.AppointmentTerms{
+			AppointmentID: appointment.ID, AuthorID: client.ID,
+			Content: models.TermsContent{IncludedServices: services},
+		}
+		if _, err := repo.CreateVersion(terms, nil, nil); err != nil {
+			t.Fatalf("CreateVersion: %v", err)
+		}
+		return terms
+	}
+	first := create("Limpeza da cozinha")
+	if first.Version != 1 || first.ContentHash != first.Content.Hash() {
+		t.Errorf("primeira versão %d com hash %q; want 1 com o hash do conteúdo", first.Version, first.ContentHash)
+	}
+	if err := repo.EnsureAcknowledged(appointment.ID); !errors.Is(err, ErrTermsNotAcknowledged) {
+		t.Errorf("EnsureAcknowledged: %v, want %v", err, ErrTermsNotAcknowledged)
+	}
+
+	second := create("Limpeza da cozinha", "Passar roupa")
+	if second.Version != 2 || second.ContentHash == first.ContentHash {
+		t.Errorf("segunda versão %d com o mesmo hash %v; want 2 com hash novo", second.Version, second.ContentHash == first.ContentHash)
+	}
+
+	// Só a versão mais recente pode ser reconhecida, e uma única vez
+	if _, err := repo.Acknowledge(appointment.ID, 1, provider.ID, nil); !errors.Is(err, ErrTermsOutdated) {
+		t.Errorf("Acknowledge da versão antiga: %v, want %v", err, ErrTermsOutdated)
+	}
+	ack, err := repo.Acknowledge(appointment.ID, 2, provider.ID, nil)
+	if err != nil {
+		t.Fatalf("Acknowledge: %v", err)
+	}
+	if ack.TermsID != second.ID || ack.ContentHash != second.ContentHash {
+		t.Errorf("ciência da versão %d com hash %q; want versão %d com hash %q", ack.TermsID, ack.ContentHash, second.ID, second.ContentHash)
+	}
+	if _, err := repo.Acknowledge(appointment.ID, 2, provider.ID, nil); !errors.Is(err, ErrTermsAcknowledged) {
+		t.Errorf("Acknowledge repetido: %v, want %v", err, ErrTermsAcknowledged)
+	}
+	if err := repo.EnsureAcknowledged(appointment.ID); err != nil {
+		t.Errorf("EnsureAcknowledged depois da ciência: %v", err)
+	}
+
+	versions, err := repo.GetVersions(appointment.ID)
+	if err != nil {
+		t.Fatalf("GetVersions: %v", err)
+	}
+	if len(versions) != 2 || versions[0].Acknowledgement != nil || versions[1].Acknowledgement == nil {
+		t.Errorf("histórico com %d versões; want 2, só a última reconhecida", len(versions))
+	}
+
+	// Uma nova versão exige nova ciência
+	create("Limpeza da cozinha")
+	if err := repo.EnsureAcknowledged(appointment.ID); !errors.Is(err, ErrTermsNotAcknowledged) {
+		t.Errorf("EnsureAcknowledged depois da nova versão: %v, want %v", err, ErrTermsNotAcknowledged)
+	}
+}
+
+func TestNewTermsReturnInstantBookingsToProvider(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      models.AppointmentStatus
+		instant     bool
+		wantPending bool
+		wantStatus  models.AppointmentStatus
+	}{
+		{"reserva instantânea confirmada", models.AppointmentStatusConfirmed, true, true, models.AppointmentStatusPending},
+		{"confirmada pela prestadora", models.AppointmentStatusConfirmed, false, false, models.AppointmentStatusConfirmed},
+		{"aguardando a prestadora", models.AppointmentStatusPending, false, false, models.AppointmentStatusPending},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			client := testutil.CreateUser(t, db, models.UserTypeClient)
+			provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+			appointment := testutil.CreateAppointment(t, db, models.Appointment{
+				UserID: client.ID, ProviderID: provider.ID, Status: tt.status, InstantBooked: tt.instant,
+				Date: time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC),
+			})
+
+			terms := &models.AppointmentTerms{
+				AppointmentID: appointment.ID, AuthorID: client.ID,
+				Content: models.TermsContent{IncludedServices: []string{"Limpeza da cozinha"}},
+			}
+			reverted := &models.AppointmentEvent{AppointmentID: appointment.ID, ActorID: &client.ID, Type: models.AppointmentEventStatusChanged}
+			pending, err := NewTermsRepository(db).CreateVersion(terms, nil, reverted)
+			if err != nil {
+				t.Fatalf("CreateVersion: %v", err)
+			}
+
+			var stored models.Appointment
+			db.First(&stored, appointment.ID)
+			var events int64
+			db.Model(&models.AppointmentEvent{}).Where("appointment_id = ? AND type = ?", appointment.ID, models.AppointmentEventStatusChanged).Count(&events)
+			if pending != tt.wantPending || stored.Status != tt.wantStatus {
+				t.Errorf("pending %v, status %s; want %v, %s", pending, stored.Status, tt.wantPending, tt.wantStatus)
+			}
+			if tt.wantPending && (stored.InstantBooked || events != 1) {
+				t.Errorf("instant_booked %v com %d eventos; want falso com 1", stored.InstantBooked, events)
+			}
+			if !tt.wantPending && events != 0 {
+				t.Errorf("%d eventos de status, want 0", events)
+			}
+		})
+	}
+}
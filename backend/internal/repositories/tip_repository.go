@@ -0,0 +1,143 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrTipNotFound    = errors.New("gorjeta não encontrada")
+	ErrTipAlreadySent = errors.New("a gorjeta deste atendimento já foi enviada")
+	ErrTipNotPending  = errors.New("a cobrança da gorjeta já foi concluída")
+)
+
+type TipRepository struct {
+	db *gorm.DB
+}
+
+func NewTipRepository(db *gorm.DB) *TipRepository {
+	return &TipRepository{
+		db: db,
+	}
+}
+
+// Reserve grava a gorjeta como pendente antes da cobrança. Uma gorjeta recusada pode ser
+// substituída; se já houver uma pendente ou paga, retorna ErrTipAlreadySent.
+func (r *TipRepository) Reserve(tip *models.Tip) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var existing models.Tip
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("appointment_id = ?", tip.AppointmentID).
+			First(&existing).Error
+
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			tip.Status = models.TipStatusPending
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(tip)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				// Outra requisição gravou a gorjeta ao mesmo tempo
+				return ErrTipAlreadySent
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if existing.Status != models.TipStatusFailed {
+			return ErrTipAlreadySent
+		}
+
+		tip.ID = existing.ID
+		tip.CreatedAt = existing.CreatedAt
+		tip.Status = models.TipStatusPending
+		tip.GatewayIntentID = ""
+		tip.FailureReason = ""
+		return tx.Model(tip).
+			Select("amount", "currency", "message", "status", "payment_method_id", "gateway_intent_id", "failure_reason").
+			Updates(tip).Error
+	})
+}
+
+// UpdateStatus salva o andamento da cobrança da gorjeta pendente, os lançamentos no razão
+// e os eventos do histórico na mesma transação. Retorna ErrTipNotPending se a cobrança já
+// tiver sido concluída por outra execução.
+func (r *TipRepository) UpdateStatus(tip *models.Tip, ledger []*models.LedgerTransaction, events ...*models.AppointmentEvent) error {
+	if err := checkAllBalanced(ledger); err != nil {
+		return err
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(tip).
+			Where("status = ?", models.TipStatusPending).
+			Select("status", "gateway_intent_id", "failure_reason", "paid_at").
+			Updates(tip)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTipNotPending
+		}
+		if err := postLedger(tx, ledger...); err != nil {
+			return err
+		}
+		return appendEvents(tx, events...)
+	})
+}
+
+// GetForReconciliation retorna as gorjetas pagas no período e as gorjetas informadas,
+// que tiveram lançamentos no razão no período
+func (r *TipRepository) GetForReconciliation(from, to time.Time, ids []uint) ([]models.Tip, error) {
+	query := r.db.Where("paid_at >= ? AND paid_at < ?", from, to)
+	if len(ids) > 0 {
+		query = query.Or("id IN ?", ids)
+	}
+
+	var tips []models.Tip
+	if err := query.Order("id").Find(&tips).Error; err != nil {
+		return nil, err
+	}
+	return tips, nil
+}
+
+// GetPendingBefore retorna as gorjetas com cobrança pendente desde antes de before
+func (r *TipRepository) GetPendingBefore(before time.Time) ([]models.Tip, error) {
+	var tips []models.Tip
+	if err := r.db.Where("status = ? AND updated_at < ?", models.TipStatusPending, before).Find(&tips).Error; err != nil {
+		return nil, err
+	}
+	return tips, nil
+}
+
+// FindByAppointment busca a gorjeta de um agendamento
+func (r *TipRepository) FindByAppointment(appointmentID uint) (*models.Tip, error) {
+	var tip models.Tip
+	if err := r.db.Where("appointment_id = ?", appointmentID).First(&tip).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTipNotFound
+		}
+		return nil, err
+	}
+	return &tip, nil
+}
+
+// GetByUser retorna as gorjetas enviadas pelo cliente
+func (r *TipRepository) GetByUser(userID uint) ([]models.Tip, error) {
+	var tips []models.Tip
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&tips).Error
+	return tips, err
+}
+
+// GetPaidByProvider retorna as gorjetas recebidas pela prestadora no período [from, to)
+func (r *TipRepository) GetPaidByProvider(providerID uint, from, to time.Time) ([]models.Tip, error) {
+	var tips []models.Tip
+	err := r.db.Where("provider_id = ? AND status = ? AND paid_at >= ? AND paid_at < ?", providerID, models.TipStatusSucceeded, from, to).
+		Order("paid_at").
+		Find(&tips).Error
+	return tips, err
+}
@@ -0,0 +1,244 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrWaitlistEntryNotFound = errors.New("inscrição na lista de espera não encontrada")
+	ErrWaitlistOfferNotFound = errors.New("oferta de vaga não encontrada")
+	ErrWaitlistOfferClosed   = errors.New("oferta de vaga não está mais disponível")
+)
+
+type WaitlistRepository struct {
+	db     *gorm.DB
+	travel TravelTimeEstimator
+}
+
+func NewWaitlistRepository(db *gorm.DB, travel TravelTimeEstimator) *WaitlistRepository {
+	return &WaitlistRepository{
+		db:     db,
+		travel: travel,
+	}
+}
+
+// CreateEntry inscreve o cliente na lista de espera
+func (r *WaitlistRepository) CreateEntry(entry *models.WaitlistEntry) error {
+	return r.db.Create(entry).Error
+}
+
+// FindEntry busca uma inscrição pelo ID
+func (r *WaitlistRepository) FindEntry(id uint) (*models.WaitlistEntry, error) {
+	var entry models.WaitlistEntry
+	if err := r.db.First(&entry, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWaitlistEntryNotFound
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetEntriesByUser retorna as inscrições de um cliente
+func (r *WaitlistRepository) GetEntriesByUser(userID uint) ([]models.WaitlistEntry, error) {
+	var entries []models.WaitlistEntry
+	if err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// CancelEntry retira o cliente da lista de espera e encerra uma oferta pendente
+func (r *WaitlistRepository) CancelEntry(entry *models.WaitlistEntry) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.WaitlistOffer{}).
+			Where("entry_id = ? AND status = ?", entry.ID, models.WaitlistOfferStatusPending).
+			Update("status", models.WaitlistOfferStatusDeclined).Error
+		if err != nil {
+			return err
+		}
+
+		entry.Status = models.WaitlistEntryStatusCancelled
+		return tx.Model(&models.WaitlistEntry{}).Where("id = ?", entry.ID).Update("status", entry.Status).Error
+	})
+}
+
+// GetWaitingForDay retorna, por ordem de inscrição, os clientes aguardando vaga com a
+// prestadora cujo período inclui o dia informado e que ainda não receberam a vaga slotStart
+func (r *WaitlistRepository) GetWaitingForDay(providerID uint, day, slotStart time.Time) ([]models.WaitlistEntry, error) {
+	var entries []models.WaitlistEntry
+	err := r.db.Where("provider_id = ? AND status = ? AND window_start <= ? AND window_end >= ?",
+		providerID, models.WaitlistEntryStatusWaiting, day, day).
+		Where("id NOT IN (?)", r.db.Model(&models.WaitlistOffer{}).Select("entry_id").
+			Where("provider_id = ? AND slot_start = ?", providerID, slotStart)).
+		Order("created_at ASC, id ASC").
+		Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// CreateOffer registra a oferta de vaga e marca a inscrição como ofertada
+func (r *WaitlistRepository) CreateOffer(offer *models.WaitlistOffer) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.WaitlistEntry{}).
+			Where("id = ? AND status = ?", offer.EntryID, models.WaitlistEntryStatusWaiting).
+			Update("status", models.WaitlistEntryStatusOffered)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrWaitlistOfferClosed
+		}
+		return tx.Create(offer).Error
+	})
+}
+
+// FindOffer busca uma oferta pelo ID
+func (r *WaitlistRepository) FindOffer(id uint) (*models.WaitlistOffer, error) {
+	var offer models.WaitlistOffer
+	if err := r.db.First(&offer, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWaitlistOfferNotFound
+		}
+		return nil, err
+	}
+	return &offer, nil
+}
+
+// GetPendingOffersByUser retorna as ofertas pendentes de um cliente
+func (r *WaitlistRepository) GetPendingOffersByUser(userID uint, now time.Time) ([]models.WaitlistOffer, error) {
+	var offers []models.WaitlistOffer
+	err := r.db.Where("user_id = ? AND status = ? AND expires_at > ?", userID, models.WaitlistOfferStatusPending, now).
+		Order("expires_at ASC").Find(&offers).Error
+	if err != nil {
+		return nil, err
+	}
+	return offers, nil
+}
+
+// GetExpiredOffers retorna as ofertas pendentes cuja reserva expirou
+func (r *WaitlistRepository) GetExpiredOffers(now time.Time) ([]models.WaitlistOffer, error) {
+	var offers []models.WaitlistOffer
+	err := r.db.Where("status = ? AND expires_at <= ?", models.WaitlistOfferStatusPending, now).Find(&offers).Error
+	if err != nil {
+		return nil, err
+	}
+	return offers, nil
+}
+
+// GetActiveHolds retorna as reservas de vaga ainda válidas da prestadora no intervalo [from, to)
+func (r *WaitlistRepository) GetActiveHolds(providerID uint, from, to, now time.Time) ([]models.WaitlistOffer, error) {
+	return findActiveHolds(r.db, providerID, from, to, now)
+}
+
+// AcceptOffer cria o agendamento da vaga ofertada, desde que a reserva ainda seja válida,
+// a prestadora continue livre e abaixo do limite de agendamentos, e encerra a oferta e a inscrição
+func (r *WaitlistRepository) AcceptOffer(offer *models.WaitlistOffer, appointment *models.Appointment, now time.Time, events ...*models.AppointmentEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockProvider(tx, offer.ProviderID); err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.WaitlistOffer{}).
+			Where("id = ? AND status = ? AND expires_at > ?", offer.ID, models.WaitlistOfferStatusPending, now).
+			Update("status", models.WaitlistOfferStatusAccepted)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrWaitlistOfferClosed
+		}
+
+		if err := ensureProviderAvailable(tx, appointment, 0, r.travel); err != nil {
+			return err
+		}
+		if err := enforceBookingCaps(tx, appointment, 0); err != nil {
+			return err
+		}
+		if err := tx.Create(appointment).Error; err != nil {
+			return err
+		}
+
+		offer.Status = models.WaitlistOfferStatusAccepted
+		offer.AppointmentID = &appointment.ID
+		if err := tx.Model(&models.WaitlistOffer{}).Where("id = ?", offer.ID).Update("appointment_id", appointment.ID).Error; err != nil {
+			return err
+		}
+
+		err := tx.Model(&models.WaitlistEntry{}).Where("id = ?", offer.EntryID).Updates(map[string]interface{}{
+			"status":         models.WaitlistEntryStatusBooked,
+			"appointment_id": appointment.ID,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return appendEvents(tx, eventsFor(appointment.ID, events)...)
+	})
+}
+
+// CloseOffer encerra uma oferta pendente (recusada ou expirada) e devolve a inscrição à espera
+func (r *WaitlistRepository) CloseOffer(offer *models.WaitlistOffer, status models.WaitlistOfferStatus) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.WaitlistOffer{}).
+			Where("id = ? AND status = ?", offer.ID, models.WaitlistOfferStatusPending).
+			Update("status", status)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrWaitlistOfferClosed
+		}
+		offer.Status = status
+
+		return tx.Model(&models.WaitlistEntry{}).
+			Where("id = ? AND status = ?", offer.EntryID, models.WaitlistEntryStatusOffered).
+			Update("status", models.WaitlistEntryStatusWaiting).Error
+	})
+}
+
+// ExpireEntries encerra as inscrições cujo período já terminou
+func (r *WaitlistRepository) ExpireEntries(today time.Time) error {
+	return r.db.Model(&models.WaitlistEntry{}).
+		Where("status = ? AND window_end < ?", models.WaitlistEntryStatusWaiting, today).
+		Update("status", models.WaitlistEntryStatusExpired).Error
+}
+
+// findActiveHolds retorna as ofertas pendentes e não expiradas que reservam horários
+// da prestadora sobrepostos a [from, to). A reserva nunca ultrapassa a vaga liberada.
+func findActiveHolds(tx *gorm.DB, providerID uint, from, to, now time.Time) ([]models.WaitlistOffer, error) {
+	var candidates []models.WaitlistOffer
+	err := tx.Where("provider_id = ? AND status = ? AND expires_at > ?", providerID, models.WaitlistOfferStatusPending, now).
+		Where("slot_start < ? AND slot_end > ?", to, from).
+		Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var holds []models.WaitlistOffer
+	for _, o := range candidates {
+		if o.StartsAt().Before(to) && from.Before(o.EndsAt()) {
+			holds = append(holds, o)
+		}
+	}
+	return holds, nil
+}
+
+// hasActiveHold indica se o intervalo está reservado para um cliente diferente de userID
+func hasActiveHold(tx *gorm.DB, providerID uint, start, end time.Time, userID uint, now time.Time) (bool, error) {
+	holds, err := findActiveHolds(tx, providerID, start, end, now)
+	if err != nil {
+		return false, err
+	}
+	for _, hold := range holds {
+		if hold.UserID != userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,169 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrWebhookEventNotFound = errors.New("evento de webhook não encontrado")
+	ErrWebhookEventNotRetry = errors.New("apenas eventos com falha ou ignorados podem ser reprocessados")
+)
+
+// Limites da listagem de eventos de webhook
+const (
+	DefaultWebhookEventPageSize = 50
+	MaxWebhookEventPageSize     = 200
+)
+
+type WebhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) *WebhookRepository {
+	return &WebhookRepository{
+		db: db,
+	}
+}
+
+// SaveDelivery grava a notificação assinada e os eventos extraídos dela na mesma transação.
+// Retorna falso, sem gravar nada, se a notificação com a mesma assinatura já tiver sido
+// recebida. Dos eventos, retorna os gravados; os que o provedor já tinha enviado com o
+// mesmo ID são descartados.
+func (r *WebhookRepository) SaveDelivery(delivery *models.WebhookDelivery, events []*models.WebhookEvent) ([]*models.WebhookEvent, bool, error) {
+	var created []*models.WebhookEvent
+	accepted := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(delivery)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		accepted = true
+
+		for _, event := range events {
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(event)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				created = append(created, event)
+			}
+		}
+		return nil
+	})
+	if err != nil || !accepted {
+		return nil, false, err
+	}
+	return created, true, nil
+}
+
+// PurgeDeliveries apaga as notificações cuja assinatura já não seria aceita
+func (r *WebhookRepository) PurgeDeliveries(now time.Time) error {
+	return r.db.Where("expires_at < ?", now).Delete(&models.WebhookDelivery{}).Error
+}
+
+// FindByID busca um evento pelo ID
+func (r *WebhookRepository) FindByID(id uint) (*models.WebhookEvent, error) {
+	var event models.WebhookEvent
+	if err := r.db.First(&event, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookEventNotFound
+		}
+		return nil, err
+	}
+	return &event, nil
+}
+
+// GetDue retorna os eventos pendentes cuja próxima tentativa já chegou, dos mais antigos
+// aos mais novos
+func (r *WebhookRepository) GetDue(now time.Time, limit int) ([]models.WebhookEvent, error) {
+	var events []models.WebhookEvent
+	err := r.db.Where("status = ? AND next_attempt_at <= ?", models.WebhookEventPending, now).
+		Order("id").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// Claim reserva uma tentativa de processamento do evento até lockedUntil, contando a
+// tentativa. Retorna falso se o evento não estiver pendente ou já estiver com outro processo.
+func (r *WebhookRepository) Claim(event *models.WebhookEvent, now, lockedUntil time.Time) (bool, error) {
+	result := r.db.Model(&models.WebhookEvent{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", event.ID, models.WebhookEventPending, now).
+		Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": lockedUntil,
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+
+	event.Attempts++
+	event.NextAttemptAt = &lockedUntil
+	return true, nil
+}
+
+// Finish grava o resultado de uma tentativa de processamento
+func (r *WebhookRepository) Finish(event *models.WebhookEvent) error {
+	return r.db.Model(event).
+		Select("status", "next_attempt_at", "last_error", "processed_at").
+		Updates(event).Error
+}
+
+// Retry devolve à fila um evento com falha ou ignorado, zerando as tentativas
+func (r *WebhookRepository) Retry(event *models.WebhookEvent, now time.Time) error {
+	result := r.db.Model(&models.WebhookEvent{}).
+		Where("id = ? AND status IN ?", event.ID, []models.WebhookEventStatus{models.WebhookEventFailed, models.WebhookEventIgnored}).
+		Updates(map[string]interface{}{
+			"status":          models.WebhookEventPending,
+			"attempts":        0,
+			"next_attempt_at": now,
+			"last_error":      "",
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrWebhookEventNotRetry
+	}
+
+	event.Status = models.WebhookEventPending
+	event.Attempts = 0
+	event.NextAttemptAt = &now
+	event.LastError = ""
+	return nil
+}
+
+// WebhookEventFilter contém os filtros da listagem de eventos
+type WebhookEventFilter struct {
+	Provider string
+	Status   models.WebhookEventStatus
+	Type     string
+	Limit    int
+}
+
+// GetEvents lista os eventos recebidos, do mais recente ao mais antigo
+func (r *WebhookRepository) GetEvents(filter WebhookEventFilter) ([]models.WebhookEvent, error) {
+	query := r.db.Model(&models.WebhookEvent{})
+	if filter.Provider != "" {
+		query = query.Where("provider = ?", filter.Provider)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultWebhookEventPageSize
+	}
+	limit = min(limit, MaxWebhookEventPageSize)
+
+	var events []models.WebhookEvent
+	err := query.Order("id DESC").Limit(limit).Find(&events).Error
+	return events, err
+}
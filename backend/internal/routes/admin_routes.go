@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/handlers"
+)
+
+func SetupAdminRoutes(router *gin.Engine, adminHandler *handlers.AdminHandler, requireAdmin gin.HandlerFunc) {
+	admin := router.Group("/api/admin", requireAdmin)
+	{
+		// Verificação de identidade de usuários
+		admin.PATCH("/users/:id/verification", adminHandler.SetUserVerification)
+
+		// Categoria comercial de prestadoras
+		admin.PATCH("/users/:id/tier", adminHandler.SetProviderTier)
+	}
+}
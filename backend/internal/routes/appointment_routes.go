@@ -17,9 +17,18 @@ func SetupAppointmentRoutes(router *gin.Engine, appointmentHandler *handlers.App
 		// Listar agendamentos da prestadora
 		appointments.GET("/provider", appointmentHandler.GetProviderAppointments)
 
+		// Detalhes de um agendamento
+		appointments.GET("/:id", appointmentHandler.GetAppointment)
+
+		// Histórico de alterações do agendamento
+		appointments.GET("/:id/history", appointmentHandler.GetAppointmentHistory)
+
 		// Atualizar status do agendamento
 		appointments.PATCH("/:id/status", appointmentHandler.UpdateAppointmentStatus)
 
+		// Avaliação do cliente pela prestadora
+		appointments.POST("/:id/client-rating", appointmentHandler.RateClient)
+
 		// Buscar prestadoras disponíveis
 		appointments.GET("/available-providers", appointmentHandler.GetAvailableProviders)
 	}
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/handlers"
+)
+
+func SetupCalendarRoutes(router *gin.Engine, calendarHandler *handlers.CalendarHandler) {
+	calendar := router.Group("/api/calendar")
+	{
+		// Feed de calendário do usuário autenticado
+		calendar.GET("/feed", calendarHandler.GetFeed)
+		calendar.PATCH("/feed", calendarHandler.UpdateFeed)
+		calendar.POST("/feed/regenerate", calendarHandler.RegenerateFeed)
+
+		// Assinatura pública, autenticada pelo token secreto da URL
+		calendar.GET("/feeds/:token", calendarHandler.ServeFeed)
+	}
+
+	// Exportação de um agendamento em .ics
+	router.GET("/api/appointments/:id/ics", calendarHandler.DownloadAppointment)
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/handlers"
+)
+
+func SetupCommissionRoutes(router *gin.Engine, commissionHandler *handlers.CommissionHandler, requireAdmin gin.HandlerFunc) {
+	rules := router.Group("/api/admin/commission-rules", requireAdmin)
+	{
+		// Regras de comissão da plataforma, versionadas a cada alteração
+		rules.GET("", commissionHandler.GetRules)
+		rules.POST("", commissionHandler.CreateRule)
+		rules.PUT("/:ruleId", commissionHandler.UpdateRule)
+		rules.DELETE("/:ruleId", commissionHandler.DeactivateRule)
+	}
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/handlers"
+)
+
+func SetupDisputeRoutes(router *gin.Engine, disputeHandler *handlers.DisputeHandler, requireAdmin gin.HandlerFunc) {
+	dispute := router.Group("/api/appointments/:id/dispute")
+	{
+		// Contestar o atendimento concluído, congelando o pagamento
+		dispute.POST("", disputeHandler.OpenDispute)
+
+		// Consultar a disputa
+		dispute.GET("", disputeHandler.GetDispute)
+
+		// Decisão do admin sobre a divisão do valor
+		dispute.POST("/resolve", requireAdmin, disputeHandler.ResolveDispute)
+	}
+}
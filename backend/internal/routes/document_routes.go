@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/handlers"
+	"github.com/xclean/backend/internal/middleware"
+)
+
+// Cada verificação recalcula o documento; o limite protege a rota pública
+const (
+	documentVerifyLimit  = 30
+	documentVerifyWindow = time.Minute
+)
+
+func SetupDocumentRoutes(router *gin.Engine, documentHandler *handlers.DocumentHandler) {
+	// Conferência pública da assinatura de recibos e extratos
+	router.GET("/api/documents/verify", middleware.RateLimit(documentVerifyLimit, documentVerifyWindow), documentHandler.VerifyDocument)
+}
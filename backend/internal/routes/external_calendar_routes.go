@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/handlers"
+)
+
+func SetupExternalCalendarRoutes(router *gin.Engine, externalCalendarHandler *handlers.ExternalCalendarHandler) {
+	calendars := router.Group("/api/providers/me/external-calendars")
+	{
+		// Calendários externos da prestadora autenticada
+		calendars.GET("", externalCalendarHandler.GetCalendars)
+
+		// Assinar um calendário por URL ou enviar um arquivo .ics
+		calendars.POST("", externalCalendarHandler.SubscribeCalendar)
+		calendars.POST("/upload", externalCalendarHandler.UploadCalendar)
+
+		// Sincronizar agora ou remover um calendário
+		calendars.POST("/:calendarId/sync", externalCalendarHandler.SyncCalendar)
+		calendars.DELETE("/:calendarId", externalCalendarHandler.DeleteCalendar)
+	}
+}
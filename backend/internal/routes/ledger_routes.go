@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/handlers"
+)
+
+func SetupLedgerRoutes(router *gin.Engine, ledgerHandler *handlers.LedgerHandler, requireAdmin gin.HandlerFunc) {
+	// Saldo do usuário autenticado
+	router.GET("/api/ledger/balance", ledgerHandler.GetMyBalance)
+
+	admin := router.Group("/api/admin/ledger", requireAdmin)
+	{
+		// Contas e saldos do razão
+		admin.GET("/accounts", ledgerHandler.GetAccounts)
+		admin.GET("/accounts/:accountId/postings", ledgerHandler.GetAccountPostings)
+
+		// Conciliação com os registros do gateway
+		admin.GET("/reconciliation", ledgerHandler.GetReconciliation)
+	}
+}
@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/handlers"
+)
+
+func SetupNoShowRoutes(router *gin.Engine, noShowHandler *handlers.NoShowHandler, requireAdmin gin.HandlerFunc) {
+	noShow := router.Group("/api/appointments/:id/no-show")
+	{
+		// Alegar ausência da outra parte
+		noShow.POST("", noShowHandler.ClaimNoShow)
+
+		// Consultar a alegação
+		noShow.GET("", noShowHandler.GetNoShowClaim)
+
+		// Contestar a alegação
+		noShow.POST("/dispute", noShowHandler.DisputeNoShow)
+
+		// Decisão do admin sobre alegação contestada
+		noShow.POST("/resolve", requireAdmin, noShowHandler.ResolveNoShow)
+	}
+}
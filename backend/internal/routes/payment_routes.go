@@ -0,0 +1,42 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/handlers"
+)
+
+func SetupPaymentRoutes(router *gin.Engine, paymentHandler *handlers.PaymentHandler) {
+	payments := router.Group("/api/payments")
+	{
+		// Intenção de pagamento de um agendamento
+		payments.POST("/create-intent", paymentHandler.CreateIntent)
+
+		// Confirmação com o método escolhido
+		payments.POST("/confirm", paymentHandler.ConfirmPayment)
+
+		// Reembolso pela prestadora ou pelo suporte; o cliente faz um pedido de reembolso
+		payments.POST("/refund", paymentHandler.RefundPayment)
+
+		// Pedidos de reembolso do cliente, analisados pela prestadora ou pelo suporte
+		payments.GET("/refund-requests", paymentHandler.GetRefundRequests)
+		payments.POST("/refund-requests/:id/approve", paymentHandler.ApproveRefundRequest)
+		payments.POST("/refund-requests/:id/reject", paymentHandler.RejectRefundRequest)
+
+		// Pix: cobrança com BR Code, QR Code e notificação do PSP
+		payments.POST("/pix", paymentHandler.CreatePixCharge)
+		payments.GET("/pix/:txid/qrcode", paymentHandler.GetPixQRCode)
+		payments.POST("/pix/:txid/simulate", paymentHandler.SimulatePixPayment)
+		payments.POST("/pix/webhook", paymentHandler.PixWebhook)
+
+		// Histórico de pagamentos do cliente
+		payments.GET("/history", paymentHandler.GetPaymentHistory)
+
+		// Recibo assinado com a taxa da plataforma, em JSON, PDF ou CSV
+		payments.GET("/:id/receipt", paymentHandler.GetReceipt)
+
+		// Métodos de pagamento salvos
+		payments.GET("/methods", paymentHandler.GetPaymentMethods)
+		payments.POST("/methods", paymentHandler.SavePaymentMethod)
+		payments.DELETE("/methods/:methodId", paymentHandler.DeletePaymentMethod)
+	}
+}
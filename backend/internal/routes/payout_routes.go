@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/handlers"
+)
+
+func SetupPayoutRoutes(router *gin.Engine, payoutHandler *handlers.PayoutHandler) {
+	me := router.Group("/api/providers/me")
+	{
+		// Saldos da prestadora autenticada
+		me.GET("/wallet", payoutHandler.GetWallet)
+
+		// Extrato de ganhos com a comissão da plataforma
+		me.GET("/earnings", payoutHandler.GetEarnings)
+
+		// Extrato mensal assinado, em JSON, PDF ou CSV
+		me.GET("/statements/:month", payoutHandler.GetStatement)
+
+		// Conta bancária ou chave Pix para receber os saques
+		me.GET("/payout-destination", payoutHandler.GetDestination)
+		me.PUT("/payout-destination", payoutHandler.SaveDestination)
+
+		// Histórico e solicitação de saques
+		me.GET("/payouts", payoutHandler.GetPayouts)
+		me.POST("/payouts", payoutHandler.RequestPayout)
+	}
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/handlers"
+)
+
+func SetupPromotionRoutes(router *gin.Engine, promotionHandler *handlers.PromotionHandler, requireAdmin gin.HandlerFunc) {
+	promoCodes := router.Group("/api/admin/promo-codes", requireAdmin)
+	{
+		// Cupons de desconto custeados pela plataforma
+		promoCodes.GET("", promotionHandler.GetPromoCodes)
+		promoCodes.POST("", promotionHandler.CreatePromoCode)
+		promoCodes.PUT("/:promoId", promotionHandler.UpdatePromoCode)
+		promoCodes.DELETE("/:promoId", promotionHandler.DeactivatePromoCode)
+	}
+
+	// Carteira de créditos do cliente, aplicada automaticamente nas reservas
+	router.GET("/api/wallet", promotionHandler.GetWallet)
+
+	referrals := router.Group("/api/referrals")
+	{
+		referrals.GET("/me", promotionHandler.GetMyReferrals)
+		referrals.POST("/redeem", promotionHandler.RedeemReferral)
+	}
+}
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/handlers"
+)
+
+func SetupProviderRoutes(router *gin.Engine, providerHandler *handlers.ProviderHandler) {
+	providers := router.Group("/api/providers")
+	{
+		// Configurações da prestadora autenticada
+		providers.GET("/me/settings", providerHandler.GetSettings)
+		providers.PATCH("/me/settings", providerHandler.UpdateSettings)
+
+		// Política de cancelamento de uma prestadora
+		providers.GET("/:id/cancellation-policy", providerHandler.GetCancellationPolicy)
+
+		// Horários livres de uma prestadora
+		providers.GET("/:id/slots", providerHandler.GetSlots)
+	}
+}
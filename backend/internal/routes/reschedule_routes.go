@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/handlers"
+)
+
+func SetupRescheduleRoutes(router *gin.Engine, rescheduleHandler *handlers.RescheduleHandler) {
+	reschedule := router.Group("/api/appointments/:id/reschedule")
+	{
+		// Propor novo horário
+		reschedule.POST("", rescheduleHandler.ProposeReschedule)
+
+		// Histórico de remarcações
+		reschedule.GET("", rescheduleHandler.GetRescheduleHistory)
+
+		// Responder a um pedido de remarcação
+		reschedule.POST("/:requestId/accept", rescheduleHandler.AcceptReschedule)
+		reschedule.POST("/:requestId/reject", rescheduleHandler.RejectReschedule)
+		reschedule.POST("/:requestId/counter", rescheduleHandler.CounterReschedule)
+	}
+}
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/handlers"
+)
+
+func SetupSeriesRoutes(router *gin.Engine, seriesHandler *handlers.SeriesHandler) {
+	series := router.Group("/api/series")
+	{
+		// Criar série recorrente
+		series.POST("", seriesHandler.CreateSeries)
+
+		// Listar séries do usuário
+		series.GET("", seriesHandler.GetSeriesList)
+
+		// Detalhes da série com ocorrências e conflitos
+		series.GET("/:id", seriesHandler.GetSeries)
+
+		// Alterar uma ocorrência
+		series.POST("/:id/occurrences/:appointmentId/skip", seriesHandler.SkipOccurrence)
+		series.POST("/:id/occurrences/:appointmentId/move", seriesHandler.MoveOccurrence)
+		series.POST("/:id/occurrences/:appointmentId/cancel", seriesHandler.CancelOccurrence)
+	}
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/handlers"
+)
+
+func SetupTermsRoutes(router *gin.Engine, termsHandler *handlers.TermsHandler) {
+	terms := router.Group("/api/appointments/:id/terms")
+	{
+		// Versões das condições do agendamento
+		terms.GET("", termsHandler.GetTerms)
+
+		// Nova versão definida pelo cliente
+		terms.PUT("", termsHandler.SetTerms)
+
+		// Ciência da prestadora sobre a versão mais recente
+		terms.POST("/acknowledge", termsHandler.AcknowledgeTerms)
+	}
+}
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/handlers"
+)
+
+func SetupTipRoutes(router *gin.Engine, tipHandler *handlers.TipHandler) {
+	tip := router.Group("/api/appointments/:id/tip")
+	{
+		// Gorjeta do cliente após o atendimento concluído
+		tip.POST("", tipHandler.SendTip)
+		tip.GET("", tipHandler.GetTip)
+	}
+}
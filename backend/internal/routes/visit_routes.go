@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/handlers"
+)
+
+func SetupVisitRoutes(router *gin.Engine, visitHandler *handlers.VisitHandler) {
+	visit := router.Group("/api/appointments/:id")
+	{
+		// Chegada da prestadora ao local
+		visit.POST("/check-in", visitHandler.CheckIn)
+
+		// Saída da prestadora e conclusão do atendimento
+		visit.POST("/check-out", visitHandler.CheckOut)
+	}
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/handlers"
+)
+
+func SetupWaitlistRoutes(router *gin.Engine, waitlistHandler *handlers.WaitlistHandler) {
+	waitlist := router.Group("/api/waitlist")
+	{
+		// Entrar na lista de espera de uma prestadora
+		waitlist.POST("", waitlistHandler.JoinWaitlist)
+
+		// Inscrições e vagas ofertadas ao cliente
+		waitlist.GET("", waitlistHandler.GetWaitlist)
+
+		// Sair da lista de espera
+		waitlist.DELETE("/:id", waitlistHandler.LeaveWaitlist)
+
+		// Responder a uma vaga ofertada
+		waitlist.POST("/offers/:offerId/accept", waitlistHandler.AcceptOffer)
+		waitlist.POST("/offers/:offerId/decline", waitlistHandler.DeclineOffer)
+	}
+}
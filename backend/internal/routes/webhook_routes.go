@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xclean/backend/internal/handlers"
+)
+
+func SetupWebhookRoutes(router *gin.Engine, webhookHandler *handlers.WebhookHandler, requireAdmin gin.HandlerFunc) {
+	// Notificações dos provedores de pagamento, autenticadas pela assinatura de cada um
+	router.POST("/api/webhooks/:provider", webhookHandler.Receive)
+
+	events := router.Group("/api/admin/webhooks/events", requireAdmin)
+	{
+		events.GET("", webhookHandler.GetEvents)
+		events.POST("/:eventId/retry", webhookHandler.RetryEvent)
+	}
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"encoding/json"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+)
+
+// AuditService registra o histórico de alterações dos agendamentos
+type AuditService struct {
+	eventRepo *repositories.AppointmentEventRepository
+}
+
+func NewAuditService(eventRepo *repositories.AppointmentEventRepository) *AuditService {
+	return &AuditService{
+		eventRepo: eventRepo,
+	}
+}
+
+// Event monta um evento do histórico do agendamento. actorID nulo indica o sistema e
+// appointmentID zero, um agendamento ainda não criado. Os valores são serializados em JSON.
+// O evento é gravado pelo repositório na mesma transação da alteração que ele descreve,
+// de modo que uma falha no histórico desfaz a alteração.
+func (s *AuditService) Event(appointmentID uint, actorID *uint, eventType models.AppointmentEventType, oldValue, newValue interface{}) *models.AppointmentEvent {
+	return &models.AppointmentEvent{
+		AppointmentID: appointmentID,
+		ActorID:       actorID,
+		Type:          eventType,
+		OldValue:      marshalEventValue(oldValue),
+		NewValue:      marshalEventValue(newValue),
+	}
+}
+
+// History retorna o histórico de um agendamento
+func (s *AuditService) History(appointmentID uint) ([]models.AppointmentEvent, error) {
+	return s.eventRepo.GetByAppointment(appointmentID)
+}
+
+// marshalEventValue converte um valor do evento em JSON
+func marshalEventValue(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/testutil"
+)
+
+func TestAuditHistory(t *testing.T) {
+	db := testutil.NewDB(t)
+	client := testutil.CreateUser(t, db, models.UserTypeClient)
+	provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+	appointment := testutil.CreateAppointment(t, db, models.Appointment{
+		UserID: client.ID, ProviderID: provider.ID, Date: time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC),
+	})
+	other := testutil.CreateAppointment(t, db, models.Appointment{
+		UserID: client.ID, ProviderID: provider.ID, Date: time.Date(2026, 3, 11, 9, 0, 0, 0, time.UTC),
+	})
+
+	eventRepo := repositories.NewAppointmentEventRepository(db)
+	audit := NewAuditService(eventRepo)
+	err := eventRepo.Append(
+		audit.Event(appointment.ID, &client.ID, models.AppointmentEventCreated, nil, map[string]interface{}{"status": "pending"}),
+		audit.Event(other.ID, &client.ID, models.AppointmentEventCreated, nil, nil),
+		audit.Event(appointment.ID, nil, models.AppointmentEventStatusChanged, models.AppointmentStatusPending, models.AppointmentStatusConfirmed),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := audit.History(appointment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("histórico com %d eventos, want 2: %+v", len(history), history)
+	}
+	created, changed := history[0], history[1]
+	if created.Type != models.AppointmentEventCreated || created.ActorID == nil || *created.ActorID != client.ID ||
+		created.OldValue != "" || created.NewValue != `{"status":"pending"}` {
+		t.Errorf("primeiro evento %+v; want criação pelo cliente", created)
+	}
+	if changed.Type != models.AppointmentEventStatusChanged || changed.ActorID != nil ||
+		changed.OldValue != `"pending"` || changed.NewValue != `"confirmed"` {
+		t.Errorf("segundo evento %+v; want alteração de status pelo sistema", changed)
+	}
+}
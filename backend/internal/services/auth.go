@@ -12,6 +12,8 @@ import (
 var (
 	ErrInvalidCredentials = errors.New("credenciais inválidas")
 	ErrUserNotFound       = errors.New("usuário não encontrado")
+	ErrMissingToken       = errors.New("token não fornecido")
+	ErrInvalidToken       = errors.New("token inválido")
 )
 
 type AuthService struct {
@@ -67,5 +69,30 @@ func (s *AuthService) ValidateToken(tokenString string) (jwt.MapClaims, error) {
 		return claims, nil
 	}
 
-	return nil, errors.New("token inválido")
+	return nil, ErrInvalidToken
+}
+
+// UserIDFromHeader extrai o ID do usuário de um header Authorization no formato "Bearer <token>"
+func (s *AuthService) UserIDFromHeader(authHeader string) (uint, error) {
+	if authHeader == "" {
+		return 0, ErrMissingToken
+	}
+
+	// Validar formato do token
+	if len(authHeader) < 7 || authHeader[:7] != "Bearer " {
+		return 0, ErrInvalidToken
+	}
+
+	claims, err := s.ValidateToken(authHeader[7:])
+	if err != nil {
+		return 0, err
+	}
+
+	// Extrair ID do usuário
+	userID, ok := claims["user_id"].(float64)
+	if !ok || userID == 0 {
+		return 0, ErrInvalidToken
+	}
+
+	return uint(userID), nil
 }
@@ -0,0 +1,278 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+)
+
+// SlotStep é o intervalo entre os inícios de horários oferecidos na busca de disponibilidade
+const SlotStep = 30 * time.Minute
+
+// TimeRange é um intervalo de horário no formato HH:MM
+type TimeRange struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// WorkingHours define os intervalos de trabalho por dia da semana,
+// com as chaves sun, mon, tue, wed, thu, fri e sat
+type WorkingHours map[string][]TimeRange
+
+var weekdayKeys = [...]string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// DefaultWorkingHours é usado quando a prestadora não configurou seus horários
+var DefaultWorkingHours = WorkingHours{
+	"sun": {{Start: "08:00", End: "20:00"}},
+	"mon": {{Start: "08:00", End: "20:00"}},
+	"tue": {{Start: "08:00", End: "20:00"}},
+	"wed": {{Start: "08:00", End: "20:00"}},
+	"thu": {{Start: "08:00", End: "20:00"}},
+	"fri": {{Start: "08:00", End: "20:00"}},
+	"sat": {{Start: "08:00", End: "20:00"}},
+}
+
+// ParseWorkingHours interpreta o campo WorkingHours do perfil da prestadora
+func ParseWorkingHours(value string) (WorkingHours, error) {
+	if strings.TrimSpace(value) == "" {
+		return DefaultWorkingHours, nil
+	}
+
+	var hours WorkingHours
+	if err := json.Unmarshal([]byte(value), &hours); err != nil {
+		return nil, fmt.Errorf("horário de trabalho inválido: %w", err)
+	}
+
+	for day, ranges := range hours {
+		if !isWeekdayKey(day) {
+			return nil, fmt.Errorf("horário de trabalho inválido: dia %q", day)
+		}
+		for _, r := range ranges {
+			start, err1 := time.Parse("15:04", r.Start)
+			end, err2 := time.Parse("15:04", r.End)
+			if err1 != nil || err2 != nil || !start.Before(end) {
+				return nil, fmt.Errorf("horário de trabalho inválido em %s: %s-%s", day, r.Start, r.End)
+			}
+		}
+	}
+	return hours, nil
+}
+
+func isWeekdayKey(day string) bool {
+	for _, key := range weekdayKeys {
+		if key == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Slot é um intervalo de horário livre na agenda da prestadora
+type Slot struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// SlotQuery descreve a busca de horários livres de uma prestadora
+type SlotQuery struct {
+	ProviderID uint
+	Date       time.Time // Dia da busca; em IsAvailable, o início exato desejado
+	Duration   int       // Duração em minutos; usa o padrão se não informada
+	// UserID permite ignorar as reservas da lista de espera feitas para o próprio cliente
+	UserID uint
+	// Endereço do atendimento, usado para o tempo de deslocamento; (0, 0) se desconhecido
+	Latitude  float64
+	Longitude float64
+}
+
+// AvailabilityService calcula os horários livres das prestadoras a partir do horário
+// de trabalho, dos agendamentos ativos, das reservas temporárias da lista de espera
+// e dos compromissos importados de calendários externos, dentro da janela e dos limites
+// de agendamento da prestadora
+type AvailabilityService struct {
+	appointmentRepo      *repositories.AppointmentRepository
+	userRepo             *repositories.UserRepository
+	waitlistRepo         *repositories.WaitlistRepository
+	externalCalendarRepo *repositories.ExternalCalendarRepository
+	bookingRules         *BookingRulesService
+	now                  func() time.Time
+}
+
+func NewAvailabilityService(
+	appointmentRepo *repositories.AppointmentRepository,
+	userRepo *repositories.UserRepository,
+	waitlistRepo *repositories.WaitlistRepository,
+	externalCalendarRepo *repositories.ExternalCalendarRepository,
+	bookingRules *BookingRulesService,
+) *AvailabilityService {
+	return &AvailabilityService{
+		appointmentRepo:      appointmentRepo,
+		userRepo:             userRepo,
+		waitlistRepo:         waitlistRepo,
+		externalCalendarRepo: externalCalendarRepo,
+		bookingRules:         bookingRules,
+		now:                  time.Now,
+	}
+}
+
+// FreeSlots retorna os horários em que a prestadora pode atender no dia da busca,
+// respeitando o intervalo mínimo entre atendimentos, a antecedência mínima, o horizonte
+// de agendamento e os limites diário e semanal configurados por ela
+func (s *AvailabilityService) FreeSlots(q SlotQuery) ([]Slot, error) {
+	duration := q.Duration
+	if duration <= 0 {
+		duration = models.DefaultAppointmentDuration
+	}
+	length := time.Duration(duration) * time.Minute
+
+	hours := DefaultWorkingHours
+	profile, err := s.userRepo.GetProviderProfile(q.ProviderID)
+	if err != nil {
+		profile = nil
+	} else if parsed, err := ParseWorkingHours(profile.WorkingHours); err == nil {
+		hours = parsed
+	}
+
+	day := time.Date(q.Date.Year(), q.Date.Month(), q.Date.Day(), 0, 0, 0, 0, q.Date.Location())
+	ranges := hours[weekdayKeys[day.Weekday()]]
+	if len(ranges) == 0 {
+		return []Slot{}, nil
+	}
+
+	earliest, latest := s.bookingRules.Window(profile)
+	if !latest.IsZero() && !day.Before(latest) {
+		return []Slot{}, nil
+	}
+	full, err := s.capsReached(profile, q.ProviderID, day)
+	if err != nil {
+		return nil, err
+	}
+	if full {
+		return []Slot{}, nil
+	}
+
+	gap, err := s.appointmentRepo.ScheduleGap(q.ProviderID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Atendimentos fora do dia ainda podem exigir intervalo em relação aos do dia
+	margin := gap.Buffer + repositories.MaxTravelTime
+	dayStart := models.ScheduleTime(day, 0, 0)
+	busy, err := s.busyItems(q.ProviderID, dayStart.Add(-margin), dayStart.AddDate(0, 0, 1).Add(margin), q.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	slots := []Slot{}
+	for _, r := range ranges {
+		rangeStart := atClock(day, r.Start)
+		rangeEnd := atClock(day, r.End)
+		for start := rangeStart; !start.Add(length).After(rangeEnd); start = start.Add(SlotStep) {
+			if start.Before(earliest) || (!latest.IsZero() && !start.Before(latest)) {
+				continue
+			}
+			candidate := repositories.ScheduleItem{
+				Start:     start,
+				End:       start.Add(length),
+				Latitude:  q.Latitude,
+				Longitude: q.Longitude,
+			}
+			blocked, err := blockedByAny(gap, busy, candidate)
+			if err != nil {
+				return nil, err
+			}
+			if !blocked {
+				slots = append(slots, Slot{Start: candidate.Start, End: candidate.End})
+			}
+		}
+	}
+
+	sort.Slice(slots, func(i, j int) bool { return slots[i].Start.Before(slots[j].Start) })
+	return slots, nil
+}
+
+// IsAvailable indica se a prestadora pode atender começando exatamente em q.Date
+func (s *AvailabilityService) IsAvailable(q SlotQuery) (bool, error) {
+	slots, err := s.FreeSlots(q)
+	if err != nil {
+		return false, err
+	}
+	for _, slot := range slots {
+		if slot.Start.Equal(q.Date) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// capsReached indica se a prestadora já atingiu o limite diário ou semanal de agendamentos no dia
+func (s *AvailabilityService) capsReached(profile *models.ProviderProfile, providerID uint, day time.Time) (bool, error) {
+	if profile == nil || (profile.DailyBookingCap <= 0 && profile.WeeklyBookingCap <= 0) {
+		return false, nil
+	}
+
+	counts, err := s.appointmentRepo.CountBookings(providerID, day)
+	if err != nil {
+		return false, err
+	}
+	return (profile.DailyBookingCap > 0 && counts.Day >= profile.DailyBookingCap) ||
+		(profile.WeeklyBookingCap > 0 && counts.Week >= profile.WeeklyBookingCap), nil
+}
+
+// busyItems reúne os intervalos ocupados da prestadora entre from e to.
+// Apenas os agendamentos têm endereço; reservas e compromissos externos usam só o intervalo fixo.
+func (s *AvailabilityService) busyItems(providerID uint, from, to time.Time, forUserID uint) ([]repositories.ScheduleItem, error) {
+	appointments, err := s.appointmentRepo.GetProviderSchedule(providerID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []repositories.ScheduleItem
+	for i := range appointments {
+		items = append(items, repositories.AppointmentScheduleItem(&appointments[i]))
+	}
+
+	holds, err := s.waitlistRepo.GetActiveHolds(providerID, from, to, s.now())
+	if err != nil {
+		return nil, err
+	}
+	for _, hold := range holds {
+		if hold.UserID == forUserID {
+			continue
+		}
+		items = append(items, repositories.ScheduleItem{Start: hold.StartsAt(), End: hold.EndsAt()})
+	}
+
+	external, err := s.externalCalendarRepo.GetBusyBlocks(providerID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range external {
+		items = append(items, repositories.ScheduleItem{Start: b.StartAt, End: b.EndAt})
+	}
+
+	return items, nil
+}
+
+// atClock combina o dia com um horário HH:MM já validado, no fuso da agenda
+func atClock(day time.Time, clock string) time.Time {
+	t, _ := time.Parse("15:04", clock)
+	return models.ScheduleTime(day, t.Hour(), t.Minute())
+}
+
+// blockedByAny indica se algum intervalo ocupado impede o atendimento candidate
+func blockedByAny(gap repositories.ScheduleGap, busy []repositories.ScheduleItem, candidate repositories.ScheduleItem) (bool, error) {
+	for _, item := range busy {
+		blocked, err := gap.Blocks(item, candidate)
+		if err != nil || blocked {
+			return blocked, err
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+)
+
+var (
+	ErrBookingInPast  = errors.New("não é possível agendar no passado")
+	ErrBookingTooSoon = errors.New("antecedência mínima não respeitada")
+	ErrBookingTooFar  = errors.New("data além do limite de agendamento da prestadora")
+)
+
+// BookingRulesService aplica a antecedência mínima e o horizonte máximo de agendamento
+// configurados pela prestadora. Os limites diário e semanal são aplicados pelo repositório,
+// junto com a verificação de conflitos.
+type BookingRulesService struct {
+	now func() time.Time
+}
+
+func NewBookingRulesService() *BookingRulesService {
+	return &BookingRulesService{
+		now: time.Now,
+	}
+}
+
+// Window retorna o primeiro horário e o último dia (exclusivo) em que a prestadora aceita agendamentos.
+// latest é zero quando não há horizonte configurado.
+func (s *BookingRulesService) Window(profile *models.ProviderProfile) (earliest, latest time.Time) {
+	now := s.now()
+	earliest = now
+	if profile == nil {
+		return earliest, time.Time{}
+	}
+
+	if profile.MinNoticeMinutes > 0 {
+		earliest = now.Add(time.Duration(profile.MinNoticeMinutes) * time.Minute)
+	}
+	if profile.MaxAdvanceDays > 0 {
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		latest = today.AddDate(0, 0, profile.MaxAdvanceDays+1)
+	}
+	return earliest, latest
+}
+
+// CheckWindow valida o início do agendamento contra a janela da prestadora,
+// retornando um erro que descreve o limite violado
+func (s *BookingRulesService) CheckWindow(profile *models.ProviderProfile, startsAt time.Time) error {
+	if !startsAt.After(s.now()) {
+		return ErrBookingInPast
+	}
+
+	earliest, latest := s.Window(profile)
+	if startsAt.Before(earliest) {
+		return fmt.Errorf("%w: esta prestadora exige %s de antecedência", ErrBookingTooSoon, describeMinutes(profile.MinNoticeMinutes))
+	}
+	if !latest.IsZero() && !startsAt.Before(latest) {
+		return fmt.Errorf("%w: agendamentos só são aceitos até %d dias à frente", ErrBookingTooFar, profile.MaxAdvanceDays)
+	}
+	return nil
+}
+
+// IsBookingWindowError indica se err é a violação da janela de agendamento da prestadora
+func IsBookingWindowError(err error) bool {
+	return errors.Is(err, ErrBookingInPast) || errors.Is(err, ErrBookingTooSoon) || errors.Is(err, ErrBookingTooFar)
+}
+
+// describeMinutes descreve uma duração em minutos, horas ou dias
+func describeMinutes(minutes int) string {
+	switch {
+	case minutes%(24*60) == 0:
+		return plural(minutes/(24*60), "dia", "dias")
+	case minutes%60 == 0:
+		return plural(minutes/60, "hora", "horas")
+	}
+	return plural(minutes, "minuto", "minutos")
+}
+
+func plural(n int, singular, pluralForm string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, pluralForm)
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+)
+
+func TestBookingRulesCheckWindow(t *testing.T) {
+	now := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	profile := &models.ProviderProfile{MinNoticeMinutes: 120, MaxAdvanceDays: 7}
+
+	tests := []struct {
+		name     string
+		profile  *models.ProviderProfile
+		startsAt time.Time
+		wantErr  error
+	}{
+		{"no passado", profile, now.Add(-time.Minute), ErrBookingInPast},
+		{"agora", nil, now, ErrBookingInPast},
+		{"sem regras", nil, now.Add(time.Minute), nil},
+		{"antes da antecedência mínima", profile, now.Add(119 * time.Minute), ErrBookingTooSoon},
+		{"na antecedência mínima", profile, now.Add(120 * time.Minute), nil},
+		{"último dia do horizonte", profile, time.Date(2026, 3, 17, 18, 0, 0, 0, time.UTC), nil},
+		{"depois do horizonte", profile, time.Date(2026, 3, 18, 8, 0, 0, 0, time.UTC), ErrBookingTooFar},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewBookingRulesService()
+			service.now = func() time.Time { return now }
+			if err := service.CheckWindow(tt.profile, tt.startsAt); !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckWindow: %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDescribeMinutes(t *testing.T) {
+	tests := []struct {
+		minutes int
+		want    string
+	}{
+		{1, "1 minuto"},
+		{90, "90 minutos"},
+		{60, "1 hora"},
+		{180, "3 horas"},
+		{1440, "1 dia"},
+		{2880, "2 dias"},
+	}
+	for _, tt := range tests {
+		if got := describeMinutes(tt.minutes); got != tt.want {
+			t.Errorf("describeMinutes(%d) = %q, want %q", tt.minutes, got, tt.want)
+		}
+	}
+}
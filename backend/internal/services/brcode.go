@@ -0,0 +1,88 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidBRCode = errors.New("dados inválidos para o BR Code")
+
+// BRCode contém os campos de um BR Code dinâmico do Pix (padrão EMV QRCPS-MPM do Banco Central)
+type BRCode struct {
+	Location     string // URL do payload da cobrança no PSP, sem "https://"
+	Amount       int64  // Em centavos
+	MerchantName string
+	MerchantCity string
+}
+
+// Payload monta o texto do BR Code ("Pix Copia e Cola"), incluindo o CRC16 final
+func (b BRCode) Payload() (string, error) {
+	location := strings.TrimPrefix(b.Location, "https://")
+	name := brCodeText(b.MerchantName, 25)
+	city := brCodeText(b.MerchantCity, 15)
+	if location == "" || len(location) > 77 || name == "" || city == "" || b.Amount <= 0 {
+		return "", ErrInvalidBRCode
+	}
+
+	var sb strings.Builder
+	writeEMV(&sb, "00", "01")
+	writeEMV(&sb, "01", "12") // QR de uso único
+	writeEMV(&sb, "26", emv("00", "br.gov.bcb.pix")+emv("25", location))
+	writeEMV(&sb, "52", "0000")
+	writeEMV(&sb, "53", "986") // BRL
+	writeEMV(&sb, "54", fmt.Sprintf("%d.%02d", b.Amount/100, b.Amount%100))
+	writeEMV(&sb, "58", "BR")
+	writeEMV(&sb, "59", name)
+	writeEMV(&sb, "60", city)
+	writeEMV(&sb, "62", emv("05", "***")) // No QR dinâmico, o txid fica no payload do PSP
+
+	sb.WriteString("6304")
+	payload := sb.String()
+	return payload + fmt.Sprintf("%04X", crc16CCITT([]byte(payload))), nil
+}
+
+func emv(id, value string) string {
+	return fmt.Sprintf("%s%02d%s", id, len(value), value)
+}
+
+func writeEMV(sb *strings.Builder, id, value string) {
+	sb.WriteString(emv(id, value))
+}
+
+// brCodeText converte o texto para maiúsculas sem acentos, limitado a max caracteres
+func brCodeText(value string, max int) string {
+	replacer := strings.NewReplacer(
+		"Á", "A", "À", "A", "Â", "A", "Ã", "A", "Ä", "A",
+		"É", "E", "È", "E", "Ê", "E", "Ë", "E",
+		"Í", "I", "Ì", "I", "Î", "I", "Ï", "I",
+		"Ó", "O", "Ò", "O", "Ô", "O", "Õ", "O", "Ö", "O",
+		"Ú", "U", "Ù", "U", "Û", "U", "Ü", "U",
+		"Ç", "C", "Ñ", "N",
+	)
+	value = replacer.Replace(strings.ToUpper(strings.TrimSpace(value)))
+
+	var sb strings.Builder
+	for _, r := range value {
+		if r >= 0x20 && r < 0x7F && sb.Len() < max {
+			sb.WriteRune(r)
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// crc16CCITT calcula o CRC16-CCITT (polinômio 0x1021, valor inicial 0xFFFF) exigido pelo BR Code
+func crc16CCITT(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+)
+
+// calendarTokenBytes é o tamanho em bytes do token secreto do feed
+const calendarTokenBytes = 32
+
+// CalendarService gerencia as assinaturas iCalendar e a exportação de agendamentos em .ics
+type CalendarService struct {
+	feedRepo *repositories.CalendarFeedRepository
+	now      func() time.Time
+}
+
+func NewCalendarService(feedRepo *repositories.CalendarFeedRepository) *CalendarService {
+	return &CalendarService{
+		feedRepo: feedRepo,
+		now:      time.Now,
+	}
+}
+
+// Feed retorna o feed do usuário, criando-o com um novo token se ainda não existir
+func (s *CalendarService) Feed(userID uint) (*models.CalendarFeed, error) {
+	feed, err := s.feedRepo.FindByUser(userID)
+	if err == nil {
+		return feed, nil
+	}
+	if !errors.Is(err, repositories.ErrCalendarFeedNotFound) {
+		return nil, err
+	}
+
+	token, err := newCalendarToken()
+	if err != nil {
+		return nil, err
+	}
+	feed = &models.CalendarFeed{UserID: userID, Token: token}
+	if err := s.feedRepo.Create(feed); err != nil {
+		return nil, err
+	}
+	return feed, nil
+}
+
+// Regenerate troca o token do feed, invalidando a URL de assinatura anterior
+func (s *CalendarService) Regenerate(userID uint) (*models.CalendarFeed, error) {
+	feed, err := s.Feed(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := newCalendarToken()
+	if err != nil {
+		return nil, err
+	}
+	feed.Token = token
+	if err := s.feedRepo.Update(feed); err != nil {
+		return nil, err
+	}
+	return feed, nil
+}
+
+// SetIncludeDetails define se os eventos do feed trazem nomes, endereço e observações
+func (s *CalendarService) SetIncludeDetails(userID uint, include bool) (*models.CalendarFeed, error) {
+	feed, err := s.Feed(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	feed.IncludeDetails = include
+	if err := s.feedRepo.Update(feed); err != nil {
+		return nil, err
+	}
+	return feed, nil
+}
+
+// RenderFeed gera o calendário com os agendamentos a partir de hoje do dono do token
+func (s *CalendarService) RenderFeed(token string) ([]byte, error) {
+	feed, err := s.feedRepo.FindByToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	today := models.ScheduleTime(now.In(models.ScheduleLocation), 0, 0)
+	appointments, err := s.feedRepo.GetAppointments(feed.UserID, today)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]ICalEvent, 0, len(appointments))
+	for i := range appointments {
+		events = append(events, AppointmentICalEvent(&appointments[i], feed.UserID, feed.IncludeDetails, now))
+	}
+	return EncodeICalendar("xclean", events), nil
+}
+
+// RenderAppointment gera o arquivo .ics de um único agendamento para um de seus participantes
+func (s *CalendarService) RenderAppointment(appointment *models.Appointment, viewerID uint) []byte {
+	event := AppointmentICalEvent(appointment, viewerID, true, s.now())
+	return EncodeICalendar("", []ICalEvent{event})
+}
+
+// newCalendarToken gera um token aleatório para a URL de assinatura
+func newCalendarToken() (string, error) {
+	buf := make([]byte, calendarTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/testutil"
+)
+
+func TestEncodeICalendarFoldsAndEscapes(t *testing.T) {
+	start := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	ics := string(EncodeICalendar("", []ICalEvent{{
+		UID:         "appointment-1@xclean",
+		Start:       start,
+		End:         start.Add(2 * time.Hour),
+		Stamp:       start,
+		Summary:     "Limpeza; cozinha, área de serviço",
+		Description: strings.Repeat("é", 60),
+	}}))
+
+	for _, line := range strings.Split(strings.TrimSuffix(ics, "\r\n"), "\r\n") {
+		if len(line) > icalLineLimit {
+			t.Errorf("linha com %d octetos: %q", len(line), line)
+		}
+		if !strings.HasPrefix(line, " ") && strings.Contains(line, "\n") {
+			t.Errorf("quebra de linha sem CRLF: %q", line)
+		}
+	}
+	if !strings.Contains(ics, `SUMMARY:Limpeza\; cozinha\, área de serviço`) {
+		t.Errorf("resumo sem escape:\n%s", ics)
+	}
+	// Desfazer as continuações devolve a descrição sem caracteres partidos
+	unfolded := strings.ReplaceAll(ics, "\r\n ", "")
+	if !strings.Contains(unfolded, "DESCRIPTION:"+strings.Repeat("é", 60)+"\r\n") {
+		t.Errorf("descrição corrompida pela quebra:\n%s", ics)
+	}
+	if !strings.Contains(ics, "DTSTART:20260310T090000\r\n") || !strings.Contains(ics, "DTEND:20260310T110000\r\n") {
+		t.Errorf("horários fora do formato flutuante:\n%s", ics)
+	}
+}
+
+func TestRenderFeed(t *testing.T) {
+	previous := models.ScheduleLocation
+	models.ScheduleLocation = time.FixedZone("BRT", -3*60*60)
+	t.Cleanup(func() { models.ScheduleLocation = previous })
+
+	db := testutil.NewDB(t)
+	client := testutil.CreateUser(t, db, models.UserTypeClient)
+	provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+	day := func(d int) time.Time {
+		return models.ScheduleTime(time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC), 0, 0)
+	}
+	testutil.CreateAppointment(t, db, models.Appointment{
+		UserID: client.ID, ProviderID: provider.ID, Date: day(8), Time: "09:00", Service: "Passado",
+	})
+	today := testutil.CreateAppointment(t, db, models.Appointment{
+		UserID: client.ID, ProviderID: provider.ID, Date: day(9), Time: "09:00", Service: "Hoje",
+		Location: "Rua das Flores, 10", Status: models.AppointmentStatusConfirmed,
+	})
+	cancelled := testutil.CreateAppointment(t, db, models.Appointment{
+		UserID: client.ID, ProviderID: provider.ID, Date: day(12), Time: "14:00", Service: "Cancelado",
+		Status: models.AppointmentStatusCancelled,
+	})
+
+	service := NewCalendarService(repositories.NewCalendarFeedRepository(db))
+	// 01:00 em UTC já é dia 10, mas ainda é dia 9 no fuso da agenda
+	service.now = func() time.Time { return time.Date(2026, 3, 10, 1, 0, 0, 0, time.UTC) }
+
+	feed, err := service.Feed(provider.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ics, err := service.RenderFeed(feed.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := string(ics)
+	if strings.Count(body, "BEGIN:VEVENT") != 2 || strings.Contains(body, "Passado") {
+		t.Errorf("feed deveria ter só os agendamentos de hoje em diante:\n%s", body)
+	}
+	if !strings.Contains(body, fmt.Sprintf("UID:appointment-%d@xclean", today.ID)) || !strings.Contains(body, "STATUS:CONFIRMED") {
+		t.Errorf("agendamento de hoje ausente ou sem status:\n%s", body)
+	}
+	if !strings.Contains(body, fmt.Sprintf("UID:appointment-%d@xclean", cancelled.ID)) || !strings.Contains(body, "STATUS:CANCELLED") {
+		t.Errorf("agendamento cancelado ausente ou sem status:\n%s", body)
+	}
+	// Sem os detalhes, o feed não expõe endereço nem nome do cliente
+	if strings.Contains(body, "LOCATION") || strings.Contains(body, client.Name) {
+		t.Errorf("feed expõe detalhes sem permissão:\n%s", body)
+	}
+
+	if _, err := service.SetIncludeDetails(provider.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	ics, err = service.RenderFeed(feed.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := string(ics); !strings.Contains(body, "LOCATION:Rua das Flores\\, 10") || !strings.Contains(body, "Hoje - "+client.Name) {
+		t.Errorf("feed sem os detalhes autorizados:\n%s", body)
+	}
+
+	// O novo token invalida a URL anterior
+	regenerated, err := service.Regenerate(provider.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.RenderFeed(feed.Token); !errors.Is(err, repositories.ErrCalendarFeedNotFound) {
+		t.Errorf("token antigo: %v, want %v", err, repositories.ErrCalendarFeedNotFound)
+	}
+	if _, err := service.RenderFeed(regenerated.Token); err != nil {
+		t.Errorf("token novo: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+)
+
+var (
+	ErrAppointmentNotCancellable = errors.New("agendamento não pode ser cancelado")
+)
+
+// CancellationTier define a taxa aplicada quando o cancelamento ocorre
+// com pelo menos MinNotice de antecedência do início do agendamento
+type CancellationTier struct {
+	MinNotice  time.Duration `json:"min_notice"`
+	FeePercent float64       `json:"fee_percent"`
+}
+
+// cancellationTiers contém as faixas de cada política, da maior para a menor antecedência
+var cancellationTiers = map[models.CancellationPolicy][]CancellationTier{
+	models.CancellationPolicyFlexible: {
+		{MinNotice: 24 * time.Hour, FeePercent: 0},
+		{MinNotice: 0, FeePercent: 25},
+	},
+	models.CancellationPolicyModerate: {
+		{MinNotice: 48 * time.Hour, FeePercent: 0},
+		{MinNotice: 24 * time.Hour, FeePercent: 25},
+		{MinNotice: 0, FeePercent: 50},
+	},
+	models.CancellationPolicyStrict: {
+		{MinNotice: 72 * time.Hour, FeePercent: 0},
+		{MinNotice: 24 * time.Hour, FeePercent: 50},
+		{MinNotice: 0, FeePercent: 100},
+	},
+}
+
+// RefundIssuer é implementado pela camada de pagamentos para devolver ao
+// cliente o valor pago descontada a taxa de cancelamento
+type RefundIssuer interface {
+	RefundCancellation(appointment *models.Appointment) error
+}
+
+// CancellationService calcula as taxas de cancelamento dos agendamentos
+type CancellationService struct {
+	now func() time.Time
+}
+
+func NewCancellationService() *CancellationService {
+	return &CancellationService{
+		now: time.Now,
+	}
+}
+
+// Tiers retorna as faixas de taxa de uma política
+func (s *CancellationService) Tiers(policy models.CancellationPolicy) []CancellationTier {
+	if !policy.IsValid() {
+		policy = models.CancellationPolicyFlexible
+	}
+	return cancellationTiers[policy]
+}
+
+// ComputeFee calcula a taxa devida por um cancelamento feito em cancelledAt, limitada ao
+// valor que o cliente paga depois do cupom e dos créditos. Cancelamentos feitos pela
+// prestadora ou de agendamentos ainda não confirmados nunca geram taxa para o cliente.
+func (s *CancellationService) ComputeFee(appointment *models.Appointment, policy models.CancellationPolicy, cancelledBy uint, cancelledAt time.Time) float64 {
+	if cancelledBy != appointment.UserID || appointment.Status == models.AppointmentStatusPending {
+		return 0
+	}
+
+	notice := appointment.StartsAt().Sub(cancelledAt)
+	percent := 100.0
+	for _, tier := range s.Tiers(policy) {
+		if notice >= tier.MinNotice {
+			percent = tier.FeePercent
+			break
+		}
+	}
+
+	fee := math.Round(appointment.Price*percent) / 100
+	paid := float64(AppointmentAmountDue(appointment)) / 100
+	return math.Min(fee, paid)
+}
+
+// Cancel marca o agendamento como cancelado e registra quem cancelou e a taxa devida
+func (s *CancellationService) Cancel(appointment *models.Appointment, policy models.CancellationPolicy, cancelledBy uint, reason string) error {
+	now := s.now()
+	fee := s.ComputeFee(appointment, policy, cancelledBy, now)
+	if err := s.markCancelled(appointment, cancelledBy, reason, now); err != nil {
+		return err
+	}
+
+	appointment.CancellationFee = fee
+	return nil
+}
+
+// Skip cancela uma ocorrência de série pulada de forma planejada, sem taxa de cancelamento
+func (s *CancellationService) Skip(appointment *models.Appointment, skippedBy uint, reason string) error {
+	if err := s.markCancelled(appointment, skippedBy, reason, s.now()); err != nil {
+		return err
+	}
+
+	appointment.CancellationFee = 0
+	return nil
+}
+
+// markCancelled marca o agendamento como cancelado em now, se ainda não tiver começado
+func (s *CancellationService) markCancelled(appointment *models.Appointment, cancelledBy uint, reason string, now time.Time) error {
+	switch appointment.Status {
+	case models.AppointmentStatusCancelled, models.AppointmentStatusCompleted, models.AppointmentStatusInProgress:
+		return ErrAppointmentNotCancellable
+	}
+
+	appointment.Status = models.AppointmentStatusCancelled
+	appointment.CancelledAt = &now
+	appointment.CancelledBy = &cancelledBy
+	appointment.CancellationReason = reason
+	return nil
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+)
+
+func TestCancellationComputeFee(t *testing.T) {
+	now := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	service := &CancellationService{now: func() time.Time { return now }}
+	const client, provider = 1, 2
+
+	tests := []struct {
+		name        string
+		policy      models.CancellationPolicy
+		notice      time.Duration
+		cancelledBy uint
+		status      models.AppointmentStatus
+		credit      int64 // Créditos aplicados na reserva, em centavos
+		want        float64
+	}{
+		{"flexível com antecedência", models.CancellationPolicyFlexible, 25 * time.Hour, client, models.AppointmentStatusConfirmed, 0, 0},
+		{"flexível em cima da hora", models.CancellationPolicyFlexible, 2 * time.Hour, client, models.AppointmentStatusConfirmed, 0, 50},
+		{"moderada entre 24h e 48h", models.CancellationPolicyModerate, 30 * time.Hour, client, models.AppointmentStatusConfirmed, 0, 50},
+		{"moderada em cima da hora", models.CancellationPolicyModerate, time.Hour, client, models.AppointmentStatusConfirmed, 0, 100},
+		{"rígida entre 24h e 72h", models.CancellationPolicyStrict, 48 * time.Hour, client, models.AppointmentStatusConfirmed, 0, 100},
+		{"rígida em cima da hora", models.CancellationPolicyStrict, time.Hour, client, models.AppointmentStatusConfirmed, 0, 200},
+		{"depois do início", models.CancellationPolicyFlexible, -time.Hour, client, models.AppointmentStatusConfirmed, 0, 200},
+		{"cancelada pela prestadora", models.CancellationPolicyStrict, time.Hour, provider, models.AppointmentStatusConfirmed, 0, 0},
+		{"política inválida usa flexível", "", 2 * time.Hour, client, models.AppointmentStatusConfirmed, 0, 50},
+		{"pendente não gera taxa", models.CancellationPolicyStrict, time.Hour, client, models.AppointmentStatusPending, 0, 0},
+		{"limitada ao valor pago após descontos", models.CancellationPolicyStrict, time.Hour, client, models.AppointmentStatusConfirmed, 15000, 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			startsAt := now.Add(tt.notice)
+			appointment := &models.Appointment{
+				UserID:        client,
+				ProviderID:    provider,
+				Date:          startsAt,
+				Time:          startsAt.Format("15:04"),
+				Price:         200,
+				Status:        tt.status,
+				CreditApplied: tt.credit,
+			}
+			if got := service.ComputeFee(appointment, tt.policy, tt.cancelledBy, now); got != tt.want {
+				t.Errorf("ComputeFee = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCancellationCancel(t *testing.T) {
+	now := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	service := &CancellationService{now: func() time.Time { return now }}
+	const client, provider = 1, 2
+
+	tests := []struct {
+		name    string
+		status  models.AppointmentStatus
+		wantErr error
+		wantFee float64
+	}{
+		{"pendente", models.AppointmentStatusPending, nil, 0},
+		{"confirmado", models.AppointmentStatusConfirmed, nil, 100},
+		{"em andamento", models.AppointmentStatusInProgress, ErrAppointmentNotCancellable, 0},
+		{"concluído", models.AppointmentStatusCompleted, ErrAppointmentNotCancellable, 0},
+		{"já cancelado", models.AppointmentStatusCancelled, ErrAppointmentNotCancellable, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			startsAt := now.Add(time.Hour)
+			appointment := &models.Appointment{
+				UserID:     client,
+				ProviderID: provider,
+				Date:       startsAt,
+				Time:       startsAt.Format("15:04"),
+				Price:      200,
+				Status:     tt.status,
+			}
+			err := service.Cancel(appointment, models.CancellationPolicyModerate, client, "imprevisto")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Cancel: err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if appointment.Status != tt.status {
+					t.Errorf("status %s alterado; want %s", appointment.Status, tt.status)
+				}
+				return
+			}
+			if appointment.Status != models.AppointmentStatusCancelled || appointment.CancellationFee != tt.wantFee ||
+				appointment.CancelledBy == nil || *appointment.CancelledBy != client ||
+				appointment.CancelledAt == nil || !appointment.CancelledAt.Equal(now) || appointment.CancellationReason != "imprevisto" {
+				t.Errorf("agendamento %+v; want cancelado pelo cliente às %s com taxa %v", appointment, now, tt.wantFee)
+			}
+		})
+	}
+}
+
+func TestCancellationSkipIsFeeFree(t *testing.T) {
+	now := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	service := &CancellationService{now: func() time.Time { return now }}
+	startsAt := now.Add(time.Hour)
+	appointment := &models.Appointment{
+		UserID:     1,
+		ProviderID: 2,
+		Date:       startsAt,
+		Time:       startsAt.Format("15:04"),
+		Price:      200,
+		Status:     models.AppointmentStatusConfirmed,
+	}
+
+	if err := service.Skip(appointment, 1, "Ocorrência pulada"); err != nil {
+		t.Fatalf("Skip: %v", err)
+	}
+	if appointment.Status != models.AppointmentStatusCancelled || appointment.CancellationFee != 0 {
+		t.Errorf("Skip: status %s, taxa %v; want cancelled sem taxa", appointment.Status, appointment.CancellationFee)
+	}
+
+	if err := service.Skip(appointment, 1, ""); !errors.Is(err, ErrAppointmentNotCancellable) {
+		t.Errorf("Skip de agendamento cancelado: err = %v, want ErrAppointmentNotCancellable", err)
+	}
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+)
+
+var (
+	ErrInvalidCommissionRule = errors.New("regra de comissão inválida")
+)
+
+// CommissionService escolhe a regra de comissão de cada atendimento e registra a divisão
+// do repasse entre a prestadora e a plataforma
+type CommissionService struct {
+	commissionRepo *repositories.CommissionRepository
+	userRepo       *repositories.UserRepository
+	tipRepo        *repositories.TipRepository
+	now            func() time.Time
+}
+
+func NewCommissionService(
+	commissionRepo *repositories.CommissionRepository,
+	userRepo *repositories.UserRepository,
+	tipRepo *repositories.TipRepository,
+) *CommissionService {
+	return &CommissionService{
+		commissionRepo: commissionRepo,
+		userRepo:       userRepo,
+		tipRepo:        tipRepo,
+		now:            time.Now,
+	}
+}
+
+// ValidateRule confere os valores de uma regra antes de salvá-la
+func (s *CommissionService) ValidateRule(rule *models.CommissionRule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	rule.City = strings.TrimSpace(rule.City)
+
+	switch {
+	case rule.Name == "":
+		return fmt.Errorf("%w: informe o nome", ErrInvalidCommissionRule)
+	case rule.PercentBps < 0 || rule.PercentBps > 10000:
+		return fmt.Errorf("%w: percentual deve estar entre 0 e 10000 pontos-base", ErrInvalidCommissionRule)
+	case rule.FixedAmount < 0:
+		return fmt.Errorf("%w: valor fixo negativo", ErrInvalidCommissionRule)
+	case rule.ProviderTier != "" && !rule.ProviderTier.IsValid():
+		return fmt.Errorf("%w: categoria de prestadora desconhecida", ErrInvalidCommissionRule)
+	case rule.StartsAt != nil && rule.EndsAt != nil && !rule.StartsAt.Before(*rule.EndsAt):
+		return fmt.Errorf("%w: o período termina antes de começar", ErrInvalidCommissionRule)
+	}
+	return nil
+}
+
+// RuleFor retorna a regra mais específica que se aplica à prestadora no instante at, ou
+// nil se nenhuma se aplica. Entre regras igualmente específicas, vale a mais recente.
+func (s *CommissionService) RuleFor(profile *models.ProviderProfile, at time.Time) (*models.CommissionRule, error) {
+	rules, err := s.commissionRepo.GetActiveRules(at)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected *models.CommissionRule
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.Applies(profile.City, profile.Tier, at) {
+			continue
+		}
+		if selected == nil || rule.Specificity() > selected.Specificity() ||
+			(rule.Specificity() == selected.Specificity() && rule.ID > selected.ID) {
+			selected = rule
+		}
+	}
+	return selected, nil
+}
+
+// Quote divide amount centavos entre a prestadora e a plataforma pela regra vigente em at.
+// Sem regra aplicável, a plataforma não retém comissão.
+func (s *CommissionService) Quote(providerID uint, amount int64, at time.Time) (*models.PaymentSettlement, error) {
+	quote, err := s.Quoter(providerID, at)
+	if err != nil {
+		return nil, err
+	}
+	return quote(amount), nil
+}
+
+// Quoter busca a regra vigente em at para a prestadora e retorna a função que divide um
+// valor por ela. A função não acessa o banco e pode ser usada dentro de uma transação.
+func (s *CommissionService) Quoter(providerID uint, at time.Time) (func(amount int64) *models.PaymentSettlement, error) {
+	profile, err := s.userRepo.GetProviderProfile(providerID)
+	if errors.Is(err, repositories.ErrUserNotFound) {
+		profile = &models.ProviderProfile{UserID: providerID, Tier: models.ProviderTierStandard}
+	} else if err != nil {
+		return nil, err
+	}
+
+	rule, err := s.RuleFor(profile, at)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(amount int64) *models.PaymentSettlement {
+		settlement := &models.PaymentSettlement{
+			ProviderID:     providerID,
+			GrossAmount:    amount,
+			ProviderAmount: amount,
+			City:           profile.City,
+			ProviderTier:   profile.Tier,
+		}
+		if rule != nil {
+			settlement.PlatformFee = rule.Fee(amount)
+			settlement.ProviderAmount = amount - settlement.PlatformFee
+			settlement.CommissionRuleID = &rule.ID
+			settlement.CommissionRuleVersion = rule.Version
+			settlement.CommissionRuleName = rule.Name
+			settlement.CommissionPercentBps = rule.PercentBps
+			settlement.CommissionFixedAmount = rule.FixedAmount
+		}
+		return settlement
+	}, nil
+}
+
+// EarningsStatement é o extrato de ganhos da prestadora no período, em centavos. O valor
+// líquido soma os repasses, já descontada a comissão, e as gorjetas, que não têm comissão.
+type EarningsStatement struct {
+	From        time.Time                  `json:"from"`
+	To          time.Time                  `json:"to"`
+	Currency    string                     `json:"currency"`
+	Gross       int64                      `json:"gross"`
+	PlatformFee int64                      `json:"platform_fee"`
+	Tips        int64                      `json:"tips"`
+	Net         int64                      `json:"net"`
+	Settlements []models.PaymentSettlement `json:"settlements"`
+	TipItems    []models.Tip               `json:"tip_items"`
+}
+
+// EarningsStatement lista os repasses da prestadora no período [from, to), com a
+// comissão retida em cada um, e as gorjetas recebidas
+func (s *CommissionService) EarningsStatement(providerID uint, from, to time.Time) (*EarningsStatement, error) {
+	settlements, err := s.commissionRepo.GetSettlementsByProvider(providerID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	tips, err := s.tipRepo.GetPaidByProvider(providerID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &EarningsStatement{
+		From:        from,
+		To:          to,
+		Currency:    models.PaymentCurrency,
+		Settlements: settlements,
+		TipItems:    tips,
+	}
+	for _, settlement := range settlements {
+		statement.Gross += settlement.GrossAmount
+		statement.PlatformFee += settlement.PlatformFee
+		statement.Net += settlement.ProviderAmount
+	}
+	for _, tip := range tips {
+		statement.Tips += tip.Amount
+		statement.Net += tip.Amount
+	}
+	return statement, nil
+}
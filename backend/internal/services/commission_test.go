@@ -0,0 +1,141 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/testutil"
+)
+
+func TestCommissionRuleFor(t *testing.T) {
+	db := testutil.NewDB(t)
+	commissionRepo := repositories.NewCommissionRepository(db)
+	service := NewCommissionService(commissionRepo, repositories.NewUserRepository(db), repositories.NewTipRepository(db))
+
+	promoStart := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	promoEnd := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	rules := []*models.CommissionRule{
+		{Name: "geral", Active: true, PercentBps: 1500},
+		{Name: "geral 2026", Active: true, PercentBps: 1800},
+		{Name: "pro", Active: true, PercentBps: 1200, ProviderTier: models.ProviderTierPro},
+		{Name: "São Paulo", Active: true, PercentBps: 1000, FixedAmount: 200, City: "São Paulo"},
+		{Name: "promoção de março", Active: true, PercentBps: 500, StartsAt: &promoStart, EndsAt: &promoEnd},
+		{Name: "Campinas", Active: false, PercentBps: 0, City: "Campinas"},
+	}
+	for _, rule := range rules {
+		if err := commissionRepo.CreateRule(rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	february := time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		city     string
+		tier     models.ProviderTier
+		at       time.Time
+		wantRule string
+		wantFee  int64
+	}{
+		{"regra geral mais recente", "Rio de Janeiro", models.ProviderTierStandard, february, "geral 2026", 1800},
+		{"categoria vence a regra geral", "Rio de Janeiro", models.ProviderTierPro, february, "pro", 1200},
+		{"cidade vence a categoria, sem diferenciar maiúsculas", "são paulo", models.ProviderTierPro, february, "São Paulo", 1200},
+		{"período promocional vence a cidade", "São Paulo", models.ProviderTierStandard, promoStart, "promoção de março", 500},
+		{"fim do período é exclusivo", "São Paulo", models.ProviderTierStandard, promoEnd, "São Paulo", 1200},
+		{"regra inativa é ignorada", "Campinas", models.ProviderTierStandard, february, "geral 2026", 1800},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := service.RuleFor(&models.ProviderProfile{City: tt.city, Tier: tt.tier}, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rule == nil || rule.Name != tt.wantRule || rule.Fee(10000) != tt.wantFee {
+				t.Errorf("regra %+v; want %q com comissão %d", rule, tt.wantRule, tt.wantFee)
+			}
+		})
+	}
+}
+
+func TestEscrowReleaseAppliesCommission(t *testing.T) {
+	tests := []struct {
+		name         string
+		rule         *models.CommissionRule
+		update       bool // a regra é alterada entre o pagamento e o repasse
+		wantFee      int64
+		wantVersion  int
+		wantProvider int64
+	}{
+		{"sem regra a prestadora recebe tudo", nil, false, 0, 0, 10000},
+		{"percentual com valor fixo", &models.CommissionRule{Name: "geral", Active: true, PercentBps: 1500, FixedAmount: 100}, false, 1600, 1, 8400},
+		{"vale a versão vigente no repasse", &models.CommissionRule{Name: "geral", Active: true, PercentBps: 1500}, true, 2000, 2, 8000},
+		{"comissão limitada ao valor pago", &models.CommissionRule{Name: "fixa", Active: true, FixedAmount: 15000}, false, 10000, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			client := testutil.CreateUser(t, db, models.UserTypeClient)
+			provider := testutil.CreateProvider(t, db, models.ProviderProfile{City: "São Paulo"})
+			appointment := testutil.CreateAppointment(t, db, models.Appointment{
+				UserID: client.ID, ProviderID: provider.ID, Price: 100,
+				Date: time.Now().UTC().AddDate(0, 0, 3).Truncate(24 * time.Hour),
+			})
+			commissionRepo := repositories.NewCommissionRepository(db)
+			if tt.rule != nil {
+				if err := commissionRepo.CreateRule(tt.rule); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			service := newPaymentService(db, NewFakePaymentGateway())
+			payment, err := service.CreateIntent(appointment)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := service.Confirm(payment, "pm_card_visa"); err != nil {
+				t.Fatalf("Confirm: %v", err)
+			}
+
+			if tt.update {
+				tt.rule.PercentBps = 2000
+				if err := commissionRepo.UpdateRule(tt.rule); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := service.paymentRepo.ScheduleEscrowRelease(payment.ID, time.Now().Add(-time.Minute)); err != nil {
+				t.Fatal(err)
+			}
+			if err := service.ReleaseDueEscrows(); err != nil {
+				t.Fatal(err)
+			}
+
+			settlement, err := commissionRepo.FindSettlementByPayment(payment.ID)
+			if err != nil {
+				t.Fatalf("repasse não registrado: %v", err)
+			}
+			if settlement.GrossAmount != 10000 || settlement.PlatformFee != tt.wantFee ||
+				settlement.ProviderAmount != tt.wantProvider || settlement.CommissionRuleVersion != tt.wantVersion {
+				t.Errorf("repasse %+v; want comissão %d, prestadora %d, versão %d", settlement, tt.wantFee, tt.wantProvider, tt.wantVersion)
+			}
+
+			// O razão acompanha a divisão: a prestadora e a plataforma ficam com saldo credor
+			balance, err := service.ledger.UserBalance(models.LedgerAccountProvider, provider.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			revenue, err := service.ledger.account(models.LedgerAccountPlatformRevenue, nil, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			platform, err := repositories.NewLedgerRepository(db).Balance(revenue.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if balance.Balance != -tt.wantProvider || platform != -tt.wantFee {
+				t.Errorf("saldos no razão: prestadora %d, plataforma %d; want %d, %d", balance.Balance, platform, -tt.wantProvider, -tt.wantFee)
+			}
+		})
+	}
+}
@@ -0,0 +1,567 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+)
+
+// Formatos em que recibos e extratos podem ser baixados
+const (
+	DocumentFormatJSON = "json"
+	DocumentFormatPDF  = "pdf"
+	DocumentFormatCSV  = "csv"
+)
+
+const (
+	// documentDate é o formato das datas nos documentos
+	documentDate = "02/01/2006"
+	// StatementMonthLayout é o formato do mês dos extratos
+	StatementMonthLayout = "2006-01"
+	// csvSeparator é o separador das planilhas, o padrão das planilhas em português,
+	// em que a vírgula é o separador decimal
+	csvSeparator = ';'
+)
+
+var (
+	ErrReceiptNotAvailable = errors.New("o recibo fica disponível após o repasse à prestadora ou o reembolso do pagamento")
+	ErrInvalidDocument     = errors.New("documento inválido")
+)
+
+// DocumentSigner assina os documentos emitidos com HMAC-SHA256. A assinatura cobre os
+// valores do documento, que podem ser recalculados a partir do razão para conferência.
+type DocumentSigner struct {
+	secret []byte
+}
+
+func NewDocumentSigner(secret string) *DocumentSigner {
+	return &DocumentSigner{secret: []byte(secret)}
+}
+
+// Sign assina os campos informados, na ordem
+func (s *DocumentSigner) Sign(fields ...string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(strings.Join(fields, "|")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify confere a assinatura dos campos informados
+func (s *DocumentSigner) Verify(signature string, fields ...string) bool {
+	expected := s.Sign(fields...)
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(strings.TrimSpace(signature))))
+}
+
+// PaymentReceipt é o recibo do pagamento de um agendamento, em centavos. Os valores vêm dos
+// lançamentos do razão e são assinados; nomes, serviço e status são apenas informativos.
+type PaymentReceipt struct {
+	PaymentID             uint                     `json:"payment_id"`
+	AppointmentID         uint                     `json:"appointment_id"`
+	ClientName            string                   `json:"client_name,omitempty"`
+	ProviderName          string                   `json:"provider_name,omitempty"`
+	Service               string                   `json:"service"`
+	Date                  time.Time                `json:"date"`
+	Method                models.PaymentMethodType `json:"method"`
+	Status                models.PaymentStatus     `json:"status"`
+	PaidAt                *time.Time               `json:"paid_at,omitempty"`
+	Currency              string                   `json:"currency"`
+	Amount                int64                    `json:"amount"`
+	RefundedAmount        int64                    `json:"refunded_amount"`
+	NetAmount             int64                    `json:"net_amount"`
+	PromoDiscount         int64                    `json:"promo_discount"` // Custeado pela plataforma
+	CreditApplied         int64                    `json:"credit_applied"` // Pago com créditos da carteira
+	PlatformFee           int64                    `json:"platform_fee"`   // Já incluída no valor pago
+	ProviderAmount        int64                    `json:"provider_amount"`
+	CommissionRule        string                   `json:"commission_rule,omitempty"`
+	CommissionRuleVersion int                      `json:"commission_rule_version,omitempty"`
+	Signature             string                   `json:"signature,omitempty"`
+}
+
+// StatementLine é um repasse ou uma gorjeta do extrato mensal, em centavos. O valor bruto é
+// o do atendimento, incluindo cupom e créditos; o líquido é o que coube à prestadora.
+type StatementLine struct {
+	Date          time.Time                    `json:"date"`
+	Kind          models.LedgerTransactionKind `json:"kind"`
+	AppointmentID uint                         `json:"appointment_id"`
+	PaymentID     *uint                        `json:"payment_id,omitempty"`
+	Gross         int64                        `json:"gross"`
+	Refunded      int64                        `json:"refunded"`
+	PlatformFee   int64                        `json:"platform_fee"`
+	Tip           int64                        `json:"tip"`
+	Net           int64                        `json:"net"`
+}
+
+// MonthlyStatement é o extrato mensal de ganhos da prestadora, em centavos, montado a partir
+// dos lançamentos do razão. Líquido = bruto − reembolsos − taxas + gorjetas.
+type MonthlyStatement struct {
+	ProviderID   uint            `json:"provider_id"`
+	ProviderName string          `json:"provider_name"`
+	Month        string          `json:"month"`
+	From         time.Time       `json:"from"`
+	To           time.Time       `json:"to"`
+	Currency     string          `json:"currency"`
+	Gross        int64           `json:"gross"`
+	Refunds      int64           `json:"refunds"`
+	PlatformFees int64           `json:"platform_fees"`
+	Tips         int64           `json:"tips"`
+	Net          int64           `json:"net"`
+	Lines        []StatementLine `json:"lines"`
+	Signature    string          `json:"signature"`
+}
+
+// DocumentService emite os recibos de pagamento e os extratos mensais das prestadoras em
+// JSON, PDF e CSV, assinados para conferência
+type DocumentService struct {
+	paymentRepo     *repositories.PaymentRepository
+	appointmentRepo *repositories.AppointmentRepository
+	ledgerRepo      *repositories.LedgerRepository
+	userRepo        *repositories.UserRepository
+	commissionRepo  *repositories.CommissionRepository
+	signer          *DocumentSigner
+	location        *time.Location
+}
+
+func NewDocumentService(
+	paymentRepo *repositories.PaymentRepository,
+	appointmentRepo *repositories.AppointmentRepository,
+	ledgerRepo *repositories.LedgerRepository,
+	userRepo *repositories.UserRepository,
+	commissionRepo *repositories.CommissionRepository,
+	signer *DocumentSigner,
+	location *time.Location,
+) *DocumentService {
+	if location == nil {
+		location = time.UTC
+	}
+	return &DocumentService{
+		paymentRepo:     paymentRepo,
+		appointmentRepo: appointmentRepo,
+		ledgerRepo:      ledgerRepo,
+		userRepo:        userRepo,
+		commissionRepo:  commissionRepo,
+		signer:          signer,
+		location:        location,
+	}
+}
+
+// Receipt monta e assina o recibo de um pagamento encerrado, repassado à prestadora ou
+// devolvido ao cliente, a partir dos lançamentos do razão. Depois do encerramento esses
+// lançamentos não mudam, e a assinatura continua válida.
+func (s *DocumentService) Receipt(payment *models.Payment) (*PaymentReceipt, error) {
+	if payment.PaidAt == nil ||
+		(payment.EscrowStatus != models.EscrowStatusReleased && payment.EscrowStatus != models.EscrowStatusRefunded) {
+		return nil, ErrReceiptNotAvailable
+	}
+
+	appointment, err := s.appointmentRepo.FindDetailByID(payment.AppointmentID)
+	if err != nil {
+		return nil, err
+	}
+	postings, err := s.ledgerRepo.ReceiptPostings(payment.ID, payment.AppointmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	receipt := &PaymentReceipt{
+		PaymentID:     payment.ID,
+		AppointmentID: payment.AppointmentID,
+		ClientName:    appointment.User.Name,
+		ProviderName:  appointment.Provider.Name,
+		Service:       appointment.Service,
+		Date:          appointment.Date,
+		Method:        payment.Method,
+		Status:        payment.Status,
+		PaidAt:        payment.PaidAt,
+		Currency:      payment.Currency,
+	}
+	// Saldos credores são negativos no razão
+	for _, posting := range postings {
+		switch {
+		case posting.Kind == models.LedgerTransactionPayment && posting.AccountType == models.LedgerAccountGatewayClearing:
+			receipt.Amount += posting.Amount
+		case posting.Kind == models.LedgerTransactionRefund && posting.AccountType == models.LedgerAccountGatewayClearing:
+			receipt.RefundedAmount -= posting.Amount
+		case posting.AccountType == models.LedgerAccountPromotions:
+			receipt.PromoDiscount += posting.Amount
+		case (posting.Kind == models.LedgerTransactionCreditHold || posting.Kind == models.LedgerTransactionCreditReturn) &&
+			posting.AccountType == models.LedgerAccountEscrow:
+			receipt.CreditApplied -= posting.Amount
+		case posting.Kind == models.LedgerTransactionEscrowRelease && posting.AccountType == models.LedgerAccountPlatformRevenue:
+			receipt.PlatformFee -= posting.Amount
+		case posting.Kind == models.LedgerTransactionEscrowRelease && posting.AccountType == models.LedgerAccountProvider:
+			receipt.ProviderAmount -= posting.Amount
+		}
+	}
+	receipt.NetAmount = receipt.Amount - receipt.RefundedAmount
+
+	if settlement, err := s.commissionRepo.FindSettlementByPayment(payment.ID); err == nil {
+		receipt.CommissionRule = settlement.CommissionRuleName
+		receipt.CommissionRuleVersion = settlement.CommissionRuleVersion
+	} else if !errors.Is(err, repositories.ErrSettlementNotFound) {
+		return nil, err
+	}
+
+	receipt.Signature = s.signer.Sign(receiptFields(receipt)...)
+	return receipt, nil
+}
+
+func receiptFields(receipt *PaymentReceipt) []string {
+	return []string{
+		"receipt",
+		strconv.FormatUint(uint64(receipt.PaymentID), 10),
+		strconv.FormatUint(uint64(receipt.AppointmentID), 10),
+		receipt.PaidAt.UTC().Format(time.RFC3339),
+		receipt.Currency,
+		strconv.FormatInt(receipt.Amount, 10),
+		strconv.FormatInt(receipt.RefundedAmount, 10),
+		strconv.FormatInt(receipt.PromoDiscount, 10),
+		strconv.FormatInt(receipt.CreditApplied, 10),
+		strconv.FormatInt(receipt.PlatformFee, 10),
+		strconv.FormatInt(receipt.ProviderAmount, 10),
+	}
+}
+
+// VerifyReceipt recalcula o recibo do pagamento e confere a assinatura informada
+func (s *DocumentService) VerifyReceipt(paymentID uint, signature string) (*PaymentReceipt, bool, error) {
+	payment, err := s.paymentRepo.FindByID(paymentID)
+	if err != nil {
+		return nil, false, err
+	}
+	receipt, err := s.Receipt(payment)
+	if err != nil {
+		return nil, false, err
+	}
+	return receipt, s.signer.Verify(signature, receiptFields(receipt)...), nil
+}
+
+// ParseStatementMonth interpreta o mês de um extrato no formato AAAA-MM, no fuso dos agendamentos
+func (s *DocumentService) ParseStatementMonth(value string) (time.Time, error) {
+	month, err := time.ParseInLocation(StatementMonthLayout, value, s.location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: mês deve estar no formato AAAA-MM", ErrInvalidDocument)
+	}
+	return month, nil
+}
+
+// MonthlyStatement monta e assina o extrato da prestadora no mês que começa em month,
+// com os repasses e as gorjetas lançados no razão no período
+func (s *DocumentService) MonthlyStatement(providerID uint, month time.Time) (*MonthlyStatement, error) {
+	provider, err := s.userRepo.FindByID(providerID)
+	if err != nil {
+		return nil, err
+	}
+
+	from := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, s.location)
+	to := from.AddDate(0, 1, 0)
+	postings, err := s.ledgerRepo.ProviderStatementPostings(providerID,
+		[]models.LedgerTransactionKind{models.LedgerTransactionEscrowRelease, models.LedgerTransactionTipTransfer}, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// Agrupa as partidas por lançamento, mantendo a ordem cronológica
+	var lines []StatementLine
+	index := map[uint]int{}
+	var appointmentIDs []uint
+	for _, posting := range postings {
+		i, ok := index[posting.TransactionID]
+		if !ok {
+			i = len(lines)
+			index[posting.TransactionID] = i
+			lines = append(lines, StatementLine{
+				Date:          posting.CreatedAt,
+				Kind:          posting.Kind,
+				AppointmentID: posting.AppointmentID,
+				PaymentID:     posting.PaymentID,
+			})
+			if posting.Kind == models.LedgerTransactionEscrowRelease {
+				appointmentIDs = append(appointmentIDs, posting.AppointmentID)
+			}
+		}
+
+		line := &lines[i]
+		switch posting.AccountType {
+		case models.LedgerAccountEscrow:
+			line.Gross += posting.Amount
+		case models.LedgerAccountPlatformRevenue:
+			line.PlatformFee -= posting.Amount
+		case models.LedgerAccountProvider:
+			if posting.Kind == models.LedgerTransactionTipTransfer {
+				line.Tip -= posting.Amount
+			}
+			line.Net -= posting.Amount
+		}
+	}
+
+	// O valor liberado já desconta os reembolsos; o bruto os soma de volta
+	returns, err := s.ledgerRepo.EscrowReturns(appointmentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &MonthlyStatement{
+		ProviderID:   providerID,
+		ProviderName: provider.Name,
+		Month:        from.Format(StatementMonthLayout),
+		From:         from,
+		To:           to,
+		Currency:     models.PaymentCurrency,
+		Lines:        lines,
+	}
+	for i := range statement.Lines {
+		line := &statement.Lines[i]
+		if line.Kind == models.LedgerTransactionEscrowRelease {
+			line.Refunded = returns[line.AppointmentID]
+			line.Gross += line.Refunded
+		}
+		statement.Gross += line.Gross
+		statement.Refunds += line.Refunded
+		statement.PlatformFees += line.PlatformFee
+		statement.Tips += line.Tip
+		statement.Net += line.Net
+	}
+	if statement.Lines == nil {
+		statement.Lines = []StatementLine{}
+	}
+	statement.Signature = s.signer.Sign(statementFields(statement)...)
+	return statement, nil
+}
+
+func statementFields(statement *MonthlyStatement) []string {
+	return []string{
+		"statement",
+		strconv.FormatUint(uint64(statement.ProviderID), 10),
+		statement.Month,
+		statement.Currency,
+		strconv.Itoa(len(statement.Lines)),
+		strconv.FormatInt(statement.Gross, 10),
+		strconv.FormatInt(statement.Refunds, 10),
+		strconv.FormatInt(statement.PlatformFees, 10),
+		strconv.FormatInt(statement.Tips, 10),
+		strconv.FormatInt(statement.Net, 10),
+	}
+}
+
+// VerifyStatement recalcula o extrato do mês e confere a assinatura informada
+func (s *DocumentService) VerifyStatement(providerID uint, month time.Time, signature string) (*MonthlyStatement, bool, error) {
+	statement, err := s.MonthlyStatement(providerID, month)
+	if err != nil {
+		return nil, false, err
+	}
+	return statement, s.signer.Verify(signature, statementFields(statement)...), nil
+}
+
+// ReceiptPDF gera o recibo em PDF
+func (s *DocumentService) ReceiptPDF(receipt *PaymentReceipt) []byte {
+	doc := NewPDFDocument(fmt.Sprintf("Recibo %s", receiptNumber(receipt)))
+	doc.Heading("Recibo de pagamento", 18)
+	doc.Paragraph("Nº "+receiptNumber(receipt), 10)
+	doc.Space(10)
+
+	doc.Field("Agendamento", fmt.Sprintf("#%d — %s", receipt.AppointmentID, receipt.Service))
+	doc.Field("Data do atendimento", receipt.Date.Format(documentDate))
+	if receipt.ClientName != "" {
+		doc.Field("Cliente", receipt.ClientName)
+	}
+	if receipt.ProviderName != "" {
+		doc.Field("Prestadora", receipt.ProviderName)
+	}
+	doc.Field("Forma de pagamento", paymentMethodLabel(receipt.Method))
+	doc.Field("Pago em", receipt.PaidAt.In(s.location).Format(documentDate+" 15:04"))
+	doc.Space(10)
+
+	doc.Field("Valor do atendimento", FormatBRL(receipt.Amount+receipt.PromoDiscount+receipt.CreditApplied))
+	if receipt.PromoDiscount > 0 {
+		doc.Field("Desconto de cupom", FormatBRL(-receipt.PromoDiscount))
+	}
+	if receipt.CreditApplied > 0 {
+		doc.Field("Créditos da carteira", FormatBRL(-receipt.CreditApplied))
+	}
+	doc.Field("Valor pago", FormatBRL(receipt.Amount))
+	if receipt.RefundedAmount > 0 {
+		doc.Field("Reembolsado", FormatBRL(-receipt.RefundedAmount))
+	}
+	doc.Field("Valor líquido", FormatBRL(receipt.NetAmount))
+	doc.Field("Taxa da plataforma (incluída)", FormatBRL(receipt.PlatformFee))
+	doc.Field("Valor da prestadora", FormatBRL(receipt.ProviderAmount))
+	doc.Space(20)
+
+	writeSignature(doc, receipt.Signature)
+	return doc.Bytes()
+}
+
+// ReceiptCSV gera o recibo em CSV, com um campo por linha
+func (s *DocumentService) ReceiptCSV(receipt *PaymentReceipt) ([]byte, error) {
+	rows := [][]string{
+		{"campo", "valor"},
+		{"recibo", receiptNumber(receipt)},
+		{"pagamento", strconv.FormatUint(uint64(receipt.PaymentID), 10)},
+		{"agendamento", strconv.FormatUint(uint64(receipt.AppointmentID), 10)},
+		{"servico", receipt.Service},
+		{"data_atendimento", receipt.Date.Format(documentDate)},
+		{"cliente", receipt.ClientName},
+		{"prestadora", receipt.ProviderName},
+		{"forma_pagamento", string(receipt.Method)},
+		{"pago_em", receipt.PaidAt.In(s.location).Format(time.RFC3339)},
+		{"moeda", receipt.Currency},
+		{"valor_atendimento", csvAmount(receipt.Amount + receipt.PromoDiscount + receipt.CreditApplied)},
+		{"desconto_cupom", csvAmount(receipt.PromoDiscount)},
+		{"creditos_carteira", csvAmount(receipt.CreditApplied)},
+		{"valor_pago", csvAmount(receipt.Amount)},
+		{"reembolsado", csvAmount(receipt.RefundedAmount)},
+		{"valor_liquido", csvAmount(receipt.NetAmount)},
+		{"taxa_plataforma", csvAmount(receipt.PlatformFee)},
+		{"valor_prestadora", csvAmount(receipt.ProviderAmount)},
+		{"assinatura", receipt.Signature},
+	}
+	return encodeCSV(rows)
+}
+
+// StatementPDF gera o extrato mensal em PDF
+func (s *DocumentService) StatementPDF(statement *MonthlyStatement) []byte {
+	doc := NewPDFDocument(fmt.Sprintf("Extrato %s", statement.Month))
+	doc.Heading("Extrato mensal de ganhos", 18)
+	doc.Paragraph(fmt.Sprintf("%s — %s", statement.ProviderName, monthLabel(statement.From)), 10)
+	doc.Paragraph(fmt.Sprintf("Período: %s a %s", statement.From.Format(documentDate), statement.To.AddDate(0, 0, -1).Format(documentDate)), 10)
+	doc.Space(10)
+
+	doc.Field("Valor bruto", FormatBRL(statement.Gross))
+	doc.Field("Reembolsos", FormatBRL(-statement.Refunds))
+	doc.Field("Taxas da plataforma", FormatBRL(-statement.PlatformFees))
+	doc.Field("Gorjetas", FormatBRL(statement.Tips))
+	doc.Field("Valor líquido", FormatBRL(statement.Net))
+	doc.Space(16)
+
+	rows := make([][]string, 0, len(statement.Lines))
+	for _, line := range statement.Lines {
+		rows = append(rows, []string{
+			line.Date.In(s.location).Format(documentDate),
+			statementKindLabel(line.Kind),
+			fmt.Sprintf("#%d", line.AppointmentID),
+			FormatBRL(line.Gross),
+			FormatBRL(line.Refunded),
+			FormatBRL(line.PlatformFee),
+			FormatBRL(line.Tip),
+			FormatBRL(line.Net),
+		})
+	}
+	if len(rows) == 0 {
+		doc.Paragraph("Nenhum repasse ou gorjeta no período.", 10)
+	} else {
+		doc.Table([]float64{0, 55, 110, 165, 230, 295, 360, 425},
+			[]string{"Data", "Tipo", "Agend.", "Bruto", "Reembolso", "Taxa", "Gorjeta", "Líquido"}, rows)
+	}
+	doc.Space(10)
+
+	writeSignature(doc, statement.Signature)
+	return doc.Bytes()
+}
+
+// StatementCSV gera o extrato mensal em CSV, com uma linha por repasse ou gorjeta e o total
+func (s *DocumentService) StatementCSV(statement *MonthlyStatement) ([]byte, error) {
+	rows := [][]string{{"data", "tipo", "agendamento", "pagamento", "bruto", "reembolsado", "taxa_plataforma", "gorjeta", "liquido"}}
+	for _, line := range statement.Lines {
+		payment := ""
+		if line.PaymentID != nil {
+			payment = strconv.FormatUint(uint64(*line.PaymentID), 10)
+		}
+		rows = append(rows, []string{
+			line.Date.In(s.location).Format(time.RFC3339),
+			string(line.Kind),
+			strconv.FormatUint(uint64(line.AppointmentID), 10),
+			payment,
+			csvAmount(line.Gross),
+			csvAmount(line.Refunded),
+			csvAmount(line.PlatformFee),
+			csvAmount(line.Tip),
+			csvAmount(line.Net),
+		})
+	}
+	rows = append(rows,
+		[]string{"total", "", "", "", csvAmount(statement.Gross), csvAmount(statement.Refunds),
+			csvAmount(statement.PlatformFees), csvAmount(statement.Tips), csvAmount(statement.Net)},
+		[]string{"assinatura", statement.Signature},
+	)
+	return encodeCSV(rows)
+}
+
+func writeSignature(doc *PDFDocument, signature string) {
+	doc.Paragraph("Assinatura digital (HMAC-SHA256):", 8)
+	doc.Paragraph(signature, 8)
+	doc.Paragraph("Confira a autenticidade em /api/documents/verify.", 8)
+}
+
+func receiptNumber(receipt *PaymentReceipt) string {
+	return fmt.Sprintf("XC-%06d", receipt.PaymentID)
+}
+
+// encodeCSV grava as linhas com o separador das planilhas em português
+func encodeCSV(rows [][]string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = csvSeparator
+	if err := w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// csvAmount formata centavos com vírgula decimal, sem separador de milhar
+func csvAmount(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	return fmt.Sprintf("%s%d,%02d", sign, cents/100, cents%100)
+}
+
+// FormatBRL formata centavos em reais, como "R$ 1.234,56"
+func FormatBRL(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+
+	digits := strconv.FormatInt(cents/100, 10)
+	var integer strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			integer.WriteByte('.')
+		}
+		integer.WriteRune(d)
+	}
+	return fmt.Sprintf("%sR$ %s,%02d", sign, integer.String(), cents%100)
+}
+
+var monthNames = [...]string{"janeiro", "fevereiro", "março", "abril", "maio", "junho",
+	"julho", "agosto", "setembro", "outubro", "novembro", "dezembro"}
+
+func monthLabel(month time.Time) string {
+	return fmt.Sprintf("%s de %d", monthNames[month.Month()-1], month.Year())
+}
+
+func paymentMethodLabel(method models.PaymentMethodType) string {
+	switch method {
+	case models.PaymentMethodCard:
+		return "Cartão"
+	case models.PaymentMethodPix:
+		return "Pix"
+	}
+	return string(method)
+}
+
+func statementKindLabel(kind models.LedgerTransactionKind) string {
+	if kind == models.LedgerTransactionTipTransfer {
+		return "Gorjeta"
+	}
+	return "Repasse"
+}
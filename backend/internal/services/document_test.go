@@ -0,0 +1,213 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/testutil"
+)
+
+func TestFormatAmounts(t *testing.T) {
+	tests := []struct {
+		cents   int64
+		wantBRL string
+		wantCSV string
+	}{
+		{0, "R$ 0,00", "0,00"},
+		{5, "R$ 0,05", "0,05"},
+		{123456, "R$ 1.234,56", "1234,56"},
+		{100000000, "R$ 1.000.000,00", "1000000,00"},
+		{-2550, "-R$ 25,50", "-25,50"},
+	}
+	for _, tt := range tests {
+		if got := FormatBRL(tt.cents); got != tt.wantBRL {
+			t.Errorf("FormatBRL(%d) = %q, want %q", tt.cents, got, tt.wantBRL)
+		}
+		if got := csvAmount(tt.cents); got != tt.wantCSV {
+			t.Errorf("csvAmount(%d) = %q, want %q", tt.cents, got, tt.wantCSV)
+		}
+	}
+}
+
+func TestDocumentSigner(t *testing.T) {
+	signer := NewDocumentSigner("segredo")
+	signature := signer.Sign("receipt", "1", "10000")
+
+	if !signer.Verify(signature, "receipt", "1", "10000") {
+		t.Error("assinatura válida recusada")
+	}
+	if !signer.Verify(" "+strings.ToUpper(signature)+" ", "receipt", "1", "10000") {
+		t.Error("assinatura em maiúsculas recusada")
+	}
+	if signer.Verify(signature, "receipt", "1", "10001") {
+		t.Error("assinatura aceita com o valor alterado")
+	}
+	if NewDocumentSigner("outro").Verify(signature, "receipt", "1", "10000") {
+		t.Error("assinatura aceita com outro segredo")
+	}
+}
+
+func TestReceiptAndMonthlyStatement(t *testing.T) {
+	db := testutil.NewDB(t)
+	payments := newPaymentService(db, NewFakePaymentGateway())
+	documents := NewDocumentService(payments.paymentRepo, payments.appointmentRepo, repositories.NewLedgerRepository(db),
+		repositories.NewUserRepository(db), repositories.NewCommissionRepository(db), NewDocumentSigner("segredo"), time.UTC)
+
+	client := testutil.CreateUser(t, db, models.UserTypeClient)
+	unpaid, err := payments.CreateIntent(testutil.CreateAppointment(t, db, models.Appointment{
+		UserID: client.ID, ProviderID: testutil.CreateProvider(t, db, models.ProviderProfile{}).ID, Price: 50,
+		Date: time.Now().UTC().AddDate(0, 0, 3).Truncate(24 * time.Hour),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := documents.Receipt(unpaid); !errors.Is(err, ErrReceiptNotAvailable) {
+		t.Errorf("Receipt sem pagamento: %v, want %v", err, ErrReceiptNotAvailable)
+	}
+
+	checkedOutAt := time.Now().UTC().Add(-EscrowDisputeWindow)
+	appointment, payment := paidAppointment(t, db, payments, checkedOutAt)
+	if err := payments.ScheduleEscrowRelease(appointment); err != nil {
+		t.Fatal(err)
+	}
+	if err := payments.ReleaseDueEscrows(); err != nil {
+		t.Fatal(err)
+	}
+	if payment, err = payments.paymentRepo.FindByID(payment.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	receipt, err := documents.Receipt(payment)
+	if err != nil {
+		t.Fatalf("Receipt: %v", err)
+	}
+	if receipt.Amount != 10000 || receipt.PlatformFee+receipt.ProviderAmount != receipt.Amount {
+		t.Errorf("recibo de %d com taxa %d e repasse %d", receipt.Amount, receipt.PlatformFee, receipt.ProviderAmount)
+	}
+	if _, ok, err := documents.VerifyReceipt(payment.ID, receipt.Signature); err != nil || !ok {
+		t.Errorf("VerifyReceipt = %v, %v; want true", ok, err)
+	}
+	if _, ok, _ := documents.VerifyReceipt(payment.ID, strings.Repeat("0", len(receipt.Signature))); ok {
+		t.Error("VerifyReceipt aceitou uma assinatura falsa")
+	}
+	if pdf := documents.ReceiptPDF(receipt); !bytes.HasPrefix(pdf, []byte("%PDF-")) {
+		t.Errorf("ReceiptPDF não gerou um PDF: %.20q", pdf)
+	}
+
+	month, err := documents.ParseStatementMonth(time.Now().UTC().Format(StatementMonthLayout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	statement, err := documents.MonthlyStatement(appointment.ProviderID, month)
+	if err != nil {
+		t.Fatalf("MonthlyStatement: %v", err)
+	}
+	if len(statement.Lines) != 1 || statement.Gross != 10000 || statement.PlatformFees != receipt.PlatformFee || statement.Net != receipt.ProviderAmount {
+		t.Errorf("extrato com %d linhas, bruto %d, taxas %d e líquido %d; want 1, 10000, %d e %d", len(statement.Lines),
+			statement.Gross, statement.PlatformFees, statement.Net, receipt.PlatformFee, receipt.ProviderAmount)
+	}
+	if _, ok, err := documents.VerifyStatement(appointment.ProviderID, month, statement.Signature); err != nil || !ok {
+		t.Errorf("VerifyStatement = %v, %v; want true", ok, err)
+	}
+	csv, err := documents.StatementCSV(statement)
+	if err != nil || !bytes.Contains(csv, []byte("100,00")) {
+		t.Errorf("StatementCSV = %q, %v; want o bruto de 100,00", csv, err)
+	}
+
+	// Mês sem movimento
+	empty, err := documents.MonthlyStatement(appointment.ProviderID, month.AddDate(0, -1, 0))
+	if err != nil || len(empty.Lines) != 0 || empty.Net != 0 {
+		t.Errorf("extrato do mês anterior = %+v, %v; want vazio", empty, err)
+	}
+	if _, err := documents.ParseStatementMonth("03/2026"); !errors.Is(err, ErrInvalidDocument) {
+		t.Errorf("ParseStatementMonth com formato errado: %v, want %v", err, ErrInvalidDocument)
+	}
+}
+
+func TestReceiptFromLedger(t *testing.T) {
+	tests := []struct {
+		name         string
+		refund       int64
+		release      bool
+		wantErr      error
+		wantRefunded int64
+		wantFee      int64
+		wantProvider int64
+	}{
+		{"em custódia ainda não tem recibo", 0, false, ErrReceiptNotAvailable, 0, 0, 0},
+		{"repassado", 0, true, nil, 0, 1500, 8500},
+		{"repassado após reembolso parcial", 4000, true, nil, 4000, 900, 5100},
+		{"reembolsado integralmente", 10000, true, nil, 10000, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			client := testutil.CreateUser(t, db, models.UserTypeClient)
+			provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+			appointment := testutil.CreateAppointment(t, db, models.Appointment{
+				UserID: client.ID, ProviderID: provider.ID, Price: 100,
+				Date: time.Now().UTC().AddDate(0, 0, 3).Truncate(24 * time.Hour),
+			})
+			commissionRepo := repositories.NewCommissionRepository(db)
+			rule := &models.CommissionRule{Name: "geral", Active: true, PercentBps: 1500}
+			if err := commissionRepo.CreateRule(rule); err != nil {
+				t.Fatal(err)
+			}
+
+			service := newPaymentService(db, NewFakePaymentGateway())
+			documents := NewDocumentService(service.paymentRepo, service.appointmentRepo, repositories.NewLedgerRepository(db),
+				repositories.NewUserRepository(db), commissionRepo, NewDocumentSigner("segredo"), time.UTC)
+
+			payment, err := service.CreateIntent(appointment)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := service.Confirm(payment, "pm_card_visa"); err != nil {
+				t.Fatalf("Confirm: %v", err)
+			}
+			if tt.refund > 0 {
+				if _, err := service.Refund(payment, tt.refund, "ajuste", nil); err != nil {
+					t.Fatalf("Refund: %v", err)
+				}
+			}
+			if tt.release {
+				if err := service.paymentRepo.ScheduleEscrowRelease(payment.ID, time.Now().Add(-time.Minute)); err != nil {
+					t.Fatal(err)
+				}
+				if err := service.ReleaseDueEscrows(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			payment, _ = service.paymentRepo.FindByID(payment.ID)
+			receipt, err := documents.Receipt(payment)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Receipt: %v; want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if receipt.Amount != 10000 || receipt.RefundedAmount != tt.wantRefunded || receipt.NetAmount != 10000-tt.wantRefunded ||
+				receipt.PlatformFee != tt.wantFee || receipt.ProviderAmount != tt.wantProvider {
+				t.Errorf("recibo %+v; want reembolso %d, taxa %d, prestadora %d", receipt, tt.wantRefunded, tt.wantFee, tt.wantProvider)
+			}
+
+			// Alterar a regra depois do encerramento não invalida o recibo já emitido
+			rule.PercentBps = 2000
+			if err := commissionRepo.UpdateRule(rule); err != nil {
+				t.Fatal(err)
+			}
+			if _, valid, err := documents.VerifyReceipt(payment.ID, receipt.Signature); err != nil || !valid {
+				t.Errorf("VerifyReceipt = %v, %v; want assinatura válida", valid, err)
+			}
+			if _, valid, _ := documents.VerifyReceipt(payment.ID, documents.signer.Sign("receipt", "adulterado")); valid {
+				t.Error("assinatura de outro conteúdo foi aceita")
+			}
+		})
+	}
+}
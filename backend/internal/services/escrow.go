@@ -0,0 +1,276 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+)
+
+// EscrowDisputeWindow é o prazo após o check-out para contestar o atendimento antes
+// de o valor retido ser repassado à prestadora
+const EscrowDisputeWindow = 48 * time.Hour
+
+var (
+	ErrDisputeNotAllowed   = errors.New("disputas só podem ser abertas em atendimentos concluídos e pagos")
+	ErrDisputeWindowClosed = errors.New("prazo de contestação do atendimento encerrado")
+	ErrDisputeNotOpen      = errors.New("a disputa já foi resolvida")
+)
+
+// EscrowScheduler é implementado pela camada de pagamentos para agendar o repasse do
+// valor retido quando o atendimento é concluído
+type EscrowScheduler interface {
+	ScheduleEscrowRelease(appointment *models.Appointment) error
+}
+
+// ScheduleEscrowRelease agenda o repasse do valor retido para o fim da janela de
+// contestação, contada a partir da conclusão do atendimento. Sem pagamento, o cupom e
+// o crédito da reserva, se houver, são repassados de imediato.
+func (s *PaymentService) ScheduleEscrowRelease(appointment *models.Appointment) error {
+	payment, err := s.paymentRepo.FindActiveByAppointment(appointment.ID)
+	if errors.Is(err, repositories.ErrPaymentNotFound) {
+		return s.releaseUnpaidIncentives(appointment)
+	}
+	if err != nil {
+		return err
+	}
+	if payment.EscrowStatus != models.EscrowStatusHeld {
+		return nil
+	}
+
+	completedAt := s.now()
+	if appointment.CompletedAt != nil {
+		completedAt = *appointment.CompletedAt
+	}
+	return s.paymentRepo.ScheduleEscrowRelease(payment.ID, completedAt.Add(EscrowDisputeWindow))
+}
+
+// releaseUnpaidIncentives repassa à prestadora, pela regra de comissão vigente, o cupom e
+// o crédito de um agendamento concluído que não teve pagamento
+func (s *PaymentService) releaseUnpaidIncentives(appointment *models.Appointment) error {
+	current, err := s.appointmentRepo.FindByID(appointment.ID)
+	if err != nil {
+		return err
+	}
+	gross := current.PromoDiscount + current.CreditApplied
+	if gross <= 0 {
+		return nil
+	}
+
+	quote, err := s.commission.Quoter(current.ProviderID, s.now())
+	if err != nil {
+		return err
+	}
+	settlement := quote(gross)
+	settlement.AppointmentID = current.ID
+	transaction, err := s.ledger.IncentiveReleaseTransaction(current, settlement)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.paymentRepo.ReleaseUnpaidIncentives(current.ID, gross, transaction,
+		s.auditService.Event(current.ID, nil, models.AppointmentEventEscrowReleased, nil, map[string]interface{}{
+			"released_amount":         gross,
+			"promo_discount":          current.PromoDiscount,
+			"credit_applied":          current.CreditApplied,
+			"platform_fee":            settlement.PlatformFee,
+			"provider_amount":         settlement.ProviderAmount,
+			"commission_rule_id":      settlement.CommissionRuleID,
+			"commission_rule_version": settlement.CommissionRuleVersion,
+		}))
+	return err
+}
+
+// FreezeEscrow congela o valor retido enquanto uma alegação de ausência está em aberto
+func (s *PaymentService) FreezeEscrow(appointment *models.Appointment) error {
+	payment, err := s.paymentRepo.FindActiveByAppointment(appointment.ID)
+	if errors.Is(err, repositories.ErrPaymentNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = s.paymentRepo.FreezeEscrow(payment.ID, s.escrowFrozenEvent(payment))
+	return err
+}
+
+// escrowFrozenEvent registra no histórico o congelamento do valor retido
+func (s *PaymentService) escrowFrozenEvent(payment *models.Payment) *models.AppointmentEvent {
+	return s.auditService.Event(payment.AppointmentID, nil, models.AppointmentEventEscrowFrozen, models.EscrowStatusHeld, map[string]interface{}{
+		"payment_id":    payment.ID,
+		"escrow_status": models.EscrowStatusFrozen,
+	})
+}
+
+// UnfreezeEscrow devolve à custódia o valor congelado por uma alegação de ausência rejeitada
+func (s *PaymentService) UnfreezeEscrow(appointment *models.Appointment) error {
+	payment, err := s.paymentRepo.FindActiveByAppointment(appointment.ID)
+	if errors.Is(err, repositories.ErrPaymentNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = s.paymentRepo.UnfreezeEscrow(payment.ID, s.auditService.Event(appointment.ID, nil, models.AppointmentEventEscrowUnfrozen, models.EscrowStatusFrozen, map[string]interface{}{
+		"payment_id":    payment.ID,
+		"escrow_status": models.EscrowStatusHeld,
+	}))
+	return err
+}
+
+// ReleaseDueEscrows repassa às prestadoras os valores cuja janela de contestação terminou
+func (s *PaymentService) ReleaseDueEscrows() error {
+	now := s.now()
+	due, err := s.paymentRepo.GetDueEscrowReleases(now)
+	if err != nil {
+		return err
+	}
+
+	for i := range due {
+		record, err := s.escrowReleaseRecorder(&due[i], nil)
+		if err != nil {
+			log.Printf("Erro ao repassar pagamento %d: %v", due[i].ID, err)
+			continue
+		}
+		if _, _, err := s.paymentRepo.ReleaseDueEscrow(due[i].ID, now, record); err != nil {
+			log.Printf("Erro ao repassar pagamento %d: %v", due[i].ID, err)
+		}
+	}
+	return nil
+}
+
+// releaseEscrowNow encerra a custódia de um pagamento cujo desfecho já está decidido.
+// Os eventos informados são gravados no histórico junto com o repasse.
+func (s *PaymentService) releaseEscrowNow(payment *models.Payment, actor *uint, events ...*models.AppointmentEvent) error {
+	record, err := s.escrowReleaseRecorder(payment, actor, events...)
+	if err != nil {
+		return err
+	}
+	_, _, err = s.paymentRepo.ReleaseEscrowNow(payment.ID, s.now(), record)
+	return err
+}
+
+// escrowReleaseRecorder monta o registro do encerramento da custódia: a divisão do valor
+// repassado pela regra de comissão vigente, o lançamento no razão e o evento do histórico.
+// A regra e as contas do razão são buscadas antes da transação do repasse, que não acessa
+// o banco além do próprio pagamento.
+func (s *PaymentService) escrowReleaseRecorder(payment *models.Payment, actor *uint, events ...*models.AppointmentEvent) (repositories.EscrowReleaseRecorder, error) {
+	quote, err := s.commission.Quoter(payment.ProviderID, s.now())
+	if err != nil {
+		return nil, err
+	}
+	ledger, err := s.ledger.EscrowReleaseTransactions(payment)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(released *models.Payment, returned repositories.ReturnedIncentives) *repositories.EscrowReleaseRecord {
+		record := &repositories.EscrowReleaseRecord{}
+		details := map[string]interface{}{
+			"payment_id":      released.ID,
+			"escrow_status":   released.EscrowStatus,
+			"released_amount": released.ReleasedAmount,
+			"refunded_amount": released.RefundedAmount,
+		}
+		if returned.PromoDiscount > 0 || returned.Credit > 0 {
+			details["returned_promo_discount"] = returned.PromoDiscount
+			details["returned_credit"] = returned.Credit
+		}
+		var settlement *models.PaymentSettlement
+		if released.ReleasedAmount > 0 {
+			settlement = quote(released.ReleasedAmount)
+			settlement.PaymentID = released.ID
+			settlement.AppointmentID = released.AppointmentID
+			record.Settlement = settlement
+			details["platform_fee"] = settlement.PlatformFee
+			details["provider_amount"] = settlement.ProviderAmount
+			details["commission_rule_id"] = settlement.CommissionRuleID
+			details["commission_rule_version"] = settlement.CommissionRuleVersion
+		}
+		record.Ledger = ledger(settlement, returned)
+		record.Events = append(events, s.auditService.Event(released.AppointmentID, actor, models.AppointmentEventEscrowReleased, nil, details))
+		return record
+	}, nil
+}
+
+// OpenDispute contesta um atendimento concluído dentro da janela de contestação,
+// congelando o valor retido até a decisão de um admin
+func (s *PaymentService) OpenDispute(appointment *models.Appointment, openedBy uint, reason string) (*models.PaymentDispute, error) {
+	if appointment.Status != models.AppointmentStatusCompleted {
+		return nil, ErrDisputeNotAllowed
+	}
+
+	payment, err := s.paymentRepo.FindActiveByAppointment(appointment.ID)
+	if errors.Is(err, repositories.ErrPaymentNotFound) {
+		return nil, ErrDisputeNotAllowed
+	}
+	if err != nil {
+		return nil, err
+	}
+	if payment.EscrowStatus != models.EscrowStatusHeld {
+		return nil, ErrDisputeNotAllowed
+	}
+	if payment.EscrowReleaseAt != nil && !s.now().Before(*payment.EscrowReleaseAt) {
+		return nil, ErrDisputeWindowClosed
+	}
+
+	dispute := &models.PaymentDispute{
+		AppointmentID: appointment.ID,
+		PaymentID:     payment.ID,
+		OpenedBy:      openedBy,
+		Reason:        reason,
+	}
+	opened := s.auditService.Event(appointment.ID, &openedBy, models.AppointmentEventDisputeOpened, models.EscrowStatusHeld, map[string]interface{}{
+		"payment_id":    payment.ID,
+		"reason":        dispute.Reason,
+		"escrow_status": models.EscrowStatusFrozen,
+	})
+	if err := s.paymentRepo.OpenDispute(dispute, opened); err != nil {
+		if errors.Is(err, repositories.ErrEscrowNotHeld) {
+			return nil, ErrDisputeNotAllowed
+		}
+		return nil, err
+	}
+	return dispute, nil
+}
+
+// ResolveDispute decide uma disputa aberta: refundAmount centavos são devolvidos ao cliente
+// e o restante é repassado à prestadora
+func (s *PaymentService) ResolveDispute(dispute *models.PaymentDispute, refundAmount int64, resolvedBy uint, note string) error {
+	now := s.now()
+	dispute.RefundAmount = refundAmount
+	dispute.ResolvedAt = &now
+	dispute.ResolvedBy = &resolvedBy
+	dispute.ResolutionNote = note
+
+	closed, err := s.paymentRepo.CloseDispute(dispute)
+	if err != nil {
+		return err
+	}
+	if !closed {
+		return ErrDisputeNotOpen
+	}
+
+	payment, err := s.paymentRepo.FindByID(dispute.PaymentID)
+	if err == nil && refundAmount > 0 {
+		_, err = s.Refund(payment, refundAmount, "disputa do atendimento", &resolvedBy)
+	}
+	if err != nil {
+		// A decisão não foi aplicada; a disputa volta a aguardar análise
+		if reopenErr := s.paymentRepo.ReopenDispute(dispute); reopenErr != nil {
+			log.Printf("Erro ao reabrir disputa %d: %v", dispute.ID, reopenErr)
+		}
+		return err
+	}
+
+	// A decisão entra no histórico junto com o repasse que a aplica
+	return s.releaseEscrowNow(payment, &resolvedBy, s.auditService.Event(dispute.AppointmentID, &resolvedBy, models.AppointmentEventDisputeClosed, models.PaymentDisputeStatusOpen, map[string]interface{}{
+		"dispute_id":    dispute.ID,
+		"refund_amount": dispute.RefundAmount,
+		"note":          dispute.ResolutionNote,
+	}))
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/testutil"
+	"gorm.io/gorm"
+)
+
+// paidAppointment cria um agendamento concluído e pago com cartão, com o valor em custódia
+func paidAppointment(t *testing.T, db *gorm.DB, service *PaymentService, completedAt time.Time) (*models.Appointment, *models.Payment) {
+	t.Helper()
+	client := testutil.CreateUser(t, db, models.UserTypeClient)
+	provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+	appointment := testutil.CreateAppointment(t, db, models.Appointment{
+		UserID: client.ID, ProviderID: provider.ID, Price: 100,
+		Date: time.Now().UTC().AddDate(0, 0, 3).Truncate(24 * time.Hour),
+	})
+
+	payment, err := service.CreateIntent(appointment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment, err = service.Confirm(payment, "pm_card_visa"); err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+	if payment.EscrowStatus != models.EscrowStatusHeld {
+		t.Fatalf("custódia %s depois do pagamento; want %s", payment.EscrowStatus, models.EscrowStatusHeld)
+	}
+
+	appointment.Status = models.AppointmentStatusCompleted
+	appointment.CheckedOutAt = &completedAt
+	appointment.CompletedAt = &completedAt
+	if err := db.Save(appointment).Error; err != nil {
+		t.Fatal(err)
+	}
+	return appointment, payment
+}
+
+func TestEscrowReleasedAfterDisputeWindow(t *testing.T) {
+	db := testutil.NewDB(t)
+	service := newPaymentService(db, NewFakePaymentGateway())
+	checkedOutAt := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	appointment, payment := paidAppointment(t, db, service, checkedOutAt)
+
+	if err := service.ScheduleEscrowRelease(appointment); err != nil {
+		t.Fatal(err)
+	}
+
+	release := func(at time.Time) *models.Payment {
+		t.Helper()
+		service.now = func() time.Time { return at }
+		if err := service.ReleaseDueEscrows(); err != nil {
+			t.Fatal(err)
+		}
+		stored, err := service.paymentRepo.FindByID(payment.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stored
+	}
+
+	if stored := release(checkedOutAt.Add(EscrowDisputeWindow - time.Minute)); stored.EscrowStatus != models.EscrowStatusHeld {
+		t.Errorf("custódia %s antes do fim da janela; want %s", stored.EscrowStatus, models.EscrowStatusHeld)
+	}
+	stored := release(checkedOutAt.Add(EscrowDisputeWindow))
+	if stored.EscrowStatus != models.EscrowStatusReleased || stored.ReleasedAmount != 10000 || stored.ReleasedAt == nil {
+		t.Errorf("custódia %s com repasse %d; want %s com 10000", stored.EscrowStatus, stored.ReleasedAmount, models.EscrowStatusReleased)
+	}
+
+	// Depois do repasse não há mais o que contestar
+	if _, err := service.OpenDispute(appointment, appointment.UserID, "serviço incompleto"); !errors.Is(err, ErrDisputeNotAllowed) {
+		t.Errorf("OpenDispute depois do repasse: %v, want %v", err, ErrDisputeNotAllowed)
+	}
+}
+
+func TestEscrowDispute(t *testing.T) {
+	tests := []struct {
+		name         string
+		refund       int64
+		wantStatus   models.EscrowStatus
+		wantReleased int64
+	}{
+		{"improcedente", 0, models.EscrowStatusReleased, 10000},
+		{"reembolso parcial", 4000, models.EscrowStatusReleased, 6000},
+		{"reembolso total", 10000, models.EscrowStatusRefunded, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			service := newPaymentService(db, NewFakePaymentGateway())
+			checkedOutAt := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+			appointment, payment := paidAppointment(t, db, service, checkedOutAt)
+			if err := service.ScheduleEscrowRelease(appointment); err != nil {
+				t.Fatal(err)
+			}
+			service.now = func() time.Time { return checkedOutAt.Add(time.Hour) }
+
+			dispute, err := service.OpenDispute(appointment, appointment.UserID, "serviço incompleto")
+			if err != nil {
+				t.Fatalf("OpenDispute: %v", err)
+			}
+			if _, err := service.OpenDispute(appointment, appointment.UserID, "de novo"); !errors.Is(err, ErrDisputeNotAllowed) {
+				t.Errorf("segunda disputa: %v, want %v", err, ErrDisputeNotAllowed)
+			}
+
+			// O valor congelado não é repassado quando a janela termina
+			service.now = func() time.Time { return checkedOutAt.Add(EscrowDisputeWindow + time.Hour) }
+			if err := service.ReleaseDueEscrows(); err != nil {
+				t.Fatal(err)
+			}
+			frozen, _ := service.paymentRepo.FindByID(payment.ID)
+			if frozen.EscrowStatus != models.EscrowStatusFrozen {
+				t.Fatalf("custódia %s com disputa aberta; want %s", frozen.EscrowStatus, models.EscrowStatusFrozen)
+			}
+
+			admin := testutil.CreateUser(t, db, models.UserTypeAdmin)
+			if err := service.ResolveDispute(dispute, tt.refund, admin.ID, "decisão"); err != nil {
+				t.Fatalf("ResolveDispute: %v", err)
+			}
+			if err := service.ResolveDispute(dispute, tt.refund, admin.ID, "decisão"); !errors.Is(err, ErrDisputeNotOpen) {
+				t.Errorf("ResolveDispute repetido: %v, want %v", err, ErrDisputeNotOpen)
+			}
+
+			stored, _ := service.paymentRepo.FindByID(payment.ID)
+			if stored.EscrowStatus != tt.wantStatus || stored.ReleasedAmount != tt.wantReleased || stored.RefundedAmount != tt.refund {
+				t.Errorf("custódia %s, repasse %d, reembolso %d; want %s, %d, %d",
+					stored.EscrowStatus, stored.ReleasedAmount, stored.RefundedAmount, tt.wantStatus, tt.wantReleased, tt.refund)
+			}
+		})
+	}
+}
+
+// holdIncentives concede crédito ao cliente e leva à custódia o desconto do cupom e o
+// crédito do agendamento, como a reserva faz
+func holdIncentives(t *testing.T, db *gorm.DB, appointment *models.Appointment) {
+	t.Helper()
+	client := &appointment.UserID
+	reference := func(kind models.LedgerTransactionKind) string {
+		return fmt.Sprintf("%s:%d", kind, appointment.ID)
+	}
+	testutil.PostLedger(t, db, models.LedgerTransactionReferral, reference(models.LedgerTransactionReferral),
+		testutil.LedgerEntry{Type: models.LedgerAccountPromotions, Amount: appointment.CreditApplied},
+		testutil.LedgerEntry{Type: models.LedgerAccountClient, OwnerID: client, Amount: -appointment.CreditApplied})
+	testutil.PostLedger(t, db, models.LedgerTransactionPromoSubsidy, reference(models.LedgerTransactionPromoSubsidy),
+		testutil.LedgerEntry{Type: models.LedgerAccountPromotions, Amount: appointment.PromoDiscount},
+		testutil.LedgerEntry{Type: models.LedgerAccountEscrow, Amount: -appointment.PromoDiscount})
+	testutil.PostLedger(t, db, models.LedgerTransactionCreditHold, reference(models.LedgerTransactionCreditHold),
+		testutil.LedgerEntry{Type: models.LedgerAccountClient, OwnerID: client, Amount: appointment.CreditApplied},
+		testutil.LedgerEntry{Type: models.LedgerAccountEscrow, Amount: -appointment.CreditApplied})
+}
+
+func TestEscrowReleaseReturnsRefundedIncentives(t *testing.T) {
+	tests := []struct {
+		name         string
+		refund       int64
+		wantStatus   models.EscrowStatus
+		wantProvider int64
+		wantPromo    int64 // desconto do cupom devolvido à conta de promoções
+		wantCredit   int64 // crédito devolvido à carteira do cliente
+	}{
+		{"sem reembolso", 0, models.EscrowStatusReleased, 10000, 0, 0},
+		{"reembolso de metade", 2500, models.EscrowStatusReleased, 5000, 1000, 1500},
+		{"reembolso total", 5000, models.EscrowStatusRefunded, 0, 2000, 3000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			client := testutil.CreateUser(t, db, models.UserTypeClient)
+			provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+			appointment := testutil.CreateAppointment(t, db, models.Appointment{
+				UserID: client.ID, ProviderID: provider.ID, Price: 100, PromoDiscount: 2000, CreditApplied: 3000,
+				Date: time.Now().UTC().AddDate(0, 0, 3).Truncate(24 * time.Hour),
+			})
+			service := newPaymentService(db, NewFakePaymentGateway())
+			holdIncentives(t, db, appointment)
+
+			payment, err := service.CreateIntent(appointment)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if payment, err = service.Confirm(payment, "pm_card_visa"); err != nil {
+				t.Fatalf("Confirm: %v", err)
+			}
+			if tt.refund > 0 {
+				if _, err := service.Refund(payment, tt.refund, "disputa", nil); err != nil {
+					t.Fatalf("Refund: %v", err)
+				}
+			}
+			if err := service.releaseEscrowNow(payment, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			released, err := service.paymentRepo.FindByID(payment.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if released.EscrowStatus != tt.wantStatus || released.ReleasedAmount != tt.wantProvider {
+				t.Errorf("custódia %s com repasse %d; want %s com %d", released.EscrowStatus, released.ReleasedAmount, tt.wantStatus, tt.wantProvider)
+			}
+
+			// Saldos credores são negativos no razão
+			providerBalance := testutil.LedgerBalance(t, db, models.LedgerAccountProvider, &provider.ID)
+			escrow := testutil.LedgerBalance(t, db, models.LedgerAccountEscrow, nil)
+			promotions := testutil.LedgerBalance(t, db, models.LedgerAccountPromotions, nil)
+			wallet := testutil.LedgerBalance(t, db, models.LedgerAccountClient, &client.ID)
+			if providerBalance != -tt.wantProvider || escrow != 0 {
+				t.Errorf("prestadora %d, custódia %d; want %d, 0", providerBalance, escrow, -tt.wantProvider)
+			}
+			if promotions != 5000-tt.wantPromo || wallet != -tt.wantCredit {
+				t.Errorf("promoções %d, carteira %d; want %d, %d", promotions, wallet, 5000-tt.wantPromo, -tt.wantCredit)
+			}
+		})
+	}
+}
+
+func TestScheduleEscrowReleaseReleasesUnpaidIncentives(t *testing.T) {
+	db := testutil.NewDB(t)
+	client := testutil.CreateUser(t, db, models.UserTypeClient)
+	provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+	if err := repositories.NewCommissionRepository(db).CreateRule(&models.CommissionRule{Name: "geral", Active: true, PercentBps: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	// Cupom e crédito cobrem todo o atendimento, sem pagamento
+	appointment := testutil.CreateAppointment(t, db, models.Appointment{
+		UserID: client.ID, ProviderID: provider.ID, Price: 50, PromoDiscount: 2000, CreditApplied: 3000,
+		Status: models.AppointmentStatusCompleted, Date: time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC),
+	})
+	service := newPaymentService(db, NewFakePaymentGateway())
+	holdIncentives(t, db, appointment)
+
+	// O repasse é lançado uma única vez
+	for range 2 {
+		if err := service.ScheduleEscrowRelease(appointment); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	providerBalance := testutil.LedgerBalance(t, db, models.LedgerAccountProvider, &provider.ID)
+	revenue := testutil.LedgerBalance(t, db, models.LedgerAccountPlatformRevenue, nil)
+	escrow := testutil.LedgerBalance(t, db, models.LedgerAccountEscrow, nil)
+	if providerBalance != -4500 || revenue != -500 || escrow != 0 {
+		t.Errorf("prestadora %d, plataforma %d, custódia %d; want -4500, -500, 0", providerBalance, revenue, escrow)
+	}
+
+	events, err := repositories.NewAppointmentEventRepository(db).GetByAppointment(appointment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Type != models.AppointmentEventEscrowReleased {
+		t.Errorf("eventos %+v; want um repasse", events)
+	}
+}
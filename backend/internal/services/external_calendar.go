@@ -0,0 +1,159 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+)
+
+const (
+	// ExternalBusyHorizon é até quando os compromissos externos são importados
+	ExternalBusyHorizon = 180 * 24 * time.Hour
+	// maxExternalCalendarSize limita o tamanho do arquivo lido de um calendário externo
+	maxExternalCalendarSize = 5 << 20
+	// externalCalendarTimeout limita a duração de cada download
+	externalCalendarTimeout = 30 * time.Second
+)
+
+var (
+	ErrInvalidCalendarURL    = errors.New("URL de calendário inválida")
+	ErrCalendarFetchFailed   = errors.New("não foi possível baixar o calendário")
+	ErrCalendarNotSubscribed = errors.New("calendário enviado como arquivo não pode ser sincronizado")
+)
+
+// HTTPClient é o cliente usado para baixar os calendários externos.
+// *http.Client o satisfaz; sem cliente informado, o serviço só acessa endereços públicos.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// ExternalCalendarService importa compromissos de calendários externos da prestadora,
+// por URL ou arquivo, e os guarda como intervalos ocupados
+type ExternalCalendarService struct {
+	calendarRepo *repositories.ExternalCalendarRepository
+	client       HTTPClient
+	location     *time.Location
+	now          func() time.Time
+}
+
+// NewExternalCalendarService cria o serviço. location é o fuso em que os horários dos
+// agendamentos são expressos, usado para converter os horários com fuso dos calendários.
+func NewExternalCalendarService(
+	calendarRepo *repositories.ExternalCalendarRepository,
+	client HTTPClient,
+	location *time.Location,
+) *ExternalCalendarService {
+	if client == nil {
+		client = newExternalCalendarClient(isPublicAddress)
+	}
+	if location == nil {
+		location = time.UTC
+	}
+	return &ExternalCalendarService{
+		calendarRepo: calendarRepo,
+		client:       client,
+		location:     location,
+		now:          time.Now,
+	}
+}
+
+// NormalizeCalendarURL valida a URL do calendário, aceitando também o esquema webcal
+func NormalizeCalendarURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return "", ErrInvalidCalendarURL
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "webcal", "webcals":
+		u.Scheme = "https"
+	case "http", "https":
+	default:
+		return "", ErrInvalidCalendarURL
+	}
+	return u.String(), nil
+}
+
+// Sync baixa o calendário pela URL e substitui os intervalos ocupados importados.
+// Em caso de falha, o erro fica registrado no calendário e os intervalos anteriores são mantidos.
+func (s *ExternalCalendarService) Sync(calendar *models.ExternalCalendar) error {
+	if calendar.URL == "" {
+		return ErrCalendarNotSubscribed
+	}
+
+	err := s.fetchAndImport(calendar)
+	if err != nil {
+		if setErr := s.calendarRepo.SetError(calendar, err.Error()); setErr != nil {
+			log.Printf("Erro ao registrar falha do calendário externo %d: %v", calendar.ID, setErr)
+		}
+	}
+	return err
+}
+
+// Import lê um arquivo iCalendar enviado pela prestadora e substitui os intervalos ocupados
+func (s *ExternalCalendarService) Import(calendar *models.ExternalCalendar, r io.Reader) error {
+	now := s.now()
+	local := now.In(s.location)
+	from := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, s.location)
+	to := from.Add(ExternalBusyHorizon)
+
+	busy, err := ParseBusyTimes(io.LimitReader(r, maxExternalCalendarSize), s.location, from, to)
+	if err != nil {
+		return err
+	}
+
+	blocks := make([]models.ExternalBusyBlock, 0, len(busy))
+	for _, b := range busy {
+		blocks = append(blocks, models.ExternalBusyBlock{
+			CalendarID: calendar.ID,
+			ProviderID: calendar.ProviderID,
+			StartAt:    b.Start.UTC(),
+			EndAt:      b.End.UTC(),
+		})
+	}
+	return s.calendarRepo.ReplaceBlocks(calendar, blocks, now)
+}
+
+// SyncAll sincroniza todos os calendários com URL. Falhas individuais são registradas
+// no próprio calendário e não interrompem os demais.
+func (s *ExternalCalendarService) SyncAll() error {
+	calendars, err := s.calendarRepo.GetSubscribed()
+	if err != nil {
+		return err
+	}
+
+	for i := range calendars {
+		if err := s.Sync(&calendars[i]); err != nil {
+			log.Printf("Erro ao sincronizar calendário externo %d: %v", calendars[i].ID, err)
+		}
+	}
+	return nil
+}
+
+func (s *ExternalCalendarService) fetchAndImport(calendar *models.ExternalCalendar) error {
+	req, err := http.NewRequest(http.MethodGet, calendar.URL, nil)
+	if err != nil {
+		return ErrInvalidCalendarURL
+	}
+	req.Header.Set("Accept", "text/calendar")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCalendarFetchFailed, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: status %d", ErrCalendarFetchFailed, resp.StatusCode)
+	}
+
+	return s.Import(calendar, resp.Body)
+}
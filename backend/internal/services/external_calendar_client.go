@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxExternalCalendarRedirects limita os redirecionamentos seguidos ao baixar um calendário
+const maxExternalCalendarRedirects = 5
+
+var ErrCalendarAddressNotAllowed = errors.New("o calendário deve estar em um endereço público")
+
+// nonPublicPrefixes são as faixas, além das reconhecidas por netip, que não levam à internet
+// pública: rede compartilhada das operadoras (CGNAT), faixas reservadas e o prefixo NAT64,
+// que encapsula endereços IPv4 arbitrários
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// isPublicAddress indica se o endereço pertence à internet pública. Loopback, redes
+// privadas, link-local (que inclui o serviço de metadados 169.254.169.254) e multicast
+// são recusados.
+func isPublicAddress(addr netip.AddrPort) bool {
+	ip := addr.Addr().Unmap()
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// newExternalCalendarClient cria o cliente que baixa os calendários informados pelas
+// prestadoras. O endereço é verificado depois da resolução do nome, a cada conexão, para
+// que a URL não alcance a rede interna nem por DNS nem por redirecionamento.
+func newExternalCalendarClient(allowed func(netip.AddrPort) bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+
+	transport := &http.Transport{
+		// Um proxy faria a conexão em nosso lugar, sem a verificação do endereço
+		Proxy: nil,
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			host, port, err := net.SplitHostPort(address)
+			if err != nil {
+				return nil, err
+			}
+			ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+			if err != nil {
+				return nil, err
+			}
+			portNumber, err := strconv.ParseUint(port, 10, 16)
+			if err != nil {
+				return nil, err
+			}
+
+			// Recusa o nome inteiro se algum dos endereços não for público
+			targets := make([]netip.AddrPort, 0, len(ips))
+			for _, ip := range ips {
+				target := netip.AddrPortFrom(ip.Unmap(), uint16(portNumber))
+				if !allowed(target) {
+					return nil, fmt.Errorf("%w: %s", ErrCalendarAddressNotAllowed, host)
+				}
+				targets = append(targets, target)
+			}
+
+			var lastErr error
+			for _, target := range targets {
+				conn, err := dialer.DialContext(ctx, network, target.String())
+				if err == nil {
+					return conn, nil
+				}
+				lastErr = err
+			}
+			if lastErr == nil {
+				lastErr = fmt.Errorf("nenhum endereço encontrado para %s", host)
+			}
+			return nil, lastErr
+		},
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: externalCalendarTimeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+	}
+
+	return &http.Client{
+		Timeout:   externalCalendarTimeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxExternalCalendarRedirects {
+				return fmt.Errorf("mais de %d redirecionamentos", maxExternalCalendarRedirects)
+			}
+			if scheme := strings.ToLower(req.URL.Scheme); scheme != "http" && scheme != "https" {
+				return ErrInvalidCalendarURL
+			}
+			return nil
+		},
+	}
+}
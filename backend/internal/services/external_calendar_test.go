@@ -0,0 +1,269 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/testutil"
+)
+
+const busyCalendar = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\nUID:utc\r\nDTSTART:20260310T130000Z\r\nDTEND:20260310T140000Z\r\nEND:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nUID:tzid\r\nDTSTART;TZID=America/New_York:20260311T090000\r\nDTEND;TZID=America/New_York:20260311T100000\r\nEND:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nUID:flutuante\r\nDTSTART:20260312T100000\r\nDTEND:20260312T110000\r\nEND:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nUID:dia-inteiro\r\nDTSTART;VALUE=DATE:20260313\r\nEND:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func saoPaulo(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Skipf("fuso indisponível: %v", err)
+	}
+	return loc
+}
+
+func TestParseBusyTimesReturnsInstants(t *testing.T) {
+	loc := saoPaulo(t)
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	busy, err := ParseBusyTimes(strings.NewReader(busyCalendar), loc, from, from.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatalf("ParseBusyTimes: %v", err)
+	}
+
+	// Todos começam às 10h em São Paulo (UTC-3), exceto o dia inteiro, à meia-noite local
+	want := []time.Time{
+		time.Date(2026, 3, 10, 13, 0, 0, 0, time.UTC),
+		time.Date(2026, 3, 11, 13, 0, 0, 0, time.UTC),
+		time.Date(2026, 3, 12, 13, 0, 0, 0, time.UTC),
+		time.Date(2026, 3, 13, 3, 0, 0, 0, time.UTC),
+	}
+	if len(busy) != len(want) {
+		t.Fatalf("%d intervalos, want %d: %+v", len(busy), len(want), busy)
+	}
+	for i, b := range busy {
+		if !b.Start.Equal(want[i]) {
+			t.Errorf("intervalo %d começa em %s, want %s", i, b.Start.UTC(), want[i])
+		}
+	}
+}
+
+func TestIsPublicAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"8.8.8.8:443", true},
+		{"[2001:4860:4860::8888]:443", true},
+		{"127.0.0.1:80", false},
+		{"10.0.0.5:80", false},
+		{"172.16.0.1:80", false},
+		{"192.168.1.1:80", false},
+		{"169.254.169.254:80", false},
+		{"100.64.0.1:80", false},
+		{"0.0.0.0:80", false},
+		{"[::1]:80", false},
+		{"[fd00::1]:80", false},
+		{"[fe80::1]:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+		{"[64:ff9b::a00:1]:80", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := isPublicAddress(netip.MustParseAddrPort(tt.addr)); got != tt.want {
+				t.Errorf("isPublicAddress(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+// onlyServer libera apenas o servidor de teste informado, como se fosse o único endereço público
+func onlyServer(t *testing.T, server *httptest.Server) func(netip.AddrPort) bool {
+	t.Helper()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := netip.MustParseAddrPort(u.Host)
+	return func(target netip.AddrPort) bool { return target == addr }
+}
+
+func TestExternalCalendarSync(t *testing.T) {
+	loc := saoPaulo(t)
+
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(busyCalendar))
+	}))
+	defer internal.Close()
+
+	var public *httptest.Server
+	public = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/interno":
+			http.Redirect(w, r, internal.URL, http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, public.URL+"/loop", http.StatusFound)
+		default:
+			w.Write([]byte(busyCalendar))
+		}
+	}))
+	defer public.Close()
+
+	tests := []struct {
+		name       string
+		client     HTTPClient
+		url        string
+		wantErr    error
+		wantBlocks int
+	}{
+		{"endereço local recusado pelo cliente padrão", nil, internal.URL, ErrCalendarAddressNotAllowed, 0},
+		{"redirecionamento para a rede interna", newExternalCalendarClient(onlyServer(t, public)), public.URL + "/interno", ErrCalendarAddressNotAllowed, 0},
+		{"redirecionamentos em excesso", newExternalCalendarClient(onlyServer(t, public)), public.URL + "/loop", ErrCalendarFetchFailed, 0},
+		{"calendário público importado", newExternalCalendarClient(onlyServer(t, public)), public.URL + "/agenda.ics", nil, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+			calendarRepo := repositories.NewExternalCalendarRepository(db)
+			calendar := &models.ExternalCalendar{ProviderID: provider.ID, Name: "Pessoal", URL: tt.url}
+			if err := db.Create(calendar).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			service := NewExternalCalendarService(calendarRepo, tt.client, loc)
+			service.now = func() time.Time { return time.Date(2026, 3, 1, 12, 0, 0, 0, loc) }
+
+			err := service.Sync(calendar)
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Sync: %v, want %v", err, tt.wantErr)
+			}
+
+			var blocks []models.ExternalBusyBlock
+			db.Where("calendar_id = ?", calendar.ID).Order("start_at").Find(&blocks)
+			if len(blocks) != tt.wantBlocks {
+				t.Fatalf("%d intervalos importados, want %d", len(blocks), tt.wantBlocks)
+			}
+			if tt.wantBlocks == 0 {
+				return
+			}
+
+			// O compromisso das 10h em São Paulo ocupa o mesmo instante que o agendamento das 10h
+			previous := models.ScheduleLocation
+			models.ScheduleLocation = loc
+			defer func() { models.ScheduleLocation = previous }()
+			appointment := models.Appointment{Date: time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC), Time: "10:00"}
+			if !blocks[0].StartAt.Equal(appointment.StartsAt()) {
+				t.Errorf("intervalo começa em %s, agendamento em %s", blocks[0].StartAt, appointment.StartsAt())
+			}
+		})
+	}
+}
+
+func TestParseBusyTimes(t *testing.T) {
+	const calendar = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" +
+		"BEGIN:VEVENT\r\nUID:reuniao\r\nDTSTART:20260310T130000Z\r\nDTEND:20260310T140000Z\r\n" +
+		"BEGIN:VALARM\r\nTRIGGER:-PT15M\r\nEND:VALARM\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:livre\r\nDTSTART:20260311T130000Z\r\nDTEND:20260311T140000Z\r\nTRANSP:TRANSPARENT\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:cancelado\r\nDTSTART:20260312T130000Z\r\nDTEND:20260312T140000Z\r\nSTATUS:CANCELLED\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:semanal\r\nDTSTART:20260302T090000\r\nDURATION:PT30M\r\n" +
+		"RRULE:FREQ=WEEKLY;COUNT=3;WKST=SU\r\nEXDATE:20260309T090000\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:dia-inteiro\r\nDTSTART;VALUE=DATE:20260320\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:fora-do-periodo\r\nDTSTART:20260415T130000Z\r\nDTEND:20260415T140000Z\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	busy, err := ParseBusyTimes(strings.NewReader(calendar), time.UTC, from, from.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatalf("ParseBusyTimes: %v", err)
+	}
+
+	at := func(day, hour, minute int) time.Time { return time.Date(2026, 3, day, hour, minute, 0, 0, time.UTC) }
+	want := []BusyTime{
+		{at(10, 13, 0), at(10, 14, 0)},
+		{at(2, 9, 0), at(2, 9, 30)},
+		{at(16, 9, 0), at(16, 9, 30)},
+		{at(20, 0, 0), at(21, 0, 0)},
+	}
+	if len(busy) != len(want) {
+		t.Fatalf("%d intervalos, want %d: %+v", len(busy), len(want), busy)
+	}
+	for i := range want {
+		if !busy[i].Start.Equal(want[i].Start) || !busy[i].End.Equal(want[i].End) {
+			t.Errorf("intervalo %d = %s a %s, want %s a %s", i, busy[i].Start, busy[i].End, want[i].Start, want[i].End)
+		}
+	}
+
+	if _, err := ParseBusyTimes(strings.NewReader("BEGIN:VEVENT\r\nEND:VEVENT\r\n"), time.UTC, from, from.AddDate(0, 1, 0)); !errors.Is(err, ErrInvalidICalendar) {
+		t.Errorf("arquivo sem VCALENDAR: err = %v, want ErrInvalidICalendar", err)
+	}
+}
+
+func TestNormalizeCalendarURL(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+		err  error
+	}{
+		{"webcal://calendar.example.com/agenda.ics", "https://calendar.example.com/agenda.ics", nil},
+		{" https://calendar.example.com/agenda.ics ", "https://calendar.example.com/agenda.ics", nil},
+		{"ftp://calendar.example.com/agenda.ics", "", ErrInvalidCalendarURL},
+		{"agenda.ics", "", ErrInvalidCalendarURL},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeCalendarURL(tt.raw)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("NormalizeCalendarURL(%q) = %q, %v; want %q, %v", tt.raw, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestExternalCalendarImportReplacesBlocks(t *testing.T) {
+	db := testutil.NewDB(t)
+	provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+	calendarRepo := repositories.NewExternalCalendarRepository(db)
+	calendar := &models.ExternalCalendar{ProviderID: provider.ID, Name: "Pessoal"}
+	if err := calendarRepo.Create(calendar); err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewExternalCalendarService(calendarRepo, nil, time.UTC)
+	service.now = func() time.Time { return time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC) }
+	event := func(day string) string {
+		return "BEGIN:VEVENT\r\nDTSTART:" + day + "T130000Z\r\nDTEND:" + day + "T140000Z\r\nEND:VEVENT\r\n"
+	}
+
+	for _, tt := range []struct {
+		body string
+		want int
+	}{
+		{"BEGIN:VCALENDAR\r\n" + event("20260310") + event("20260311") + "END:VCALENDAR\r\n", 2},
+		{"BEGIN:VCALENDAR\r\n" + event("20260312") + "END:VCALENDAR\r\n", 1},
+	} {
+		if err := service.Import(calendar, strings.NewReader(tt.body)); err != nil {
+			t.Fatalf("Import: %v", err)
+		}
+		blocks, err := calendarRepo.GetBusyBlocks(provider.ID, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(blocks) != tt.want {
+			t.Fatalf("%d intervalos após a importação, want %d", len(blocks), tt.want)
+		}
+	}
+
+	var stored models.ExternalCalendar
+	db.First(&stored, calendar.ID)
+	if stored.LastSyncedAt == nil || stored.LastError != "" {
+		t.Errorf("calendário %+v; want sincronizado sem erro", stored)
+	}
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// fakeIntent guarda o estado de uma intenção no FakePaymentGateway
+type fakeIntent struct {
+	intent   GatewayIntent
+	refunded int64
+}
+
+// FakePaymentGateway é um gateway em memória para desenvolvimento e testes.
+// Métodos de pagamento com "declined" no ID (como pm_card_chargeDeclined) são recusados;
+// os demais são aprovados. A bandeira é lida do ID (pm_card_mastercard), com visa como padrão.
+type FakePaymentGateway struct {
+	mu      sync.Mutex
+	intents map[string]*fakeIntent
+	methods map[string]string // ID do método -> cliente
+}
+
+func NewFakePaymentGateway() *FakePaymentGateway {
+	return &FakePaymentGateway{
+		intents: map[string]*fakeIntent{},
+		methods: map[string]string{},
+	}
+}
+
+func (g *FakePaymentGateway) CreateIntent(req GatewayIntentRequest) (*GatewayIntent, error) {
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("valor inválido: %d", req.Amount)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	id := "pi_fake_" + fakeGatewayID()
+	intent := GatewayIntent{
+		ID:           id,
+		ClientSecret: id + "_secret_" + fakeGatewayID(),
+		Amount:       req.Amount,
+		Currency:     req.Currency,
+		Status:       GatewayIntentRequiresConfirmation,
+	}
+	g.intents[id] = &fakeIntent{intent: intent}
+	return &intent, nil
+}
+
+func (g *FakePaymentGateway) ConfirmIntent(intentID, paymentMethodID string) (*GatewayIntent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	stored, ok := g.intents[intentID]
+	if !ok {
+		return nil, ErrGatewayIntentNotFound
+	}
+
+	switch stored.intent.Status {
+	case GatewayIntentRequiresConfirmation, GatewayIntentFailed:
+		if strings.Contains(strings.ToLower(paymentMethodID), "declined") {
+			stored.intent.Status = GatewayIntentFailed
+			stored.intent.FailureReason = "cartão recusado"
+		} else {
+			stored.intent.Status = GatewayIntentSucceeded
+			stored.intent.FailureReason = ""
+		}
+	}
+
+	intent := stored.intent
+	return &intent, nil
+}
+
+func (g *FakePaymentGateway) GetIntent(intentID string) (*GatewayIntent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	stored, ok := g.intents[intentID]
+	if !ok {
+		return nil, ErrGatewayIntentNotFound
+	}
+	intent := stored.intent
+	return &intent, nil
+}
+
+func (g *FakePaymentGateway) CancelIntent(intentID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	stored, ok := g.intents[intentID]
+	if !ok {
+		return ErrGatewayIntentNotFound
+	}
+	if stored.intent.Status == GatewayIntentSucceeded {
+		return fmt.Errorf("intenção %s já foi paga", intentID)
+	}
+	stored.intent.Status = GatewayIntentCanceled
+	return nil
+}
+
+func (g *FakePaymentGateway) Refund(intentID string, amount int64, reason string) (*GatewayRefund, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	stored, ok := g.intents[intentID]
+	if !ok {
+		return nil, ErrGatewayIntentNotFound
+	}
+	if stored.intent.Status != GatewayIntentSucceeded || amount <= 0 || stored.refunded+amount > stored.intent.Amount {
+		return nil, ErrGatewayInvalidRefund
+	}
+
+	stored.refunded += amount
+	return &GatewayRefund{ID: "re_fake_" + fakeGatewayID(), Amount: amount}, nil
+}
+
+func (g *FakePaymentGateway) AttachPaymentMethod(customerRef, paymentMethodID string) (*GatewayPaymentMethod, error) {
+	if !strings.HasPrefix(paymentMethodID, "pm_") {
+		return nil, ErrGatewayMethodNotFound
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if owner, ok := g.methods[paymentMethodID]; ok && owner != customerRef {
+		return nil, ErrGatewayMethodInUse
+	}
+	g.methods[paymentMethodID] = customerRef
+
+	brand := "visa"
+	for _, b := range []string{"mastercard", "amex", "elo", "hipercard"} {
+		if strings.Contains(paymentMethodID, b) {
+			brand = b
+		}
+	}
+	return &GatewayPaymentMethod{
+		ID:       paymentMethodID,
+		Brand:    brand,
+		Last4:    "4242",
+		ExpMonth: 12,
+		ExpYear:  2034,
+	}, nil
+}
+
+func (g *FakePaymentGateway) DetachPaymentMethod(paymentMethodID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.methods[paymentMethodID]; !ok {
+		return ErrGatewayMethodNotFound
+	}
+	delete(g.methods, paymentMethodID)
+	return nil
+}
+
+func fakeGatewayID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFakePaymentGateway(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		wantStatus GatewayIntentStatus
+		refund     int64
+		wantRefund error
+	}{
+		{"cartão aprovado", "pm_card_visa", GatewayIntentSucceeded, 4000, nil},
+		{"reembolso acima do pago", "pm_card_visa", GatewayIntentSucceeded, 10001, ErrGatewayInvalidRefund},
+		{"cartão recusado", "pm_card_chargeDeclined", GatewayIntentFailed, 4000, ErrGatewayInvalidRefund},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gateway := NewFakePaymentGateway()
+			intent, err := gateway.CreateIntent(GatewayIntentRequest{Amount: 10000, Currency: "brl"})
+			if err != nil || intent.Status != GatewayIntentRequiresConfirmation || intent.ClientSecret == "" {
+				t.Fatalf("CreateIntent = %+v, %v", intent, err)
+			}
+
+			confirmed, err := gateway.ConfirmIntent(intent.ID, tt.method)
+			if err != nil || confirmed.Status != tt.wantStatus {
+				t.Fatalf("ConfirmIntent = %+v, %v; want %s", confirmed, err, tt.wantStatus)
+			}
+			if tt.wantStatus == GatewayIntentFailed && confirmed.FailureReason == "" {
+				t.Error("recusa sem motivo")
+			}
+
+			refund, err := gateway.Refund(intent.ID, tt.refund, "teste")
+			if !errors.Is(err, tt.wantRefund) {
+				t.Fatalf("Refund: %v, want %v", err, tt.wantRefund)
+			}
+			if err == nil && refund.Amount != tt.refund {
+				t.Errorf("reembolso de %d, want %d", refund.Amount, tt.refund)
+			}
+		})
+	}
+}
+
+func TestFakePaymentGatewayRejectsIntentErrors(t *testing.T) {
+	gateway := NewFakePaymentGateway()
+	if _, err := gateway.CreateIntent(GatewayIntentRequest{Amount: 0}); err == nil {
+		t.Error("intenção sem valor aceita")
+	}
+	if _, err := gateway.ConfirmIntent("pi_desconhecida", "pm_card_visa"); !errors.Is(err, ErrGatewayIntentNotFound) {
+		t.Errorf("ConfirmIntent: %v, want %v", err, ErrGatewayIntentNotFound)
+	}
+
+	intent, _ := gateway.CreateIntent(GatewayIntentRequest{Amount: 5000})
+	if _, err := gateway.ConfirmIntent(intent.ID, "pm_card_visa"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gateway.CancelIntent(intent.ID); err == nil {
+		t.Error("intenção paga cancelada")
+	}
+}
+
+func TestFakePaymentGatewayMethodOwnership(t *testing.T) {
+	gateway := NewFakePaymentGateway()
+	if _, err := gateway.AttachPaymentMethod("user_1", "pm_card_mastercard"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.AttachPaymentMethod("user_1", "pm_card_mastercard"); err != nil {
+		t.Errorf("novo vínculo ao mesmo cliente: %v", err)
+	}
+	if _, err := gateway.AttachPaymentMethod("user_2", "pm_card_mastercard"); !errors.Is(err, ErrGatewayMethodInUse) {
+		t.Errorf("vínculo a outro cliente: %v, want %v", err, ErrGatewayMethodInUse)
+	}
+	if _, err := gateway.AttachPaymentMethod("user_1", "card_sem_prefixo"); !errors.Is(err, ErrGatewayMethodNotFound) {
+		t.Errorf("método inválido: %v, want %v", err, ErrGatewayMethodNotFound)
+	}
+}
@@ -0,0 +1,20 @@
+package services
+
+import (
+	"math"
+)
+
+// earthRadiusKm é o raio médio da Terra em quilômetros
+const earthRadiusKm = 6371.0
+
+// HaversineKm calcula a distância em linha reta entre duas coordenadas, em quilômetros
+func HaversineKm(lat1, lng1, lat2, lng2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLng := toRad(lng2 - lng1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+)
+
+const (
+	icalProductID = "-//xclean//Agendamentos//PT"
+	// icalLineLimit é o tamanho máximo de uma linha em octetos antes da quebra (RFC 5545, 3.1)
+	icalLineLimit = 75
+	// icalLocalTime é o formato de horário flutuante: os agendamentos guardam dia e hora
+	// locais, sem fuso, e o calendário do usuário os exibe no fuso em que ele estiver
+	icalLocalTime = "20060102T150405"
+	icalUTCTime   = "20060102T150405Z"
+)
+
+// ICalEvent é um evento de calendário gerado a partir de um agendamento
+type ICalEvent struct {
+	UID          string
+	Start        time.Time
+	End          time.Time
+	Stamp        time.Time
+	Summary      string
+	Description  string
+	Location     string
+	Cancelled    bool
+	Confirmed    bool
+	LastModified time.Time
+}
+
+// EncodeICalendar gera um VCALENDAR com os eventos informados
+func EncodeICalendar(name string, events []ICalEvent) []byte {
+	var b strings.Builder
+	writeICalLine(&b, "BEGIN:VCALENDAR")
+	writeICalLine(&b, "VERSION:2.0")
+	writeICalLine(&b, "PRODID:"+icalProductID)
+	writeICalLine(&b, "CALSCALE:GREGORIAN")
+	writeICalLine(&b, "METHOD:PUBLISH")
+	if name != "" {
+		writeICalLine(&b, "X-WR-CALNAME:"+escapeICalText(name))
+	}
+
+	for _, e := range events {
+		writeICalLine(&b, "BEGIN:VEVENT")
+		writeICalLine(&b, "UID:"+e.UID)
+		writeICalLine(&b, "DTSTAMP:"+e.Stamp.UTC().Format(icalUTCTime))
+		writeICalLine(&b, "DTSTART:"+e.Start.Format(icalLocalTime))
+		writeICalLine(&b, "DTEND:"+e.End.Format(icalLocalTime))
+		writeICalLine(&b, "SUMMARY:"+escapeICalText(e.Summary))
+		if e.Description != "" {
+			writeICalLine(&b, "DESCRIPTION:"+escapeICalText(e.Description))
+		}
+		if e.Location != "" {
+			writeICalLine(&b, "LOCATION:"+escapeICalText(e.Location))
+		}
+		switch {
+		case e.Cancelled:
+			writeICalLine(&b, "STATUS:CANCELLED")
+		case e.Confirmed:
+			writeICalLine(&b, "STATUS:CONFIRMED")
+		default:
+			writeICalLine(&b, "STATUS:TENTATIVE")
+		}
+		if !e.LastModified.IsZero() {
+			writeICalLine(&b, "LAST-MODIFIED:"+e.LastModified.UTC().Format(icalUTCTime))
+		}
+		writeICalLine(&b, "END:VEVENT")
+	}
+
+	writeICalLine(&b, "END:VCALENDAR")
+	return []byte(b.String())
+}
+
+// AppointmentICalEvent converte um agendamento em evento de calendário do ponto de vista de viewerID.
+// Sem includeDetails, o evento traz apenas o serviço e o horário.
+func AppointmentICalEvent(appointment *models.Appointment, viewerID uint, includeDetails bool, stamp time.Time) ICalEvent {
+	event := ICalEvent{
+		UID:          fmt.Sprintf("appointment-%d@xclean", appointment.ID),
+		Start:        appointment.StartsAt(),
+		End:          appointment.EndsAt(),
+		Stamp:        stamp,
+		Summary:      appointment.Service,
+		Cancelled:    appointment.Status == models.AppointmentStatusCancelled,
+		Confirmed:    appointment.Status != models.AppointmentStatusPending,
+		LastModified: appointment.UpdatedAt,
+	}
+
+	if includeDetails {
+		counterpart := appointment.Provider
+		if viewerID == appointment.ProviderID {
+			counterpart = appointment.User
+		}
+		if counterpart.Name != "" {
+			event.Summary = fmt.Sprintf("%s - %s", appointment.Service, counterpart.Name)
+		}
+		event.Location = appointment.Location
+		event.Description = appointment.Notes
+	}
+
+	return event
+}
+
+// escapeICalText escapa os caracteres especiais de valores TEXT (RFC 5545, 3.3.11)
+func escapeICalText(value string) string {
+	value = strings.ReplaceAll(value, "\\", "\\\\")
+	value = strings.ReplaceAll(value, ";", "\\;")
+	value = strings.ReplaceAll(value, ",", "\\,")
+	value = strings.ReplaceAll(value, "\r\n", "\\n")
+	value = strings.ReplaceAll(value, "\n", "\\n")
+	return strings.ReplaceAll(value, "\r", "")
+}
+
+// writeICalLine escreve uma linha terminada em CRLF, quebrando-a em linhas de
+// continuação sem dividir caracteres UTF-8 no meio
+func writeICalLine(b *strings.Builder, line string) {
+	limit := icalLineLimit
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// A continuação começa com um espaço, que conta no limite
+		limit = icalLineLimit - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+func isRuneStart(c byte) bool {
+	return c&0xC0 != 0x80
+}
@@ -0,0 +1,340 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidICalendar = errors.New("arquivo iCalendar inválido")
+)
+
+// maxBusyTimes limita os intervalos importados de um único calendário externo
+const maxBusyTimes = 2000
+
+// BusyTime é um intervalo ocupado lido de um calendário externo
+type BusyTime struct {
+	Start time.Time
+	End   time.Time
+}
+
+// icalProperty é uma linha de conteúdo do iCalendar já desdobrada
+type icalProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// icalBusyEvent reúne as propriedades de um VEVENT relevantes para a ocupação
+type icalBusyEvent struct {
+	start    time.Time
+	end      time.Time
+	duration time.Duration
+	allDay   bool
+	hasEnd   bool
+	rrule    string
+	exdates  []time.Time
+	skip     bool
+}
+
+// ParseBusyTimes lê os eventos de um calendário iCalendar e retorna os intervalos ocupados
+// que se sobrepõem a [from, to). Eventos cancelados ou marcados como livres (TRANSP:TRANSPARENT)
+// são ignorados.
+//
+// Os horários retornados são instantes reais, comparáveis com o início dos agendamentos.
+// Horários flutuantes, sem fuso, e eventos de dia inteiro são interpretados no fuso loc.
+func ParseBusyTimes(r io.Reader, loc *time.Location, from, to time.Time) ([]BusyTime, error) {
+	properties, err := readICalProperties(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		busy     []BusyTime
+		event    *icalBusyEvent
+		depth    int // componentes aninhados dentro do VEVENT, como VALARM
+		calendar bool
+	)
+	for _, p := range properties {
+		switch {
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VCALENDAR"):
+			calendar = true
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VEVENT") && event == nil:
+			event = &icalBusyEvent{}
+		case p.name == "BEGIN" && event != nil:
+			depth++
+		case p.name == "END" && event != nil && depth > 0:
+			depth--
+		case p.name == "END" && strings.EqualFold(p.value, "VEVENT") && event != nil:
+			if !event.skip && !event.start.IsZero() {
+				busy = append(busy, event.expand(from, to)...)
+				if len(busy) > maxBusyTimes {
+					return nil, fmt.Errorf("%w: mais de %d horários ocupados", ErrInvalidICalendar, maxBusyTimes)
+				}
+			}
+			event = nil
+		case event != nil && depth == 0:
+			if err := event.apply(p, loc); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if !calendar {
+		return nil, fmt.Errorf("%w: VCALENDAR não encontrado", ErrInvalidICalendar)
+	}
+	return busy, nil
+}
+
+// apply registra uma propriedade do VEVENT
+func (e *icalBusyEvent) apply(p icalProperty, loc *time.Location) error {
+	switch p.name {
+	case "DTSTART":
+		start, allDay, err := parseICalTime(p, loc)
+		if err != nil {
+			return err
+		}
+		e.start, e.allDay = start, allDay
+	case "DTEND":
+		end, _, err := parseICalTime(p, loc)
+		if err != nil {
+			return err
+		}
+		e.end, e.hasEnd = end, true
+	case "DURATION":
+		d, err := parseICalDuration(p.value)
+		if err != nil {
+			return err
+		}
+		e.duration = d
+	case "STATUS":
+		if strings.EqualFold(p.value, "CANCELLED") {
+			e.skip = true
+		}
+	case "TRANSP":
+		if strings.EqualFold(p.value, "TRANSPARENT") {
+			e.skip = true
+		}
+	case "RRULE":
+		e.rrule = p.value
+	case "EXDATE":
+		for _, value := range strings.Split(p.value, ",") {
+			exdate, _, err := parseICalTime(icalProperty{name: p.name, params: p.params, value: value}, loc)
+			if err != nil {
+				return err
+			}
+			e.exdates = append(e.exdates, exdate)
+		}
+	}
+	return nil
+}
+
+// length retorna a duração do evento, com um dia para eventos de dia inteiro sem fim
+func (e *icalBusyEvent) length() time.Duration {
+	switch {
+	case e.hasEnd && e.end.After(e.start):
+		return e.end.Sub(e.start)
+	case e.duration > 0:
+		return e.duration
+	case e.allDay:
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+// expand retorna as ocorrências do evento que se sobrepõem a [from, to).
+// Regras de recorrência fora do subconjunto suportado geram apenas a primeira ocorrência;
+// ocorrências alteradas (RECURRENCE-ID) chegam como eventos próprios e também bloqueiam a agenda.
+func (e *icalBusyEvent) expand(from, to time.Time) []BusyTime {
+	length := e.length()
+	if length <= 0 {
+		return nil
+	}
+
+	starts := []time.Time{e.start}
+	if e.rrule != "" {
+		if rule, err := ParseRRule(stripWeekStart(e.rrule)); err == nil {
+			starts = starts[:0]
+			for _, day := range rule.Occurrences(e.recurrenceStart(rule, from), to) {
+				starts = append(starts, time.Date(day.Year(), day.Month(), day.Day(),
+					e.start.Hour(), e.start.Minute(), e.start.Second(), 0, e.start.Location()))
+			}
+		}
+	}
+
+	var busy []BusyTime
+	for _, start := range starts {
+		end := start.Add(length)
+		if !start.Before(to) || !end.After(from) || e.excluded(start) {
+			continue
+		}
+		busy = append(busy, BusyTime{Start: start, End: end})
+	}
+	return busy
+}
+
+// recurrenceStart avança o início da série em períodos inteiros até perto de from, para que
+// séries antigas não esgotem o limite de ocorrências antes do período importado.
+// Com COUNT, a contagem depende da primeira ocorrência e o início é mantido.
+func (e *icalBusyEvent) recurrenceStart(rule *RRule, from time.Time) time.Time {
+	if rule.Count > 0 {
+		return e.start
+	}
+
+	periodDays := rule.Interval
+	if rule.Freq == FrequencyWeekly {
+		periodDays *= 7
+	}
+	period := time.Duration(periodDays) * 24 * time.Hour
+
+	// Mantém um período de folga para ocorrências que começam antes de from e ainda estão em andamento
+	skip := int(from.Sub(e.start)/period) - 1
+	if skip <= 0 {
+		return e.start
+	}
+	return e.start.AddDate(0, 0, skip*periodDays)
+}
+
+func (e *icalBusyEvent) excluded(start time.Time) bool {
+	for _, exdate := range e.exdates {
+		if exdate.Equal(start) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripWeekStart remove o WKST, que não é suportado por ParseRRule (que assume segunda-feira)
+func stripWeekStart(rule string) string {
+	parts := strings.Split(rule, ";")
+	kept := parts[:0]
+	for _, part := range parts {
+		if !strings.HasPrefix(strings.ToUpper(part), "WKST=") {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, ";")
+}
+
+// readICalProperties lê as linhas de conteúdo, desfazendo as quebras de linha (RFC 5545, 3.1)
+func readICalProperties(r io.Reader) ([]icalProperty, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidICalendar, err)
+	}
+
+	properties := make([]icalProperty, 0, len(lines))
+	for _, line := range lines {
+		p, ok := parseICalProperty(line)
+		if !ok {
+			return nil, fmt.Errorf("%w: linha %q", ErrInvalidICalendar, line)
+		}
+		properties = append(properties, p)
+	}
+	return properties, nil
+}
+
+// parseICalProperty separa nome, parâmetros e valor de uma linha como
+// DTSTART;TZID="America/Sao_Paulo":20240501T090000
+func parseICalProperty(line string) (icalProperty, bool) {
+	colon := -1
+	quoted := false
+	for i, c := range line {
+		if c == '"' {
+			quoted = !quoted
+		}
+		if c == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon <= 0 {
+		return icalProperty{}, false
+	}
+
+	head := strings.Split(line[:colon], ";")
+	p := icalProperty{
+		name:   strings.ToUpper(head[0]),
+		params: map[string]string{},
+		value:  line[colon+1:],
+	}
+	for _, param := range head[1:] {
+		key, val, _ := strings.Cut(param, "=")
+		p.params[strings.ToUpper(key)] = strings.Trim(val, `"`)
+	}
+	return p, true
+}
+
+// parseICalTime interpreta DATE ou DATE-TIME e indica se o valor é um dia inteiro
+func parseICalTime(p icalProperty, loc *time.Location) (time.Time, bool, error) {
+	value := strings.TrimSpace(p.value)
+
+	if p.params["VALUE"] == "DATE" || len(value) == 8 {
+		day, err := time.ParseInLocation("20060102", value, loc)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("%w: data %q em %s", ErrInvalidICalendar, value, p.name)
+		}
+		return day, true, nil
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("%w: horário %q em %s", ErrInvalidICalendar, value, p.name)
+		}
+		return t.In(loc), false, nil
+	}
+
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%w: horário %q em %s", ErrInvalidICalendar, value, p.name)
+	}
+	if tzid := p.params["TZID"]; tzid != "" {
+		if zone, err := time.LoadLocation(tzid); err == nil {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, zone)
+			return t, false, nil
+		}
+		// Fusos desconhecidos (nomes do Windows, por exemplo) são tratados como horário flutuante
+	}
+	return t, false, nil
+}
+
+var icalDurationPattern = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseICalDuration interpreta durações como PT1H30M ou P1D (RFC 5545, 3.3.6)
+func parseICalDuration(value string) (time.Duration, error) {
+	m := icalDurationPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil || m[1] == "-" {
+		return 0, fmt.Errorf("%w: duração %q", ErrInvalidICalendar, value)
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+2] == "" {
+			continue
+		}
+		n, _ := strconv.Atoi(m[i+2])
+		d += time.Duration(n) * unit
+	}
+	return d, nil
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+)
+
+// InstantBookingService decide se um agendamento pode ser confirmado sem aprovação da prestadora
+type InstantBookingService struct {
+	appointmentRepo *repositories.AppointmentRepository
+}
+
+func NewInstantBookingService(appointmentRepo *repositories.AppointmentRepository) *InstantBookingService {
+	return &InstantBookingService{
+		appointmentRepo: appointmentRepo,
+	}
+}
+
+// Qualifies indica se o cliente atende a todos os critérios de reserva instantânea da prestadora.
+// O limite diário é aplicado na criação do agendamento, junto com a verificação de conflitos.
+func (s *InstantBookingService) Qualifies(profile *models.ProviderProfile, client *models.User) (bool, error) {
+	if profile == nil || !profile.InstantBookingEnabled {
+		return false, nil
+	}
+
+	if profile.InstantBookingVerifiedOnly && !client.IsVerified {
+		return false, nil
+	}
+
+	if profile.InstantBookingMinRating > 0 && (client.RatingCount == 0 || client.Rating < profile.InstantBookingMinRating) {
+		return false, nil
+	}
+
+	if profile.InstantBookingPastClientsOnly {
+		return s.appointmentRepo.HasCompletedAppointment(client.ID, profile.UserID)
+	}
+
+	return true, nil
+}
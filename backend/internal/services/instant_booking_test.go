@@ -0,0 +1,60 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/testutil"
+)
+
+func TestInstantBookingQualifies(t *testing.T) {
+	tests := []struct {
+		name     string
+		profile  models.ProviderProfile
+		verified bool
+		rating   float64
+		ratings  int
+		past     bool // O cliente já teve um atendimento concluído com a prestadora
+		want     bool
+	}{
+		{"desabilitada", models.ProviderProfile{}, true, 5, 3, true, false},
+		{"sem critérios", models.ProviderProfile{InstantBookingEnabled: true}, false, 0, 0, false, true},
+		{"exige verificação", models.ProviderProfile{InstantBookingEnabled: true, InstantBookingVerifiedOnly: true}, false, 5, 3, true, false},
+		{"verificado", models.ProviderProfile{InstantBookingEnabled: true, InstantBookingVerifiedOnly: true}, true, 0, 0, false, true},
+		{"avaliação abaixo do mínimo", models.ProviderProfile{InstantBookingEnabled: true, InstantBookingMinRating: 4.5}, false, 4.2, 3, false, false},
+		{"sem avaliações", models.ProviderProfile{InstantBookingEnabled: true, InstantBookingMinRating: 4.5}, false, 0, 0, false, false},
+		{"avaliação no mínimo", models.ProviderProfile{InstantBookingEnabled: true, InstantBookingMinRating: 4.5}, false, 4.5, 2, false, true},
+		{"cliente novo", models.ProviderProfile{InstantBookingEnabled: true, InstantBookingPastClientsOnly: true}, true, 5, 3, false, false},
+		{"cliente antigo", models.ProviderProfile{InstantBookingEnabled: true, InstantBookingPastClientsOnly: true}, false, 0, 0, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			client := testutil.CreateUser(t, db, models.UserTypeClient)
+			client.IsVerified, client.Rating, client.RatingCount = tt.verified, tt.rating, tt.ratings
+			provider := testutil.CreateProvider(t, db, tt.profile)
+			if tt.past {
+				testutil.CreateAppointment(t, db, models.Appointment{
+					UserID: client.ID, ProviderID: provider.ID, Status: models.AppointmentStatusCompleted,
+					Date: time.Date(2026, 2, 10, 9, 0, 0, 0, time.UTC),
+				})
+			}
+			// Um atendimento cancelado não conta como histórico com a prestadora
+			testutil.CreateAppointment(t, db, models.Appointment{
+				UserID: client.ID, ProviderID: provider.ID, Status: models.AppointmentStatusCancelled,
+				Date: time.Date(2026, 2, 17, 9, 0, 0, 0, time.UTC),
+			})
+
+			tt.profile.UserID = provider.ID
+			got, err := NewInstantBookingService(repositories.NewAppointmentRepository(db, nil)).Qualifies(&tt.profile, client)
+			if err != nil {
+				t.Fatalf("Qualifies: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Qualifies = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
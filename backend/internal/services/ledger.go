@@ -0,0 +1,534 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+)
+
+// Situações de um pagamento no relatório de conciliação
+const (
+	ReconciliationMissingInLedger = "missing_in_ledger" // Movimento no gateway sem lançamento no razão
+	ReconciliationAmountMismatch  = "amount_mismatch"   // Valores diferentes entre gateway e razão
+)
+
+// LedgerService lança no razão de partidas dobradas os movimentos de dinheiro da plataforma.
+//
+// Um pagamento capturado entra pela conta de compensação do método de pagamento na conta do
+// cliente e dela vai para a custódia. Reembolsos fazem o caminho inverso; o repasse move o
+// valor da custódia para a conta da prestadora, de onde saem os saques, e a comissão para
+// a receita da plataforma. Gorjetas vão direto do cliente para a prestadora. Descontos de
+// cupom saem da conta de promoções e créditos da carteira saem da conta do cliente; os dois
+// entram na custódia na reserva e são repassados junto com o valor pago.
+type LedgerService struct {
+	ledgerRepo  *repositories.LedgerRepository
+	paymentRepo *repositories.PaymentRepository
+	tipRepo     *repositories.TipRepository
+}
+
+func NewLedgerService(ledgerRepo *repositories.LedgerRepository, paymentRepo *repositories.PaymentRepository, tipRepo *repositories.TipRepository) *LedgerService {
+	return &LedgerService{
+		ledgerRepo:  ledgerRepo,
+		paymentRepo: paymentRepo,
+		tipRepo:     tipRepo,
+	}
+}
+
+// ledgerEntry é uma partida a ser lançada, antes de a conta ser resolvida
+type ledgerEntry struct {
+	accountType models.LedgerAccountType
+	ownerID     *uint
+	qualifier   string
+	amount      int64
+}
+
+// ledgerMovement é um lançamento a ser gravado, antes de as contas serem resolvidas
+type ledgerMovement struct {
+	kind        models.LedgerTransactionKind
+	reference   string
+	description string
+	entries     []ledgerEntry
+}
+
+// PaymentTransactions monta os lançamentos do valor recebido do cliente e de sua entrada
+// na custódia, gravados junto com a liquidação do pagamento
+func (s *LedgerService) PaymentTransactions(payment *models.Payment) ([]*models.LedgerTransaction, error) {
+	return s.paymentTransactions(payment,
+		ledgerMovement{
+			kind:        models.LedgerTransactionPayment,
+			reference:   fmt.Sprintf("payment:%d", payment.ID),
+			description: fmt.Sprintf("Pagamento do agendamento #%d", payment.AppointmentID),
+			entries:     []ledgerEntry{clearingEntry(payment, payment.Amount), clientEntry(payment, -payment.Amount)},
+		},
+		ledgerMovement{
+			kind:        models.LedgerTransactionEscrowHold,
+			reference:   fmt.Sprintf("escrow_hold:%d", payment.ID),
+			description: fmt.Sprintf("Custódia do agendamento #%d", payment.AppointmentID),
+			entries:     []ledgerEntry{clientEntry(payment, payment.Amount), escrowEntry(-payment.Amount)},
+		},
+	)
+}
+
+// RefundTransactions monta os lançamentos da saída do valor da custódia e de seu estorno
+// ao cliente pelo gateway, gravados junto com a conclusão do reembolso
+func (s *LedgerService) RefundTransactions(payment *models.Payment, refund *models.Refund) ([]*models.LedgerTransaction, error) {
+	return s.paymentTransactions(payment,
+		ledgerMovement{
+			kind:        models.LedgerTransactionEscrowReturn,
+			reference:   fmt.Sprintf("escrow_return:%d", refund.ID),
+			description: fmt.Sprintf("Devolução da custódia do agendamento #%d", payment.AppointmentID),
+			entries:     []ledgerEntry{escrowEntry(refund.Amount), clientEntry(payment, -refund.Amount)},
+		},
+		ledgerMovement{
+			kind:        models.LedgerTransactionRefund,
+			reference:   fmt.Sprintf("refund:%d", refund.ID),
+			description: fmt.Sprintf("Reembolso do agendamento #%d", payment.AppointmentID),
+			entries:     []ledgerEntry{clientEntry(payment, refund.Amount), clearingEntry(payment, -refund.Amount)},
+		},
+	)
+}
+
+// ChargebackTransactions monta o lançamento do valor contestado no emissor do cartão, que o
+// gateway debita da compensação e mantém à parte até a decisão da contestação
+func (s *LedgerService) ChargebackTransactions(payment *models.Payment, amount int64) ([]*models.LedgerTransaction, error) {
+	return s.paymentTransactions(payment,
+		ledgerMovement{
+			kind:        models.LedgerTransactionChargeback,
+			reference:   fmt.Sprintf("chargeback:%d", payment.ID),
+			description: fmt.Sprintf("Contestação do pagamento do agendamento #%d", payment.AppointmentID),
+			entries: []ledgerEntry{
+				{accountType: models.LedgerAccountChargebacks, amount: amount},
+				clearingEntry(payment, -amount),
+			},
+		},
+	)
+}
+
+// EscrowReleaseTransactions resolve as contas do repasse de um pagamento e retorna a função
+// que monta os lançamentos a partir da divisão pela comissão, decidida na transação do repasse.
+// O valor sai da custódia para a prestadora e para a receita da plataforma; a parte do cupom e
+// do crédito devolvida volta à conta de promoções e à carteira do cliente.
+func (s *LedgerService) EscrowReleaseTransactions(payment *models.Payment) (func(settlement *models.PaymentSettlement, returned repositories.ReturnedIncentives) []*models.LedgerTransaction, error) {
+	accounts, err := s.resolve(escrowEntry(0), providerEntry(payment.ProviderID, 0), ledgerEntry{accountType: models.LedgerAccountPlatformRevenue},
+		ledgerEntry{accountType: models.LedgerAccountPromotions}, clientEntry(payment, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	return func(settlement *models.PaymentSettlement, returned repositories.ReturnedIncentives) []*models.LedgerTransaction {
+		var movements []ledgerMovement
+		if settlement != nil && settlement.GrossAmount > 0 {
+			movements = append(movements, escrowReleaseMovement(settlement,
+				fmt.Sprintf("escrow_release:%d", payment.ID), fmt.Sprintf("Repasse do agendamento #%d", payment.AppointmentID)))
+		}
+		movements = append(movements, incentiveReturnMovements(payment.AppointmentID, payment.UserID, returned.PromoDiscount, returned.Credit)...)
+
+		transactions := make([]*models.LedgerTransaction, 0, len(movements))
+		for _, movement := range movements {
+			transaction := accounts.transaction(movement)
+			transaction.PaymentID = &payment.ID
+			transaction.AppointmentID = &payment.AppointmentID
+			transactions = append(transactions, transaction)
+		}
+		return transactions
+	}, nil
+}
+
+// IncentiveReleaseTransaction monta o repasse à prestadora do cupom e do crédito de um
+// agendamento coberto inteiramente por eles, sem pagamento, dividido pela comissão
+func (s *LedgerService) IncentiveReleaseTransaction(appointment *models.Appointment, settlement *models.PaymentSettlement) (*models.LedgerTransaction, error) {
+	transaction, err := s.transaction(escrowReleaseMovement(settlement,
+		fmt.Sprintf("incentive_release:%d", appointment.ID), fmt.Sprintf("Repasse do cupom e dos créditos do agendamento #%d", appointment.ID)))
+	if err != nil {
+		return nil, err
+	}
+	transaction.AppointmentID = &appointment.ID
+	return transaction, nil
+}
+
+// escrowReleaseMovement tira da custódia o valor bruto do repasse e o divide entre a
+// prestadora e a receita da plataforma
+func escrowReleaseMovement(settlement *models.PaymentSettlement, reference, description string) ledgerMovement {
+	entries := []ledgerEntry{escrowEntry(settlement.GrossAmount)}
+	if settlement.ProviderAmount > 0 {
+		entries = append(entries, providerEntry(settlement.ProviderID, -settlement.ProviderAmount))
+	}
+	if settlement.PlatformFee > 0 {
+		entries = append(entries, ledgerEntry{accountType: models.LedgerAccountPlatformRevenue, amount: -settlement.PlatformFee})
+	}
+	return ledgerMovement{
+		kind:        models.LedgerTransactionEscrowRelease,
+		reference:   reference,
+		description: description,
+		entries:     entries,
+	}
+}
+
+// incentiveReturnMovements devolvem da custódia o desconto do cupom à conta de promoções e
+// o crédito à carteira do cliente
+func incentiveReturnMovements(appointmentID, userID uint, promoDiscount, credit int64) []ledgerMovement {
+	var movements []ledgerMovement
+	if promoDiscount > 0 {
+		movements = append(movements, ledgerMovement{
+			kind:        models.LedgerTransactionPromoReversal,
+			reference:   fmt.Sprintf("promo_reversal:%d", appointmentID),
+			description: fmt.Sprintf("Estorno do cupom do agendamento #%d", appointmentID),
+			entries: []ledgerEntry{
+				escrowEntry(promoDiscount),
+				{accountType: models.LedgerAccountPromotions, amount: -promoDiscount},
+			},
+		})
+	}
+	if credit > 0 {
+		movements = append(movements, ledgerMovement{
+			kind:        models.LedgerTransactionCreditReturn,
+			reference:   fmt.Sprintf("credit_return:%d", appointmentID),
+			description: fmt.Sprintf("Créditos devolvidos do agendamento #%d", appointmentID),
+			entries: []ledgerEntry{
+				escrowEntry(credit),
+				{accountType: models.LedgerAccountClient, ownerID: &userID, amount: -credit},
+			},
+		})
+	}
+	return movements
+}
+
+// TipTransactions monta os lançamentos da gorjeta recebida pelo gateway e de seu repasse
+// integral à prestadora, sem passar pela custódia nem pela comissão
+func (s *LedgerService) TipTransactions(tip *models.Tip) ([]*models.LedgerTransaction, error) {
+	movements := []ledgerMovement{
+		{
+			kind:        models.LedgerTransactionTip,
+			reference:   fmt.Sprintf("tip:%d", tip.ID),
+			description: fmt.Sprintf("Gorjeta do agendamento #%d", tip.AppointmentID),
+			entries: []ledgerEntry{
+				{accountType: models.LedgerAccountGatewayClearing, qualifier: string(models.PaymentMethodCard), amount: tip.Amount},
+				{accountType: models.LedgerAccountClient, ownerID: &tip.UserID, amount: -tip.Amount},
+			},
+		},
+		{
+			kind:        models.LedgerTransactionTipTransfer,
+			reference:   fmt.Sprintf("tip_transfer:%d", tip.ID),
+			description: fmt.Sprintf("Repasse da gorjeta do agendamento #%d", tip.AppointmentID),
+			entries: []ledgerEntry{
+				{accountType: models.LedgerAccountClient, ownerID: &tip.UserID, amount: tip.Amount},
+				{accountType: models.LedgerAccountProvider, ownerID: &tip.ProviderID, amount: -tip.Amount},
+			},
+		},
+	}
+
+	transactions := make([]*models.LedgerTransaction, 0, len(movements))
+	for _, movement := range movements {
+		transaction, err := s.transaction(movement)
+		if err != nil {
+			return nil, err
+		}
+		transaction.TipID = &tip.ID
+		transaction.AppointmentID = &tip.AppointmentID
+		transactions = append(transactions, transaction)
+	}
+	return transactions, nil
+}
+
+func clearingEntry(payment *models.Payment, amount int64) ledgerEntry {
+	return ledgerEntry{accountType: models.LedgerAccountGatewayClearing, qualifier: string(payment.Method), amount: amount}
+}
+
+func clientEntry(payment *models.Payment, amount int64) ledgerEntry {
+	return ledgerEntry{accountType: models.LedgerAccountClient, ownerID: &payment.UserID, amount: amount}
+}
+
+func escrowEntry(amount int64) ledgerEntry {
+	return ledgerEntry{accountType: models.LedgerAccountEscrow, amount: amount}
+}
+
+func providerEntry(providerID uint, amount int64) ledgerEntry {
+	return ledgerEntry{accountType: models.LedgerAccountProvider, ownerID: &providerID, amount: amount}
+}
+
+// PayoutDebit monta o lançamento de um saque: o valor sai da conta da prestadora para a
+// compensação de repasses. Retorna também a conta da prestadora, que é bloqueada na
+// gravação; a referência é definida quando o saque é gravado.
+func (s *LedgerService) PayoutDebit(providerID uint, amount int64) (*models.LedgerTransaction, *models.LedgerAccount, error) {
+	account, err := s.account(models.LedgerAccountProvider, &providerID, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	transaction, err := s.transaction(ledgerMovement{
+		kind:        models.LedgerTransactionPayout,
+		description: "Saque da prestadora",
+		entries: []ledgerEntry{
+			{accountType: models.LedgerAccountProvider, ownerID: &providerID, amount: amount},
+			payoutClearingEntry(-amount),
+		},
+	})
+	return transaction, account, err
+}
+
+// PayoutReversal monta o lançamento que devolve ao saldo da prestadora um saque que falhou
+func (s *LedgerService) PayoutReversal(payout *models.Payout) (*models.LedgerTransaction, error) {
+	transaction, err := s.transaction(ledgerMovement{
+		kind:        models.LedgerTransactionPayoutReturn,
+		reference:   fmt.Sprintf("payout_reversal:%d", payout.ID),
+		description: "Estorno de saque não realizado",
+		entries: []ledgerEntry{
+			payoutClearingEntry(payout.Amount),
+			{accountType: models.LedgerAccountProvider, ownerID: &payout.ProviderID, amount: -payout.Amount},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	transaction.PayoutID = &payout.ID
+	return transaction, nil
+}
+
+func payoutClearingEntry(amount int64) ledgerEntry {
+	return ledgerEntry{accountType: models.LedgerAccountGatewayClearing, qualifier: "payout", amount: amount}
+}
+
+// paymentTransactions monta os lançamentos de um pagamento
+func (s *LedgerService) paymentTransactions(payment *models.Payment, movements ...ledgerMovement) ([]*models.LedgerTransaction, error) {
+	transactions := make([]*models.LedgerTransaction, 0, len(movements))
+	for _, movement := range movements {
+		transaction, err := s.transaction(movement)
+		if err != nil {
+			return nil, err
+		}
+		transaction.PaymentID = &payment.ID
+		transaction.AppointmentID = &payment.AppointmentID
+		transactions = append(transactions, transaction)
+	}
+	return transactions, nil
+}
+
+// transaction resolve as contas das partidas e monta o lançamento
+func (s *LedgerService) transaction(movement ledgerMovement) (*models.LedgerTransaction, error) {
+	accounts, err := s.resolve(movement.entries...)
+	if err != nil {
+		return nil, err
+	}
+	return accounts.transaction(movement), nil
+}
+
+// ledgerAccounts são contas do razão já resolvidas, pelo código. Permitem montar lançamentos
+// dentro de transações que não acessam o banco além do próprio movimento.
+type ledgerAccounts map[string]uint
+
+// resolve resolve as contas das partidas, criando-as no primeiro uso
+func (s *LedgerService) resolve(entries ...ledgerEntry) (ledgerAccounts, error) {
+	accounts := ledgerAccounts{}
+	for _, entry := range entries {
+		code := entry.code()
+		if _, ok := accounts[code]; ok {
+			continue
+		}
+		account, err := s.account(entry.accountType, entry.ownerID, entry.qualifier)
+		if err != nil {
+			return nil, err
+		}
+		accounts[code] = account.ID
+	}
+	return accounts, nil
+}
+
+// transaction monta o lançamento com as contas resolvidas
+func (a ledgerAccounts) transaction(movement ledgerMovement) *models.LedgerTransaction {
+	transaction := &models.LedgerTransaction{
+		Kind:        movement.kind,
+		Reference:   movement.reference,
+		Description: movement.description,
+	}
+	for _, entry := range movement.entries {
+		transaction.Postings = append(transaction.Postings, models.LedgerPosting{
+			AccountID: a[entry.code()],
+			Amount:    entry.amount,
+		})
+	}
+	return transaction
+}
+
+func (e ledgerEntry) code() string {
+	return models.LedgerAccountCode(e.accountType, e.ownerID, e.qualifier)
+}
+
+// account retorna a conta do razão, criando-a no primeiro uso
+func (s *LedgerService) account(accountType models.LedgerAccountType, ownerID *uint, qualifier string) (*models.LedgerAccount, error) {
+	return s.ledgerRepo.EnsureAccount(&models.LedgerAccount{
+		Code:     models.LedgerAccountCode(accountType, ownerID, qualifier),
+		Type:     accountType,
+		OwnerID:  ownerID,
+		Currency: models.PaymentCurrency,
+	})
+}
+
+// UserBalance retorna a conta e o saldo de um cliente ou de uma prestadora. Usuários
+// sem movimentos recebem a conta com saldo zero.
+func (s *LedgerService) UserBalance(accountType models.LedgerAccountType, userID uint) (*repositories.AccountBalance, error) {
+	account, err := s.account(accountType, &userID, "")
+	if err != nil {
+		return nil, err
+	}
+	balance, err := s.ledgerRepo.Balance(account.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &repositories.AccountBalance{LedgerAccount: *account, Balance: balance}, nil
+}
+
+// ReconciliationItem compara um pagamento ou uma gorjeta no gateway com o que foi lançado
+// no razão. Os valores são líquidos: capturado menos reembolsado, em centavos.
+type ReconciliationItem struct {
+	PaymentID       uint   `json:"payment_id,omitempty"`
+	TipID           uint   `json:"tip_id,omitempty"`
+	GatewayIntentID string `json:"payment_intent_id"`
+	Method          string `json:"method"`
+	GatewayAmount   int64  `json:"gateway_amount"`
+	LedgerAmount    int64  `json:"ledger_amount"`
+	Issue           string `json:"issue"`
+}
+
+// ReconciliationReport resume a conciliação do razão com os registros do gateway no período
+type ReconciliationReport struct {
+	From          time.Time            `json:"from"`
+	To            time.Time            `json:"to"`
+	Payments      int                  `json:"payments"`
+	Tips          int                  `json:"tips"`
+	Matched       int                  `json:"matched"`
+	GatewayTotal  int64                `json:"gateway_total"`
+	LedgerTotal   int64                `json:"ledger_total"`
+	Discrepancies []ReconciliationItem `json:"discrepancies"`
+}
+
+// add compara o valor do gateway com o saldo lançado de um item e o inclui no relatório
+func (r *ReconciliationReport) add(item ReconciliationItem, posted bool) {
+	r.GatewayTotal += item.GatewayAmount
+	r.LedgerTotal += item.LedgerAmount
+	switch {
+	case item.GatewayAmount == item.LedgerAmount:
+		r.Matched++
+		return
+	case !posted:
+		item.Issue = ReconciliationMissingInLedger
+	default:
+		item.Issue = ReconciliationAmountMismatch
+	}
+	r.Discrepancies = append(r.Discrepancies, item)
+}
+
+// Reconcile compara os pagamentos e as gorjetas liquidados ou lançados no período com o
+// saldo das contas de compensação de cada um. Só contam os reembolsos confirmados pelo gateway.
+func (s *LedgerService) Reconcile(from, to time.Time) (*ReconciliationReport, error) {
+	if !from.Before(to) {
+		return nil, errors.New("período de conciliação inválido")
+	}
+
+	report := &ReconciliationReport{From: from, To: to, Discrepancies: []ReconciliationItem{}}
+	if err := s.reconcilePayments(report); err != nil {
+		return nil, err
+	}
+	if err := s.reconcileTips(report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (s *LedgerService) reconcilePayments(report *ReconciliationReport) error {
+	postedIDs, err := s.ledgerRepo.PaymentIDsPostedBetween(report.From, report.To)
+	if err != nil {
+		return err
+	}
+	payments, err := s.paymentRepo.GetForReconciliation(report.From, report.To, postedIDs)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]uint, len(payments))
+	for i := range payments {
+		ids[i] = payments[i].ID
+	}
+	totals := map[uint]int64{}
+	if len(ids) > 0 {
+		if totals, err = s.ledgerRepo.ClearingTotals(ids); err != nil {
+			return err
+		}
+	}
+
+	for i := range payments {
+		payment := &payments[i]
+		ledger, posted := totals[payment.ID]
+		report.Payments++
+		report.add(ReconciliationItem{
+			PaymentID:       payment.ID,
+			GatewayIntentID: payment.GatewayIntentID,
+			Method:          string(payment.Method),
+			GatewayAmount:   gatewayNetAmount(payment),
+			LedgerAmount:    ledger,
+		}, posted)
+	}
+	return nil
+}
+
+func (s *LedgerService) reconcileTips(report *ReconciliationReport) error {
+	postedIDs, err := s.ledgerRepo.TipIDsPostedBetween(report.From, report.To)
+	if err != nil {
+		return err
+	}
+	tips, err := s.tipRepo.GetForReconciliation(report.From, report.To, postedIDs)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]uint, len(tips))
+	for i := range tips {
+		ids[i] = tips[i].ID
+	}
+	totals := map[uint]int64{}
+	if len(ids) > 0 {
+		if totals, err = s.ledgerRepo.TipClearingTotals(ids); err != nil {
+			return err
+		}
+	}
+
+	for i := range tips {
+		tip := &tips[i]
+		var captured int64
+		if tip.Status == models.TipStatusSucceeded && tip.PaidAt != nil {
+			captured = tip.Amount
+		}
+		ledger, posted := totals[tip.ID]
+		report.Tips++
+		report.add(ReconciliationItem{
+			TipID:           tip.ID,
+			GatewayIntentID: tip.GatewayIntentID,
+			Method:          string(models.PaymentMethodCard),
+			GatewayAmount:   captured,
+			LedgerAmount:    ledger,
+		}, posted)
+	}
+	return nil
+}
+
+// gatewayNetAmount retorna o valor capturado pelo gateway menos os reembolsos confirmados e
+// o valor contestado
+func gatewayNetAmount(payment *models.Payment) int64 {
+	if payment.PaidAt == nil {
+		return 0
+	}
+	switch payment.Status {
+	case models.PaymentStatusSucceeded, models.PaymentStatusPartiallyRefunded, models.PaymentStatusRefunded:
+	default:
+		return 0
+	}
+
+	net := payment.Amount
+	for _, refund := range payment.Refunds {
+		if refund.Status == models.RefundStatusSucceeded {
+			net -= refund.Amount
+		}
+	}
+	return net - payment.ChargebackAmount
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/testutil"
+)
+
+func TestLedgerFollowsPaymentLifecycle(t *testing.T) {
+	db := testutil.NewDB(t)
+	service := newPaymentService(db, NewFakePaymentGateway())
+	appointment, payment := paidAppointment(t, db, service, time.Now())
+
+	escrow := testutil.LedgerBalance(t, db, models.LedgerAccountEscrow, nil)
+	client := testutil.LedgerBalance(t, db, models.LedgerAccountClient, &appointment.UserID)
+	if escrow != -10000 || client != 0 {
+		t.Fatalf("custódia %d, cliente %d depois do pagamento; want -10000, 0", escrow, client)
+	}
+
+	if _, err := service.Refund(payment, 3000, "ajuste", nil); err != nil {
+		t.Fatalf("Refund: %v", err)
+	}
+	if err := service.releaseEscrowNow(payment, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Saldos credores são negativos no razão
+	escrow = testutil.LedgerBalance(t, db, models.LedgerAccountEscrow, nil)
+	provider := testutil.LedgerBalance(t, db, models.LedgerAccountProvider, &appointment.ProviderID)
+	if escrow != 0 || provider != -7000 {
+		t.Errorf("custódia %d, prestadora %d depois do repasse; want 0, -7000", escrow, provider)
+	}
+
+	balance, err := service.ledger.UserBalance(models.LedgerAccountProvider, appointment.ProviderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Balance != -7000 {
+		t.Errorf("UserBalance = %d, want -7000", balance.Balance)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	db := testutil.NewDB(t)
+	service := newPaymentService(db, NewFakePaymentGateway())
+	_, posted := paidAppointment(t, db, service, time.Now())
+
+	// Pagamento liquidado no gateway sem lançamentos no razão
+	paidAt := time.Now()
+	missing := testutil.CreatePayment(t, db, models.Payment{
+		Method: models.PaymentMethodCard, Amount: 8000, Status: models.PaymentStatusSucceeded, PaidAt: &paidAt,
+	})
+
+	report, err := service.ledger.Reconcile(time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Payments != 2 || report.Matched != 1 || report.GatewayTotal != 18000 || report.LedgerTotal != posted.Amount {
+		t.Errorf("relatório %+v; want 2 pagamentos, 1 conferido, 18000 no gateway e %d no razão", report, posted.Amount)
+	}
+	if len(report.Discrepancies) != 1 || report.Discrepancies[0].PaymentID != missing.ID ||
+		report.Discrepancies[0].Issue != ReconciliationMissingInLedger {
+		t.Errorf("divergências = %+v; want o pagamento %d ausente do razão", report.Discrepancies, missing.ID)
+	}
+}
+
+func TestReconcileIncludesTips(t *testing.T) {
+	db := testutil.NewDB(t)
+	service := newPaymentService(db, NewFakePaymentGateway())
+	appointment, _ := paidAppointment(t, db, service, time.Now())
+	tipRepo := repositories.NewTipRepository(db)
+
+	tests := []struct {
+		name   string
+		posted bool
+	}{
+		{"gorjeta lançada com o pagamento", true},
+		{"gorjeta paga sem lançamentos", false},
+	}
+	tipIDs := map[bool]uint{}
+	for i, tt := range tests {
+		tipAppointment := testutil.CreateAppointment(t, db, models.Appointment{
+			UserID: appointment.UserID, ProviderID: appointment.ProviderID, Date: time.Date(2026, 3, 10+i, 0, 0, 0, 0, time.UTC),
+		})
+		tip := &models.Tip{
+			AppointmentID: tipAppointment.ID, UserID: appointment.UserID, ProviderID: appointment.ProviderID,
+			Amount: 1500, Currency: models.PaymentCurrency, Status: models.TipStatusPending, GatewayIntentID: "pi_tip",
+		}
+		if err := db.Create(tip).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		var transactions []*models.LedgerTransaction
+		if tt.posted {
+			var err error
+			if transactions, err = service.ledger.TipTransactions(tip); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+		}
+		paidAt := time.Now()
+		tip.Status, tip.PaidAt = models.TipStatusSucceeded, &paidAt
+		if err := tipRepo.UpdateStatus(tip, transactions); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		tipIDs[tt.posted] = tip.ID
+	}
+
+	report, err := service.ledger.Reconcile(time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Payments != 1 || report.Tips != 2 || report.Matched != 2 {
+		t.Errorf("relatório com %d pagamentos, %d gorjetas e %d conferidos; want 1, 2 e 2", report.Payments, report.Tips, report.Matched)
+	}
+	if len(report.Discrepancies) != 1 {
+		t.Fatalf("divergências = %+v; want apenas a gorjeta sem lançamentos", report.Discrepancies)
+	}
+	item := report.Discrepancies[0]
+	if item.TipID != tipIDs[false] || item.Issue != ReconciliationMissingInLedger || item.GatewayAmount != 1500 {
+		t.Errorf("divergência = %+v; want gorjeta %d ausente do razão", item, tipIDs[false])
+	}
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+)
+
+const (
+	// NoShowGracePeriod é a tolerância após o início antes de uma ausência poder ser alegada
+	NoShowGracePeriod = 15 * time.Minute
+	// NoShowDisputeWindow é o prazo para a outra parte contestar a alegação
+	NoShowDisputeWindow = 48 * time.Hour
+	// StaleBookingAfter é o tempo após o início em que um agendamento confirmado sem
+	// check-in nem alegação é encerrado automaticamente
+	StaleBookingAfter = 24 * time.Hour
+)
+
+var (
+	ErrNoShowNotAllowed   = errors.New("ausência só pode ser alegada em agendamentos confirmados")
+	ErrNoShowTooEarly     = errors.New("ausência só pode ser alegada após o período de tolerância")
+	ErrNoShowNotOpen      = errors.New("alegação de ausência não está aberta para contestação")
+	ErrNoShowWindowClosed = errors.New("prazo de contestação encerrado")
+	ErrNoShowNotDisputed  = errors.New("alegação de ausência não está em análise")
+)
+
+// NoShowSettler é implementado pela camada de pagamentos para congelar o valor pago
+// enquanto a alegação está em aberto e, após a decisão, reter a taxa de ausência do
+// cliente ou reembolsá-lo quando a prestadora não comparece
+type NoShowSettler interface {
+	FreezeEscrow(appointment *models.Appointment) error
+	SettleNoShow(appointment *models.Appointment, claim *models.NoShowClaim) error
+}
+
+// NoShowService trata as alegações de ausência e sua resolução
+type NoShowService struct {
+	noShowRepo      *repositories.NoShowRepository
+	appointmentRepo *repositories.AppointmentRepository
+	auditService    *AuditService
+	settler         NoShowSettler
+	now             func() time.Time
+}
+
+func NewNoShowService(
+	noShowRepo *repositories.NoShowRepository,
+	appointmentRepo *repositories.AppointmentRepository,
+	auditService *AuditService,
+	settler NoShowSettler,
+) *NoShowService {
+	return &NoShowService{
+		noShowRepo:      noShowRepo,
+		appointmentRepo: appointmentRepo,
+		auditService:    auditService,
+		settler:         settler,
+		now:             time.Now,
+	}
+}
+
+// Claim registra que a outra parte do agendamento não compareceu
+func (s *NoShowService) Claim(appointment *models.Appointment, claimedBy uint, evidence string) (*models.NoShowClaim, error) {
+	if appointment.Status != models.AppointmentStatusConfirmed {
+		return nil, ErrNoShowNotAllowed
+	}
+
+	now := s.now()
+	if now.Before(appointment.StartsAt().Add(NoShowGracePeriod)) {
+		return nil, ErrNoShowTooEarly
+	}
+
+	absent := models.NoShowPartyProvider
+	if claimedBy == appointment.ProviderID {
+		absent = models.NoShowPartyClient
+	}
+
+	claim := &models.NoShowClaim{
+		AppointmentID:   appointment.ID,
+		ClaimedBy:       &claimedBy,
+		AbsentParty:     absent,
+		Evidence:        evidence,
+		DisputeDeadline: now.Add(NoShowDisputeWindow),
+		Status:          models.NoShowClaimStatusOpen,
+	}
+	claimed := s.auditService.Event(appointment.ID, &claimedBy, models.AppointmentEventNoShowClaimed, models.AppointmentStatusConfirmed, map[string]interface{}{
+		"status":       models.AppointmentStatusNoShow,
+		"absent_party": claim.AbsentParty,
+		"evidence":     claim.Evidence,
+	})
+	if err := s.noShowRepo.Create(claim, claimed); err != nil {
+		return nil, err
+	}
+
+	appointment.Status = models.AppointmentStatusNoShow
+
+	if s.settler != nil {
+		if err := s.settler.FreezeEscrow(appointment); err != nil {
+			log.Printf("Erro ao congelar pagamento do agendamento %d: %v", appointment.ID, err)
+		}
+	}
+	return claim, nil
+}
+
+// Dispute registra a contestação da parte acusada dentro da janela de contestação
+func (s *NoShowService) Dispute(claim *models.NoShowClaim, disputedBy uint, response string) error {
+	if claim.Status != models.NoShowClaimStatusOpen {
+		return ErrNoShowNotOpen
+	}
+
+	now := s.now()
+	if now.After(claim.DisputeDeadline) {
+		return ErrNoShowWindowClosed
+	}
+
+	claim.Status = models.NoShowClaimStatusDisputed
+	claim.Response = response
+	claim.RespondedAt = &now
+	disputed := s.auditService.Event(claim.AppointmentID, &disputedBy, models.AppointmentEventNoShowDisputed, models.NoShowClaimStatusOpen, map[string]interface{}{
+		"status":   claim.Status,
+		"response": claim.Response,
+	})
+	return s.noShowRepo.Update(claim, disputed)
+}
+
+// Resolve decide uma alegação contestada. resolvedBy é nulo nas resoluções automáticas.
+func (s *NoShowService) Resolve(claim *models.NoShowClaim, appointment *models.Appointment, upheld bool, resolvedBy *uint, note string) error {
+	if claim.Status != models.NoShowClaimStatusDisputed {
+		return ErrNoShowNotDisputed
+	}
+	return s.resolve(claim, appointment, upheld, resolvedBy, note)
+}
+
+// ProcessExpired confirma as alegações não contestadas no prazo e encerra os
+// agendamentos confirmados abandonados sem alegação de nenhuma das partes
+func (s *NoShowService) ProcessExpired() error {
+	now := s.now()
+
+	claims, err := s.noShowRepo.GetExpiredOpen(now)
+	if err != nil {
+		return err
+	}
+	for i := range claims {
+		appointment, err := s.appointmentRepo.FindByID(claims[i].AppointmentID)
+		if err != nil {
+			log.Printf("Erro ao buscar agendamento %d: %v", claims[i].AppointmentID, err)
+			continue
+		}
+		if err := s.resolve(&claims[i], appointment, true, nil, "Não contestada no prazo"); err != nil {
+			log.Printf("Erro ao resolver alegação de ausência %d: %v", claims[i].ID, err)
+		}
+	}
+
+	stale, err := s.noShowRepo.GetStaleConfirmed(now.Add(-StaleBookingAfter))
+	if err != nil {
+		return err
+	}
+	for i := range stale {
+		claim := &models.NoShowClaim{
+			AppointmentID:   stale[i].ID,
+			AbsentParty:     models.NoShowPartyUnknown,
+			DisputeDeadline: now,
+			Status:          models.NoShowClaimStatusOpen,
+		}
+		if err := s.noShowRepo.Create(claim); err != nil {
+			log.Printf("Erro ao encerrar agendamento abandonado %d: %v", stale[i].ID, err)
+			continue
+		}
+		stale[i].Status = models.AppointmentStatusNoShow
+		if err := s.resolve(claim, &stale[i], true, nil, "Encerrado automaticamente sem check-in"); err != nil {
+			log.Printf("Erro ao resolver agendamento abandonado %d: %v", stale[i].ID, err)
+		}
+	}
+
+	return nil
+}
+
+// resolve aplica a decisão, calcula a taxa e repassa o acerto para a camada de pagamentos.
+// Ausência do cliente retém o valor integral; ausência da prestadora ou de parte
+// desconhecida reembolsa o cliente. Alegações rejeitadas devolvem o agendamento a
+// confirmado, sem check-in, e o pagamento volta à custódia até o check-out.
+func (s *NoShowService) resolve(claim *models.NoShowClaim, appointment *models.Appointment, upheld bool, resolvedBy *uint, note string) error {
+	now := s.now()
+	previousStatus := claim.Status
+	claim.ResolvedAt = &now
+	claim.ResolvedBy = resolvedBy
+	claim.ResolutionNote = note
+
+	if upheld {
+		claim.Status = models.NoShowClaimStatusUpheld
+		appointment.Status = models.AppointmentStatusNoShow
+		if claim.AbsentParty == models.NoShowPartyClient {
+			claim.Fee = appointment.Price
+		}
+	} else {
+		claim.Status = models.NoShowClaimStatusRejected
+		appointment.Status = models.AppointmentStatusConfirmed
+	}
+
+	resolved := s.auditService.Event(appointment.ID, resolvedBy, models.AppointmentEventNoShowResolved, previousStatus, map[string]interface{}{
+		"claim_status":       claim.Status,
+		"appointment_status": appointment.Status,
+		"fee":                claim.Fee,
+		"note":               claim.ResolutionNote,
+	})
+	if err := s.noShowRepo.Resolve(claim, appointment, resolved); err != nil {
+		return err
+	}
+
+	if s.settler != nil {
+		return s.settler.SettleNoShow(appointment, claim)
+	}
+	return nil
+}
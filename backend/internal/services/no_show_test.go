@@ -0,0 +1,112 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/testutil"
+)
+
+// recordingSettler guarda os acertos pedidos à camada de pagamentos
+type recordingSettler struct {
+	frozen  []uint
+	settled []models.NoShowClaimStatus
+}
+
+func (r *recordingSettler) FreezeEscrow(appointment *models.Appointment) error {
+	r.frozen = append(r.frozen, appointment.ID)
+	return nil
+}
+
+func (r *recordingSettler) SettleNoShow(appointment *models.Appointment, claim *models.NoShowClaim) error {
+	r.settled = append(r.settled, claim.Status)
+	return nil
+}
+
+func TestNoShowResolve(t *testing.T) {
+	tests := []struct {
+		name       string
+		claimant   string
+		upheld     bool
+		wantStatus models.AppointmentStatus
+		wantFee    float64
+	}{
+		{"ausência do cliente confirmada", "provider", true, models.AppointmentStatusNoShow, 150},
+		{"ausência da prestadora confirmada", "client", true, models.AppointmentStatusNoShow, 0},
+		{"alegação rejeitada volta a confirmado", "provider", false, models.AppointmentStatusConfirmed, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			client := testutil.CreateUser(t, db, models.UserTypeClient)
+			provider := testutil.CreateProvider(t, db, models.ProviderProfile{HourlyRate: 75})
+			startsAt := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+			appointment := testutil.CreateAppointment(t, db, models.Appointment{
+				UserID: client.ID, ProviderID: provider.ID, Date: startsAt, Price: 150,
+				Status: models.AppointmentStatusConfirmed,
+			})
+
+			settler := &recordingSettler{}
+			appointmentRepo := repositories.NewAppointmentRepository(db, nil)
+			service := NewNoShowService(repositories.NewNoShowRepository(db), appointmentRepo,
+				NewAuditService(repositories.NewAppointmentEventRepository(db)), settler)
+			service.now = func() time.Time { return startsAt.Add(time.Hour) }
+
+			claimant := client.ID
+			if tt.claimant == "provider" {
+				claimant = provider.ID
+			}
+			claim, err := service.Claim(appointment, claimant, "")
+			if err != nil {
+				t.Fatalf("Claim: %v", err)
+			}
+			if err := service.Dispute(claim, client.ID+provider.ID-claimant, "estive lá"); err != nil {
+				t.Fatalf("Dispute: %v", err)
+			}
+			if err := service.Resolve(claim, appointment, tt.upheld, nil, ""); err != nil {
+				t.Fatalf("Resolve: %v", err)
+			}
+
+			stored, err := appointmentRepo.FindByID(appointment.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Status != tt.wantStatus {
+				t.Errorf("status do agendamento = %s, want %s", stored.Status, tt.wantStatus)
+			}
+			if claim.Fee != tt.wantFee {
+				t.Errorf("taxa = %v, want %v", claim.Fee, tt.wantFee)
+			}
+			if len(settler.frozen) != 1 || len(settler.settled) != 1 || settler.settled[0] != claim.Status {
+				t.Errorf("acertos = %v congelados, %v resolvidos", settler.frozen, settler.settled)
+			}
+		})
+	}
+}
+
+func TestNoShowStaleSkipsRejectedClaims(t *testing.T) {
+	db := testutil.NewDB(t)
+	client := testutil.CreateUser(t, db, models.UserTypeClient)
+	provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+	startsAt := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	appointment := testutil.CreateAppointment(t, db, models.Appointment{
+		UserID: client.ID, ProviderID: provider.ID, Date: startsAt, Status: models.AppointmentStatusConfirmed,
+	})
+	abandoned := testutil.CreateAppointment(t, db, models.Appointment{
+		UserID: client.ID, ProviderID: provider.ID, Date: startsAt.Add(3 * time.Hour), Status: models.AppointmentStatusConfirmed,
+	})
+	db.Create(&models.NoShowClaim{
+		AppointmentID: appointment.ID, AbsentParty: models.NoShowPartyClient,
+		DisputeDeadline: startsAt, Status: models.NoShowClaimStatusRejected,
+	})
+
+	stale, err := repositories.NewNoShowRepository(db).GetStaleConfirmed(startsAt.Add(48 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stale) != 1 || stale[0].ID != abandoned.ID {
+		t.Errorf("GetStaleConfirmed = %+v, want apenas o agendamento %d", stale, abandoned.ID)
+	}
+}
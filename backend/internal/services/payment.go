@@ -0,0 +1,520 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+)
+
+var (
+	ErrAppointmentNotPayable  = errors.New("este agendamento não pode ser pago")
+	ErrAppointmentAlreadyPaid = errors.New("este agendamento já foi pago")
+	ErrPaymentNotConfirmable  = errors.New("este pagamento não aguarda confirmação")
+	ErrPaymentDeclined        = errors.New("pagamento recusado")
+	ErrPaymentMethodNotOwned  = errors.New("este método de pagamento pertence a outro cliente")
+	ErrRefundFailed           = errors.New("não foi possível processar o reembolso")
+)
+
+// ToMinorUnits converte um valor em reais para centavos
+func ToMinorUnits(value float64) int64 {
+	return int64(math.Round(value * 100))
+}
+
+// AppointmentAmountDue retorna, em centavos, o valor a ser cobrado do cliente pelo
+// agendamento, já descontados o cupom e os créditos aplicados na reserva
+func AppointmentAmountDue(appointment *models.Appointment) int64 {
+	amount := ToMinorUnits(appointment.Price+appointment.OvertimeCharge) - appointment.PromoDiscount - appointment.CreditApplied
+	return max(amount, 0)
+}
+
+// PaymentService cobra os agendamentos pelo PaymentGateway (cartão) ou pelo PixProvider e
+// processa os reembolsos. Os valores são sempre calculados a partir do agendamento, nunca
+// informados pelo cliente. O valor pago fica em custódia até a conclusão do atendimento.
+// Também implementa RefundIssuer, NoShowSettler e EscrowScheduler.
+type PaymentService struct {
+	paymentRepo     *repositories.PaymentRepository
+	appointmentRepo *repositories.AppointmentRepository
+	termsRepo       *repositories.TermsRepository
+	gateway         PaymentGateway
+	pix             PixProvider
+	pixConfig       PixConfig
+	auditService    *AuditService
+	ledger          *LedgerService
+	commission      *CommissionService
+	promotions      *PromotionService
+	now             func() time.Time
+}
+
+func NewPaymentService(
+	paymentRepo *repositories.PaymentRepository,
+	appointmentRepo *repositories.AppointmentRepository,
+	termsRepo *repositories.TermsRepository,
+	gateway PaymentGateway,
+	pix PixProvider,
+	pixConfig PixConfig,
+	auditService *AuditService,
+	ledger *LedgerService,
+	commission *CommissionService,
+	promotions *PromotionService,
+) *PaymentService {
+	if pixConfig.ChargeExpiry <= 0 {
+		pixConfig.ChargeExpiry = DefaultPixChargeExpiry
+	}
+	return &PaymentService{
+		paymentRepo:     paymentRepo,
+		appointmentRepo: appointmentRepo,
+		termsRepo:       termsRepo,
+		gateway:         gateway,
+		pix:             pix,
+		pixConfig:       pixConfig,
+		auditService:    auditService,
+		ledger:          ledger,
+		commission:      commission,
+		promotions:      promotions,
+		now:             time.Now,
+	}
+}
+
+// CreateIntent cria a intenção de pagamento com cartão do agendamento
+func (s *PaymentService) CreateIntent(appointment *models.Appointment) (*models.Payment, error) {
+	return s.openPayment(appointment, models.PaymentMethodCard, s.newCardPayment)
+}
+
+// openPayment abre o pagamento do agendamento pelo método informado. Se já houver um pagamento
+// em aberto com o mesmo método e valor, ele é reaproveitado; caso contrário, é cancelado e substituído.
+func (s *PaymentService) openPayment(
+	appointment *models.Appointment,
+	method models.PaymentMethodType,
+	create func(appointment *models.Appointment, amount int64) (*models.Payment, error),
+) (*models.Payment, error) {
+	switch appointment.Status {
+	case models.AppointmentStatusCancelled, models.AppointmentStatusNoShow:
+		return nil, ErrAppointmentNotPayable
+	}
+	amount := AppointmentAmountDue(appointment)
+	if amount <= 0 {
+		return nil, ErrAppointmentNotPayable
+	}
+
+	existing, err := s.paymentRepo.FindActiveByAppointment(appointment.ID)
+	switch {
+	case err == nil:
+		if reused, err := s.reusePayment(existing, method, amount); reused != nil || err != nil {
+			return reused, err
+		}
+	case !errors.Is(err, repositories.ErrPaymentNotFound):
+		return nil, err
+	}
+
+	payment, err := create(appointment, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, created, err := s.paymentRepo.CreateIfNone(payment)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		// Outra requisição criou o pagamento antes; a cobrança nova é descartada
+		if err := s.cancelAtProvider(payment); err != nil {
+			log.Printf("Erro ao cancelar cobrança %s: %v", payment.GatewayIntentID, err)
+		}
+		if existing.Status == models.PaymentStatusPending || existing.Status == models.PaymentStatusFailed {
+			return existing, nil
+		}
+		return nil, ErrAppointmentAlreadyPaid
+	}
+	return payment, nil
+}
+
+// reusePayment retorna o pagamento existente quando ele ainda serve para o valor devido.
+// Retorna nil quando um novo pagamento deve ser criado.
+func (s *PaymentService) reusePayment(existing *models.Payment, method models.PaymentMethodType, amount int64) (*models.Payment, error) {
+	if existing.Status != models.PaymentStatusPending && existing.Status != models.PaymentStatusFailed {
+		return nil, ErrAppointmentAlreadyPaid
+	}
+	expired := existing.ExpiresAt != nil && !existing.ExpiresAt.After(s.now())
+	if existing.Method == method && existing.Amount == amount && !expired {
+		return existing, nil
+	}
+	return nil, s.cancelIntent(existing)
+}
+
+// newCardPayment cria a intenção no gateway de cartão
+func (s *PaymentService) newCardPayment(appointment *models.Appointment, amount int64) (*models.Payment, error) {
+	intent, err := s.gateway.CreateIntent(GatewayIntentRequest{
+		Amount:      amount,
+		Currency:    models.PaymentCurrency,
+		Description: fmt.Sprintf("Agendamento #%d - %s", appointment.ID, appointment.Service),
+		Metadata:    map[string]string{"appointment_id": fmt.Sprint(appointment.ID)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Payment{
+		AppointmentID:   appointment.ID,
+		UserID:          appointment.UserID,
+		ProviderID:      appointment.ProviderID,
+		Amount:          amount,
+		Currency:        models.PaymentCurrency,
+		Method:          models.PaymentMethodCard,
+		Status:          models.PaymentStatusPending,
+		GatewayIntentID: intent.ID,
+		ClientSecret:    intent.ClientSecret,
+	}, nil
+}
+
+// Confirm confirma o pagamento com cartão usando o método informado, que precisa ser do
+// cliente do pagamento. Pagamentos recusados podem ser confirmados novamente com outro método.
+func (s *PaymentService) Confirm(payment *models.Payment, paymentMethodID string) (*models.Payment, error) {
+	if payment.Method != models.PaymentMethodCard ||
+		(payment.Status != models.PaymentStatusPending && payment.Status != models.PaymentStatusFailed) {
+		return nil, ErrPaymentNotConfirmable
+	}
+	if err := s.ensureMethodOwner(payment.UserID, paymentMethodID); err != nil {
+		return nil, err
+	}
+
+	intent, err := s.gateway.ConfirmIntent(payment.GatewayIntentID, paymentMethodID)
+	if err != nil {
+		return nil, err
+	}
+
+	payment.PaymentMethodID = paymentMethodID
+	switch intent.Status {
+	case GatewayIntentSucceeded:
+		if err := s.markPaid(payment, "", s.now()); err != nil {
+			return nil, err
+		}
+		return payment, nil
+	case GatewayIntentFailed:
+		payment.Status = models.PaymentStatusFailed
+		payment.FailureReason = intent.FailureReason
+		if err := s.paymentRepo.UpdateStatus(payment); err != nil {
+			return nil, err
+		}
+		return payment, fmt.Errorf("%w: %s", ErrPaymentDeclined, payment.FailureReason)
+	}
+	return nil, fmt.Errorf("status inesperado do gateway: %s", intent.Status)
+}
+
+// ensureMethodOwner garante que o método de pagamento é do cliente. Um cartão novo, criado
+// pelo aplicativo, é salvo para ele; o gateway recusa cartões vinculados a outro cliente.
+func (s *PaymentService) ensureMethodOwner(userID uint, paymentMethodID string) error {
+	_, err := s.paymentRepo.FindMethod(userID, paymentMethodID)
+	if !errors.Is(err, repositories.ErrPaymentMethodNotFound) {
+		return err
+	}
+
+	if _, err := s.SaveMethod(userID, paymentMethodID); err != nil {
+		if errors.Is(err, ErrGatewayMethodInUse) {
+			return ErrPaymentMethodNotOwned
+		}
+		return err
+	}
+	return nil
+}
+
+// paidEvent registra no histórico a liquidação do pagamento
+func (s *PaymentService) paidEvent(payment *models.Payment) *models.AppointmentEvent {
+	return s.auditService.Event(payment.AppointmentID, nil, models.AppointmentEventPaymentPaid, nil, map[string]interface{}{
+		"payment_id": payment.ID,
+		"method":     payment.Method,
+		"amount":     payment.Amount,
+	})
+}
+
+// latePaymentReason é o motivo do reembolso de pagamentos recebidos depois do cancelamento
+const latePaymentReason = "pagamento recebido após o cancelamento da cobrança"
+
+// markPaid registra a liquidação informada pelo provedor, com os lançamentos no razão na
+// mesma transação. Um pagamento já cancelado, como um Pix pago depois de a cobrança expirar,
+// não confirma o agendamento: o valor é devolvido integralmente ao cliente.
+func (s *PaymentService) markPaid(payment *models.Payment, endToEndID string, paidAt time.Time) error {
+	ledger, err := s.ledger.PaymentTransactions(payment)
+	if err != nil {
+		return err
+	}
+
+	settled, err := s.paymentRepo.MarkPaid(payment, endToEndID, paidAt, ledger, s.paidEvent(payment))
+	if err != nil {
+		return err
+	}
+	if settled {
+		s.paymentSettled(payment)
+		return nil
+	}
+
+	late, err := s.paymentRepo.MarkPaidAfterCancel(payment, endToEndID, paidAt, latePaymentReason, ledger, s.paidEvent(payment))
+	if err != nil || !late {
+		return err
+	}
+	s.refundLatePayment(payment)
+	return nil
+}
+
+// refundLatePayment devolve o pagamento recebido após o cancelamento. Se o provedor recusar
+// a devolução, o valor continua congelado e o motivo gravado no pagamento o identifica para
+// a conciliação manual.
+func (s *PaymentService) refundLatePayment(payment *models.Payment) {
+	if _, err := s.Refund(payment, payment.Amount, latePaymentReason, nil); err != nil {
+		log.Printf("Pagamento %d recebido após o cancelamento não foi devolvido; conciliar manualmente: %v", payment.ID, err)
+		return
+	}
+	if _, err := s.paymentRepo.CloseRefundedEscrow(payment.ID); err != nil {
+		log.Printf("Erro ao encerrar custódia do pagamento %d devolvido: %v", payment.ID, err)
+	}
+}
+
+// paymentSettled acompanha o status do agendamento pago: o pendente é confirmado, o
+// concluído tem o repasse agendado e o cancelado é reembolsado.
+// Se as condições do agendamento ainda não tiverem a ciência da prestadora, ele continua
+// pendente e a confirmação segue o fluxo normal.
+func (s *PaymentService) paymentSettled(payment *models.Payment) {
+	appointment, err := s.appointmentRepo.FindByID(payment.AppointmentID)
+	if err != nil {
+		log.Printf("Erro ao buscar agendamento %d pago: %v", payment.AppointmentID, err)
+		return
+	}
+	switch appointment.Status {
+	case models.AppointmentStatusPending:
+	case models.AppointmentStatusCompleted:
+		if err := s.ScheduleEscrowRelease(appointment); err != nil {
+			log.Printf("Erro ao agendar repasse do agendamento %d: %v", appointment.ID, err)
+		}
+		return
+	case models.AppointmentStatusCancelled:
+		// Pix pago depois do cancelamento
+		s.refundCancelled(appointment)
+		return
+	default:
+		return
+	}
+
+	if err := s.termsRepo.EnsureAcknowledged(appointment.ID); err != nil {
+		if !errors.Is(err, repositories.ErrTermsNotAcknowledged) {
+			log.Printf("Erro ao verificar condições do agendamento %d: %v", appointment.ID, err)
+		}
+		return
+	}
+
+	confirmed := s.auditService.Event(appointment.ID, nil, models.AppointmentEventStatusChanged, appointment.Status, models.AppointmentStatusConfirmed)
+	err = s.appointmentRepo.TransitionStatus(appointment.ID, models.AppointmentStatusPending, models.AppointmentStatusConfirmed, confirmed)
+	if errors.Is(err, repositories.ErrAppointmentStatusChanged) {
+		// O cliente cancelou enquanto o pagamento era liquidado
+		current, err := s.appointmentRepo.FindByID(appointment.ID)
+		if err != nil {
+			log.Printf("Erro ao buscar agendamento %d pago: %v", appointment.ID, err)
+			return
+		}
+		if current.Status == models.AppointmentStatusCancelled {
+			s.refundCancelled(current)
+		}
+		return
+	}
+	if err != nil {
+		log.Printf("Erro ao confirmar agendamento %d pago: %v", appointment.ID, err)
+	}
+}
+
+// refundCancelled devolve o pagamento liquidado depois do cancelamento do agendamento
+func (s *PaymentService) refundCancelled(appointment *models.Appointment) {
+	if err := s.RefundCancellation(appointment); err != nil {
+		log.Printf("Erro ao reembolsar agendamento %d cancelado: %v", appointment.ID, err)
+	}
+}
+
+// Refund devolve amount centavos do pagamento, ou todo o saldo quando amount é zero.
+// O valor é reservado antes de chamar o gateway, para que reembolsos simultâneos
+// não ultrapassem o valor pago.
+func (s *PaymentService) Refund(payment *models.Payment, amount int64, reason string, requestedBy *uint) (*models.Refund, error) {
+	if amount == 0 {
+		amount = payment.Refundable()
+	}
+
+	refund := &models.Refund{
+		Amount:      amount,
+		Reason:      reason,
+		RequestedBy: requestedBy,
+	}
+	if _, err := s.paymentRepo.ReserveRefund(payment.ID, refund); err != nil {
+		return nil, err
+	}
+
+	// As contas do razão são resolvidas antes de o gateway devolver o valor
+	ledger, err := s.ledger.RefundTransactions(payment, refund)
+	if err != nil {
+		s.releaseRefund(refund)
+		return nil, err
+	}
+	result, err := s.refundAtProvider(payment, amount, reason)
+	if err != nil {
+		s.releaseRefund(refund)
+		return nil, fmt.Errorf("%w: %v", ErrRefundFailed, err)
+	}
+	if err := s.paymentRepo.CompleteRefund(refund, result.ID, ledger, s.refundedEvent(payment, refund, requestedBy)); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// releaseRefund devolve ao pagamento o valor reservado de um reembolso não realizado
+func (s *PaymentService) releaseRefund(refund *models.Refund) {
+	if err := s.paymentRepo.FailRefund(refund); err != nil {
+		log.Printf("Erro ao liberar reembolso %d recusado: %v", refund.ID, err)
+	}
+}
+
+// refundedEvent registra no histórico um reembolso concluído
+func (s *PaymentService) refundedEvent(payment *models.Payment, refund *models.Refund, requestedBy *uint) *models.AppointmentEvent {
+	return s.auditService.Event(payment.AppointmentID, requestedBy, models.AppointmentEventRefunded, nil, map[string]interface{}{
+		"payment_id": payment.ID,
+		"refund_id":  refund.ID,
+		"amount":     refund.Amount,
+		"reason":     refund.Reason,
+	})
+}
+
+// RefundCancellation devolve ao cliente o valor pago descontada a taxa de cancelamento,
+// que é repassada à prestadora. Intenções ainda não pagas são canceladas. O crédito da
+// carteira e o uso do cupom aplicados na reserva são devolvidos antes. Se algo falhar, o
+// reembolso continua pendente e é refeito por RetryCancellationRefunds.
+func (s *PaymentService) RefundCancellation(appointment *models.Appointment) error {
+	if err := s.promotions.ReleaseBooking(appointment); err != nil {
+		return err
+	}
+	if err := s.settleExcept(appointment, ToMinorUnits(appointment.CancellationFee), "cancelamento do agendamento"); err != nil {
+		return err
+	}
+	return s.appointmentRepo.ClearCancellationRefund(appointment.ID)
+}
+
+// cancellationRefundRetryDelay é a espera antes de refazer um reembolso de cancelamento
+// pendente, para não concorrer com a tentativa feita no próprio cancelamento
+const cancellationRefundRetryDelay = 5 * time.Minute
+
+// RetryCancellationRefunds refaz os reembolsos de cancelamento que falharam
+func (s *PaymentService) RetryCancellationRefunds() error {
+	pending, err := s.appointmentRepo.GetPendingCancellationRefunds(s.now().Add(-cancellationRefundRetryDelay))
+	if err != nil {
+		return err
+	}
+	for i := range pending {
+		if err := s.RefundCancellation(&pending[i]); err != nil {
+			log.Printf("Erro ao reembolsar agendamento %d cancelado: %v", pending[i].ID, err)
+		}
+	}
+	return nil
+}
+
+// SettleNoShow encerra a custódia após a decisão da alegação de ausência. Se confirmada,
+// a taxa definida na alegação é repassada à prestadora e o restante é reembolsado;
+// se rejeitada, o valor volta à custódia, sem repasse, até o check-out do atendimento.
+func (s *PaymentService) SettleNoShow(appointment *models.Appointment, claim *models.NoShowClaim) error {
+	if claim.Status != models.NoShowClaimStatusUpheld {
+		return s.UnfreezeEscrow(appointment)
+	}
+	return s.settleExcept(appointment, ToMinorUnits(claim.Fee), "ausência confirmada")
+}
+
+// settleExcept reembolsa o pagamento do agendamento mantendo o valor keep, que é
+// repassado à prestadora em seguida. Sem pagamento, o cupom e o crédito da reserva que
+// ainda estiverem na custódia são devolvidos.
+func (s *PaymentService) settleExcept(appointment *models.Appointment, keep int64, reason string) error {
+	payment, err := s.paymentRepo.FindActiveByAppointment(appointment.ID)
+	if errors.Is(err, repositories.ErrPaymentNotFound) {
+		return s.promotions.ReleaseBooking(appointment)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch payment.Status {
+	case models.PaymentStatusPending, models.PaymentStatusFailed:
+		return s.cancelIntent(payment)
+	case models.PaymentStatusSucceeded, models.PaymentStatusPartiallyRefunded:
+		available := payment.Amount - payment.RefundedAmount
+		amount := available - min(max(keep, 0), available)
+		if amount > 0 {
+			if _, err := s.Refund(payment, amount, reason, nil); err != nil {
+				return err
+			}
+		}
+		return s.releaseEscrowNow(payment, nil)
+	}
+	return nil
+}
+
+// cancelIntent cancela um pagamento ainda não pago, no provedor e localmente
+func (s *PaymentService) cancelIntent(payment *models.Payment) error {
+	if err := s.cancelAtProvider(payment); err != nil {
+		return err
+	}
+	payment.Status = models.PaymentStatusCanceled
+	return s.paymentRepo.UpdateStatus(payment)
+}
+
+func (s *PaymentService) cancelAtProvider(payment *models.Payment) error {
+	if payment.Method == models.PaymentMethodPix {
+		return s.pix.CancelCharge(payment.GatewayIntentID)
+	}
+	return s.gateway.CancelIntent(payment.GatewayIntentID)
+}
+
+func (s *PaymentService) refundAtProvider(payment *models.Payment, amount int64, reason string) (*GatewayRefund, error) {
+	if payment.Method == models.PaymentMethodPix {
+		return s.pix.Refund(payment.GatewayIntentID, amount, reason)
+	}
+	return s.gateway.Refund(payment.GatewayIntentID, amount, reason)
+}
+
+// History retorna os pagamentos feitos pelo cliente
+func (s *PaymentService) History(userID uint) ([]models.Payment, error) {
+	return s.paymentRepo.GetByUser(userID)
+}
+
+// SaveMethod salva um método de pagamento criado pelo aplicativo no gateway
+func (s *PaymentService) SaveMethod(userID uint, paymentMethodID string) (*models.PaymentMethod, error) {
+	attached, err := s.gateway.AttachPaymentMethod(customerRef(userID), paymentMethodID)
+	if err != nil {
+		return nil, err
+	}
+
+	method := &models.PaymentMethod{
+		UserID:          userID,
+		GatewayMethodID: attached.ID,
+		Brand:           attached.Brand,
+		Last4:           attached.Last4,
+		ExpMonth:        attached.ExpMonth,
+		ExpYear:         attached.ExpYear,
+	}
+	if err := s.paymentRepo.SaveMethod(method); err != nil {
+		return nil, err
+	}
+	return method, nil
+}
+
+// DeleteMethod remove um método de pagamento salvo pelo cliente
+func (s *PaymentService) DeleteMethod(userID uint, paymentMethodID string) error {
+	method, err := s.paymentRepo.FindMethod(userID, paymentMethodID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.gateway.DetachPaymentMethod(method.GatewayMethodID); err != nil && !errors.Is(err, ErrGatewayMethodNotFound) {
+		return err
+	}
+	return s.paymentRepo.DeleteMethod(method)
+}
+
+// customerRef identifica o cliente no gateway
+func customerRef(userID uint) string {
+	return fmt.Sprintf("user_%d", userID)
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"errors"
+)
+
+var (
+	ErrGatewayIntentNotFound = errors.New("intenção de pagamento não encontrada no gateway")
+	ErrGatewayMethodNotFound = errors.New("método de pagamento não encontrado no gateway")
+	ErrGatewayInvalidRefund  = errors.New("reembolso recusado pelo gateway")
+	ErrGatewayMethodInUse    = errors.New("método de pagamento vinculado a outro cliente no gateway")
+)
+
+// GatewayIntentStatus é o estado de uma intenção de pagamento no gateway
+type GatewayIntentStatus string
+
+const (
+	GatewayIntentRequiresConfirmation GatewayIntentStatus = "requires_confirmation"
+	GatewayIntentSucceeded            GatewayIntentStatus = "succeeded"
+	GatewayIntentFailed               GatewayIntentStatus = "failed"
+	GatewayIntentCanceled             GatewayIntentStatus = "canceled"
+)
+
+// GatewayIntentRequest descreve a cobrança a ser criada no gateway
+type GatewayIntentRequest struct {
+	Amount      int64 // Em centavos
+	Currency    string
+	Description string
+	Metadata    map[string]string
+}
+
+// GatewayIntent é uma intenção de pagamento no gateway
+type GatewayIntent struct {
+	ID            string
+	ClientSecret  string
+	Amount        int64
+	Currency      string
+	Status        GatewayIntentStatus
+	FailureReason string
+}
+
+// GatewayRefund é um reembolso processado pelo gateway
+type GatewayRefund struct {
+	ID     string
+	Amount int64
+}
+
+// GatewayPaymentMethod descreve um cartão salvo no gateway
+type GatewayPaymentMethod struct {
+	ID       string
+	Brand    string
+	Last4    string
+	ExpMonth int
+	ExpYear  int
+}
+
+// PaymentGateway é o provedor de pagamentos, no modelo de intenções do Stripe:
+// a intenção é criada pelo servidor, confirmada com um método de pagamento e
+// reembolsada total ou parcialmente. FakePaymentGateway o implementa em memória.
+type PaymentGateway interface {
+	CreateIntent(req GatewayIntentRequest) (*GatewayIntent, error)
+	ConfirmIntent(intentID, paymentMethodID string) (*GatewayIntent, error)
+	GetIntent(intentID string) (*GatewayIntent, error)
+	CancelIntent(intentID string) error
+	Refund(intentID string, amount int64, reason string) (*GatewayRefund, error)
+
+	// customerRef identifica o cliente no gateway. Métodos já vinculados a outro cliente
+	// são recusados com ErrGatewayMethodInUse.
+	AttachPaymentMethod(customerRef, paymentMethodID string) (*GatewayPaymentMethod, error)
+	DetachPaymentMethod(paymentMethodID string) error
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+)
+
+// pixQRCodeScale é o tamanho em pixels de cada módulo do QR Code
+const pixQRCodeScale = 8
+
+var (
+	ErrNotPixPayment     = errors.New("o pagamento não é uma cobrança Pix")
+	ErrPixAmountMismatch = errors.New("valor recebido diferente do valor da cobrança")
+)
+
+// CreatePixCharge cria a cobrança Pix do agendamento, com o BR Code dinâmico para pagamento
+func (s *PaymentService) CreatePixCharge(appointment *models.Appointment) (*models.Payment, error) {
+	return s.openPayment(appointment, models.PaymentMethodPix, s.newPixPayment)
+}
+
+// newPixPayment cria a cobrança no PSP e monta o código "copia e cola"
+func (s *PaymentService) newPixPayment(appointment *models.Appointment, amount int64) (*models.Payment, error) {
+	charge, err := s.pix.CreateCharge(PixChargeRequest{
+		TxID:        newPixTxID(),
+		Amount:      amount,
+		Expiry:      s.pixConfig.ChargeExpiry,
+		Description: fmt.Sprintf("Agendamento #%d", appointment.ID),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := BRCode{
+		Location:     charge.Location,
+		Amount:       amount,
+		MerchantName: s.pixConfig.MerchantName,
+		MerchantCity: s.pixConfig.MerchantCity,
+	}.Payload()
+	if err != nil {
+		if cancelErr := s.pix.CancelCharge(charge.TxID); cancelErr != nil {
+			log.Printf("Erro ao cancelar cobrança Pix %s: %v", charge.TxID, cancelErr)
+		}
+		return nil, err
+	}
+
+	expiresAt := charge.ExpiresAt
+	return &models.Payment{
+		AppointmentID:   appointment.ID,
+		UserID:          appointment.UserID,
+		ProviderID:      appointment.ProviderID,
+		Amount:          amount,
+		Currency:        models.PaymentCurrency,
+		Method:          models.PaymentMethodPix,
+		Status:          models.PaymentStatusPending,
+		GatewayIntentID: charge.TxID,
+		PixCode:         code,
+		ExpiresAt:       &expiresAt,
+	}, nil
+}
+
+// PixQRCode desenha o QR Code PNG do código "copia e cola" da cobrança
+func (s *PaymentService) PixQRCode(payment *models.Payment) ([]byte, error) {
+	if payment.Method != models.PaymentMethodPix || payment.PixCode == "" {
+		return nil, ErrNotPixPayment
+	}
+
+	qr, err := EncodeQRCode([]byte(payment.PixCode))
+	if err != nil {
+		return nil, err
+	}
+	return qr.PNG(pixQRCodeScale)
+}
+
+// SettlePix registra a liquidação informada pelo PSP e confirma o agendamento.
+// Notificações repetidas são ignoradas. Cobranças já canceladas, como as expiradas,
+// são devolvidas ao cliente em vez de confirmar o agendamento.
+func (s *PaymentService) SettlePix(notification PixNotification) error {
+	payment, err := s.paymentRepo.FindByIntentID(notification.TxID)
+	if err != nil {
+		return err
+	}
+	if payment.Method != models.PaymentMethodPix {
+		return ErrNotPixPayment
+	}
+	if notification.Amount != payment.Amount {
+		return fmt.Errorf("%w: cobrança %s de %d, recebido %d", ErrPixAmountMismatch, payment.GatewayIntentID, payment.Amount, notification.Amount)
+	}
+
+	return s.markPaid(payment, notification.EndToEndID, notification.PaidAt)
+}
+
+// ExpirePixCharges cancela as cobranças Pix pendentes cuja validade terminou
+func (s *PaymentService) ExpirePixCharges() error {
+	expired, err := s.paymentRepo.GetExpiredPixCharges(s.now())
+	if err != nil {
+		return err
+	}
+
+	for i := range expired {
+		if err := s.cancelIntent(&expired[i]); err != nil && !errors.Is(err, ErrPixChargeNotFound) {
+			log.Printf("Erro ao expirar cobrança Pix %s: %v", expired[i].GatewayIntentID, err)
+		}
+	}
+	return nil
+}
+
+// FindPixCharge busca a cobrança Pix pelo txid
+func (s *PaymentService) FindPixCharge(txID string) (*models.Payment, error) {
+	payment, err := s.paymentRepo.FindByIntentID(txID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Method != models.PaymentMethodPix {
+		return nil, repositories.ErrPaymentNotFound
+	}
+	return payment, nil
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/testutil"
+	"gorm.io/gorm"
+)
+
+// newPaymentService monta o serviço de pagamentos com o gateway e o PSP em memória
+func newPaymentService(db *gorm.DB, gateway PaymentGateway) *PaymentService {
+	paymentRepo := repositories.NewPaymentRepository(db)
+	ledgerRepo := repositories.NewLedgerRepository(db)
+	userRepo := repositories.NewUserRepository(db)
+	appointmentRepo := repositories.NewAppointmentRepository(db, nil)
+	ledger := NewLedgerService(ledgerRepo, paymentRepo, repositories.NewTipRepository(db))
+	commission := NewCommissionService(repositories.NewCommissionRepository(db), userRepo, repositories.NewTipRepository(db))
+	audit := NewAuditService(repositories.NewAppointmentEventRepository(db))
+	promotions := NewPromotionService(repositories.NewPromotionRepository(db), ledgerRepo, userRepo, appointmentRepo, ledger, NewPricingService())
+	return NewPaymentService(paymentRepo, appointmentRepo, repositories.NewTermsRepository(db), gateway,
+		NewFakePixProvider(), PixConfig{MerchantName: "XCLEAN", MerchantCity: "SAO PAULO"}, audit, ledger, commission, promotions)
+}
+
+func TestCardPaymentFlow(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		otherOwner  bool
+		wantErr     error
+		wantStatus  models.PaymentStatus
+		wantAppt    models.AppointmentStatus
+		refund      int64
+		wantRefunds int64
+	}{
+		{"aprovado e reembolsado em parte", "pm_card_visa", false, nil, models.PaymentStatusPartiallyRefunded, models.AppointmentStatusConfirmed, 4000, 4000},
+		{"aprovado e reembolsado por inteiro", "pm_card_visa", false, nil, models.PaymentStatusRefunded, models.AppointmentStatusConfirmed, 10000, 10000},
+		{"recusado", "pm_card_chargeDeclined", false, ErrPaymentDeclined, models.PaymentStatusFailed, models.AppointmentStatusPending, 0, 0},
+		{"cartão de outro cliente", "pm_card_visa", true, ErrPaymentMethodNotOwned, models.PaymentStatusPending, models.AppointmentStatusPending, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			client := testutil.CreateUser(t, db, models.UserTypeClient)
+			provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+			appointment := testutil.CreateAppointment(t, db, models.Appointment{
+				UserID: client.ID, ProviderID: provider.ID, Price: 100,
+				Date: time.Now().UTC().AddDate(0, 0, 3).Truncate(24 * time.Hour),
+			})
+
+			gateway := NewFakePaymentGateway()
+			service := newPaymentService(db, gateway)
+			if tt.otherOwner {
+				other := testutil.CreateUser(t, db, models.UserTypeClient)
+				if _, err := service.SaveMethod(other.ID, tt.method); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			payment, err := service.CreateIntent(appointment)
+			if err != nil || payment.Amount != 10000 || payment.Status != models.PaymentStatusPending {
+				t.Fatalf("CreateIntent = %+v, %v", payment, err)
+			}
+			// Uma nova intenção com o mesmo valor reaproveita a que está em aberto
+			again, err := service.CreateIntent(appointment)
+			if err != nil || again.ID != payment.ID {
+				t.Fatalf("CreateIntent repetido = %+v, %v; want o pagamento %d", again, err, payment.ID)
+			}
+
+			_, err = service.Confirm(payment, tt.method)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Confirm: %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.refund > 0 {
+				if _, err := service.Refund(payment, tt.refund, "ajuste", &provider.ID); err != nil {
+					t.Fatalf("Refund: %v", err)
+				}
+			}
+
+			var stored models.Payment
+			db.First(&stored, payment.ID)
+			var storedAppt models.Appointment
+			db.First(&storedAppt, appointment.ID)
+			if stored.Status != tt.wantStatus || stored.RefundedAmount != tt.wantRefunds || storedAppt.Status != tt.wantAppt {
+				t.Errorf("pagamento %s com %d reembolsado, agendamento %s; want %s, %d, %s",
+					stored.Status, stored.RefundedAmount, storedAppt.Status, tt.wantStatus, tt.wantRefunds, tt.wantAppt)
+			}
+		})
+	}
+}
+
+func TestRefundCannotExceedPaidAmount(t *testing.T) {
+	db := testutil.NewDB(t)
+	client := testutil.CreateUser(t, db, models.UserTypeClient)
+	provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+	appointment := testutil.CreateAppointment(t, db, models.Appointment{
+		UserID: client.ID, ProviderID: provider.ID, Price: 100,
+		Date: time.Now().UTC().AddDate(0, 0, 3).Truncate(24 * time.Hour),
+	})
+	service := newPaymentService(db, NewFakePaymentGateway())
+	payment, err := service.CreateIntent(appointment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Confirm(payment, "pm_card_visa"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.Refund(payment, 6000, "ajuste", &provider.ID); err != nil {
+		t.Fatalf("Refund: %v", err)
+	}
+	if _, err := service.Refund(payment, 6000, "ajuste", &provider.ID); !errors.Is(err, repositories.ErrRefundExceedsAvailable) {
+		t.Errorf("Refund acima do saldo: %v, want %v", err, repositories.ErrRefundExceedsAvailable)
+	}
+}
+
+func TestPaymentSettledAfterCancelIsRefunded(t *testing.T) {
+	db := testutil.NewDB(t)
+	client := testutil.CreateUser(t, db, models.UserTypeClient)
+	provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+	appointment := testutil.CreateAppointment(t, db, models.Appointment{
+		UserID: client.ID, ProviderID: provider.ID, Price: 100,
+		Date: time.Now().UTC().AddDate(0, 0, 3).Truncate(24 * time.Hour),
+	})
+	service := newPaymentService(db, NewFakePaymentGateway())
+	payment, err := service.CreateIntent(appointment)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// O cliente cancela entre a leitura do agendamento pendente e a confirmação
+	cancelled := false
+	err = db.Callback().Query().After("gorm:query").Register("test:cancel_appointment", func(tx *gorm.DB) {
+		if cancelled || tx.Statement.Table != "appointment_terms" {
+			return
+		}
+		cancelled = true
+		err := db.Model(&models.Appointment{}).Where("id = ?", appointment.ID).Update("status", models.AppointmentStatusCancelled).Error
+		if err != nil {
+			t.Error(err)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.Confirm(payment, "pm_card_visa"); err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+
+	var stored models.Payment
+	db.First(&stored, payment.ID)
+	var storedAppt models.Appointment
+	db.First(&storedAppt, appointment.ID)
+	if !cancelled || storedAppt.Status != models.AppointmentStatusCancelled {
+		t.Fatalf("agendamento %s; want cancelado", storedAppt.Status)
+	}
+	if stored.Status != models.PaymentStatusRefunded || stored.RefundedAmount != stored.Amount {
+		t.Errorf("pagamento %s com %d reembolsado; want reembolso total de %d", stored.Status, stored.RefundedAmount, stored.Amount)
+	}
+}
+
+// refundDownGateway recusa reembolsos enquanto down estiver ligado
+type refundDownGateway struct {
+	*FakePaymentGateway
+	down bool
+}
+
+func (g *refundDownGateway) Refund(intentID string, amount int64, reason string) (*GatewayRefund, error) {
+	if g.down {
+		return nil, errGatewayTimeout
+	}
+	return g.FakePaymentGateway.Refund(intentID, amount, reason)
+}
+
+func TestCancellationRefundIsRetried(t *testing.T) {
+	db := testutil.NewDB(t)
+	client := testutil.CreateUser(t, db, models.UserTypeClient)
+	provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+	appointment := testutil.CreateAppointment(t, db, models.Appointment{
+		UserID: client.ID, ProviderID: provider.ID, Price: 100,
+		Date: time.Now().UTC().AddDate(0, 0, 3).Truncate(24 * time.Hour),
+	})
+	gateway := &refundDownGateway{FakePaymentGateway: NewFakePaymentGateway(), down: true}
+	service := newPaymentService(db, gateway)
+	payment, err := service.CreateIntent(appointment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Confirm(payment, "pm_card_visa"); err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+
+	cancelledAt := time.Now().Add(-time.Hour)
+	appointment.Status = models.AppointmentStatusCancelled
+	appointment.CancelledAt = &cancelledAt
+	appointment.CancelledBy = &client.ID
+	if err := service.appointmentRepo.Cancel(appointment); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.RefundCancellation(appointment); !errors.Is(err, ErrRefundFailed) {
+		t.Fatalf("RefundCancellation com o gateway fora: %v, want ErrRefundFailed", err)
+	}
+
+	check := func(wantPending bool, wantStatus models.PaymentStatus) {
+		t.Helper()
+		var stored models.Appointment
+		db.First(&stored, appointment.ID)
+		var storedPayment models.Payment
+		db.First(&storedPayment, payment.ID)
+		if stored.CancellationRefundPending != wantPending || storedPayment.Status != wantStatus {
+			t.Errorf("reembolso pendente %v, pagamento %s; want %v, %s", stored.CancellationRefundPending, storedPayment.Status, wantPending, wantStatus)
+		}
+	}
+	check(true, models.PaymentStatusSucceeded)
+
+	if err := service.RetryCancellationRefunds(); err != nil {
+		t.Fatal(err)
+	}
+	check(true, models.PaymentStatusSucceeded)
+
+	gateway.down = false
+	if err := service.RetryCancellationRefunds(); err != nil {
+		t.Fatal(err)
+	}
+	check(false, models.PaymentStatusRefunded)
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+)
+
+// Nomes dos provedores de pagamento na URL do webhook
+const (
+	GatewayWebhookProvider = "gateway"
+	PixWebhookProvider     = "pix"
+)
+
+const (
+	// Cabeçalhos da assinatura das notificações do gateway de cartão
+	GatewayTimestampHeader = "X-Gateway-Timestamp"
+	GatewaySignatureHeader = "X-Gateway-Signature"
+	// GatewayWebhookTolerance é a diferença máxima aceita entre o horário da assinatura e o do servidor
+	GatewayWebhookTolerance = 5 * time.Minute
+
+	// PixWebhookEventType é o tipo dos eventos de liquidação Pix
+	PixWebhookEventType = "pix.received"
+)
+
+// Tipos de evento do gateway de cartão
+const (
+	GatewayEventPaymentSucceeded = "payment_intent.succeeded"
+	GatewayEventPaymentFailed    = "payment_intent.payment_failed"
+	GatewayEventRefunded         = "charge.refunded"
+	GatewayEventChargeback       = "charge.dispute.created"
+)
+
+var errRefundInProgress = errors.New("reembolso aguardando resposta do gateway")
+
+// GatewayEvent é uma notificação do gateway de cartão
+type GatewayEvent struct {
+	ID      string           `json:"id"`
+	Type    string           `json:"type"`
+	Created int64            `json:"created"` // Segundos desde a época Unix
+	Data    GatewayEventData `json:"data"`
+}
+
+// GatewayEventData são os dados do pagamento afetado pelo evento
+type GatewayEventData struct {
+	PaymentIntent string `json:"payment_intent"`
+	Amount        int64  `json:"amount"` // Em centavos
+	RefundID      string `json:"refund_id,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+// GatewayWebhookSource recebe as notificações assinadas do gateway de cartão
+type GatewayWebhookSource struct {
+	secret   string
+	payments *PaymentService
+}
+
+func NewGatewayWebhookSource(secret string, payments *PaymentService) *GatewayWebhookSource {
+	return &GatewayWebhookSource{
+		secret:   secret,
+		payments: payments,
+	}
+}
+
+func (g *GatewayWebhookSource) Verify(header http.Header, body []byte, now time.Time) error {
+	return VerifyWebhookSignature(g.secret, header.Get(GatewayTimestampHeader), header.Get(GatewaySignatureHeader), body, now, GatewayWebhookTolerance)
+}
+
+func (g *GatewayWebhookSource) Signature(header http.Header) string {
+	return header.Get(GatewaySignatureHeader)
+}
+
+func (g *GatewayWebhookSource) Parse(body []byte) ([]WebhookEventInput, error) {
+	var event GatewayEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+	if event.ID == "" || event.Type == "" || event.Data.PaymentIntent == "" {
+		return nil, fmt.Errorf("evento sem id, tipo ou intenção de pagamento")
+	}
+	return []WebhookEventInput{{ID: event.ID, Type: event.Type, Payload: body}}, nil
+}
+
+func (g *GatewayWebhookSource) Process(stored *models.WebhookEvent) error {
+	var event GatewayEvent
+	if err := json.Unmarshal([]byte(stored.Payload), &event); err != nil {
+		return fmt.Errorf("%w: %v", ErrWebhookEventIgnored, err)
+	}
+	return g.payments.HandleGatewayEvent(event)
+}
+
+// PixWebhookSource recebe as notificações de liquidação do PSP Pix. Cada pagamento da
+// notificação vira um evento, identificado pelo endToEndId.
+type PixWebhookSource struct {
+	secret   string
+	payments *PaymentService
+}
+
+func NewPixWebhookSource(secret string, payments *PaymentService) *PixWebhookSource {
+	return &PixWebhookSource{
+		secret:   secret,
+		payments: payments,
+	}
+}
+
+func (p *PixWebhookSource) Verify(header http.Header, body []byte, now time.Time) error {
+	return VerifyPixWebhook(p.secret, header.Get(PixTimestampHeader), header.Get(PixSignatureHeader), body, now)
+}
+
+func (p *PixWebhookSource) Signature(header http.Header) string {
+	return header.Get(PixSignatureHeader)
+}
+
+func (p *PixWebhookSource) Parse(body []byte) ([]WebhookEventInput, error) {
+	notifications, err := ParsePixWebhook(body)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs := make([]WebhookEventInput, 0, len(notifications))
+	for _, notification := range notifications {
+		payload, err := json.Marshal(notification)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, WebhookEventInput{ID: notification.EndToEndID, Type: PixWebhookEventType, Payload: payload})
+	}
+	return inputs, nil
+}
+
+func (p *PixWebhookSource) Process(stored *models.WebhookEvent) error {
+	var notification PixNotification
+	if err := json.Unmarshal([]byte(stored.Payload), &notification); err != nil {
+		return fmt.Errorf("%w: %v", ErrWebhookEventIgnored, err)
+	}
+
+	err := p.payments.SettlePix(notification)
+	if errors.Is(err, repositories.ErrPaymentNotFound) || errors.Is(err, ErrNotPixPayment) || errors.Is(err, ErrPixAmountMismatch) {
+		// Reprocessar não muda o resultado; fica registrado para conciliação manual
+		return fmt.Errorf("%w: %v", ErrWebhookEventIgnored, err)
+	}
+	return err
+}
+
+// HandleGatewayEvent aplica ao pagamento uma notificação do gateway de cartão. Eventos
+// repetidos ou já refletidos pelo fluxo síncrono não têm efeito.
+func (s *PaymentService) HandleGatewayEvent(event GatewayEvent) error {
+	payment, err := s.paymentRepo.FindByIntentID(event.Data.PaymentIntent)
+	if errors.Is(err, repositories.ErrPaymentNotFound) {
+		return fmt.Errorf("%w: %v", ErrWebhookEventIgnored, err)
+	}
+	if err != nil {
+		return err
+	}
+	if payment.Method != models.PaymentMethodCard {
+		return fmt.Errorf("%w: pagamento %d não é de cartão", ErrWebhookEventIgnored, payment.ID)
+	}
+
+	switch event.Type {
+	case GatewayEventPaymentSucceeded:
+		return s.gatewayPaymentSucceeded(payment, event)
+	case GatewayEventPaymentFailed:
+		_, err := s.paymentRepo.MarkFailed(payment, event.Data.Reason)
+		return err
+	case GatewayEventRefunded:
+		return s.gatewayRefunded(payment, event)
+	case GatewayEventChargeback:
+		return s.gatewayChargeback(payment, event)
+	}
+	return fmt.Errorf("%w: tipo %q não tratado", ErrWebhookEventIgnored, event.Type)
+}
+
+// gatewayPaymentSucceeded liquida o pagamento cuja confirmação não chegou pelo fluxo síncrono
+func (s *PaymentService) gatewayPaymentSucceeded(payment *models.Payment, event GatewayEvent) error {
+	if event.Data.Amount != payment.Amount {
+		return fmt.Errorf("%w: intenção %s de %d, capturado %d", ErrWebhookEventIgnored, payment.GatewayIntentID, payment.Amount, event.Data.Amount)
+	}
+
+	paidAt := s.now()
+	if event.Created > 0 {
+		paidAt = time.Unix(event.Created, 0)
+	}
+	return s.markPaid(payment, "", paidAt)
+}
+
+// gatewayRefunded registra um reembolso feito diretamente no gateway, como pelo painel do
+// provedor. Reembolsos pedidos pela plataforma já estão registrados com o mesmo ID.
+func (s *PaymentService) gatewayRefunded(payment *models.Payment, event GatewayEvent) error {
+	if event.Data.RefundID == "" || event.Data.Amount <= 0 {
+		return fmt.Errorf("%w: reembolso sem ID ou valor", ErrWebhookEventIgnored)
+	}
+
+	_, err := s.paymentRepo.FindRefundByGatewayID(event.Data.RefundID)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, repositories.ErrRefundNotFound) {
+		return err
+	}
+
+	// O reembolso pode ser o que a plataforma acabou de pedir e ainda não gravou; a nova
+	// tentativa o encontra registrado
+	pending, err := s.paymentRepo.HasPendingRefund(payment.ID)
+	if err != nil {
+		return err
+	}
+	if pending {
+		return errRefundInProgress
+	}
+
+	refund := &models.Refund{Amount: event.Data.Amount, Reason: "reembolso feito no gateway"}
+	if _, err := s.paymentRepo.ReserveRefund(payment.ID, refund); err != nil {
+		if errors.Is(err, repositories.ErrEscrowReleased) || errors.Is(err, repositories.ErrRefundExceedsAvailable) {
+			return fmt.Errorf("%w: %v", ErrWebhookEventIgnored, err)
+		}
+		return err
+	}
+	ledger, err := s.ledger.RefundTransactions(payment, refund)
+	if err != nil {
+		s.releaseRefund(refund)
+		return err
+	}
+	return s.paymentRepo.CompleteRefund(refund, event.Data.RefundID, ledger, s.refundedEvent(payment, refund, nil))
+}
+
+// gatewayChargeback lança o valor contestado pelo cliente no emissor do cartão e congela o
+// valor retido. Se o valor já foi repassado, a contestação fica no histórico para o suporte.
+func (s *PaymentService) gatewayChargeback(payment *models.Payment, event GatewayEvent) error {
+	if event.Data.Amount <= 0 || event.Data.Amount > payment.Amount {
+		return fmt.Errorf("%w: contestação de %d no pagamento de %d", ErrWebhookEventIgnored, event.Data.Amount, payment.Amount)
+	}
+
+	ledger, err := s.ledger.ChargebackTransactions(payment, event.Data.Amount)
+	if err != nil {
+		return err
+	}
+	chargeback := s.auditService.Event(payment.AppointmentID, nil, models.AppointmentEventChargeback, payment.EscrowStatus, map[string]interface{}{
+		"payment_id": payment.ID,
+		"event_id":   event.ID,
+		"amount":     event.Data.Amount,
+		"reason":     event.Data.Reason,
+	})
+	_, err = s.paymentRepo.Chargeback(payment.ID, event.Data.Amount, ledger, chargeback, s.escrowFrozenEvent(payment))
+	return err
+}
@@ -0,0 +1,376 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+)
+
+const (
+	// MinPayoutAmount é o menor saque aceito, em centavos
+	MinPayoutAmount = 1000
+	// MaxPayoutAttempts é o número de envios ao provedor antes de o saque ser dado como falho
+	MaxPayoutAttempts = 5
+	// PayoutRetryDelay é o intervalo antes da segunda tentativa; dobra a cada nova falha
+	PayoutRetryDelay = 15 * time.Minute
+)
+
+var (
+	ErrInvalidPayoutDestination = errors.New("dados de recebimento inválidos")
+)
+
+var (
+	onlyDigits    = regexp.MustCompile(`^[0-9]+$`)
+	pixRandomKey  = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	pixPhoneKey   = regexp.MustCompile(`^\+55[0-9]{10,11}$`)
+	bankAccountNo = regexp.MustCompile(`^[0-9]{1,12}-?[0-9xX]?$`)
+)
+
+// ProviderWallet resume o saldo da prestadora, em centavos
+type ProviderWallet struct {
+	Currency  string `json:"currency"`
+	Pending   int64  `json:"pending"`    // Em custódia, aguardando a conclusão dos atendimentos
+	Available int64  `json:"available"`  // Liberado para saque
+	InTransit int64  `json:"in_transit"` // Saques solicitados ainda não pagos
+	PaidOut   int64  `json:"paid_out"`   // Total já sacado
+
+	AutoPayoutEnabled bool                      `json:"auto_payout_enabled"`
+	AutoPayoutMinimum int64                     `json:"auto_payout_minimum"`
+	Destination       *models.PayoutDestination `json:"destination,omitempty"`
+}
+
+// PayoutService controla os saques das prestadoras pelo PayoutProvider. O valor é
+// descontado do saldo no razão quando o saque é solicitado e devolvido se ele falhar.
+type PayoutService struct {
+	payoutRepo *repositories.PayoutRepository
+	ledgerRepo *repositories.LedgerRepository
+	userRepo   *repositories.UserRepository
+	ledger     *LedgerService
+	provider   PayoutProvider
+	now        func() time.Time
+}
+
+func NewPayoutService(
+	payoutRepo *repositories.PayoutRepository,
+	ledgerRepo *repositories.LedgerRepository,
+	userRepo *repositories.UserRepository,
+	ledger *LedgerService,
+	provider PayoutProvider,
+) *PayoutService {
+	return &PayoutService{
+		payoutRepo: payoutRepo,
+		ledgerRepo: ledgerRepo,
+		userRepo:   userRepo,
+		ledger:     ledger,
+		provider:   provider,
+		now:        time.Now,
+	}
+}
+
+// Wallet retorna os saldos da prestadora a partir do razão e dos saques
+func (s *PayoutService) Wallet(providerID uint) (*ProviderWallet, error) {
+	account, err := s.ledger.UserBalance(models.LedgerAccountProvider, providerID)
+	if err != nil {
+		return nil, err
+	}
+	escrow, err := s.ledgerRepo.ProviderEscrowBalance(providerID)
+	if err != nil {
+		return nil, err
+	}
+	inTransit, paidOut, err := s.payoutRepo.Totals(providerID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Saldos credores são negativos no razão
+	wallet := &ProviderWallet{
+		Currency:  account.Currency,
+		Pending:   -escrow,
+		Available: -account.Balance,
+		InTransit: inTransit,
+		PaidOut:   paidOut,
+	}
+
+	if profile, err := s.userRepo.GetProviderProfile(providerID); err == nil {
+		wallet.AutoPayoutEnabled = profile.AutoPayoutEnabled
+		wallet.AutoPayoutMinimum = autoPayoutMinimum(profile)
+	}
+	if destination, err := s.payoutRepo.FindDestination(providerID); err == nil {
+		wallet.Destination = destination
+	}
+	return wallet, nil
+}
+
+// SaveDestination valida e cadastra a conta bancária ou chave Pix da prestadora
+func (s *PayoutService) SaveDestination(destination *models.PayoutDestination) error {
+	if err := normalizePayoutDestination(destination); err != nil {
+		return err
+	}
+	return s.payoutRepo.SaveDestination(destination)
+}
+
+// RequestPayout saca amount centavos do saldo disponível, ou todo o saldo quando amount é
+// zero, e envia o saque ao provedor. Falhas no envio são tentadas novamente pelo ProcessPayouts.
+func (s *PayoutService) RequestPayout(providerID uint, amount int64, automatic bool) (*models.Payout, error) {
+	destination, err := s.payoutRepo.FindDestination(providerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if amount == 0 {
+		balance, err := s.ledger.UserBalance(models.LedgerAccountProvider, providerID)
+		if err != nil {
+			return nil, err
+		}
+		amount = -balance.Balance
+	}
+
+	debit, account, err := s.ledger.PayoutDebit(providerID, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	payout := &models.Payout{
+		ProviderID:       providerID,
+		Amount:           amount,
+		Currency:         models.PaymentCurrency,
+		Status:           models.PayoutStatusPending,
+		Automatic:        automatic,
+		DestinationID:    destination.ID,
+		DestinationType:  destination.Type,
+		DestinationLabel: destination.Label(),
+		NextAttemptAt:    &now,
+	}
+	if err := s.payoutRepo.CreateWithDebit(payout, account.ID, MinPayoutAmount, debit); err != nil {
+		return nil, err
+	}
+
+	s.submit(payout, destination)
+	return payout, nil
+}
+
+// History retorna os saques da prestadora
+func (s *PayoutService) History(providerID uint) ([]models.Payout, error) {
+	return s.payoutRepo.GetByProvider(providerID)
+}
+
+// ProcessPayouts reenvia os saques pendentes cuja tentativa venceu e acompanha os que
+// estão em processamento no provedor. Saques que o provedor não encontra são dados como
+// falhos e estornados.
+func (s *PayoutService) ProcessPayouts() error {
+	due, err := s.payoutRepo.GetDueRetries(s.now())
+	if err != nil {
+		return err
+	}
+	for i := range due {
+		destination, err := s.payoutRepo.FindDestination(due[i].ProviderID)
+		if err != nil {
+			log.Printf("Erro ao buscar destino do saque %d: %v", due[i].ID, err)
+			continue
+		}
+		s.submit(&due[i], destination)
+	}
+
+	processing, err := s.payoutRepo.GetProcessing()
+	if err != nil {
+		return err
+	}
+	for i := range processing {
+		transfer, err := s.provider.GetTransfer(processing[i].TransferID)
+		if errors.Is(err, ErrPayoutTransferNotFound) {
+			// O provedor não conhece a transferência: o dinheiro não saiu e volta ao saldo
+			processing[i].FailureReason = err.Error()
+			s.fail(&processing[i])
+			continue
+		}
+		if err != nil {
+			log.Printf("Erro ao consultar saque %d: %v", processing[i].ID, err)
+			continue
+		}
+		s.apply(&processing[i], transfer)
+	}
+	return nil
+}
+
+// RunAutoPayouts saca o saldo disponível das prestadoras com repasse automático que
+// atingiram o valor mínimo configurado
+func (s *PayoutService) RunAutoPayouts() error {
+	profiles, err := s.payoutRepo.GetAutoPayoutProfiles()
+	if err != nil {
+		return err
+	}
+
+	for i := range profiles {
+		balance, err := s.ledger.UserBalance(models.LedgerAccountProvider, profiles[i].UserID)
+		if err != nil {
+			log.Printf("Erro ao consultar saldo da prestadora %d: %v", profiles[i].UserID, err)
+			continue
+		}
+		available := -balance.Balance
+		if available < autoPayoutMinimum(&profiles[i]) {
+			continue
+		}
+		if _, err := s.RequestPayout(profiles[i].UserID, available, true); err != nil {
+			log.Printf("Erro no repasse automático da prestadora %d: %v", profiles[i].UserID, err)
+		}
+	}
+	return nil
+}
+
+// submit envia o saque pendente ao provedor. Falhas temporárias ficam agendadas para nova
+// tentativa, com intervalo crescente, até MaxPayoutAttempts.
+func (s *PayoutService) submit(payout *models.Payout, destination *models.PayoutDestination) {
+	retryAt := s.now().Add(PayoutRetryDelay << min(payout.Attempts, 10))
+	claimed, err := s.payoutRepo.ClaimAttempt(payout, retryAt)
+	if err != nil || !claimed {
+		if err != nil {
+			log.Printf("Erro ao reservar envio do saque %d: %v", payout.ID, err)
+		}
+		return
+	}
+
+	transfer, err := s.provider.CreateTransfer(PayoutTransferRequest{
+		Reference:   fmt.Sprintf("payout_%d", payout.ID),
+		Amount:      payout.Amount,
+		Currency:    payout.Currency,
+		Destination: destination,
+	})
+	if err != nil {
+		payout.FailureReason = err.Error()
+		if payout.Attempts >= MaxPayoutAttempts {
+			s.fail(payout)
+			return
+		}
+		if err := s.payoutRepo.UpdateStatus(payout); err != nil {
+			log.Printf("Erro ao registrar falha do saque %d: %v", payout.ID, err)
+		}
+		return
+	}
+
+	s.apply(payout, transfer)
+}
+
+// apply registra o estado da transferência informado pelo provedor
+func (s *PayoutService) apply(payout *models.Payout, transfer *PayoutTransfer) {
+	payout.TransferID = transfer.ID
+	payout.NextAttemptAt = nil
+
+	switch transfer.Status {
+	case PayoutTransferProcessing:
+		if payout.Status == models.PayoutStatusProcessing {
+			return
+		}
+		payout.Status = models.PayoutStatusProcessing
+		payout.FailureReason = ""
+	case PayoutTransferPaid:
+		now := s.now()
+		payout.Status = models.PayoutStatusPaid
+		payout.FailureReason = ""
+		payout.PaidAt = &now
+	case PayoutTransferFailed:
+		payout.FailureReason = transfer.FailureReason
+		s.fail(payout)
+		return
+	default:
+		log.Printf("Status inesperado do saque %d no provedor: %s", payout.ID, transfer.Status)
+		return
+	}
+
+	if err := s.payoutRepo.UpdateStatus(payout); err != nil {
+		log.Printf("Erro ao atualizar saque %d: %v", payout.ID, err)
+	}
+}
+
+// fail encerra o saque e devolve o valor ao saldo disponível da prestadora
+func (s *PayoutService) fail(payout *models.Payout) {
+	reversal, err := s.ledger.PayoutReversal(payout)
+	if err == nil {
+		_, err = s.payoutRepo.Fail(payout, reversal)
+	}
+	if err != nil {
+		log.Printf("Erro ao estornar saque %d: %v", payout.ID, err)
+	}
+}
+
+// autoPayoutMinimum retorna o mínimo do repasse automático, nunca abaixo de MinPayoutAmount
+func autoPayoutMinimum(profile *models.ProviderProfile) int64 {
+	return max(profile.AutoPayoutMinimum, MinPayoutAmount)
+}
+
+// normalizePayoutDestination valida os dados de recebimento e remove a formatação de
+// documentos e chaves
+func normalizePayoutDestination(d *models.PayoutDestination) error {
+	d.HolderName = strings.TrimSpace(d.HolderName)
+	d.HolderDocument = stripDocument(d.HolderDocument)
+	if d.HolderName == "" {
+		return fmt.Errorf("%w: informe o nome do titular", ErrInvalidPayoutDestination)
+	}
+	if !onlyDigits.MatchString(d.HolderDocument) || (len(d.HolderDocument) != 11 && len(d.HolderDocument) != 14) {
+		return fmt.Errorf("%w: CPF ou CNPJ do titular", ErrInvalidPayoutDestination)
+	}
+
+	switch d.Type {
+	case models.PayoutDestinationPixKey:
+		d.BankCode, d.Branch, d.AccountNumber, d.AccountType = "", "", "", ""
+		return normalizePixKey(d)
+	case models.PayoutDestinationBankAccount:
+		d.PixKeyType, d.PixKey = "", ""
+		d.AccountNumber = strings.TrimSpace(d.AccountNumber)
+		if len(d.BankCode) != 3 || !onlyDigits.MatchString(d.BankCode) {
+			return fmt.Errorf("%w: código do banco deve ter 3 dígitos", ErrInvalidPayoutDestination)
+		}
+		if len(d.Branch) == 0 || len(d.Branch) > 5 || !onlyDigits.MatchString(d.Branch) {
+			return fmt.Errorf("%w: agência", ErrInvalidPayoutDestination)
+		}
+		if !bankAccountNo.MatchString(d.AccountNumber) {
+			return fmt.Errorf("%w: número da conta", ErrInvalidPayoutDestination)
+		}
+		if d.AccountType != models.BankAccountChecking && d.AccountType != models.BankAccountSavings {
+			return fmt.Errorf("%w: tipo de conta", ErrInvalidPayoutDestination)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: tipo de destino", ErrInvalidPayoutDestination)
+}
+
+// normalizePixKey valida a chave Pix conforme o tipo informado
+func normalizePixKey(d *models.PayoutDestination) error {
+	key := strings.TrimSpace(d.PixKey)
+	valid := false
+	switch d.PixKeyType {
+	case models.PixKeyCPF:
+		key = stripDocument(key)
+		valid = len(key) == 11 && onlyDigits.MatchString(key)
+	case models.PixKeyCNPJ:
+		key = stripDocument(key)
+		valid = len(key) == 14 && onlyDigits.MatchString(key)
+	case models.PixKeyEmail:
+		key = strings.ToLower(key)
+		_, err := mail.ParseAddress(key)
+		valid = err == nil && len(key) <= 77
+	case models.PixKeyPhone:
+		key = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "").Replace(key)
+		valid = pixPhoneKey.MatchString(key)
+	case models.PixKeyRandom:
+		key = strings.ToLower(key)
+		valid = pixRandomKey.MatchString(key)
+	}
+	if !valid {
+		return fmt.Errorf("%w: chave Pix", ErrInvalidPayoutDestination)
+	}
+	d.PixKey = key
+	return nil
+}
+
+// stripDocument remove pontos, traços e barras de CPF e CNPJ
+func stripDocument(value string) string {
+	return strings.NewReplacer(".", "", "-", "", "/", "", " ", "").Replace(value)
+}
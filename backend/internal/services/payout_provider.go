@@ -0,0 +1,106 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/xclean/backend/internal/models"
+)
+
+var (
+	ErrPayoutTransferNotFound = errors.New("transferência não encontrada no provedor de repasses")
+	ErrPayoutUnavailable      = errors.New("provedor de repasses indisponível")
+)
+
+// PayoutTransferStatus é o estado de uma transferência no provedor de repasses
+type PayoutTransferStatus string
+
+const (
+	PayoutTransferProcessing PayoutTransferStatus = "processing"
+	PayoutTransferPaid       PayoutTransferStatus = "paid"
+	PayoutTransferFailed     PayoutTransferStatus = "failed"
+)
+
+// PayoutTransferRequest descreve a transferência a ser enviada à prestadora.
+// Reference identifica o saque e torna o envio idempotente no provedor.
+type PayoutTransferRequest struct {
+	Reference   string
+	Amount      int64 // Em centavos
+	Currency    string
+	Destination *models.PayoutDestination
+}
+
+// PayoutTransfer é uma transferência no provedor de repasses
+type PayoutTransfer struct {
+	ID            string
+	Status        PayoutTransferStatus
+	FailureReason string
+}
+
+// PayoutProvider envia os saques das prestadoras por TED ou Pix. Erros indicam falhas
+// temporárias, que são tentadas novamente; recusas definitivas voltam com status failed.
+// FakePayoutProvider o implementa em memória.
+type PayoutProvider interface {
+	CreateTransfer(req PayoutTransferRequest) (*PayoutTransfer, error)
+	GetTransfer(transferID string) (*PayoutTransfer, error)
+}
+
+// FakePayoutProvider é um provedor de repasses em memória para desenvolvimento e testes.
+// As transferências ficam em processamento até a primeira consulta, quando são pagas.
+// Destinos com "invalid" na chave Pix ou na conta são recusados, e com "unavailable"
+// simulam indisponibilidade do provedor.
+type FakePayoutProvider struct {
+	mu          sync.Mutex
+	transfers   map[string]*PayoutTransfer
+	byReference map[string]string
+}
+
+func NewFakePayoutProvider() *FakePayoutProvider {
+	return &FakePayoutProvider{
+		transfers:   map[string]*PayoutTransfer{},
+		byReference: map[string]string{},
+	}
+}
+
+func (p *FakePayoutProvider) CreateTransfer(req PayoutTransferRequest) (*PayoutTransfer, error) {
+	target := req.Destination.PixKey + req.Destination.AccountNumber
+	if strings.Contains(target, "unavailable") {
+		return nil, ErrPayoutUnavailable
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if id, ok := p.byReference[req.Reference]; ok {
+		transfer := *p.transfers[id]
+		return &transfer, nil
+	}
+
+	transfer := &PayoutTransfer{ID: "tr_fake_" + fakeGatewayID(), Status: PayoutTransferProcessing}
+	if strings.Contains(target, "invalid") {
+		transfer.Status = PayoutTransferFailed
+		transfer.FailureReason = "destino inválido"
+	}
+	p.transfers[transfer.ID] = transfer
+	p.byReference[req.Reference] = transfer.ID
+
+	result := *transfer
+	return &result, nil
+}
+
+func (p *FakePayoutProvider) GetTransfer(transferID string) (*PayoutTransfer, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	transfer, ok := p.transfers[transferID]
+	if !ok {
+		return nil, ErrPayoutTransferNotFound
+	}
+	if transfer.Status == PayoutTransferProcessing {
+		transfer.Status = PayoutTransferPaid
+	}
+
+	result := *transfer
+	return &result, nil
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/testutil"
+	"gorm.io/gorm"
+)
+
+// newPayoutService monta o serviço de saques com o provedor em memória e credita amount
+// centavos no saldo disponível da prestadora
+func newPayoutService(t *testing.T, db *gorm.DB, providerID uint, amount int64) *PayoutService {
+	t.Helper()
+	ledgerRepo := repositories.NewLedgerRepository(db)
+	ledger := NewLedgerService(ledgerRepo, repositories.NewPaymentRepository(db), repositories.NewTipRepository(db))
+	service := NewPayoutService(repositories.NewPayoutRepository(db), ledgerRepo, repositories.NewUserRepository(db), ledger, NewFakePayoutProvider())
+
+	testutil.PostLedger(t, db, models.LedgerTransactionEscrowRelease, "escrow_release:test",
+		testutil.LedgerEntry{Type: models.LedgerAccountEscrow, Amount: amount},
+		testutil.LedgerEntry{Type: models.LedgerAccountProvider, OwnerID: &providerID, Amount: -amount})
+	return service
+}
+
+func TestPayoutLifecycle(t *testing.T) {
+	tests := []struct {
+		name          string
+		pixKey        string
+		forget        bool // o provedor perde a transferência enquanto ela está em processamento
+		wantStatus    models.PayoutStatus
+		wantAttempts  int
+		wantAvailable int64
+		wantPaidOut   int64
+	}{
+		{"pago na consulta ao provedor", "prestadora@example.com", false, models.PayoutStatusPaid, 1, 0, 5000},
+		{"recusado pelo provedor", "invalid@example.com", false, models.PayoutStatusFailed, 1, 5000, 0},
+		{"provedor indisponível até a última tentativa", "unavailable@example.com", false, models.PayoutStatusFailed, MaxPayoutAttempts, 5000, 0},
+		{"transferência desconhecida pelo provedor", "prestadora@example.com", true, models.PayoutStatusFailed, 1, 5000, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+			service := newPayoutService(t, db, provider.ID, 5000)
+			payoutRepo := service.payoutRepo
+			clock := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+			service.now = func() time.Time { return clock }
+
+			err := service.SaveDestination(&models.PayoutDestination{
+				ProviderID: provider.ID, Type: models.PayoutDestinationPixKey, PixKeyType: models.PixKeyEmail,
+				PixKey: tt.pixKey, HolderName: "Prestadora", HolderDocument: "123.456.789-09",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			payout, err := service.RequestPayout(provider.ID, 0, false)
+			if err != nil {
+				t.Fatalf("RequestPayout: %v", err)
+			}
+			if tt.forget {
+				service.provider = NewFakePayoutProvider()
+			}
+
+			// Cada nova tentativa só sai depois do intervalo, que dobra a cada falha
+			for payout.Status == models.PayoutStatusPending {
+				delay := payout.NextAttemptAt.Sub(clock)
+				if want := PayoutRetryDelay << (payout.Attempts - 1); delay != want {
+					t.Fatalf("tentativa %d reagendada em %s; want %s", payout.Attempts, delay, want)
+				}
+				attempts := payout.Attempts
+				clock = payout.NextAttemptAt.Add(-time.Minute)
+				if err := service.ProcessPayouts(); err != nil {
+					t.Fatal(err)
+				}
+				if payout, _ = payoutRepo.FindByID(payout.ID); payout.Attempts != attempts {
+					t.Fatalf("saque reenviado antes do intervalo: %d tentativas", payout.Attempts)
+				}
+				clock = clock.Add(time.Minute)
+				if err := service.ProcessPayouts(); err != nil {
+					t.Fatal(err)
+				}
+				payout, _ = payoutRepo.FindByID(payout.ID)
+			}
+			if payout.Status == models.PayoutStatusProcessing {
+				if err := service.ProcessPayouts(); err != nil {
+					t.Fatal(err)
+				}
+				payout, _ = payoutRepo.FindByID(payout.ID)
+			}
+
+			wallet, err := service.Wallet(provider.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if payout.Status != tt.wantStatus || payout.Attempts != tt.wantAttempts {
+				t.Errorf("saque %s após %d tentativas; want %s após %d", payout.Status, payout.Attempts, tt.wantStatus, tt.wantAttempts)
+			}
+			if wallet.Available != tt.wantAvailable || wallet.PaidOut != tt.wantPaidOut || wallet.InTransit != 0 {
+				t.Errorf("carteira disponível %d, sacado %d, em trânsito %d; want %d, %d, 0",
+					wallet.Available, wallet.PaidOut, wallet.InTransit, tt.wantAvailable, tt.wantPaidOut)
+			}
+		})
+	}
+}
+
+func TestRequestPayoutChecksBalance(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  int64
+		wantErr error
+	}{
+		{"abaixo do mínimo", MinPayoutAmount - 1, repositories.ErrPayoutBelowMinimum},
+		{"acima do saldo", 5001, repositories.ErrInsufficientBalance},
+		{"parte do saldo", 2000, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+			service := newPayoutService(t, db, provider.ID, 5000)
+
+			if _, err := service.RequestPayout(provider.ID, tt.amount, false); !errors.Is(err, repositories.ErrPayoutDestinationNotFound) {
+				t.Fatalf("RequestPayout sem destino: %v, want %v", err, repositories.ErrPayoutDestinationNotFound)
+			}
+			err := service.SaveDestination(&models.PayoutDestination{
+				ProviderID: provider.ID, Type: models.PayoutDestinationBankAccount, BankCode: "341", Branch: "0001",
+				AccountNumber: "12345-6", AccountType: models.BankAccountChecking, HolderName: "Prestadora", HolderDocument: "12345678909",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := service.RequestPayout(provider.ID, tt.amount, false); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RequestPayout: %v, want %v", err, tt.wantErr)
+			}
+			wallet, err := service.Wallet(provider.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantAvailable := int64(5000)
+			if tt.wantErr == nil {
+				wantAvailable -= tt.amount
+			}
+			if wallet.Available != wantAvailable {
+				t.Errorf("disponível %d, want %d", wallet.Available, wantAvailable)
+			}
+		})
+	}
+}
+
+func TestNormalizePayoutDestination(t *testing.T) {
+	tests := []struct {
+		name    string
+		keyType models.PixKeyType
+		key     string
+		wantKey string
+		valid   bool
+	}{
+		{"CPF formatado", models.PixKeyCPF, "123.456.789-09", "12345678909", true},
+		{"CNPJ curto", models.PixKeyCNPJ, "12.345.678/0001", "", false},
+		{"e-mail em maiúsculas", models.PixKeyEmail, " Prestadora@Example.com ", "prestadora@example.com", true},
+		{"telefone formatado", models.PixKeyPhone, "+55 (11) 91234-5678", "+5511912345678", true},
+		{"telefone sem DDI", models.PixKeyPhone, "11912345678", "", false},
+		{"chave aleatória", models.PixKeyRandom, "123E4567-E89B-12D3-A456-426614174000", "123e4567-e89b-12d3-a456-426614174000", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			destination := &models.PayoutDestination{
+				Type: models.PayoutDestinationPixKey, PixKeyType: tt.keyType, PixKey: tt.key,
+				HolderName: " Prestadora ", HolderDocument: "123.456.789-09",
+			}
+			err := normalizePayoutDestination(destination)
+			if tt.valid != (err == nil) {
+				t.Fatalf("normalizePayoutDestination: %v; want válido = %v", err, tt.valid)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidPayoutDestination) {
+				t.Errorf("erro %v, want %v", err, ErrInvalidPayoutDestination)
+			}
+			if tt.valid && (destination.PixKey != tt.wantKey || destination.HolderDocument != "12345678909" || destination.HolderName != "Prestadora") {
+				t.Errorf("destino %+v; want chave %q", destination, tt.wantKey)
+			}
+		})
+	}
+}
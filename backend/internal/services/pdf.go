@@ -0,0 +1,178 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Página A4 em pontos e margens dos documentos
+const (
+	pdfPageWidth  = 595.0
+	pdfPageHeight = 842.0
+	pdfMargin     = 50.0
+	pdfLineGap    = 4.0
+)
+
+// PDFDocument monta um PDF simples de texto, com as fontes padrão Helvetica e Helvetica-Bold,
+// sem dependências externas. O texto é convertido para WinAnsiEncoding, que cobre os
+// acentos do português. A saída depende apenas do conteúdo, sem data de geração.
+type PDFDocument struct {
+	title string
+	pages []*bytes.Buffer
+	y     float64
+}
+
+// NewPDFDocument cria um documento com o título informado nos metadados
+func NewPDFDocument(title string) *PDFDocument {
+	d := &PDFDocument{title: title}
+	d.newPage()
+	return d
+}
+
+func (d *PDFDocument) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = pdfPageHeight - pdfMargin
+}
+
+// ensure inicia uma nova página se não houver espaço para height pontos
+func (d *PDFDocument) ensure(height float64) {
+	if d.y-height < pdfMargin {
+		d.newPage()
+	}
+}
+
+// text escreve um trecho na linha atual, na posição x
+func (d *PDFDocument) text(x float64, size float64, bold bool, value string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.pages[len(d.pages)-1], "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, d.y, pdfEscape(value))
+}
+
+// Heading escreve um título em negrito
+func (d *PDFDocument) Heading(value string, size float64) {
+	d.ensure(size + pdfLineGap)
+	d.y -= size
+	d.text(pdfMargin, size, true, value)
+	d.y -= pdfLineGap * 2
+}
+
+// Paragraph escreve uma linha de texto
+func (d *PDFDocument) Paragraph(value string, size float64) {
+	d.ensure(size + pdfLineGap)
+	d.y -= size
+	d.text(pdfMargin, size, false, value)
+	d.y -= pdfLineGap
+}
+
+// Field escreve um rótulo em negrito seguido do valor
+func (d *PDFDocument) Field(label, value string) {
+	const size = 10
+	d.ensure(size + pdfLineGap)
+	d.y -= size
+	d.text(pdfMargin, size, true, label)
+	d.text(pdfMargin+170, size, false, value)
+	d.y -= pdfLineGap
+}
+
+// Table escreve uma tabela com as colunas nas posições columns, relativas à margem.
+// O cabeçalho é repetido a cada nova página.
+func (d *PDFDocument) Table(columns []float64, header []string, rows [][]string) {
+	const size = 8
+	writeRow := func(cells []string, bold bool) {
+		d.y -= size
+		for i, cell := range cells {
+			if i < len(columns) {
+				d.text(pdfMargin+columns[i], size, bold, cell)
+			}
+		}
+		d.y -= pdfLineGap
+	}
+
+	d.ensure(2 * (size + pdfLineGap))
+	writeRow(header, true)
+	for _, row := range rows {
+		if d.y-(size+pdfLineGap) < pdfMargin {
+			d.newPage()
+			writeRow(header, true)
+		}
+		writeRow(row, false)
+	}
+	d.y -= pdfLineGap
+}
+
+// Space avança height pontos na página
+func (d *PDFDocument) Space(height float64) {
+	d.y -= height
+}
+
+// Bytes serializa o documento no formato PDF 1.4
+func (d *PDFDocument) Bytes() []byte {
+	var out bytes.Buffer
+	offsets := []int{}
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objetos fixos: catálogo, árvore de páginas, fontes e metadados. As páginas
+	// começam no objeto 6, cada uma seguida do seu conteúdo.
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 6+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	object(fmt.Sprintf("<< /Title (%s) /Producer (xclean) >>", pdfEscape(d.title)))
+
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 7+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// pdfEscape converte o texto para WinAnsiEncoding e escapa os caracteres especiais das
+// strings do PDF. Caracteres sem equivalente viram "?".
+func pdfEscape(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		var c byte
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			c = byte(r)
+		case r < 0x20:
+			c = ' '
+		case r < 0x80 || (r >= 0xA0 && r <= 0xFF):
+			c = byte(r)
+		default:
+			c = winAnsiExtra[r]
+			if c == 0 {
+				c = '?'
+			}
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// winAnsiExtra mapeia os caracteres da faixa 0x80–0x9F do WinAnsiEncoding
+var winAnsiExtra = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87, '‰': 0x89,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
@@ -0,0 +1,294 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+)
+
+const (
+	// DefaultPixChargeExpiry é a validade padrão de uma cobrança Pix
+	DefaultPixChargeExpiry = 30 * time.Minute
+	// PixWebhookTolerance é a diferença máxima aceita entre o horário da assinatura e o do servidor
+	PixWebhookTolerance = 5 * time.Minute
+
+	// Cabeçalhos da notificação assinada
+	PixTimestampHeader = "X-Pix-Timestamp"
+	PixSignatureHeader = "X-Pix-Signature"
+)
+
+var (
+	ErrInvalidPixSignature = errors.New("assinatura do webhook Pix inválida")
+	ErrInvalidPixWebhook   = errors.New("notificação Pix inválida")
+	ErrPixChargeNotFound   = errors.New("cobrança Pix não encontrada no PSP")
+)
+
+// PixChargeRequest descreve uma cobrança imediata (cob) a ser criada no PSP
+type PixChargeRequest struct {
+	TxID        string
+	Amount      int64 // Em centavos
+	Expiry      time.Duration
+	Description string
+}
+
+// PixCharge é uma cobrança criada no PSP. Location é a URL do payload usada no BR Code dinâmico.
+type PixCharge struct {
+	TxID      string
+	Location  string
+	ExpiresAt time.Time
+}
+
+// PixProvider é o PSP que recebe os pagamentos Pix. A liquidação chega pelo webhook assinado;
+// FakePixProvider o implementa em memória.
+type PixProvider interface {
+	CreateCharge(req PixChargeRequest) (*PixCharge, error)
+	CancelCharge(txID string) error
+	// Refund solicita a devolução de parte ou de todo o valor recebido
+	Refund(txID string, amount int64, reason string) (*GatewayRefund, error)
+}
+
+// PixConfig identifica o recebedor nos BR Codes e define a validade das cobranças
+type PixConfig struct {
+	MerchantName string
+	MerchantCity string
+	ChargeExpiry time.Duration
+}
+
+// PixNotification é a liquidação de uma cobrança informada pelo PSP
+type PixNotification struct {
+	TxID       string
+	EndToEndID string
+	Amount     int64 // Em centavos
+	PaidAt     time.Time
+}
+
+// pixWebhookBody segue o formato de notificação da API Pix do Banco Central
+type pixWebhookBody struct {
+	Pix []struct {
+		EndToEndID string `json:"endToEndId"`
+		TxID       string `json:"txid"`
+		Valor      string `json:"valor"`
+		Horario    string `json:"horario"`
+	} `json:"pix"`
+}
+
+// SignPixWebhook assina o corpo da notificação: HMAC-SHA256 de "timestamp.corpo", em hexadecimal
+func SignPixWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyPixWebhook confere a assinatura e o horário de uma notificação recebida
+func VerifyPixWebhook(secret, timestamp, signature string, body []byte, now time.Time) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidPixSignature
+	}
+	sentAt := time.Unix(seconds, 0)
+	if now.Sub(sentAt) > PixWebhookTolerance || sentAt.Sub(now) > PixWebhookTolerance {
+		return ErrInvalidPixSignature
+	}
+
+	expected := SignPixWebhook(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		return ErrInvalidPixSignature
+	}
+	return nil
+}
+
+// ParsePixWebhook lê as liquidações de uma notificação do PSP
+func ParsePixWebhook(body []byte) ([]PixNotification, error) {
+	var parsed pixWebhookBody
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPixWebhook, err)
+	}
+
+	notifications := make([]PixNotification, 0, len(parsed.Pix))
+	for _, p := range parsed.Pix {
+		amount, err := parsePixAmount(p.Valor)
+		if err != nil || p.TxID == "" || p.EndToEndID == "" {
+			return nil, fmt.Errorf("%w: pagamento %q", ErrInvalidPixWebhook, p.EndToEndID)
+		}
+		paidAt, err := time.Parse(time.RFC3339, p.Horario)
+		if err != nil {
+			return nil, fmt.Errorf("%w: horário %q", ErrInvalidPixWebhook, p.Horario)
+		}
+		notifications = append(notifications, PixNotification{
+			TxID:       p.TxID,
+			EndToEndID: p.EndToEndID,
+			Amount:     amount,
+			PaidAt:     paidAt,
+		})
+	}
+	return notifications, nil
+}
+
+// parsePixAmount converte valores como "123.45" para centavos sem passar por ponto flutuante
+func parsePixAmount(value string) (int64, error) {
+	whole, frac, _ := strings.Cut(strings.TrimSpace(value), ".")
+	if len(frac) > 2 {
+		return 0, fmt.Errorf("valor inválido: %q", value)
+	}
+	frac += strings.Repeat("0", 2-len(frac))
+
+	reais, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || reais < 0 {
+		return 0, fmt.Errorf("valor inválido: %q", value)
+	}
+	centavos, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil || centavos < 0 {
+		return 0, fmt.Errorf("valor inválido: %q", value)
+	}
+	return reais*100 + centavos, nil
+}
+
+// formatPixAmount converte centavos para o formato da API Pix
+func formatPixAmount(amount int64) string {
+	return fmt.Sprintf("%d.%02d", amount/100, amount%100)
+}
+
+// newPixTxID gera um txid de 32 caracteres alfanuméricos, dentro do limite de 26 a 35 do Pix
+func newPixTxID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// fakePixCharge guarda uma cobrança no FakePixProvider
+type fakePixCharge struct {
+	amount   int64
+	refunded int64
+	canceled bool
+}
+
+// FakePixProvider é um PSP em memória para desenvolvimento e testes.
+// Os pagamentos são simulados pelo PixWebhookSimulator.
+type FakePixProvider struct {
+	mu      sync.Mutex
+	charges map[string]*fakePixCharge
+	now     func() time.Time
+}
+
+func NewFakePixProvider() *FakePixProvider {
+	return &FakePixProvider{
+		charges: map[string]*fakePixCharge{},
+		now:     time.Now,
+	}
+}
+
+func (p *FakePixProvider) CreateCharge(req PixChargeRequest) (*PixCharge, error) {
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("valor inválido: %d", req.Amount)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.charges[req.TxID] = &fakePixCharge{amount: req.Amount}
+	return &PixCharge{
+		TxID:      req.TxID,
+		Location:  "pix.fake.xclean.local/qr/v2/" + req.TxID,
+		ExpiresAt: p.now().Add(req.Expiry),
+	}, nil
+}
+
+func (p *FakePixProvider) CancelCharge(txID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	charge, ok := p.charges[txID]
+	if !ok {
+		return ErrPixChargeNotFound
+	}
+	charge.canceled = true
+	return nil
+}
+
+func (p *FakePixProvider) Refund(txID string, amount int64, reason string) (*GatewayRefund, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	charge, ok := p.charges[txID]
+	if !ok {
+		return nil, ErrPixChargeNotFound
+	}
+	if amount <= 0 || charge.refunded+amount > charge.amount {
+		return nil, ErrGatewayInvalidRefund
+	}
+	charge.refunded += amount
+	return &GatewayRefund{ID: "D" + newPixTxID(), Amount: amount}, nil
+}
+
+// PixWebhookSimulator envia ao webhook notificações assinadas como as do PSP,
+// simulando o pagamento de uma cobrança em desenvolvimento e testes
+type PixWebhookSimulator struct {
+	url    string
+	secret string
+	client HTTPClient
+	now    func() time.Time
+}
+
+func NewPixWebhookSimulator(url, secret string, client HTTPClient) *PixWebhookSimulator {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &PixWebhookSimulator{
+		url:    url,
+		secret: secret,
+		client: client,
+		now:    time.Now,
+	}
+}
+
+// Pay notifica o pagamento integral da cobrança Pix
+func (s *PixWebhookSimulator) Pay(payment *models.Payment) error {
+	now := s.now()
+	body, err := json.Marshal(map[string]interface{}{
+		"pix": []map[string]string{{
+			"endToEndId": "E" + newPixTxID(),
+			"txid":       payment.GatewayIntentID,
+			"valor":      formatPixAmount(payment.Amount),
+			"horario":    now.UTC().Format(time.RFC3339),
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(PixTimestampHeader, timestamp)
+	req.Header.Set(PixSignatureHeader, SignPixWebhook(s.secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook Pix respondeu com status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/testutil"
+)
+
+func TestBRCodePayload(t *testing.T) {
+	code := BRCode{
+		Location:     "https://pix.example.com/qr/v2/abc123",
+		Amount:       12345,
+		MerchantName: "Faxina São João",
+		MerchantCity: "São Paulo",
+	}
+	payload, err := code.Payload()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, field := range []string{
+		"000201", "010212", "5303986", "5406123.45", "5802BR",
+		emv("25", "pix.example.com/qr/v2/abc123"), emv("59", "FAXINA SAO JOAO"), emv("60", "SAO PAULO"),
+	} {
+		if !strings.Contains(payload, field) {
+			t.Errorf("payload %q sem o campo %q", payload, field)
+		}
+	}
+	body, crc := payload[:len(payload)-4], payload[len(payload)-4:]
+	if !strings.HasSuffix(body, "6304") || crc != fmt.Sprintf("%04X", crc16CCITT([]byte(body))) {
+		t.Errorf("CRC %q não confere com o payload", crc)
+	}
+
+	code.Amount = 0
+	if _, err := code.Payload(); !errors.Is(err, ErrInvalidBRCode) {
+		t.Errorf("cobrança sem valor: erro %v; want %v", err, ErrInvalidBRCode)
+	}
+}
+
+func TestCRC16CCITT(t *testing.T) {
+	// Valor de verificação do CRC-16/CCITT-FALSE
+	if got := crc16CCITT([]byte("123456789")); got != 0x29B1 {
+		t.Errorf("crc16CCITT = %04X, want 29B1", got)
+	}
+}
+
+func TestSettlePix(t *testing.T) {
+	db := testutil.NewDB(t)
+	client := testutil.CreateUser(t, db, models.UserTypeClient)
+	provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+	appointment := testutil.CreateAppointment(t, db, models.Appointment{
+		UserID: client.ID, ProviderID: provider.ID, Price: 100,
+		Date: time.Now().UTC().AddDate(0, 0, 3).Truncate(24 * time.Hour),
+	})
+
+	service := newPaymentService(db, NewFakePaymentGateway())
+	payment, err := service.CreatePixCharge(appointment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Method != models.PaymentMethodPix || payment.PixCode == "" || payment.ExpiresAt == nil {
+		t.Fatalf("cobrança %+v; want Pix com código e validade", payment)
+	}
+	if _, err := service.PixQRCode(payment); err != nil {
+		t.Fatalf("PixQRCode: %v", err)
+	}
+
+	wrong := PixNotification{TxID: payment.GatewayIntentID, EndToEndID: "E122", Amount: payment.Amount - 1, PaidAt: time.Now()}
+	if err := service.SettlePix(wrong); !errors.Is(err, ErrPixAmountMismatch) {
+		t.Fatalf("SettlePix com valor diferente: %v, want %v", err, ErrPixAmountMismatch)
+	}
+
+	notification := PixNotification{TxID: payment.GatewayIntentID, EndToEndID: "E123", Amount: payment.Amount, PaidAt: time.Now()}
+	// A segunda notificação, repetida, não tem efeito
+	for i := 0; i < 2; i++ {
+		if err := service.SettlePix(notification); err != nil {
+			t.Fatalf("SettlePix: %v", err)
+		}
+	}
+
+	var stored models.Payment
+	db.First(&stored, payment.ID)
+	if stored.Status != models.PaymentStatusSucceeded || stored.PaidAt == nil {
+		t.Errorf("pagamento %s; want pago", stored.Status)
+	}
+	var appt models.Appointment
+	db.First(&appt, appointment.ID)
+	if appt.Status != models.AppointmentStatusConfirmed {
+		t.Errorf("agendamento %s; want confirmado", appt.Status)
+	}
+	var events int64
+	db.Model(&models.AppointmentEvent{}).Where("appointment_id = ? AND type = ?", appointment.ID, models.AppointmentEventPaymentPaid).Count(&events)
+	if events != 1 {
+		t.Errorf("%d eventos de pagamento, want 1", events)
+	}
+}
+
+func TestSettlePixRefundsCanceledCharge(t *testing.T) {
+	db := testutil.NewDB(t)
+	client := testutil.CreateUser(t, db, models.UserTypeClient)
+	provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+	appointment := testutil.CreateAppointment(t, db, models.Appointment{
+		UserID: client.ID, ProviderID: provider.ID, Price: 100,
+		Date: time.Now().UTC().AddDate(0, 0, 3).Truncate(24 * time.Hour),
+	})
+
+	service := newPaymentService(db, NewFakePaymentGateway())
+	payment, err := service.CreatePixCharge(appointment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A cobrança expira antes de o PSP notificar o pagamento
+	if err := service.cancelIntent(payment); err != nil {
+		t.Fatal(err)
+	}
+
+	notification := PixNotification{TxID: payment.GatewayIntentID, EndToEndID: "E123", Amount: payment.Amount, PaidAt: time.Now()}
+	for i := 0; i < 2; i++ {
+		if err := service.SettlePix(notification); err != nil {
+			t.Fatalf("SettlePix: %v", err)
+		}
+	}
+
+	var stored models.Payment
+	db.Preload("Refunds").First(&stored, payment.ID)
+	if stored.Status != models.PaymentStatusRefunded || stored.EscrowStatus != models.EscrowStatusRefunded || stored.RefundedAmount != payment.Amount {
+		t.Errorf("pagamento %s/%s, reembolsado %d; want devolvido por inteiro", stored.Status, stored.EscrowStatus, stored.RefundedAmount)
+	}
+	if len(stored.Refunds) != 1 || stored.Refunds[0].Reason != latePaymentReason {
+		t.Errorf("reembolsos = %+v; want um reembolso pelo pagamento tardio", stored.Refunds)
+	}
+	var appt models.Appointment
+	db.First(&appt, appointment.ID)
+	if appt.Status != models.AppointmentStatusPending {
+		t.Errorf("agendamento %s; want pendente", appt.Status)
+	}
+}
+
+func TestPixWebhookSimulator(t *testing.T) {
+	const secret = "segredo"
+	var received []PixNotification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := VerifyPixWebhook(secret, r.Header.Get(PixTimestampHeader), r.Header.Get(PixSignatureHeader), body, time.Now()); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		notifications, err := ParsePixWebhook(body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received = append(received, notifications...)
+	}))
+	defer server.Close()
+
+	payment := &models.Payment{GatewayIntentID: "tx123", Amount: 12345}
+	if err := NewPixWebhookSimulator(server.URL, secret, nil).Pay(payment); err != nil {
+		t.Fatalf("Pay: %v", err)
+	}
+	if len(received) != 1 || received[0].TxID != "tx123" || received[0].Amount != 12345 || received[0].EndToEndID == "" {
+		t.Errorf("notificações = %+v", received)
+	}
+
+	if err := NewPixWebhookSimulator(server.URL, "outro segredo", nil).Pay(payment); err == nil {
+		t.Error("notificação com assinatura inválida deveria falhar")
+	}
+}
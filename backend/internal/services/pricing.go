@@ -0,0 +1,42 @@
+package services
+
+import "math"
+
+// PricingService calcula o preço dos agendamentos
+type PricingService struct{}
+
+func NewPricingService() *PricingService {
+	return &PricingService{}
+}
+
+// Quote calcula o preço de um atendimento a partir do valor por hora da prestadora
+// e da duração em minutos
+func (s *PricingService) Quote(hourlyRate float64, duration int) float64 {
+	return math.Round(hourlyRate*float64(duration)/60*100) / 100
+}
+
+// MinChargeAmount é o menor valor, em centavos, cobrado do cliente numa reserva com cupom
+// ou créditos. Abaixo dele, o desconto e o crédito são reduzidos.
+const MinChargeAmount = 100
+
+// CheckoutBreakdown detalha, em centavos, o valor de uma reserva com cupom e créditos
+type CheckoutBreakdown struct {
+	Subtotal      int64 `json:"subtotal"`
+	PromoDiscount int64 `json:"promo_discount"`
+	CreditApplied int64 `json:"credit_applied"`
+	Total         int64 `json:"total"`
+}
+
+// Checkout aplica ao subtotal o desconto do cupom e, em seguida, até credit centavos da
+// carteira do cliente, sem deixar o total abaixo de MinChargeAmount
+func (s *PricingService) Checkout(subtotal, promoDiscount, credit int64) CheckoutBreakdown {
+	chargeable := max(subtotal-MinChargeAmount, 0)
+	discount := min(max(promoDiscount, 0), chargeable)
+	applied := min(max(credit, 0), chargeable-discount)
+	return CheckoutBreakdown{
+		Subtotal:      subtotal,
+		PromoDiscount: discount,
+		CreditApplied: applied,
+		Total:         subtotal - discount - applied,
+	}
+}
@@ -0,0 +1,48 @@
+package services
+
+import "testing"
+
+func TestPricingQuote(t *testing.T) {
+	pricing := NewPricingService()
+	tests := []struct {
+		name       string
+		hourlyRate float64
+		duration   int
+		want       float64
+	}{
+		{"duas horas", 50, 120, 100},
+		{"fração de hora", 45, 90, 67.5},
+		{"arredonda centavos", 33.33, 50, 27.78},
+		{"sem duração", 50, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pricing.Quote(tt.hourlyRate, tt.duration); got != tt.want {
+				t.Errorf("Quote(%v, %d) = %v, want %v", tt.hourlyRate, tt.duration, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPricingCheckout(t *testing.T) {
+	pricing := NewPricingService()
+	tests := []struct {
+		name                    string
+		subtotal, promo, credit int64
+		want                    CheckoutBreakdown
+	}{
+		{"sem descontos", 10000, 0, 0, CheckoutBreakdown{10000, 0, 0, 10000}},
+		{"cupom e crédito", 10000, 2000, 3000, CheckoutBreakdown{10000, 2000, 3000, 5000}},
+		{"cupom maior que o valor", 10000, 15000, 0, CheckoutBreakdown{10000, 9900, 0, MinChargeAmount}},
+		{"crédito limitado ao mínimo", 10000, 5000, 8000, CheckoutBreakdown{10000, 5000, 4900, MinChargeAmount}},
+		{"valor abaixo do mínimo", 80, 50, 50, CheckoutBreakdown{80, 0, 0, 80}},
+		{"valores negativos ignorados", 10000, -100, -100, CheckoutBreakdown{10000, 0, 0, 10000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pricing.Checkout(tt.subtotal, tt.promo, tt.credit); got != tt.want {
+				t.Errorf("Checkout(%d, %d, %d) = %+v, want %+v", tt.subtotal, tt.promo, tt.credit, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,425 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+)
+
+// Créditos de indicação, em centavos, concedidos a quem indicou e ao indicado após o
+// primeiro atendimento concluído do indicado
+const (
+	ReferrerReward = 2000
+	RefereeReward  = 2000
+)
+
+const (
+	promoCodeMinLength = 3
+	promoCodeMaxLength = 32
+	referralCodeLength = 8
+	// Caracteres dos códigos de indicação, sem os que se confundem (0/O, 1/I)
+	referralCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	referralCodeAttempts = 5
+)
+
+var (
+	ErrInvalidPromoCode       = errors.New("cupom inválido")
+	ErrPromoCodeNotStarted    = errors.New("cupom ainda não está válido")
+	ErrPromoCodeExpired       = errors.New("cupom expirado")
+	ErrPromoCodeMinSpend      = errors.New("o valor do atendimento não atinge o mínimo do cupom")
+	ErrSelfReferral           = errors.New("não é possível usar o próprio código de indicação")
+	ErrReferralNotAllowed     = errors.New("apenas clientes sem atendimentos concluídos podem usar um código de indicação")
+	ErrReferralCodeGeneration = errors.New("não foi possível gerar o código de indicação")
+)
+
+// PromotionService cuida dos cupons, das indicações e da carteira de créditos dos clientes.
+//
+// O desconto do cupom é custeado pela conta de promoções da plataforma e o crédito sai da
+// conta do cliente; os dois vão para a custódia na reserva e são repassados à prestadora
+// com o valor pago, que recebe sempre pelo preço cheio. No cancelamento, voltam à origem.
+type PromotionService struct {
+	promotionRepo   *repositories.PromotionRepository
+	ledgerRepo      *repositories.LedgerRepository
+	userRepo        *repositories.UserRepository
+	appointmentRepo *repositories.AppointmentRepository
+	ledger          *LedgerService
+	pricing         *PricingService
+	now             func() time.Time
+}
+
+func NewPromotionService(
+	promotionRepo *repositories.PromotionRepository,
+	ledgerRepo *repositories.LedgerRepository,
+	userRepo *repositories.UserRepository,
+	appointmentRepo *repositories.AppointmentRepository,
+	ledger *LedgerService,
+	pricing *PricingService,
+) *PromotionService {
+	return &PromotionService{
+		promotionRepo:   promotionRepo,
+		ledgerRepo:      ledgerRepo,
+		userRepo:        userRepo,
+		appointmentRepo: appointmentRepo,
+		ledger:          ledger,
+		pricing:         pricing,
+		now:             time.Now,
+	}
+}
+
+// NormalizeCode padroniza um código de cupom ou de indicação
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// ValidatePromoCode normaliza o código e confere as regras de um cupom antes de salvá-lo
+func (s *PromotionService) ValidatePromoCode(promo *models.PromoCode) error {
+	promo.Code = NormalizeCode(promo.Code)
+	if len(promo.Code) < promoCodeMinLength || len(promo.Code) > promoCodeMaxLength {
+		return fmt.Errorf("%w: o código deve ter de %d a %d caracteres", ErrInvalidPromoCode, promoCodeMinLength, promoCodeMaxLength)
+	}
+	for _, r := range promo.Code {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '-' && r != '_' {
+			return fmt.Errorf("%w: o código aceita apenas letras, números, hífen e sublinhado", ErrInvalidPromoCode)
+		}
+	}
+
+	switch {
+	case promo.PercentOff < 0 || promo.PercentOff > 100:
+		return fmt.Errorf("%w: o percentual deve estar entre 1 e 100", ErrInvalidPromoCode)
+	case promo.AmountOff < 0 || promo.MaxDiscount < 0 || promo.MinSpend < 0:
+		return fmt.Errorf("%w: valores não podem ser negativos", ErrInvalidPromoCode)
+	case (promo.PercentOff > 0) == (promo.AmountOff > 0):
+		return fmt.Errorf("%w: informe o percentual ou o valor do desconto, não os dois", ErrInvalidPromoCode)
+	case promo.MaxRedemptions < 0 || promo.PerUserLimit < 0:
+		return fmt.Errorf("%w: limites de uso não podem ser negativos", ErrInvalidPromoCode)
+	case promo.StartsAt != nil && promo.ExpiresAt != nil && !promo.ExpiresAt.After(*promo.StartsAt):
+		return fmt.Errorf("%w: a validade deve terminar depois do início", ErrInvalidPromoCode)
+	}
+	return nil
+}
+
+// PrepareBooking calcula o cupom e os créditos de uma reserva de subtotal centavos e
+// monta os lançamentos que os levam à custódia. O uso do cupom e o saldo são confirmados
+// na gravação do agendamento; retorna nil se não houver desconto a aplicar.
+func (s *PromotionService) PrepareBooking(userID uint, subtotal int64, code string, useCredit bool) (*repositories.BookingIncentives, CheckoutBreakdown, error) {
+	incentives := &repositories.BookingIncentives{}
+
+	var discount int64
+	if code = NormalizeCode(code); code != "" {
+		promo, err := s.promotionRepo.FindPromoCodeByCode(code)
+		if err != nil {
+			return nil, CheckoutBreakdown{}, err
+		}
+		if err := s.checkPromoCode(promo, subtotal); err != nil {
+			return nil, CheckoutBreakdown{}, err
+		}
+		incentives.PromoCode = promo
+		discount = promo.Discount(subtotal)
+	}
+
+	var credit int64
+	if useCredit {
+		balance, err := s.ledger.UserBalance(models.LedgerAccountClient, userID)
+		if err != nil {
+			return nil, CheckoutBreakdown{}, err
+		}
+		// Créditos aparecem negativos no razão
+		credit = max(-balance.Balance, 0)
+		incentives.CreditAccountID = balance.ID
+	}
+
+	breakdown := s.pricing.Checkout(subtotal, discount, credit)
+	incentives.PromoDiscount = breakdown.PromoDiscount
+	incentives.Credit = breakdown.CreditApplied
+
+	if incentives.PromoDiscount > 0 {
+		transaction, err := s.ledger.transaction(ledgerMovement{
+			kind:        models.LedgerTransactionPromoSubsidy,
+			description: fmt.Sprintf("Desconto do cupom %s", incentives.PromoCode.Code),
+			entries: []ledgerEntry{
+				{accountType: models.LedgerAccountPromotions, amount: incentives.PromoDiscount},
+				escrowEntry(-incentives.PromoDiscount),
+			},
+		})
+		if err != nil {
+			return nil, CheckoutBreakdown{}, err
+		}
+		incentives.Ledger = append(incentives.Ledger, transaction)
+	}
+	if incentives.Credit > 0 {
+		transaction, err := s.ledger.transaction(ledgerMovement{
+			kind:        models.LedgerTransactionCreditHold,
+			description: "Créditos da carteira usados na reserva",
+			entries: []ledgerEntry{
+				{accountType: models.LedgerAccountClient, ownerID: &userID, amount: incentives.Credit},
+				escrowEntry(-incentives.Credit),
+			},
+		})
+		if err != nil {
+			return nil, CheckoutBreakdown{}, err
+		}
+		incentives.Ledger = append(incentives.Ledger, transaction)
+	}
+
+	if incentives.PromoCode == nil && incentives.Credit == 0 {
+		return nil, breakdown, nil
+	}
+	return incentives, breakdown, nil
+}
+
+// checkPromoCode confere a validade e o valor mínimo do cupom. Os limites de uso são
+// verificados na gravação, com o cliente bloqueado.
+func (s *PromotionService) checkPromoCode(promo *models.PromoCode, subtotal int64) error {
+	now := s.now()
+	switch {
+	case !promo.Active:
+		return repositories.ErrPromoCodeUnavailable
+	case promo.StartsAt != nil && now.Before(*promo.StartsAt):
+		return ErrPromoCodeNotStarted
+	case promo.ExpiresAt != nil && !now.Before(*promo.ExpiresAt):
+		return ErrPromoCodeExpired
+	case promo.MaxRedemptions > 0 && promo.Redemptions >= promo.MaxRedemptions:
+		return repositories.ErrPromoCodeUnavailable
+	case subtotal < promo.MinSpend:
+		return ErrPromoCodeMinSpend
+	}
+	return nil
+}
+
+// ReleaseBooking devolve à carteira do cliente o crédito e ao cupom o uso de um agendamento
+// cancelado, estornando o que estava na custódia. Chamadas repetidas não têm efeito.
+func (s *PromotionService) ReleaseBooking(appointment *models.Appointment) error {
+	current, err := s.appointmentRepo.FindByID(appointment.ID)
+	if err != nil {
+		return err
+	}
+	if current.PromoDiscount == 0 && current.CreditApplied == 0 {
+		return nil
+	}
+
+	var reversals []*models.LedgerTransaction
+	for _, movement := range incentiveReturnMovements(current.ID, current.UserID, current.PromoDiscount, current.CreditApplied) {
+		transaction, err := s.ledger.transaction(movement)
+		if err != nil {
+			return err
+		}
+		reversals = append(reversals, transaction)
+	}
+	for _, transaction := range reversals {
+		transaction.AppointmentID = &current.ID
+	}
+
+	released, err := s.promotionRepo.ReleaseIncentives(current.ID, current.PromoDiscount, current.CreditApplied, reversals...)
+	if err != nil {
+		return err
+	}
+	if released {
+		appointment.PromoDiscount = 0
+		appointment.CreditApplied = 0
+	}
+	return nil
+}
+
+// ClientWallet é a carteira de créditos de um cliente, em centavos, com os movimentos do período
+type ClientWallet struct {
+	Currency string                 `json:"currency"`
+	Balance  int64                  `json:"balance"`
+	From     time.Time              `json:"from"`
+	To       time.Time              `json:"to"`
+	Entries  []models.LedgerPosting `json:"entries"`
+}
+
+// Wallet retorna o saldo de créditos do cliente e os movimentos do período [from, to).
+// Créditos aparecem negativos no razão; na carteira, os sinais são invertidos.
+func (s *PromotionService) Wallet(userID uint, from, to time.Time) (*ClientWallet, error) {
+	balance, err := s.ledger.UserBalance(models.LedgerAccountClient, userID)
+	if err != nil {
+		return nil, err
+	}
+	postings, err := s.ledgerRepo.GetPostings(balance.ID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for i := range postings {
+		postings[i].Amount = -postings[i].Amount
+	}
+	return &ClientWallet{
+		Currency: balance.Currency,
+		Balance:  -balance.Balance,
+		From:     from,
+		To:       to,
+		Entries:  postings,
+	}, nil
+}
+
+// ReferralCode retorna o código de indicação do cliente, gerando-o no primeiro acesso
+func (s *PromotionService) ReferralCode(userID uint) (*models.ReferralCode, error) {
+	code, err := s.promotionRepo.FindReferralCodeByUser(userID)
+	if !errors.Is(err, repositories.ErrReferralCodeNotFound) {
+		return code, err
+	}
+
+	for attempt := 0; attempt < referralCodeAttempts; attempt++ {
+		value, err := newReferralCode()
+		if err != nil {
+			return nil, err
+		}
+		code = &models.ReferralCode{UserID: userID, Code: value}
+		err = s.promotionRepo.CreateReferralCode(code)
+		if !errors.Is(err, repositories.ErrReferralCodeExists) {
+			return code, err
+		}
+	}
+	return nil, ErrReferralCodeGeneration
+}
+
+// newReferralCode gera um código de indicação aleatório
+func newReferralCode() (string, error) {
+	buf := make([]byte, referralCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i, b := range buf {
+		buf[i] = referralCodeAlphabet[int(b)%len(referralCodeAlphabet)]
+	}
+	return string(buf), nil
+}
+
+// RedeemReferral registra que o cliente foi indicado pelo dono do código. Só vale para
+// clientes que ainda não concluíram nenhum atendimento, uma única vez.
+func (s *PromotionService) RedeemReferral(userID uint, code string) (*models.Referral, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.UserType != models.UserTypeClient {
+		return nil, ErrReferralNotAllowed
+	}
+
+	referralCode, err := s.promotionRepo.FindReferralCode(NormalizeCode(code))
+	if err != nil {
+		return nil, err
+	}
+	if referralCode.UserID == userID {
+		return nil, ErrSelfReferral
+	}
+
+	_, err = s.promotionRepo.FirstCompletedAppointment(userID)
+	if err == nil {
+		return nil, ErrReferralNotAllowed
+	}
+	if !errors.Is(err, repositories.ErrNoCompletedAppointment) {
+		return nil, err
+	}
+
+	referral := &models.Referral{
+		ReferrerID: referralCode.UserID,
+		RefereeID:  userID,
+		Status:     models.ReferralStatusPending,
+	}
+	if err := s.promotionRepo.CreateReferral(referral); err != nil {
+		return nil, err
+	}
+	return referral, nil
+}
+
+// ReferralSummary reúne o código de indicação do cliente, suas indicações e, se houver,
+// a indicação pela qual ele chegou
+type ReferralSummary struct {
+	Code       string            `json:"code"`
+	Referrals  []models.Referral `json:"referrals"`
+	Pending    int               `json:"pending"`
+	Rewarded   int               `json:"rewarded"`
+	Earned     int64             `json:"earned"` // Em centavos
+	ReferredBy *models.Referral  `json:"referred_by,omitempty"`
+}
+
+// Referrals retorna o resumo das indicações do cliente
+func (s *PromotionService) Referrals(userID uint) (*ReferralSummary, error) {
+	code, err := s.ReferralCode(userID)
+	if err != nil {
+		return nil, err
+	}
+	referrals, err := s.promotionRepo.GetReferralsByReferrer(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &ReferralSummary{Code: code.Code, Referrals: referrals}
+	for _, referral := range referrals {
+		if referral.Status == models.ReferralStatusRewarded {
+			summary.Rewarded++
+			summary.Earned += referral.ReferrerReward
+		} else {
+			summary.Pending++
+		}
+	}
+
+	referredBy, err := s.promotionRepo.FindReferralByReferee(userID)
+	switch {
+	case err == nil:
+		summary.ReferredBy = referredBy
+	case !errors.Is(err, repositories.ErrReferralNotFound):
+		return nil, err
+	}
+	return summary, nil
+}
+
+// RewardReferrals credita os dois clientes de cada indicação cujo indicado concluiu o
+// primeiro atendimento com check-out ou repasse à prestadora. Executada periodicamente;
+// cada indicação é recompensada uma vez.
+func (s *PromotionService) RewardReferrals() error {
+	referrals, err := s.promotionRepo.GetRewardableReferrals()
+	if err != nil {
+		return err
+	}
+
+	for i := range referrals {
+		if err := s.rewardReferral(&referrals[i]); err != nil {
+			log.Printf("Erro ao recompensar indicação %d: %v", referrals[i].ID, err)
+		}
+	}
+	return nil
+}
+
+func (s *PromotionService) rewardReferral(referral *models.Referral) error {
+	appointment, err := s.promotionRepo.FirstVerifiedAppointment(referral.RefereeID)
+	if err != nil {
+		return err
+	}
+	referral.ReferrerReward = ReferrerReward
+	referral.RefereeReward = RefereeReward
+	referral.AppointmentID = &appointment.ID
+
+	referrer, err := s.referralCredit(referral, referral.ReferrerID, referral.ReferrerReward, "referrer", "Crédito por indicar um cliente")
+	if err != nil {
+		return err
+	}
+	referee, err := s.referralCredit(referral, referral.RefereeID, referral.RefereeReward, "referee", "Crédito de boas-vindas por indicação")
+	if err != nil {
+		return err
+	}
+
+	_, err = s.promotionRepo.RewardReferral(referral, s.now(), referrer, referee)
+	return err
+}
+
+// referralCredit monta o lançamento que leva o crédito de indicação da conta de promoções
+// à carteira do cliente
+func (s *PromotionService) referralCredit(referral *models.Referral, userID uint, amount int64, role, description string) (*models.LedgerTransaction, error) {
+	return s.ledger.transaction(ledgerMovement{
+		kind:        models.LedgerTransactionReferral,
+		reference:   fmt.Sprintf("referral:%d:%s", referral.ID, role),
+		description: description,
+		entries: []ledgerEntry{
+			{accountType: models.LedgerAccountPromotions, amount: amount},
+			{accountType: models.LedgerAccountClient, ownerID: &userID, amount: -amount},
+		},
+	})
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/testutil"
+	"gorm.io/gorm"
+)
+
+// newPromotionService monta o serviço de promoções com o razão sobre o banco de teste
+func newPromotionService(db *gorm.DB) *PromotionService {
+	ledgerRepo := repositories.NewLedgerRepository(db)
+	ledger := NewLedgerService(ledgerRepo, repositories.NewPaymentRepository(db), repositories.NewTipRepository(db))
+	return NewPromotionService(repositories.NewPromotionRepository(db), ledgerRepo, repositories.NewUserRepository(db),
+		repositories.NewAppointmentRepository(db, nil), ledger, NewPricingService())
+}
+
+func TestValidatePromoCode(t *testing.T) {
+	starts := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	ends := starts.AddDate(0, 1, 0)
+	tests := []struct {
+		name     string
+		promo    models.PromoCode
+		wantCode string
+		wantErr  error
+	}{
+		{"percentual", models.PromoCode{Code: " bemvindo10 ", PercentOff: 10}, "BEMVINDO10", nil},
+		{"valor fixo com validade", models.PromoCode{Code: "verao_26", AmountOff: 1500, StartsAt: &starts, ExpiresAt: &ends}, "VERAO_26", nil},
+		{"código curto", models.PromoCode{Code: "ab", PercentOff: 10}, "AB", ErrInvalidPromoCode},
+		{"caractere inválido", models.PromoCode{Code: "DEZ%", PercentOff: 10}, "DEZ%", ErrInvalidPromoCode},
+		{"percentual acima de 100", models.PromoCode{Code: "TUDO", PercentOff: 120}, "TUDO", ErrInvalidPromoCode},
+		{"percentual e valor", models.PromoCode{Code: "DOIS", PercentOff: 10, AmountOff: 500}, "DOIS", ErrInvalidPromoCode},
+		{"sem desconto", models.PromoCode{Code: "NADA"}, "NADA", ErrInvalidPromoCode},
+		{"validade invertida", models.PromoCode{Code: "VOLTA", PercentOff: 10, StartsAt: &ends, ExpiresAt: &starts}, "VOLTA", ErrInvalidPromoCode},
+	}
+	service := &PromotionService{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.ValidatePromoCode(&tt.promo)
+			if !errors.Is(err, tt.wantErr) || tt.promo.Code != tt.wantCode {
+				t.Errorf("ValidatePromoCode = %v, código %q; want %v, %q", err, tt.promo.Code, tt.wantErr, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestPromoCodeAndCreditOnBooking(t *testing.T) {
+	db := testutil.NewDB(t)
+	service := newPromotionService(db)
+	client := testutil.CreateUser(t, db, models.UserTypeClient)
+	provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+
+	promo := &models.PromoCode{Code: "DEZ", Active: true, PercentOff: 10, MaxRedemptions: 1}
+	if err := repositories.NewPromotionRepository(db).CreatePromoCode(promo); err != nil {
+		t.Fatal(err)
+	}
+	// R$ 30,00 de crédito na carteira do cliente
+	testutil.PostLedger(t, db, models.LedgerTransactionReferral, "credito", testutil.LedgerEntry{Type: models.LedgerAccountPromotions, Amount: 3000},
+		testutil.LedgerEntry{Type: models.LedgerAccountClient, OwnerID: &client.ID, Amount: -3000})
+
+	incentives, breakdown, err := service.PrepareBooking(client.ID, 20000, "dez", true)
+	if err != nil {
+		t.Fatalf("PrepareBooking: %v", err)
+	}
+	if breakdown.PromoDiscount != 2000 || breakdown.CreditApplied != 3000 {
+		t.Fatalf("desconto %d e crédito %d, want 2000 e 3000", breakdown.PromoDiscount, breakdown.CreditApplied)
+	}
+
+	appointment := &models.Appointment{
+		UserID: client.ID, ProviderID: provider.ID, Service: "Limpeza", Date: time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC),
+		Time: "09:00", Duration: 60, Status: models.AppointmentStatusPending,
+	}
+	if err := service.appointmentRepo.CreateWithoutConflict(appointment, incentives, nil); err != nil {
+		t.Fatalf("CreateWithoutConflict: %v", err)
+	}
+	if got := testutil.LedgerBalance(t, db, models.LedgerAccountClient, &client.ID); got != 0 {
+		t.Errorf("saldo do cliente após a reserva = %d, want 0", got)
+	}
+	if got := testutil.LedgerBalance(t, db, models.LedgerAccountEscrow, nil); got != -5000 {
+		t.Errorf("custódia após a reserva = %d, want -5000", got)
+	}
+
+	// O cupom tinha um único uso
+	if _, _, err := service.PrepareBooking(client.ID, 20000, "DEZ", false); !errors.Is(err, repositories.ErrPromoCodeUnavailable) {
+		t.Errorf("segundo uso do cupom: %v, want %v", err, repositories.ErrPromoCodeUnavailable)
+	}
+
+	// O cancelamento devolve o crédito e o uso do cupom; repetir não tem efeito
+	for i := 0; i < 2; i++ {
+		if err := service.ReleaseBooking(appointment); err != nil {
+			t.Fatalf("ReleaseBooking: %v", err)
+		}
+	}
+	if got := testutil.LedgerBalance(t, db, models.LedgerAccountClient, &client.ID); got != -3000 {
+		t.Errorf("saldo do cliente após o cancelamento = %d, want -3000", got)
+	}
+	if got := testutil.LedgerBalance(t, db, models.LedgerAccountEscrow, nil); got != 0 {
+		t.Errorf("custódia após o cancelamento = %d, want 0", got)
+	}
+	if _, _, err := service.PrepareBooking(client.ID, 20000, "DEZ", false); err != nil {
+		t.Errorf("cupom após o cancelamento: %v", err)
+	}
+}
+
+func TestRedeemReferral(t *testing.T) {
+	db := testutil.NewDB(t)
+	service := newPromotionService(db)
+	referrer := testutil.CreateUser(t, db, models.UserTypeClient)
+	referee := testutil.CreateUser(t, db, models.UserTypeClient)
+	veteran := testutil.CreateUser(t, db, models.UserTypeClient)
+	provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+	testutil.CreateAppointment(t, db, models.Appointment{
+		UserID: veteran.ID, ProviderID: provider.ID, Status: models.AppointmentStatusCompleted,
+		Date: time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC),
+	})
+
+	code, err := service.ReferralCode(referrer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := service.ReferralCode(referrer.ID); err != nil || again.Code != code.Code {
+		t.Errorf("ReferralCode repetido = %v, %v; want %s", again, err, code.Code)
+	}
+
+	tests := []struct {
+		name    string
+		userID  uint
+		wantErr error
+	}{
+		{"próprio código", referrer.ID, ErrSelfReferral},
+		{"cliente com atendimento concluído", veteran.ID, ErrReferralNotAllowed},
+		{"prestadora", provider.ID, ErrReferralNotAllowed},
+		{"cliente novo", referee.ID, nil},
+		{"indicado duas vezes", referee.ID, repositories.ErrAlreadyReferred},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := service.RedeemReferral(tt.userID, code.Code); !errors.Is(err, tt.wantErr) {
+				t.Errorf("RedeemReferral: %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRewardReferralsRequiresVerifiedCompletion(t *testing.T) {
+	checkedOut := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name         string
+		checkedOut   *time.Time
+		escrow       models.EscrowStatus // vazio quando o atendimento não tem pagamento
+		wantStatus   models.ReferralStatus
+		wantReferee  int64
+		wantReferrer int64
+	}{
+		{"concluído só pelo status", nil, "", models.ReferralStatusPending, 0, 0},
+		{"concluído com check-out", &checkedOut, "", models.ReferralStatusRewarded, RefereeReward, ReferrerReward},
+		{"pagamento repassado à prestadora", nil, models.EscrowStatusReleased, models.ReferralStatusRewarded, RefereeReward, ReferrerReward},
+		{"pagamento ainda em custódia", nil, models.EscrowStatusHeld, models.ReferralStatusPending, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			service := newPromotionService(db)
+
+			referrer := testutil.CreateUser(t, db, models.UserTypeClient)
+			referee := testutil.CreateUser(t, db, models.UserTypeClient)
+			provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+			code, err := service.ReferralCode(referrer.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := service.RedeemReferral(referee.ID, code.Code); err != nil {
+				t.Fatalf("RedeemReferral: %v", err)
+			}
+
+			appointment := testutil.CreateAppointment(t, db, models.Appointment{
+				UserID: referee.ID, ProviderID: provider.ID, Status: models.AppointmentStatusCompleted,
+				Date: time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC), CheckedOutAt: tt.checkedOut,
+			})
+			if tt.escrow != "" {
+				testutil.CreatePayment(t, db, models.Payment{
+					AppointmentID: appointment.ID, UserID: referee.ID, ProviderID: provider.ID, Amount: 10000,
+					Status: models.PaymentStatusSucceeded, EscrowStatus: tt.escrow,
+				})
+			}
+
+			if err := service.RewardReferrals(); err != nil {
+				t.Fatal(err)
+			}
+
+			referral, err := service.promotionRepo.FindReferralByReferee(referee.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			refereeBalance, _ := service.ledger.UserBalance(models.LedgerAccountClient, referee.ID)
+			referrerBalance, _ := service.ledger.UserBalance(models.LedgerAccountClient, referrer.ID)
+			// Créditos na carteira são saldos credores, negativos no razão
+			if referral.Status != tt.wantStatus || -refereeBalance.Balance != tt.wantReferee || -referrerBalance.Balance != tt.wantReferrer {
+				t.Errorf("indicação %s, créditos %d e %d; want %s, %d e %d", referral.Status,
+					-refereeBalance.Balance, -referrerBalance.Balance, tt.wantStatus, tt.wantReferee, tt.wantReferrer)
+			}
+		})
+	}
+}
@@ -0,0 +1,494 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// Gerador de QR Code (ISO/IEC 18004) em modo byte com correção de erros nível M,
+// suficiente para os BR Codes do Pix sem depender de bibliotecas externas.
+
+var ErrQRCodeTooLong = errors.New("conteúdo longo demais para um QR Code")
+
+// Tabelas do nível M, indexadas pela versão (1 a 40)
+var (
+	qrECCCodewordsPerBlock = [41]int{-1,
+		10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26,
+		26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28}
+	qrECCBlocks = [41]int{-1,
+		1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16,
+		17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49}
+)
+
+// qrFormatBitsM identifica o nível M nos bits de formato
+const qrFormatBitsM = 0
+
+// QRCode é a matriz de módulos de um QR Code; true é um módulo escuro
+type QRCode struct {
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+// EncodeQRCode gera o QR Code de menor versão que comporta data
+func EncodeQRCode(data []byte) (*QRCode, error) {
+	version := 0
+	for v := 1; v <= 40; v++ {
+		if qrDataBits(data, v) <= qrDataCodewords(v)*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrQRCodeTooLong
+	}
+
+	codewords := qrEncodeData(data, version)
+	qr := newQRCode(version)
+	qr.drawFunctionPatterns(version)
+	qr.drawCodewords(qrAddECCAndInterleave(codewords, version))
+
+	// Escolhe a máscara com menor penalidade
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		qr.applyMask(mask)
+		qr.drawFormatBits(mask)
+		if penalty := qr.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		qr.applyMask(mask) // a máscara é um XOR: aplicar de novo desfaz
+	}
+	qr.applyMask(best)
+	qr.drawFormatBits(best)
+	return qr, nil
+}
+
+// Size retorna o número de módulos de cada lado, sem a zona de silêncio
+func (qr *QRCode) Size() int {
+	return qr.size
+}
+
+// Dark indica se o módulo da coluna x e linha y é escuro
+func (qr *QRCode) Dark(x, y int) bool {
+	return qr.modules[y][x]
+}
+
+// PNG desenha o QR Code com scale pixels por módulo e a zona de silêncio de 4 módulos
+func (qr *QRCode) PNG(scale int) ([]byte, error) {
+	if scale < 1 {
+		scale = 1
+	}
+	const border = 4
+	side := (qr.size + 2*border) * scale
+
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+	for y := 0; y < qr.size; y++ {
+		for x := 0; x < qr.size; x++ {
+			if !qr.modules[y][x] {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex((x+border)*scale+dx, (y+border)*scale+dy, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func newQRCode(version int) *QRCode {
+	size := version*4 + 17
+	qr := &QRCode{size: size, modules: make([][]bool, size), function: make([][]bool, size)}
+	for i := range qr.modules {
+		qr.modules[i] = make([]bool, size)
+		qr.function[i] = make([]bool, size)
+	}
+	return qr
+}
+
+// qrRawDataModules retorna os módulos disponíveis para dados e correção na versão
+func qrRawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		numAlign := version/7 + 2
+		result -= (25*numAlign-10)*numAlign - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+func qrDataCodewords(version int) int {
+	return qrRawDataModules(version)/8 - qrECCCodewordsPerBlock[version]*qrECCBlocks[version]
+}
+
+func qrCountBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+func qrDataBits(data []byte, version int) int {
+	return 4 + qrCountBits(version) + len(data)*8
+}
+
+// qrEncodeData monta os codewords de dados: modo, tamanho, conteúdo, terminador e preenchimento
+func qrEncodeData(data []byte, version int) []byte {
+	capacity := qrDataCodewords(version) * 8
+	var bits []bool
+	appendBits := func(value, length int) {
+		for i := length - 1; i >= 0; i-- {
+			bits = append(bits, (value>>i)&1 == 1)
+		}
+	}
+
+	appendBits(0x4, 4) // modo byte
+	appendBits(len(data), qrCountBits(version))
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+	appendBits(0, min(4, capacity-len(bits)))
+	appendBits(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		appendBits(pad, 8)
+	}
+
+	codewords := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			codewords[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return codewords
+}
+
+// qrAddECCAndInterleave divide os dados em blocos, calcula a correção de cada um e intercala
+func qrAddECCAndInterleave(data []byte, version int) []byte {
+	numBlocks := qrECCBlocks[version]
+	eccLen := qrECCCodewordsPerBlock[version]
+	rawCodewords := qrRawDataModules(version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	divisor := reedSolomonDivisor(eccLen)
+	blocks := make([][]byte, 0, numBlocks)
+	for i, k := 0, 0; i < numBlocks; i++ {
+		length := shortBlockLen - eccLen
+		if i >= numShortBlocks {
+			length++
+		}
+		block := append([]byte{}, data[k:k+length]...)
+		k += length
+		ecc := reedSolomonRemainder(block, divisor)
+		if i < numShortBlocks {
+			block = append(block, 0)
+		}
+		blocks = append(blocks, append(block, ecc...))
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			// Os blocos curtos têm um byte de enchimento que não é transmitido
+			if i != shortBlockLen-eccLen || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+// reedSolomonDivisor retorna o polinômio gerador de grau degree
+func reedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+func reedSolomonRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coef := range divisor {
+			result[i] ^= gfMultiply(coef, factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplica em GF(2^8) com o polinômio 0x11D
+func gfMultiply(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+func (qr *QRCode) setFunction(x, y int, dark bool) {
+	qr.modules[y][x] = dark
+	qr.function[y][x] = true
+}
+
+func (qr *QRCode) drawFunctionPatterns(version int) {
+	for i := 0; i < qr.size; i++ {
+		qr.setFunction(6, i, i%2 == 0)
+		qr.setFunction(i, 6, i%2 == 0)
+	}
+
+	qr.drawFinder(3, 3)
+	qr.drawFinder(qr.size-4, 3)
+	qr.drawFinder(3, qr.size-4)
+
+	positions := qrAlignmentPositions(version, qr.size)
+	last := len(positions) - 1
+	for i, y := range positions {
+		for j, x := range positions {
+			// Posições que coincidem com os padrões de localização
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			qr.drawAlignment(x, y)
+		}
+	}
+
+	qr.drawFormatBits(0) // reserva as posições; os bits reais são gravados depois da máscara
+	qr.drawVersion(version)
+}
+
+func (qr *QRCode) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || x >= qr.size || y < 0 || y >= qr.size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			qr.setFunction(x, y, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (qr *QRCode) drawAlignment(cx, cy int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			qr.setFunction(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+func qrAlignmentPositions(version, size int) []int {
+	if version == 1 {
+		return nil
+	}
+	numAlign := version/7 + 2
+	step := (version*8 + numAlign*3 + 5) / (numAlign*4 - 4) * 2
+
+	positions := make([]int, numAlign)
+	positions[0] = 6
+	for i, pos := numAlign-1, size-7; i >= 1; i, pos = i-1, pos-step {
+		positions[i] = pos
+	}
+	return positions
+}
+
+func (qr *QRCode) drawFormatBits(mask int) {
+	data := qrFormatBitsM<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return (bits>>i)&1 == 1 }
+
+	// Primeira cópia, junto ao padrão de localização superior esquerdo
+	for i := 0; i <= 5; i++ {
+		qr.setFunction(8, i, bit(i))
+	}
+	qr.setFunction(8, 7, bit(6))
+	qr.setFunction(8, 8, bit(7))
+	qr.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		qr.setFunction(14-i, 8, bit(i))
+	}
+
+	// Segunda cópia, dividida entre os outros dois padrões
+	for i := 0; i < 8; i++ {
+		qr.setFunction(qr.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		qr.setFunction(8, qr.size-15+i, bit(i))
+	}
+	qr.setFunction(8, qr.size-8, true) // módulo sempre escuro
+}
+
+func (qr *QRCode) drawVersion(version int) {
+	if version < 7 {
+		return
+	}
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	bits := version<<12 | rem
+
+	for i := 0; i < 18; i++ {
+		dark := (bits>>i)&1 == 1
+		a, b := qr.size-11+i%3, i/3
+		qr.setFunction(a, b, dark)
+		qr.setFunction(b, a, dark)
+	}
+}
+
+// drawCodewords distribui os bits em zigue-zague, de baixo para cima, em colunas duplas
+func (qr *QRCode) drawCodewords(data []byte) {
+	i := 0
+	for right := qr.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // pula a coluna do padrão de temporização
+		}
+		for vert := 0; vert < qr.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = qr.size - 1 - vert
+				}
+				if !qr.function[y][x] && i < len(data)*8 {
+					qr.modules[y][x] = (data[i>>3]>>(7-i&7))&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (qr *QRCode) applyMask(mask int) {
+	for y := 0; y < qr.size; y++ {
+		for x := 0; x < qr.size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !qr.function[y][x] {
+				qr.modules[y][x] = !qr.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty calcula a penalidade da máscara aplicada, pelas quatro regras da norma
+func (qr *QRCode) penalty() int {
+	result := 0
+	finderLike := [][]bool{
+		{true, false, true, true, true, false, true, false, false, false, false},
+		{false, false, false, false, true, false, true, true, true, false, true},
+	}
+
+	for _, horizontal := range []bool{true, false} {
+		at := func(line, i int) bool {
+			if horizontal {
+				return qr.modules[line][i]
+			}
+			return qr.modules[i][line]
+		}
+		for line := 0; line < qr.size; line++ {
+			// Regra 1: sequências de cinco ou mais módulos da mesma cor
+			run := 1
+			for i := 1; i <= qr.size; i++ {
+				if i < qr.size && at(line, i) == at(line, i-1) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					result += 3 + run - 5
+				}
+				run = 1
+			}
+
+			// Regra 3: padrões parecidos com os de localização
+			for i := 0; i+11 <= qr.size; i++ {
+				for _, pattern := range finderLike {
+					match := true
+					for k, dark := range pattern {
+						if at(line, i+k) != dark {
+							match = false
+							break
+						}
+					}
+					if match {
+						result += 40
+					}
+				}
+			}
+		}
+	}
+
+	// Regra 2: blocos 2x2 da mesma cor
+	dark := 0
+	for y := 0; y < qr.size; y++ {
+		for x := 0; x < qr.size; x++ {
+			if qr.modules[y][x] {
+				dark++
+			}
+			if x+1 < qr.size && y+1 < qr.size {
+				c := qr.modules[y][x]
+				if c == qr.modules[y][x+1] && c == qr.modules[y+1][x] && c == qr.modules[y+1][x+1] {
+					result += 3
+				}
+			}
+		}
+	}
+
+	// Regra 4: equilíbrio entre módulos escuros e claros
+	total := qr.size * qr.size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	result += max(k, 0) * 10
+	return result
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
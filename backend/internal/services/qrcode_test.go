@@ -0,0 +1,93 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestEncodeQRCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		length   int
+		wantSize int
+	}{
+		{"versão 1 cheia", 14, 21},
+		{"versão 2", 15, 25},
+		{"versão 6 cheia", 106, 41},
+		{"versão 7, com bits de versão", 107, 45},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qr, err := EncodeQRCode([]byte(strings.Repeat("a", tt.length)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if qr.Size() != tt.wantSize {
+				t.Fatalf("Size() = %d; want %d", qr.Size(), tt.wantSize)
+			}
+
+			size := qr.Size()
+			for _, corner := range [][2]int{{0, 0}, {size - 7, 0}, {0, size - 7}} {
+				assertFinder(t, qr, corner[0], corner[1])
+			}
+			// Padrão de temporização entre os localizadores
+			for i := 8; i < size-8; i++ {
+				if qr.Dark(i, 6) != (i%2 == 0) || qr.Dark(6, i) != (i%2 == 0) {
+					t.Fatalf("temporização incorreta no módulo %d", i)
+				}
+			}
+		})
+	}
+
+	if _, err := EncodeQRCode(bytes.Repeat([]byte("a"), 3000)); !errors.Is(err, ErrQRCodeTooLong) {
+		t.Errorf("conteúdo longo: erro %v; want ErrQRCodeTooLong", err)
+	}
+}
+
+// assertFinder confere o localizador 7x7 com canto superior esquerdo em (x, y)
+func assertFinder(t *testing.T, qr *QRCode, x, y int) {
+	t.Helper()
+	for dy := 0; dy < 7; dy++ {
+		for dx := 0; dx < 7; dx++ {
+			ring := max(abs(dx-3), abs(dy-3))
+			want := ring != 2
+			if qr.Dark(x+dx, y+dy) != want {
+				t.Fatalf("localizador em (%d, %d): módulo (%d, %d) escuro = %v", x, y, dx, dy, !want)
+			}
+		}
+	}
+}
+
+func TestQRCodePNG(t *testing.T) {
+	qr, err := EncodeQRCode([]byte("00020101021226"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const scale = 4
+	data, err := qr.PNG(scale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("PNG inválido: %v", err)
+	}
+
+	side := (qr.Size() + 8) * scale
+	if bounds := img.Bounds(); bounds.Dx() != side || bounds.Dy() != side {
+		t.Fatalf("imagem %v; want %dx%d", bounds, side, side)
+	}
+	gray := func(x, y int) uint8 { return color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y }
+	if gray(0, 0) != 255 {
+		t.Error("zona de silêncio deveria ser branca")
+	}
+	// Primeiro módulo do localizador, logo após a zona de silêncio
+	if gray(4*scale, 4*scale) != 0 {
+		t.Error("módulo escuro deveria ser preto")
+	}
+}
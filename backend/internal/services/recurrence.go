@@ -0,0 +1,160 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidRRule = errors.New("regra de recorrência inválida")
+)
+
+// Frequências suportadas pela regra de recorrência
+const (
+	FrequencyDaily  = "DAILY"
+	FrequencyWeekly = "WEEKLY"
+)
+
+// maxOccurrences limita o número de ocorrências geradas por série
+const maxOccurrences = 520
+
+var rruleWeekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// RRule é o subconjunto suportado da RRULE do iCalendar (RFC 5545):
+// FREQ=DAILY|WEEKLY, INTERVAL, COUNT, UNTIL=AAAAMMDD e BYDAY (apenas semanal)
+type RRule struct {
+	Freq     string
+	Interval int
+	Count    int
+	Until    *time.Time
+	ByDay    []time.Weekday
+}
+
+// ParseRRule interpreta uma regra como "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH;COUNT=10"
+func ParseRRule(value string) (*RRule, error) {
+	rule := &RRule{Interval: 1}
+
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	for _, part := range strings.Split(value, ";") {
+		if part == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRRule, part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(val)
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("%w: INTERVAL deve ser positivo", ErrInvalidRRule)
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("%w: COUNT deve ser positivo", ErrInvalidRRule)
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := time.Parse("20060102", val[:min(len(val), 8)])
+			if err != nil {
+				return nil, fmt.Errorf("%w: UNTIL deve estar no formato AAAAMMDD", ErrInvalidRRule)
+			}
+			rule.Until = &until
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				weekday, ok := rruleWeekdays[strings.ToUpper(day)]
+				if !ok {
+					return nil, fmt.Errorf("%w: dia %q", ErrInvalidRRule, day)
+				}
+				rule.ByDay = append(rule.ByDay, weekday)
+			}
+		default:
+			return nil, fmt.Errorf("%w: parâmetro %s não suportado", ErrInvalidRRule, key)
+		}
+	}
+
+	switch rule.Freq {
+	case FrequencyDaily:
+		if len(rule.ByDay) > 0 {
+			return nil, fmt.Errorf("%w: BYDAY só é suportado com FREQ=WEEKLY", ErrInvalidRRule)
+		}
+	case FrequencyWeekly:
+	default:
+		return nil, fmt.Errorf("%w: FREQ deve ser DAILY ou WEEKLY", ErrInvalidRRule)
+	}
+
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, fmt.Errorf("%w: use COUNT ou UNTIL, não ambos", ErrInvalidRRule)
+	}
+
+	return rule, nil
+}
+
+// Occurrences retorna as datas da série iniciada em start que caem até o dia to (inclusive).
+// As datas mantêm o fuso de start e começam sempre à meia-noite.
+func (r *RRule) Occurrences(start, to time.Time) []time.Time {
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	if r.Until != nil {
+		until := time.Date(r.Until.Year(), r.Until.Month(), r.Until.Day(), 0, 0, 0, 0, start.Location())
+		if until.Before(to) {
+			to = until
+		}
+	}
+
+	var dates []time.Time
+	emit := func(d time.Time) bool {
+		if d.After(to) || len(dates) >= maxOccurrences || (r.Count > 0 && len(dates) >= r.Count) {
+			return false
+		}
+		dates = append(dates, d)
+		return true
+	}
+
+	if r.Freq == FrequencyDaily {
+		for d := start; emit(d); d = d.AddDate(0, 0, r.Interval) {
+		}
+		return dates
+	}
+
+	days := r.ByDay
+	if len(days) == 0 {
+		days = []time.Weekday{start.Weekday()}
+	}
+	// Semanas começam na segunda-feira (WKST=MO)
+	offsets := make([]int, 0, len(days))
+	for _, day := range days {
+		offsets = append(offsets, (int(day)+6)%7)
+	}
+	sort.Ints(offsets)
+
+	weekStart := start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+	for ; !weekStart.After(to); weekStart = weekStart.AddDate(0, 0, 7*r.Interval) {
+		for _, offset := range offsets {
+			d := weekStart.AddDate(0, 0, offset)
+			if d.Before(start) {
+				continue
+			}
+			if !emit(d) {
+				return dates
+			}
+		}
+	}
+	return dates
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRRuleOccurrences(t *testing.T) {
+	// Terça-feira
+	start := time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		rule string
+		want []time.Time
+	}{
+		{"FREQ=WEEKLY", []time.Time{day(3), day(10), day(17), day(24), day(31)}},
+		{"FREQ=WEEKLY;INTERVAL=2;COUNT=2", []time.Time{day(3), day(17)}},
+		{"FREQ=WEEKLY;BYDAY=MO,TH;UNTIL=20260312", []time.Time{day(5), day(9), day(12)}},
+		{"FREQ=DAILY;INTERVAL=10", []time.Time{day(3), day(13), day(23)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			rule, err := ParseRRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRRule: %v", err)
+			}
+			got := rule.Occurrences(start, to)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Occurrences = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Fatalf("Occurrences = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRRuleRejectsUnsupportedRules(t *testing.T) {
+	for _, rule := range []string{
+		"FREQ=MONTHLY",
+		"FREQ=DAILY;BYDAY=MO",
+		"FREQ=WEEKLY;COUNT=2;UNTIL=20260312",
+		"FREQ=WEEKLY;INTERVAL=0",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYMONTH=3",
+	} {
+		if _, err := ParseRRule(rule); !errors.Is(err, ErrInvalidRRule) {
+			t.Errorf("ParseRRule(%q): err = %v, want ErrInvalidRRule", rule, err)
+		}
+	}
+}
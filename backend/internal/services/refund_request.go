@@ -0,0 +1,86 @@
+package services
+
+import (
+	"errors"
+	"log"
+
+	"github.com/xclean/backend/internal/models"
+)
+
+var (
+	ErrRefundRequestNotPending = errors.New("o pedido de reembolso já foi analisado")
+)
+
+// RequestRefund registra o pedido de reembolso do cliente, de amount centavos ou de todo o
+// saldo quando amount é zero. O valor só é devolvido quando a prestadora ou o suporte
+// aprovam o pedido.
+func (s *PaymentService) RequestRefund(payment *models.Payment, amount int64, reason string) (*models.RefundRequest, error) {
+	if amount == 0 {
+		amount = payment.Refundable()
+	}
+	request := &models.RefundRequest{
+		PaymentID: payment.ID,
+		Amount:    amount,
+		Reason:    reason,
+	}
+	requestedBy := payment.UserID
+	err := s.paymentRepo.CreateRefundRequest(request, s.auditService.Event(payment.AppointmentID, &requestedBy, models.AppointmentEventRefundAsked, nil, map[string]interface{}{
+		"payment_id": payment.ID,
+		"amount":     amount,
+		"reason":     reason,
+	}))
+	if err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// ReviewRefundRequest aprova ou recusa um pedido de reembolso pendente. Na aprovação, o
+// valor pedido é devolvido ao cliente; se o reembolso falhar, o pedido volta a aguardar
+// análise.
+func (s *PaymentService) ReviewRefundRequest(request *models.RefundRequest, approve bool, reviewedBy uint, note string) error {
+	now := s.now()
+	request.Status = models.RefundRequestStatusRejected
+	if approve {
+		request.Status = models.RefundRequestStatusApproved
+	}
+	request.ReviewedAt = &now
+	request.ReviewedBy = &reviewedBy
+	request.ReviewNote = note
+
+	reviewed := s.auditService.Event(request.AppointmentID, &reviewedBy, models.AppointmentEventRefundReviewed, models.RefundRequestStatusPending, map[string]interface{}{
+		"refund_request_id": request.ID,
+		"status":            request.Status,
+		"amount":            request.Amount,
+		"note":              note,
+	})
+	if !approve {
+		closed, err := s.paymentRepo.CloseRefundRequest(request, reviewed)
+		if err == nil && !closed {
+			err = ErrRefundRequestNotPending
+		}
+		return err
+	}
+
+	closed, err := s.paymentRepo.CloseRefundRequest(request)
+	if err != nil {
+		return err
+	}
+	if !closed {
+		return ErrRefundRequestNotPending
+	}
+
+	payment, err := s.paymentRepo.FindByID(request.PaymentID)
+	var refund *models.Refund
+	if err == nil {
+		refund, err = s.Refund(payment, request.Amount, request.Reason, &reviewedBy)
+	}
+	if err != nil {
+		// A aprovação não foi aplicada; o pedido volta a aguardar análise
+		if reopenErr := s.paymentRepo.ReopenRefundRequest(request); reopenErr != nil {
+			log.Printf("Erro ao reabrir pedido de reembolso %d: %v", request.ID, reopenErr)
+		}
+		return err
+	}
+	return s.paymentRepo.CompleteRefundRequest(request, refund.ID, reviewed)
+}
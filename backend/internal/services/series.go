@@ -0,0 +1,153 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+)
+
+// SeriesMaterializationHorizon define com quanta antecedência as ocorrências são criadas
+const SeriesMaterializationHorizon = 8 * 7 * 24 * time.Hour
+
+// Resultados possíveis ao materializar uma ocorrência
+const (
+	OccurrenceCreated  = "created"
+	OccurrenceConflict = "conflict"
+	OccurrenceCapped   = "capped"
+	OccurrenceExisting = "existing"
+)
+
+// OccurrenceResult descreve o resultado da materialização de uma ocorrência
+type OccurrenceResult struct {
+	Date          time.Time `json:"date"`
+	Status        string    `json:"status"`
+	AppointmentID uint      `json:"appointment_id,omitempty"`
+	ConflictsWith []uint    `json:"conflicts_with,omitempty"`
+	Reason        string    `json:"reason,omitempty"`
+}
+
+// SeriesService gera as ocorrências das séries de agendamentos recorrentes
+type SeriesService struct {
+	seriesRepo     *repositories.SeriesRepository
+	userRepo       *repositories.UserRepository
+	pricingService *PricingService
+	auditService   *AuditService
+	now            func() time.Time
+}
+
+func NewSeriesService(
+	seriesRepo *repositories.SeriesRepository,
+	userRepo *repositories.UserRepository,
+	pricingService *PricingService,
+	auditService *AuditService,
+) *SeriesService {
+	return &SeriesService{
+		seriesRepo:     seriesRepo,
+		userRepo:       userRepo,
+		pricingService: pricingService,
+		auditService:   auditService,
+		now:            time.Now,
+	}
+}
+
+// Materialize cria as ocorrências da série até o horizonte de materialização.
+// Conflitos são reportados por ocorrência, sem interromper as demais.
+func (s *SeriesService) Materialize(series *models.AppointmentSeries) ([]OccurrenceResult, error) {
+	rule, err := ParseRRule(series.RRule)
+	if err != nil {
+		return nil, err
+	}
+
+	var hourlyRate float64
+	if profile, err := s.userRepo.GetProviderProfile(series.ProviderID); err == nil {
+		hourlyRate = profile.HourlyRate
+	}
+
+	now := s.now()
+	horizon := now.Add(SeriesMaterializationHorizon)
+
+	var results []OccurrenceResult
+	for _, date := range rule.Occurrences(series.StartDate, horizon) {
+		if series.EndsBefore != nil && !date.Before(*series.EndsBefore) {
+			break
+		}
+		if date.Before(series.MaterializedUntil) {
+			continue
+		}
+
+		occurrenceDate := date
+		occurrence := &models.Appointment{
+			UserID:         series.UserID,
+			ProviderID:     series.ProviderID,
+			Service:        series.Service,
+			Date:           date,
+			Time:           series.Time,
+			Status:         models.AppointmentStatusPending,
+			Notes:          series.Notes,
+			Duration:       series.Duration,
+			Location:       series.Location,
+			Latitude:       series.Latitude,
+			Longitude:      series.Longitude,
+			SeriesID:       &series.ID,
+			OccurrenceDate: &occurrenceDate,
+		}
+		// Ocorrências que já começaram não são mais criadas
+		if occurrence.StartsAt().Before(now) {
+			continue
+		}
+		occurrence.Price = s.pricingService.Quote(hourlyRate, occurrence.Duration)
+
+		event := s.auditService.Event(0, &series.UserID, models.AppointmentEventCreated, nil, map[string]interface{}{
+			"status":    occurrence.Status,
+			"date":      occurrence.Date,
+			"time":      occurrence.Time,
+			"price":     occurrence.Price,
+			"series_id": series.ID,
+		})
+		created, conflicts, err := s.seriesRepo.MaterializeOccurrence(occurrence, event)
+		if repositories.IsBookingCapError(err) {
+			results = append(results, OccurrenceResult{Date: date, Status: OccurrenceCapped, Reason: err.Error()})
+			continue
+		}
+		if err != nil {
+			return results, err
+		}
+
+		result := OccurrenceResult{Date: date, Status: OccurrenceExisting}
+		switch {
+		case created:
+			result.Status = OccurrenceCreated
+			result.AppointmentID = occurrence.ID
+		case len(conflicts) > 0:
+			result.Status = OccurrenceConflict
+			for _, conflict := range conflicts {
+				result.ConflictsWith = append(result.ConflictsWith, conflict.ID)
+			}
+		}
+		results = append(results, result)
+	}
+
+	series.MaterializedUntil = horizon
+	if err := s.seriesRepo.UpdateMaterializedUntil(series.ID, horizon); err != nil {
+		return results, err
+	}
+
+	return results, nil
+}
+
+// MaterializeDue estende todas as séries ativas até o horizonte de materialização
+func (s *SeriesService) MaterializeDue() error {
+	due, err := s.seriesRepo.GetDueForMaterialization(s.now().Add(SeriesMaterializationHorizon))
+	if err != nil {
+		return err
+	}
+
+	for i := range due {
+		if _, err := s.Materialize(&due[i]); err != nil {
+			log.Printf("Erro ao materializar série %d: %v", due[i].ID, err)
+		}
+	}
+	return nil
+}
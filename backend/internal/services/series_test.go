@@ -0,0 +1,72 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/testutil"
+)
+
+func TestSeriesMaterializeReportsConflictsPerOccurrence(t *testing.T) {
+	db := testutil.NewDB(t)
+	client := testutil.CreateUser(t, db, models.UserTypeClient)
+	provider := testutil.CreateProvider(t, db, models.ProviderProfile{HourlyRate: 60})
+	// A prestadora já atende outro cliente no horário da segunda ocorrência
+	busy := testutil.CreateAppointment(t, db, models.Appointment{
+		UserID: testutil.CreateUser(t, db, models.UserTypeClient).ID, ProviderID: provider.ID,
+		Status: models.AppointmentStatusConfirmed, Date: time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC), Time: "11:00", Duration: 60,
+	})
+
+	seriesRepo := repositories.NewSeriesRepository(db, nil)
+	series := &models.AppointmentSeries{
+		UserID: client.ID, ProviderID: provider.ID, RRule: "FREQ=WEEKLY;COUNT=3",
+		StartDate: time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC), Time: "10:00", Service: "Limpeza", Duration: 120,
+	}
+	if err := seriesRepo.Create(series); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	service := NewSeriesService(seriesRepo, repositories.NewUserRepository(db), NewPricingService(),
+		NewAuditService(repositories.NewAppointmentEventRepository(db)))
+	service.now = func() time.Time { return now }
+
+	results, err := service.Materialize(series)
+	if err != nil {
+		t.Fatalf("Materialize: %v", err)
+	}
+	want := []string{OccurrenceCreated, OccurrenceConflict, OccurrenceCreated}
+	if len(results) != len(want) {
+		t.Fatalf("resultados %+v, want %v", results, want)
+	}
+	for i, result := range results {
+		if result.Status != want[i] {
+			t.Errorf("ocorrência %s: %s, want %s", result.Date.Format("2006-01-02"), result.Status, want[i])
+		}
+	}
+	if got := results[1].ConflictsWith; len(got) != 1 || got[0] != busy.ID {
+		t.Errorf("conflitos %v, want [%d]", got, busy.ID)
+	}
+
+	occurrences, err := seriesRepo.GetOccurrences(series.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(occurrences) != 2 || occurrences[0].Price != 120 || occurrences[0].SeriesID == nil || *occurrences[0].SeriesID != series.ID {
+		t.Errorf("ocorrências %+v; want duas, de R$ 120, ligadas à série", occurrences)
+	}
+	conflicts, err := seriesRepo.GetConflicts(series.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 1 || conflicts[0].ConflictingAppointmentID != busy.ID {
+		t.Errorf("conflitos registrados %+v, want um com o agendamento %d", conflicts, busy.ID)
+	}
+
+	// Uma nova materialização não repete as ocorrências já geradas
+	if results, err := service.Materialize(series); err != nil || len(results) != 0 {
+		t.Errorf("segunda materialização: %+v, %v; want nenhuma ocorrência", results, err)
+	}
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+)
+
+const (
+	// TipWindow é o prazo após o check-out para enviar uma gorjeta
+	TipWindow = 7 * 24 * time.Hour
+	// Limites do valor da gorjeta, em centavos
+	MinTipAmount = 100
+	MaxTipAmount = 50000
+	// maxTipMessageLength limita o recado enviado com a gorjeta
+	maxTipMessageLength = 280
+	// tipPendingTimeout é o tempo sem resposta do gateway antes de ReconcilePending consultar a intenção
+	tipPendingTimeout = 10 * time.Minute
+)
+
+var (
+	ErrTipNotAllowed        = errors.New("gorjetas só podem ser enviadas após atendimentos concluídos")
+	ErrTipWindowClosed      = errors.New("prazo para enviar gorjeta encerrado")
+	ErrInvalidTipAmount     = fmt.Errorf("a gorjeta deve ser de %d a %d centavos", MinTipAmount, MaxTipAmount)
+	ErrNoSavedPaymentMethod = errors.New("nenhum cartão salvo para cobrar a gorjeta")
+	ErrTipMessageTooLong    = fmt.Errorf("o recado deve ter até %d caracteres", maxTipMessageLength)
+	ErrTipPending           = errors.New("cobrança da gorjeta em processamento no gateway")
+)
+
+// TipService cobra as gorjetas no cartão salvo do cliente e as repassa integralmente à
+// prestadora, sem custódia nem comissão
+type TipService struct {
+	tipRepo      *repositories.TipRepository
+	paymentRepo  *repositories.PaymentRepository
+	gateway      PaymentGateway
+	ledger       *LedgerService
+	auditService *AuditService
+	now          func() time.Time
+}
+
+func NewTipService(
+	tipRepo *repositories.TipRepository,
+	paymentRepo *repositories.PaymentRepository,
+	gateway PaymentGateway,
+	ledger *LedgerService,
+	auditService *AuditService,
+) *TipService {
+	return &TipService{
+		tipRepo:      tipRepo,
+		paymentRepo:  paymentRepo,
+		gateway:      gateway,
+		ledger:       ledger,
+		auditService: auditService,
+		now:          time.Now,
+	}
+}
+
+// Send cobra a gorjeta do cliente no cartão informado ou, se nenhum for informado, no
+// último cartão salvo. Gorjetas recusadas podem ser enviadas novamente. Se o gateway falhar
+// durante a confirmação, a gorjeta fica pendente até que ReconcilePending consulte a intenção.
+func (s *TipService) Send(appointment *models.Appointment, amount int64, paymentMethodID, message string) (*models.Tip, error) {
+	if appointment.Status != models.AppointmentStatusCompleted {
+		return nil, ErrTipNotAllowed
+	}
+	// Atendimentos concluídos antes de CompletedAt existir contam a partir do check-out
+	completedAt := appointment.CompletedAt
+	if completedAt == nil {
+		completedAt = appointment.CheckedOutAt
+	}
+	if completedAt == nil {
+		return nil, ErrTipNotAllowed
+	}
+	if s.now().After(completedAt.Add(TipWindow)) {
+		return nil, ErrTipWindowClosed
+	}
+	if amount < MinTipAmount || amount > MaxTipAmount {
+		return nil, ErrInvalidTipAmount
+	}
+	message = strings.TrimSpace(message)
+	if len([]rune(message)) > maxTipMessageLength {
+		return nil, ErrTipMessageTooLong
+	}
+
+	method, err := s.paymentMethod(appointment.UserID, paymentMethodID)
+	if err != nil {
+		return nil, err
+	}
+
+	tip := &models.Tip{
+		AppointmentID:   appointment.ID,
+		UserID:          appointment.UserID,
+		ProviderID:      appointment.ProviderID,
+		Amount:          amount,
+		Currency:        models.PaymentCurrency,
+		Message:         message,
+		PaymentMethodID: method.GatewayMethodID,
+	}
+	if err := s.tipRepo.Reserve(tip); err != nil {
+		return nil, err
+	}
+	// Os lançamentos são montados antes da cobrança e gravados com a gorjeta paga
+	ledger, err := s.ledger.TipTransactions(tip)
+	if err != nil {
+		s.failed(tip, "erro ao preparar lançamentos")
+		return nil, err
+	}
+
+	intent, err := s.charge(tip)
+	if err != nil && tip.GatewayIntentID == "" {
+		// A intenção não chegou a ser confirmada: nada foi cobrado
+		s.failed(tip, err.Error())
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("Erro ao confirmar gorjeta %d no gateway, consultando a intenção: %v", tip.ID, err)
+		if intent, err = s.gateway.GetIntent(tip.GatewayIntentID); err != nil {
+			return tip, ErrTipPending
+		}
+	}
+	return tip, s.settle(tip, intent, ledger)
+}
+
+// charge cria a intenção da gorjeta no gateway, grava seu ID e a confirma. GatewayIntentID
+// só fica preenchido se a confirmação foi tentada.
+func (s *TipService) charge(tip *models.Tip) (*GatewayIntent, error) {
+	intent, err := s.gateway.CreateIntent(GatewayIntentRequest{
+		Amount:      tip.Amount,
+		Currency:    tip.Currency,
+		Description: fmt.Sprintf("Gorjeta do agendamento #%d", tip.AppointmentID),
+		Metadata: map[string]string{
+			"appointment_id": fmt.Sprint(tip.AppointmentID),
+			"tip_id":         fmt.Sprint(tip.ID),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	tip.GatewayIntentID = intent.ID
+	if err := s.tipRepo.UpdateStatus(tip, nil); err != nil {
+		tip.GatewayIntentID = ""
+		return nil, err
+	}
+
+	return s.gateway.ConfirmIntent(intent.ID, tip.PaymentMethodID)
+}
+
+// settle registra o estado da intenção da gorjeta informado pelo gateway. Intenções ainda
+// não concluídas deixam a gorjeta pendente.
+func (s *TipService) settle(tip *models.Tip, intent *GatewayIntent, ledger []*models.LedgerTransaction) error {
+	switch intent.Status {
+	case GatewayIntentSucceeded:
+		now := s.now()
+		tip.Status = models.TipStatusSucceeded
+		tip.FailureReason = ""
+		tip.PaidAt = &now
+		paid := s.auditService.Event(tip.AppointmentID, &tip.UserID, models.AppointmentEventTipPaid, nil, map[string]interface{}{
+			"tip_id": tip.ID,
+			"amount": tip.Amount,
+		})
+		return s.tipRepo.UpdateStatus(tip, ledger, paid)
+	case GatewayIntentFailed, GatewayIntentCanceled:
+		s.failed(tip, intent.FailureReason)
+		return fmt.Errorf("%w: %s", ErrPaymentDeclined, intent.FailureReason)
+	default:
+		return ErrTipPending
+	}
+}
+
+// ReconcilePending conclui as gorjetas que ficaram pendentes por falha do gateway ou do
+// servidor, consultando a intenção de cada uma. Intenções nunca confirmadas são canceladas
+// e a gorjeta é dada como falha, podendo ser enviada novamente.
+func (s *TipService) ReconcilePending() error {
+	tips, err := s.tipRepo.GetPendingBefore(s.now().Add(-tipPendingTimeout))
+	if err != nil {
+		return err
+	}
+
+	for i := range tips {
+		tip := &tips[i]
+		if tip.GatewayIntentID == "" {
+			s.failed(tip, "cobrança não concluída")
+			continue
+		}
+
+		intent, err := s.gateway.GetIntent(tip.GatewayIntentID)
+		if errors.Is(err, ErrGatewayIntentNotFound) {
+			s.failed(tip, err.Error())
+			continue
+		}
+		if err != nil {
+			log.Printf("Erro ao consultar gorjeta %d no gateway: %v", tip.ID, err)
+			continue
+		}
+		if intent.Status == GatewayIntentRequiresConfirmation {
+			if err := s.gateway.CancelIntent(intent.ID); err != nil {
+				log.Printf("Erro ao cancelar intenção da gorjeta %d: %v", tip.ID, err)
+				continue
+			}
+			intent.Status = GatewayIntentCanceled
+			intent.FailureReason = "cobrança não concluída"
+		}
+
+		var ledger []*models.LedgerTransaction
+		if intent.Status == GatewayIntentSucceeded {
+			if ledger, err = s.ledger.TipTransactions(tip); err != nil {
+				log.Printf("Erro ao preparar lançamentos da gorjeta %d: %v", tip.ID, err)
+				continue
+			}
+		}
+		if err := s.settle(tip, intent, ledger); err != nil && !errors.Is(err, ErrPaymentDeclined) {
+			log.Printf("Erro ao concluir gorjeta %d: %v", tip.ID, err)
+		}
+	}
+	return nil
+}
+
+// failed marca a gorjeta como recusada, permitindo um novo envio
+func (s *TipService) failed(tip *models.Tip, reason string) {
+	tip.Status = models.TipStatusFailed
+	tip.FailureReason = reason
+	if err := s.tipRepo.UpdateStatus(tip, nil); err != nil {
+		log.Printf("Erro ao registrar falha da gorjeta %d: %v", tip.ID, err)
+	}
+}
+
+// paymentMethod retorna o cartão salvo escolhido pelo cliente ou o mais recente
+func (s *TipService) paymentMethod(userID uint, paymentMethodID string) (*models.PaymentMethod, error) {
+	if paymentMethodID != "" {
+		return s.paymentRepo.FindMethod(userID, paymentMethodID)
+	}
+
+	methods, err := s.paymentRepo.GetMethods(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(methods) == 0 {
+		return nil, ErrNoSavedPaymentMethod
+	}
+	return &methods[0], nil
+}
+
+// History retorna as gorjetas enviadas pelo cliente
+func (s *TipService) History(userID uint) ([]models.Tip, error) {
+	return s.tipRepo.GetByUser(userID)
+}
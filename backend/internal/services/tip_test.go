@@ -0,0 +1,247 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/testutil"
+)
+
+func TestTipSend(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		wantErr      error
+		wantStatus   models.TipStatus
+		wantPostings int64
+		wantProvider int64 // saldo da prestadora no razão; saldos credores são negativos
+	}{
+		{"aprovada", "pm_card_visa", nil, models.TipStatusSucceeded, 2, -2000},
+		{"recusada", "pm_card_chargeDeclined", ErrPaymentDeclined, models.TipStatusFailed, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			client := testutil.CreateUser(t, db, models.UserTypeClient)
+			provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+			checkedOutAt := time.Now().Add(-time.Hour)
+			appointment := testutil.CreateAppointment(t, db, models.Appointment{
+				UserID: client.ID, ProviderID: provider.ID, Status: models.AppointmentStatusCompleted,
+				Date: time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC), CheckedOutAt: &checkedOutAt,
+			})
+
+			payments := newPaymentService(db, NewFakePaymentGateway())
+			tipRepo := repositories.NewTipRepository(db)
+			service := NewTipService(tipRepo, payments.paymentRepo, payments.gateway, payments.ledger, payments.auditService)
+			if _, err := service.Send(appointment, 2000, "", ""); !errors.Is(err, ErrNoSavedPaymentMethod) {
+				t.Fatalf("Send sem cartão salvo: %v; want %v", err, ErrNoSavedPaymentMethod)
+			}
+			if _, err := payments.SaveMethod(client.ID, tt.method); err != nil {
+				t.Fatal(err)
+			}
+
+			tip, err := service.Send(appointment, 2000, "", " Obrigado! ")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Send: %v; want %v", err, tt.wantErr)
+			}
+			stored, _ := tipRepo.FindByAppointment(appointment.ID)
+			var postings int64
+			db.Model(&models.LedgerTransaction{}).Where("tip_id = ?", tip.ID).Count(&postings)
+			balance := testutil.LedgerBalance(t, db, models.LedgerAccountProvider, &provider.ID)
+			if stored.Status != tt.wantStatus || stored.Message != "Obrigado!" || postings != tt.wantPostings || balance != tt.wantProvider {
+				t.Errorf("gorjeta %s %q com %d lançamentos, prestadora %d; want %s com %d, %d",
+					stored.Status, stored.Message, postings, balance, tt.wantStatus, tt.wantPostings, tt.wantProvider)
+			}
+
+			// Só uma gorjeta por atendimento; a recusada pode ser enviada de novo
+			_, err = service.Send(appointment, 2000, "", "")
+			if tt.wantStatus == models.TipStatusSucceeded && !errors.Is(err, repositories.ErrTipAlreadySent) {
+				t.Errorf("segunda gorjeta: %v; want %v", err, repositories.ErrTipAlreadySent)
+			}
+			if tt.wantStatus == models.TipStatusFailed && !errors.Is(err, ErrPaymentDeclined) {
+				t.Errorf("reenvio da gorjeta recusada: %v; want %v", err, ErrPaymentDeclined)
+			}
+		})
+	}
+}
+
+func TestTipSendValidates(t *testing.T) {
+	db := testutil.NewDB(t)
+	client := testutil.CreateUser(t, db, models.UserTypeClient)
+	provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+	payments := newPaymentService(db, NewFakePaymentGateway())
+	if _, err := payments.SaveMethod(client.ID, "pm_card_visa"); err != nil {
+		t.Fatal(err)
+	}
+	service := NewTipService(repositories.NewTipRepository(db), payments.paymentRepo, payments.gateway, payments.ledger, payments.auditService)
+
+	recent := time.Now().Add(-time.Hour)
+	longAgo := time.Now().Add(-TipWindow - time.Hour)
+	tests := []struct {
+		name       string
+		status     models.AppointmentStatus
+		checkedOut *time.Time
+		amount     int64
+		message    string
+		wantErr    error
+	}{
+		{"atendimento não concluído", models.AppointmentStatusConfirmed, &recent, 2000, "", ErrTipNotAllowed},
+		{"prazo encerrado", models.AppointmentStatusCompleted, &longAgo, 2000, "", ErrTipWindowClosed},
+		{"valor abaixo do mínimo", models.AppointmentStatusCompleted, &recent, MinTipAmount - 1, "", ErrInvalidTipAmount},
+		{"valor acima do máximo", models.AppointmentStatusCompleted, &recent, MaxTipAmount + 1, "", ErrInvalidTipAmount},
+		{"recado longo", models.AppointmentStatusCompleted, &recent, 2000, strings.Repeat("á", maxTipMessageLength+1), ErrTipMessageTooLong},
+		{"recado no limite", models.AppointmentStatusCompleted, &recent, 2000, strings.Repeat("á", maxTipMessageLength), nil},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appointment := testutil.CreateAppointment(t, db, models.Appointment{
+				UserID: client.ID, ProviderID: provider.ID, Status: tt.status,
+				Date: time.Date(2026, 3, 10+i, 0, 0, 0, 0, time.UTC), CheckedOutAt: tt.checkedOut,
+			})
+			if _, err := service.Send(appointment, tt.amount, "", tt.message); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Send: %v; want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+var errGatewayTimeout = errors.New("tempo esgotado no gateway")
+
+// flakyGateway simula quedas do gateway na confirmação da intenção, antes ou depois da
+// captura, e na consulta da intenção
+type flakyGateway struct {
+	*FakePaymentGateway
+	confirmFails   bool
+	captureFirst   bool
+	lookupDown     bool
+	confirmedCalls int
+}
+
+func (g *flakyGateway) ConfirmIntent(intentID, paymentMethodID string) (*GatewayIntent, error) {
+	g.confirmedCalls++
+	if !g.confirmFails {
+		return g.FakePaymentGateway.ConfirmIntent(intentID, paymentMethodID)
+	}
+	if g.captureFirst {
+		g.FakePaymentGateway.ConfirmIntent(intentID, paymentMethodID)
+	}
+	return nil, errGatewayTimeout
+}
+
+func (g *flakyGateway) GetIntent(intentID string) (*GatewayIntent, error) {
+	if g.lookupDown {
+		return nil, errGatewayTimeout
+	}
+	return g.FakePaymentGateway.GetIntent(intentID)
+}
+
+func TestTipSendSurvivesGatewayErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		confirmFails bool
+		captureFirst bool
+		lookupDown   bool
+		wantErr      error
+		wantStatus   models.TipStatus // após o envio
+		wantFinal    models.TipStatus // após ReconcilePending, com o gateway de volta
+		wantPostings int64
+	}{
+		{"aprovada", "pm_card_visa", false, false, false, nil, models.TipStatusSucceeded, models.TipStatusSucceeded, 2},
+		{"recusada", "pm_card_chargeDeclined", false, false, false, ErrPaymentDeclined, models.TipStatusFailed, models.TipStatusFailed, 0},
+		{"queda após a captura, consulta imediata", "pm_card_visa", true, true, false, nil, models.TipStatusSucceeded, models.TipStatusSucceeded, 2},
+		{"queda após a captura, consulta pelo job", "pm_card_visa", true, true, true, ErrTipPending, models.TipStatusPending, models.TipStatusSucceeded, 2},
+		{"queda antes da captura", "pm_card_visa", true, false, true, ErrTipPending, models.TipStatusPending, models.TipStatusFailed, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			client := testutil.CreateUser(t, db, models.UserTypeClient)
+			provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+			completedAt := time.Now().Add(-time.Hour)
+			appointment := testutil.CreateAppointment(t, db, models.Appointment{
+				UserID: client.ID, ProviderID: provider.ID, Status: models.AppointmentStatusCompleted,
+				Date: time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC), CompletedAt: &completedAt,
+			})
+
+			gateway := &flakyGateway{FakePaymentGateway: NewFakePaymentGateway()}
+			payments := newPaymentService(db, gateway)
+			if _, err := payments.SaveMethod(client.ID, tt.method); err != nil {
+				t.Fatal(err)
+			}
+			tipRepo := repositories.NewTipRepository(db)
+			service := NewTipService(tipRepo, payments.paymentRepo, gateway, payments.ledger, payments.auditService)
+
+			gateway.confirmFails, gateway.captureFirst, gateway.lookupDown = tt.confirmFails, tt.captureFirst, tt.lookupDown
+			tip, err := service.Send(appointment, 2000, "", "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Send: %v; want %v", err, tt.wantErr)
+			}
+			stored, _ := tipRepo.FindByAppointment(appointment.ID)
+			if stored.Status != tt.wantStatus {
+				t.Fatalf("gorjeta %s após o envio; want %s", stored.Status, tt.wantStatus)
+			}
+
+			// Gorjetas pendentes não podem ser reenviadas, para não cobrar duas vezes
+			if tt.wantStatus == models.TipStatusPending {
+				if _, err := service.Send(appointment, 2000, "", ""); !errors.Is(err, repositories.ErrTipAlreadySent) {
+					t.Errorf("reenvio da gorjeta pendente: %v; want %v", err, repositories.ErrTipAlreadySent)
+				}
+			}
+
+			gateway.confirmFails, gateway.lookupDown = false, false
+			service.now = func() time.Time { return time.Now().Add(tipPendingTimeout + time.Minute) }
+			if err := service.ReconcilePending(); err != nil {
+				t.Fatal(err)
+			}
+
+			stored, _ = tipRepo.FindByAppointment(appointment.ID)
+			var postings int64
+			db.Model(&models.LedgerTransaction{}).Where("tip_id = ?", tip.ID).Count(&postings)
+			if stored.Status != tt.wantFinal || postings != tt.wantPostings || gateway.confirmedCalls != 1 {
+				t.Errorf("gorjeta %s com %d lançamentos e %d confirmações; want %s com %d e 1",
+					stored.Status, postings, gateway.confirmedCalls, tt.wantFinal, tt.wantPostings)
+			}
+		})
+	}
+}
+
+func TestTipWindowUsesCompletion(t *testing.T) {
+	db := testutil.NewDB(t)
+	client := testutil.CreateUser(t, db, models.UserTypeClient)
+	provider := testutil.CreateProvider(t, db, models.ProviderProfile{})
+	payments := newPaymentService(db, NewFakePaymentGateway())
+	if _, err := payments.SaveMethod(client.ID, "pm_card_visa"); err != nil {
+		t.Fatal(err)
+	}
+	service := NewTipService(repositories.NewTipRepository(db), payments.paymentRepo, payments.gateway, payments.ledger, payments.auditService)
+
+	longAgo := time.Now().Add(-TipWindow - time.Hour)
+	recent := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name        string
+		completedAt *time.Time
+		checkedOut  *time.Time
+		wantErr     error
+	}{
+		{"dentro do prazo", &recent, nil, nil},
+		{"prazo encerrado mesmo com alterações recentes", &longAgo, nil, ErrTipWindowClosed},
+		{"concluído antes de CompletedAt, pelo check-out", nil, &recent, nil},
+		{"sem data de conclusão", nil, nil, ErrTipNotAllowed},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appointment := testutil.CreateAppointment(t, db, models.Appointment{
+				UserID: client.ID, ProviderID: provider.ID, Status: models.AppointmentStatusCompleted,
+				Date: time.Date(2026, 3, 10+i, 0, 0, 0, 0, time.UTC), CompletedAt: tt.completedAt, CheckedOutAt: tt.checkedOut,
+			})
+			if _, err := service.Send(appointment, 2000, "", ""); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Send: %v; want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"math"
+	"time"
+)
+
+// DefaultAverageSpeedKmh é a velocidade média usada para estimar o deslocamento na cidade
+const DefaultAverageSpeedKmh = 25.0
+
+// travelRounding arredonda as estimativas de deslocamento para cima
+const travelRounding = 5 * time.Minute
+
+// HaversineTravelEstimator estima o deslocamento pela distância em linha reta a uma
+// velocidade média. É a estimativa padrão quando não há um serviço de rotas configurado.
+type HaversineTravelEstimator struct {
+	AverageSpeedKmh float64
+}
+
+func NewHaversineTravelEstimator(averageSpeedKmh float64) *HaversineTravelEstimator {
+	if averageSpeedKmh <= 0 {
+		averageSpeedKmh = DefaultAverageSpeedKmh
+	}
+	return &HaversineTravelEstimator{
+		AverageSpeedKmh: averageSpeedKmh,
+	}
+}
+
+// TravelTime estima o tempo de deslocamento entre dois pontos, arredondado para cima em blocos de 5 minutos
+func (e *HaversineTravelEstimator) TravelTime(fromLat, fromLng, toLat, toLng float64) (time.Duration, error) {
+	hours := HaversineKm(fromLat, fromLng, toLat, toLng) / e.AverageSpeedKmh
+	blocks := math.Ceil(hours * float64(time.Hour) / float64(travelRounding))
+	return time.Duration(blocks) * travelRounding, nil
+}
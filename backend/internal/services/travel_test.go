@@ -0,0 +1,33 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHaversineTravelTimeRoundsUp(t *testing.T) {
+	estimator := NewHaversineTravelEstimator(0)
+	tests := []struct {
+		name             string
+		fromLat, fromLng float64
+		toLat, toLng     float64
+		want             time.Duration
+	}{
+		{"mesmo endereço", -23.55, -46.63, -23.55, -46.63, 0},
+		// Cerca de 1,1 km a 25 km/h leva pouco mais de 2 minutos
+		{"mesmo bairro", -23.55, -46.63, -23.56, -46.63, 5 * time.Minute},
+		// Cerca de 11,1 km leva 26,7 minutos
+		{"outra região", -23.55, -46.63, -23.65, -46.63, 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := estimator.TravelTime(tt.fromLat, tt.fromLng, tt.toLat, tt.toLng)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("TravelTime = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
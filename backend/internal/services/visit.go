@@ -0,0 +1,107 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+)
+
+const (
+	// CheckInToleranceMeters é a distância máxima aceita entre o dispositivo e o local do agendamento
+	CheckInToleranceMeters = 200
+	// CheckInEarlyWindow define quanto tempo antes do início o check-in é permitido
+	CheckInEarlyWindow = 30 * time.Minute
+	// OvertimeGracePeriod é a tolerância antes de o tempo extra ser cobrado
+	OvertimeGracePeriod = 15 * time.Minute
+	// OvertimeBlockMinutes é o bloco mínimo de cobrança do tempo extra
+	OvertimeBlockMinutes = 15
+)
+
+var (
+	ErrCheckInNotAllowed  = errors.New("check-in só é permitido em agendamentos confirmados")
+	ErrCheckInTooEarly    = errors.New("check-in ainda não está liberado para este agendamento")
+	ErrCheckOutNotAllowed = errors.New("check-out só é permitido após o check-in")
+	ErrOutsideGeofence    = errors.New("localização fora do raio permitido para o agendamento")
+	ErrVisitLocationUnset = errors.New("agendamento sem coordenadas do local; não é possível validar a presença")
+)
+
+// VisitService valida o check-in e o check-out da prestadora e calcula o tempo extra
+type VisitService struct {
+	pricingService *PricingService
+	now            func() time.Time
+}
+
+func NewVisitService(pricingService *PricingService) *VisitService {
+	return &VisitService{
+		pricingService: pricingService,
+		now:            time.Now,
+	}
+}
+
+// CheckIn registra a chegada da prestadora e inicia o atendimento
+func (s *VisitService) CheckIn(appointment *models.Appointment, lat, lng float64) error {
+	if appointment.Status != models.AppointmentStatusConfirmed {
+		return ErrCheckInNotAllowed
+	}
+
+	now := s.now()
+	if now.Before(appointment.StartsAt().Add(-CheckInEarlyWindow)) {
+		return ErrCheckInTooEarly
+	}
+
+	if err := checkGeofence(appointment, lat, lng); err != nil {
+		return err
+	}
+
+	appointment.Status = models.AppointmentStatusInProgress
+	appointment.CheckedInAt = &now
+	appointment.CheckInLatitude = lat
+	appointment.CheckInLongitude = lng
+	return nil
+}
+
+// CheckOut registra a saída da prestadora, conclui o atendimento e calcula a
+// duração real e o tempo extra a ser cobrado pelo valor por hora informado
+func (s *VisitService) CheckOut(appointment *models.Appointment, lat, lng, hourlyRate float64) error {
+	if appointment.Status != models.AppointmentStatusInProgress || appointment.CheckedInAt == nil {
+		return ErrCheckOutNotAllowed
+	}
+
+	if err := checkGeofence(appointment, lat, lng); err != nil {
+		return err
+	}
+
+	now := s.now()
+	appointment.Status = models.AppointmentStatusCompleted
+	appointment.CheckedOutAt = &now
+	appointment.CompletedAt = &now
+	appointment.CheckOutLatitude = lat
+	appointment.CheckOutLongitude = lng
+
+	actual := now.Sub(*appointment.CheckedInAt)
+	appointment.ActualDuration = int(math.Round(actual.Minutes()))
+
+	scheduled := appointment.EndsAt().Sub(appointment.StartsAt())
+	overtime := actual - scheduled
+	if overtime > OvertimeGracePeriod {
+		blocks := math.Ceil(overtime.Minutes() / OvertimeBlockMinutes)
+		appointment.OvertimeMinutes = int(blocks) * OvertimeBlockMinutes
+		appointment.OvertimeCharge = s.pricingService.Quote(hourlyRate, appointment.OvertimeMinutes)
+	}
+
+	return nil
+}
+
+// checkGeofence verifica se a coordenada está dentro da tolerância do local do agendamento.
+// Agendamentos sem coordenadas cadastradas não podem ser validados e são recusados.
+func checkGeofence(appointment *models.Appointment, lat, lng float64) error {
+	if appointment.Latitude == 0 && appointment.Longitude == 0 {
+		return ErrVisitLocationUnset
+	}
+	if HaversineKm(appointment.Latitude, appointment.Longitude, lat, lng)*1000 > CheckInToleranceMeters {
+		return ErrOutsideGeofence
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+)
+
+func TestVisitCheckInGeofence(t *testing.T) {
+	startsAt := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	service := NewVisitService(NewPricingService())
+	service.now = func() time.Time { return startsAt.Add(-10 * time.Minute) }
+
+	tests := []struct {
+		name             string
+		siteLat, siteLng float64
+		lat, lng         float64
+		want             error
+	}{
+		{"no local", -23.5505, -46.6333, -23.5506, -46.6334, nil},
+		{"fora do raio", -23.5505, -46.6333, -23.5605, -46.6333, ErrOutsideGeofence},
+		{"agendamento sem coordenadas", 0, 0, -23.5505, -46.6333, ErrVisitLocationUnset},
+		{"coordenada zero válida", 0, 0.0005, 0, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appointment := &models.Appointment{
+				Date:      startsAt,
+				Time:      "09:00",
+				Status:    models.AppointmentStatusConfirmed,
+				Latitude:  tt.siteLat,
+				Longitude: tt.siteLng,
+			}
+			err := service.CheckIn(appointment, tt.lat, tt.lng)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("CheckIn: err = %v, want %v", err, tt.want)
+			}
+			if err == nil && appointment.Status != models.AppointmentStatusInProgress {
+				t.Errorf("CheckIn: status = %s, want in_progress", appointment.Status)
+			}
+		})
+	}
+}
+
+func TestVisitCheckInTooEarly(t *testing.T) {
+	startsAt := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	service := NewVisitService(NewPricingService())
+	service.now = func() time.Time { return startsAt.Add(-time.Hour) }
+
+	appointment := &models.Appointment{
+		Date: startsAt, Time: "09:00", Status: models.AppointmentStatusConfirmed,
+		Latitude: -23.5505, Longitude: -46.6333,
+	}
+	if err := service.CheckIn(appointment, -23.5505, -46.6333); !errors.Is(err, ErrCheckInTooEarly) {
+		t.Errorf("CheckIn: err = %v, want ErrCheckInTooEarly", err)
+	}
+}
+
+func TestVisitCheckOutOvertime(t *testing.T) {
+	startsAt := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		worked   time.Duration
+		minutes  int
+		charge   float64
+		duration int
+	}{
+		{"no horário", 2 * time.Hour, 0, 0, 120},
+		{"dentro da tolerância", 2*time.Hour + 15*time.Minute, 0, 0, 135},
+		{"um bloco", 2*time.Hour + 16*time.Minute, 30, 30, 136},
+		{"dois blocos", 2*time.Hour + 31*time.Minute, 45, 45, 151},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewVisitService(NewPricingService())
+			service.now = func() time.Time { return startsAt.Add(tt.worked) }
+			checkedIn := startsAt
+			appointment := &models.Appointment{
+				Date: startsAt, Time: "09:00", Duration: 120, Status: models.AppointmentStatusInProgress,
+				Latitude: -23.5505, Longitude: -46.6333, CheckedInAt: &checkedIn,
+			}
+
+			if err := service.CheckOut(appointment, -23.5505, -46.6333, 60); err != nil {
+				t.Fatalf("CheckOut: %v", err)
+			}
+			if appointment.Status != models.AppointmentStatusCompleted {
+				t.Errorf("status = %s, want completed", appointment.Status)
+			}
+			if appointment.OvertimeMinutes != tt.minutes || appointment.OvertimeCharge != tt.charge || appointment.ActualDuration != tt.duration {
+				t.Errorf("tempo extra = %d min, R$ %v, duração %d; want %d min, R$ %v, duração %d",
+					appointment.OvertimeMinutes, appointment.OvertimeCharge, appointment.ActualDuration, tt.minutes, tt.charge, tt.duration)
+			}
+		})
+	}
+}
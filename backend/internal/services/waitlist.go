@@ -0,0 +1,200 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+)
+
+// WaitlistHoldDuration é por quanto tempo a vaga fica reservada para o cliente que a recebeu
+const WaitlistHoldDuration = 30 * time.Minute
+
+var (
+	ErrWaitlistOfferExpired = errors.New("a reserva da vaga expirou")
+)
+
+// WaitlistService oferece as vagas liberadas aos clientes da lista de espera, por ordem
+// de inscrição, passando ao próximo quando a reserva expira ou é recusada
+type WaitlistService struct {
+	waitlistRepo        *repositories.WaitlistRepository
+	userRepo            *repositories.UserRepository
+	availabilityService *AvailabilityService
+	pricingService      *PricingService
+	bookingRules        *BookingRulesService
+	auditService        *AuditService
+	now                 func() time.Time
+}
+
+func NewWaitlistService(
+	waitlistRepo *repositories.WaitlistRepository,
+	userRepo *repositories.UserRepository,
+	availabilityService *AvailabilityService,
+	pricingService *PricingService,
+	bookingRules *BookingRulesService,
+	auditService *AuditService,
+) *WaitlistService {
+	return &WaitlistService{
+		waitlistRepo:        waitlistRepo,
+		userRepo:            userRepo,
+		availabilityService: availabilityService,
+		pricingService:      pricingService,
+		bookingRules:        bookingRules,
+		auditService:        auditService,
+		now:                 time.Now,
+	}
+}
+
+// SlotFreed oferece o horário de um agendamento cancelado à lista de espera da prestadora
+func (s *WaitlistService) SlotFreed(appointment *models.Appointment) error {
+	if !appointment.StartsAt().After(s.now()) {
+		return nil
+	}
+	_, err := s.offerSlot(appointment.ProviderID, appointment.StartsAt(), appointment.EndsAt())
+	return err
+}
+
+// Accept aceita a vaga ofertada e cria o agendamento para o cliente
+func (s *WaitlistService) Accept(offer *models.WaitlistOffer) (*models.Appointment, error) {
+	now := s.now()
+	if !offer.ExpiresAt.After(now) {
+		return nil, ErrWaitlistOfferExpired
+	}
+
+	entry, err := s.waitlistRepo.FindEntry(offer.EntryID)
+	if err != nil {
+		return nil, err
+	}
+
+	appointment := &models.Appointment{
+		UserID:     entry.UserID,
+		ProviderID: entry.ProviderID,
+		Service:    entry.Service,
+		Date:       offer.SlotStart,
+		Time:       offer.SlotStart.Format("15:04"),
+		Status:     models.AppointmentStatusPending,
+		Notes:      entry.Notes,
+		Duration:   offer.Duration,
+		Location:   entry.Location,
+		Latitude:   entry.Latitude,
+		Longitude:  entry.Longitude,
+	}
+	profile, err := s.userRepo.GetProviderProfile(entry.ProviderID)
+	if err != nil {
+		profile = nil
+	}
+
+	// A antecedência mínima pode ter passado enquanto a vaga estava reservada
+	if err := s.bookingRules.CheckWindow(profile, appointment.StartsAt()); err != nil {
+		return nil, err
+	}
+	if profile != nil {
+		appointment.Price = s.pricingService.Quote(profile.HourlyRate, appointment.Duration)
+	}
+
+	created := s.auditService.Event(0, &entry.UserID, models.AppointmentEventCreated, nil, map[string]interface{}{
+		"status":            appointment.Status,
+		"date":              appointment.Date,
+		"time":              appointment.Time,
+		"price":             appointment.Price,
+		"waitlist_offer_id": offer.ID,
+	})
+	if err := s.waitlistRepo.AcceptOffer(offer, appointment, now, created); err != nil {
+		return nil, err
+	}
+	return appointment, nil
+}
+
+// Decline recusa a vaga e a oferece ao próximo cliente da lista
+func (s *WaitlistService) Decline(offer *models.WaitlistOffer) error {
+	if err := s.waitlistRepo.CloseOffer(offer, models.WaitlistOfferStatusDeclined); err != nil {
+		return err
+	}
+	_, err := s.offerSlot(offer.ProviderID, offer.SlotStart, offer.SlotEnd)
+	return err
+}
+
+// ProcessExpired passa adiante as vagas cuja reserva expirou e encerra as inscrições vencidas
+func (s *WaitlistService) ProcessExpired() error {
+	now := s.now()
+
+	offers, err := s.waitlistRepo.GetExpiredOffers(now)
+	if err != nil {
+		return err
+	}
+	for i := range offers {
+		offer := &offers[i]
+		if err := s.waitlistRepo.CloseOffer(offer, models.WaitlistOfferStatusExpired); err != nil {
+			log.Printf("Erro ao expirar oferta de vaga %d: %v", offer.ID, err)
+			continue
+		}
+		if _, err := s.offerSlot(offer.ProviderID, offer.SlotStart, offer.SlotEnd); err != nil {
+			log.Printf("Erro ao oferecer vaga da oferta %d ao próximo cliente: %v", offer.ID, err)
+		}
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return s.waitlistRepo.ExpireEntries(today)
+}
+
+// offerSlot oferece a vaga [slotStart, slotEnd) ao primeiro cliente elegível da lista.
+// Retorna nil se nenhum cliente puder recebê-la.
+func (s *WaitlistService) offerSlot(providerID uint, slotStart, slotEnd time.Time) (*models.WaitlistOffer, error) {
+	now := s.now()
+	if !slotStart.After(now) {
+		return nil, nil
+	}
+
+	day := time.Date(slotStart.Year(), slotStart.Month(), slotStart.Day(), 0, 0, 0, 0, slotStart.Location())
+	entries, err := s.waitlistRepo.GetWaitingForDay(providerID, day, slotStart)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		duration := entry.Duration
+		if duration <= 0 {
+			duration = models.DefaultAppointmentDuration
+		}
+		if slotStart.Add(time.Duration(duration) * time.Minute).After(slotEnd) {
+			continue
+		}
+
+		available, err := s.availabilityService.IsAvailable(SlotQuery{
+			ProviderID: providerID,
+			Date:       slotStart,
+			Duration:   duration,
+			UserID:     entry.UserID,
+			Latitude:   entry.Latitude,
+			Longitude:  entry.Longitude,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !available {
+			continue
+		}
+
+		offer := &models.WaitlistOffer{
+			EntryID:    entry.ID,
+			UserID:     entry.UserID,
+			ProviderID: providerID,
+			SlotStart:  slotStart,
+			SlotEnd:    slotEnd,
+			Duration:   duration,
+			ExpiresAt:  now.Add(WaitlistHoldDuration),
+			Status:     models.WaitlistOfferStatusPending,
+		}
+		if err := s.waitlistRepo.CreateOffer(offer); err != nil {
+			if errors.Is(err, repositories.ErrWaitlistOfferClosed) {
+				continue
+			}
+			return nil, err
+		}
+		return offer, nil
+	}
+
+	return nil, nil
+}
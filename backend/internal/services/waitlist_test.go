@@ -0,0 +1,150 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/testutil"
+	"gorm.io/gorm"
+)
+
+// newWaitlistService monta a lista de espera com todos os serviços no mesmo relógio
+func newWaitlistService(db *gorm.DB, clock func() time.Time) *WaitlistService {
+	waitlistRepo := repositories.NewWaitlistRepository(db, nil)
+	userRepo := repositories.NewUserRepository(db)
+	bookingRules := &BookingRulesService{now: clock}
+	availability := NewAvailabilityService(repositories.NewAppointmentRepository(db, nil), userRepo, waitlistRepo,
+		repositories.NewExternalCalendarRepository(db), bookingRules)
+	availability.now = clock
+	service := NewWaitlistService(waitlistRepo, userRepo, availability, NewPricingService(), bookingRules,
+		NewAuditService(repositories.NewAppointmentEventRepository(db)))
+	service.now = clock
+	return service
+}
+
+func TestWaitlistAcceptCreatesAppointment(t *testing.T) {
+	tests := []struct {
+		name     string
+		acceptIn time.Duration // Tempo entre a oferta e o aceite
+		wantErr  error
+	}{
+		{"dentro da reserva", 10 * time.Minute, nil},
+		{"reserva expirada", WaitlistHoldDuration + time.Minute, ErrWaitlistOfferExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			client := testutil.CreateUser(t, db, models.UserTypeClient)
+			provider := testutil.CreateProvider(t, db, models.ProviderProfile{HourlyRate: 40})
+			day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+			testutil.CreateWaitlistEntry(t, db, models.WaitlistEntry{
+				UserID: client.ID, ProviderID: provider.ID, WindowStart: day, WindowEnd: day, Duration: 120,
+			})
+
+			now := day.Add(8 * time.Hour)
+			service := newWaitlistService(db, func() time.Time { return now })
+			offer, err := service.offerSlot(provider.ID, day.Add(10*time.Hour), day.Add(12*time.Hour))
+			if err != nil || offer == nil {
+				t.Fatalf("offerSlot: %+v, %v; want uma oferta", offer, err)
+			}
+
+			now = now.Add(tt.acceptIn)
+			appointment, err := service.Accept(offer)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Accept: err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if appointment.UserID != client.ID || appointment.Time != "10:00" || appointment.Duration != 120 || appointment.Price != 80 {
+				t.Errorf("agendamento %+v; want do cliente às 10:00, 120 minutos, R$ 80", appointment)
+			}
+			var stored models.WaitlistOffer
+			db.First(&stored, offer.ID)
+			if stored.Status != models.WaitlistOfferStatusAccepted {
+				t.Errorf("oferta %s, want accepted", stored.Status)
+			}
+		})
+	}
+}
+
+func TestWaitlistAcceptChecksBookingWindow(t *testing.T) {
+	tests := []struct {
+		name      string
+		acceptIn  time.Duration // Tempo entre a oferta e o aceite
+		wantErr   error
+		wantPrice float64
+	}{
+		{"dentro da antecedência", 10 * time.Minute, nil, 80},
+		{"antecedência vencida durante a reserva", 25 * time.Minute, ErrBookingTooSoon, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			client := testutil.CreateUser(t, db, models.UserTypeClient)
+			provider := testutil.CreateProvider(t, db, models.ProviderProfile{HourlyRate: 40, MinNoticeMinutes: 100})
+			day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+
+			testutil.CreateWaitlistEntry(t, db, models.WaitlistEntry{
+				UserID: client.ID, ProviderID: provider.ID, WindowStart: day, WindowEnd: day, Duration: 120,
+			})
+
+			now := time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC)
+			service := newWaitlistService(db, func() time.Time { return now })
+
+			// O cancelamento libera as 10:00, ainda dentro da antecedência mínima
+			offer, err := service.offerSlot(provider.ID, day.Add(10*time.Hour), day.Add(12*time.Hour))
+			if err != nil || offer == nil {
+				t.Fatalf("offerSlot: %+v, %v", offer, err)
+			}
+
+			now = now.Add(tt.acceptIn)
+			appointment, err := service.Accept(offer)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Accept: %v, want %v", err, tt.wantErr)
+			}
+
+			var created int64
+			db.Model(&models.Appointment{}).Count(&created)
+			if tt.wantErr != nil {
+				if created != 0 {
+					t.Errorf("%d agendamentos criados; want nenhum", created)
+				}
+				return
+			}
+			if created != 1 || appointment.Price != tt.wantPrice {
+				t.Errorf("%d agendamentos com preço %.2f; want 1 com %.2f", created, appointment.Price, tt.wantPrice)
+			}
+		})
+	}
+}
+
+func TestWaitlistDeclinePassesSlotToNextClient(t *testing.T) {
+	db := testutil.NewDB(t)
+	provider := testutil.CreateProvider(t, db, models.ProviderProfile{HourlyRate: 40})
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	first := testutil.CreateWaitlistEntry(t, db, models.WaitlistEntry{
+		UserID: testutil.CreateUser(t, db, models.UserTypeClient).ID, ProviderID: provider.ID, WindowStart: day, WindowEnd: day, Duration: 120,
+	})
+	second := testutil.CreateWaitlistEntry(t, db, models.WaitlistEntry{
+		UserID: testutil.CreateUser(t, db, models.UserTypeClient).ID, ProviderID: provider.ID, WindowStart: day, WindowEnd: day, Duration: 120,
+	})
+
+	service := newWaitlistService(db, func() time.Time { return day.Add(8 * time.Hour) })
+	offer, err := service.offerSlot(provider.ID, day.Add(10*time.Hour), day.Add(12*time.Hour))
+	if err != nil || offer == nil || offer.EntryID != first.ID {
+		t.Fatalf("primeira oferta %+v, %v; want para a inscrição %d", offer, err, first.ID)
+	}
+	if err := service.Decline(offer); err != nil {
+		t.Fatal(err)
+	}
+
+	var offers []models.WaitlistOffer
+	db.Order("id").Find(&offers)
+	if len(offers) != 2 || offers[0].Status != models.WaitlistOfferStatusDeclined || offers[1].EntryID != second.ID {
+		t.Errorf("ofertas %+v; want a recusada e uma nova para a inscrição %d", offers, second.ID)
+	}
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+)
+
+const (
+	// WebhookMaxAttempts é o número de tentativas de processamento antes de o evento
+	// ficar com falha, aguardando reprocessamento por um admin
+	WebhookMaxAttempts = 8
+	// WebhookRetryDelay é a espera antes da segunda tentativa, dobrada a cada nova falha
+	WebhookRetryDelay = time.Minute
+	// WebhookProcessingLease é o tempo reservado a uma tentativa; se o processo cair no
+	// meio, o evento volta à fila depois dele
+	WebhookProcessingLease = 5 * time.Minute
+	// WebhookDeliveryRetention é por quanto tempo a assinatura de uma notificação fica
+	// registrada para descartar repetições. Cobre a tolerância de horário dos provedores
+	// nos dois sentidos: assinaturas mais antigas já são recusadas na verificação.
+	WebhookDeliveryRetention = 2 * max(GatewayWebhookTolerance, PixWebhookTolerance)
+	// webhookBatchSize limita os eventos processados por execução da tarefa periódica
+	webhookBatchSize = 100
+)
+
+var (
+	ErrUnknownWebhookProvider  = errors.New("provedor de webhook desconhecido")
+	ErrInvalidWebhookSignature = errors.New("assinatura do webhook inválida")
+	ErrInvalidWebhookPayload   = errors.New("corpo do webhook inválido")
+	// ErrWebhookEventIgnored indica que o evento não tem efeito e não deve ser repetido,
+	// como um pagamento desconhecido
+	ErrWebhookEventIgnored = errors.New("evento ignorado")
+)
+
+// WebhookEventInput é um evento extraído do corpo de uma notificação
+type WebhookEventInput struct {
+	ID      string // ID do evento no provedor, usado para descartar reenvios
+	Type    string
+	Payload []byte // Corpo original do evento
+}
+
+// WebhookSource é um provedor que envia eventos por webhook. Cada provedor confere a
+// própria assinatura, extrai os eventos da notificação e aplica um evento já gravado.
+// Process deve ser idempotente: um evento pode ser aplicado mais de uma vez após falhas.
+// Erros com ErrWebhookEventIgnored encerram o evento sem novas tentativas.
+// Signature retorna a assinatura da notificação, usada para descartar repetições dela.
+type WebhookSource interface {
+	Verify(header http.Header, body []byte, now time.Time) error
+	Signature(header http.Header) string
+	Parse(body []byte) ([]WebhookEventInput, error)
+	Process(event *models.WebhookEvent) error
+}
+
+// WebhookReceipt resume uma notificação recebida
+type WebhookReceipt struct {
+	Received   int `json:"received"`
+	Duplicates int `json:"duplicates"`
+}
+
+// WebhookService recebe os webhooks dos provedores registrados. Os eventos são gravados
+// como chegaram, com o ID do provedor como chave única, e processados em segundo plano,
+// com novas tentativas em intervalos crescentes.
+type WebhookService struct {
+	webhookRepo *repositories.WebhookRepository
+	sources     map[string]WebhookSource
+	now         func() time.Time
+}
+
+func NewWebhookService(webhookRepo *repositories.WebhookRepository) *WebhookService {
+	return &WebhookService{
+		webhookRepo: webhookRepo,
+		sources:     map[string]WebhookSource{},
+		now:         time.Now,
+	}
+}
+
+// Register registra um provedor com o nome usado na URL do webhook
+func (s *WebhookService) Register(provider string, source WebhookSource) {
+	s.sources[provider] = source
+}
+
+// Ingest confere a assinatura da notificação e grava os eventos novos, que são processados
+// em segundo plano. Eventos já recebidos, ou de uma notificação com assinatura já recebida,
+// são contados como duplicados e não são gravados.
+func (s *WebhookService) Ingest(provider string, header http.Header, body []byte) (*WebhookReceipt, error) {
+	source, ok := s.sources[provider]
+	if !ok {
+		return nil, ErrUnknownWebhookProvider
+	}
+
+	now := s.now()
+	if err := source.Verify(header, body, now); err != nil {
+		if !errors.Is(err, ErrInvalidWebhookSignature) {
+			err = fmt.Errorf("%w: %v", ErrInvalidWebhookSignature, err)
+		}
+		return nil, err
+	}
+	inputs, err := source.Parse(body)
+	if err != nil {
+		if !errors.Is(err, ErrInvalidWebhookPayload) {
+			err = fmt.Errorf("%w: %v", ErrInvalidWebhookPayload, err)
+		}
+		return nil, err
+	}
+
+	events := make([]*models.WebhookEvent, 0, len(inputs))
+	for _, input := range inputs {
+		events = append(events, &models.WebhookEvent{
+			Provider:      provider,
+			EventID:       input.ID,
+			Type:          input.Type,
+			Payload:       string(input.Payload),
+			Status:        models.WebhookEventPending,
+			NextAttemptAt: &now,
+		})
+	}
+	delivery := &models.WebhookDelivery{
+		Provider:  provider,
+		Signature: strings.ToLower(source.Signature(header)),
+		ExpiresAt: now.Add(WebhookDeliveryRetention),
+	}
+	created, _, err := s.webhookRepo.SaveDelivery(delivery, events)
+	if err != nil {
+		return nil, err
+	}
+	// Uma notificação repetida, mesmo com assinatura válida, não grava nenhum evento
+	receipt := &WebhookReceipt{Received: len(created), Duplicates: len(events) - len(created)}
+
+	if len(created) > 0 {
+		go func() {
+			for _, event := range created {
+				s.process(event)
+			}
+		}()
+	}
+	return receipt, nil
+}
+
+// ProcessDue processa os eventos pendentes cuja próxima tentativa já chegou.
+// Executada periodicamente.
+func (s *WebhookService) ProcessDue() error {
+	if err := s.webhookRepo.PurgeDeliveries(s.now()); err != nil {
+		log.Printf("Erro ao apagar assinaturas de webhook expiradas: %v", err)
+	}
+
+	events, err := s.webhookRepo.GetDue(s.now(), webhookBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for i := range events {
+		s.process(&events[i])
+	}
+	return nil
+}
+
+// Retry devolve à fila um evento com falha ou ignorado e o processa em segundo plano
+func (s *WebhookService) Retry(event *models.WebhookEvent) error {
+	if err := s.webhookRepo.Retry(event, s.now()); err != nil {
+		return err
+	}
+
+	go s.process(event)
+	return nil
+}
+
+// process faz uma tentativa de processamento do evento, se ele ainda estiver pendente e
+// livre, e agenda a próxima em caso de falha temporária
+func (s *WebhookService) process(event *models.WebhookEvent) {
+	now := s.now()
+	claimed, err := s.webhookRepo.Claim(event, now, now.Add(WebhookProcessingLease))
+	if err != nil {
+		log.Printf("Erro ao reservar evento de webhook %d: %v", event.ID, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	err = ErrUnknownWebhookProvider
+	if source, ok := s.sources[event.Provider]; ok {
+		err = processSource(source, event)
+	}
+
+	finishedAt := s.now()
+	event.NextAttemptAt = nil
+	event.LastError = ""
+	switch {
+	case err == nil:
+		event.Status = models.WebhookEventProcessed
+		event.ProcessedAt = &finishedAt
+	case errors.Is(err, ErrWebhookEventIgnored):
+		event.Status = models.WebhookEventIgnored
+		event.LastError = err.Error()
+		event.ProcessedAt = &finishedAt
+	case event.Attempts >= WebhookMaxAttempts:
+		event.Status = models.WebhookEventFailed
+		event.LastError = err.Error()
+	default:
+		next := finishedAt.Add(WebhookRetryDelay << (event.Attempts - 1))
+		event.Status = models.WebhookEventPending
+		event.NextAttemptAt = &next
+		event.LastError = err.Error()
+	}
+
+	if err != nil && !errors.Is(err, ErrWebhookEventIgnored) {
+		log.Printf("Erro ao processar evento %s de %s (tentativa %d): %v", event.EventID, event.Provider, event.Attempts, err)
+	}
+	if err := s.webhookRepo.Finish(event); err != nil {
+		log.Printf("Erro ao gravar resultado do evento de webhook %d: %v", event.ID, err)
+	}
+}
+
+// processSource aplica o evento pela origem. Um pânico no processamento vira um erro
+// temporário, para que o evento siga o fluxo de novas tentativas em vez de derrubar o
+// servidor, que processa os eventos fora das requisições.
+func processSource(source WebhookSource, event *models.WebhookEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Pânico ao processar evento %s de %s: %v\n%s", event.EventID, event.Provider, r, debug.Stack())
+			err = fmt.Errorf("pânico no processamento: %v", r)
+		}
+	}()
+	return source.Process(event)
+}
+
+// SignWebhook assina o corpo de uma notificação: HMAC-SHA256 de "timestamp.corpo", em
+// hexadecimal. É o esquema usado pelos provedores de pagamento integrados.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature confere a assinatura de SignWebhook e se o horário da assinatura
+// está a até tolerance do horário do servidor, o que limita a repetição de notificações antigas
+func VerifyWebhookSignature(secret, timestamp, signature string, body []byte, now time.Time, tolerance time.Duration) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidWebhookSignature
+	}
+	sentAt := time.Unix(seconds, 0)
+	if now.Sub(sentAt) > tolerance || sentAt.Sub(now) > tolerance {
+		return ErrInvalidWebhookSignature
+	}
+
+	expected := SignWebhook(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		return ErrInvalidWebhookSignature
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/testutil"
+	"gorm.io/gorm"
+)
+
+// fakeWebhookSource aceita notificações assinadas por SignWebhook, com um evento por ID
+// separado por vírgulas no corpo, e processa os eventos com o erro configurado
+type fakeWebhookSource struct {
+	secret string
+	err    error
+}
+
+func (s *fakeWebhookSource) Verify(header http.Header, body []byte, now time.Time) error {
+	return VerifyWebhookSignature(s.secret, header.Get("X-Timestamp"), header.Get("X-Signature"), body, now, 5*time.Minute)
+}
+
+func (s *fakeWebhookSource) Signature(header http.Header) string {
+	return header.Get("X-Signature")
+}
+
+func (s *fakeWebhookSource) Parse(body []byte) ([]WebhookEventInput, error) {
+	var inputs []WebhookEventInput
+	for _, id := range strings.Split(string(body), ",") {
+		inputs = append(inputs, WebhookEventInput{ID: id, Type: "teste", Payload: []byte(id)})
+	}
+	return inputs, nil
+}
+
+func (s *fakeWebhookSource) Process(event *models.WebhookEvent) error {
+	return s.err
+}
+
+func signedWebhookHeader(secret string, at time.Time, body []byte) http.Header {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	header := http.Header{}
+	header.Set("X-Timestamp", timestamp)
+	header.Set("X-Signature", SignWebhook(secret, timestamp, body))
+	return header
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"id":"evt_1"}`)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := SignWebhook("segredo", timestamp, body)
+
+	tests := []struct {
+		name      string
+		timestamp string
+		signature string
+		body      []byte
+		now       time.Time
+		wantErr   error
+	}{
+		{"válida", timestamp, signature, body, now, nil},
+		{"em maiúsculas", timestamp, strings.ToUpper(signature), body, now, nil},
+		{"dentro da tolerância", timestamp, signature, body, now.Add(5 * time.Minute), nil},
+		{"fora da tolerância", timestamp, signature, body, now.Add(6 * time.Minute), ErrInvalidWebhookSignature},
+		{"do futuro", timestamp, signature, body, now.Add(-6 * time.Minute), ErrInvalidWebhookSignature},
+		{"corpo alterado", timestamp, signature, []byte(`{"id":"evt_2"}`), now, ErrInvalidWebhookSignature},
+		{"horário inválido", "ontem", signature, body, now, ErrInvalidWebhookSignature},
+		{"sem assinatura", timestamp, "", body, now, ErrInvalidWebhookSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhookSignature("segredo", tt.timestamp, tt.signature, tt.body, tt.now, 5*time.Minute)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyWebhookSignature: %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// waitWebhookEvents espera o processamento em segundo plano deixar want eventos com o status
+func waitWebhookEvents(t *testing.T, db *gorm.DB, status models.WebhookEventStatus, want int64) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var count int64
+		db.Model(&models.WebhookEvent{}).Where("status = ?", status).Count(&count)
+		if count == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d eventos com status %s; want %d", count, status, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWebhookIngestDeduplicatesEvents(t *testing.T) {
+	db := testutil.NewDB(t)
+	service := NewWebhookService(repositories.NewWebhookRepository(db))
+	service.Register("teste", &fakeWebhookSource{secret: "segredo"})
+
+	now := time.Now()
+	tests := []struct {
+		name          string
+		provider      string
+		body          string
+		header        http.Header
+		wantErr       error
+		wantReceived  int
+		wantDuplicate int
+	}{
+		{"provedor desconhecido", "outro", "evt_1", nil, ErrUnknownWebhookProvider, 0, 0},
+		{"assinatura inválida", "teste", "evt_1", signedWebhookHeader("errado", now, []byte("evt_1")), ErrInvalidWebhookSignature, 0, 0},
+		{"eventos novos", "teste", "evt_1,evt_2", nil, nil, 2, 0},
+		{"reenvio parcial", "teste", "evt_2,evt_3", nil, nil, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := tt.header
+			if header == nil {
+				header = signedWebhookHeader("segredo", now, []byte(tt.body))
+			}
+			receipt, err := service.Ingest(tt.provider, header, []byte(tt.body))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Ingest: %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (receipt.Received != tt.wantReceived || receipt.Duplicates != tt.wantDuplicate) {
+				t.Errorf("recibo %+v; want %d recebidos, %d duplicados", receipt, tt.wantReceived, tt.wantDuplicate)
+			}
+		})
+	}
+
+	waitWebhookEvents(t, db, models.WebhookEventProcessed, 3)
+}
+
+func TestWebhookProcessRetriesWithBackoff(t *testing.T) {
+	db := testutil.NewDB(t)
+	webhookRepo := repositories.NewWebhookRepository(db)
+	service := NewWebhookService(webhookRepo)
+	source := &fakeWebhookSource{err: errors.New("banco indisponível")}
+	service.Register("teste", source)
+
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	event := &models.WebhookEvent{Provider: "teste", EventID: "evt_1", Type: "teste", Payload: "{}",
+		Status: models.WebhookEventPending, NextAttemptAt: &now}
+	delivery := &models.WebhookDelivery{Provider: "teste", Signature: "assinatura", ExpiresAt: now.Add(WebhookDeliveryRetention)}
+	if _, _, err := webhookRepo.SaveDelivery(delivery, []*models.WebhookEvent{event}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Cada falha dobra a espera até a próxima tentativa
+	delay := WebhookRetryDelay
+	for attempt := 1; attempt < WebhookMaxAttempts; attempt++ {
+		service.now = func() time.Time { return now }
+		if err := service.ProcessDue(); err != nil {
+			t.Fatal(err)
+		}
+		stored, err := webhookRepo.FindByID(event.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Status != models.WebhookEventPending || stored.Attempts != attempt || !stored.NextAttemptAt.Equal(now.Add(delay)) {
+			t.Fatalf("tentativa %d: status %s, %d tentativas, próxima em %v; want %s em %v", attempt,
+				stored.Status, stored.Attempts, stored.NextAttemptAt, models.WebhookEventPending, now.Add(delay))
+		}
+		now = now.Add(delay)
+		delay *= 2
+	}
+
+	service.now = func() time.Time { return now }
+	if err := service.ProcessDue(); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := webhookRepo.FindByID(event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.WebhookEventFailed || stored.LastError == "" || stored.NextAttemptAt != nil {
+		t.Fatalf("após %d tentativas: status %s, erro %q; want %s", WebhookMaxAttempts, stored.Status, stored.LastError, models.WebhookEventFailed)
+	}
+
+	// O reprocessamento devolve o evento à fila; um evento sem efeito não é repetido
+	if err := webhookRepo.Retry(stored, now); err != nil {
+		t.Fatal(err)
+	}
+	source.err = ErrWebhookEventIgnored
+	if err := service.ProcessDue(); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err = webhookRepo.FindByID(event.ID); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.WebhookEventIgnored || stored.Attempts != 1 || stored.ProcessedAt == nil {
+		t.Errorf("após o reprocessamento: status %s com %d tentativas; want %s com 1", stored.Status, stored.Attempts, models.WebhookEventIgnored)
+	}
+	if err := webhookRepo.Retry(&models.WebhookEvent{ID: event.ID + 1}, now); !errors.Is(err, repositories.ErrWebhookEventNotRetry) {
+		t.Errorf("Retry de evento inexistente: %v, want %v", err, repositories.ErrWebhookEventNotRetry)
+	}
+}
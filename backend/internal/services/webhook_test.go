@@ -0,0 +1,76 @@
+package services_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/services"
+	"github.com/xclean/backend/internal/testutil"
+	"github.com/xclean/backend/internal/testutil/apptest"
+)
+
+func TestWebhookIngestRejectsReplayedSignature(t *testing.T) {
+	db := testutil.NewDB(t)
+	service := services.NewWebhookService(repositories.NewWebhookRepository(db))
+	service.Register("stub", &apptest.WebhookSource{Secret: "segredo"})
+
+	body := []byte(`{"pix":[]}`)
+	signed := func(at time.Time) http.Header {
+		timestamp := strconv.FormatInt(at.Unix(), 10)
+		header := http.Header{}
+		header.Set(services.PixTimestampHeader, timestamp)
+		header.Set(services.PixSignatureHeader, services.SignPixWebhook("segredo", timestamp, body))
+		return header
+	}
+
+	now := time.Now()
+	first := signed(now)
+	tests := []struct {
+		name          string
+		header        http.Header
+		wantReceived  int
+		wantDuplicate int
+	}{
+		{"primeira entrega", first, 1, 0},
+		{"mesma notificação repetida", first, 0, 1},
+		{"nova assinatura", signed(now.Add(-time.Second)), 1, 0},
+	}
+	for _, tt := range tests {
+		receipt, err := service.Ingest("stub", tt.header, body)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if receipt.Received != tt.wantReceived || receipt.Duplicates != tt.wantDuplicate {
+			t.Errorf("%s: recibo %+v; want %d recebidos, %d duplicados", tt.name, receipt, tt.wantReceived, tt.wantDuplicate)
+		}
+	}
+
+	// Espera o processamento em segundo plano antes de o banco ser fechado
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var processed int64
+		db.Model(&models.WebhookEvent{}).Where("status = ?", models.WebhookEventProcessed).Count(&processed)
+		if processed == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d eventos processados; want 2", processed)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Assinaturas expiradas são apagadas pela tarefa periódica
+	db.Model(&models.WebhookDelivery{}).Where("1 = 1").Update("expires_at", now.Add(-time.Second))
+	if err := service.ProcessDue(); err != nil {
+		t.Fatal(err)
+	}
+	var deliveries int64
+	db.Model(&models.WebhookDelivery{}).Count(&deliveries)
+	if deliveries != 0 {
+		t.Errorf("%d assinaturas mantidas após a retenção; want 0", deliveries)
+	}
+}
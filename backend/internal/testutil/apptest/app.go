@@ -0,0 +1,102 @@
+// Package apptest monta os repositórios e serviços da API sobre um banco de teste, como
+// cmd/api faz em produção, com o gateway, o PSP e o provedor de repasses em memória.
+// Os testes internos de services não podem usá-lo, pois ele importa o pacote services.
+package apptest
+
+import (
+	"testing"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/repositories"
+	"github.com/xclean/backend/internal/services"
+	"github.com/xclean/backend/internal/testutil"
+	"gorm.io/gorm"
+)
+
+// WebhookSecret é o segredo das notificações do gateway nos testes
+const WebhookSecret = "test-webhook-secret"
+
+// App reúne os repositórios e serviços montados sobre o mesmo banco de teste
+type App struct {
+	DB *gorm.DB
+
+	UserRepo        *repositories.UserRepository
+	AppointmentRepo *repositories.AppointmentRepository
+	RescheduleRepo  *repositories.RescheduleRepository
+	SeriesRepo      *repositories.SeriesRepository
+	WaitlistRepo    *repositories.WaitlistRepository
+	TermsRepo       *repositories.TermsRepository
+	PaymentRepo     *repositories.PaymentRepository
+	LedgerRepo      *repositories.LedgerRepository
+	WebhookRepo     *repositories.WebhookRepository
+	IdempotencyRepo *repositories.IdempotencyRepository
+
+	AuthService           *services.AuthService
+	CancellationService   *services.CancellationService
+	PricingService        *services.PricingService
+	AuditService          *services.AuditService
+	BookingRulesService   *services.BookingRulesService
+	AvailabilityService   *services.AvailabilityService
+	WaitlistService       *services.WaitlistService
+	SeriesService         *services.SeriesService
+	InstantBookingService *services.InstantBookingService
+	LedgerService         *services.LedgerService
+	PaymentService        *services.PaymentService
+	WebhookService        *services.WebhookService
+}
+
+// New monta a aplicação sobre um banco de teste novo
+func New(t testing.TB) *App {
+	t.Helper()
+	db := testutil.NewDB(t)
+	travel := services.NewHaversineTravelEstimator(services.DefaultAverageSpeedKmh)
+
+	app := &App{
+		DB:              db,
+		UserRepo:        repositories.NewUserRepository(db),
+		AppointmentRepo: repositories.NewAppointmentRepository(db, travel),
+		RescheduleRepo:  repositories.NewRescheduleRepository(db, travel),
+		SeriesRepo:      repositories.NewSeriesRepository(db, travel),
+		WaitlistRepo:    repositories.NewWaitlistRepository(db, travel),
+		TermsRepo:       repositories.NewTermsRepository(db),
+		PaymentRepo:     repositories.NewPaymentRepository(db),
+		LedgerRepo:      repositories.NewLedgerRepository(db),
+		WebhookRepo:     repositories.NewWebhookRepository(db),
+		IdempotencyRepo: repositories.NewIdempotencyRepository(db),
+
+		AuthService:         services.NewAuthService("test-secret"),
+		CancellationService: services.NewCancellationService(),
+		PricingService:      services.NewPricingService(),
+		AuditService:        services.NewAuditService(repositories.NewAppointmentEventRepository(db)),
+		BookingRulesService: services.NewBookingRulesService(),
+	}
+
+	tipRepo := repositories.NewTipRepository(db)
+	app.AvailabilityService = services.NewAvailabilityService(app.AppointmentRepo, app.UserRepo, app.WaitlistRepo,
+		repositories.NewExternalCalendarRepository(db), app.BookingRulesService)
+	app.WaitlistService = services.NewWaitlistService(app.WaitlistRepo, app.UserRepo, app.AvailabilityService,
+		app.PricingService, app.BookingRulesService, app.AuditService)
+	app.SeriesService = services.NewSeriesService(app.SeriesRepo, app.UserRepo, app.PricingService, app.AuditService)
+	app.InstantBookingService = services.NewInstantBookingService(app.AppointmentRepo)
+	app.LedgerService = services.NewLedgerService(app.LedgerRepo, app.PaymentRepo, tipRepo)
+	commission := services.NewCommissionService(repositories.NewCommissionRepository(db), app.UserRepo, tipRepo)
+	promotions := services.NewPromotionService(repositories.NewPromotionRepository(db), app.LedgerRepo, app.UserRepo,
+		app.AppointmentRepo, app.LedgerService, app.PricingService)
+	app.PaymentService = services.NewPaymentService(app.PaymentRepo, app.AppointmentRepo, app.TermsRepo,
+		services.NewFakePaymentGateway(), services.NewFakePixProvider(),
+		services.PixConfig{MerchantName: "XCLEAN", MerchantCity: "SAO PAULO"},
+		app.AuditService, app.LedgerService, commission, promotions)
+	app.WebhookService = services.NewWebhookService(app.WebhookRepo)
+	app.WebhookService.Register(services.GatewayWebhookProvider, services.NewGatewayWebhookSource(WebhookSecret, app.PaymentService))
+	return app
+}
+
+// Bearer gera o header Authorization do usuário
+func (a *App) Bearer(t testing.TB, user *models.User) string {
+	t.Helper()
+	token, err := a.AuthService.GenerateToken(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "Bearer " + token
+}
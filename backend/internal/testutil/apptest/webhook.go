@@ -0,0 +1,45 @@
+package apptest
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/xclean/backend/internal/models"
+	"github.com/xclean/backend/internal/services"
+)
+
+// WebhookSource é um provedor de webhooks de teste. Com Secret, exige a assinatura de
+// SignPixWebhook; sem ele, aceita qualquer notificação. Cada notificação gera um evento
+// com ID novo, evt_1, evt_2..., para que apenas a assinatura identifique repetições.
+// Handle, se definido, substitui o processamento bem-sucedido dos eventos.
+type WebhookSource struct {
+	Secret string
+	Handle func(event *models.WebhookEvent) error
+
+	events atomic.Int64
+}
+
+func (s *WebhookSource) Verify(header http.Header, body []byte, now time.Time) error {
+	if s.Secret == "" {
+		return nil
+	}
+	return services.VerifyPixWebhook(s.Secret, header.Get(services.PixTimestampHeader), header.Get(services.PixSignatureHeader), body, now)
+}
+
+func (s *WebhookSource) Signature(header http.Header) string {
+	return header.Get(services.PixSignatureHeader)
+}
+
+func (s *WebhookSource) Parse(body []byte) ([]services.WebhookEventInput, error) {
+	id := fmt.Sprintf("evt_%d", s.events.Add(1))
+	return []services.WebhookEventInput{{ID: id, Type: "teste", Payload: body}}, nil
+}
+
+func (s *WebhookSource) Process(event *models.WebhookEvent) error {
+	if s.Handle == nil {
+		return nil
+	}
+	return s.Handle(event)
+}
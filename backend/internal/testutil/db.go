@@ -0,0 +1,137 @@
+// Package testutil reúne o que os testes dos repositórios, serviços e handlers compartilham.
+package testutil
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/xclean/backend/internal/config"
+	"github.com/xclean/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var dbCounter atomic.Int64
+
+// NewDB cria um banco SQLite em memória, exclusivo do teste, com todas as tabelas migradas.
+// O SQLite não tem bloqueio por linha, então os testes cobrem a lógica, não a concorrência.
+func NewDB(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	dsn := fmt.Sprintf("file:testdb%d?mode=memory&cache=shared&_pragma=foreign_keys(0)", dbCounter.Add(1))
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger:  logger.Default.LogMode(logger.Silent),
+		NowFunc: func() time.Time { return time.Now().UTC() },
+	})
+	if err != nil {
+		t.Fatalf("abrir banco de teste: %v", err)
+	}
+
+	// Uma única conexão mantém o banco em memória vivo e serializa as transações
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("abrir banco de teste: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := config.Migrate(db); err != nil {
+		t.Fatalf("migrar banco de teste: %v", err)
+	}
+	return db
+}
+
+// CreateUser grava um usuário do tipo informado
+func CreateUser(t testing.TB, db *gorm.DB, userType models.UserType) *models.User {
+	t.Helper()
+
+	n := dbCounter.Add(1)
+	user := &models.User{
+		Name:     fmt.Sprintf("Usuário %d", n),
+		Email:    fmt.Sprintf("usuario%d@example.com", n),
+		Password: "hash",
+		UserType: userType,
+		IsActive: true,
+	}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("criar usuário: %v", err)
+	}
+	return user
+}
+
+// CreateProvider grava uma prestadora verificada com o perfil informado
+func CreateProvider(t testing.TB, db *gorm.DB, profile models.ProviderProfile) *models.User {
+	t.Helper()
+
+	provider := CreateUser(t, db, models.UserTypeProvider)
+	profile.UserID = provider.ID
+	profile.IsVerified = true
+	// O SQLite não tem o tipo text[] usado nas roupas permitidas
+	if err := db.Omit("AvailableClothes").Create(&profile).Error; err != nil {
+		t.Fatalf("criar perfil da prestadora: %v", err)
+	}
+	return provider
+}
+
+// CreateAppointment grava um agendamento entre o cliente e a prestadora
+func CreateAppointment(t testing.TB, db *gorm.DB, appointment models.Appointment) *models.Appointment {
+	t.Helper()
+
+	if appointment.Service == "" {
+		appointment.Service = "Limpeza"
+	}
+	if appointment.Time == "" {
+		appointment.Time = appointment.Date.Format("15:04")
+	}
+	if appointment.Status == "" {
+		appointment.Status = models.AppointmentStatusPending
+	}
+	if err := db.Create(&appointment).Error; err != nil {
+		t.Fatalf("criar agendamento: %v", err)
+	}
+	return &appointment
+}
+
+// CreatePayment grava um pagamento. Sem agendamento, grava antes um agendamento entre um
+// cliente e uma prestadora novos; sem intenção ou moeda, recebe uma intenção única e a moeda padrão.
+func CreatePayment(t testing.TB, db *gorm.DB, payment models.Payment) *models.Payment {
+	t.Helper()
+
+	if payment.AppointmentID == 0 {
+		client := CreateUser(t, db, models.UserTypeClient)
+		provider := CreateProvider(t, db, models.ProviderProfile{})
+		appointment := CreateAppointment(t, db, models.Appointment{
+			UserID: client.ID, ProviderID: provider.ID, Date: time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC),
+		})
+		payment.AppointmentID, payment.UserID, payment.ProviderID = appointment.ID, client.ID, provider.ID
+	}
+	if payment.GatewayIntentID == "" {
+		payment.GatewayIntentID = fmt.Sprintf("pi_test_%d", dbCounter.Add(1))
+	}
+	if payment.Currency == "" {
+		payment.Currency = models.PaymentCurrency
+	}
+	if err := db.Create(&payment).Error; err != nil {
+		t.Fatalf("criar pagamento: %v", err)
+	}
+	return &payment
+}
+
+// CreateWaitlistEntry inscreve o cliente na lista de espera da prestadora
+func CreateWaitlistEntry(t testing.TB, db *gorm.DB, entry models.WaitlistEntry) *models.WaitlistEntry {
+	t.Helper()
+
+	if entry.Service == "" {
+		entry.Service = "Limpeza"
+	}
+	if entry.Status == "" {
+		entry.Status = models.WaitlistEntryStatusWaiting
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		t.Fatalf("criar inscrição na lista de espera: %v", err)
+	}
+	return &entry
+}
@@ -0,0 +1,68 @@
+package testutil
+
+import (
+	"testing"
+
+	"github.com/xclean/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LedgerEntry é uma partida de PostLedger. Valores positivos debitam e negativos creditam a conta.
+type LedgerEntry struct {
+	Type      models.LedgerAccountType
+	OwnerID   *uint
+	Qualifier string
+	Amount    int64
+}
+
+// LedgerAccount retorna a conta do razão do tipo, da dona e do qualificador, criando-a no
+// primeiro uso com o mesmo código usado pelo serviço do razão
+func LedgerAccount(t testing.TB, db *gorm.DB, accountType models.LedgerAccountType, ownerID *uint, qualifier string) *models.LedgerAccount {
+	t.Helper()
+
+	account := &models.LedgerAccount{
+		Code:     models.LedgerAccountCode(accountType, ownerID, qualifier),
+		Type:     accountType,
+		OwnerID:  ownerID,
+		Currency: models.PaymentCurrency,
+	}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(account).Error; err != nil {
+		t.Fatalf("criar conta do razão: %v", err)
+	}
+	if err := db.Where("code = ?", account.Code).First(account).Error; err != nil {
+		t.Fatalf("buscar conta do razão: %v", err)
+	}
+	return account
+}
+
+// PostLedger grava um lançamento do razão com as partidas informadas, sem conferir o balanço
+func PostLedger(t testing.TB, db *gorm.DB, kind models.LedgerTransactionKind, reference string, entries ...LedgerEntry) *models.LedgerTransaction {
+	t.Helper()
+
+	transaction := &models.LedgerTransaction{Kind: kind, Reference: reference}
+	for _, entry := range entries {
+		account := LedgerAccount(t, db, entry.Type, entry.OwnerID, entry.Qualifier)
+		transaction.Postings = append(transaction.Postings, models.LedgerPosting{AccountID: account.ID, Amount: entry.Amount})
+	}
+	if err := db.Create(transaction).Error; err != nil {
+		t.Fatalf("lançar no razão: %v", err)
+	}
+	return transaction
+}
+
+// LedgerBalance retorna o saldo da conta do razão do tipo e da dona informados. Saldos
+// credores, como o que a plataforma deve à prestadora, são negativos.
+func LedgerBalance(t testing.TB, db *gorm.DB, accountType models.LedgerAccountType, ownerID *uint) int64 {
+	t.Helper()
+
+	var balance int64
+	err := db.Model(&models.LedgerPosting{}).
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_postings.account_id").
+		Where("ledger_accounts.code = ?", models.LedgerAccountCode(accountType, ownerID, "")).
+		Select("COALESCE(SUM(ledger_postings.amount), 0)").Scan(&balance).Error
+	if err != nil {
+		t.Fatalf("saldo do razão: %v", err)
+	}
+	return balance
+}